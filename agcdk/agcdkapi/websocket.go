@@ -0,0 +1,266 @@
+// Package agcdkapi provides an API Gateway WebSocket API whose routes are handled by backend
+// commands running as Lambda functions.
+//
+// Every route maps to a command in backend/cmd, which runs from the image that
+// 'ago backend build-and-push' pushed for the deployment. Routes that map to the same command
+// share one function. A DynamoDB table, keyed by ConnectionIDAttribute, holds the open
+// connections: the $connect handler stores them and the $disconnect handler removes them. The
+// handlers find the table and the callback URL of the API in their environment:
+//
+//	agcdkapi.NewWebSocket(stack, agcdkapi.WebSocketProps{
+//		Name:       "chat",
+//		Deployment: deploymentIdent,
+//		Repository: shared.Base.Repositories().MainRepository(),
+//		Routes: map[string]string{
+//			agcdkapi.RouteConnect:    "chat-connections",
+//			agcdkapi.RouteDisconnect: "chat-connections",
+//			agcdkapi.RouteDefault:    "chat",
+//		},
+//	})
+//
+// The URLs and the table are recorded with agcdkutil.Output, which is how `ago ws send` and
+// `ago ws broadcast` find them:
+//
+//	ago ws broadcast --deployment DevAdam --api chat --message '{"hello":"world"}'
+package agcdkapi
+
+import (
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+
+	"github.com/advdv/ago/agcdkutil"
+	"github.com/aws/aws-cdk-go/awscdk/v2"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awsapigatewayv2"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awsapigatewayv2integrations"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awsdynamodb"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awsecr"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awslambda"
+	"github.com/aws/constructs-go/constructs/v10"
+	"github.com/aws/jsii-runtime-go"
+	"github.com/iancoleman/strcase"
+)
+
+// The route keys that API Gateway predefines. Any other key is a custom route, selected by the
+// "action" field of the messages that clients send.
+const (
+	RouteConnect    = "$connect"
+	RouteDisconnect = "$disconnect"
+	RouteDefault    = "$default"
+)
+
+const (
+	// ConnectionIDAttribute is the partition key of the connections table. Handlers store the
+	// connection ID of the request context under it.
+	ConnectionIDAttribute = "connectionId"
+	// ConnectionsTableEnv is the environment variable that holds the name of the connections
+	// table in every handler.
+	ConnectionsTableEnv = "WS_CONNECTIONS_TABLE"
+	// CallbackURLEnv is the environment variable that holds the URL handlers post messages for
+	// connections to, through the API Gateway management API.
+	CallbackURLEnv = "WS_CALLBACK_URL"
+
+	defaultTimeoutSeconds = 29
+	defaultMemorySize     = 512
+)
+
+var (
+	nameRegex  = regexp.MustCompile(`^[a-z][a-z0-9-]*$`)
+	routeRegex = regexp.MustCompile(`^(\$connect|\$disconnect|\$default|[A-Za-z][A-Za-z0-9_-]*)$`)
+)
+
+// URLOutputKey returns the output key under which the wss:// URL that clients connect to of the
+// named WebSocket API is recorded.
+func URLOutputKey(name string) string {
+	return "WebSocket" + strcase.ToCamel(name) + "URL"
+}
+
+// CallbackURLOutputKey returns the output key under which the https:// URL of the API Gateway
+// management API of the named WebSocket API is recorded.
+func CallbackURLOutputKey(name string) string {
+	return "WebSocket" + strcase.ToCamel(name) + "CallbackURL"
+}
+
+// ConnectionsTableOutputKey returns the output key under which the name of the connections table
+// of the named WebSocket API is recorded.
+func ConnectionsTableOutputKey(name string) string {
+	return "WebSocket" + strcase.ToCamel(name) + "ConnectionsTable"
+}
+
+// WebSocket provides access to a WebSocket API, its connections table and its handlers.
+type WebSocket interface {
+	// API returns the WebSocket API.
+	API() awsapigatewayv2.WebSocketApi
+	// Stage returns the stage that clients connect to.
+	Stage() awsapigatewayv2.WebSocketStage
+	// ConnectionsTable returns the table that holds the open connections.
+	ConnectionsTable() awsdynamodb.TableV2
+	// Handler returns the function that runs the named command, or nil when no route maps to it.
+	Handler(cmd string) awslambda.IFunction
+}
+
+// WebSocketProps configures the WebSocket construct.
+type WebSocketProps struct {
+	// Name identifies the API within its stack, such as "chat". It must start with a lowercase
+	// letter and contain only lowercase letters, numbers and dashes.
+	Name string
+
+	// Deployment is the deployment the API belongs to. It selects the image tag of the handlers.
+	Deployment string
+
+	// Repository holds the images pushed by 'ago backend build-and-push', usually the main
+	// repository of the shared stack.
+	Repository awsecr.IRepository
+
+	// Routes maps route keys, such as RouteConnect or "sendMessage", to the backend command that
+	// handles them. It must at least map RouteDefault or one custom route.
+	Routes map[string]string
+
+	// Environment sets extra environment variables of the handlers.
+	Environment map[string]*string
+
+	// Timeout of the handlers. Defaults to 29 seconds, the integration timeout of API Gateway.
+	Timeout awscdk.Duration
+
+	// MemorySize of the handlers in MiB. Defaults to 512.
+	MemorySize *float64
+}
+
+type webSocket struct {
+	api      awsapigatewayv2.WebSocketApi
+	stage    awsapigatewayv2.WebSocketStage
+	table    awsdynamodb.TableV2
+	handlers map[string]awslambda.IFunction
+}
+
+// NewWebSocket creates a WebSocket API with a function per command in Routes and a connections
+// table, and records its URLs and table as stack outputs. It panics when the name, a route key or
+// a command name is invalid.
+func NewWebSocket(scope constructs.Construct, props WebSocketProps) WebSocket {
+	validateWebSocketProps(props)
+
+	scope = constructs.NewConstruct(scope, jsii.String("WebSocket"+strcase.ToCamel(props.Name)))
+	stack := awscdk.Stack_Of(scope)
+
+	timeout := props.Timeout
+	if timeout == nil {
+		timeout = awscdk.Duration_Seconds(jsii.Number(defaultTimeoutSeconds))
+	}
+	memorySize := props.MemorySize
+	if memorySize == nil {
+		memorySize = jsii.Number(defaultMemorySize)
+	}
+
+	con := &webSocket{handlers: map[string]awslambda.IFunction{}}
+	con.table = awsdynamodb.NewTableV2(scope, jsii.String("Connections"), &awsdynamodb.TablePropsV2{
+		PartitionKey: &awsdynamodb.Attribute{
+			Name: jsii.String(ConnectionIDAttribute),
+			Type: awsdynamodb.AttributeType_STRING,
+		},
+		Billing:       awsdynamodb.Billing_OnDemand(nil),
+		RemovalPolicy: awscdk.RemovalPolicy_DESTROY,
+	})
+
+	con.api = awsapigatewayv2.NewWebSocketApi(scope, jsii.String("Api"), &awsapigatewayv2.WebSocketApiProps{
+		ApiName: jsii.String(*stack.StackName() + "-" + props.Name),
+	})
+	con.stage = awsapigatewayv2.NewWebSocketStage(scope, jsii.String("Stage"), &awsapigatewayv2.WebSocketStageProps{
+		WebSocketApi: con.api,
+		StageName:    jsii.String("live"),
+		AutoDeploy:   jsii.Bool(true),
+	})
+
+	for _, cmd := range slices.Sorted(maps.Values(props.Routes)) {
+		if _, ok := con.handlers[cmd]; ok {
+			continue
+		}
+
+		env := map[string]*string{
+			ConnectionsTableEnv: con.table.TableName(),
+			CallbackURLEnv:      con.stage.CallbackUrl(),
+		}
+		maps.Copy(env, props.Environment)
+
+		handler := awslambda.NewDockerImageFunction(scope, jsii.String("Handler"+strcase.ToCamel(cmd)),
+			&awslambda.DockerImageFunctionProps{
+				Code: awslambda.DockerImageCode_FromEcr(props.Repository, &awslambda.EcrImageCodeProps{
+					TagOrDigest: jsii.String(agcdkutil.ImageTag(scope, props.Deployment, cmd)),
+				}),
+				Architecture: awslambda.Architecture_ARM_64(),
+				Environment:  &env,
+				Timeout:      timeout,
+				MemorySize:   memorySize,
+			})
+		con.table.GrantReadWriteData(handler)
+		con.stage.GrantManagementApiAccess(handler)
+		con.handlers[cmd] = handler
+	}
+
+	for _, key := range slices.Sorted(maps.Keys(props.Routes)) {
+		cmd := props.Routes[key]
+		con.api.AddRoute(jsii.String(key), &awsapigatewayv2.WebSocketRouteOptions{
+			Integration: awsapigatewayv2integrations.NewWebSocketLambdaIntegration(
+				jsii.String("Route"+strcase.ToCamel(routeIdent(key))), con.handlers[cmd], nil),
+		})
+	}
+
+	agcdkutil.Output(stack, URLOutputKey(props.Name), con.stage.Url(),
+		agcdkutil.OutputOptions{Description: "URL that clients of the " + props.Name + " WebSocket API connect to"})
+	agcdkutil.Output(stack, CallbackURLOutputKey(props.Name), con.stage.CallbackUrl(),
+		agcdkutil.OutputOptions{Description: "Management API URL of the " + props.Name + " WebSocket API"})
+	agcdkutil.Output(stack, ConnectionsTableOutputKey(props.Name), con.table.TableName(),
+		agcdkutil.OutputOptions{Description: "Connections table of the " + props.Name + " WebSocket API"})
+
+	return con
+}
+
+// validateWebSocketProps panics when the name, a route key or a command name is invalid.
+func validateWebSocketProps(props WebSocketProps) {
+	if !nameRegex.MatchString(props.Name) {
+		panic(fmt.Sprintf("agcdkapi: invalid WebSocket API name %q: must start with a lowercase letter "+
+			"and contain only lowercase letters, numbers and dashes", props.Name))
+	}
+
+	handlesMessages := false
+	for key, cmd := range props.Routes {
+		if !routeRegex.MatchString(key) {
+			panic(fmt.Sprintf("agcdkapi: invalid route key %q of the %s WebSocket API", key, props.Name))
+		}
+		if !nameRegex.MatchString(cmd) {
+			panic(fmt.Sprintf("agcdkapi: invalid command %q for route %q: must start with a lowercase letter "+
+				"and contain only lowercase letters, numbers and dashes", cmd, key))
+		}
+		if key != RouteConnect && key != RouteDisconnect {
+			handlesMessages = true
+		}
+	}
+	if !handlesMessages {
+		panic(fmt.Sprintf("agcdkapi: the %s WebSocket API must route %s or a custom route to a command",
+			props.Name, RouteDefault))
+	}
+}
+
+// routeIdent turns a route key into a construct ID part, dropping the "$" of predefined routes.
+func routeIdent(key string) string {
+	if key[0] == '$' {
+		return key[1:]
+	}
+	return key
+}
+
+func (w *webSocket) API() awsapigatewayv2.WebSocketApi {
+	return w.api
+}
+
+func (w *webSocket) Stage() awsapigatewayv2.WebSocketStage {
+	return w.stage
+}
+
+func (w *webSocket) ConnectionsTable() awsdynamodb.TableV2 {
+	return w.table
+}
+
+func (w *webSocket) Handler(cmd string) awslambda.IFunction {
+	return w.handlers[cmd]
+}
@@ -0,0 +1,257 @@
+// Package agcdkdb provides an Aurora Serverless v2 PostgreSQL construct for relational storage.
+//
+// Clients reach the cluster through an RDS Proxy that requires TLS and IAM authentication, so
+// Lambda functions and Fargate services share a small pool of database connections. The
+// credentials live in Secrets Manager under the deployment secret name that 'ago secrets' reads,
+// and are rotated on a schedule. The Data API is enabled for clients outside the VPC:
+//
+//	db := agcdkdb.New(stack, agcdkdb.Props{
+//		Name:       "main",
+//		Deployment: deploymentIdent,
+//		Vpc:        svc.Service().Cluster().Vpc(),
+//	})
+//	db.AllowFrom(svc.Service())
+//	db.GrantConnect(svc.Service().TaskDefinition().TaskRole())
+//
+// where svc is an agcdkservice.Service. A Lambda function in the same VPC is both the peer and
+// the grantee. The proxy endpoint, the secret and the cluster are recorded with agcdkutil.Output
+// under ProxyEndpointOutputKey, SecretARNOutputKey and ClusterARNOutputKey.
+//
+// The cluster of a restricted deployment has deletion protection, a reader in a second
+// availability zone and a snapshot when it is removed, and is marked with agcdkutil.Protect.
+// Other deployments get a single writer that is destroyed with the stack.
+package agcdkdb
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+
+	"github.com/advdv/ago/agcdkutil"
+	"github.com/aws/aws-cdk-go/awscdk/v2"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awsec2"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awsiam"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awsrds"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awssecretsmanager"
+	"github.com/aws/constructs-go/constructs/v10"
+	"github.com/aws/jsii-runtime-go"
+	"github.com/iancoleman/strcase"
+)
+
+const (
+	defaultMinCapacity          = 0.5
+	defaultMaxCapacity          = 2
+	defaultRotationIntervalDays = 30
+	defaultBackupRetentionDays  = 7
+	restrictedBackupRetention   = 30
+	masterUsername              = "postgres"
+)
+
+var nameRegex = regexp.MustCompile(`^[a-z][a-z0-9-]*$`)
+
+// ProxyEndpointOutputKey returns the output key under which the proxy endpoint of the named
+// database is recorded.
+func ProxyEndpointOutputKey(name string) string {
+	return "Database" + strcase.ToCamel(name) + "ProxyEndpoint"
+}
+
+// SecretARNOutputKey returns the output key under which the ARN of the credentials secret of
+// the named database is recorded.
+func SecretARNOutputKey(name string) string {
+	return "Database" + strcase.ToCamel(name) + "SecretARN"
+}
+
+// ClusterARNOutputKey returns the output key under which the ARN of the cluster of the named
+// database is recorded. The Data API takes it as the resource ARN.
+func ClusterARNOutputKey(name string) string {
+	return "Database" + strcase.ToCamel(name) + "ClusterARN"
+}
+
+// SecretName returns the name of the credentials secret of the named database, which is the
+// deployment secret "database-<name>".
+func SecretName(qualifier, deployment, name string) string {
+	return qualifier + "/" + deployment + "/database-" + name
+}
+
+// Database provides access to an Aurora Serverless v2 cluster and its proxy.
+type Database interface {
+	// Cluster returns the Aurora cluster.
+	Cluster() awsrds.DatabaseCluster
+	// Proxy returns the RDS Proxy that clients connect through.
+	Proxy() awsrds.DatabaseProxy
+	// Secret returns the secret that holds the rotated credentials of the cluster.
+	Secret() awssecretsmanager.ISecret
+	// Vpc returns the VPC that the cluster and proxy run in.
+	Vpc() awsec2.IVpc
+	// AllowFrom opens the proxy port to the security groups of the peer, such as a Lambda
+	// function or Fargate service in the same VPC.
+	AllowFrom(peer awsec2.IConnectable)
+	// GrantConnect allows the grantee to connect to the proxy with IAM authentication as the
+	// database user, and to read the credentials secret.
+	GrantConnect(grantee awsiam.IGrantable)
+	// GrantDataAPI allows the grantee to run statements through the Data API.
+	GrantDataAPI(grantee awsiam.IGrantable)
+}
+
+// Props configures the Database construct.
+type Props struct {
+	// Name identifies the database within its stack, such as "main". It must start with a
+	// lowercase letter and contain only lowercase letters, numbers and dashes.
+	Name string
+
+	// Deployment is the deployment the database belongs to. Restricted deployments get a
+	// protected, highly available cluster.
+	Deployment string
+
+	// Vpc runs the cluster and the proxy in its private subnets. Defaults to a new VPC over two
+	// availability zones with a single NAT gateway.
+	Vpc awsec2.IVpc
+
+	// DatabaseName is the name of the database that is created in the cluster. Defaults to the
+	// name with dashes replaced by underscores.
+	DatabaseName string
+
+	// MinCapacity and MaxCapacity bound the Aurora capacity units of each instance. They
+	// default to 0.5 and 2.
+	MinCapacity *float64
+	MaxCapacity *float64
+
+	// RotationInterval is how often the credentials are rotated. Defaults to 30 days.
+	RotationInterval awscdk.Duration
+}
+
+type database struct {
+	cluster awsrds.DatabaseCluster
+	proxy   awsrds.DatabaseProxy
+	vpc     awsec2.IVpc
+}
+
+// New creates an Aurora Serverless v2 PostgreSQL cluster with rotated credentials behind an RDS
+// Proxy, and records the proxy endpoint, secret and cluster as stack outputs. It panics when the
+// name is invalid.
+func New(scope constructs.Construct, props Props) Database {
+	if !nameRegex.MatchString(props.Name) {
+		panic(fmt.Sprintf("agcdkdb: invalid database name %q: must start with a lowercase letter "+
+			"and contain only lowercase letters, numbers and dashes", props.Name))
+	}
+
+	scope = constructs.NewConstruct(scope, jsii.String("Database"+strcase.ToCamel(props.Name)))
+	stack := awscdk.Stack_Of(scope)
+	cfg := agcdkutil.ConfigFromScope(scope)
+	restricted := slices.Contains(cfg.RestrictedDeployments, props.Deployment)
+
+	con := &database{vpc: props.Vpc}
+	if con.vpc == nil {
+		con.vpc = awsec2.NewVpc(scope, jsii.String("Vpc"), &awsec2.VpcProps{
+			MaxAzs:      jsii.Number(2),
+			NatGateways: jsii.Number(1),
+		})
+	}
+	subnets := &awsec2.SubnetSelection{SubnetType: awsec2.SubnetType_PRIVATE_WITH_EGRESS}
+
+	databaseName := props.DatabaseName
+	if databaseName == "" {
+		databaseName = strcase.ToSnake(props.Name)
+	}
+
+	clusterProps := &awsrds.DatabaseClusterProps{
+		Engine: awsrds.DatabaseClusterEngine_AuroraPostgres(&awsrds.AuroraPostgresClusterEngineProps{
+			Version: awsrds.AuroraPostgresEngineVersion_VER_16_4(),
+		}),
+		Credentials: awsrds.Credentials_FromGeneratedSecret(jsii.String(masterUsername),
+			&awsrds.CredentialsBaseOptions{
+				SecretName: jsii.String(SecretName(cfg.Qualifier, props.Deployment, props.Name)),
+			}),
+		DefaultDatabaseName:     jsii.String(databaseName),
+		Writer:                  awsrds.ClusterInstance_ServerlessV2(jsii.String("Writer"), nil),
+		ServerlessV2MinCapacity: orDefault(props.MinCapacity, defaultMinCapacity),
+		ServerlessV2MaxCapacity: orDefault(props.MaxCapacity, defaultMaxCapacity),
+		Vpc:                     con.vpc,
+		VpcSubnets:              subnets,
+		StorageEncrypted:        jsii.Bool(true),
+		EnableDataApi:           jsii.Bool(true),
+		IamAuthentication:       jsii.Bool(true),
+		Backup: &awsrds.BackupProps{
+			Retention: awscdk.Duration_Days(jsii.Number(defaultBackupRetentionDays)),
+		},
+		DeletionProtection: jsii.Bool(false),
+		RemovalPolicy:      awscdk.RemovalPolicy_DESTROY,
+	}
+	if restricted {
+		clusterProps.Readers = &[]awsrds.IClusterInstance{
+			awsrds.ClusterInstance_ServerlessV2(jsii.String("Reader"), &awsrds.ServerlessV2ClusterInstanceProps{
+				ScaleWithWriter: jsii.Bool(true),
+			}),
+		}
+		clusterProps.Backup.Retention = awscdk.Duration_Days(jsii.Number(restrictedBackupRetention))
+		clusterProps.DeletionProtection = jsii.Bool(true)
+		clusterProps.RemovalPolicy = awscdk.RemovalPolicy_SNAPSHOT
+	}
+
+	con.cluster = awsrds.NewDatabaseCluster(scope, jsii.String("Cluster"), clusterProps)
+	if restricted {
+		agcdkutil.Protect(con.cluster, "database "+props.Name+" holds the data of "+props.Deployment)
+	}
+
+	rotationInterval := props.RotationInterval
+	if rotationInterval == nil {
+		rotationInterval = awscdk.Duration_Days(jsii.Number(defaultRotationIntervalDays))
+	}
+	con.cluster.AddRotationSingleUser(&awsrds.RotationSingleUserOptions{
+		AutomaticallyAfter: rotationInterval,
+		VpcSubnets:         subnets,
+	})
+
+	con.proxy = con.cluster.AddProxy(jsii.String("Proxy"), &awsrds.DatabaseProxyOptions{
+		Secrets:    &[]awssecretsmanager.ISecret{con.cluster.Secret()},
+		Vpc:        con.vpc,
+		VpcSubnets: subnets,
+		RequireTLS: jsii.Bool(true),
+		IamAuth:    jsii.Bool(true),
+	})
+
+	agcdkutil.Output(stack, ProxyEndpointOutputKey(props.Name), con.proxy.Endpoint(),
+		agcdkutil.OutputOptions{Description: "Proxy endpoint of the " + props.Name + " database"})
+	agcdkutil.Output(stack, SecretARNOutputKey(props.Name), con.cluster.Secret().SecretArn(),
+		agcdkutil.OutputOptions{Description: "ARN of the credentials secret of the " + props.Name + " database"})
+	agcdkutil.Output(stack, ClusterARNOutputKey(props.Name), con.cluster.ClusterArn(),
+		agcdkutil.OutputOptions{Description: "ARN of the cluster of the " + props.Name + " database"})
+
+	return con
+}
+
+func orDefault(value *float64, def float64) *float64 {
+	if value == nil {
+		return jsii.Number(def)
+	}
+	return value
+}
+
+func (d *database) Cluster() awsrds.DatabaseCluster {
+	return d.cluster
+}
+
+func (d *database) Proxy() awsrds.DatabaseProxy {
+	return d.proxy
+}
+
+func (d *database) Secret() awssecretsmanager.ISecret {
+	return d.cluster.Secret()
+}
+
+func (d *database) Vpc() awsec2.IVpc {
+	return d.vpc
+}
+
+func (d *database) AllowFrom(peer awsec2.IConnectable) {
+	d.proxy.Connections().AllowDefaultPortFrom(peer, jsii.String("Database clients"))
+}
+
+func (d *database) GrantConnect(grantee awsiam.IGrantable) {
+	d.proxy.GrantConnect(grantee, jsii.String(masterUsername))
+	d.cluster.Secret().GrantRead(grantee, nil)
+}
+
+func (d *database) GrantDataAPI(grantee awsiam.IGrantable) {
+	d.cluster.GrantDataApiAccess(grantee)
+}
@@ -0,0 +1,70 @@
+package agcdkdns
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/advdv/ago/agcdkutil"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awscertificatemanager"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awsroute53"
+	"github.com/aws/constructs-go/constructs/v10"
+	"github.com/aws/jsii-runtime-go"
+	"github.com/iancoleman/strcase"
+)
+
+var certificateNameRegex = regexp.MustCompile(`^[a-z][a-z0-9-]*$`)
+
+// CertificateProps configures a certificate created with NewCertificate.
+type CertificateProps struct {
+	// Name identifies the certificate within its scope, such as "api". It must start with a
+	// lowercase letter and contain only lowercase letters, numbers and dashes.
+	Name string
+
+	// HostedZone validates the certificate, usually DNS.HostedZone(). Required.
+	HostedZone awsroute53.IHostedZone
+
+	// DomainName is the domain name of the certificate, such as "api.example.com". Defaults to
+	// the wildcard of the zone, "*.example.com".
+	DomainName string
+
+	// SubjectAlternativeNames are additional domain names of the certificate.
+	SubjectAlternativeNames []string
+}
+
+// NewCertificate creates an ACM certificate that is validated with DNS records in the hosted
+// zone, or returns nil while the dns-delegated context flag is not set. Until the parent zone
+// delegates to the hosted zone, resolvers cannot see the validation records and the deploy would
+// wait for a validation that never completes. 'ago infra org dns-verify' sets the flag once the
+// delegation works, and the next deploy creates the certificate. Certificates are regional, so
+// every region that needs one creates its own.
+//
+// It panics when the name is invalid or the hosted zone is missing.
+func NewCertificate(scope constructs.Construct, props CertificateProps) awscertificatemanager.ICertificate {
+	if !certificateNameRegex.MatchString(props.Name) {
+		panic(fmt.Sprintf("agcdkdns: invalid certificate name %q: must start with a lowercase letter "+
+			"and contain only lowercase letters, numbers and dashes", props.Name))
+	}
+	if props.HostedZone == nil {
+		panic(fmt.Sprintf("agcdkdns: certificate %q requires a HostedZone", props.Name))
+	}
+
+	if !agcdkutil.DNSDelegated(scope) {
+		return nil
+	}
+
+	domainName := props.DomainName
+	if domainName == "" {
+		domainName = "*." + *props.HostedZone.ZoneName()
+	}
+
+	certProps := &awscertificatemanager.CertificateProps{
+		DomainName: jsii.String(domainName),
+		Validation: awscertificatemanager.CertificateValidation_FromDns(props.HostedZone),
+	}
+	if len(props.SubjectAlternativeNames) > 0 {
+		certProps.SubjectAlternativeNames = jsii.Strings(props.SubjectAlternativeNames...)
+	}
+
+	return awscertificatemanager.NewCertificate(scope,
+		jsii.String("Certificate"+strcase.ToCamel(props.Name)), certProps)
+}
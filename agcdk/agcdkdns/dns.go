@@ -2,21 +2,33 @@
 //
 // The DNS construct creates a hosted zone in the primary region and stores its ID
 // in SSM Parameter Store. Secondary regions look up the stored ID to reference
-// the same zone without recreating it.
+// the same zone without recreating it. The name servers of the zone are recorded
+// under NameServersOutputKey, which 'ago infra org dns-delegate' reads to delegate
+// the zone from its parent.
+//
+// NewCertificate creates DNS-validated certificates in the zone once the delegation
+// works, as recorded by the dns-delegated context flag:
+//
+//	zone := agcdkdns.New(stack, agcdkdns.Props{})
+//	if cert := zone.Certificate("api", "api."+agcdkutil.BaseDomainName(stack)); cert != nil {
+//		// serve the API over HTTPS
+//	}
 package agcdkdns
 
 import (
 	"github.com/advdv/ago/agcdk/agcdkparams"
 	"github.com/advdv/ago/agcdkutil"
 	"github.com/aws/aws-cdk-go/awscdk/v2"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awscertificatemanager"
 	"github.com/aws/aws-cdk-go/awscdk/v2/awsroute53"
 	"github.com/aws/constructs-go/constructs/v10"
 	"github.com/aws/jsii-runtime-go"
 )
 
 // NameServersOutputKey is the CloudFormation output key for the hosted zone's NS records.
-// Use this with `aws cloudformation describe-stacks` to retrieve the name servers.
-const NameServersOutputKey = "HostedZoneNameServers"
+// Use this with `aws cloudformation describe-stacks` to retrieve the name servers. The CLI
+// reads it from the primary shared stack to delegate and verify the zone.
+const NameServersOutputKey = agcdkutil.NameServersOutputKey
 
 const paramsNamespace = "dns"
 
@@ -26,6 +38,10 @@ type DNS interface {
 	// In the primary region, this is the actual zone.
 	// In secondary regions, this is a reference to the primary zone.
 	HostedZone() awsroute53.IHostedZone
+
+	// Certificate creates a certificate for the domain name, validated in the hosted zone, or
+	// returns nil while the zone is not delegated. See NewCertificate.
+	Certificate(name, domainName string) awscertificatemanager.ICertificate
 }
 
 // Props configures the DNS construct.
@@ -36,6 +52,7 @@ type Props struct {
 }
 
 type dns struct {
+	scope      constructs.Construct
 	hostedZone awsroute53.IHostedZone
 }
 
@@ -48,7 +65,7 @@ type dns struct {
 // to the existing hosted zone.
 func New(scope constructs.Construct, props Props) DNS {
 	scope = constructs.NewConstruct(scope, jsii.String("DNS"))
-	con := &dns{}
+	con := &dns{scope: scope}
 
 	zoneName := props.ZoneDomainName
 	if zoneName == nil {
@@ -67,10 +84,9 @@ func New(scope constructs.Construct, props Props) DNS {
 		agcdkparams.Store(scope, "HostedZoneIDParam", paramsNamespace, "hosted-zone-id",
 			hostedZone.HostedZoneId())
 
-		awscdk.NewCfnOutput(awscdk.Stack_Of(scope), jsii.String(NameServersOutputKey), &awscdk.CfnOutputProps{
-			Value:       awscdk.Fn_Join(jsii.String(","), hostedZone.HostedZoneNameServers()),
-			Description: jsii.String("Comma-separated list of NS records for DNS delegation"),
-		})
+		agcdkutil.Output(awscdk.Stack_Of(scope), NameServersOutputKey,
+			awscdk.Fn_Join(jsii.String(","), hostedZone.HostedZoneNameServers()),
+			agcdkutil.OutputOptions{Description: "Comma-separated list of NS records for DNS delegation"})
 	} else {
 		hostedZoneID := agcdkparams.Lookup(scope, "LookupHostedZoneID",
 			paramsNamespace, "hosted-zone-id", "hosted-zone-id-lookup")
@@ -88,3 +104,11 @@ func New(scope constructs.Construct, props Props) DNS {
 func (d *dns) HostedZone() awsroute53.IHostedZone {
 	return d.hostedZone
 }
+
+func (d *dns) Certificate(name, domainName string) awscertificatemanager.ICertificate {
+	return NewCertificate(d.scope, CertificateProps{
+		Name:       name,
+		HostedZone: d.hostedZone,
+		DomainName: domainName,
+	})
+}
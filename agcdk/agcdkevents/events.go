@@ -0,0 +1,86 @@
+// Package agcdkevents provides an EventBridge event bus construct with an archive of its events.
+//
+// Each deployment gets its own bus, so events published in one deployment never reach the rules
+// of another. Every event on the bus is archived, which allows replaying past events after a
+// consumer was fixed. The names of the bus and the archive are recorded with agcdkutil.Output,
+// which is how `ago events publish` and `ago events replay` find them:
+//
+//	bus := agcdkevents.New(stack, agcdkevents.Props{})
+//	bus.EventBus().GrantPutEventsTo(api, nil)
+//
+//	ago events publish --deployment DevAdam --detail-type OrderPlaced --payload order.json
+package agcdkevents
+
+import (
+	"github.com/advdv/ago/agcdkutil"
+	"github.com/aws/aws-cdk-go/awscdk/v2"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awsevents"
+	"github.com/aws/constructs-go/constructs/v10"
+	"github.com/aws/jsii-runtime-go"
+)
+
+const (
+	// BusNameOutputKey is the output key under which the name of the event bus is recorded.
+	BusNameOutputKey = agcdkutil.EventBusNameOutputKey
+	// ArchiveNameOutputKey is the output key under which the name of the archive is recorded.
+	ArchiveNameOutputKey = agcdkutil.EventArchiveNameOutputKey
+
+	defaultArchiveRetentionInDays = 30
+)
+
+// Events provides access to the event bus and its archive.
+type Events interface {
+	// EventBus returns the bus that producers publish to and rules match on.
+	EventBus() awsevents.IEventBus
+	// Archive returns the archive that keeps every event published to the bus.
+	Archive() awsevents.Archive
+}
+
+// Props configures the Events construct.
+type Props struct {
+	// ArchiveRetention is how long archived events can be replayed. Defaults to 30 days if not
+	// specified.
+	ArchiveRetention awscdk.Duration
+}
+
+type events struct {
+	bus     awsevents.EventBus
+	archive awsevents.Archive
+}
+
+// New creates an event bus that archives all of its events, and records the names of both as
+// stack outputs. It is meant to be created once in each deployment stack.
+func New(scope constructs.Construct, props Props) Events {
+	scope = constructs.NewConstruct(scope, jsii.String("Events"))
+	stack := awscdk.Stack_Of(scope)
+
+	retention := props.ArchiveRetention
+	if retention == nil {
+		retention = awscdk.Duration_Days(jsii.Number(defaultArchiveRetentionInDays))
+	}
+
+	con := &events{}
+	con.bus = awsevents.NewEventBus(scope, jsii.String("EventBus"), &awsevents.EventBusProps{})
+	con.archive = con.bus.Archive(jsii.String("Archive"), &awsevents.BaseArchiveProps{
+		Description: jsii.String("All events of " + *stack.StackName()),
+		EventPattern: &awsevents.EventPattern{
+			Account: jsii.Strings(*stack.Account()),
+		},
+		Retention: retention,
+	})
+
+	agcdkutil.Output(stack, BusNameOutputKey, con.bus.EventBusName(),
+		agcdkutil.OutputOptions{Description: "Name of the deployment's event bus"})
+	agcdkutil.Output(stack, ArchiveNameOutputKey, con.archive.ArchiveName(),
+		agcdkutil.OutputOptions{Description: "Name of the archive of the deployment's event bus"})
+
+	return con
+}
+
+func (e *events) EventBus() awsevents.IEventBus {
+	return e.bus
+}
+
+func (e *events) Archive() awsevents.Archive {
+	return e.archive
+}
@@ -0,0 +1,267 @@
+// Package agcdkjobs provides scheduled jobs: backend commands that EventBridge Scheduler runs on
+// a cron or rate expression, as a Lambda function or as an ECS Fargate task.
+//
+// The image of a job is the one 'ago backend build-and-push' pushed for the deployment, looked up
+// with agcdkutil.ImageTag. The schedules of a stack share a schedule group named after the stack,
+// which is recorded with agcdkutil.Output under ScheduleGroupOutputKey. That is how `ago jobs`
+// finds them:
+//
+//	agcdkjobs.New(stack, agcdkjobs.Props{
+//		Deployment: deploymentIdent,
+//		Repository: shared.Base.Repositories().MainRepository(),
+//		Jobs: []agcdkjobs.Job{
+//			{Name: "nightly-report", Schedule: "cron(0 3 * * ? *)", TimeZone: "Europe/Amsterdam"},
+//			{Name: "reindex", Cmd: "indexer", Schedule: "rate(6 hours)", Runner: agcdkjobs.RunnerECS},
+//		},
+//		Cluster: cluster,
+//	})
+//
+//	ago jobs run-now --deployment DevAdam --job nightly-report
+//
+// A job that `ago jobs disable` switched off for a deployment is recorded in the context, see
+// agcdkutil.JobDisabled, and its schedule stays disabled on every deploy until `ago jobs enable`
+// switches it back on.
+package agcdkjobs
+
+import (
+	"cmp"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/advdv/ago/agcdkutil"
+	"github.com/aws/aws-cdk-go/awscdk/v2"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awsecr"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awsecs"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awslambda"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awsscheduler"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awsschedulertargets"
+	"github.com/aws/constructs-go/constructs/v10"
+	"github.com/aws/jsii-runtime-go"
+	"github.com/iancoleman/strcase"
+)
+
+// Runners of a job.
+const (
+	// RunnerLambda invokes a Lambda function that runs the image of the command.
+	RunnerLambda = "lambda"
+	// RunnerECS runs the image of the command as a Fargate task, for jobs that take longer than
+	// the 15 minutes a Lambda function can run.
+	RunnerECS = "ecs"
+)
+
+// ScheduleGroupOutputKey is the output key under which the name of the schedule group of the
+// jobs of a stack is recorded.
+const ScheduleGroupOutputKey = agcdkutil.JobsScheduleGroupOutputKey
+
+const (
+	defaultTimeoutMinutes = 5
+	defaultMemoryMiB      = 512
+	defaultCPU            = 256
+	defaultRetryAttempts  = 2
+)
+
+var nameRegex = regexp.MustCompile(`^[a-z][a-z0-9-]*$`)
+
+// Jobs provides access to the schedules of the jobs and what they run.
+type Jobs interface {
+	// ScheduleGroup returns the schedule group of the jobs.
+	ScheduleGroup() awsscheduler.ScheduleGroup
+	// Schedule returns the schedule of the named job, or nil when there is no such job.
+	Schedule(name string) awsscheduler.Schedule
+	// Function returns the function of the named job, or nil when it does not run on Lambda.
+	Function(name string) awslambda.IFunction
+	// TaskDefinition returns the task definition of the named job, or nil when it does not run
+	// on ECS.
+	TaskDefinition(name string) awsecs.FargateTaskDefinition
+}
+
+// Job is a backend command that runs on a schedule.
+type Job struct {
+	// Name identifies the job and names its schedule, such as "nightly-report". It must start
+	// with a lowercase letter and contain only lowercase letters, numbers and dashes.
+	Name string
+
+	// Cmd is the backend command whose image the job runs. Defaults to Name.
+	Cmd string
+
+	// Schedule is the EventBridge Scheduler expression of the job, such as "cron(0 3 * * ? *)"
+	// or "rate(1 hour)".
+	Schedule string
+
+	// TimeZone is the IANA time zone that cron expressions are evaluated in. Defaults to UTC.
+	TimeZone string
+
+	// Runner selects what runs the job, RunnerLambda or RunnerECS. Defaults to RunnerLambda.
+	Runner string
+
+	// Timeout of a Lambda job. Defaults to 5 minutes.
+	Timeout awscdk.Duration
+
+	// Environment sets environment variables of the job.
+	Environment map[string]*string
+}
+
+// Props configures the Jobs construct.
+type Props struct {
+	// Deployment is the deployment the jobs belong to. It selects the image tags, the memory of
+	// the jobs and the jobs that are disabled.
+	Deployment string
+
+	// Repository holds the images pushed by 'ago backend build-and-push', usually the main
+	// repository of the shared base.
+	Repository awsecr.IRepository
+
+	// Jobs to schedule.
+	Jobs []Job
+
+	// Cluster runs the jobs with RunnerECS, in its VPC. Required when a job uses RunnerECS.
+	Cluster awsecs.ICluster
+}
+
+type jobs struct {
+	group     awsscheduler.ScheduleGroup
+	schedules map[string]awsscheduler.Schedule
+	functions map[string]awslambda.IFunction
+	tasks     map[string]awsecs.FargateTaskDefinition
+}
+
+// New creates a schedule for every job in a schedule group and records the name of the group as
+// a stack output. A stack can only hold one Jobs construct. It panics when a job is invalid, or
+// when no image was pushed for one of the commands.
+func New(scope constructs.Construct, props Props) Jobs {
+	validateProps(props)
+
+	scope = constructs.NewConstruct(scope, jsii.String("Jobs"))
+	stack := awscdk.Stack_Of(scope)
+	sizing := agcdkutil.DeploymentSettingsFor(scope, props.Deployment).Sizing
+
+	con := &jobs{
+		schedules: map[string]awsscheduler.Schedule{},
+		functions: map[string]awslambda.IFunction{},
+		tasks:     map[string]awsecs.FargateTaskDefinition{},
+	}
+	con.group = awsscheduler.NewScheduleGroup(scope, jsii.String("Group"), &awsscheduler.ScheduleGroupProps{
+		ScheduleGroupName: stack.StackName(),
+		RemovalPolicy:     awscdk.RemovalPolicy_DESTROY,
+	})
+
+	for _, job := range props.Jobs {
+		id := strcase.ToCamel(job.Name)
+		cmd := cmp.Or(job.Cmd, job.Name)
+		image := jsii.String(agcdkutil.ImageTag(scope, props.Deployment, cmd))
+		memory := jsii.Number(float64(cmp.Or(sizing.MemoryMiB, defaultMemoryMiB)))
+
+		var target awsscheduler.IScheduleTarget
+		switch cmp.Or(job.Runner, RunnerLambda) {
+		case RunnerECS:
+			task := awsecs.NewFargateTaskDefinition(scope, jsii.String(id+"Task"), &awsecs.FargateTaskDefinitionProps{
+				Cpu:            jsii.Number(defaultCPU),
+				MemoryLimitMiB: memory,
+			})
+			task.AddContainer(jsii.String("Job"), &awsecs.ContainerDefinitionOptions{
+				Image:       awsecs.ContainerImage_FromEcrRepository(props.Repository, image),
+				Environment: &job.Environment,
+				Logging: awsecs.LogDrivers_AwsLogs(&awsecs.AwsLogDriverProps{
+					StreamPrefix: jsii.String(job.Name),
+				}),
+			})
+			con.tasks[job.Name] = task
+			target = awsschedulertargets.NewEcsRunFargateTask(props.Cluster, &awsschedulertargets.FargateTaskProps{
+				TaskDefinition: task,
+				RetryAttempts:  jsii.Number(defaultRetryAttempts),
+			})
+		default:
+			timeout := job.Timeout
+			if timeout == nil {
+				timeout = awscdk.Duration_Minutes(jsii.Number(defaultTimeoutMinutes))
+			}
+			fn := awslambda.NewDockerImageFunction(scope, jsii.String(id+"Function"),
+				&awslambda.DockerImageFunctionProps{
+					Code: awslambda.DockerImageCode_FromEcr(props.Repository, &awslambda.EcrImageCodeProps{
+						TagOrDigest: image,
+					}),
+					Architecture: awslambda.Architecture_ARM_64(),
+					Environment:  &job.Environment,
+					Timeout:      timeout,
+					MemorySize:   memory,
+				})
+			con.functions[job.Name] = fn
+			target = awsschedulertargets.NewLambdaInvoke(fn, &awsschedulertargets.ScheduleTargetBaseProps{
+				Input:         awsscheduler.ScheduleTargetInput_FromObject(map[string]any{"job": job.Name}),
+				RetryAttempts: jsii.Number(defaultRetryAttempts),
+			})
+		}
+
+		var timeZone awscdk.TimeZone
+		if job.TimeZone != "" {
+			timeZone = awscdk.TimeZone_Of(jsii.String(job.TimeZone))
+		}
+		disabled := agcdkutil.JobDisabled(scope, props.Deployment, job.Name)
+		con.schedules[job.Name] = awsscheduler.NewSchedule(scope, jsii.String(id+"Schedule"),
+			&awsscheduler.ScheduleProps{
+				ScheduleName:  jsii.String(job.Name),
+				ScheduleGroup: con.group,
+				Schedule:      awsscheduler.ScheduleExpression_Expression(jsii.String(job.Schedule), timeZone),
+				Target:        target,
+				Description:   jsii.String("Runs the " + cmd + " command"),
+				Enabled:       jsii.Bool(!disabled),
+			})
+	}
+
+	agcdkutil.Output(stack, ScheduleGroupOutputKey, con.group.ScheduleGroupName(),
+		agcdkutil.OutputOptions{Description: "Schedule group of the jobs of the stack"})
+
+	return con
+}
+
+// validateProps panics when a job has an invalid name, command, schedule or runner, or when a
+// job runs on ECS without a cluster.
+func validateProps(props Props) {
+	seen := map[string]bool{}
+	for _, job := range props.Jobs {
+		if !nameRegex.MatchString(job.Name) {
+			panic(fmt.Sprintf("agcdkjobs: invalid job name %q: must start with a lowercase letter "+
+				"and contain only lowercase letters, numbers and dashes", job.Name))
+		}
+		if seen[job.Name] {
+			panic(fmt.Sprintf("agcdkjobs: duplicate job %q", job.Name))
+		}
+		seen[job.Name] = true
+
+		if job.Cmd != "" && !nameRegex.MatchString(job.Cmd) {
+			panic(fmt.Sprintf("agcdkjobs: invalid command %q of job %q", job.Cmd, job.Name))
+		}
+		if !strings.HasPrefix(job.Schedule, "cron(") && !strings.HasPrefix(job.Schedule, "rate(") {
+			panic(fmt.Sprintf("agcdkjobs: invalid schedule %q of job %q: must be a cron(...) or rate(...) "+
+				"expression", job.Schedule, job.Name))
+		}
+
+		switch job.Runner {
+		case "", RunnerLambda:
+		case RunnerECS:
+			if props.Cluster == nil {
+				panic(fmt.Sprintf("agcdkjobs: job %q runs on ECS, which requires a Cluster", job.Name))
+			}
+		default:
+			panic(fmt.Sprintf("agcdkjobs: invalid runner %q of job %q: must be %q or %q",
+				job.Runner, job.Name, RunnerLambda, RunnerECS))
+		}
+	}
+}
+
+func (j *jobs) ScheduleGroup() awsscheduler.ScheduleGroup {
+	return j.group
+}
+
+func (j *jobs) Schedule(name string) awsscheduler.Schedule {
+	return j.schedules[name]
+}
+
+func (j *jobs) Function(name string) awslambda.IFunction {
+	return j.functions[name]
+}
+
+func (j *jobs) TaskDefinition(name string) awsecs.FargateTaskDefinition {
+	return j.tasks[name]
+}
@@ -0,0 +1,116 @@
+// Package agcdkqueue provides an SQS queue construct with a dead-letter queue.
+//
+// Messages that fail processing MaxReceiveCount times move to the dead-letter queue, where they
+// are kept for the maximum retention of 14 days. The URLs of both queues are recorded with
+// agcdkutil.Output under keys derived from the queue's name, which is how `ago queue inspect`
+// and `ago queue redrive` find them:
+//
+//	orders := agcdkqueue.New(stack, agcdkqueue.Props{Name: "orders"})
+//	orders.Queue().GrantSendMessages(api)
+//
+//	ago queue inspect --deployment DevAdam --queue orders
+package agcdkqueue
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/advdv/ago/agcdkutil"
+	"github.com/aws/aws-cdk-go/awscdk/v2"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awssqs"
+	"github.com/aws/constructs-go/constructs/v10"
+	"github.com/aws/jsii-runtime-go"
+	"github.com/iancoleman/strcase"
+)
+
+const (
+	defaultMaxReceiveCount    = 5
+	deadLetterRetentionInDays = 14
+)
+
+var nameRegex = regexp.MustCompile(`^[a-z][a-z0-9-]*$`)
+
+// URLOutputKey returns the output key under which the URL of the named queue is recorded.
+func URLOutputKey(name string) string {
+	return "Queue" + strcase.ToCamel(name) + "URL"
+}
+
+// DeadLetterURLOutputKey returns the output key under which the URL of the named queue's
+// dead-letter queue is recorded.
+func DeadLetterURLOutputKey(name string) string {
+	return "Queue" + strcase.ToCamel(name) + "DLQURL"
+}
+
+// Queue provides access to a queue and its dead-letter queue.
+type Queue interface {
+	// Queue returns the queue that producers send to and consumers receive from.
+	Queue() awssqs.IQueue
+	// DeadLetterQueue returns the queue that receives the messages that failed processing.
+	DeadLetterQueue() awssqs.IQueue
+}
+
+// Props configures the Queue construct.
+type Props struct {
+	// Name identifies the queue within its stack, such as "orders". It must start with a
+	// lowercase letter and contain only lowercase letters, numbers and dashes.
+	Name string
+
+	// MaxReceiveCount is how often a message can be received before it moves to the dead-letter
+	// queue. Defaults to 5 if not specified.
+	MaxReceiveCount *float64
+
+	// VisibilityTimeout is how long a received message stays hidden from other consumers. Set
+	// it to at least the timeout of the consumer. Defaults to the SQS default of 30 seconds.
+	VisibilityTimeout awscdk.Duration
+}
+
+type queue struct {
+	queue      awssqs.IQueue
+	deadLetter awssqs.IQueue
+}
+
+// New creates a queue with a dead-letter queue and records the URLs of both as stack outputs.
+// It panics when the name is invalid.
+func New(scope constructs.Construct, props Props) Queue {
+	if !nameRegex.MatchString(props.Name) {
+		panic(fmt.Sprintf("agcdkqueue: invalid queue name %q: must start with a lowercase letter "+
+			"and contain only lowercase letters, numbers and dashes", props.Name))
+	}
+
+	scope = constructs.NewConstruct(scope, jsii.String("Queue"+strcase.ToCamel(props.Name)))
+	stack := awscdk.Stack_Of(scope)
+
+	maxReceiveCount := props.MaxReceiveCount
+	if maxReceiveCount == nil {
+		maxReceiveCount = jsii.Number(defaultMaxReceiveCount)
+	}
+
+	con := &queue{}
+	con.deadLetter = awssqs.NewQueue(scope, jsii.String("DeadLetterQueue"), &awssqs.QueueProps{
+		RetentionPeriod: awscdk.Duration_Days(jsii.Number(deadLetterRetentionInDays)),
+		EnforceSSL:      jsii.Bool(true),
+	})
+	con.queue = awssqs.NewQueue(scope, jsii.String("Queue"), &awssqs.QueueProps{
+		VisibilityTimeout: props.VisibilityTimeout,
+		EnforceSSL:        jsii.Bool(true),
+		DeadLetterQueue: &awssqs.DeadLetterQueue{
+			Queue:           con.deadLetter,
+			MaxReceiveCount: maxReceiveCount,
+		},
+	})
+
+	agcdkutil.Output(stack, URLOutputKey(props.Name), con.queue.QueueUrl(),
+		agcdkutil.OutputOptions{Description: "URL of the " + props.Name + " queue"})
+	agcdkutil.Output(stack, DeadLetterURLOutputKey(props.Name), con.deadLetter.QueueUrl(),
+		agcdkutil.OutputOptions{Description: "URL of the dead-letter queue of the " + props.Name + " queue"})
+
+	return con
+}
+
+func (q *queue) Queue() awssqs.IQueue {
+	return q.queue
+}
+
+func (q *queue) DeadLetterQueue() awssqs.IQueue {
+	return q.deadLetter
+}
@@ -23,7 +23,7 @@ import (
 //
 //	export KO_DOCKER_REPO=$(aws cloudformation describe-stacks --stack-name MyStack \
 //	  --query 'Stacks[0].Outputs[?OutputKey==`RepositoryURI`].OutputValue' --output text)
-const RepositoryURIOutputKey = "RepositoryURI"
+const RepositoryURIOutputKey = agcdkutil.RepositoryURIOutputKey
 
 const defaultLifecycleMaxImages = 100
 
@@ -86,10 +86,8 @@ func New(scope constructs.Construct, props Props) Repositories {
 	})
 
 	if agcdkutil.IsPrimaryRegion(scope, region) {
-		awscdk.NewCfnOutput(stack, jsii.String(RepositoryURIOutputKey), &awscdk.CfnOutputProps{
-			Value:       con.repository.RepositoryUri(),
-			Description: jsii.String("ECR repository URI for ko (export as KO_DOCKER_REPO)"),
-		})
+		agcdkutil.Output(stack, RepositoryURIOutputKey, con.repository.RepositoryUri(),
+			agcdkutil.OutputOptions{Description: "ECR repository URI for ko (export as KO_DOCKER_REPO)"})
 		cfg := agcdkutil.ConfigFromScope(scope)
 		destinations := make(
 			[]*awsecr.CfnReplicationConfiguration_ReplicationDestinationProperty,
@@ -0,0 +1,287 @@
+// Package agcdkservice provides a construct that runs a backend command image as an ECS Fargate
+// service, for workloads that outgrow Lambda: long-running connections, steady high traffic or
+// large memory needs.
+//
+// The image is the one 'ago backend build-and-push' pushed for the deployment, looked up with
+// agcdkutil.ImageTag, so deploying a new image works exactly as it does for Lambda functions. The
+// service sits behind an Application Load Balancer, which is either public or internal and
+// reached through an API Gateway HTTP API with a VPC link. Its capacity follows the sizing of the
+// deployment in infra/deployments.yaml:
+//
+//	svc := agcdkservice.New(stack, agcdkservice.Props{
+//		Name:       "coreapi",
+//		Deployment: deploymentIdent,
+//		Repository: shared.Base.Repositories().MainRepository(),
+//		Frontend:   agcdkservice.FrontendVPCLink,
+//	})
+//	orders.Queue().GrantSendMessages(svc.Service().TaskDefinition().TaskRole())
+//
+// where orders is an agcdkqueue.Queue. The URL of the service is recorded with agcdkutil.Output
+// under URLOutputKey. Connect services, scaffolded with 'ago backend new-cmd --kind connect', set
+// Protocol to ProtocolConnect.
+package agcdkservice
+
+import (
+	"cmp"
+	"fmt"
+	"regexp"
+
+	"github.com/advdv/ago/agcdkutil"
+	"github.com/aws/aws-cdk-go/awscdk/v2"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awsapigatewayv2"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awsapigatewayv2integrations"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awsapplicationautoscaling"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awscertificatemanager"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awsec2"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awsecr"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awsecs"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awsecspatterns"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awselasticloadbalancingv2"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awsroute53"
+	"github.com/aws/constructs-go/constructs/v10"
+	"github.com/aws/jsii-runtime-go"
+	"github.com/iancoleman/strcase"
+)
+
+// Frontends of the service.
+const (
+	// FrontendALB exposes the service through a public Application Load Balancer.
+	FrontendALB = "alb"
+	// FrontendVPCLink keeps the load balancer internal and exposes the service through an API
+	// Gateway HTTP API with a VPC link.
+	FrontendVPCLink = "vpc-link"
+)
+
+// Protocols that the service speaks.
+const (
+	// ProtocolHTTP serves plain HTTP/1.1.
+	ProtocolHTTP = "http"
+	// ProtocolConnect serves a Connect service, as scaffolded by 'ago backend new-cmd --kind
+	// connect'. The Connect protocol works over HTTP/1.1 with every frontend. With FrontendALB and
+	// a Certificate, the load balancer also speaks HTTP/2 to the tasks, so that gRPC and gRPC-Web
+	// clients can reach the service; clients must then use HTTP/2 as well.
+	ProtocolConnect = "connect"
+)
+
+const (
+	defaultCPU                  = 256
+	defaultMemoryMiB            = 512
+	defaultContainerPort        = 8080
+	defaultHealthCheckPath      = "/healthz"
+	defaultTargetCPUUtilization = 70
+)
+
+var nameRegex = regexp.MustCompile(`^[a-z][a-z0-9-]*$`)
+
+// URLOutputKey returns the output key under which the URL of the named service is recorded.
+func URLOutputKey(name string) string {
+	return "Service" + strcase.ToCamel(name) + "URL"
+}
+
+// Service provides access to the resources of a Fargate service.
+type Service interface {
+	// Service returns the Fargate service.
+	Service() awsecs.FargateService
+	// LoadBalancer returns the load balancer in front of the service.
+	LoadBalancer() awselasticloadbalancingv2.ApplicationLoadBalancer
+	// HTTPAPI returns the HTTP API in front of the load balancer, or nil with FrontendALB.
+	HTTPAPI() awsapigatewayv2.HttpApi
+	// URL returns the URL that clients reach the service at.
+	URL() *string
+}
+
+// Props configures the Service construct.
+type Props struct {
+	// Name is the backend command whose image the service runs, such as "coreapi". It must
+	// start with a lowercase letter and contain only lowercase letters, numbers and dashes.
+	Name string
+
+	// Deployment is the deployment the service belongs to. It selects the image tag and the
+	// sizing of the service.
+	Deployment string
+
+	// Repository holds the images pushed by 'ago backend build-and-push', usually the main
+	// repository of the shared base.
+	Repository awsecr.IRepository
+
+	// Vpc runs the service. Defaults to a new VPC over two availability zones with a single NAT
+	// gateway.
+	Vpc awsec2.IVpc
+
+	// Frontend selects how clients reach the service, FrontendALB or FrontendVPCLink. Defaults
+	// to FrontendALB.
+	Frontend string
+
+	// Certificate, DomainName and HostedZone serve the public load balancer over HTTPS at the
+	// domain name, with HTTP redirected. They are only used with FrontendALB.
+	Certificate awscertificatemanager.ICertificate
+	DomainName  string
+	HostedZone  awsroute53.IHostedZone
+
+	// CPU is the number of CPU units of a task. Defaults to 256. The memory of a task comes
+	// from the sizing of the deployment and defaults to 512 MiB.
+	CPU *float64
+
+	// ContainerPort is the port the command listens on. Defaults to 8080.
+	ContainerPort *float64
+
+	// Protocol selects what the command speaks, ProtocolHTTP or ProtocolConnect. Defaults to
+	// ProtocolHTTP.
+	Protocol string
+
+	// HealthCheckPath is the path the load balancer checks the tasks on. Defaults to "/healthz".
+	HealthCheckPath string
+
+	// TargetCPUUtilization is the average CPU utilization, in percent, that autoscaling keeps
+	// the tasks at. Defaults to 70. Autoscaling only applies when the sizing of the deployment
+	// allows more tasks than its minimum.
+	TargetCPUUtilization *float64
+
+	// Environment sets environment variables of the container.
+	Environment map[string]*string
+}
+
+type service struct {
+	fargate awsecspatterns.ApplicationLoadBalancedFargateService
+	httpAPI awsapigatewayv2.HttpApi
+	url     *string
+}
+
+// New creates a Fargate service that runs the image of a backend command behind a load balancer,
+// scaled by the sizing of the deployment, and records its URL as a stack output. It panics when
+// the name, frontend or protocol is invalid, or when no image was pushed for the deployment.
+func New(scope constructs.Construct, props Props) Service {
+	if !nameRegex.MatchString(props.Name) {
+		panic(fmt.Sprintf("agcdkservice: invalid service name %q: must start with a lowercase letter "+
+			"and contain only lowercase letters, numbers and dashes", props.Name))
+	}
+
+	frontend := props.Frontend
+	if frontend == "" {
+		frontend = FrontendALB
+	}
+	if frontend != FrontendALB && frontend != FrontendVPCLink {
+		panic(fmt.Sprintf("agcdkservice: invalid frontend %q: must be %q or %q",
+			frontend, FrontendALB, FrontendVPCLink))
+	}
+
+	protocol := props.Protocol
+	if protocol == "" {
+		protocol = ProtocolHTTP
+	}
+	if protocol != ProtocolHTTP && protocol != ProtocolConnect {
+		panic(fmt.Sprintf("agcdkservice: invalid protocol %q: must be %q or %q",
+			protocol, ProtocolHTTP, ProtocolConnect))
+	}
+
+	scope = constructs.NewConstruct(scope, jsii.String("Service"+strcase.ToCamel(props.Name)))
+	stack := awscdk.Stack_Of(scope)
+	sizing := agcdkutil.DeploymentSettingsFor(scope, props.Deployment).Sizing
+
+	vpc := props.Vpc
+	if vpc == nil {
+		vpc = awsec2.NewVpc(scope, jsii.String("Vpc"), &awsec2.VpcProps{
+			MaxAzs:      jsii.Number(2),
+			NatGateways: jsii.Number(1),
+		})
+	}
+
+	minCapacity := max(sizing.MinCapacity, 1)
+	maxCapacity := max(sizing.MaxCapacity, minCapacity)
+
+	fargateProps := &awsecspatterns.ApplicationLoadBalancedFargateServiceProps{
+		Vpc:                vpc,
+		Cpu:                orDefault(props.CPU, defaultCPU),
+		MemoryLimitMiB:     jsii.Number(float64(cmp.Or(sizing.MemoryMiB, defaultMemoryMiB))),
+		DesiredCount:       jsii.Number(float64(minCapacity)),
+		PublicLoadBalancer: jsii.Bool(frontend == FrontendALB),
+		CircuitBreaker:     &awsecs.DeploymentCircuitBreaker{Rollback: jsii.Bool(true)},
+		TaskImageOptions: &awsecspatterns.ApplicationLoadBalancedTaskImageOptions{
+			Image: awsecs.ContainerImage_FromEcrRepository(props.Repository,
+				jsii.String(agcdkutil.ImageTag(scope, props.Deployment, props.Name))),
+			ContainerPort: orDefault(props.ContainerPort, defaultContainerPort),
+			Environment:   &props.Environment,
+			LogDriver: awsecs.LogDrivers_AwsLogs(&awsecs.AwsLogDriverProps{
+				StreamPrefix: jsii.String(props.Name),
+			}),
+		},
+	}
+	if frontend == FrontendALB && props.Certificate != nil {
+		fargateProps.Certificate = props.Certificate
+		fargateProps.DomainName = jsii.String(props.DomainName)
+		fargateProps.DomainZone = props.HostedZone
+		fargateProps.RedirectHTTP = jsii.Bool(true)
+	}
+
+	con := &service{}
+	con.fargate = awsecspatterns.NewApplicationLoadBalancedFargateService(scope, jsii.String("Fargate"),
+		fargateProps)
+
+	healthCheckPath := props.HealthCheckPath
+	if healthCheckPath == "" {
+		healthCheckPath = defaultHealthCheckPath
+	}
+	con.fargate.TargetGroup().ConfigureHealthCheck(&awselasticloadbalancingv2.HealthCheck{
+		Path: jsii.String(healthCheckPath),
+	})
+
+	if protocol == ProtocolConnect && frontend == FrontendALB && props.Certificate != nil {
+		cfnTargetGroup := con.fargate.TargetGroup().Node().DefaultChild()
+		if targetGroup, ok := cfnTargetGroup.(awselasticloadbalancingv2.CfnTargetGroup); ok {
+			targetGroup.SetProtocolVersion(jsii.String("HTTP2"))
+		}
+	}
+
+	if maxCapacity > minCapacity {
+		scaling := con.fargate.Service().AutoScaleTaskCount(&awsapplicationautoscaling.EnableScalingProps{
+			MinCapacity: jsii.Number(float64(minCapacity)),
+			MaxCapacity: jsii.Number(float64(maxCapacity)),
+		})
+		scaling.ScaleOnCpuUtilization(jsii.String("CPUScaling"), &awsecs.CpuUtilizationScalingProps{
+			TargetUtilizationPercent: orDefault(props.TargetCPUUtilization, defaultTargetCPUUtilization),
+		})
+	}
+
+	switch {
+	case frontend == FrontendVPCLink:
+		con.httpAPI = awsapigatewayv2.NewHttpApi(scope, jsii.String("HttpApi"), &awsapigatewayv2.HttpApiProps{
+			DefaultIntegration: awsapigatewayv2integrations.NewHttpAlbIntegration(jsii.String("Integration"),
+				con.fargate.Listener(), nil),
+		})
+		con.url = con.httpAPI.ApiEndpoint()
+	case props.Certificate != nil:
+		con.url = jsii.String("https://" + props.DomainName)
+	default:
+		con.url = awscdk.Fn_Join(jsii.String(""), &[]*string{
+			jsii.String("http://"), con.fargate.LoadBalancer().LoadBalancerDnsName(),
+		})
+	}
+
+	agcdkutil.Output(stack, URLOutputKey(props.Name), con.url,
+		agcdkutil.OutputOptions{Description: "URL of the " + props.Name + " service"})
+
+	return con
+}
+
+func orDefault(value *float64, def float64) *float64 {
+	if value == nil {
+		return jsii.Number(def)
+	}
+	return value
+}
+
+func (s *service) Service() awsecs.FargateService {
+	return s.fargate.Service()
+}
+
+func (s *service) LoadBalancer() awselasticloadbalancingv2.ApplicationLoadBalancer {
+	return s.fargate.LoadBalancer()
+}
+
+func (s *service) HTTPAPI() awsapigatewayv2.HttpApi {
+	return s.httpAPI
+}
+
+func (s *service) URL() *string {
+	return s.url
+}
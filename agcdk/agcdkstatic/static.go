@@ -0,0 +1,235 @@
+// Package agcdkstatic provides hosting of a static site or single-page app per deployment: a
+// private S3 bucket behind a CloudFront distribution, served at a subdomain of the base domain
+// name.
+//
+// CloudFront is a global service, so a site is created in the primary region stack of its
+// deployment only. The distribution reads the bucket through an origin access control, and
+// nothing else can. With a hosted zone, once the dns-delegated context flag is set, the site is
+// served at "<name>-<deployment>.<base-domain-name>", such as "web-devadam.example.com". CloudFront
+// only accepts certificates from us-east-1, so unless the stack is in that region the certificate
+// is requested there across regions, and is validated with records in the hosted zone:
+//
+//	if agcdkutil.IsPrimaryRegionStack(stack, stack) {
+//		agcdkstatic.New(stack, agcdkstatic.Props{
+//			Name:       "web",
+//			Deployment: deploymentIdent,
+//			HostedZone: shared.Base.DNS().HostedZone(),
+//			SinglePage: true,
+//		})
+//	}
+//
+//	ago frontend deploy --deployment DevAdam --site web --dir ../../frontend/dist
+//
+// The bucket, the distribution and the URL are recorded with agcdkutil.Output under
+// BucketOutputKey, DistributionIDOutputKey and URLOutputKey. That is how `ago frontend deploy`
+// finds them to upload a build and invalidate the cache.
+package agcdkstatic
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/advdv/ago/agcdkutil"
+	"github.com/aws/aws-cdk-go/awscdk/v2"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awscertificatemanager"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awscloudfront"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awscloudfrontorigins"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awsroute53"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awsroute53targets"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awss3"
+	"github.com/aws/constructs-go/constructs/v10"
+	"github.com/aws/jsii-runtime-go"
+	"github.com/iancoleman/strcase"
+)
+
+// certificateRegion is the only region that CloudFront accepts certificates from.
+const certificateRegion = "us-east-1"
+
+// indexDocument is the object served at the root of the site, and for every path of a
+// single-page app.
+const indexDocument = "index.html"
+
+var nameRegex = regexp.MustCompile(`^[a-z][a-z0-9-]*$`)
+
+// BucketOutputKey returns the output key under which the bucket of the named site is recorded.
+func BucketOutputKey(name string) string {
+	return "Static" + strcase.ToCamel(name) + "Bucket"
+}
+
+// DistributionIDOutputKey returns the output key under which the ID of the distribution of the
+// named site is recorded.
+func DistributionIDOutputKey(name string) string {
+	return "Static" + strcase.ToCamel(name) + "DistributionID"
+}
+
+// URLOutputKey returns the output key under which the URL of the named site is recorded.
+func URLOutputKey(name string) string {
+	return "Static" + strcase.ToCamel(name) + "URL"
+}
+
+// Subdomain returns the label under the base domain name at which a deployment serves the named
+// site, such as "web-devadam".
+func Subdomain(name, deployment string) string {
+	return name + "-" + strings.ToLower(deployment)
+}
+
+// Site provides access to the resources of a static site.
+type Site interface {
+	// Bucket returns the bucket that holds the files of the site.
+	Bucket() awss3.IBucket
+	// Distribution returns the distribution that serves the site.
+	Distribution() awscloudfront.IDistribution
+	// URL returns the URL that visitors reach the site at.
+	URL() *string
+}
+
+// Props configures the Site construct.
+type Props struct {
+	// Name identifies the site within its stack and starts its subdomain, such as "web". It must
+	// start with a lowercase letter and contain only lowercase letters, numbers and dashes.
+	Name string
+
+	// Deployment is the deployment the site belongs to. It selects the subdomain.
+	Deployment string
+
+	// HostedZone holds the records of the custom domain and validates its certificate, usually
+	// the zone of the shared base. Without it, or while the dns-delegated context flag is not
+	// set, the site is only served at the domain name of the distribution.
+	HostedZone awsroute53.IHostedZone
+
+	// Certificate serves the site at its subdomain instead of a certificate that the construct
+	// requests. It must be in us-east-1 and cover the subdomain.
+	Certificate awscertificatemanager.ICertificate
+
+	// SinglePage serves the index document for paths that have no object, with status 200, so
+	// that the router of a single-page app handles them.
+	SinglePage bool
+}
+
+type site struct {
+	bucket       awss3.IBucket
+	distribution awscloudfront.IDistribution
+	url          *string
+}
+
+// New creates a private bucket and a distribution that serves it, with a certificate and alias
+// records when the hosted zone is delegated, and records the bucket, the distribution and the
+// URL as stack outputs. It panics when the name is invalid, or when the stack is not in the
+// primary region.
+func New(scope constructs.Construct, props Props) Site {
+	if !nameRegex.MatchString(props.Name) {
+		panic(fmt.Sprintf("agcdkstatic: invalid site name %q: must start with a lowercase letter "+
+			"and contain only lowercase letters, numbers and dashes", props.Name))
+	}
+	stack := awscdk.Stack_Of(scope)
+	if !agcdkutil.IsPrimaryRegionStack(scope, stack) {
+		panic(fmt.Sprintf("agcdkstatic: site %q must be created in the primary region stack, "+
+			"CloudFront serves it from every region", props.Name))
+	}
+
+	scope = constructs.NewConstruct(scope, jsii.String("Static"+strcase.ToCamel(props.Name)))
+
+	con := &site{}
+	con.bucket = awss3.NewBucket(scope, jsii.String("Bucket"), &awss3.BucketProps{
+		BlockPublicAccess: awss3.BlockPublicAccess_BLOCK_ALL(),
+		Encryption:        awss3.BucketEncryption_S3_MANAGED,
+		EnforceSSL:        jsii.Bool(true),
+		RemovalPolicy:     awscdk.RemovalPolicy_DESTROY,
+	})
+
+	distProps := &awscloudfront.DistributionProps{
+		DefaultBehavior: &awscloudfront.BehaviorOptions{
+			Origin:               awscloudfrontorigins.S3BucketOrigin_WithOriginAccessControl(con.bucket, nil),
+			ViewerProtocolPolicy: awscloudfront.ViewerProtocolPolicy_REDIRECT_TO_HTTPS,
+			CachePolicy:          awscloudfront.CachePolicy_CACHING_OPTIMIZED(),
+		},
+		DefaultRootObject: jsii.String(indexDocument),
+		PriceClass:        awscloudfront.PriceClass_PRICE_CLASS_100,
+		Comment:           jsii.String(*stack.StackName() + " " + props.Name),
+	}
+	if props.SinglePage {
+		// The bucket answers 403 for a missing object, since the distribution may not list it.
+		distProps.ErrorResponses = &[]*awscloudfront.ErrorResponse{}
+		for _, status := range []float64{403, 404} {
+			*distProps.ErrorResponses = append(*distProps.ErrorResponses, &awscloudfront.ErrorResponse{
+				HttpStatus:         jsii.Number(status),
+				ResponseHttpStatus: jsii.Number(200),
+				ResponsePagePath:   jsii.String("/" + indexDocument),
+			})
+		}
+	}
+
+	var domainName string
+	if props.HostedZone != nil && agcdkutil.DNSDelegated(scope) {
+		subdomain := Subdomain(props.Name, props.Deployment)
+		domainName = subdomain + "." + agcdkutil.BaseDomainName(scope)
+
+		certificate := props.Certificate
+		if certificate == nil {
+			certificate = newCertificate(scope, stack, props.HostedZone, domainName)
+		}
+		distProps.DomainNames = jsii.Strings(domainName)
+		distProps.Certificate = certificate
+	}
+
+	con.distribution = awscloudfront.NewDistribution(scope, jsii.String("Distribution"), distProps)
+	con.url = jsii.String("https://" + *con.distribution.DistributionDomainName())
+
+	if domainName != "" {
+		target := awsroute53.RecordTarget_FromAlias(awsroute53targets.NewCloudFrontTarget(con.distribution))
+		recordName := jsii.String(Subdomain(props.Name, props.Deployment))
+		awsroute53.NewARecord(scope, jsii.String("AliasRecord"), &awsroute53.ARecordProps{
+			Zone: props.HostedZone, RecordName: recordName, Target: target,
+		})
+		awsroute53.NewAaaaRecord(scope, jsii.String("AliasRecordIPv6"), &awsroute53.AaaaRecordProps{
+			Zone: props.HostedZone, RecordName: recordName, Target: target,
+		})
+		con.url = jsii.String("https://" + domainName)
+	}
+
+	agcdkutil.Output(stack, BucketOutputKey(props.Name), con.bucket.BucketName(),
+		agcdkutil.OutputOptions{Description: "Bucket of the " + props.Name + " static site"})
+	agcdkutil.Output(stack, DistributionIDOutputKey(props.Name), con.distribution.DistributionId(),
+		agcdkutil.OutputOptions{Description: "Distribution of the " + props.Name + " static site"})
+	agcdkutil.Output(stack, URLOutputKey(props.Name), con.url,
+		agcdkutil.OutputOptions{Description: "URL of the " + props.Name + " static site"})
+
+	return con
+}
+
+// newCertificate requests a certificate for the domain name in us-east-1, validated with records
+// in the hosted zone. A stack in another region requests it there with a custom resource, which
+// also removes the validation records when the certificate is deleted.
+func newCertificate(
+	scope constructs.Construct, stack awscdk.Stack, zone awsroute53.IHostedZone, domainName string,
+) awscertificatemanager.ICertificate {
+	if *stack.Region() == certificateRegion {
+		return awscertificatemanager.NewCertificate(scope, jsii.String("Certificate"),
+			&awscertificatemanager.CertificateProps{
+				DomainName: jsii.String(domainName),
+				Validation: awscertificatemanager.CertificateValidation_FromDns(zone),
+			})
+	}
+
+	//nolint:staticcheck // the replacement, cross-region references, needs a separate us-east-1 stack
+	return awscertificatemanager.NewDnsValidatedCertificate(scope, jsii.String("Certificate"),
+		&awscertificatemanager.DnsValidatedCertificateProps{
+			DomainName:            jsii.String(domainName),
+			HostedZone:            zone,
+			Region:                jsii.String(certificateRegion),
+			CleanupRoute53Records: jsii.Bool(true),
+		})
+}
+
+func (s *site) Bucket() awss3.IBucket {
+	return s.bucket
+}
+
+func (s *site) Distribution() awscloudfront.IDistribution {
+	return s.distribution
+}
+
+func (s *site) URL() *string {
+	return s.url
+}
@@ -0,0 +1,219 @@
+// Package agcdkwebapi provides an API Gateway HTTP API in front of a Go Lambda function, served at
+// a subdomain of the base domain name per deployment.
+//
+// The function is built from a Go main package with agcdkutil.ReproducibleGoBundling, so an
+// unchanged source does not redeploy it. With a certificate, usually the wildcard certificate of
+// the shared base, the API is served at "<name>-<deployment>.<base-domain-name>", such as
+// "api-devadam.example.com", which the wildcard covers. Every region of the deployment serves the
+// same domain name, and latency-based records send clients to the nearest one:
+//
+//	var cert awscertificatemanager.ICertificate
+//	if shared.Base.IsValidated() {
+//		cert = shared.Base.Certificates().WildcardCertificate()
+//	}
+//	api := agcdkwebapi.New(stack, agcdkwebapi.Props{
+//		Name:        "api",
+//		Entry:       "../../backend/cmd/coreapi",
+//		Deployment:  deploymentIdent,
+//		HostedZone:  shared.Base.DNS().HostedZone(),
+//		Certificate: cert,
+//	})
+//	orders.Queue().GrantSendMessages(api.Function())
+//
+// Without a certificate, for example before the DNS delegation works, the API is only served at
+// its execute-api endpoint. The URL is recorded with agcdkutil.Output under URLOutputKey. The role
+// of the function gets the permissions boundary of the project, as configured in the
+// @aws-cdk/core:permissionsBoundary context, also when the app does not apply it itself.
+package agcdkwebapi
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/advdv/ago/agcdkutil"
+	"github.com/aws/aws-cdk-go/awscdk/v2"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awsapigatewayv2"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awsapigatewayv2integrations"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awscertificatemanager"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awsiam"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awslambda"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awsroute53"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awsroute53targets"
+	"github.com/aws/aws-cdk-go/awscdklambdagoalpha/v2"
+	"github.com/aws/constructs-go/constructs/v10"
+	"github.com/aws/jsii-runtime-go"
+	"github.com/iancoleman/strcase"
+)
+
+const (
+	defaultMemorySize     = 256
+	defaultTimeoutSeconds = 29
+
+	// permissionsBoundaryContextKey holds the permissions boundary that the CDK applies to the
+	// roles of the app, as written by 'ago init'.
+	permissionsBoundaryContextKey = "@aws-cdk/core:permissionsBoundary"
+)
+
+var nameRegex = regexp.MustCompile(`^[a-z][a-z0-9-]*$`)
+
+// URLOutputKey returns the output key under which the URL of the named API is recorded.
+func URLOutputKey(name string) string {
+	return "WebAPI" + strcase.ToCamel(name) + "URL"
+}
+
+// Subdomain returns the label under the base domain name at which a deployment serves the named
+// API, such as "api-devadam".
+func Subdomain(name, deployment string) string {
+	return name + "-" + strings.ToLower(deployment)
+}
+
+// WebAPI provides access to the resources of an HTTP API and its function.
+type WebAPI interface {
+	// HTTPAPI returns the HTTP API.
+	HTTPAPI() awsapigatewayv2.HttpApi
+	// Function returns the function that handles every request.
+	Function() awslambda.IFunction
+	// URL returns the URL that clients reach the API at.
+	URL() *string
+}
+
+// Props configures the WebAPI construct.
+type Props struct {
+	// Name identifies the API within its stack and starts its subdomain, such as "api". It must
+	// start with a lowercase letter and contain only lowercase letters, numbers and dashes.
+	Name string
+
+	// Entry is the path of the Go main package of the function, relative to the CDK app, such as
+	// "../../backend/cmd/coreapi". Required.
+	Entry string
+
+	// Deployment is the deployment the API belongs to. It selects the subdomain and the memory of
+	// the function.
+	Deployment string
+
+	// HostedZone holds the record of the custom domain, usually the zone of the shared base. Only
+	// used with a Certificate.
+	HostedZone awsroute53.IHostedZone
+
+	// Certificate serves the API at its subdomain of the base domain name. It must cover the
+	// subdomain, as the wildcard certificate of the shared base does. Without it the API is only
+	// served at its execute-api endpoint.
+	Certificate awscertificatemanager.ICertificate
+
+	// Environment sets environment variables of the function.
+	Environment map[string]*string
+
+	// Timeout of the function. Defaults to 29 seconds, the integration timeout of API Gateway.
+	Timeout awscdk.Duration
+}
+
+type webAPI struct {
+	httpAPI  awsapigatewayv2.HttpApi
+	function awslambda.IFunction
+	url      *string
+}
+
+// New creates an HTTP API that sends every request to a Go function, serves it at the subdomain
+// of the deployment when a certificate is given, and records its URL as a stack output. It panics
+// when the name is invalid, the entry is missing, or a certificate is given without a hosted zone.
+func New(scope constructs.Construct, props Props) WebAPI {
+	if !nameRegex.MatchString(props.Name) {
+		panic(fmt.Sprintf("agcdkwebapi: invalid API name %q: must start with a lowercase letter "+
+			"and contain only lowercase letters, numbers and dashes", props.Name))
+	}
+	if props.Entry == "" {
+		panic(fmt.Sprintf("agcdkwebapi: API %q requires the Entry of its Go main package", props.Name))
+	}
+	if props.Certificate != nil && props.HostedZone == nil {
+		panic(fmt.Sprintf("agcdkwebapi: API %q has a Certificate but no HostedZone", props.Name))
+	}
+
+	scope = constructs.NewConstruct(scope, jsii.String("WebAPI"+strcase.ToCamel(props.Name)))
+	stack := awscdk.Stack_Of(scope)
+	applyPermissionsBoundary(scope)
+
+	memorySize := defaultMemorySize
+	if mib := agcdkutil.DeploymentSettingsFor(scope, props.Deployment).Sizing.MemoryMiB; mib > 0 {
+		memorySize = mib
+	}
+	timeout := props.Timeout
+	if timeout == nil {
+		timeout = awscdk.Duration_Seconds(jsii.Number(defaultTimeoutSeconds))
+	}
+
+	con := &webAPI{}
+	con.function = awscdklambdagoalpha.NewGoFunction(scope, jsii.String("Function"),
+		&awscdklambdagoalpha.GoFunctionProps{
+			Entry:        jsii.String(props.Entry),
+			Bundling:     agcdkutil.ReproducibleGoBundling(),
+			Runtime:      awslambda.Runtime_PROVIDED_AL2023(),
+			Architecture: awslambda.Architecture_ARM_64(),
+			Environment:  &props.Environment,
+			MemorySize:   jsii.Number(float64(memorySize)),
+			Timeout:      timeout,
+		})
+
+	apiProps := &awsapigatewayv2.HttpApiProps{
+		DefaultIntegration: awsapigatewayv2integrations.NewHttpLambdaIntegration(jsii.String("Integration"),
+			con.function, nil),
+	}
+
+	var domainName awsapigatewayv2.DomainName
+	if props.Certificate != nil {
+		subdomain := Subdomain(props.Name, props.Deployment)
+		domainName = awsapigatewayv2.NewDomainName(scope, jsii.String("DomainName"),
+			&awsapigatewayv2.DomainNameProps{
+				DomainName:  jsii.String(subdomain + "." + agcdkutil.BaseDomainName(scope)),
+				Certificate: props.Certificate,
+			})
+		apiProps.DefaultDomainMapping = &awsapigatewayv2.DomainMappingOptions{DomainName: domainName}
+		apiProps.DisableExecuteApiEndpoint = jsii.Bool(true)
+
+		awsroute53.NewARecord(scope, jsii.String("AliasRecord"), &awsroute53.ARecordProps{
+			Zone:          props.HostedZone,
+			RecordName:    jsii.String(subdomain),
+			Region:        stack.Region(),
+			SetIdentifier: stack.Region(),
+			Target: awsroute53.RecordTarget_FromAlias(awsroute53targets.NewApiGatewayv2DomainProperties(
+				domainName.RegionalDomainName(), domainName.RegionalHostedZoneId())),
+		})
+		con.url = jsii.String("https://" + subdomain + "." + agcdkutil.BaseDomainName(scope))
+	}
+
+	con.httpAPI = awsapigatewayv2.NewHttpApi(scope, jsii.String("HttpApi"), apiProps)
+	if con.url == nil {
+		con.url = con.httpAPI.ApiEndpoint()
+	}
+
+	agcdkutil.Output(stack, URLOutputKey(props.Name), con.url,
+		agcdkutil.OutputOptions{Description: "URL of the " + props.Name + " web API"})
+
+	return con
+}
+
+// applyPermissionsBoundary applies the permissions boundary of the project to the roles in scope:
+// the one in the context, or the one that the pre-bootstrap stack creates.
+func applyPermissionsBoundary(scope constructs.Construct) {
+	name := agcdkutil.PermissionsBoundaryName(agcdkutil.Qualifier(scope))
+	if boundary, ok := scope.Node().TryGetContext(jsii.String(permissionsBoundaryContextKey)).(map[string]any); ok {
+		if contextName, ok := boundary["name"].(string); ok && contextName != "" {
+			name = contextName
+		}
+	}
+
+	awsiam.PermissionsBoundary_Of(scope).Apply(awsiam.ManagedPolicy_FromManagedPolicyName(scope,
+		jsii.String("PermissionsBoundary"), jsii.String(name)))
+}
+
+func (w *webAPI) HTTPAPI() awsapigatewayv2.HttpApi {
+	return w.httpAPI
+}
+
+func (w *webAPI) Function() awslambda.IFunction {
+	return w.function
+}
+
+func (w *webAPI) URL() *string {
+	return w.url
+}
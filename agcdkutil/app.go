@@ -21,6 +21,12 @@ type AppConfig struct {
 	DeployersGroup string
 	// RestrictedDeployments are deployment identifiers that require DeployersGroup membership.
 	RestrictedDeployments []string
+	// DeploymentsFile is the path of the per-deployment settings file, relative to the CDK app
+	// directory. Defaults to DefaultDeploymentsFile. A missing file means no settings.
+	DeploymentsFile string
+	// TenantPageSize is the number of tenants on a page of SetupMultiTenantApp. Defaults to
+	// DefaultTenantPageSize.
+	TenantPageSize int
 }
 
 // SetupApp configures a CDK app with multi-region, multi-deployment stacks.
@@ -31,6 +37,9 @@ type AppConfig struct {
 //  3. Deployment stacks for each allowed deployment in the primary region
 //  4. Secondary deployment stacks for each secondary region (dependent on primary deployment)
 //
+// It also adds the [PreserveExports] aspect, which keeps exports alive that other stacks still
+// import.
+//
 // The type parameter S represents the shared construct type returned by SharedConstructor.
 // SetupApp validates all context values upfront and panics with a clear error message
 // if any required values are missing or invalid.
@@ -40,38 +49,62 @@ func SetupApp[S any](
 	newShared SharedConstructor[S],
 	newDeployment DeploymentConstructor[S],
 ) {
+	stacks := setupStacks(app, cfg, newShared)
+	for _, deploymentIdent := range stacks.config.AllowedDeployments() {
+		stacks.addDeployment(app, deploymentIdent, func(stack awscdk.Stack, shared S) {
+			newDeployment(stack, shared, deploymentIdent)
+		})
+	}
+}
+
+// appStacks are the shared stacks of an app, which the deployment stacks are added to.
+type appStacks[S any] struct {
+	config             *Config
+	primarySharedStack awscdk.Stack
+	primaryShared      S
+	secondaryShared    map[string]S
+}
+
+// setupStacks validates the context, stores the config and creates the shared stacks.
+func setupStacks[S any](app awscdk.App, cfg AppConfig, newShared SharedConstructor[S]) *appStacks[S] {
 	// Validate all context values upfront and store in construct tree
 	config, err := NewConfig(app, cfg)
 	if err != nil {
 		panic(err)
 	}
 	StoreConfig(app, config)
+	awscdk.Aspects_Of(app).Add(PreserveExports(), nil)
 
 	// Create shared primary region stack first
-	primarySharedStack := NewStackFromConfig(app, config, config.PrimaryRegion)
-	primaryShared := newShared(primarySharedStack)
+	stacks := &appStacks[S]{config: config, secondaryShared: map[string]S{}}
+	stacks.primarySharedStack = NewStackFromConfig(app, config, config.PrimaryRegion)
+	stacks.primaryShared = newShared(stacks.primarySharedStack)
 
 	// Create secondary shared region stacks with dependency on primary
-	secondaryShared := map[string]S{}
 	for _, region := range config.SecondaryRegions {
 		secondarySharedStack := NewStackFromConfig(app, config, region)
-		secondaryShared[region] = newShared(secondarySharedStack)
-		secondarySharedStack.AddDependency(primarySharedStack, jsii.String("Primary region must deploy first"))
+		stacks.secondaryShared[region] = newShared(secondarySharedStack)
+		secondarySharedStack.AddDependency(stacks.primarySharedStack,
+			jsii.String("Primary region must deploy first"))
 	}
+	return stacks
+}
 
-	// Create stacks for each allowed deployment
-	for _, deploymentIdent := range config.AllowedDeployments() {
-		primaryDeploymentStack := NewStackFromConfig(app, config, config.PrimaryRegion, deploymentIdent)
-		newDeployment(primaryDeploymentStack, primaryShared, deploymentIdent)
-		primaryDeploymentStack.AddDependency(primarySharedStack,
-			jsii.String("Primary shared stack must deploy first"))
+// addDeployment creates the stacks of a deployment in every region, which build fills with the
+// shared construct of the region.
+func (s *appStacks[S]) addDeployment(
+	app awscdk.App, deploymentIdent string, build func(stack awscdk.Stack, shared S),
+) {
+	primaryDeploymentStack := NewStackFromConfig(app, s.config, s.config.PrimaryRegion, deploymentIdent)
+	build(primaryDeploymentStack, s.primaryShared)
+	primaryDeploymentStack.AddDependency(s.primarySharedStack,
+		jsii.String("Primary shared stack must deploy first"))
 
-		// Secondary region stacks for each deployment
-		for _, region := range config.SecondaryRegions {
-			secondaryDeploymentStack := NewStackFromConfig(app, config, region, deploymentIdent)
-			newDeployment(secondaryDeploymentStack, secondaryShared[region], deploymentIdent)
-			secondaryDeploymentStack.AddDependency(primaryDeploymentStack,
-				jsii.String("Primary region deployment must deploy first"))
-		}
+	// Secondary region stacks for each deployment
+	for _, region := range s.config.SecondaryRegions {
+		secondaryDeploymentStack := NewStackFromConfig(app, s.config, region, deploymentIdent)
+		build(secondaryDeploymentStack, s.secondaryShared[region])
+		secondaryDeploymentStack.AddDependency(primaryDeploymentStack,
+			jsii.String("Primary region deployment must deploy first"))
 	}
 }
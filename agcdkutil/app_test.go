@@ -133,3 +133,57 @@ func TestSetupApp_WithSecondaryRegions(t *testing.T) {
 		}
 	}
 }
+
+func TestSetupApp_DeployRoleSessionTags(t *testing.T) {
+	defer jsii.Close()
+	t.Setenv("CDK_DEFAULT_ACCOUNT", "123456789012")
+
+	ctx := map[string]any{
+		"myapp-qualifier":         "myapp",
+		"myapp-primary-region":    "us-east-1",
+		"myapp-secondary-regions": []any{},
+		"myapp-deployments":       []any{"DevAdam"},
+		"myapp-deployer-groups":   "myapp-deployers",
+		"myapp-base-domain-name":  "example.com",
+	}
+
+	app := awscdk.NewApp(&awscdk.AppProps{
+		Context: &ctx,
+	})
+
+	agcdkutil.SetupApp(app, agcdkutil.AppConfig{
+		Prefix:         "myapp-",
+		DeployersGroup: "myapp-deployers",
+	},
+		func(stack awscdk.Stack) *testShared {
+			awscdk.NewCfnWaitConditionHandle(stack, jsii.String("Placeholder"), nil)
+			return &testShared{}
+		},
+		func(stack awscdk.Stack, _ *testShared, _ string) {
+			awscdk.NewCfnWaitConditionHandle(stack, jsii.String("Placeholder"), nil)
+		},
+	)
+
+	assembly := app.Synth(nil)
+
+	want := map[string]string{
+		"myappUse1Shared":  agcdkutil.SharedDeploymentTag,
+		"myappUse1DevAdam": "DevAdam",
+	}
+	for stackName, wantTag := range want {
+		artifact := assembly.GetStackByName(jsii.String(stackName))
+		opts := artifact.AssumeRoleAdditionalOptions()
+		if opts == nil {
+			t.Fatalf("%s: expected assume role options, got nil", stackName)
+		}
+
+		tags, ok := (*opts)["Tags"].([]any)
+		if !ok || len(tags) != 1 {
+			t.Fatalf("%s: expected one session tag, got %v", stackName, (*opts)["Tags"])
+		}
+		tag, _ := tags[0].(map[string]any)
+		if tag["Key"] != agcdkutil.DeploymentSessionTagKey || tag["Value"] != wantTag {
+			t.Errorf("%s: session tag = %v, want %s=%s", stackName, tag, agcdkutil.DeploymentSessionTagKey, wantTag)
+		}
+	}
+}
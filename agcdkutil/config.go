@@ -1,6 +1,7 @@
 package agcdkutil
 
 import (
+	"cmp"
 	"fmt"
 	"slices"
 	"strings"
@@ -51,6 +52,12 @@ func RegionIdent(scope constructs.Construct, region string) string {
 	return ConfigFromScope(scope).RegionIdent(region)
 }
 
+// Partition returns the AWS partition the project is deployed in.
+// Retrieves Config from the construct tree.
+func Partition(scope constructs.Construct) string {
+	return ConfigFromScope(scope).Partition()
+}
+
 // Qualifier returns the CDK qualifier.
 // Retrieves Config from the construct tree.
 func Qualifier(scope constructs.Construct) string {
@@ -69,6 +76,49 @@ func DNSDelegated(scope constructs.Construct) bool {
 	return ConfigFromScope(scope).DNSDelegated
 }
 
+// ImageTag returns the tag of a backend image for a deployment.
+// Retrieves Config from the construct tree.
+func ImageTag(scope constructs.Construct, deployment, name string) string {
+	return ConfigFromScope(scope).ImageTag(deployment, name)
+}
+
+// ImageDigest returns the digest of a backend image for a deployment.
+// Retrieves Config from the construct tree.
+func ImageDigest(scope constructs.Construct, deployment, name string) string {
+	return ConfigFromScope(scope).ImageDigest(deployment, name)
+}
+
+// BaseImageDigest returns the digest of the pinned base image.
+// Retrieves Config from the construct tree.
+func BaseImageDigest(scope constructs.Construct) string {
+	return ConfigFromScope(scope).BaseImageDigest()
+}
+
+// JobDisabled reports whether 'ago jobs disable' switched off the named job of a deployment.
+// Retrieves Config from the construct tree.
+func JobDisabled(scope constructs.Construct, deployment, name string) bool {
+	return ConfigFromScope(scope).JobDisabled(deployment, name)
+}
+
+// DeploymentSettingsFor returns the settings of a deployment from infra/deployments.yaml.
+// Retrieves Config from the construct tree.
+func DeploymentSettingsFor(scope constructs.Construct, deployment string) DeploymentSettings {
+	return ConfigFromScope(scope).DeploymentSettings(deployment)
+}
+
+// FeatureEnabled reports whether a feature is switched on for a deployment.
+// Retrieves Config from the construct tree.
+func FeatureEnabled(scope constructs.Construct, deployment, name string) bool {
+	return ConfigFromScope(scope).DeploymentSettings(deployment).FeatureEnabled(name)
+}
+
+// Placeholders that a sandbox synth uses for values that are only known after pushing images.
+const (
+	SandboxImageTag        = "sandbox"
+	SandboxImageDigest     = "sha256:0000000000000000000000000000000000000000000000000000000000000000"
+	SandboxBaseImageDigest = "sha256:0000000000000000000000000000000000000000000000000000000000000000"
+)
+
 // Config holds all CDK context values validated upfront.
 // It centralizes context reading and validation to provide clear error messages.
 type Config struct {
@@ -83,6 +133,43 @@ type Config struct {
 	// Validation flags for foundational infrastructure
 	DNSDelegated bool // true when DNS delegation is complete
 
+	// ImageTags maps deployment to backend image name to the tag pushed by
+	// 'ago backend build-and-push'. Optional.
+	ImageTags map[string]map[string]string
+
+	// ImageDigests maps deployment to backend image name to the digest of the image that
+	// 'ago backend build-and-push' pushed, for references that cannot change. Optional.
+	ImageDigests map[string]map[string]string
+
+	// PreservedExports maps stack name to export name to the value of exports that 'ago infra cdk
+	// deploy' keeps alive because other stacks still import them, see PreserveExports. Optional.
+	PreservedExports map[string]map[string]string
+
+	// BaseImage is the digest of the base image pinned by 'ago backend bump-base'. Optional.
+	BaseImage string
+
+	// DisabledJobs maps deployment to job name to the reason that 'ago jobs disable' recorded
+	// for switching off the schedule of the job. Optional.
+	DisabledJobs map[string]map[string]string
+
+	// Sandbox is set by 'ago infra cdk sandbox-synth', which synthesizes without AWS access.
+	// Image tags and the base image digest that were never recorded resolve to placeholders.
+	Sandbox bool
+
+	// Tenants are the idents of the tenants of a multi-tenant app, each of which gets a deployment
+	// of its own, see SetupMultiTenantApp. Optional.
+	Tenants []string
+
+	// TenantFilter and TenantPage limit a synth to a single tenant, or to a page of
+	// TenantPageSize tenants counting from 1. Zero values synthesize all tenants.
+	TenantFilter   string
+	TenantPage     int
+	TenantPageSize int
+
+	// DeploymentsFile holds the per-deployment settings from infra/deployments.yaml. It is
+	// validated when it is loaded.
+	DeploymentsFile DeploymentsFile `validate:"-"`
+
 	// From AppConfig (not context)
 	DeployersGroup        string   `validate:"required"`
 	RestrictedDeployments []string `validate:"dive,required"`
@@ -97,6 +184,7 @@ func NewConfig(scope constructs.Construct, acfg AppConfig) (*Config, error) {
 		Prefix:                acfg.Prefix,
 		DeployersGroup:        acfg.DeployersGroup,
 		RestrictedDeployments: acfg.RestrictedDeployments,
+		TenantPageSize:        cmp.Or(acfg.TenantPageSize, DefaultTenantPageSize),
 	}
 
 	cfg.Qualifier, readErrs = readContextString(scope, acfg.Prefix+"qualifier", readErrs)
@@ -105,6 +193,18 @@ func NewConfig(scope constructs.Construct, acfg AppConfig) (*Config, error) {
 	cfg.Deployments, readErrs = readContextStringSlice(scope, acfg.Prefix+"deployments", readErrs)
 	cfg.BaseDomainName, readErrs = readContextString(scope, acfg.Prefix+"base-domain-name", readErrs)
 	cfg.DNSDelegated = readOptionalContextBool(scope, acfg.Prefix+"dns-delegated")
+	cfg.ImageTags = readOptionalStringMaps(scope, acfg.Prefix+"image-tags")
+	cfg.ImageDigests = readOptionalStringMaps(scope, acfg.Prefix+"image-digests")
+	cfg.PreservedExports = readOptionalStringMaps(scope, acfg.Prefix+PreservedExportsContextKey)
+	cfg.BaseImage, _ = scope.Node().TryGetContext(jsii.String(acfg.Prefix + "base-image")).(string)
+	cfg.DisabledJobs = readOptionalStringMaps(scope, acfg.Prefix+DisabledJobsContextKey)
+	cfg.Sandbox = readOptionalContextFlag(scope, acfg.Prefix+"sandbox")
+	if scope.Node().TryGetContext(jsii.String(acfg.Prefix+TenantsContextKey)) != nil {
+		cfg.Tenants, readErrs = readContextStringSlice(scope, acfg.Prefix+TenantsContextKey, readErrs)
+	}
+	cfg.TenantFilter, _ = scope.Node().TryGetContext(jsii.String(acfg.Prefix + TenantContextKey)).(string)
+	cfg.TenantPage, readErrs = readOptionalContextInt(scope, acfg.Prefix+TenantPageContextKey, readErrs)
+	readErrs = append(readErrs, validateTenants(cfg.Tenants, cfg.Deployments, cfg.TenantFilter, cfg.TenantPage)...)
 
 	// Validate that all regions are known
 	if cfg.PrimaryRegion != "" && !IsKnownRegion(cfg.PrimaryRegion) {
@@ -116,6 +216,22 @@ func NewConfig(scope constructs.Construct, acfg AppConfig) (*Config, error) {
 			readErrs = append(readErrs, fmt.Sprintf(
 				"unknown secondary region %q - add it to agcdkutil.RegionIdents", region))
 		}
+		if cfg.PrimaryRegion != "" && PartitionFor(region) != PartitionFor(cfg.PrimaryRegion) {
+			readErrs = append(readErrs, fmt.Sprintf(
+				"secondary region %q is in partition %q, but the primary region is in %q",
+				region, PartitionFor(region), PartitionFor(cfg.PrimaryRegion)))
+		}
+	}
+
+	if len(cfg.Deployments) > 0 {
+		path := acfg.DeploymentsFile
+		if path == "" {
+			path = DefaultDeploymentsFile
+		}
+		var err error
+		if cfg.DeploymentsFile, err = LoadDeploymentsFile(path, cfg.Deployments); err != nil {
+			readErrs = append(readErrs, err.Error())
+		}
 	}
 
 	// DeployerGroups is optional (nil during bootstrap)
@@ -148,6 +264,11 @@ func (c *Config) AllRegions() []string {
 	return append([]string{c.PrimaryRegion}, c.SecondaryRegions...)
 }
 
+// Partition returns the AWS partition of the primary region, which all regions share.
+func (c *Config) Partition() string {
+	return PartitionFor(c.PrimaryRegion)
+}
+
 // RegionIdent returns the acronym identifier for a region.
 func (c *Config) RegionIdent(region string) string {
 	return RegionIdentFor(region)
@@ -168,6 +289,16 @@ func (c *Config) BaseDomainNamePtr() *string {
 	return jsii.String(c.BaseDomainName)
 }
 
+// DisabledJobsContextKey is the context key, after the prefix, under which 'ago jobs disable'
+// records the jobs whose schedule is switched off, see Config.DisabledJobs.
+const DisabledJobsContextKey = "disabled-jobs"
+
+// JobDisabled reports whether the named job of a deployment is recorded in DisabledJobs.
+func (c *Config) JobDisabled(deployment, name string) bool {
+	_, ok := c.DisabledJobs[deployment][name]
+	return ok
+}
+
 // configContextKey is the well-known key used to store validated Config in the construct tree.
 const configContextKey = "__agcdkutil_config"
 
@@ -191,6 +322,56 @@ func ConfigFromScope(scope constructs.Construct) *Config {
 	return cfg
 }
 
+// ImageTag returns the tag that 'ago backend build-and-push' recorded for the named image
+// (a backend command or component) of a deployment. It panics when no tag was recorded, since
+// the deployment cannot reference an image that was never pushed. A sandbox synth gets
+// SandboxImageTag instead.
+func (c *Config) ImageTag(deployment, name string) string {
+	tag, ok := c.ImageTags[deployment][name]
+	switch {
+	case !ok && c.Sandbox:
+		return SandboxImageTag
+	case !ok:
+		panic(fmt.Sprintf("no image tag for %q in deployment %q - run 'ago backend build-and-push --deployment %s'",
+			name, deployment, deployment))
+	}
+	return tag
+}
+
+// ImageDigest returns the digest of the image that 'ago backend build-and-push' recorded for the
+// named image of a deployment, to reference it as repository@digest. It panics when no digest was
+// recorded, and a sandbox synth gets SandboxImageDigest instead, like ImageTag.
+func (c *Config) ImageDigest(deployment, name string) string {
+	digest, ok := c.ImageDigests[deployment][name]
+	switch {
+	case !ok && c.Sandbox:
+		return SandboxImageDigest
+	case !ok:
+		panic(fmt.Sprintf("no image digest for %q in deployment %q - run 'ago backend build-and-push --deployment %s'",
+			name, deployment, deployment))
+	}
+	return digest
+}
+
+// BaseImageDigest returns the digest that 'ago backend bump-base' pinned for the base
+// image. The image is replicated, so the digest is valid in the repositories of all regions. It
+// panics when no base image was pinned, except in a sandbox synth, which gets SandboxBaseImageDigest.
+func (c *Config) BaseImageDigest() string {
+	switch {
+	case c.BaseImage == "" && c.Sandbox:
+		return SandboxBaseImageDigest
+	case c.BaseImage == "":
+		panic("no base image pinned - run 'ago backend bump-base'")
+	}
+	return c.BaseImage
+}
+
+// DeploymentSettings returns the settings of a deployment from infra/deployments.yaml, or the
+// zero settings if the deployment has none.
+func (c *Config) DeploymentSettings(deployment string) DeploymentSettings {
+	return c.DeploymentsFile.Settings(deployment)
+}
+
 // AllowedDeployments returns deployments the current deployer can access.
 // Returns nil if DeployerGroups is nil (bootstrap mode).
 func (c *Config) AllowedDeployments() []string {
@@ -281,3 +462,38 @@ func readOptionalContextBool(scope constructs.Construct, key string) bool {
 	}
 	return b
 }
+
+// readOptionalContextFlag reads a boolean context key that may also be passed as a string
+// with -c on the command line.
+func readOptionalContextFlag(scope constructs.Construct, key string) bool {
+	switch val := scope.Node().TryGetContext(jsii.String(key)).(type) {
+	case bool:
+		return val
+	case string:
+		return val == "true"
+	default:
+		return false
+	}
+}
+
+func readOptionalStringMaps(scope constructs.Construct, key string) map[string]map[string]string {
+	val, ok := scope.Node().TryGetContext(jsii.String(key)).(map[string]any)
+	if !ok {
+		return nil
+	}
+
+	result := make(map[string]map[string]string, len(val))
+	for outer, v := range val {
+		inner, ok := v.(map[string]any)
+		if !ok {
+			continue
+		}
+		result[outer] = make(map[string]string, len(inner))
+		for key, value := range inner {
+			if s, ok := value.(string); ok {
+				result[outer][key] = s
+			}
+		}
+	}
+	return result
+}
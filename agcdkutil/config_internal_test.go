@@ -57,3 +57,37 @@ func TestRegionIdentLower(t *testing.T) {
 		t.Errorf("RegionIdentLower(eu-west-1) = %q, want %q", got, "euw1")
 	}
 }
+
+func TestPartitionFor(t *testing.T) {
+	tests := []struct {
+		region        string
+		wantPartition string
+		wantSuffix    string
+	}{
+		{"us-east-1", "aws", "amazonaws.com"},
+		{"eu-central-1", "aws", "amazonaws.com"},
+		{"us-gov-west-1", "aws-us-gov", "amazonaws.com"},
+		{"cn-north-1", "aws-cn", "amazonaws.com.cn"},
+		{"eusc-de-east-1", "aws-eusc", "amazonaws.eu"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.region, func(t *testing.T) {
+			partition := PartitionFor(tt.region)
+			if partition != tt.wantPartition {
+				t.Errorf("PartitionFor(%q) = %q, want %q", tt.region, partition, tt.wantPartition)
+			}
+			if got := PartitionDNSSuffix(partition); got != tt.wantSuffix {
+				t.Errorf("PartitionDNSSuffix(%q) = %q, want %q", partition, got, tt.wantSuffix)
+			}
+		})
+	}
+}
+
+func TestARN(t *testing.T) {
+	got := ARN(PartitionAWSCN, "iam", "", "123456789012", "policy/myapp-execution-policy")
+	want := "arn:aws-cn:iam::123456789012:policy/myapp-execution-policy"
+	if got != want {
+		t.Errorf("ARN() = %q, want %q", got, want)
+	}
+}
@@ -131,6 +131,22 @@ func TestNewConfig(t *testing.T) {
 			wantErr:     true,
 			errContains: []string{"unknown secondary region"},
 		},
+		{
+			name: "secondary region in other partition",
+			context: map[string]any{
+				"myapp-qualifier":         "myapp",
+				"myapp-primary-region":    "us-gov-west-1",
+				"myapp-secondary-regions": []any{"us-east-1"},
+				"myapp-deployments":       []any{"Dev"},
+				"myapp-base-domain-name":  "example.com",
+			},
+			appConfig: agcdkutil.AppConfig{
+				Prefix:         "myapp-",
+				DeployersGroup: "myapp-deployers",
+			},
+			wantErr:     true,
+			errContains: []string{"secondary region \"us-east-1\"", "aws-us-gov"},
+		},
 		{
 			name: "multiple errors",
 			context: map[string]any{
@@ -353,3 +369,116 @@ func TestConfig_RegionIdent(t *testing.T) {
 		t.Errorf("RegionIdent(eu-west-1) = %q, want %q", ident, "Euw1")
 	}
 }
+
+func TestConfig_ImageTag(t *testing.T) {
+	defer jsii.Close()
+
+	app := awscdk.NewApp(&awscdk.AppProps{
+		Context: &map[string]any{
+			"myapp-qualifier":         "myapp",
+			"myapp-primary-region":    "us-east-1",
+			"myapp-secondary-regions": []any{},
+			"myapp-deployments":       []any{"Dev"},
+			"myapp-base-domain-name":  "example.com",
+			"myapp-image-tags": map[string]any{
+				"dev": map[string]any{
+					"coreapi": "coreapi-dev-abc123",
+					"worker":  "worker-dev-def456",
+				},
+			},
+			"myapp-image-digests": map[string]any{
+				"dev": map[string]any{"worker": "sha256:def456"},
+			},
+		},
+	})
+
+	cfg, err := agcdkutil.NewConfig(app, agcdkutil.AppConfig{
+		Prefix:         "myapp-",
+		DeployersGroup: "deployers",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if got := cfg.ImageTag("dev", "worker"); got != "worker-dev-def456" {
+		t.Errorf("ImageTag(dev, worker) = %q, want %q", got, "worker-dev-def456")
+	}
+	if got := cfg.ImageDigest("dev", "worker"); got != "sha256:def456" {
+		t.Errorf("ImageDigest(dev, worker) = %q, want %q", got, "sha256:def456")
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("expected panic for missing image tag")
+		}
+	}()
+	cfg.ImageTag("prod", "worker")
+}
+
+func TestConfig_Sandbox(t *testing.T) {
+	defer jsii.Close()
+
+	app := awscdk.NewApp(&awscdk.AppProps{
+		Context: &map[string]any{
+			"myapp-qualifier":         "myapp",
+			"myapp-primary-region":    "us-east-1",
+			"myapp-secondary-regions": []any{},
+			"myapp-deployments":       []any{"Dev"},
+			"myapp-base-domain-name":  "example.com",
+			"myapp-sandbox":           "true",
+		},
+	})
+
+	cfg, err := agcdkutil.NewConfig(app, agcdkutil.AppConfig{
+		Prefix:         "myapp-",
+		DeployersGroup: "deployers",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !cfg.Sandbox {
+		t.Fatal("expected Sandbox to be set")
+	}
+	if got := cfg.ImageTag("dev", "worker"); got != agcdkutil.SandboxImageTag {
+		t.Errorf("ImageTag(dev, worker) = %q, want %q", got, agcdkutil.SandboxImageTag)
+	}
+	if got := cfg.ImageDigest("dev", "worker"); got != agcdkutil.SandboxImageDigest {
+		t.Errorf("ImageDigest(dev, worker) = %q, want %q", got, agcdkutil.SandboxImageDigest)
+	}
+	if got := cfg.BaseImageDigest(); got != agcdkutil.SandboxBaseImageDigest {
+		t.Errorf("BaseImageDigest() = %q, want %q", got, agcdkutil.SandboxBaseImageDigest)
+	}
+}
+
+func TestConfig_JobDisabled(t *testing.T) {
+	defer jsii.Close()
+
+	app := awscdk.NewApp(&awscdk.AppProps{
+		Context: &map[string]any{
+			"myapp-qualifier":         "myapp",
+			"myapp-primary-region":    "us-east-1",
+			"myapp-secondary-regions": []any{},
+			"myapp-deployments":       []any{"Dev"},
+			"myapp-base-domain-name":  "example.com",
+			"myapp-" + agcdkutil.DisabledJobsContextKey: map[string]any{
+				"Dev": map[string]any{"nightly-report": "flaky upstream"},
+			},
+		},
+	})
+
+	cfg, err := agcdkutil.NewConfig(app, agcdkutil.AppConfig{
+		Prefix:         "myapp-",
+		DeployersGroup: "deployers",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !cfg.JobDisabled("Dev", "nightly-report") {
+		t.Error("expected nightly-report to be disabled in Dev")
+	}
+	if cfg.JobDisabled("Dev", "cleanup") || cfg.JobDisabled("Prod", "nightly-report") {
+		t.Error("expected other jobs and deployments to stay enabled")
+	}
+}
@@ -0,0 +1,255 @@
+package agcdkutil
+
+import (
+	"cmp"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/go-playground/validator/v10"
+	"github.com/goccy/go-yaml"
+)
+
+// DefaultDeploymentsFile is where the deployment settings are read from, relative to the CDK app
+// directory (infra/cdk/cdk), so that it resolves to infra/deployments.yaml.
+const DefaultDeploymentsFile = "../../deployments.yaml"
+
+// Rollout strategies.
+const (
+	// RolloutAllAtOnce shifts all traffic to a new version at once.
+	RolloutAllAtOnce = "all-at-once"
+	// RolloutCanary shifts Percent of the traffic first and the rest after IntervalMinutes.
+	RolloutCanary = "canary"
+	// RolloutLinear shifts Percent of the traffic every IntervalMinutes.
+	RolloutLinear = "linear"
+)
+
+// DeploymentsFile holds the settings of each deployment, as declared in infra/deployments.yaml:
+//
+//	deployments:
+//	  Prod:
+//	    sizing: {memory_mib: 1024, min_capacity: 2, max_capacity: 10}
+//	    features: {new-checkout: false}
+//	    alarms: {api-5xx-rate: 0.01}
+//	    deploy_windows:
+//	      - {days: [mon, tue, wed, thu], start: "09:00", end: "16:00", timezone: Europe/Amsterdam}
+//	    rollout: {strategy: canary, percent: 10, interval_minutes: 5}
+//
+// Deployments without an entry use the zero DeploymentSettings. The shared stacks have no
+// deployment, so their budget is set at the top level:
+//
+//	shared_budget: {max_resources: 400}
+type DeploymentsFile struct {
+	Deployments  map[string]DeploymentSettings `yaml:"deployments" validate:"dive"`
+	SharedBudget StackBudget                   `yaml:"shared_budget,omitempty"`
+}
+
+// DeploymentSettings are the knobs of a single deployment.
+type DeploymentSettings struct {
+	// Sizing of the deployment's compute. Zero values leave the construct defaults in place.
+	Sizing Sizing `yaml:"sizing,omitempty"`
+	// Features toggles named features on or off.
+	Features map[string]bool `yaml:"features,omitempty"`
+	// Alarms overrides alarm thresholds, keyed by alarm name.
+	Alarms map[string]float64 `yaml:"alarms,omitempty"`
+	// DeployWindows restricts when 'ago infra cdk deploy' may deploy. Empty means any time.
+	DeployWindows []DeployWindow `yaml:"deploy_windows,omitempty" validate:"dive"`
+	// Rollout selects how traffic shifts to a new version.
+	Rollout Rollout `yaml:"rollout,omitempty"`
+	// Budget caps the size of the deployment's stacks below the CloudFormation limits.
+	Budget StackBudget `yaml:"budget,omitempty"`
+}
+
+// Sizing configures the capacity of a deployment.
+type Sizing struct {
+	MemoryMiB   int `yaml:"memory_mib,omitempty" validate:"omitempty,min=128,max=10240"`
+	MinCapacity int `yaml:"min_capacity,omitempty" validate:"omitempty,min=0"`
+	MaxCapacity int `yaml:"max_capacity,omitempty" validate:"omitempty,gtefield=MinCapacity"`
+}
+
+// DeployWindow is a daily time range in which deploys are allowed.
+type DeployWindow struct {
+	// Days are the weekdays the window applies to (mon..sun). Empty means every day.
+	Days []string `yaml:"days,omitempty" validate:"dive,oneof=mon tue wed thu fri sat sun"`
+	// Start and End are times of day in 24-hour "15:04" notation.
+	Start string `yaml:"start" validate:"required,datetime=15:04"`
+	End   string `yaml:"end" validate:"required,datetime=15:04"`
+	// Timezone is an IANA time zone name. Defaults to UTC.
+	Timezone string `yaml:"timezone,omitempty" validate:"omitempty,timezone"`
+}
+
+// Rollout configures how traffic shifts to a new version.
+type Rollout struct {
+	Strategy        string `yaml:"strategy,omitempty" validate:"omitempty,oneof=all-at-once canary linear"`
+	Percent         int    `yaml:"percent,omitempty" validate:"required_unless=Strategy '' Strategy all-at-once,omitempty,min=1,max=99"`
+	IntervalMinutes int    `yaml:"interval_minutes,omitempty" validate:"required_unless=Strategy '' Strategy all-at-once,omitempty,min=1"`
+}
+
+// CloudFormation limits of a single stack. Templates above MaxTemplateBytes cannot be deployed
+// even from S3, which is where CDK uploads them.
+const (
+	MaxStackResources = 500
+	MaxTemplateBytes  = 1_000_000
+	MaxStackOutputs   = 200
+)
+
+// StackBudget caps the size of a stack's synthesized template. Zero values fall back to the
+// CloudFormation limits, and budgets above the limits are rejected.
+type StackBudget struct {
+	MaxResources     int `yaml:"max_resources,omitempty" validate:"omitempty,min=1,max=500"`
+	MaxTemplateBytes int `yaml:"max_template_bytes,omitempty" validate:"omitempty,min=1,max=1000000"`
+	MaxOutputs       int `yaml:"max_outputs,omitempty" validate:"omitempty,min=1,max=200"`
+}
+
+// WithDefaults returns the budget with the CloudFormation limits in place of unset values.
+func (b StackBudget) WithDefaults() StackBudget {
+	return StackBudget{
+		MaxResources:     cmp.Or(b.MaxResources, MaxStackResources),
+		MaxTemplateBytes: cmp.Or(b.MaxTemplateBytes, MaxTemplateBytes),
+		MaxOutputs:       cmp.Or(b.MaxOutputs, MaxStackOutputs),
+	}
+}
+
+// StrategyOrDefault returns the rollout strategy, or RolloutAllAtOnce if none is set.
+func (r Rollout) StrategyOrDefault() string {
+	if r.Strategy != "" {
+		return r.Strategy
+	}
+	return RolloutAllAtOnce
+}
+
+// LoadDeploymentsFile reads and validates a deployments file. A missing file is not an error and
+// yields no settings. Settings for deployments that are not in the known deployments are rejected,
+// since they are most likely typos.
+func LoadDeploymentsFile(path string, deployments []string) (DeploymentsFile, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return DeploymentsFile{}, nil
+	}
+	if err != nil {
+		return DeploymentsFile{}, errors.Wrapf(err, "failed to read %s", path)
+	}
+
+	var file DeploymentsFile
+	if err := yaml.UnmarshalWithOptions(data, &file, yaml.Strict()); err != nil {
+		return DeploymentsFile{}, errors.Wrapf(err, "failed to parse %s", path)
+	}
+
+	var msgs []string
+	for name := range file.Deployments {
+		if !slices.Contains(deployments, name) {
+			msgs = append(msgs, "unknown deployment "+name)
+		}
+	}
+	slices.Sort(msgs)
+
+	validate := validator.New(validator.WithRequiredStructEnabled())
+	if err := validate.Struct(file); err != nil {
+		var validationErrs validator.ValidationErrors
+		if !errors.As(err, &validationErrs) {
+			return DeploymentsFile{}, errors.Wrapf(err, "failed to validate %s", path)
+		}
+		for _, e := range validationErrs {
+			msgs = append(msgs, formatDeploymentsValidationError(e))
+		}
+	}
+
+	if len(msgs) > 0 {
+		return DeploymentsFile{}, errors.Errorf("invalid %s:\n  - %s", path, strings.Join(msgs, "\n  - "))
+	}
+
+	return file, nil
+}
+
+func formatDeploymentsValidationError(e validator.FieldError) string {
+	// Namespaces read like DeploymentsFile.Deployments[Prod].Rollout.Percent.
+	field := strings.TrimPrefix(e.Namespace(), "DeploymentsFile.")
+	switch e.Tag() {
+	case "oneof":
+		return field + " must be one of: " + e.Param()
+	case "datetime":
+		return field + " must be a time of day like 09:30"
+	case "timezone":
+		return field + " must be an IANA time zone like Europe/Amsterdam"
+	case "required_unless":
+		return field + " is required for this rollout strategy"
+	default:
+		return field + " failed validation " + e.Tag() + " " + e.Param()
+	}
+}
+
+// Settings returns the settings of a deployment, or the zero settings if it has none.
+func (f DeploymentsFile) Settings(deployment string) DeploymentSettings {
+	return f.Deployments[deployment]
+}
+
+// Budget returns the budget of a deployment's stacks, or of the shared stacks when deployment is
+// empty, with the CloudFormation limits as defaults.
+func (f DeploymentsFile) Budget(deployment string) StackBudget {
+	if deployment == "" {
+		return f.SharedBudget.WithDefaults()
+	}
+	return f.Deployments[deployment].Budget.WithDefaults()
+}
+
+// FeatureEnabled reports whether the named feature is switched on.
+func (s DeploymentSettings) FeatureEnabled(name string) bool {
+	return s.Features[name]
+}
+
+// AlarmThreshold returns the configured threshold of the named alarm, or def if none is set.
+func (s DeploymentSettings) AlarmThreshold(name string, def float64) float64 {
+	if v, ok := s.Alarms[name]; ok {
+		return v
+	}
+	return def
+}
+
+// InDeployWindow reports whether t falls in one of the deploy windows. Without windows, every
+// moment is in a window.
+func (s DeploymentSettings) InDeployWindow(t time.Time) bool {
+	if len(s.DeployWindows) == 0 {
+		return true
+	}
+	return slices.ContainsFunc(s.DeployWindows, func(w DeployWindow) bool { return w.Contains(t) })
+}
+
+// Contains reports whether t falls in the window. Windows whose end is before their start span
+// midnight, and then apply to the day they start on.
+func (w DeployWindow) Contains(t time.Time) bool {
+	loc := time.UTC
+	if w.Timezone != "" {
+		var err error
+		if loc, err = time.LoadLocation(w.Timezone); err != nil {
+			return false
+		}
+	}
+	t = t.In(loc)
+
+	start, errStart := time.Parse("15:04", w.Start)
+	end, errEnd := time.Parse("15:04", w.End)
+	if errStart != nil || errEnd != nil {
+		return false
+	}
+
+	minutes := t.Hour()*60 + t.Minute()
+	startMinutes, endMinutes := start.Hour()*60+start.Minute(), end.Hour()*60+end.Minute()
+
+	day := t
+	var in bool
+	switch {
+	case startMinutes <= endMinutes:
+		in = minutes >= startMinutes && minutes < endMinutes
+	case minutes >= startMinutes:
+		in = true
+	case minutes < endMinutes:
+		in, day = true, t.AddDate(0, 0, -1)
+	}
+
+	if !in || len(w.Days) == 0 {
+		return in
+	}
+	return slices.Contains(w.Days, strings.ToLower(day.Weekday().String()[:3]))
+}
@@ -0,0 +1,182 @@
+package agcdkutil_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/advdv/ago/agcdkutil"
+)
+
+func TestLoadDeploymentsFile(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name        string
+		content     string
+		wantErr     []string
+		checkResult func(t *testing.T, f agcdkutil.DeploymentsFile)
+	}{
+		{
+			name: "valid settings",
+			content: `deployments:
+  Prod:
+    sizing: {memory_mib: 1024, min_capacity: 2, max_capacity: 10}
+    features: {new-checkout: true}
+    alarms: {api-5xx-rate: 0.01}
+    deploy_windows:
+      - {days: [mon, tue], start: "09:00", end: "16:00", timezone: Europe/Amsterdam}
+    rollout: {strategy: canary, percent: 10, interval_minutes: 5}
+`,
+			checkResult: func(t *testing.T, f agcdkutil.DeploymentsFile) {
+				t.Helper()
+				prod := f.Settings("Prod")
+				if prod.Sizing.MemoryMiB != 1024 || prod.Rollout.Strategy != agcdkutil.RolloutCanary {
+					t.Errorf("unexpected settings: %+v", prod)
+				}
+				if !prod.FeatureEnabled("new-checkout") || prod.FeatureEnabled("other") {
+					t.Errorf("unexpected features: %v", prod.Features)
+				}
+				if got := prod.AlarmThreshold("api-5xx-rate", 1); got != 0.01 {
+					t.Errorf("AlarmThreshold = %v, want 0.01", got)
+				}
+				if got := f.Settings("Dev").Rollout.StrategyOrDefault(); got != agcdkutil.RolloutAllAtOnce {
+					t.Errorf("default strategy = %q, want %q", got, agcdkutil.RolloutAllAtOnce)
+				}
+			},
+		},
+		{
+			name: "stack budgets",
+			content: `shared_budget: {max_resources: 300}
+deployments:
+  Prod:
+    budget: {max_template_bytes: 500000, max_outputs: 50}
+`,
+			checkResult: func(t *testing.T, f agcdkutil.DeploymentsFile) {
+				t.Helper()
+				want := agcdkutil.StackBudget{MaxResources: 300, MaxTemplateBytes: 1_000_000, MaxOutputs: 200}
+				if got := f.Budget(""); got != want {
+					t.Errorf("shared budget = %+v, want %+v", got, want)
+				}
+				want = agcdkutil.StackBudget{MaxResources: 500, MaxTemplateBytes: 500_000, MaxOutputs: 50}
+				if got := f.Budget("Prod"); got != want {
+					t.Errorf("Prod budget = %+v, want %+v", got, want)
+				}
+			},
+		},
+		{
+			name:    "budget above the CloudFormation limit",
+			content: "shared_budget: {max_resources: 600}\ndeployments: {}\n",
+			wantErr: []string{"SharedBudget.MaxResources failed validation max"},
+		},
+		{
+			name:    "unknown deployment",
+			content: "deployments:\n  Prd: {}\n",
+			wantErr: []string{"unknown deployment Prd"},
+		},
+		{
+			name:    "unknown field",
+			content: "deployments:\n  Prod: {sizes: {}}\n",
+			wantErr: []string{"failed to parse"},
+		},
+		{
+			name: "invalid values",
+			content: `deployments:
+  Prod:
+    sizing: {min_capacity: 4, max_capacity: 2}
+    deploy_windows: [{days: [monday], start: "9am", end: "16:00", timezone: Mars/Base}]
+    rollout: {strategy: linear}
+`,
+			wantErr: []string{
+				"Deployments[Prod].Sizing.MaxCapacity failed validation gtefield",
+				"Deployments[Prod].DeployWindows[0].Days[0] must be one of",
+				"Deployments[Prod].DeployWindows[0].Start must be a time of day",
+				"Deployments[Prod].DeployWindows[0].Timezone must be an IANA time zone",
+				"Deployments[Prod].Rollout.Percent is required for this rollout strategy",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			path := filepath.Join(t.TempDir(), "deployments.yaml")
+			if err := os.WriteFile(path, []byte(tt.content), 0o600); err != nil {
+				t.Fatal(err)
+			}
+
+			f, err := agcdkutil.LoadDeploymentsFile(path, []string{"Dev", "Prod"})
+			if len(tt.wantErr) > 0 {
+				if err == nil {
+					t.Fatal("expected error, got nil")
+				}
+				for _, want := range tt.wantErr {
+					if !strings.Contains(err.Error(), want) {
+						t.Errorf("error %q does not contain %q", err, want)
+					}
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			tt.checkResult(t, f)
+		})
+	}
+}
+
+func TestLoadDeploymentsFileMissing(t *testing.T) {
+	t.Parallel()
+
+	f, err := agcdkutil.LoadDeploymentsFile(filepath.Join(t.TempDir(), "deployments.yaml"), []string{"Dev"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(f.Deployments) != 0 {
+		t.Errorf("expected no settings, got %v", f.Deployments)
+	}
+}
+
+func TestInDeployWindow(t *testing.T) {
+	t.Parallel()
+
+	weekdays := agcdkutil.DeploymentSettings{DeployWindows: []agcdkutil.DeployWindow{
+		{Days: []string{"mon", "tue", "wed", "thu", "fri"}, Start: "09:00", End: "17:00", Timezone: "Europe/Amsterdam"},
+	}}
+	nightly := agcdkutil.DeploymentSettings{DeployWindows: []agcdkutil.DeployWindow{
+		{Days: []string{"fri"}, Start: "22:00", End: "02:00"},
+	}}
+
+	tests := []struct {
+		name     string
+		settings agcdkutil.DeploymentSettings
+		at       string
+		want     bool
+	}{
+		{name: "no windows", settings: agcdkutil.DeploymentSettings{}, at: "2026-10-18T03:00:00Z", want: true},
+		{name: "inside weekday window", settings: weekdays, at: "2026-10-16T10:00:00Z", want: true},
+		{name: "window end is exclusive", settings: weekdays, at: "2026-10-16T15:00:00Z", want: false},
+		{name: "before weekday window in local time", settings: weekdays, at: "2026-10-16T06:30:00Z", want: false},
+		{name: "weekend", settings: weekdays, at: "2026-10-17T10:00:00Z", want: false},
+		{name: "overnight before midnight", settings: nightly, at: "2026-10-16T23:00:00Z", want: true},
+		{name: "overnight after midnight", settings: nightly, at: "2026-10-17T01:00:00Z", want: true},
+		{name: "overnight on the wrong day", settings: nightly, at: "2026-10-18T01:00:00Z", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			at, err := time.Parse(time.RFC3339, tt.at)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := tt.settings.InDeployWindow(at); got != tt.want {
+				t.Errorf("InDeployWindow(%s) = %v, want %v", tt.at, got, tt.want)
+			}
+		})
+	}
+}
@@ -36,6 +36,13 @@
 //	  "myapp-deployer-groups": "myapp-deployers"
 //	}
 //
+// # Deployment Settings
+//
+// Per-deployment knobs (sizing, feature flags, alarm thresholds, deploy windows and rollout
+// strategy) live in infra/deployments.yaml rather than in context keys. [SetupApp] loads and
+// validates the file, see [DeploymentsFile]; constructs read it with [DeploymentSettingsFor] and
+// [FeatureEnabled]. 'ago infra cdk deploy' refuses to deploy outside a deployment's deploy windows.
+//
 // # Stack Creation Order
 //
 // [SetupApp] creates stacks with the following dependency order:
@@ -44,11 +51,37 @@
 //  3. Primary deployment stacks (depend on primary shared)
 //  4. Secondary deployment stacks (depend on primary deployment)
 //
+// # Tenants
+//
+// SaaS apps that give each customer isolated infrastructure use [SetupMultiTenantApp], which adds
+// a deployment for every tenant in the tenants context key ("myapp-tenants": ["acme-corp"]). The
+// deployment of tenant acme-corp is TenantAcmeCorp, which only full deployers may deploy. The
+// tenant and tenant-page context keys limit a synth to one tenant or to a page of them:
+//
+//	ago infra cdk deploy TenantAcmeCorp
+//	cdk synth -c myapp-tenant-page=2
+//
+// # Sharing Values
+//
+// The shared stack of a region passes values to the deployment stacks of that region with
+// [Share] and [Use], which export and import them under a [ShareKey] with a fixed type:
+//
+//	var VpcID = agcdkutil.NewShareKey[*string]("VpcId")
+//
+//	agcdkutil.Share(sharedStack, VpcID, vpc.VpcId())
+//	vpcID := agcdkutil.Use(deploymentStack, VpcID)
+//
 // # Features
 //
 //   - [SetupApp]: Multi-region, multi-deployment app orchestration
+//   - [SetupMultiTenantApp]: An isolated deployment per tenant, synthesized in pages
 //   - [NewStack]: Stack creation with qualifier and region naming
 //   - [ReproducibleGoBundling]: Lambda bundling for identical builds
 //   - [AllowedDeployments]: Role-based deployment authorization
-//   - [PreserveExport]: CloudFormation export preservation
+//   - [PreserveExport], [PreserveExports]: CloudFormation export preservation
+//   - [Share], [Use]: Typed values shared by shared stacks with the deployment stacks of a region
+//   - [Replicate], [ReplicatedValue]: Primary-region values copied to SSM in every region
+//   - [Output]: Stack outputs recorded in a per-stack SSM registry for discovery by the CLI
+//   - [DeploymentSettingsFor]: Per-deployment settings from infra/deployments.yaml
+//   - [Protect]: Guard stateful resources against replacement or deletion by 'ago infra cdk deploy'
 package agcdkutil
@@ -1,11 +1,23 @@
 package agcdkutil
 
 import (
+	"maps"
+	"regexp"
+	"slices"
+
 	"github.com/aws/aws-cdk-go/awscdk/v2"
 	"github.com/aws/constructs-go/constructs/v10"
 	"github.com/aws/jsii-runtime-go"
 )
 
+// PreservedExportsContextKey is the context key, after the prefix, under which 'ago infra cdk
+// deploy' records the exports that [PreserveExports] keeps alive.
+const PreservedExportsContextKey = "preserved-exports"
+
+// PreservedExportIDPrefix starts the construct ID of every output that [PreserveExports] adds,
+// which tells them apart from the outputs of the app.
+const PreservedExportIDPrefix = "PreservedExport"
+
 // PreserveExport creates an explicit CfnOutput with a specific export name to maintain
 // backward compatibility when cross-stack references are removed. This prevents
 // CloudFormation from failing with "Cannot delete export X as it is in use" errors.
@@ -19,3 +31,37 @@ func PreserveExport(scope constructs.Construct, id string, exportName string, va
 		ExportName: jsii.String(exportName),
 	})
 }
+
+// PreserveExports returns an aspect that does what [PreserveExport] does, for the exports that
+// 'ago infra cdk deploy' recorded in Config.PreservedExports. Before deploying, the CLI looks for
+// exports that the update of a stack would delete while another stack still imports them. It
+// records their deployed values in the context, and this aspect adds them back to the stack as
+// outputs with a literal value, so the deploy no longer fails half-way. Once no stack imports
+// an export anymore, the CLI drops it from the context and the next deploy deletes it.
+//
+// [SetupApp] adds the aspect to the app. Apps that create their stacks themselves add it with
+// awscdk.Aspects_Of(app).Add(agcdkutil.PreserveExports(), nil) after calling [StoreConfig].
+func PreserveExports() awscdk.IAspect {
+	return &preserveExports{}
+}
+
+// preserveExports is returned as a pointer: jsii only passes pointers to Go implementations of
+// its interfaces on to the CDK.
+type preserveExports struct {
+	_ byte
+}
+
+var nonAlphanumeric = regexp.MustCompile(`[^A-Za-z0-9]`)
+
+func (*preserveExports) Visit(node constructs.IConstruct) {
+	if !*awscdk.Stack_IsStack(node) {
+		return
+	}
+
+	stack := awscdk.Stack_Of(node)
+	exports := ConfigFromScope(stack).PreservedExports[*stack.StackName()]
+	for _, name := range slices.Sorted(maps.Keys(exports)) {
+		id := PreservedExportIDPrefix + nonAlphanumeric.ReplaceAllString(name, "")
+		PreserveExport(stack, id, name, jsii.String(exports[name]))
+	}
+}
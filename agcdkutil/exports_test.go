@@ -0,0 +1,64 @@
+//nolint:paralleltest // jsii runtime doesn't support parallel tests
+package agcdkutil_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/advdv/ago/agcdkutil"
+	"github.com/aws/aws-cdk-go/awscdk/v2"
+	"github.com/aws/jsii-runtime-go"
+)
+
+func TestPreserveExports(t *testing.T) {
+	t.Setenv("CDK_DEFAULT_ACCOUNT", "123456789012")
+	defer jsii.Close()
+
+	ctx := map[string]any{
+		"myapp-qualifier":         "myapp",
+		"myapp-primary-region":    "us-east-1",
+		"myapp-secondary-regions": []any{},
+		"myapp-deployments":       []any{"Dev"},
+		"myapp-deployer-groups":   "myapp-deployers",
+		"myapp-base-domain-name":  "example.com",
+		"myapp-preserved-exports": map[string]any{
+			"myappUse1Shared": map[string]any{"myappUse1Shared:ExportsOutputRefVpc8378EB38": "vpc-123"},
+		},
+	}
+
+	app := awscdk.NewApp(&awscdk.AppProps{Context: &ctx})
+	agcdkutil.SetupApp(app, agcdkutil.AppConfig{Prefix: "myapp-", DeployersGroup: "myapp-deployers"},
+		func(stack awscdk.Stack) awscdk.Stack { return stack },
+		func(awscdk.Stack, awscdk.Stack, string) {})
+
+	assembly := app.Synth(nil)
+
+	outputs := func(stackName string) map[string]any {
+		template, _ := assembly.GetStackByName(jsii.String(stackName)).Template().(map[string]any)
+		outputs, _ := template["Outputs"].(map[string]any)
+		return outputs
+	}
+
+	var preserved []map[string]any
+	for id, output := range outputs("myappUse1Shared") {
+		if strings.HasPrefix(id, agcdkutil.PreservedExportIDPrefix) {
+			preserved = append(preserved, output.(map[string]any))
+		}
+	}
+	if len(preserved) != 1 {
+		t.Fatalf("expected 1 preserved export, got %v", preserved)
+	}
+	if got := preserved[0]["Value"]; got != "vpc-123" {
+		t.Errorf("value = %v, want vpc-123", got)
+	}
+	export, _ := preserved[0]["Export"].(map[string]any)
+	if got := export["Name"]; got != "myappUse1Shared:ExportsOutputRefVpc8378EB38" {
+		t.Errorf("export name = %v", got)
+	}
+
+	for id := range outputs("myappUse1Dev") {
+		if strings.HasPrefix(id, agcdkutil.PreservedExportIDPrefix) {
+			t.Errorf("unexpected preserved export %s in the deployment stack", id)
+		}
+	}
+}
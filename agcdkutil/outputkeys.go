@@ -0,0 +1,107 @@
+package agcdkutil
+
+import "slices"
+
+// Output keys with a fixed name. The constructs and templates that create the outputs and the ago
+// CLI that reads them both use these constants, so that a renamed output cannot go unnoticed.
+// Outputs that are named after a construct, such as the URL of a queue, are named by a function
+// of the construct's package instead.
+const (
+	// NameServersOutputKey holds the comma-separated name servers of the hosted zone, created by
+	// agcdkdns in the primary shared stack.
+	NameServersOutputKey = "HostedZoneNameServers"
+	// RepositoryURIOutputKey holds the URI of the main ECR repository, created by agcdkrepos in
+	// the primary shared stack.
+	RepositoryURIOutputKey = "RepositoryURI"
+	// EventBusNameOutputKey holds the name of the event bus created by agcdkevents.
+	EventBusNameOutputKey = "EventBusName"
+	// EventArchiveNameOutputKey holds the name of the event archive created by agcdkevents.
+	EventArchiveNameOutputKey = "EventArchiveName"
+	// JobsScheduleGroupOutputKey holds the schedule group of the jobs created by agcdkjobs.
+	JobsScheduleGroupOutputKey = "JobsScheduleGroup"
+
+	// ExecutionPolicyARNOutputKey holds the ARN of the CDK execution policy, created by the
+	// pre-bootstrap stack.
+	ExecutionPolicyARNOutputKey = "ExecutionPolicyArn"
+	// PermissionsBoundaryNameOutputKey holds the name of the permissions boundary, created by the
+	// pre-bootstrap stack.
+	PermissionsBoundaryNameOutputKey = "PermissionsBoundaryName"
+	// DeploymentScopePolicyARNOutputKey holds the ARN of the policy that limits deploy role
+	// sessions to their deployment, created by the pre-bootstrap stack.
+	DeploymentScopePolicyARNOutputKey = "DeploymentScopePolicyArn"
+	// ProvenanceBucketNameOutputKey holds the name of the bucket of the deploy provenance
+	// records, created by the pre-bootstrap stack.
+	ProvenanceBucketNameOutputKey = "ProvenanceBucketName"
+
+	// BootstrapVersionOutputKey holds the version of the CDK toolkit stack.
+	BootstrapVersionOutputKey = "BootstrapVersion"
+
+	// AccountIDOutputKey holds the ID of the account created by an account stack in the
+	// management account.
+	AccountIDOutputKey = "AccountId"
+	// ManagementRoleARNOutputKey holds the ARN of the role that the management stack creates for
+	// the project.
+	ManagementRoleARNOutputKey = "ManagementRoleArn"
+)
+
+// StackKind is a kind of stack whose outputs the ago CLI relies on.
+type StackKind string
+
+// Kinds of stacks with expected outputs.
+const (
+	// StackKindPreBootstrap is the stack that 'ago infra cdk bootstrap' deploys before the CDK
+	// toolkit stack.
+	StackKindPreBootstrap StackKind = "pre-bootstrap"
+	// StackKindToolkit is the CDK toolkit stack of the qualifier.
+	StackKindToolkit StackKind = "toolkit"
+	// StackKindShared is a shared stack, created by SetupApp in every region.
+	StackKindShared StackKind = "shared"
+)
+
+// ExpectedOutput is an output that the stacks of a kind expose.
+type ExpectedOutput struct {
+	// Key is the output key.
+	Key string
+	// PrimaryRegionOnly is set when only the stack in the primary region exposes the output.
+	PrimaryRegionOnly bool
+	// Producer is what creates the output, such as "agcdkdns".
+	Producer string
+}
+
+// expectedOutputs lists the outputs that the ago CLI reads, by the kind of stack it reads them
+// from. Outputs of constructs that an app may leave out, such as agcdkevents, are not listed.
+var expectedOutputs = map[StackKind][]ExpectedOutput{
+	StackKindPreBootstrap: {
+		{Key: ExecutionPolicyARNOutputKey, Producer: "pre-bootstrap template"},
+		{Key: PermissionsBoundaryNameOutputKey, Producer: "pre-bootstrap template"},
+		{Key: DeploymentScopePolicyARNOutputKey, Producer: "pre-bootstrap template"},
+		{Key: ProvenanceBucketNameOutputKey, Producer: "pre-bootstrap template"},
+	},
+	StackKindToolkit: {
+		{Key: BootstrapVersionOutputKey, Producer: "cdk bootstrap"},
+	},
+	StackKindShared: {
+		{Key: NameServersOutputKey, PrimaryRegionOnly: true, Producer: "agcdkdns"},
+		{Key: RepositoryURIOutputKey, PrimaryRegionOnly: true, Producer: "agcdkrepos"},
+	},
+}
+
+// ExpectedOutputs returns the outputs that the stacks of a kind expose.
+func ExpectedOutputs(kind StackKind) []ExpectedOutput {
+	return slices.Clone(expectedOutputs[kind])
+}
+
+// MissingOutputs returns the expected outputs of a stack of the kind that are not among its
+// outputs, in the order they are listed in.
+func MissingOutputs(kind StackKind, primaryRegion bool, outputs map[string]string) []ExpectedOutput {
+	var missing []ExpectedOutput
+	for _, expected := range expectedOutputs[kind] {
+		if expected.PrimaryRegionOnly && !primaryRegion {
+			continue
+		}
+		if _, ok := outputs[expected.Key]; !ok {
+			missing = append(missing, expected)
+		}
+	}
+	return missing
+}
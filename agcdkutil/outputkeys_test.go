@@ -0,0 +1,36 @@
+package agcdkutil_test
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/advdv/ago/agcdkutil"
+)
+
+func TestMissingOutputs(t *testing.T) {
+	t.Parallel()
+
+	keys := func(outputs []agcdkutil.ExpectedOutput) []string {
+		var result []string
+		for _, o := range outputs {
+			result = append(result, o.Key)
+		}
+		return result
+	}
+
+	shared := map[string]string{agcdkutil.RepositoryURIOutputKey: "repo"}
+	if got := keys(agcdkutil.MissingOutputs(agcdkutil.StackKindShared, true, shared)); !slices.Equal(got,
+		[]string{agcdkutil.NameServersOutputKey}) {
+		t.Errorf("unexpected missing outputs of the primary shared stack: %v", got)
+	}
+	if got := agcdkutil.MissingOutputs(agcdkutil.StackKindShared, false, nil); len(got) != 0 {
+		t.Errorf("expected no missing outputs of a secondary shared stack, got %v", keys(got))
+	}
+	if got := agcdkutil.MissingOutputs(agcdkutil.StackKindToolkit, false,
+		map[string]string{agcdkutil.BootstrapVersionOutputKey: "28"}); len(got) != 0 {
+		t.Errorf("expected no missing outputs of the toolkit stack, got %v", keys(got))
+	}
+	if got := agcdkutil.MissingOutputs("unknown", true, nil); len(got) != 0 {
+		t.Errorf("expected no expected outputs of an unknown kind, got %v", keys(got))
+	}
+}
@@ -0,0 +1,101 @@
+package agcdkutil
+
+import (
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-cdk-go/awscdk/v2"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awsssm"
+	"github.com/aws/jsii-runtime-go"
+)
+
+// OutputRegistryParameterName returns the name of the SSM parameter that holds the outputs
+// recorded with [Output] for a stack, as a JSON object of output key to value.
+func OutputRegistryParameterName(stackName string) string {
+	return "/ago/outputs/" + stackName
+}
+
+// OutputOptions configures an output created with [Output].
+type OutputOptions struct {
+	// Description of the output.
+	Description string
+	// ExportName makes the output available to other stacks through Fn::ImportValue.
+	ExportName string
+}
+
+const outputRegistryID = "OutputRegistry"
+
+// outputRegistry tracks the outputs of a single stack and the parameter they are written to.
+type outputRegistry struct {
+	param  awsssm.CfnParameter
+	values map[string]*string
+}
+
+var (
+	outputRegistriesMu sync.Mutex
+	outputRegistries   = map[string]*outputRegistry{}
+)
+
+// Output creates a CfnOutput on the stack and records it in the stack's output registry: an SSM
+// parameter named [OutputRegistryParameterName] that holds all outputs as a JSON object. The CLI
+// reads this parameter to discover outputs, so prefer Output over creating CfnOutputs directly.
+func Output(stack awscdk.Stack, key string, value *string, opts OutputOptions) awscdk.CfnOutput {
+	props := &awscdk.CfnOutputProps{Value: value}
+	if opts.Description != "" {
+		props.Description = jsii.String(opts.Description)
+	}
+	if opts.ExportName != "" {
+		props.ExportName = jsii.String(opts.ExportName)
+	}
+	output := awscdk.NewCfnOutput(stack, jsii.String(key), props)
+
+	outputRegistriesMu.Lock()
+	defer outputRegistriesMu.Unlock()
+
+	reg := outputRegistryFor(stack)
+	reg.values[key] = value
+	reg.param.SetValue(outputRegistryJSON(stack, reg.values))
+
+	return output
+}
+
+// outputRegistryFor returns the registry of the stack, creating its parameter on first use. The
+// registry is keyed by the stack's address; a stack without the parameter child is always given
+// a fresh registry so that registries of earlier apps are never reused.
+func outputRegistryFor(stack awscdk.Stack) *outputRegistry {
+	addr := *stack.Node().Addr()
+	if reg, ok := outputRegistries[addr]; ok && stack.Node().TryFindChild(jsii.String(outputRegistryID)) != nil {
+		return reg
+	}
+
+	reg := &outputRegistry{
+		param: awsssm.NewCfnParameter(stack, jsii.String(outputRegistryID), &awsssm.CfnParameterProps{
+			Name:        jsii.String(OutputRegistryParameterName(*stack.StackName())),
+			Type:        jsii.String("String"),
+			Value:       jsii.String("{}"),
+			Description: jsii.String("Outputs of " + *stack.StackName() + ", recorded by agcdkutil.Output"),
+		}),
+		values: map[string]*string{},
+	}
+	outputRegistries[addr] = reg
+
+	return reg
+}
+
+// outputRegistryJSON renders the values as a JSON object with sorted keys, so that the
+// synthesized template is deterministic. Values may be tokens.
+func outputRegistryJSON(stack awscdk.Stack, values map[string]*string) *string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+
+	entries := make([]string, 0, len(keys))
+	for _, key := range keys {
+		entries = append(entries, *stack.ToJsonString(key, nil)+":"+*stack.ToJsonString(values[key], nil))
+	}
+
+	return jsii.String("{" + strings.Join(entries, ",") + "}")
+}
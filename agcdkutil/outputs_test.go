@@ -0,0 +1,53 @@
+//nolint:paralleltest // jsii runtime doesn't support parallel tests
+package agcdkutil_test
+
+import (
+	"testing"
+
+	"github.com/advdv/ago/agcdkutil"
+	"github.com/aws/aws-cdk-go/awscdk/v2"
+	"github.com/aws/jsii-runtime-go"
+)
+
+func TestOutput(t *testing.T) {
+	defer jsii.Close()
+
+	app := awscdk.NewApp(nil)
+	stack := awscdk.NewStack(app, jsii.String("myappUse1Dev"), nil)
+
+	agcdkutil.Output(stack, "ServiceURL", jsii.String("https://dev.example.com"),
+		agcdkutil.OutputOptions{Description: "URL of the service"})
+	agcdkutil.Output(stack, "BucketName", jsii.String("my-bucket"), agcdkutil.OutputOptions{})
+
+	template, ok := app.Synth(nil).GetStackByName(jsii.String("myappUse1Dev")).Template().(map[string]any)
+	if !ok {
+		t.Fatal("expected template to be a map")
+	}
+
+	outputs, _ := template["Outputs"].(map[string]any)
+	for _, key := range []string{"ServiceURL", "BucketName"} {
+		if _, ok := outputs[key]; !ok {
+			t.Errorf("expected output %q, got %v", key, outputs)
+		}
+	}
+
+	resources, _ := template["Resources"].(map[string]any)
+	var params []map[string]any
+	for _, res := range resources {
+		r, _ := res.(map[string]any)
+		if r["Type"] == "AWS::SSM::Parameter" {
+			props, _ := r["Properties"].(map[string]any)
+			params = append(params, props)
+		}
+	}
+	if len(params) != 1 {
+		t.Fatalf("expected 1 registry parameter, got %d", len(params))
+	}
+
+	if got, want := params[0]["Name"], agcdkutil.OutputRegistryParameterName("myappUse1Dev"); got != want {
+		t.Errorf("parameter name = %v, want %q", got, want)
+	}
+	if got, want := params[0]["Value"], `{"BucketName":"my-bucket","ServiceURL":"https://dev.example.com"}`; got != want {
+		t.Errorf("parameter value = %v, want %s", got, want)
+	}
+}
@@ -0,0 +1,30 @@
+package agcdkutil
+
+import (
+	"github.com/aws/aws-cdk-go/awscdk/v2"
+	"github.com/aws/constructs-go/constructs/v10"
+	"github.com/aws/jsii-runtime-go"
+)
+
+// ProtectedMetadataKey is the template metadata key that marks a resource as protected. Its value
+// is the reason given to [Protect].
+const ProtectedMetadataKey = "ago:protected"
+
+// Protect marks a resource as protected: 'ago infra cdk deploy' refuses to deploy a change set
+// that replaces or deletes it unless --allow-protected is given. Use it for stateful resources
+// such as tables, buckets and user pools, where stack termination protection is too coarse.
+//
+// The construct may be a CfnResource or a higher-level construct whose default child is one.
+// The protection is recorded in the resource's template metadata, so it applies to the logical ID
+// the resource synthesizes to.
+func Protect(construct constructs.IConstruct, reason string) {
+	resource, ok := construct.(awscdk.CfnResource)
+	if !ok {
+		resource, ok = construct.Node().DefaultChild().(awscdk.CfnResource)
+	}
+	if !ok {
+		panic("agcdkutil.Protect: " + *construct.Node().Path() + " is not a CfnResource and has no CfnResource default child")
+	}
+
+	resource.AddMetadata(jsii.String(ProtectedMetadataKey), reason)
+}
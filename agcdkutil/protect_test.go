@@ -0,0 +1,61 @@
+//nolint:paralleltest // jsii runtime doesn't support parallel tests
+package agcdkutil_test
+
+import (
+	"testing"
+
+	"github.com/advdv/ago/agcdkutil"
+	"github.com/aws/aws-cdk-go/awscdk/v2"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awss3"
+	"github.com/aws/jsii-runtime-go"
+)
+
+func TestProtect(t *testing.T) {
+	defer jsii.Close()
+
+	app := awscdk.NewApp(nil)
+	stack := awscdk.NewStack(app, jsii.String("myappUse1Prod"), nil)
+
+	bucket := awss3.NewBucket(stack, jsii.String("Data"), nil)
+	agcdkutil.Protect(bucket, "holds customer uploads")
+	awss3.NewBucket(stack, jsii.String("Scratch"), nil)
+
+	template, ok := app.Synth(nil).GetStackByName(jsii.String("myappUse1Prod")).Template().(map[string]any)
+	if !ok {
+		t.Fatal("expected template to be a map")
+	}
+
+	logicalID := *stack.GetLogicalId(bucket.Node().DefaultChild().(awscdk.CfnElement))
+
+	resources, _ := template["Resources"].(map[string]any)
+	protected := map[string]any{}
+	for id, res := range resources {
+		r, _ := res.(map[string]any)
+		metadata, _ := r["Metadata"].(map[string]any)
+		if reason, ok := metadata[agcdkutil.ProtectedMetadataKey]; ok {
+			protected[id] = reason
+		}
+	}
+
+	if len(protected) != 1 {
+		t.Fatalf("expected 1 protected resource, got %v", protected)
+	}
+	if got := protected[logicalID]; got != "holds customer uploads" {
+		t.Errorf("reason of %s = %v, want %q", logicalID, got, "holds customer uploads")
+	}
+}
+
+func TestProtectPanicsWithoutResource(t *testing.T) {
+	defer jsii.Close()
+
+	app := awscdk.NewApp(nil)
+	stack := awscdk.NewStack(app, jsii.String("myappUse1Prod"), nil)
+
+	defer func() {
+		if recover() == nil {
+			t.Error("expected panic")
+		}
+	}()
+
+	agcdkutil.Protect(stack, "not a resource")
+}
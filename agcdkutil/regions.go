@@ -84,3 +84,59 @@ func AllKnownRegions() []string {
 	slices.Sort(regions)
 	return regions
 }
+
+// Partition names as they appear in the second field of an ARN.
+const (
+	PartitionAWS      = "aws"
+	PartitionAWSUsGov = "aws-us-gov"
+	PartitionAWSCN    = "aws-cn"
+	PartitionAWSEUSC  = "aws-eusc"
+)
+
+// PartitionFor returns the AWS partition a region belongs to.
+func PartitionFor(region string) string {
+	switch {
+	case strings.HasPrefix(region, "us-gov-"):
+		return PartitionAWSUsGov
+	case strings.HasPrefix(region, "cn-"):
+		return PartitionAWSCN
+	case strings.HasPrefix(region, "eusc-"):
+		return PartitionAWSEUSC
+	default:
+		return PartitionAWS
+	}
+}
+
+// PartitionDNSSuffix returns the DNS suffix of service endpoints in a partition,
+// e.g. "amazonaws.com" or "amazonaws.com.cn".
+func PartitionDNSSuffix(partition string) string {
+	switch partition {
+	case PartitionAWSCN:
+		return "amazonaws.com.cn"
+	case PartitionAWSEUSC:
+		return "amazonaws.eu"
+	default:
+		return "amazonaws.com"
+	}
+}
+
+// PartitionGlobalRegion returns the region that hosts global services (IAM, Route 53,
+// Service Quotas for global services) in a partition.
+func PartitionGlobalRegion(partition string) string {
+	switch partition {
+	case PartitionAWSUsGov:
+		return "us-gov-west-1"
+	case PartitionAWSCN:
+		return "cn-northwest-1"
+	case PartitionAWSEUSC:
+		return "eusc-de-east-1"
+	default:
+		return "us-east-1"
+	}
+}
+
+// ARN formats an ARN in the given partition. Region and account may be empty for global
+// resources, e.g. ARN("aws", "iam", "", "123456789012", "role/Admin").
+func ARN(partition, service, region, account, resource string) string {
+	return "arn:" + partition + ":" + service + ":" + region + ":" + account + ":" + resource
+}
@@ -0,0 +1,96 @@
+package agcdkutil
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/aws/aws-cdk-go/awscdk/v2"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awsssm"
+	"github.com/aws/aws-cdk-go/awscdk/v2/customresources"
+	"github.com/aws/constructs-go/constructs/v10"
+	"github.com/aws/jsii-runtime-go"
+	"github.com/iancoleman/strcase"
+)
+
+var replicatedNameRegex = regexp.MustCompile(`^[a-z][a-z0-9-]*$`)
+
+// ReplicatedParameterName returns the name of the SSM parameter that holds a value replicated
+// with [Replicate]. The name is the same in every region.
+func ReplicatedParameterName(qualifier, name string) string {
+	return "/ago/" + qualifier + "/replicated/" + name
+}
+
+// Replicate writes value to an SSM parameter named [ReplicatedParameterName] in the primary region
+// and copies it to the same parameter in every secondary region, so that stacks in any region can
+// read values that only exist in the primary region, such as a hosted zone ID, a certificate ARN
+// or a repository URI, with [ReplicatedValue]. The name must be lowercase with dashes, such as
+// "hosted-zone-id".
+//
+// Stack must be the primary shared stack, or a sub-stack of it. [SetupApp] deploys it before the
+// stacks of the secondary regions, so the copies exist when those stacks read them. A copy is
+// updated when the value changes and deleted with the stack.
+func Replicate(stack awscdk.Stack, name string, value *string) {
+	if !replicatedNameRegex.MatchString(name) {
+		panic("replicated value name must be lowercase letters, digits and dashes, got: " + name)
+	}
+
+	cfg := ConfigFromScope(stack)
+	if DeploymentOf(stack) != SharedDeploymentTag || !cfg.IsPrimaryRegionStack(rootStackOf(stack)) {
+		panic(fmt.Sprintf("agcdkutil.Replicate(%s): %s is not the primary shared stack", name, *stack.Node().Path()))
+	}
+
+	paramName := ReplicatedParameterName(cfg.Qualifier, name)
+	id := "Replicated" + strcase.ToCamel(name)
+	awsssm.NewStringParameter(stack, jsii.String(id), &awsssm.StringParameterProps{
+		ParameterName: jsii.String(paramName),
+		StringValue:   value,
+		Description:   jsii.String("Replicated to the secondary regions by agcdkutil.Replicate"),
+	})
+
+	for _, region := range cfg.SecondaryRegions {
+		paramArn := stack.FormatArn(&awscdk.ArnComponents{
+			Service:      jsii.String("ssm"),
+			Region:       jsii.String(region),
+			Resource:     jsii.String("parameter"),
+			ResourceName: jsii.String(strings.TrimPrefix(paramName, "/")),
+		})
+		put := &customresources.AwsSdkCall{
+			Service: jsii.String("SSM"),
+			Action:  jsii.String("putParameter"),
+			Parameters: map[string]any{
+				"Name":      paramName,
+				"Value":     value,
+				"Type":      "String",
+				"Overwrite": true,
+			},
+			Region:             jsii.String(region),
+			PhysicalResourceId: customresources.PhysicalResourceId_Of(jsii.String(paramName + "@" + region)),
+		}
+
+		customresources.NewAwsCustomResource(stack, jsii.String(id+cfg.RegionIdent(region)),
+			&customresources.AwsCustomResourceProps{
+				OnCreate: put,
+				OnUpdate: put,
+				OnDelete: &customresources.AwsSdkCall{
+					Service:    jsii.String("SSM"),
+					Action:     jsii.String("deleteParameter"),
+					Parameters: map[string]any{"Name": paramName},
+					Region:     jsii.String(region),
+				},
+				Policy: customresources.AwsCustomResourcePolicy_FromSdkCalls(&customresources.SdkCallsPolicyOptions{
+					Resources: &[]*string{paramArn},
+				}),
+				InstallLatestAwsSdk: jsii.Bool(false),
+			})
+	}
+}
+
+// ReplicatedValue returns the value that [Replicate] wrote under name, read from the SSM parameter
+// in the region of scope. It works in every stack of the app, in the primary region as well as the
+// secondary ones. The value is resolved when the stack deploys, so a stack picks up a changed
+// value on its next deploy.
+func ReplicatedValue(scope constructs.Construct, name string) *string {
+	return awsssm.StringParameter_ValueForStringParameter(scope,
+		jsii.String(ReplicatedParameterName(ConfigFromScope(scope).Qualifier, name)), nil)
+}
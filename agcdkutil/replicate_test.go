@@ -0,0 +1,85 @@
+//nolint:paralleltest // jsii runtime doesn't support parallel tests
+package agcdkutil_test
+
+import (
+	"testing"
+
+	"github.com/advdv/ago/agcdkutil"
+	"github.com/aws/aws-cdk-go/awscdk/v2"
+	"github.com/aws/jsii-runtime-go"
+)
+
+func TestReplicate(t *testing.T) {
+	defer jsii.Close()
+	t.Setenv("CDK_DEFAULT_ACCOUNT", "123456789012")
+
+	app := newShareTestApp()
+	agcdkutil.SetupApp(app, agcdkutil.AppConfig{Prefix: "myapp-", DeployersGroup: "myapp-deployers"},
+		func(stack awscdk.Stack) awscdk.Stack {
+			if agcdkutil.IsPrimaryRegionStack(stack, stack) {
+				agcdkutil.Replicate(stack, "hosted-zone-id", jsii.String("Z123"))
+			}
+			return stack
+		},
+		func(stack awscdk.Stack, _ awscdk.Stack, _ string) {
+			awscdk.NewCfnOutput(stack, jsii.String("HostedZoneId"), &awscdk.CfnOutputProps{
+				Value: agcdkutil.ReplicatedValue(stack, "hosted-zone-id"),
+			})
+		})
+
+	assembly := app.Synth(nil)
+	resources := func(stackName string) map[string]any {
+		template, _ := assembly.GetStackByName(jsii.String(stackName)).Template().(map[string]any)
+		resources, _ := template["Resources"].(map[string]any)
+		return resources
+	}
+
+	var params, copies int
+	for _, res := range resources("myappUse1Shared") {
+		r, _ := res.(map[string]any)
+		props, _ := r["Properties"].(map[string]any)
+		switch r["Type"] {
+		case "AWS::SSM::Parameter":
+			if props["Name"] == agcdkutil.ReplicatedParameterName("myapp", "hosted-zone-id") {
+				params++
+			}
+		case "Custom::AWS":
+			copies++
+		}
+	}
+	if params != 1 || copies != 1 {
+		t.Errorf("expected 1 parameter and 1 copy to the secondary region, got %d and %d", params, copies)
+	}
+
+	template, _ := assembly.GetStackByName(jsii.String("myappEuw1Dev")).Template().(map[string]any)
+	parameters, _ := template["Parameters"].(map[string]any)
+	var found bool
+	for _, param := range parameters {
+		p, _ := param.(map[string]any)
+		if p["Type"] == "AWS::SSM::Parameter::Value<String>" && p["Default"] == "/ago/myapp/replicated/hosted-zone-id" {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("expected the secondary deployment stack to read the replicated parameter, got %v", parameters)
+	}
+}
+
+func TestReplicatePanicsOutsidePrimarySharedStack(t *testing.T) {
+	defer jsii.Close()
+
+	app := newShareTestApp()
+
+	defer func() {
+		if recover() == nil {
+			t.Error("expected panic")
+		}
+	}()
+
+	agcdkutil.SetupApp(app, agcdkutil.AppConfig{Prefix: "myapp-", DeployersGroup: "myapp-deployers"},
+		func(stack awscdk.Stack) awscdk.Stack {
+			agcdkutil.Replicate(stack, "hosted-zone-id", jsii.String("Z123"))
+			return stack
+		},
+		func(awscdk.Stack, awscdk.Stack, string) {})
+}
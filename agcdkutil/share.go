@@ -0,0 +1,162 @@
+package agcdkutil
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+
+	"github.com/aws/aws-cdk-go/awscdk/v2"
+	"github.com/aws/jsii-runtime-go"
+)
+
+// Shareable are the types of values that shared stacks can share with deployment stacks: the
+// string, string list and number values that CDK constructs expose, which may be tokens.
+type Shareable interface {
+	*string | *[]*string | *float64
+}
+
+// ShareKey identifies a value of type T that the shared stack of a region shares with the
+// deployment stacks of that region. Declare keys once, next to the shared constructs, and pass
+// the same key to [Share] and [Use] so that both sides agree on the name and the type.
+type ShareKey[T Shareable] struct {
+	name string
+}
+
+// NewShareKey returns the key of a shared value. The name is part of the CloudFormation export
+// and must be PascalCase, such as "VpcId" or "ClusterArn".
+func NewShareKey[T Shareable](name string) ShareKey[T] {
+	if name == "" || name[0] < 'A' || name[0] > 'Z' {
+		panic("share key must start with an upper-case letter, got: " + name)
+	}
+	for _, c := range name {
+		if (c < 'a' || c > 'z') && (c < 'A' || c > 'Z') && (c < '0' || c > '9') {
+			panic("share key must only contain letters and digits, got: " + name)
+		}
+	}
+	return ShareKey[T]{name: name}
+}
+
+// Name returns the name of the key.
+func (k ShareKey[T]) Name() string {
+	return k.name
+}
+
+// ShareExportName returns the name of the CloudFormation export that holds a value shared with
+// [Share]. Exports are scoped to a region, so the name only has to be unique among the stacks of
+// one region and is the same in every region.
+func ShareExportName(qualifier, regionIdent, key string) string {
+	return SharedStackName(qualifier, regionIdent) + ":" + key
+}
+
+// shareListSeparator joins the elements of shared string lists in their export.
+const shareListSeparator = ","
+
+// sharedValue is a value that a shared stack shares.
+type sharedValue struct {
+	stack awscdk.Stack
+	typ   string
+}
+
+var (
+	sharedValuesMu sync.Mutex
+	// sharedValues holds the shared values of every app by app address and export name.
+	sharedValues = map[string]map[string]sharedValue{}
+)
+
+// Share makes value available to the deployment stacks of the stack's region under key. It adds
+// an [Output] named "Share" followed by the key to stack, with the export [ShareExportName], so
+// the value is also recorded in the output registry. Stack must be a shared stack, or a
+// sub-stack of one, and a key can only be shared once per region.
+func Share[T Shareable](stack awscdk.Stack, key ShareKey[T], value T) {
+	if deployment := DeploymentOf(stack); deployment != SharedDeploymentTag {
+		panic(fmt.Sprintf("agcdkutil.Share(%s): %s is not a shared stack", key.name, *stack.Node().Path()))
+	}
+
+	exportName := shareExportNameFor(stack, key.name)
+	sharedValuesMu.Lock()
+	defer sharedValuesMu.Unlock()
+
+	app := *stack.Node().Root().Node().Addr()
+	if sharedValues[app] == nil {
+		sharedValues[app] = map[string]sharedValue{}
+	}
+	if _, ok := sharedValues[app][exportName]; ok {
+		panic(fmt.Sprintf("agcdkutil.Share(%s): already shared in the region of %s", key.name, *stack.StackName()))
+	}
+	sharedValues[app][exportName] = sharedValue{stack: rootStackOf(stack), typ: fmt.Sprintf("%T", value)}
+
+	Output(stack, "Share"+key.name, encodeShared(value), OutputOptions{
+		Description: "Shared as " + key.name + " with the deployment stacks of the region",
+		ExportName:  exportName,
+	})
+}
+
+// Use returns the value that the shared stack of the stack's region shared under key, as an
+// import of its export. It makes the stack depend on the shared stack, and panics when the key
+// was not shared in the region or was shared with another type, so that mistakes surface at
+// synth time instead of at deploy time. Stack must be a deployment stack, or a sub-stack of one.
+func Use[T Shareable](stack awscdk.Stack, key ShareKey[T]) T {
+	deployment := DeploymentOf(stack)
+	if deployment == "" || deployment == SharedDeploymentTag {
+		panic(fmt.Sprintf("agcdkutil.Use(%s): %s is not a deployment stack", key.name, *stack.Node().Path()))
+	}
+
+	exportName := shareExportNameFor(stack, key.name)
+	sharedValuesMu.Lock()
+	shared, ok := sharedValues[*stack.Node().Root().Node().Addr()][exportName]
+	sharedValuesMu.Unlock()
+
+	var zero T
+	switch {
+	case !ok:
+		panic(fmt.Sprintf("agcdkutil.Use(%s): not shared in the region of %s, call agcdkutil.Share in the "+
+			"shared constructor", key.name, *stack.StackName()))
+	case shared.typ != fmt.Sprintf("%T", zero):
+		panic(fmt.Sprintf("agcdkutil.Use(%s): shared as %s, not %T", key.name, shared.typ, zero))
+	}
+
+	rootStackOf(stack).AddDependency(shared.stack, jsii.String("Uses "+key.name+" of the shared stack"))
+	return decodeShared[T](awscdk.Fn_ImportValue(jsii.String(exportName)))
+}
+
+// shareExportNameFor returns the export name of key in the region of stack.
+func shareExportNameFor(stack awscdk.Stack, key string) string {
+	cfg := ConfigFromScope(stack)
+	return ShareExportName(cfg.Qualifier, cfg.RegionIdent(*rootStackOf(stack).Region()), key)
+}
+
+// rootStackOf returns the top-level stack of a stack that may be a sub-stack.
+func rootStackOf(stack awscdk.Stack) awscdk.Stack {
+	for stack.NestedStackParent() != nil {
+		stack = stack.NestedStackParent()
+	}
+	return stack
+}
+
+// encodeShared renders a shared value as the string value of its export.
+func encodeShared[T Shareable](value T) *string {
+	switch v := any(value).(type) {
+	case *[]*string:
+		return awscdk.Fn_Join(jsii.String(shareListSeparator), v)
+	case *float64:
+		if *awscdk.Token_IsUnresolved(v) {
+			return awscdk.Token_AsString(v, nil)
+		}
+		return jsii.String(strconv.FormatFloat(*v, 'f', -1, 64))
+	default:
+		return any(value).(*string)
+	}
+}
+
+// decodeShared turns the imported string value of an export back into a value of type T.
+func decodeShared[T Shareable](imported *string) T {
+	var value T
+	switch any(value).(type) {
+	case *[]*string:
+		return any(awscdk.Fn_Split(jsii.String(shareListSeparator), imported, nil)).(T)
+	case *float64:
+		return any(awscdk.Token_AsNumber(imported)).(T)
+	default:
+		return any(imported).(T)
+	}
+}
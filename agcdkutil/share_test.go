@@ -0,0 +1,133 @@
+//nolint:paralleltest // jsii runtime doesn't support parallel tests
+package agcdkutil_test
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/advdv/ago/agcdkutil"
+	"github.com/aws/aws-cdk-go/awscdk/v2"
+	"github.com/aws/jsii-runtime-go"
+)
+
+var (
+	vpcIDKey = agcdkutil.NewShareKey[*string]("VpcId")
+	zonesKey = agcdkutil.NewShareKey[*[]*string]("Zones")
+	portKey  = agcdkutil.NewShareKey[*float64]("Port")
+)
+
+func newShareTestApp() awscdk.App {
+	ctx := map[string]any{
+		"myapp-qualifier":         "myapp",
+		"myapp-primary-region":    "us-east-1",
+		"myapp-secondary-regions": []any{"eu-west-1"},
+		"myapp-deployments":       []any{"Dev"},
+		"myapp-deployer-groups":   "myapp-deployers",
+		"myapp-base-domain-name":  "example.com",
+	}
+	return awscdk.NewApp(&awscdk.AppProps{Context: &ctx})
+}
+
+func TestShareAndUse(t *testing.T) {
+	defer jsii.Close()
+	t.Setenv("CDK_DEFAULT_ACCOUNT", "123456789012")
+
+	app := newShareTestApp()
+	agcdkutil.SetupApp(app, agcdkutil.AppConfig{Prefix: "myapp-", DeployersGroup: "myapp-deployers"},
+		func(stack awscdk.Stack) awscdk.Stack {
+			agcdkutil.Share(stack, vpcIDKey, jsii.String("vpc-123"))
+			agcdkutil.Share(stack, zonesKey, &[]*string{jsii.String("a"), jsii.String("b")})
+			agcdkutil.Share(stack, portKey, jsii.Number(5432))
+			return stack
+		},
+		func(stack awscdk.Stack, _ awscdk.Stack, _ string) {
+			awscdk.NewCfnOutput(stack, jsii.String("Vpc"), &awscdk.CfnOutputProps{
+				Value: agcdkutil.Use(stack, vpcIDKey),
+			})
+			awscdk.NewCfnOutput(stack, jsii.String("FirstZone"), &awscdk.CfnOutputProps{
+				Value: awscdk.Fn_Select(jsii.Number(0), agcdkutil.Use(stack, zonesKey)),
+			})
+			awscdk.NewCfnOutput(stack, jsii.String("Port"), &awscdk.CfnOutputProps{
+				Value: awscdk.Token_AsString(agcdkutil.Use(stack, portKey), nil),
+			})
+		})
+
+	assembly := app.Synth(nil)
+	outputs := func(stackName string) map[string]any {
+		template, _ := assembly.GetStackByName(jsii.String(stackName)).Template().(map[string]any)
+		outputs, _ := template["Outputs"].(map[string]any)
+		return outputs
+	}
+
+	shared := outputs("myappEuw1Shared")
+	vpc, _ := shared["ShareVpcId"].(map[string]any)
+	export, _ := vpc["Export"].(map[string]any)
+	if export["Name"] != agcdkutil.ShareExportName("myapp", "Euw1", "VpcId") || vpc["Value"] != "vpc-123" {
+		t.Errorf("unexpected shared output %v", vpc)
+	}
+	if port, _ := shared["SharePort"].(map[string]any); port["Value"] != "5432" {
+		t.Errorf("unexpected shared port %v", port)
+	}
+
+	deployment := outputs("myappEuw1Dev")
+	used, _ := deployment["Vpc"].(map[string]any)
+	imported, _ := used["Value"].(map[string]any)
+	if imported["Fn::ImportValue"] != "myappEuw1Shared:VpcId" {
+		t.Errorf("expected an import of the secondary region's export, got %v", used["Value"])
+	}
+
+	stack := awscdk.Stack_Of(app.Node().FindChild(jsii.String("myappEuw1Dev")))
+	var deps []string
+	for _, dep := range *stack.Dependencies() {
+		deps = append(deps, *dep.StackName())
+	}
+	if !slices.Contains(deps, "myappEuw1Shared") {
+		t.Errorf("expected the deployment stack to depend on the shared stack of its region, got %v", deps)
+	}
+}
+
+func TestUsePanicsWhenNotShared(t *testing.T) {
+	defer jsii.Close()
+
+	app := newShareTestApp()
+
+	defer func() {
+		if recover() == nil {
+			t.Error("expected panic")
+		}
+	}()
+
+	agcdkutil.SetupApp(app, agcdkutil.AppConfig{Prefix: "myapp-", DeployersGroup: "myapp-deployers"},
+		func(stack awscdk.Stack) awscdk.Stack { return stack },
+		func(stack awscdk.Stack, _ awscdk.Stack, _ string) {
+			agcdkutil.Use(stack, vpcIDKey)
+		})
+}
+
+func TestSharePanicsInDeploymentStack(t *testing.T) {
+	defer jsii.Close()
+
+	app := newShareTestApp()
+
+	defer func() {
+		if recover() == nil {
+			t.Error("expected panic")
+		}
+	}()
+
+	agcdkutil.SetupApp(app, agcdkutil.AppConfig{Prefix: "myapp-", DeployersGroup: "myapp-deployers"},
+		func(stack awscdk.Stack) awscdk.Stack { return stack },
+		func(stack awscdk.Stack, _ awscdk.Stack, _ string) {
+			agcdkutil.Share(stack, vpcIDKey, jsii.String("vpc-123"))
+		})
+}
+
+func TestNewShareKeyPanicsOnInvalidName(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("expected panic")
+		}
+	}()
+
+	agcdkutil.NewShareKey[*string]("vpc-id")
+}
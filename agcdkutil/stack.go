@@ -25,6 +25,20 @@ func DeploymentStackName(qualifier, regionIdent, deploymentIdent string) string
 	return base + deploymentIdent
 }
 
+// PermissionsBoundaryName returns the name of the managed policy that the pre-bootstrap stack
+// creates as the permissions boundary of every role of the project.
+func PermissionsBoundaryName(qualifier string) string {
+	return qualifier + "-permissions-boundary"
+}
+
+// DeploymentSessionTagKey is the session tag set when the CDK CLI assumes a stack's deploy role.
+// Its value is the deployment identifier, or SharedDeploymentTag for shared stacks. The IAM
+// policies of the pre-bootstrap stack use it to limit dev deployers to their own deployment.
+const DeploymentSessionTagKey = "ago-deployment"
+
+// SharedDeploymentTag is the DeploymentSessionTagKey value for shared stacks.
+const SharedDeploymentTag = "Shared"
+
 // NewStack creates a new CDK Stack, either shared or multi-deployment.
 //
 // Deprecated: Use NewStackFromConfig instead for upfront validation.
@@ -48,6 +62,7 @@ func newStackInternal(
 ) awscdk.Stack {
 	var stackName string
 	var description string
+	deploymentTag := SharedDeploymentTag
 
 	baseIdent := strcase.ToLowerCamel(fmt.Sprintf("%s-%s", qual, regionAcronym))
 
@@ -59,6 +74,7 @@ func newStackInternal(
 		}
 
 		stackName = DeploymentStackName(qual, regionAcronym, dident)
+		deploymentTag = dident
 		description = fmt.Sprintf("%s (region: %s, deployment: %s)", baseIdent, region, dident)
 	case len(deploymentIdent) > 0:
 		panic("invalid deploymentIdent: " + deploymentIdent[0])
@@ -75,8 +91,12 @@ func newStackInternal(
 		Description: jsii.String(description),
 		Synthesizer: awscdk.NewDefaultStackSynthesizer(&awscdk.DefaultStackSynthesizerProps{
 			Qualifier: jsii.String(qual),
+			DeployRoleAdditionalOptions: &map[string]any{
+				"Tags": []any{map[string]any{"Key": DeploymentSessionTagKey, "Value": deploymentTag}},
+			},
 		}),
 	})
+	stack.Node().SetContext(jsii.String(deploymentContextKey), deploymentTag)
 
 	awscdk.Annotations_Of(stack).AcknowledgeWarning(
 		jsii.String("@aws-cdk/aws-lambda-go-alpha:goBuildFlagsSecurityWarning"),
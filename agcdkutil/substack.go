@@ -0,0 +1,56 @@
+package agcdkutil
+
+import (
+	"github.com/aws/aws-cdk-go/awscdk/v2"
+	"github.com/aws/constructs-go/constructs/v10"
+	"github.com/aws/jsii-runtime-go"
+)
+
+// deploymentContextKey is the construct context key under which a stack records the deployment it
+// belongs to. Constructs, including those in sub-stacks, read it with [DeploymentOf].
+const deploymentContextKey = "ago:deployment"
+
+// DeploymentOf returns the deployment identifier of the stack that scope is in, or
+// SharedDeploymentTag for shared stacks. It returns an empty string outside stacks created by
+// agcdkutil.
+func DeploymentOf(scope constructs.Construct) string {
+	deployment, _ := scope.Node().TryGetContext(jsii.String(deploymentContextKey)).(string)
+	return deployment
+}
+
+// NewSubStack creates a nested stack in the stack of scope, to split a shared or deployment stack
+// before it reaches the CloudFormation limits of 500 resources and 1 MB of template. The name is
+// the construct ID of the sub-stack and must be PascalCase, such as "Data" or "Monitoring".
+//
+// The sub-stack is deployed, diffed and destroyed with its parent. It inherits the parent's tags
+// and deployment, so [DeploymentOf] works inside it, and the permissions boundary from the CDK
+// context applies to it as to every stack of the app. The ago CLI rolls its resources up under
+// the parent, prefixed with the name.
+func NewSubStack(scope constructs.Construct, name string) awscdk.NestedStack {
+	if name == "" || name[0] < 'A' || name[0] > 'Z' {
+		panic("sub-stack name must start with an upper-case letter, got: " + name)
+	}
+	for _, c := range name {
+		if (c < 'a' || c > 'z') && (c < 'A' || c > 'Z') && (c < '0' || c > '9') {
+			panic("sub-stack name must only contain letters and digits, got: " + name)
+		}
+	}
+
+	parent := awscdk.Stack_Of(scope)
+	nested := awscdk.NewNestedStack(scope, jsii.String(name), &awscdk.NestedStackProps{
+		Description: jsii.String(*parent.Node().Path() + " sub-stack " + name),
+	})
+
+	if tags := parent.Tags().TagValues(); tags != nil {
+		for key, value := range *tags {
+			awscdk.Tags_Of(nested).Add(jsii.String(key), value, nil)
+		}
+	}
+
+	awscdk.Annotations_Of(nested).AcknowledgeWarning(
+		jsii.String("@aws-cdk/aws-lambda-go-alpha:goBuildFlagsSecurityWarning"),
+		jsii.String("Build flags are controlled by agcdkutil.ReproducibleGoBundling and are safe"),
+	)
+
+	return nested
+}
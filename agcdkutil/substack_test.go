@@ -0,0 +1,85 @@
+//nolint:paralleltest // jsii runtime doesn't support parallel tests
+package agcdkutil_test
+
+import (
+	"testing"
+
+	"github.com/advdv/ago/agcdkutil"
+	"github.com/aws/aws-cdk-go/awscdk/v2"
+	"github.com/aws/jsii-runtime-go"
+)
+
+func TestNewSubStack(t *testing.T) {
+	defer jsii.Close()
+	t.Setenv("CDK_DEFAULT_ACCOUNT", "123456789012")
+
+	ctx := map[string]any{
+		"myapp-qualifier":         "myapp",
+		"myapp-primary-region":    "us-east-1",
+		"myapp-secondary-regions": []any{},
+		"myapp-deployments":       []any{"Prod"},
+		"myapp-deployer-groups":   "myapp-deployers",
+		"myapp-base-domain-name":  "example.com",
+	}
+
+	app := awscdk.NewApp(&awscdk.AppProps{
+		Context: &ctx,
+	})
+
+	deployments := map[string]string{}
+	agcdkutil.SetupApp(app, agcdkutil.AppConfig{
+		Prefix:         "myapp-",
+		DeployersGroup: "myapp-deployers",
+	},
+		func(stack awscdk.Stack) *testShared {
+			sub := agcdkutil.NewSubStack(stack, "Data")
+			deployments[*sub.StackName()] = agcdkutil.DeploymentOf(sub)
+			awscdk.NewCfnWaitConditionHandle(sub, jsii.String("Placeholder"), nil)
+			return &testShared{}
+		},
+		func(stack awscdk.Stack, _ *testShared, _ string) {
+			sub := agcdkutil.NewSubStack(stack, "Data")
+			deployments[*sub.StackName()] = agcdkutil.DeploymentOf(sub)
+			awscdk.NewCfnWaitConditionHandle(sub, jsii.String("Placeholder"), nil)
+		},
+	)
+
+	seen := map[string]bool{}
+	for _, deployment := range deployments {
+		seen[deployment] = true
+	}
+	if len(deployments) != 2 || !seen[agcdkutil.SharedDeploymentTag] || !seen["Prod"] {
+		t.Errorf("expected sub-stacks of the Shared and Prod deployments, got %v", deployments)
+	}
+
+	template, ok := app.Synth(nil).GetStackByName(jsii.String("myappUse1Prod")).Template().(map[string]any)
+	if !ok {
+		t.Fatal("expected template to be a map")
+	}
+	resources, _ := template["Resources"].(map[string]any)
+	var nested int
+	for _, res := range resources {
+		r, _ := res.(map[string]any)
+		if r["Type"] == "AWS::CloudFormation::Stack" {
+			nested++
+		}
+	}
+	if nested != 1 {
+		t.Errorf("expected 1 nested stack resource in the parent, got %d", nested)
+	}
+}
+
+func TestNewSubStackPanicsOnInvalidName(t *testing.T) {
+	defer jsii.Close()
+
+	app := awscdk.NewApp(nil)
+	stack := awscdk.NewStack(app, jsii.String("myappUse1Prod"), nil)
+
+	defer func() {
+		if recover() == nil {
+			t.Error("expected panic")
+		}
+	}()
+
+	agcdkutil.NewSubStack(stack, "data-tier")
+}
@@ -0,0 +1,201 @@
+package agcdkutil
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-cdk-go/awscdk/v2"
+	"github.com/aws/constructs-go/constructs/v10"
+	"github.com/aws/jsii-runtime-go"
+	"github.com/iancoleman/strcase"
+)
+
+// Context keys, after the prefix, of the multi-tenant deployment model.
+const (
+	// TenantsContextKey lists the idents of the tenants, each of which gets its own deployment.
+	TenantsContextKey = "tenants"
+	// TenantContextKey limits a synth to the stacks of a single tenant, as passed with
+	// -c myapp-tenant=acme. 'ago infra cdk deploy' sets it when it deploys a tenant.
+	TenantContextKey = "tenant"
+	// TenantPageContextKey limits a synth to a page of tenants, counting from 1, as passed with
+	// -c myapp-tenant-page=2.
+	TenantPageContextKey = "tenant-page"
+)
+
+// DefaultTenantPageSize is the number of tenants on a page when AppConfig sets no page size.
+const DefaultTenantPageSize = 50
+
+// TenantStackTagKey is the tag that marks the stacks, and so the resources, of a tenant with its
+// ident, for cost allocation per customer.
+const TenantStackTagKey = "ago-tenant"
+
+var tenantRegex = regexp.MustCompile(`^[a-z][a-z0-9-]*$`)
+
+// Tenant is a customer with an isolated deployment.
+type Tenant struct {
+	// Ident identifies the tenant in the tenants context key, such as "acme-corp".
+	Ident string
+	// Deployment is the deployment identifier of the tenant, such as "TenantAcmeCorp".
+	Deployment string
+}
+
+// TenantConstructor creates the infrastructure of a single tenant in a given stack. It receives
+// the shared construct from the same region and the tenant.
+type TenantConstructor[S any] func(stack awscdk.Stack, shared S, tenant Tenant)
+
+// TenantDeploymentIdent returns the deployment identifier of a tenant, such as "TenantAcmeCorp"
+// for "acme-corp". It starts with an upper-case letter like every deployment identifier. Apps with
+// tenants may not name other deployments "Tenant...", so that the two cannot clash.
+func TenantDeploymentIdent(tenant string) string {
+	return "Tenant" + strcase.ToCamel(tenant)
+}
+
+// TenantPage returns the tenants on a page, counting from 1, of the given size. Page 0 holds all
+// tenants, and a page past the end holds none.
+func TenantPage(tenants []string, page, size int) []string {
+	if page == 0 {
+		return tenants
+	}
+	if size <= 0 {
+		size = DefaultTenantPageSize
+	}
+	start := (page - 1) * size
+	if start >= len(tenants) {
+		return nil
+	}
+	return tenants[start:min(start+size, len(tenants))]
+}
+
+// validateTenants returns the problems with the tenant idents and the tenant filters of a synth.
+func validateTenants(tenants, deployments []string, tenant string, page int) []string {
+	var errs []string
+	idents := map[string]string{}
+	for _, t := range tenants {
+		if !tenantRegex.MatchString(t) {
+			errs = append(errs, fmt.Sprintf("tenant %q must start with a lowercase letter and contain only "+
+				"lowercase letters, numbers and dashes", t))
+			continue
+		}
+		ident := TenantDeploymentIdent(t)
+		if other, ok := idents[ident]; ok {
+			errs = append(errs, fmt.Sprintf("tenants %q and %q have the same deployment identifier %s",
+				other, t, ident))
+		}
+		idents[ident] = t
+	}
+
+	for _, d := range deployments {
+		if len(tenants) > 0 && strings.HasPrefix(d, "Tenant") {
+			errs = append(errs, fmt.Sprintf("deployment %q starts with Tenant, which is reserved for "+
+				"the deployments of tenants", d))
+		}
+	}
+
+	if tenant != "" && !slices.Contains(tenants, tenant) {
+		errs = append(errs, fmt.Sprintf("tenant %q selected with context key %q is not a tenant",
+			tenant, TenantContextKey))
+	}
+	if page < 0 {
+		errs = append(errs, fmt.Sprintf("context key %q must count from 1, got %d", TenantPageContextKey, page))
+	}
+	return errs
+}
+
+// Tenant returns the tenant that a deployment identifier belongs to, and false when it is not the
+// deployment of a tenant.
+func (c *Config) Tenant(deployment string) (Tenant, bool) {
+	for _, t := range c.Tenants {
+		if TenantDeploymentIdent(t) == deployment {
+			return Tenant{Ident: t, Deployment: deployment}, true
+		}
+	}
+	return Tenant{}, false
+}
+
+// AllowedTenants returns the tenants whose stacks the current deployer synthesizes: those on the
+// selected page, or the selected tenant. Tenant deployments serve customers, so only members of
+// the DeployersGroup synthesize them. Returns nil if DeployerGroups is nil (bootstrap mode).
+func (c *Config) AllowedTenants() []Tenant {
+	if c.DeployerGroups == nil || !slices.Contains(c.DeployerGroups, c.DeployersGroup) {
+		return nil
+	}
+
+	idents := TenantPage(c.Tenants, c.TenantPage, c.TenantPageSize)
+	if c.TenantFilter != "" {
+		idents = []string{c.TenantFilter}
+	}
+
+	tenants := make([]Tenant, 0, len(idents))
+	for _, t := range idents {
+		tenants = append(tenants, Tenant{Ident: t, Deployment: TenantDeploymentIdent(t)})
+	}
+	return tenants
+}
+
+// SetupMultiTenantApp configures a CDK app like [SetupApp], and adds an isolated deployment for
+// every tenant in the tenants context key, for SaaS apps where each customer gets their own
+// stacks:
+//
+//	{
+//	  "myapp-deployments": ["Dev", "Stag", "Prod"],
+//	  "myapp-tenants": ["acme-corp", "globex"]
+//	}
+//
+// Resources that all tenants use, such as a VPC or a cluster, belong in the shared stacks that
+// newShared creates; resources of a single tenant belong in the stacks of newTenant, which are
+// named and tagged after the tenant and depend only on the shared stacks. The deployments of
+// newDeployment, such as a staging environment, are set up alongside as usual.
+//
+// Each tenant adds a stack per region, so with hundreds of tenants a single synth gets slow and
+// an app gets close to the stack quota of the account. The tenant-page context key limits a synth
+// to a page of AppConfig.TenantPageSize tenants, and the tenant context key to a single tenant.
+func SetupMultiTenantApp[S any](
+	app awscdk.App,
+	cfg AppConfig,
+	newShared SharedConstructor[S],
+	newDeployment DeploymentConstructor[S],
+	newTenant TenantConstructor[S],
+) {
+	stacks := setupStacks(app, cfg, newShared)
+	for _, deploymentIdent := range stacks.config.AllowedDeployments() {
+		stacks.addDeployment(app, deploymentIdent, func(stack awscdk.Stack, shared S) {
+			newDeployment(stack, shared, deploymentIdent)
+		})
+	}
+
+	for _, tenant := range stacks.config.AllowedTenants() {
+		stacks.addDeployment(app, tenant.Deployment, func(stack awscdk.Stack, shared S) {
+			awscdk.Tags_Of(stack).Add(jsii.String(TenantStackTagKey), jsii.String(tenant.Ident), nil)
+			newTenant(stack, shared, tenant)
+		})
+	}
+}
+
+// TenantOf returns the tenant whose stack the scope is in, and false when the stack is not the
+// stack of a tenant.
+// Retrieves Config from the construct tree.
+func TenantOf(scope constructs.Construct) (Tenant, bool) {
+	return ConfigFromScope(scope).Tenant(DeploymentOf(scope))
+}
+
+// readOptionalContextInt reads an integer context key that may also be passed as a string with
+// -c on the command line. It returns 0 when the key is not set.
+func readOptionalContextInt(scope constructs.Construct, key string, errs []string) (int, []string) {
+	switch val := scope.Node().TryGetContext(jsii.String(key)).(type) {
+	case nil:
+		return 0, errs
+	case float64:
+		return int(val), errs
+	case string:
+		n, err := strconv.Atoi(val)
+		if err != nil {
+			return 0, append(errs, fmt.Sprintf("context key %q must be a number, got %q", key, val))
+		}
+		return n, errs
+	default:
+		return 0, append(errs, fmt.Sprintf("context key %q must be a number, got %T", key, val))
+	}
+}
@@ -0,0 +1,114 @@
+//nolint:paralleltest // jsii runtime doesn't support parallel tests
+package agcdkutil_test
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/advdv/ago/agcdkutil"
+	"github.com/aws/aws-cdk-go/awscdk/v2"
+	"github.com/aws/jsii-runtime-go"
+)
+
+func TestTenantDeploymentIdent(t *testing.T) {
+	for tenant, want := range map[string]string{
+		"acme-corp": "TenantAcmeCorp",
+		"globex":    "TenantGlobex",
+		"initech2":  "TenantInitech2",
+	} {
+		if got := agcdkutil.TenantDeploymentIdent(tenant); got != want {
+			t.Errorf("TenantDeploymentIdent(%q) = %q, want %q", tenant, got, want)
+		}
+	}
+}
+
+func TestTenantPage(t *testing.T) {
+	tenants := []string{"a", "b", "c", "d", "e"}
+	tests := []struct {
+		page, size int
+		want       []string
+	}{
+		{page: 0, size: 2, want: []string{"a", "b", "c", "d", "e"}},
+		{page: 1, size: 2, want: []string{"a", "b"}},
+		{page: 3, size: 2, want: []string{"e"}},
+		{page: 4, size: 2, want: nil},
+		{page: 1, size: 0, want: []string{"a", "b", "c", "d", "e"}},
+	}
+	for _, tt := range tests {
+		if got := agcdkutil.TenantPage(tenants, tt.page, tt.size); !slices.Equal(got, tt.want) {
+			t.Errorf("TenantPage(page %d, size %d) = %v, want %v", tt.page, tt.size, got, tt.want)
+		}
+	}
+}
+
+// setupMultiTenantApp synthesizes a multi-tenant app with the context overrides, and returns the
+// deployments and the tenants that were constructed.
+func setupMultiTenantApp(t *testing.T, overrides map[string]any) (deployments, tenants []string) {
+	t.Helper()
+	ctx := map[string]any{
+		"myapp-qualifier":         "myapp",
+		"myapp-primary-region":    "us-east-1",
+		"myapp-secondary-regions": []any{},
+		"myapp-deployments":       []any{"Dev", "Prod"},
+		"myapp-tenants":           []any{"acme-corp", "globex", "initech"},
+		"myapp-deployer-groups":   "myapp-deployers",
+		"myapp-base-domain-name":  "example.com",
+	}
+	for k, v := range overrides {
+		ctx[k] = v
+	}
+
+	app := awscdk.NewApp(&awscdk.AppProps{Context: &ctx})
+	agcdkutil.SetupMultiTenantApp(app, agcdkutil.AppConfig{
+		Prefix:         "myapp-",
+		DeployersGroup: "myapp-deployers",
+		TenantPageSize: 2,
+	},
+		func(stack awscdk.Stack) *testShared { return &testShared{Region: *stack.Region()} },
+		func(stack awscdk.Stack, shared *testShared, deploymentIdent string) {
+			deployments = append(deployments, deploymentIdent)
+		},
+		func(stack awscdk.Stack, shared *testShared, tenant agcdkutil.Tenant) {
+			if got, ok := agcdkutil.TenantOf(stack); !ok || got != tenant {
+				t.Errorf("TenantOf(%s) = %+v, %v, want %+v", *stack.StackName(), got, ok, tenant)
+			}
+			tenants = append(tenants, tenant.Deployment)
+		},
+	)
+	return deployments, tenants
+}
+
+func TestSetupMultiTenantApp(t *testing.T) {
+	defer jsii.Close()
+
+	t.Run("all tenants", func(t *testing.T) {
+		deployments, tenants := setupMultiTenantApp(t, nil)
+		if want := []string{"Dev", "Prod"}; !slices.Equal(deployments, want) {
+			t.Errorf("deployments = %v, want %v", deployments, want)
+		}
+		if want := []string{"TenantAcmeCorp", "TenantGlobex", "TenantInitech"}; !slices.Equal(tenants, want) {
+			t.Errorf("tenants = %v, want %v", tenants, want)
+		}
+	})
+
+	t.Run("page of tenants", func(t *testing.T) {
+		_, tenants := setupMultiTenantApp(t, map[string]any{"myapp-tenant-page": "2"})
+		if want := []string{"TenantInitech"}; !slices.Equal(tenants, want) {
+			t.Errorf("tenants = %v, want %v", tenants, want)
+		}
+	})
+
+	t.Run("single tenant", func(t *testing.T) {
+		_, tenants := setupMultiTenantApp(t, map[string]any{"myapp-tenant": "globex"})
+		if want := []string{"TenantGlobex"}; !slices.Equal(tenants, want) {
+			t.Errorf("tenants = %v, want %v", tenants, want)
+		}
+	})
+
+	t.Run("dev deployer synthesizes no tenants", func(t *testing.T) {
+		_, tenants := setupMultiTenantApp(t, map[string]any{"myapp-deployer-groups": "myapp-dev-deployers"})
+		if len(tenants) != 0 {
+			t.Errorf("tenants = %v, want none", tenants)
+		}
+	})
+}
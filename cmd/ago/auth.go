@@ -0,0 +1,363 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/advdv/ago/agcdkutil"
+	"github.com/advdv/ago/cmd/ago/internal/awsapi"
+	"github.com/advdv/ago/cmd/ago/internal/config"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cognitoidentityprovider"
+	cognitotypes "github.com/aws/aws-sdk-go-v2/service/cognitoidentityprovider/types"
+	"github.com/cockroachdb/errors"
+	"github.com/urfave/cli/v3"
+)
+
+// userPoolOutputSuffix is how user pool outputs are recognized in a deployment stack's output
+// registry, e.g. "UserPoolId" or "AdminUserPoolId".
+const userPoolOutputSuffix = "UserPoolId"
+
+func authCmd() *cli.Command {
+	deploymentFlags := func(flags ...cli.Flag) []cli.Flag {
+		return append([]cli.Flag{
+			&cli.StringFlag{
+				Name:  "deployment",
+				Usage: "Deployment identifier (defaults to Dev{username} for the current deployer)",
+			},
+			&cli.StringFlag{
+				Name:  "user-pool-output",
+				Usage: "Output of the deployment stack that holds the user pool id (defaults to the only output ending in " + userPoolOutputSuffix + ")",
+			},
+		}, flags...)
+	}
+
+	return &cli.Command{
+		Name:  "auth",
+		Usage: "Administer the users in a deployment's Cognito user pool",
+		Commands: []*cli.Command{
+			{
+				Name:      "create-user",
+				Usage:     "Create a user in the deployment's user pool",
+				ArgsUsage: "<username>",
+				Flags: deploymentFlags(
+					&cli.StringFlag{
+						Name:  "email",
+						Usage: "Email address of the user, marked as verified",
+					},
+					&cli.StringFlag{
+						Name:  "temporary-password",
+						Usage: "Temporary password the user must change at first sign-in (generated by Cognito if not set)",
+					},
+					&cli.BoolFlag{
+						Name:  "suppress-invite",
+						Usage: "Do not send the invitation message",
+					},
+				),
+				Action: config.RunWithConfig(runAuthCreateUser),
+			},
+			{
+				Name:  "list-users",
+				Usage: "List the users in the deployment's user pool",
+				Flags: deploymentFlags(&cli.BoolFlag{
+					Name:  "json",
+					Usage: "Print the users as JSON",
+				}),
+				Action: config.RunWithConfig(runAuthListUsers),
+			},
+			{
+				Name:      "set-password",
+				Usage:     "Set the password of a user in the deployment's user pool",
+				ArgsUsage: "<username>",
+				Flags: deploymentFlags(
+					&cli.StringFlag{
+						Name:     "password",
+						Usage:    "New password of the user",
+						Required: true,
+					},
+					&cli.BoolFlag{
+						Name:  "temporary",
+						Usage: "Require the user to change the password at the next sign-in",
+					},
+				),
+				Action: config.RunWithConfig(runAuthSetPassword),
+			},
+		},
+	}
+}
+
+type authOptions struct {
+	Deployment        string
+	OutputKey         string
+	Profile           string
+	Region            string
+	Username          string
+	Email             string
+	Password          string
+	TemporaryPassword bool
+	SuppressInvite    bool
+	JSON              bool
+	Output            io.Writer
+}
+
+func authOptionsFromCmd(cmd *cli.Command) authOptions {
+	return authOptions{
+		Deployment: cmd.String("deployment"),
+		OutputKey:  cmd.String("user-pool-output"),
+		Profile:    cmd.String("profile"),
+		Region:     cmd.String("region"),
+		Username:   cmd.Args().First(),
+		Output:     os.Stdout,
+	}
+}
+
+func runAuthCreateUser(ctx context.Context, cmd *cli.Command, cfg config.Config) error {
+	opts := authOptionsFromCmd(cmd)
+	if opts.Username == "" {
+		return errors.New("username argument is required")
+	}
+	opts.Email = cmd.String("email")
+	opts.Password = cmd.String("temporary-password")
+	opts.SuppressInvite = cmd.Bool("suppress-invite")
+	return doAuthCreateUser(ctx, cfg, opts)
+}
+
+func runAuthListUsers(ctx context.Context, cmd *cli.Command, cfg config.Config) error {
+	opts := authOptionsFromCmd(cmd)
+	opts.JSON = cmd.Bool("json") || jsonOutput(cmd)
+	return doAuthListUsers(ctx, cfg, opts)
+}
+
+func runAuthSetPassword(ctx context.Context, cmd *cli.Command, cfg config.Config) error {
+	opts := authOptionsFromCmd(cmd)
+	if opts.Username == "" {
+		return errors.New("username argument is required")
+	}
+	opts.Password = cmd.String("password")
+	opts.TemporaryPassword = cmd.Bool("temporary")
+	return doAuthSetPassword(ctx, cfg, opts)
+}
+
+// userPool is the user pool of a deployment and how to reach it.
+type userPool struct {
+	ID         string
+	Deployment string
+	AWS        *awsapi.Clients
+}
+
+// resolveUserPool finds the user pool of the deployment from the outputs that agcdkutil.Output
+// recorded for the deployment stack in the primary region.
+func resolveUserPool(ctx context.Context, cfg config.Config, opts authOptions) (*userPool, error) {
+	cdk, err := loadCDKContext(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	username, usernameErr := resolveCDKCallerUsername(ctx, opts.Profile, cdk.Context)
+
+	deployment, err := resolveDeploymentIdent(cfg.Inner, cdkCommandOptions{Deployment: opts.Deployment},
+		cdk.Context, username, usernameErr)
+	if err != nil {
+		return nil, err
+	}
+
+	profile := resolveCDKProfile(opts.Profile, cdk.Context, username)
+
+	primaryRegion := cdk.PrimaryRegion
+	region := resolveAWSRegion(opts.Region, primaryRegion)
+	if region == "" {
+		return nil, errors.Errorf("primary region not found at context key %q", cdk.Prefix+"primary-region")
+	}
+
+	clients, err := awsapi.New(ctx, profile, region)
+	if err != nil {
+		return nil, err
+	}
+
+	stackName := agcdkutil.DeploymentStackName(cdk.Qualifier, agcdkutil.RegionIdentFor(region), deployment)
+	outputs, err := getOutputRegistry(ctx, clients, stackName)
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := selectUserPoolOutput(outputs, opts.OutputKey)
+	if err != nil {
+		return nil, errors.Wrapf(err, "stack %q", stackName)
+	}
+
+	return &userPool{ID: id, Deployment: deployment, AWS: clients}, nil
+}
+
+// selectUserPoolOutput returns the user pool id from a stack's outputs: the output named key, or
+// the only output whose name ends in userPoolOutputSuffix when key is empty.
+func selectUserPoolOutput(outputs map[string]string, key string) (string, error) {
+	if key != "" {
+		id, ok := outputs[key]
+		if !ok {
+			return "", errors.Errorf("has no output %q", key)
+		}
+		return id, nil
+	}
+
+	var candidates []string
+	for k := range outputs {
+		if strings.HasSuffix(k, userPoolOutputSuffix) {
+			candidates = append(candidates, k)
+		}
+	}
+	slices.Sort(candidates)
+
+	switch len(candidates) {
+	case 0:
+		return "", errors.Errorf("has no output ending in %q, record the user pool id with agcdkutil.Output",
+			userPoolOutputSuffix)
+	case 1:
+		return outputs[candidates[0]], nil
+	default:
+		return "", errors.Errorf("has several user pool outputs (%s), select one with --user-pool-output",
+			strings.Join(candidates, ", "))
+	}
+}
+
+func doAuthCreateUser(ctx context.Context, cfg config.Config, opts authOptions) error {
+	pool, err := resolveUserPool(ctx, cfg, opts)
+	if err != nil {
+		return err
+	}
+
+	in := &cognitoidentityprovider.AdminCreateUserInput{
+		UserPoolId: aws.String(pool.ID),
+		Username:   aws.String(opts.Username),
+	}
+	if opts.Email != "" {
+		in.UserAttributes = []cognitotypes.AttributeType{
+			{Name: aws.String("email"), Value: aws.String(opts.Email)},
+			{Name: aws.String("email_verified"), Value: aws.String("true")},
+		}
+	}
+	if opts.Password != "" {
+		in.TemporaryPassword = aws.String(opts.Password)
+	}
+	if opts.SuppressInvite {
+		in.MessageAction = cognitotypes.MessageActionTypeSuppress
+	}
+
+	if _, err := pool.AWS.Cognito.AdminCreateUser(ctx, in); err != nil {
+		return errors.Wrapf(err, "failed to create user %q", opts.Username)
+	}
+
+	writeOutputf(opts.Output, "Created user %q in user pool %s (deployment: %s)\n",
+		opts.Username, pool.ID, pool.Deployment)
+	return nil
+}
+
+// cognitoUser is a user of a user pool, in the shape of the ListUsers API.
+type cognitoUser struct {
+	Username       string             `json:"Username"`       //nolint:tagliatelle // AWS API uses PascalCase
+	UserStatus     string             `json:"UserStatus"`     //nolint:tagliatelle // AWS API uses PascalCase
+	Enabled        bool               `json:"Enabled"`        //nolint:tagliatelle // AWS API uses PascalCase
+	UserCreateDate string             `json:"UserCreateDate"` //nolint:tagliatelle // AWS API uses PascalCase
+	Attributes     []cognitoAttribute `json:"Attributes"`     //nolint:tagliatelle // AWS API uses PascalCase
+}
+
+type cognitoAttribute struct {
+	Name  string `json:"Name"`  //nolint:tagliatelle // AWS API uses PascalCase
+	Value string `json:"Value"` //nolint:tagliatelle // AWS API uses PascalCase
+}
+
+func newCognitoUser(u cognitotypes.UserType) cognitoUser {
+	user := cognitoUser{
+		Username:   aws.ToString(u.Username),
+		UserStatus: string(u.UserStatus),
+		Enabled:    u.Enabled,
+	}
+	if u.UserCreateDate != nil {
+		user.UserCreateDate = u.UserCreateDate.Format(time.RFC3339)
+	}
+	for _, a := range u.Attributes {
+		user.Attributes = append(user.Attributes, cognitoAttribute{
+			Name:  aws.ToString(a.Name),
+			Value: aws.ToString(a.Value),
+		})
+	}
+	return user
+}
+
+func (u cognitoUser) attribute(name string) string {
+	for _, a := range u.Attributes {
+		if a.Name == name {
+			return a.Value
+		}
+	}
+	return ""
+}
+
+func doAuthListUsers(ctx context.Context, cfg config.Config, opts authOptions) error {
+	pool, err := resolveUserPool(ctx, cfg, opts)
+	if err != nil {
+		return err
+	}
+
+	users := []cognitoUser{}
+	pages := cognitoidentityprovider.NewListUsersPaginator(pool.AWS.Cognito,
+		&cognitoidentityprovider.ListUsersInput{UserPoolId: aws.String(pool.ID)})
+	for pages.HasMorePages() {
+		page, err := pages.NextPage(ctx)
+		if err != nil {
+			return errors.Wrap(err, "failed to list users")
+		}
+		for _, u := range page.Users {
+			users = append(users, newCognitoUser(u))
+		}
+	}
+	slices.SortFunc(users, func(a, b cognitoUser) int { return strings.Compare(a.Username, b.Username) })
+
+	if opts.JSON {
+		data, err := json.MarshalIndent(users, "", "  ")
+		if err != nil {
+			return errors.Wrap(err, "failed to marshal users")
+		}
+		writeOutputf(opts.Output, "%s\n", data)
+		return nil
+	}
+
+	if len(users) == 0 {
+		writeOutputf(opts.Output, "No users in user pool %s (deployment: %s)\n", pool.ID, pool.Deployment)
+		return nil
+	}
+
+	for _, u := range users {
+		status := u.UserStatus
+		if !u.Enabled {
+			status += " (disabled)"
+		}
+		writeOutputf(opts.Output, "%-36s %-32s %s\n", u.Username, u.attribute("email"), status)
+	}
+	return nil
+}
+
+func doAuthSetPassword(ctx context.Context, cfg config.Config, opts authOptions) error {
+	pool, err := resolveUserPool(ctx, cfg, opts)
+	if err != nil {
+		return err
+	}
+
+	_, err = pool.AWS.Cognito.AdminSetUserPassword(ctx, &cognitoidentityprovider.AdminSetUserPasswordInput{
+		UserPoolId: aws.String(pool.ID),
+		Username:   aws.String(opts.Username),
+		Password:   aws.String(opts.Password),
+		Permanent:  !opts.TemporaryPassword,
+	})
+	if err != nil {
+		return errors.Wrapf(err, "failed to set password of user %q", opts.Username)
+	}
+
+	writeOutputf(opts.Output, "Set password of user %q in user pool %s (deployment: %s)\n",
+		opts.Username, pool.ID, pool.Deployment)
+	return nil
+}
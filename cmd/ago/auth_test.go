@@ -0,0 +1,71 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSelectUserPoolOutput(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		outputs map[string]string
+		key     string
+		want    string
+		wantErr string
+	}{
+		{
+			name:    "single user pool output",
+			outputs: map[string]string{"ApiUrl": "https://api", "UserPoolId": "eu-central-1_abc"},
+			want:    "eu-central-1_abc",
+		},
+		{
+			name:    "prefixed user pool output",
+			outputs: map[string]string{"AdminUserPoolId": "eu-central-1_adm"},
+			want:    "eu-central-1_adm",
+		},
+		{
+			name:    "explicit output",
+			outputs: map[string]string{"UserPoolId": "eu-central-1_abc", "AdminUserPoolId": "eu-central-1_adm"},
+			key:     "AdminUserPoolId",
+			want:    "eu-central-1_adm",
+		},
+		{
+			name:    "explicit output missing",
+			outputs: map[string]string{"UserPoolId": "eu-central-1_abc"},
+			key:     "Pool",
+			wantErr: `has no output "Pool"`,
+		},
+		{
+			name:    "no user pool output",
+			outputs: map[string]string{"ApiUrl": "https://api"},
+			wantErr: "record the user pool id with agcdkutil.Output",
+		},
+		{
+			name:    "ambiguous",
+			outputs: map[string]string{"UserPoolId": "eu-central-1_abc", "AdminUserPoolId": "eu-central-1_adm"},
+			wantErr: "several user pool outputs (AdminUserPoolId, UserPoolId)",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := selectUserPoolOutput(tt.outputs, tt.key)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("expected error containing %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"os"
+
+	"github.com/advdv/ago/cmd/ago/internal/cdkcontext"
+	"github.com/cockroachdb/errors"
+	"github.com/urfave/cli/v3"
+)
+
+// AWS profile and region resolution.
+//
+// Every command that talks to AWS resolves its profile and region through this file, so the
+// precedence is the same everywhere:
+//
+//	profile: --profile > AGO_PROFILE > AWS_PROFILE > .ago.yml profile > cdk.json "profile" >
+//	         CDK context (deployer or admin profile)
+//	region:  --region > AGO_REGION > AWS_REGION > AWS_DEFAULT_REGION > .ago.yml default_region >
+//	         CDK context (primary region) > eu-central-1
+//
+// The layers up to .ago.yml are applied to the flags before a command runs, see
+// config.ApplyDefaults, so the flag holds the AGO_* variable or the .ago.yml value. Commands pass
+// the fallbacks that apply to them: organization commands have no cdk.json and fall back to the
+// default region directly, while CDK commands pick the deployer profile of the current user.
+// 'ago config resolve' prints where the values come from.
+
+// globalAWSFlags are defined on the root command and accepted by every subcommand.
+func globalAWSFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringFlag{
+			Name:  "profile",
+			Usage: "AWS profile to use (overrides AGO_PROFILE, AWS_PROFILE and the project defaults)",
+		},
+		&cli.StringFlag{
+			Name:  "region",
+			Usage: "AWS region to use (overrides AGO_REGION, AWS_REGION and the project defaults)",
+		},
+		scopedSessionFlag(),
+	}
+}
+
+// awsProfileOverride returns the profile given with --profile or AWS_PROFILE, or "".
+func awsProfileOverride(flag string) string {
+	return cmp.Or(flag, os.Getenv("AWS_PROFILE"))
+}
+
+// resolveAWSProfile returns the profile given with --profile or AWS_PROFILE, or the result of
+// fallback otherwise.
+func resolveAWSProfile(flag string, fallback func() (string, error)) (string, error) {
+	if profile := awsProfileOverride(flag); profile != "" {
+		return profile, nil
+	}
+	return fallback()
+}
+
+// resolveAWSRegion returns the region given with --region, AWS_REGION or AWS_DEFAULT_REGION, or
+// the first non-empty fallback otherwise.
+func resolveAWSRegion(flag string, fallbacks ...string) string {
+	return cmp.Or(append([]string{flag, os.Getenv("AWS_REGION"), os.Getenv("AWS_DEFAULT_REGION")}, fallbacks...)...)
+}
+
+// getCDKProfile returns the profile of cdk.json, which the cdk CLI and read-only commands use.
+func getCDKProfile(cdkCtx *cdkcontext.Context) (string, error) {
+	if cdkCtx.Profile == "" {
+		return "", errors.New("profile not found in cdk.json")
+	}
+	return cdkCtx.Profile, nil
+}
+
+// getAdminProfile returns the admin profile that 'ago infra create-aws-account' wrote to cdk.json.
+func getAdminProfile(cdkCtx *cdkcontext.Context) (string, error) {
+	if cdkCtx.AdminProfile == "" {
+		return "", errors.New("admin-profile not found in cdk.json - was 'ago infra create-aws-account' run?")
+	}
+	return cdkCtx.AdminProfile, nil
+}
+
+// resolveCDKCallerUsername returns the IAM user behind the overriding profile, or the current
+// user as found by getCallerUsername when there is no override.
+func resolveCDKCallerUsername(ctx context.Context, flag string, cdkCtx *cdkcontext.Context) (string, error) {
+	if profile := awsProfileOverride(flag); profile != "" {
+		return getUsernameFromProfile(ctx, profile)
+	}
+	return getCallerUsername(ctx, cdkCtx)
+}
+
+// resolveCDKProfile returns the profile for commands that act on behalf of a deployer: an
+// override wins, otherwise the current user's deployer profile or the admin profile is used.
+func resolveCDKProfile(flag string, cdkCtx *cdkcontext.Context, username string) string {
+	if profile := awsProfileOverride(flag); profile != "" {
+		return profile
+	}
+	return resolveProfile(cdkCtx, username)
+}
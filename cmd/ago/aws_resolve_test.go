@@ -0,0 +1,84 @@
+//nolint:paralleltest // tests modify the process environment
+package main
+
+import (
+	"testing"
+
+	"github.com/cockroachdb/errors"
+)
+
+func TestResolveAWSProfile(t *testing.T) {
+	fallback := func() (string, error) { return "from-cdk-json", nil }
+
+	tests := []struct {
+		name    string
+		flag    string
+		env     string
+		want    string
+		wantErr bool
+	}{
+		{name: "flag wins over env", flag: "from-flag", env: "from-env", want: "from-flag"},
+		{name: "env wins over fallback", env: "from-env", want: "from-env"},
+		{name: "fallback", want: "from-cdk-json"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("AWS_PROFILE", tt.env)
+
+			got, err := resolveAWSProfile(tt.flag, fallback)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("resolveAWSProfile() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	t.Run("fallback error", func(t *testing.T) {
+		t.Setenv("AWS_PROFILE", "")
+
+		_, err := resolveAWSProfile("", func() (string, error) { return "", errors.New("no profile") })
+		if err == nil {
+			t.Error("expected error")
+		}
+	})
+}
+
+func TestResolveAWSRegion(t *testing.T) {
+	tests := []struct {
+		name          string
+		flag          string
+		region        string
+		defaultRegion string
+		fallbacks     []string
+		want          string
+	}{
+		{
+			name: "flag wins", flag: "eu-west-1", region: "us-east-1", defaultRegion: "us-west-2",
+			fallbacks: []string{"eu-central-1"}, want: "eu-west-1",
+		},
+		{
+			name: "AWS_REGION wins over AWS_DEFAULT_REGION", region: "us-east-1", defaultRegion: "us-west-2",
+			fallbacks: []string{"eu-central-1"}, want: "us-east-1",
+		},
+		{
+			name: "AWS_DEFAULT_REGION wins over fallbacks", defaultRegion: "us-west-2",
+			fallbacks: []string{"eu-central-1"}, want: "us-west-2",
+		},
+		{name: "first non-empty fallback", fallbacks: []string{"", "eu-central-1", "us-east-1"}, want: "eu-central-1"},
+		{name: "nothing set", want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("AWS_REGION", tt.region)
+			t.Setenv("AWS_DEFAULT_REGION", tt.defaultRegion)
+
+			if got := resolveAWSRegion(tt.flag, tt.fallbacks...); got != tt.want {
+				t.Errorf("resolveAWSRegion() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+
+	"github.com/advdv/ago/agcdkutil"
+	"github.com/advdv/ago/cmd/ago/internal/awsapi"
+	"github.com/advdv/ago/cmd/ago/internal/ops"
+	"github.com/cockroachdb/errors"
+	"github.com/urfave/cli/v3"
+)
+
+// Scoped sessions.
+//
+// Operational commands, such as redriving a queue or setting a secret, act on a handful of the
+// resources of one deployment, but run with the credentials of the deployer, which reach every
+// deployment. With --scoped-session they instead assume the CDK deploy role of the region, tagged
+// with the deployment, and pass an inline session policy that only allows what the command does
+// to the resources it resolved. A bug in a command then cannot touch anything else. The deploy
+// role may operate deployment resources through the OperateDeploymentResources statement of the
+// deployment scope policy, which bootstrap attaches.
+
+// scopedSessionFlag is the global --scoped-session flag.
+func scopedSessionFlag() cli.Flag {
+	return &cli.BoolFlag{
+		Name: "scoped-session",
+		Usage: "Run the queue, events and secrets commands through a deploy role session that a session " +
+			"policy limits to the resources of the deployment",
+		Sources: cli.EnvVars("AGO_SCOPED_SESSION"),
+	}
+}
+
+// sessionPolicy is an IAM policy document that limits an assumed role session.
+//
+//nolint:tagliatelle // IAM policies use PascalCase
+type sessionPolicy struct {
+	Version   string             `json:"Version"`
+	Statement []sessionStatement `json:"Statement"`
+}
+
+// sessionStatement allows actions on resources.
+//
+//nolint:tagliatelle // IAM policies use PascalCase
+type sessionStatement struct {
+	Effect   string   `json:"Effect"`
+	Action   []string `json:"Action"`
+	Resource []string `json:"Resource"`
+}
+
+// sessionAccount is the account that a session acts in, to build the ARNs of its resources.
+type sessionAccount struct {
+	Partition string
+	ID        string
+	Region    string
+}
+
+// arn returns the ARN of a resource of the service in the region of the account.
+func (a sessionAccount) arn(service, resource string) string {
+	return "arn:" + a.Partition + ":" + service + ":" + a.Region + ":" + a.ID + ":" + resource
+}
+
+// sessionScope returns the statements of the session policy of a command.
+type sessionScope func(account sessionAccount) []sessionStatement
+
+// allow returns a statement that allows the actions on the resources.
+func allow(resources []string, actions ...string) sessionStatement {
+	return sessionStatement{Effect: "Allow", Action: actions, Resource: resources}
+}
+
+// newSessionPolicy returns the session policy with the statements as JSON.
+func newSessionPolicy(statements []sessionStatement) (string, error) {
+	data, err := json.Marshal(sessionPolicy{Version: "2012-10-17", Statement: statements})
+	if err != nil {
+		return "", errors.Wrap(err, "failed to marshal session policy")
+	}
+	return string(data), nil
+}
+
+// parseCallerARN returns the partition and account of a caller identity ARN, such as
+// arn:aws:iam::123456789012:user/alice.
+func parseCallerARN(arn string) (partition, accountID string, err error) {
+	parts := strings.SplitN(arn, ":", 6)
+	if len(parts) != 6 || parts[0] != "arn" || parts[1] == "" || parts[4] == "" {
+		return "", "", errors.Errorf("invalid caller ARN %q", arn)
+	}
+	return parts[1], parts[4], nil
+}
+
+// clients returns clients for the profile and region of the stacks. When scoped, the clients act
+// through a session of the deploy role that the scope limits, named after the command.
+func (s deploymentStacks) clients(
+	ctx context.Context, scoped bool, command string, scope sessionScope,
+) (*awsapi.Clients, error) {
+	if !scoped {
+		return s.aws, nil
+	}
+
+	caller, err := ops.CallerARN(ctx, s.aws)
+	if err != nil {
+		return nil, err
+	}
+	partition, accountID, err := parseCallerARN(caller)
+	if err != nil {
+		return nil, err
+	}
+
+	policy, err := newSessionPolicy(scope(sessionAccount{Partition: partition, ID: accountID, Region: s.Region}))
+	if err != nil {
+		return nil, err
+	}
+
+	roleName := ops.DeployRoleName(s.Qualifier, accountID, s.Region)
+	return awsapi.NewWithRole(ctx, s.Profile, s.Region, awsapi.SessionRole{
+		ARN:         "arn:" + partition + ":iam::" + accountID + ":role/" + roleName,
+		SessionName: "ago-" + command,
+		Policy:      policy,
+		Tags:        map[string]string{agcdkutil.DeploymentSessionTagKey: s.Deployment},
+	})
+}
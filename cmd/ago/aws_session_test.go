@@ -0,0 +1,61 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestParseCallerARN(t *testing.T) {
+	t.Parallel()
+
+	for arn, want := range map[string][2]string{
+		"arn:aws:iam::123456789012:user/alice":                    {"aws", "123456789012"},
+		"arn:aws-cn:sts::123456789012:assumed-role/Admin/session": {"aws-cn", "123456789012"},
+	} {
+		partition, accountID, err := parseCallerARN(arn)
+		if err != nil {
+			t.Fatalf("parseCallerARN(%q): %v", arn, err)
+		}
+		if partition != want[0] || accountID != want[1] {
+			t.Errorf("parseCallerARN(%q) = %q, %q, want %q, %q", arn, partition, accountID, want[0], want[1])
+		}
+	}
+
+	if _, _, err := parseCallerARN("alice"); err == nil {
+		t.Error("expected error for an invalid ARN")
+	}
+}
+
+func TestNewSessionPolicy(t *testing.T) {
+	t.Parallel()
+
+	account := sessionAccount{Partition: "aws", ID: "123456789012", Region: "eu-central-1"}
+	policy, err := newSessionPolicy([]sessionStatement{
+		allow([]string{account.arn("sqs", "myappEucDev-Jobs")}, "sqs:ReceiveMessage"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var doc struct {
+		Version   string
+		Statement []struct {
+			Effect   string
+			Action   []string
+			Resource []string
+		}
+	}
+	if err := json.Unmarshal([]byte(policy), &doc); err != nil {
+		t.Fatal(err)
+	}
+	if doc.Version != "2012-10-17" || len(doc.Statement) != 1 {
+		t.Fatalf("unexpected policy: %s", policy)
+	}
+	statement := doc.Statement[0]
+	if statement.Effect != "Allow" || statement.Action[0] != "sqs:ReceiveMessage" {
+		t.Errorf("unexpected statement: %+v", statement)
+	}
+	if want := "arn:aws:sqs:eu-central-1:123456789012:myappEucDev-Jobs"; statement.Resource[0] != want {
+		t.Errorf("resource = %q, want %q", statement.Resource[0], want)
+	}
+}
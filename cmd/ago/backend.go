@@ -2,17 +2,28 @@ package main
 
 import (
 	"context"
+	"encoding/base64"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
+	"sync"
 
+	"github.com/advdv/ago/agcdkutil"
+	"github.com/advdv/ago/cmd/ago/internal/awsapi"
+	"github.com/advdv/ago/cmd/ago/internal/cdkcontext"
 	"github.com/advdv/ago/cmd/ago/internal/cmdexec"
 	"github.com/advdv/ago/cmd/ago/internal/config"
 	"github.com/advdv/ago/cmd/ago/internal/dirhash"
+	"github.com/advdv/ago/cmd/ago/internal/ops"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecr"
+	ecrtypes "github.com/aws/aws-sdk-go-v2/service/ecr/types"
 	"github.com/cockroachdb/errors"
 	"github.com/urfave/cli/v3"
+	"golang.org/x/sync/errgroup"
 )
 
 func backendCmd() *cli.Command {
@@ -29,14 +40,6 @@ func backendCmd() *cli.Command {
 						Usage: "Deployment identifier (e.g., dev, stag, prod)",
 						Value: "dev",
 					},
-					&cli.StringFlag{
-						Name:  "profile",
-						Usage: "AWS profile for ECR access (defaults to cdk.json profile)",
-					},
-					&cli.StringFlag{
-						Name:  "region",
-						Usage: "AWS region (defaults to primary region from context)",
-					},
 					&cli.StringFlag{
 						Name:  "stack-name",
 						Usage: "CloudFormation stack name containing the ECR repository (defaults to {qualifier}-Shared-{region-ident})",
@@ -46,6 +49,9 @@ func backendCmd() *cli.Command {
 						Usage: "Target platform for the build",
 						Value: "linux/arm64",
 					},
+					modeFlag(),
+					builderFlag(),
+					parallelFlag(),
 				},
 				Action: config.RunWithConfig(runBackendBuildAndPush),
 			},
@@ -57,22 +63,46 @@ func backendCmd() *cli.Command {
 						Name:  "debug",
 						Usage: "Print visited files to stderr",
 					},
+					&cli.BoolFlag{
+						Name:  "no-cache",
+						Usage: "Read every file instead of reusing the digests of unchanged files",
+					},
+					&cli.StringFlag{
+						Name:  "manifest",
+						Usage: "Write the digest of every hashed file to this path, for a later --explain",
+					},
+					&cli.StringFlag{
+						Name:  "explain",
+						Usage: "Print the files that were added, removed or modified since this manifest",
+					},
 				},
 				Action: config.RunWithConfig(runBackendHash),
 			},
+			backendDeployCmd(),
+			backendNewCmdCmd(),
+			backendShellCmd(),
+			backendRegenDockerfileCmd(),
+			backendBumpBaseCmd(),
+			backendPromoteCmd(),
+			backendPruneImagesCmd(),
 		},
 	}
 }
 
 func runBackendBuildAndPush(ctx context.Context, cmd *cli.Command, cfg config.Config) error {
+	output, result := commandOutput(cmd)
 	return doBackendBuildAndPush(ctx, cfg, backendBuildAndPushOptions{
 		Deployment: cmd.String("deployment"),
 		Profile:    cmd.String("profile"),
 		Region:     cmd.String("region"),
 		StackName:  cmd.String("stack-name"),
 		Platform:   cmd.String("platform"),
-		Output:     os.Stdout,
+		Mode:       cmd.String("mode"),
+		Builder:    cmd.String("builder"),
+		Parallel:   cmd.Int("parallel"),
+		Output:     output,
 		ErrOut:     os.Stderr,
+		Result:     result,
 	})
 }
 
@@ -82,56 +112,85 @@ type backendBuildAndPushOptions struct {
 	Region     string
 	StackName  string
 	Platform   string
+	Mode       string
+	Builder    string
+	Parallel   int
 	Output     io.Writer
 	ErrOut     io.Writer
+	Result     io.Writer
 }
 
-func doBackendBuildAndPush(ctx context.Context, cfg config.Config, opts backendBuildAndPushOptions) error {
-	exec := cmdexec.New(cfg).WithOutput(opts.Output, opts.ErrOut)
-	backendExec := exec.InSubdir("backend")
+// buildAndPushResult is the result of build-and-push in the JSON output format.
+type buildAndPushResult struct {
+	Deployment string              `json:"deployment"`
+	Repository string              `json:"repository"`
+	Images     []pushedImageResult `json:"images"`
+}
 
-	cdkContext, err := readCDKContext(cfg)
+type pushedImageResult struct {
+	Name    string `json:"name"`
+	Tag     string `json:"tag"`
+	Digest  string `json:"digest,omitempty"`
+	Existed bool   `json:"existed"`
+}
+
+func doBackendBuildAndPush(ctx context.Context, cfg config.Config, opts backendBuildAndPushOptions) error {
+	res, err := buildAndPush(ctx, cfg, opts)
 	if err != nil {
 		return err
 	}
+	return writeResult(opts.Result, res)
+}
 
-	profile := opts.Profile
-	if profile == "" {
-		profile, err = getCDKProfile(cfg)
-		if err != nil {
-			return err
-		}
+// buildAndPush builds and pushes the images of the backend commands and components, and records
+// their tags and digests for the deployment in cdk.context.json.
+func buildAndPush(ctx context.Context, cfg config.Config, opts backendBuildAndPushOptions) (buildAndPushResult, error) {
+	if err := requireTools(ctx, cfg, "build-and-push"); err != nil {
+		return buildAndPushResult{}, err
 	}
-
-	region := opts.Region
-	if region == "" {
-		region, err = cdkContext.getString("primary-region")
-		if err != nil {
-			return err
+	mode, err := parseBuildMode(opts.Mode)
+	if err != nil {
+		return buildAndPushResult{}, err
+	}
+	if mode == buildModeKo {
+		if err := requireKo(ctx, cfg); err != nil {
+			return buildAndPushResult{}, err
 		}
 	}
 
-	stackName := opts.StackName
-	if stackName == "" {
-		stackName, err = deriveSharedStackName(cdkContext, region)
-		if err != nil {
-			return err
+	// Without the Dockerfile mode only the components need an image builder.
+	var builder imageBuilder
+	if mode == buildModeDockerfile || len(cfg.Inner.Components) > 0 {
+		if builder, err = resolveImageBuilder(ctx, cfg, opts.Builder); err != nil {
+			return buildAndPushResult{}, err
 		}
 	}
 
-	repoURI, err := getStackOutputValue(ctx, exec, profile, region, stackName, "RepositoryURI")
+	exec := cmdexec.New(cfg).WithOutput(opts.Output, opts.ErrOut)
+	backendExec := exec.InSubdir(cfg.Inner.Layout.Backend())
+
+	repo, err := resolveBackendRepository(ctx, cfg, opts.Profile, opts.Region, opts.StackName)
 	if err != nil {
-		return errors.Wrap(err, "failed to get ECR repository URI from stack outputs")
+		return buildAndPushResult{}, err
 	}
 
-	if err := loginToECR(ctx, exec, profile, region); err != nil {
-		return err
+	repoURI := repo.URI
+
+	if builder != "" {
+		if err := loginToECR(ctx, exec, repo.AWS, builder.loginClient()); err != nil {
+			return buildAndPushResult{}, err
+		}
+	}
+	if mode == buildModeKo {
+		if err := loginToECR(ctx, exec, repo.AWS, "ko"); err != nil {
+			return buildAndPushResult{}, err
+		}
 	}
 
 	cmdDir := filepath.Join(backendExec.Dir(), "cmd")
 	entries, err := os.ReadDir(cmdDir)
 	if err != nil {
-		return errors.Wrap(err, "failed to read backend/cmd directory")
+		return buildAndPushResult{}, errors.Wrap(err, "failed to read backend/cmd directory")
 	}
 
 	var cmdNames []string
@@ -142,7 +201,7 @@ func doBackendBuildAndPush(ctx context.Context, cfg config.Config, opts backendB
 	}
 
 	if len(cmdNames) == 0 {
-		return errors.New("no commands found in backend/cmd")
+		return buildAndPushResult{}, errors.New("no commands found in backend/cmd")
 	}
 
 	repoName := extractRepoName(repoURI)
@@ -150,68 +209,248 @@ func doBackendBuildAndPush(ctx context.Context, cfg config.Config, opts backendB
 	h := dirhash.New(dirhash.WithAlwaysInclude("Dockerfile", ".dockerignore"))
 	sourceHash, err := h.Hash(backendExec.Dir(), ".dockerignore")
 	if err != nil {
-		return errors.Wrap(err, "failed to compute backend source hash")
+		return buildAndPushResult{}, errors.Wrap(err, "failed to compute backend source hash")
+	}
+
+	var baseImage string
+	if cfg.Inner.BaseImage != nil {
+		digest, err := pinnedBaseImageDigest(cfg)
+		if err != nil {
+			return buildAndPushResult{}, err
+		}
+		baseImage = repoURI + "@" + digest
+		sourceHash = baseImageSourceHash(sourceHash, digest)
+	}
+
+	cmdSourceHash := sourceHash
+	if mode == buildModeKo {
+		cmdSourceHash = koSourceHash(sourceHash)
 	}
 
+	images := make([]backendImage, 0, len(cmdNames)+len(cfg.Inner.Components))
 	for _, cmdName := range cmdNames {
-		writeOutputf(opts.Output, "\nBuilding %s...\n", cmdName)
-
-		tag, err := buildAndPushImage(ctx, backendExec, buildImageOptions{
-			CmdName:    cmdName,
-			Deployment: opts.Deployment,
-			RepoURI:    repoURI,
-			RepoName:   repoName,
-			Platform:   opts.Platform,
-			Profile:    profile,
-			Region:     region,
-			SourceHash: sourceHash,
+		images = append(images, backendImage{
+			Name: cmdName, Exec: backendExec, Dockerfile: "Dockerfile", SourceHash: cmdSourceHash,
+			BaseImage: baseImage, Ko: mode == buildModeKo,
 		})
+	}
+
+	for _, component := range cfg.Inner.Components {
+		if slices.Contains(cmdNames, component.Name) {
+			return buildAndPushResult{}, errors.Errorf("component %q clashes with backend/cmd/%s",
+				component.Name, component.Name)
+		}
+
+		componentHash, err := componentSourceHash(ctx, exec, component)
 		if err != nil {
-			return errors.Wrapf(err, "failed to build and push %s", cmdName)
+			return buildAndPushResult{}, errors.Wrapf(err, "failed to compute source hash of component %s",
+				component.Name)
 		}
 
-		writeOutputf(opts.Output, "Pushed %s:%s\n", repoURI, tag)
+		images = append(images, backendImage{
+			Name:       component.Name,
+			Exec:       exec.InSubdir(component.Context),
+			Dockerfile: component.DockerfileName(),
+			SourceHash: componentHash,
+		})
 	}
 
-	return nil
+	// Each build writes its output through its own writers when builds run at the same time, so
+	// that every line is prefixed with the image it belongs to.
+	parallel := max(opts.Parallel, 1)
+	var outputMu sync.Mutex
+	pushed := make([]pushedImageResult, len(images))
+	group, groupCtx := errgroup.WithContext(ctx)
+	group.SetLimit(parallel)
+	for i, image := range images {
+		group.Go(func() error {
+			output, imageExec := opts.Output, image.Exec
+			if parallel > 1 {
+				out := newPrefixWriter(&outputMu, opts.Output, image.Name)
+				errOut := newPrefixWriter(&outputMu, opts.ErrOut, image.Name)
+				defer out.Flush()
+				defer errOut.Flush()
+				output, imageExec = out, image.Exec.WithOutput(out, errOut)
+			}
+
+			if image.Ko {
+				writeOutputf(output, "\nBuilding %s with ko...\n", image.Name)
+			} else {
+				writeOutputf(output, "\nBuilding %s with %s...\n", image.Name, builder)
+			}
+			result, err := buildAndPushImage(groupCtx, imageExec, buildImageOptions{
+				CmdName:    image.Name,
+				Dockerfile: image.Dockerfile,
+				Deployment: opts.Deployment,
+				RepoURI:    repoURI,
+				RepoName:   repoName,
+				Platform:   opts.Platform,
+				Builder:    builder,
+				ECR:        repo.AWS.ECR,
+				SourceHash: image.SourceHash,
+				BaseImage:  image.BaseImage,
+				Ko:         image.Ko,
+			})
+			if err != nil {
+				return errors.Wrapf(err, "failed to build and push %s", image.Name)
+			}
+
+			pushed[i] = result
+			if result.Existed {
+				writeOutputf(output, "Pushed %s:%s (already exists)\n", repoURI, result.Tag)
+			} else {
+				writeOutputf(output, "Pushed %s:%s\n", repoURI, result.Tag)
+			}
+			return nil
+		})
+	}
+	if err := group.Wait(); err != nil {
+		return buildAndPushResult{}, err
+	}
+
+	tags := make(map[string]string, len(pushed))
+	digests := make(map[string]string, len(pushed))
+	for _, image := range pushed {
+		tags[image.Name] = image.Tag
+		if image.Digest != "" {
+			digests[image.Name] = image.Digest
+		}
+	}
+	res := buildAndPushResult{Deployment: opts.Deployment, Repository: repoURI, Images: pushed}
+	writePushSummary(opts.Output, pushed)
+
+	if err := cdkcontext.UpdateImages(cfg.CDKDir(), opts.Deployment, tags, digests); err != nil {
+		return buildAndPushResult{}, err
+	}
+
+	writeOutputf(opts.Output, "\nUpdated cdk.context.json: image-tags and image-digests for %s\n", opts.Deployment)
+
+	return res, nil
+}
+
+// backendImage is an image built by build-and-push: either a Go command in backend/cmd or a
+// component declared in .ago.yml. Only the Go commands are built on the pinned base image, and
+// with ko in the ko build mode.
+type backendImage struct {
+	Name       string
+	Exec       cmdexec.Executor
+	Dockerfile string
+	SourceHash string
+	BaseImage  string
+	Ko         bool
+}
+
+// componentSourceHash computes the source hash of a component with its hash strategy.
+func componentSourceHash(ctx context.Context, exec cmdexec.Executor, component config.Component) (string, error) {
+	if component.HashStrategy() == config.HashStrategyGit {
+		tree, err := exec.Output(ctx, "git", "rev-parse", "HEAD:"+filepath.ToSlash(component.Context))
+		if err != nil {
+			return "", err
+		}
+		return tree[:min(len(tree), 12)], nil
+	}
+
+	h := dirhash.New(dirhash.WithAlwaysInclude(component.DockerfileName(), ".dockerignore"))
+	return h.Hash(filepath.Join(exec.Dir(), component.Context), ".dockerignore")
+}
+
+// backendRepository identifies the ECR repository that holds the backend images.
+type backendRepository struct {
+	Profile string
+	Region  string
+	URI     string
+	AWS     *awsapi.Clients
+}
+
+// resolveBackendRepository resolves the profile, region and repository URI for backend
+// image commands, falling back to cdk.json and context values for anything not given.
+func resolveBackendRepository(
+	ctx context.Context, cfg config.Config, profile, region, stackName string,
+) (backendRepository, error) {
+	cdkContext, err := readCDKContext(cfg)
+	if err != nil {
+		return backendRepository{}, err
+	}
+
+	profile, err = resolveAWSProfile(profile, func() (string, error) { return getCDKProfile(cdkContext) })
+	if err != nil {
+		return backendRepository{}, err
+	}
+
+	region = resolveAWSRegion(region)
+	if region == "" {
+		if err := cdkContext.Require("primary-region"); err != nil {
+			return backendRepository{}, err
+		}
+		region = cdkContext.PrimaryRegion
+	}
+
+	if stackName == "" {
+		stackName = deriveSharedStackName(cdkContext, region)
+	}
+
+	clients, err := awsapi.New(ctx, profile, region)
+	if err != nil {
+		return backendRepository{}, err
+	}
+
+	repoURI, err := ops.StackOutput(ctx, clients, stackName, agcdkutil.RepositoryURIOutputKey)
+	if err != nil {
+		return backendRepository{}, errors.Wrap(err, "failed to get ECR repository URI from stack outputs")
+	}
+
+	return backendRepository{Profile: profile, Region: region, URI: repoURI, AWS: clients}, nil
 }
 
 type buildImageOptions struct {
 	CmdName    string
+	Dockerfile string
 	Deployment string
 	RepoURI    string
 	RepoName   string
 	Platform   string
-	Profile    string
-	Region     string
+	Builder    imageBuilder
+	ECR        awsapi.ECR
 	SourceHash string
+	// BaseImage is passed as the BASE_IMAGE build argument, or to ko as its base image, when set.
+	BaseImage string
+	// Ko builds the Go command with ko instead of the Dockerfile and the builder.
+	Ko bool
 }
 
-func buildAndPushImage(ctx context.Context, exec cmdexec.Executor, opts buildImageOptions) (string, error) {
+// buildAndPushImage builds and pushes the image unless its tag already exists in the
+// repository. It returns the tag and digest of the image, and whether it already existed.
+func buildAndPushImage(ctx context.Context, exec cmdexec.Executor, opts buildImageOptions) (pushedImageResult, error) {
 	tag := fmt.Sprintf("%s-%s-%s", opts.CmdName, opts.Deployment, opts.SourceHash)
 	fullImageRef := fmt.Sprintf("%s:%s", opts.RepoURI, tag)
+	image := pushedImageResult{Name: opts.CmdName, Tag: tag}
 
-	exists, err := ecrTagExists(ctx, exec, opts.Profile, opts.Region, opts.RepoName, tag)
+	digest, exists, err := lookupImageDigest(ctx, opts.ECR, opts.RepoName, tag)
 	if err != nil {
-		return "", errors.Wrap(err, "failed to check if tag exists")
+		return pushedImageResult{}, errors.Wrap(err, "failed to check if tag exists")
 	}
 
 	if exists {
-		return tag + " (already exists)", nil
+		image.Digest, image.Existed = digest, true
+		return image, nil
 	}
 
-	if err := exec.Mise(ctx, "depot", "build",
-		"--file", "Dockerfile",
-		"--build-arg", "CMD_NAME="+opts.CmdName,
-		"--platform", opts.Platform,
-		"--push",
-		"--tag", fullImageRef,
-		".",
-	); err != nil {
-		return "", errors.Wrap(err, "depot build failed")
+	if opts.Ko {
+		if image.Digest, err = koBuild(ctx, exec, opts, tag); err != nil {
+			return pushedImageResult{}, err
+		}
+		return image, nil
 	}
 
-	return tag, nil
+	if err := runBuildCommands(ctx, exec, imageBuildCommands(opts, fullImageRef)); err != nil {
+		return pushedImageResult{}, err
+	}
+	if !cmdexec.DryRun() {
+		if image.Digest, err = ecrImageDigest(ctx, opts.ECR, opts.RepoName, tag); err != nil {
+			return pushedImageResult{}, err
+		}
+	}
+	return image, nil
 }
 
 func extractRepoName(repoURI string) string {
@@ -222,66 +461,86 @@ func extractRepoName(repoURI string) string {
 	return repoURI
 }
 
-func ecrTagExists(ctx context.Context, exec cmdexec.Executor, profile, region, repoName, tag string) (bool, error) {
-	_, err := exec.MiseOutput(ctx, "aws", "ecr", "describe-images",
-		"--profile", profile,
-		"--region", region,
-		"--repository-name", repoName,
-		"--image-ids", fmt.Sprintf("imageTag=%s", tag),
-		"--query", "imageDetails[0].imageTags",
-		"--output", "text",
-	)
+// lookupImageDigest returns the digest of the image with the tag, and false when the repository
+// has no image with the tag.
+func lookupImageDigest(ctx context.Context, client awsapi.ECR, repoName, tag string) (string, bool, error) {
+	out, err := client.DescribeImages(ctx, &ecr.DescribeImagesInput{
+		RepositoryName: aws.String(repoName),
+		ImageIds:       []ecrtypes.ImageIdentifier{{ImageTag: aws.String(tag)}},
+	})
+	if awsapi.IsErrorCode(err, "ImageNotFoundException") {
+		return "", false, nil
+	}
 	if err != nil {
-		if strings.Contains(err.Error(), "ImageNotFoundException") {
-			return false, nil
-		}
-		return false, nil
+		return "", false, err
+	}
+	if len(out.ImageDetails) == 0 {
+		return "", false, nil
 	}
-	return true, nil
+	return aws.ToString(out.ImageDetails[0].ImageDigest), true, nil
 }
 
-func loginToECR(ctx context.Context, exec cmdexec.Executor, profile, region string) error {
-	password, err := exec.MiseOutput(ctx, "aws", "ecr", "get-login-password",
-		"--profile", profile,
-		"--region", region,
-	)
+// loginToECR logs the container client, docker, podman or ko, in to the registry of the clients'
+// account and region.
+func loginToECR(ctx context.Context, exec cmdexec.Executor, clients *awsapi.Clients, client string) error {
+	out, err := clients.ECR.GetAuthorizationToken(ctx, &ecr.GetAuthorizationTokenInput{})
 	if err != nil {
-		return errors.Wrap(err, "failed to get ECR login password")
+		return errors.Wrap(err, "failed to get ECR authorization token")
+	}
+	if len(out.AuthorizationData) == 0 {
+		return errors.New("ECR returned no authorization data")
 	}
 
-	accountID, err := getAWSAccountID(ctx, exec, profile)
+	auth := out.AuthorizationData[0]
+	token, err := base64.StdEncoding.DecodeString(aws.ToString(auth.AuthorizationToken))
 	if err != nil {
-		return err
+		return errors.Wrap(err, "failed to decode ECR authorization token")
 	}
+	username, password, ok := strings.Cut(string(token), ":")
+	if !ok {
+		return errors.New("unexpected ECR authorization token format")
+	}
+	cmdexec.RegisterSecret(aws.ToString(auth.AuthorizationToken), password)
 
-	registryURL := fmt.Sprintf("%s.dkr.ecr.%s.amazonaws.com", accountID, region)
-
-	if err := exec.RunWithStdin(ctx, strings.NewReader(password), "docker", "login",
-		"--username", "AWS",
-		"--password-stdin",
-		registryURL,
-	); err != nil {
-		return errors.Wrap(err, "docker login to ECR failed")
+	registryURL := strings.TrimPrefix(aws.ToString(auth.ProxyEndpoint), "https://")
+	name, args := client, []string{"login"}
+	if client == "ko" {
+		// mise manages ko, so it is not necessarily on the PATH.
+		name, args = "mise", []string{"exec", "--", "ko", "login"}
+	}
+	args = append(args, "--username", username, "--password-stdin", registryURL)
+	if err := exec.RunWithStdin(ctx, strings.NewReader(password), name, args...); err != nil {
+		return errors.Wrapf(err, "%s login to ECR failed", client)
 	}
 
 	return nil
 }
 
-func getAWSAccountID(ctx context.Context, exec cmdexec.Executor, profile string) (string, error) {
-	output, err := exec.MiseOutput(ctx, "aws", "sts", "get-caller-identity",
-		"--profile", profile,
-		"--query", "Account",
-		"--output", "text",
-	)
+// getAWSPartition returns the partition (e.g. "aws", "aws-us-gov", "aws-cn") of the
+// caller's identity, for constructing ARNs when no region is at hand.
+func getAWSPartition(ctx context.Context, clients *awsapi.Clients) (string, error) {
+	arn, err := ops.CallerARN(ctx, clients)
 	if err != nil {
-		return "", errors.Wrap(err, "failed to get AWS account ID")
+		return "", err
 	}
 
-	return strings.TrimSpace(output), nil
+	parts := strings.SplitN(arn, ":", 3)
+	if len(parts) < 3 || parts[0] != "arn" {
+		return "", errors.Errorf("unexpected ARN format: %s", arn)
+	}
+
+	return parts[1], nil
+}
+
+// backendHashResult is the result of 'ago backend hash'. Changes is set with --explain.
+type backendHashResult struct {
+	Hash     string                `json:"hash"`
+	Previous string                `json:"previous,omitempty"`
+	Changes  *dirhash.ManifestDiff `json:"changes,omitempty"`
 }
 
 func runBackendHash(_ context.Context, cmd *cli.Command, cfg config.Config) error {
-	backendDir := filepath.Join(cfg.ProjectDir, "backend")
+	backendDir := cfg.BackendDir()
 
 	opts := []dirhash.Option{
 		dirhash.WithAlwaysInclude("Dockerfile", ".dockerignore"),
@@ -290,13 +549,72 @@ func runBackendHash(_ context.Context, cmd *cli.Command, cfg config.Config) erro
 	if cmd.Bool("debug") {
 		opts = append(opts, dirhash.WithLogger(&dirhash.DebugLogger{W: os.Stderr}))
 	}
+	if !cmd.Bool("no-cache") {
+		cacheDir, err := dirhashCacheDir()
+		if err != nil {
+			return err
+		}
+		opts = append(opts, dirhash.WithCache(cacheDir))
+	}
 
 	h := dirhash.New(opts...)
-	hash, err := h.Hash(backendDir, ".dockerignore")
+	manifest, err := h.Manifest(backendDir, ".dockerignore")
 	if err != nil {
 		return err
 	}
 
-	fmt.Fprintln(os.Stdout, hash)
+	progress, result := commandOutput(cmd)
+	return doBackendHash(progress, result, manifest, cmd.String("manifest"), cmd.String("explain"))
+}
+
+// doBackendHash prints the hash of the manifest, writes the manifest to manifestPath, and explains
+// the hash by the files that changed since the manifest at explainPath. Empty paths skip either.
+func doBackendHash(progress, result io.Writer, manifest dirhash.Manifest, manifestPath, explainPath string) error {
+	res := backendHashResult{Hash: manifest.Hash}
+	if explainPath != "" {
+		previous, err := dirhash.ReadManifest(explainPath)
+		if err != nil {
+			return err
+		}
+		diff := manifest.Diff(previous)
+		res.Previous, res.Changes = previous.Hash, &diff
+	}
+	if manifestPath != "" {
+		if err := dirhash.WriteManifest(manifestPath, manifest); err != nil {
+			return err
+		}
+	}
+
+	if result != nil {
+		return writeResult(result, res)
+	}
+
+	writeOutputf(progress, "%s\n", res.Hash)
+	if res.Changes == nil {
+		return nil
+	}
+	if res.Changes.Empty() {
+		writeOutputf(progress, "No files changed since %s\n", res.Previous)
+		return nil
+	}
+	writeOutputf(progress, "Changed since %s:\n", res.Previous)
+	for _, change := range []struct {
+		mark  string
+		files []string
+	}{{"+", res.Changes.Added}, {"-", res.Changes.Removed}, {"~", res.Changes.Modified}} {
+		for _, file := range change.files {
+			writeOutputf(progress, "  %s %s\n", change.mark, file)
+		}
+	}
 	return nil
 }
+
+// dirhashCacheDir returns the directory of the file digests that 'ago backend hash' reuses for
+// files that did not change.
+func dirhashCacheDir() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", errors.Wrap(err, "failed to determine user cache directory")
+	}
+	return filepath.Join(dir, "ago", "dirhash"), nil
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/advdv/ago/cmd/ago/internal/awsapi"
+	"github.com/advdv/ago/cmd/ago/internal/cdkcontext"
+	"github.com/advdv/ago/cmd/ago/internal/cmdexec"
+	"github.com/advdv/ago/cmd/ago/internal/config"
+	"github.com/advdv/ago/cmd/ago/internal/dirhash"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecr"
+	ecrtypes "github.com/aws/aws-sdk-go-v2/service/ecr/types"
+	"github.com/cockroachdb/errors"
+	"github.com/urfave/cli/v3"
+)
+
+// baseImageContextKey is the context key, under the project prefix, that pins the digest of the
+// base image.
+const baseImageContextKey = "base-image"
+
+func backendBumpBaseCmd() *cli.Command {
+	return &cli.Command{
+		Name:  "bump-base",
+		Usage: "Build and push the base image declared in .ago.yml and pin its digest in cdk.context.json",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:  "stack-name",
+				Usage: "CloudFormation stack name containing the ECR repository (defaults to {qualifier}-Shared-{region-ident})",
+			},
+			&cli.StringFlag{
+				Name:  "platform",
+				Usage: "Target platform for the build",
+				Value: "linux/arm64",
+			},
+			builderFlag(),
+			&cli.BoolFlag{
+				Name:  "rebuild",
+				Usage: "Rebuild and push the backend images of every deployment with recorded image tags on the new base",
+			},
+		},
+		Action: config.RunWithConfig(runBackendBumpBase),
+	}
+}
+
+type backendBumpBaseOptions struct {
+	Profile   string
+	Region    string
+	StackName string
+	Platform  string
+	Builder   string
+	Rebuild   bool
+	Output    io.Writer
+	ErrOut    io.Writer
+}
+
+func runBackendBumpBase(ctx context.Context, cmd *cli.Command, cfg config.Config) error {
+	return doBackendBumpBase(ctx, cfg, backendBumpBaseOptions{
+		Profile:   cmd.String("profile"),
+		Region:    cmd.String("region"),
+		StackName: cmd.String("stack-name"),
+		Platform:  cmd.String("platform"),
+		Builder:   cmd.String("builder"),
+		Rebuild:   cmd.Bool("rebuild"),
+		Output:    os.Stdout,
+		ErrOut:    os.Stderr,
+	})
+}
+
+func doBackendBumpBase(ctx context.Context, cfg config.Config, opts backendBumpBaseOptions) error {
+	base := cfg.Inner.BaseImage
+	if base == nil {
+		return errors.New("no base_image declared in .ago.yml")
+	}
+
+	builder, err := resolveImageBuilder(ctx, cfg, opts.Builder)
+	if err != nil {
+		return err
+	}
+
+	exec := cmdexec.New(cfg).WithOutput(opts.Output, opts.ErrOut)
+
+	repo, err := resolveBackendRepository(ctx, cfg, opts.Profile, opts.Region, opts.StackName)
+	if err != nil {
+		return err
+	}
+	repoName := extractRepoName(repo.URI)
+
+	if err := loginToECR(ctx, exec, repo.AWS, builder.loginClient()); err != nil {
+		return err
+	}
+
+	h := dirhash.New(dirhash.WithAlwaysInclude(base.DockerfileName(), ".dockerignore"))
+	sourceHash, err := h.Hash(filepath.Join(cfg.ProjectDir, base.Context), ".dockerignore")
+	if err != nil {
+		return errors.Wrap(err, "failed to compute base image source hash")
+	}
+
+	writeOutputf(opts.Output, "Building base image with %s...\n", builder)
+	image, err := buildAndPushImage(ctx, exec.InSubdir(base.Context), buildImageOptions{
+		CmdName:    "base",
+		Dockerfile: base.DockerfileName(),
+		Deployment: "shared",
+		RepoURI:    repo.URI,
+		RepoName:   repoName,
+		Platform:   opts.Platform,
+		Builder:    builder,
+		ECR:        repo.AWS.ECR,
+		SourceHash: sourceHash,
+	})
+	if err != nil {
+		return errors.Wrap(err, "failed to build and push base image")
+	}
+	if image.Existed {
+		writeOutputf(opts.Output, "Pushed %s:%s (already exists)\n", repo.URI, image.Tag)
+	} else {
+		writeOutputf(opts.Output, "Pushed %s:%s\n", repo.URI, image.Tag)
+	}
+
+	digest := image.Digest
+
+	previous, _ := pinnedBaseImageDigest(cfg)
+	if previous == digest {
+		writeOutputf(opts.Output, "Base image is unchanged: %s\n", digest)
+	} else {
+		if err := cdkcontext.Update(cfg.CDKDir(), func(context map[string]any, prefix string) error {
+			context[prefix+baseImageContextKey] = digest
+			return nil
+		}); err != nil {
+			return err
+		}
+		writeOutputf(opts.Output, "Pinned base image %s in cdk.context.json\n", digest)
+	}
+
+	// The repository replicates to the secondary regions, so the digest is valid there too once
+	// replication has caught up.
+	cdkCtx, err := readCDKContext(cfg)
+	if err != nil {
+		return err
+	}
+	for _, region := range cdkCtx.SecondaryRegions {
+		clients, err := awsapi.New(ctx, repo.Profile, region)
+		if err != nil {
+			return err
+		}
+		if _, err := ecrImageDigest(ctx, clients.ECR, repoName, image.Tag); err != nil {
+			writeOutputf(opts.Output, "  %s: not replicated yet, this can take a few minutes\n", region)
+			continue
+		}
+		writeOutputf(opts.Output, "  %s: replicated\n", region)
+	}
+
+	deployments := slices.Sorted(maps.Keys(cdkCtx.ImageTags))
+
+	if !opts.Rebuild {
+		if len(deployments) > 0 && previous != digest {
+			writeOutputf(opts.Output, "\nRebuild the backend images on the new base with 'ago backend build-and-push' "+
+				"for: %s (or rerun with --rebuild)\n", strings.Join(deployments, ", "))
+		}
+		return nil
+	}
+
+	for _, deployment := range deployments {
+		writeOutputf(opts.Output, "\nRebuilding backend images of %s...\n", deployment)
+		if err := doBackendBuildAndPush(ctx, cfg, backendBuildAndPushOptions{
+			Deployment: deployment,
+			Profile:    opts.Profile,
+			Region:     opts.Region,
+			StackName:  opts.StackName,
+			Platform:   opts.Platform,
+			Builder:    string(builder),
+			Output:     opts.Output,
+			ErrOut:     opts.ErrOut,
+		}); err != nil {
+			return errors.Wrapf(err, "failed to rebuild backend images of %s", deployment)
+		}
+	}
+
+	return nil
+}
+
+// pinnedBaseImageDigest returns the base image digest that 'ago backend bump-base' pinned.
+func pinnedBaseImageDigest(cfg config.Config) (string, error) {
+	cdkCtx, err := readCDKContext(cfg)
+	if err != nil {
+		return "", err
+	}
+
+	digest := cdkCtx.String(baseImageContextKey)
+	if digest == "" {
+		return "", errors.New("no base image pinned yet, run 'ago backend bump-base' first")
+	}
+	return digest, nil
+}
+
+// baseImageSourceHash folds the base image digest into the source hash of an image, so that
+// bumping the base changes the tags of every image built on it.
+func baseImageSourceHash(sourceHash, digest string) string {
+	sum := sha256.Sum256([]byte(sourceHash + "\n" + digest))
+	return hex.EncodeToString(sum[:])[:len(sourceHash)]
+}
+
+func ecrImageDigest(ctx context.Context, client awsapi.ECR, repoName, tag string) (string, error) {
+	out, err := client.DescribeImages(ctx, &ecr.DescribeImagesInput{
+		RepositoryName: aws.String(repoName),
+		ImageIds:       []ecrtypes.ImageIdentifier{{ImageTag: aws.String(tag)}},
+	})
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to get digest of %s:%s", repoName, tag)
+	}
+	if len(out.ImageDetails) == 0 {
+		return "", errors.Errorf("image %s:%s not found", repoName, tag)
+	}
+	return aws.ToString(out.ImageDetails[0].ImageDigest), nil
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestBaseImageSourceHash(t *testing.T) {
+	t.Parallel()
+
+	source := "0123456789abcdef"
+	a := baseImageSourceHash(source, "sha256:aaa")
+	b := baseImageSourceHash(source, "sha256:bbb")
+
+	if len(a) != len(source) {
+		t.Errorf("expected hash of length %d, got %q", len(source), a)
+	}
+	if a == b {
+		t.Error("expected different base images to give different hashes")
+	}
+	if a != baseImageSourceHash(source, "sha256:aaa") {
+		t.Error("expected the hash to be deterministic")
+	}
+}
+
+func TestRenderBackendDockerfileBaseImage(t *testing.T) {
+	t.Parallel()
+
+	rendered, err := renderBackendDockerfile(BackendConfig{
+		GoVersion: "1.25.5", RuntimeImage: defaultBackendRuntimeImage, BaseImage: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range []string{
+		"ARG BASE_IMAGE=" + defaultBackendRuntimeImage + "\nFROM golang:1.25.5 AS build",
+		"FROM ${BASE_IMAGE} AS runtime",
+	} {
+		if !strings.Contains(string(rendered), want) {
+			t.Errorf("Dockerfile does not contain %q:\n%s", want, rendered)
+		}
+	}
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"context"
+	"slices"
+	"strings"
+
+	"github.com/advdv/ago/cmd/ago/internal/cmdexec"
+	"github.com/advdv/ago/cmd/ago/internal/config"
+	"github.com/cockroachdb/errors"
+	"github.com/urfave/cli/v3"
+)
+
+// imageBuilder is the tool that builds and pushes the backend images.
+type imageBuilder string
+
+const (
+	// builderDepot builds on depot's remote builders, which keep the layer cache between runs and
+	// machines. It needs a depot subscription.
+	builderDepot imageBuilder = "depot"
+	// builderDocker builds locally with docker buildx.
+	builderDocker imageBuilder = "docker"
+	// builderPodman builds locally with podman.
+	builderPodman imageBuilder = "podman"
+)
+
+// imageBuilders lists the builders in the order that autodetection prefers them.
+var imageBuilders = []imageBuilder{builderDepot, builderDocker, builderPodman}
+
+// builderFlag is the --builder flag of the commands that build backend images.
+func builderFlag() cli.Flag {
+	names := make([]string, len(imageBuilders))
+	for i, b := range imageBuilders {
+		names[i] = string(b)
+	}
+	return &cli.StringFlag{
+		Name: "builder",
+		Usage: "Tool that builds and pushes the images: " + strings.Join(names, ", ") +
+			" (default: the first of them that can run)",
+	}
+}
+
+// tools returns the tools that the builder runs. Depot pushes with the credentials of the docker
+// CLI, so it needs docker to log in to ECR.
+func (b imageBuilder) tools() []toolRequirement {
+	docker := toolRequirement{Binary: "docker", Install: "install Docker Desktop or Docker Engine"}
+	switch b {
+	case builderDepot:
+		return []toolRequirement{{Binary: "depot", Mise: "depot"}, docker}
+	case builderPodman:
+		return []toolRequirement{{Binary: "podman", Install: "install Podman"}}
+	default:
+		return []toolRequirement{docker}
+	}
+}
+
+// loginClient returns the container CLI whose credentials the builder pushes with.
+func (b imageBuilder) loginClient() string {
+	if b == builderPodman {
+		return "podman"
+	}
+	return "docker"
+}
+
+// selectImageBuilder returns the builder named by the flag, or the first builder without tool
+// problems when the flag is empty. The error lists the problems of every builder when none can
+// run, so that the fix for the preferred one is at hand.
+func selectImageBuilder(flag string, problems func(imageBuilder) []toolProblem) (imageBuilder, error) {
+	if flag != "" {
+		builder := imageBuilder(flag)
+		if !slices.Contains(imageBuilders, builder) {
+			return "", errors.Errorf("unknown builder %q, expected one of: depot, docker, podman", flag)
+		}
+		if found := problems(builder); len(found) > 0 {
+			return "", toolProblemsError("build-and-push --builder "+flag, found)
+		}
+		return builder, nil
+	}
+
+	var all []toolProblem
+	for _, builder := range imageBuilders {
+		found := problems(builder)
+		if len(found) == 0 {
+			return builder, nil
+		}
+		all = append(all, found...)
+	}
+	return "", errors.Wrap(toolProblemsError("build-and-push", all),
+		"no image builder can run, install one of depot, docker or podman")
+}
+
+// resolveImageBuilder returns the builder to build the backend images with. A docker without the
+// buildx plugin is passed over, since the images are built with 'docker buildx build'.
+func resolveImageBuilder(ctx context.Context, cfg config.Config, flag string) (imageBuilder, error) {
+	declared, err := readMiseTools(cfg.ProjectDir)
+	if err != nil {
+		return "", err
+	}
+
+	exec := cmdexec.New(cfg)
+	return selectImageBuilder(flag, func(builder imageBuilder) []toolProblem {
+		problems := findToolProblems(ctx, exec, declared, builder.tools())
+		if builder == builderDocker && len(problems) == 0 {
+			if _, err := exec.Output(ctx, "docker", "buildx", "version"); err != nil {
+				problems = append(problems, toolProblem{
+					Tool:    "docker buildx",
+					Problem: "plugin not installed",
+					Fix:     "install the docker buildx plugin",
+				})
+			}
+		}
+		return problems
+	})
+}
+
+// buildCommand is a command that builds or pushes an image.
+type buildCommand struct {
+	// Mise runs the command through mise, for builders that mise manages.
+	Mise bool
+	Name string
+	Args []string
+}
+
+// imageBuildCommands returns the commands with which the builder of the options builds the image
+// and pushes it under the reference. Lambda rejects image indexes, so docker buildx is kept from attaching
+// provenance attestations, which depot does not attach by default.
+func imageBuildCommands(opts buildImageOptions, imageRef string) []buildCommand {
+	args := []string{
+		"--file", opts.Dockerfile,
+		"--build-arg", "CMD_NAME=" + opts.CmdName,
+	}
+	if opts.BaseImage != "" {
+		args = append(args, "--build-arg", "BASE_IMAGE="+opts.BaseImage)
+	}
+	args = append(args, "--platform", opts.Platform)
+
+	switch opts.Builder {
+	case builderDocker:
+		args = append([]string{"buildx", "build"}, args...)
+		args = append(args, "--provenance=false", "--push", "--tag", imageRef, ".")
+		return []buildCommand{{Name: "docker", Args: args}}
+	case builderPodman:
+		args = append([]string{"build"}, args...)
+		args = append(args, "--tag", imageRef, ".")
+		return []buildCommand{
+			{Name: "podman", Args: args},
+			{Name: "podman", Args: []string{"push", imageRef}},
+		}
+	default:
+		args = append([]string{"build"}, args...)
+		args = append(args, "--push", "--tag", imageRef, ".")
+		return []buildCommand{{Mise: true, Name: "depot", Args: args}}
+	}
+}
+
+// runBuildCommands runs the commands in order and stops at the first that fails.
+func runBuildCommands(ctx context.Context, exec cmdexec.Executor, commands []buildCommand) error {
+	for _, c := range commands {
+		run := exec.Run
+		if c.Mise {
+			run = exec.Mise
+		}
+		if err := run(ctx, c.Name, c.Args...); err != nil {
+			return errors.Wrapf(err, "%s %s failed", c.Name, c.Args[0])
+		}
+	}
+	return nil
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestSelectImageBuilder(t *testing.T) {
+	t.Parallel()
+
+	missing := func(unavailable ...imageBuilder) func(imageBuilder) []toolProblem {
+		return func(b imageBuilder) []toolProblem {
+			if slices.Contains(unavailable, b) {
+				return []toolProblem{{Tool: string(b), Problem: "not found on the PATH", Fix: "install it"}}
+			}
+			return nil
+		}
+	}
+
+	for _, tc := range []struct {
+		name    string
+		flag    string
+		missing []imageBuilder
+		want    imageBuilder
+		wantErr string
+	}{
+		{name: "prefers depot", want: builderDepot},
+		{name: "falls back to docker", missing: []imageBuilder{builderDepot}, want: builderDocker},
+		{
+			name:    "falls back to podman",
+			missing: []imageBuilder{builderDepot, builderDocker},
+			want:    builderPodman,
+		},
+		{
+			name:    "none can run",
+			missing: []imageBuilder{builderDepot, builderDocker, builderPodman},
+			wantErr: "no image builder can run",
+		},
+		{name: "explicit", flag: "podman", want: builderPodman},
+		{
+			name:    "explicit cannot run",
+			flag:    "depot",
+			missing: []imageBuilder{builderDepot},
+			wantErr: "'ago build-and-push --builder depot' needs tools that cannot run",
+		},
+		{name: "unknown", flag: "kaniko", wantErr: `unknown builder "kaniko"`},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := selectImageBuilder(tc.flag, missing(tc.missing...))
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Fatalf("expected error containing %q, got %v", tc.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tc.want {
+				t.Errorf("expected %s, got %s", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestImageBuildCommands(t *testing.T) {
+	t.Parallel()
+
+	opts := buildImageOptions{
+		CmdName:    "coreapi",
+		Dockerfile: "Dockerfile",
+		Platform:   "linux/arm64",
+		BaseImage:  "repo@sha256:abc",
+	}
+	ref := "123.dkr.ecr.eu-west-1.amazonaws.com/app:coreapi-dev-abc"
+	common := []string{
+		"--file", "Dockerfile",
+		"--build-arg", "CMD_NAME=coreapi",
+		"--build-arg", "BASE_IMAGE=repo@sha256:abc",
+		"--platform", "linux/arm64",
+	}
+
+	for _, tc := range []struct {
+		builder imageBuilder
+		want    []buildCommand
+	}{
+		{builderDepot, []buildCommand{{
+			Mise: true, Name: "depot",
+			Args: slices.Concat([]string{"build"}, common, []string{"--push", "--tag", ref, "."}),
+		}}},
+		{builderDocker, []buildCommand{{
+			Name: "docker",
+			Args: slices.Concat([]string{"buildx", "build"}, common,
+				[]string{"--provenance=false", "--push", "--tag", ref, "."}),
+		}}},
+		{builderPodman, []buildCommand{
+			{Name: "podman", Args: slices.Concat([]string{"build"}, common, []string{"--tag", ref, "."})},
+			{Name: "podman", Args: []string{"push", ref}},
+		}},
+	} {
+		opts.Builder = tc.builder
+		got := imageBuildCommands(opts, ref)
+		if !slices.EqualFunc(got, tc.want, func(a, b buildCommand) bool {
+			return a.Mise == b.Mise && a.Name == b.Name && slices.Equal(a.Args, b.Args)
+		}) {
+			t.Errorf("%s: expected %+v, got %+v", tc.builder, tc.want, got)
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"context"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/advdv/ago/cmd/ago/internal/config"
+	"github.com/urfave/cli/v3"
+)
+
+func backendDeployCmd() *cli.Command {
+	return &cli.Command{
+		Name: "deploy",
+		Usage: "Build and push the backend images of a deployment, record their tags in cdk.context.json and " +
+			"deploy the stacks of the deployment when an image changed",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:  "deployment",
+				Usage: "Deployment to push and deploy the images for (default: the caller's Dev deployment)",
+			},
+			&cli.StringFlag{
+				Name:  "platform",
+				Usage: "Target platform for the build",
+				Value: "linux/arm64",
+			},
+			modeFlag(),
+			builderFlag(),
+			parallelFlag(),
+			&cli.BoolFlag{
+				Name:  "force",
+				Usage: "Deploy even when no image changed",
+			},
+			&cli.BoolFlag{
+				Name:  "hotswap",
+				Usage: "Enable CDK hotswap, which updates the image of a Lambda function without CloudFormation",
+			},
+			&cli.BoolFlag{
+				Name:  "yes",
+				Usage: "Deploy restricted deployments without showing a cost estimate and asking for confirmation",
+			},
+		},
+		Action: config.RunWithConfig(runBackendDeploy),
+	}
+}
+
+type backendDeployOptions struct {
+	Deployment  string
+	Platform    string
+	Mode        string
+	Builder     string
+	Parallel    int
+	Force       bool
+	Hotswap     bool
+	Yes         bool
+	Acknowledge []string
+	Profile     string
+	Region      string
+	Output      io.Writer
+	ErrOut      io.Writer
+	Result      io.Writer
+}
+
+func runBackendDeploy(ctx context.Context, cmd *cli.Command, cfg config.Config) error {
+	output, result := commandOutput(cmd)
+	return doBackendDeploy(ctx, cfg, backendDeployOptions{
+		Deployment:  cmd.String("deployment"),
+		Platform:    cmd.String("platform"),
+		Mode:        cmd.String("mode"),
+		Builder:     cmd.String("builder"),
+		Parallel:    cmd.Int("parallel"),
+		Force:       cmd.Bool("force"),
+		Hotswap:     cmd.Bool("hotswap"),
+		Yes:         cmd.Bool("yes"),
+		Acknowledge: cmd.StringSlice("acknowledge"),
+		Profile:     cmd.String("profile"),
+		Region:      cmd.String("region"),
+		Output:      output,
+		ErrOut:      os.Stderr,
+		Result:      result,
+	})
+}
+
+// backendDeployResult is the result of 'ago backend deploy' in the JSON output format.
+type backendDeployResult struct {
+	buildAndPushResult
+
+	Changed  []string `json:"changed"`
+	Deployed bool     `json:"deployed"`
+}
+
+func doBackendDeploy(ctx context.Context, cfg config.Config, opts backendDeployOptions) error {
+	cdk, err := loadCDKContext(cfg)
+	if err != nil {
+		return err
+	}
+
+	username, usernameErr := resolveCDKCallerUsername(ctx, opts.Profile, cdk.Context)
+	deployment, err := resolveDeploymentIdent(cfg.Inner, cdkCommandOptions{Deployment: opts.Deployment},
+		cdk.Context, username, usernameErr)
+	if err != nil {
+		return err
+	}
+
+	previous := cdk.ImageTags[deployment]
+	pushed, err := buildAndPush(ctx, cfg, backendBuildAndPushOptions{
+		Deployment: deployment,
+		Profile:    opts.Profile,
+		Region:     opts.Region,
+		Platform:   opts.Platform,
+		Mode:       opts.Mode,
+		Builder:    opts.Builder,
+		Parallel:   opts.Parallel,
+		Output:     opts.Output,
+		ErrOut:     opts.ErrOut,
+	})
+	if err != nil {
+		return err
+	}
+
+	res := backendDeployResult{buildAndPushResult: pushed, Changed: changedImages(previous, pushed.Images)}
+	if len(res.Changed) == 0 && !opts.Force {
+		writeOutputf(opts.Output, "\nNo image of %s changed, nothing to deploy (deploy anyway with --force)\n",
+			deployment)
+		return writeResult(opts.Result, res)
+	}
+
+	if len(res.Changed) > 0 {
+		writeOutputf(opts.Output, "\nDeploying %s with the new images of %s...\n", deployment,
+			strings.Join(res.Changed, ", "))
+	} else {
+		writeOutputf(opts.Output, "\nDeploying %s...\n", deployment)
+	}
+	if err := withNotification(ctx, cfg, opts.Output, "deploy", deployment, func() error {
+		return doDeploy(ctx, cfg, cdkCommandOptions{
+			Deployment:     deployment,
+			Profile:        opts.Profile,
+			Region:         opts.Region,
+			DeploymentOnly: true,
+			Hotswap:        opts.Hotswap,
+			Yes:            opts.Yes,
+			Acknowledge:    opts.Acknowledge,
+			Output:         opts.Output,
+		})
+	}); err != nil {
+		return err
+	}
+
+	res.Deployed = true
+	return writeResult(opts.Result, res)
+}
+
+// changedImages returns the names of the pushed images whose tag differs from the recorded one,
+// in the order they were pushed. An image without a recorded tag is new, and so changed.
+func changedImages(previous map[string]string, pushed []pushedImageResult) []string {
+	changed := []string{}
+	for _, image := range pushed {
+		if previous[image.Name] != image.Tag {
+			changed = append(changed, image.Name)
+		}
+	}
+	return changed
+}
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestChangedImages(t *testing.T) {
+	t.Parallel()
+
+	previous := map[string]string{"api": "abc123", "worker": "def456", "removed": "000000"}
+	pushed := []pushedImageResult{
+		{Name: "api", Tag: "abc123", Existed: true},
+		{Name: "worker", Tag: "fff999"},
+		{Name: "migrate", Tag: "aaa111"},
+	}
+
+	got := changedImages(previous, pushed)
+	if want := []string{"worker", "migrate"}; !slices.Equal(got, want) {
+		t.Errorf("changedImages() = %v, want %v", got, want)
+	}
+
+	if got := changedImages(map[string]string{"api": "abc123"}, pushed[:1]); len(got) != 0 {
+		t.Errorf("expected no changed images, got %v", got)
+	}
+}
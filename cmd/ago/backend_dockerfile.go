@@ -0,0 +1,183 @@
+package main
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/advdv/ago/cmd/ago/internal/cmdexec"
+	"github.com/advdv/ago/cmd/ago/internal/config"
+	"github.com/cockroachdb/errors"
+	"github.com/urfave/cli/v3"
+)
+
+func backendRegenDockerfileCmd() *cli.Command {
+	return &cli.Command{
+		Name:  "regen-dockerfile",
+		Usage: "Regenerate backend/Dockerfile from the ago template, showing the changes first",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:  "runtime-image",
+				Usage: "Base image of the runtime stage (use an image with a shell for 'ago backend shell')",
+				Value: defaultBackendRuntimeImage,
+			},
+		},
+		Action: config.RunWithConfig(runBackendRegenDockerfile),
+	}
+}
+
+type regenDockerfileOptions struct {
+	RuntimeImage string
+	DryRun       bool
+	Output       io.Writer
+}
+
+func runBackendRegenDockerfile(_ context.Context, cmd *cli.Command, cfg config.Config) error {
+	return doBackendRegenDockerfile(cfg, regenDockerfileOptions{
+		RuntimeImage: cmd.String("runtime-image"),
+		DryRun:       cmdexec.DryRun(),
+		Output:       os.Stdout,
+	})
+}
+
+func doBackendRegenDockerfile(cfg config.Config, opts regenDockerfileOptions) error {
+	backendDir := cfg.BackendDir()
+
+	goVersion, err := readGoVersion(filepath.Join(backendDir, "go.mod"))
+	if err != nil {
+		return err
+	}
+
+	rendered, err := renderBackendDockerfile(BackendConfig{
+		GoVersion:    goVersion,
+		RuntimeImage: cmp.Or(opts.RuntimeImage, defaultBackendRuntimeImage),
+		BaseImage:    cfg.Inner.BaseImage != nil,
+		BufVersion:   backendBufVersion(cfg),
+	})
+	if err != nil {
+		return err
+	}
+
+	dockerfilePath := filepath.Join(backendDir, "Dockerfile")
+	current, err := os.ReadFile(dockerfilePath)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return errors.Wrap(err, "failed to read backend Dockerfile")
+	}
+
+	if bytes.Equal(current, rendered) {
+		writeOutputf(opts.Output, "backend/Dockerfile is up to date.\n")
+		return nil
+	}
+
+	writeOutputf(opts.Output, "--- backend/Dockerfile (current)\n+++ backend/Dockerfile (template)\n")
+	for _, line := range lineDiff(string(current), string(rendered)) {
+		writeOutputf(opts.Output, "%s\n", line)
+	}
+
+	if opts.DryRun {
+		writeOutputf(opts.Output, "\nDry run: backend/Dockerfile not written.\n")
+		return nil
+	}
+
+	//nolint:gosec // config file needs to be readable
+	if err := cmdexec.WriteFile(dockerfilePath, rendered, 0o644); err != nil {
+		return errors.Wrap(err, "failed to write backend Dockerfile")
+	}
+
+	writeOutputf(opts.Output, "\nWrote backend/Dockerfile.\n")
+
+	return nil
+}
+
+func renderBackendDockerfile(cfg BackendConfig) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := backendDockerfileTemplate.Execute(&buf, cfg); err != nil {
+		return nil, errors.Wrap(err, "failed to execute backend Dockerfile template")
+	}
+	return buf.Bytes(), nil
+}
+
+// backendBufVersion returns the version of buf for the proto stage of the backend Dockerfile: the
+// version that mise.toml pins, or "latest". It is empty when the backend has no buf.gen.yaml.
+func backendBufVersion(cfg config.Config) string {
+	if _, err := os.Stat(filepath.Join(cfg.BackendDir(), "buf.gen.yaml")); err != nil {
+		return ""
+	}
+
+	tools, _ := readMiseTools(cfg.ProjectDir)
+	for _, tool := range tools {
+		if tool.Name == "buf" && pinnedVersionRegex.MatchString(tool.Version) {
+			return strings.TrimPrefix(tool.Version, "v")
+		}
+	}
+	return "latest"
+}
+
+// readGoVersion returns the version from the "go" directive of a go.mod file.
+func readGoVersion(goModPath string) (string, error) {
+	data, err := os.ReadFile(goModPath)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to read go.mod")
+	}
+
+	for line := range strings.SplitSeq(string(data), "\n") {
+		if version, ok := strings.CutPrefix(strings.TrimSpace(line), "go "); ok {
+			return strings.TrimSpace(version), nil
+		}
+	}
+
+	return "", errors.Newf("go directive not found in %s", goModPath)
+}
+
+// lineDiff returns the lines of a and b prefixed with " ", "-" or "+", based on their longest
+// common subsequence. It is meant for small files such as a Dockerfile.
+func lineDiff(a, b string) []string {
+	x := strings.Split(strings.TrimSuffix(a, "\n"), "\n")
+	y := strings.Split(strings.TrimSuffix(b, "\n"), "\n")
+	if a == "" {
+		x = nil
+	}
+
+	lcs := make([][]int, len(x)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(y)+1)
+	}
+	for i := len(x) - 1; i >= 0; i-- {
+		for j := len(y) - 1; j >= 0; j-- {
+			if x[i] == y[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var out []string
+	i, j := 0, 0
+	for i < len(x) && j < len(y) {
+		switch {
+		case x[i] == y[j]:
+			out = append(out, " "+x[i])
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			out = append(out, "-"+x[i])
+			i++
+		default:
+			out = append(out, "+"+y[j])
+			j++
+		}
+	}
+	for ; i < len(x); i++ {
+		out = append(out, "-"+x[i])
+	}
+	for ; j < len(y); j++ {
+		out = append(out, "+"+y[j])
+	}
+
+	return out
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/advdv/ago/cmd/ago/internal/config"
+)
+
+func TestLineDiff(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name string
+		a, b string
+		want []string
+	}{
+		{
+			name: "identical",
+			a:    "a\nb\n",
+			b:    "a\nb\n",
+			want: []string{" a", " b"},
+		},
+		{
+			name: "changed line",
+			a:    "FROM ubuntu\nUSER 1001\n",
+			b:    "FROM distroless\nUSER 1001\n",
+			want: []string{"-FROM ubuntu", "+FROM distroless", " USER 1001"},
+		},
+		{
+			name: "new file",
+			a:    "",
+			b:    "a\n",
+			want: []string{"+a"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if got := lineDiff(tt.a, tt.b); !slices.Equal(got, tt.want) {
+				t.Errorf("expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestBackendRegenDockerfile(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	backendDir := filepath.Join(dir, "backend")
+	if err := os.MkdirAll(backendDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(backendDir, "go.mod"), []byte("module example.com/backend\n\ngo 1.25.5\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	dockerfilePath := filepath.Join(backendDir, "Dockerfile")
+	if err := os.WriteFile(dockerfilePath, []byte("FROM ubuntu:24.04\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := config.Config{ProjectDir: dir}
+
+	var out bytes.Buffer
+	if err := doBackendRegenDockerfile(cfg, regenDockerfileOptions{DryRun: true, Output: &out}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(out.String(), "-FROM ubuntu:24.04") {
+		t.Errorf("expected diff to remove old base image, got:\n%s", out.String())
+	}
+	if data, _ := os.ReadFile(dockerfilePath); string(data) != "FROM ubuntu:24.04\n" {
+		t.Errorf("dry run must not write the Dockerfile, got:\n%s", data)
+	}
+
+	out.Reset()
+	if err := doBackendRegenDockerfile(cfg, regenDockerfileOptions{Output: &out}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	data, err := os.ReadFile(dockerfilePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"FROM golang:1.25.5 AS build", "FROM " + defaultBackendRuntimeImage + " AS runtime", "ARG CMD_NAME"} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("expected Dockerfile to contain %q, got:\n%s", want, data)
+		}
+	}
+
+	out.Reset()
+	if err := doBackendRegenDockerfile(cfg, regenDockerfileOptions{Output: &out}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(out.String(), "up to date") {
+		t.Errorf("expected up to date message, got:\n%s", out.String())
+	}
+}
+
+func TestRenderBackendDockerfileProtoStage(t *testing.T) {
+	t.Parallel()
+
+	cfg := BackendConfig{GoVersion: "1.25.5", RuntimeImage: defaultBackendRuntimeImage}
+	plain, err := renderBackendDockerfile(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(plain), "proto") {
+		t.Errorf("expected no proto stage without buf, got:\n%s", plain)
+	}
+
+	cfg.BufVersion = "1.50.0"
+	withProto, err := renderBackendDockerfile(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"RUN buf generate\n\nFROM golang:1.25.5 AS build",
+		"COPY --from=bufbuild/buf:1.50.0 /usr/local/bin/buf /usr/local/bin/buf",
+		"COPY . .\nCOPY --from=proto /src/gen ./gen/\n",
+	} {
+		if !strings.Contains(string(withProto), want) {
+			t.Errorf("expected Dockerfile to contain %q, got:\n%s", want, withProto)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+
+	"github.com/advdv/ago/cmd/ago/internal/cmdexec"
+	"github.com/advdv/ago/cmd/ago/internal/config"
+	"github.com/cockroachdb/errors"
+	"github.com/urfave/cli/v3"
+)
+
+// buildMode is how build-and-push builds the images of the backend commands.
+type buildMode string
+
+const (
+	// buildModeDockerfile builds every command with backend/Dockerfile and the image builder.
+	buildModeDockerfile buildMode = "dockerfile"
+	// buildModeKo builds every command with ko, which needs no Dockerfile or container runtime and
+	// builds the same image from the same source. Components keep their Dockerfiles.
+	buildModeKo buildMode = "ko"
+)
+
+// koTool is ko, which mise manages.
+var koTool = toolRequirement{Binary: "ko", Mise: "ko"}
+
+// modeFlag is the --mode flag of the commands that build backend images.
+func modeFlag() cli.Flag {
+	return &cli.StringFlag{
+		Name:  "mode",
+		Usage: "How the backend commands are built: dockerfile, with backend/Dockerfile, or ko",
+		Value: string(buildModeDockerfile),
+	}
+}
+
+func parseBuildMode(flag string) (buildMode, error) {
+	switch mode := buildMode(flag); mode {
+	case buildModeDockerfile, buildModeKo:
+		return mode, nil
+	case "":
+		return buildModeDockerfile, nil
+	default:
+		return "", errors.Errorf("unknown build mode %q, expected dockerfile or ko", flag)
+	}
+}
+
+// requireKo returns an error when ko cannot run.
+func requireKo(ctx context.Context, cfg config.Config) error {
+	declared, err := readMiseTools(cfg.ProjectDir)
+	if err != nil {
+		return err
+	}
+	if problems := findToolProblems(ctx, cmdexec.New(cfg), declared, []toolRequirement{koTool}); len(problems) > 0 {
+		return toolProblemsError("build-and-push --mode ko", problems)
+	}
+	return nil
+}
+
+// koSourceHash folds the build mode into the source hash of a command, so that an image that ko
+// built never gets the tag of one built from the Dockerfile, or the other way around.
+func koSourceHash(sourceHash string) string {
+	sum := sha256.Sum256([]byte(sourceHash + "\n" + string(buildModeKo)))
+	return hex.EncodeToString(sum[:])[:len(sourceHash)]
+}
+
+// koBuildArgs returns the arguments of the ko build of a command. --bare pushes to KO_DOCKER_REPO
+// itself instead of a repository per command, and no SBOM is pushed alongside, since the shared
+// repository only holds images.
+func koBuildArgs(opts buildImageOptions, tag string) []string {
+	return []string{
+		"build", "./cmd/" + opts.CmdName,
+		"--bare",
+		"--tags", tag,
+		"--platform", opts.Platform,
+		"--sbom", "none",
+	}
+}
+
+// koBuild builds and pushes the image of a command with ko, on the pinned base image when there
+// is one, and returns its digest.
+func koBuild(ctx context.Context, exec cmdexec.Executor, opts buildImageOptions, tag string) (string, error) {
+	koExec := exec.WithEnv("KO_DOCKER_REPO", opts.RepoURI)
+	if opts.BaseImage != "" {
+		koExec = koExec.WithEnv("KO_DEFAULTBASEIMAGE", opts.BaseImage)
+	}
+
+	out, err := koExec.MiseOutput(ctx, "ko", koBuildArgs(opts, tag)...)
+	if err != nil {
+		return "", errors.Wrap(err, "ko build failed")
+	}
+	if cmdexec.DryRun() {
+		return "", nil
+	}
+	return koImageDigest(out)
+}
+
+// koImageDigest returns the digest of the image reference that ko build prints last, such as
+// 123456789012.dkr.ecr.eu-central-1.amazonaws.com/myapp-main@sha256:abc.
+func koImageDigest(output string) (string, error) {
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	ref := strings.TrimSpace(lines[len(lines)-1])
+	_, digest, ok := strings.Cut(ref, "@")
+	if !ok || !strings.HasPrefix(digest, "sha256:") {
+		return "", errors.Errorf("ko build printed no image digest: %q", ref)
+	}
+	return digest, nil
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestParseBuildMode(t *testing.T) {
+	t.Parallel()
+
+	for flag, want := range map[string]buildMode{
+		"":           buildModeDockerfile,
+		"dockerfile": buildModeDockerfile,
+		"ko":         buildModeKo,
+	} {
+		got, err := parseBuildMode(flag)
+		if err != nil {
+			t.Fatalf("parseBuildMode(%q): %v", flag, err)
+		}
+		if got != want {
+			t.Errorf("parseBuildMode(%q) = %q, want %q", flag, got, want)
+		}
+	}
+
+	if _, err := parseBuildMode("bazel"); err == nil {
+		t.Error("expected error for unknown build mode")
+	}
+}
+
+func TestKoBuildArgs(t *testing.T) {
+	t.Parallel()
+
+	got := koBuildArgs(buildImageOptions{CmdName: "coreapi", Platform: "linux/arm64"}, "coreapi-dev-abc123")
+	want := []string{
+		"build", "./cmd/coreapi", "--bare", "--tags", "coreapi-dev-abc123",
+		"--platform", "linux/arm64", "--sbom", "none",
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("koBuildArgs() = %v, want %v", got, want)
+	}
+}
+
+func TestKoImageDigest(t *testing.T) {
+	t.Parallel()
+
+	out := "2026/10/16 10:00:00 Publishing myapp-main:coreapi-dev-abc123\n" +
+		"123456789012.dkr.ecr.eu-central-1.amazonaws.com/myapp-main@sha256:abc\n"
+	digest, err := koImageDigest(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if digest != "sha256:abc" {
+		t.Errorf("digest = %q, want %q", digest, "sha256:abc")
+	}
+
+	if _, err := koImageDigest("123456789012.dkr.ecr.eu-central-1.amazonaws.com/myapp-main:latest"); err == nil {
+		t.Error("expected error for a reference without digest")
+	}
+}
+
+func TestKoSourceHash(t *testing.T) {
+	t.Parallel()
+
+	hash := "abc123def456"
+	got := koSourceHash(hash)
+	if got == hash {
+		t.Error("expected the ko source hash to differ from the source hash")
+	}
+	if len(got) != len(hash) {
+		t.Errorf("len = %d, want %d", len(got), len(hash))
+	}
+	if koSourceHash(hash) != got {
+		t.Error("expected the ko source hash to be stable")
+	}
+}
@@ -0,0 +1,319 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"maps"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"text/template"
+
+	"github.com/advdv/ago/cmd/ago/internal/cmdexec"
+	"github.com/advdv/ago/cmd/ago/internal/config"
+	"github.com/cockroachdb/errors"
+	"github.com/iancoleman/strcase"
+	"github.com/urfave/cli/v3"
+)
+
+// Kinds of backend commands that new-cmd scaffolds.
+const (
+	// backendCmdKindHTTP is a plain HTTP server, like the coreapi command of a new project.
+	backendCmdKindHTTP = "http"
+	// backendCmdKindConnect is a Connect service that serves the Connect, gRPC and gRPC-Web
+	// protocols, with its API defined in backend/proto and its code generated by buf.
+	backendCmdKindConnect = "connect"
+)
+
+// backendCmdNameRegex matches the names of backend commands, which agcdkservice also requires.
+var backendCmdNameRegex = regexp.MustCompile(`^[a-z][a-z0-9-]*$`)
+
+func backendNewCmdCmd() *cli.Command {
+	return &cli.Command{
+		Name:      "new-cmd",
+		Usage:     "Scaffold a new backend command in backend/cmd",
+		ArgsUsage: "<name>",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:  "kind",
+				Usage: "Kind of command: http (plain HTTP server) or connect (Connect/gRPC service with buf codegen)",
+				Value: backendCmdKindHTTP,
+			},
+		},
+		Action: config.RunWithConfig(runBackendNewCmd),
+	}
+}
+
+type backendNewCmdOptions struct {
+	Name   string
+	Kind   string
+	Output io.Writer
+	Result io.Writer
+}
+
+// backendNewCmdResult is the result of new-cmd in the JSON output format.
+type backendNewCmdResult struct {
+	Name  string   `json:"name"`
+	Kind  string   `json:"kind"`
+	Files []string `json:"files"`
+}
+
+func runBackendNewCmd(ctx context.Context, cmd *cli.Command, cfg config.Config) error {
+	if cmd.Args().Len() != 1 {
+		return errors.New("expected exactly one argument: the name of the command")
+	}
+
+	output, result := commandOutput(cmd)
+	return doBackendNewCmd(ctx, cfg, backendNewCmdOptions{
+		Name:   cmd.Args().First(),
+		Kind:   cmd.String("kind"),
+		Output: output,
+		Result: result,
+	})
+}
+
+func doBackendNewCmd(ctx context.Context, cfg config.Config, opts backendNewCmdOptions) error {
+	if !backendCmdNameRegex.MatchString(opts.Name) {
+		return errors.Errorf("invalid command name %q: must start with a lowercase letter and contain only "+
+			"lowercase letters, numbers and dashes", opts.Name)
+	}
+
+	backendDir := cfg.BackendDir()
+	cmdDir := filepath.Join(backendDir, "cmd", opts.Name)
+	if _, err := os.Stat(cmdDir); err == nil {
+		return errors.Errorf("backend/cmd/%s already exists", opts.Name)
+	}
+
+	module, err := readModuleName(backendDir)
+	if err != nil {
+		return err
+	}
+
+	files, err := renderBackendCmdFiles(module, opts.Name, opts.Kind)
+	if err != nil {
+		return err
+	}
+
+	res := backendNewCmdResult{Name: opts.Name, Kind: opts.Kind, Files: []string{}}
+	for _, name := range slices.Sorted(maps.Keys(files)) {
+		path := filepath.Join(backendDir, name)
+		// Shared buf configuration belongs to every Connect command and may have been customized.
+		if _, err := os.Stat(path); err == nil {
+			writeOutputf(opts.Output, "Keeping existing backend/%s\n", name)
+			continue
+		}
+		if err := cmdexec.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return errors.Wrapf(err, "failed to create directory for backend/%s", name)
+		}
+		//nolint:gosec // source file needs to be readable
+		if err := cmdexec.WriteFile(path, files[name], 0o644); err != nil {
+			return errors.Wrapf(err, "failed to write backend/%s", name)
+		}
+		writeOutputf(opts.Output, "Wrote backend/%s\n", name)
+		res.Files = append(res.Files, "backend/"+name)
+	}
+
+	exec := cmdexec.New(cfg).WithOutput(opts.Output, opts.Output)
+	backendExec := exec.InSubdir(cfg.Inner.Layout.Backend())
+	if opts.Kind == backendCmdKindConnect {
+		if err := ensureBufTool(ctx, cfg, exec, opts.Output); err != nil {
+			return err
+		}
+		writeOutputf(opts.Output, "Generating protobuf code...\n")
+		if err := backendExec.Mise(ctx, "buf", "generate"); err != nil {
+			return errors.Wrap(err, "buf generate failed")
+		}
+	}
+
+	if err := backendExec.Run(ctx, "go", "mod", "tidy"); err != nil {
+		return errors.Wrap(err, "backend go mod tidy failed")
+	}
+
+	writeOutputf(opts.Output, "\nCreated backend/cmd/%s. 'ago backend build-and-push' builds its image, "+
+		"run it with agcdkservice.New and Name %q", opts.Name, opts.Name)
+	if opts.Kind == backendCmdKindConnect {
+		writeOutputf(opts.Output, " and Protocol agcdkservice.ProtocolConnect")
+		dockerfile, _ := os.ReadFile(filepath.Join(backendDir, "Dockerfile"))
+		if !bytes.Contains(dockerfile, []byte(" AS proto")) {
+			writeOutputf(opts.Output, ".\nRun 'ago backend regen-dockerfile' to add the stage that generates the "+
+				"protobuf code to the Dockerfile")
+		}
+	}
+	writeOutputf(opts.Output, ".\n")
+
+	return writeResult(opts.Result, res)
+}
+
+// ensureBufTool adds buf to mise.toml, pinned to its latest version, unless it is declared.
+func ensureBufTool(ctx context.Context, cfg config.Config, exec cmdexec.Executor, output io.Writer) error {
+	tools, err := readMiseTools(cfg.ProjectDir)
+	if err != nil {
+		return err
+	}
+	if slices.ContainsFunc(tools, func(t MiseTool) bool { return t.Name == "buf" }) {
+		return nil
+	}
+
+	version, err := latestMiseVersion(ctx, exec, "buf")
+	if err != nil {
+		return err
+	}
+	writeOutputf(output, "Adding buf %s to mise.toml...\n", version)
+	if err := exec.Run(ctx, "mise", "use", "buf@"+version); err != nil {
+		return errors.Wrap(err, "failed to add buf to mise.toml")
+	}
+	return nil
+}
+
+// backendCmdTemplateData is the data of the templates that new-cmd renders.
+type backendCmdTemplateData struct {
+	// Module is the module path of the backend.
+	Module string
+	// Name is the name of the command, such as "order-api".
+	Name string
+	// Package is the protobuf package of a Connect command without its version, such as
+	// "orderapi".
+	Package string
+	// Service is the name of the service of a Connect command, such as "OrderApiService".
+	Service string
+}
+
+// renderBackendCmdFiles returns the files of a new backend command of the given kind, keyed by
+// their path relative to the backend directory.
+func renderBackendCmdFiles(module, name, kind string) (map[string][]byte, error) {
+	data := backendCmdTemplateData{
+		Module:  module,
+		Name:    name,
+		Package: strings.ReplaceAll(name, "-", ""),
+		Service: strcase.ToCamel(name) + "Service",
+	}
+
+	var templates map[string]*template.Template
+	switch kind {
+	case backendCmdKindHTTP:
+		templates = map[string]*template.Template{
+			filepath.Join("cmd", name, "main.go"): backendCoreAPIMainTemplate,
+		}
+	case backendCmdKindConnect:
+		templates = map[string]*template.Template{
+			filepath.Join("cmd", name, "main.go"):                             backendConnectMainTemplate,
+			filepath.Join("proto", data.Package, "v1", data.Package+".proto"): backendConnectProtoTemplate,
+			"buf.yaml":     backendBufYAMLTemplate,
+			"buf.gen.yaml": backendBufGenYAMLTemplate,
+		}
+	default:
+		return nil, errors.Errorf("unknown kind %q, expected %q or %q", kind, backendCmdKindHTTP, backendCmdKindConnect)
+	}
+
+	files := make(map[string][]byte, len(templates))
+	for path, tmpl := range templates {
+		var buf bytes.Buffer
+		if err := tmpl.Execute(&buf, data); err != nil {
+			return nil, errors.Wrapf(err, "failed to execute %s template", path)
+		}
+		files[path] = buf.Bytes()
+	}
+	return files, nil
+}
+
+var backendConnectProtoTemplate = template.Must(template.New("proto").Parse(`syntax = "proto3";
+
+package {{.Package}}.v1;
+
+// {{.Service}} is the API of the {{.Name}} command.
+service {{.Service}} {
+  // Ping echoes a message, to check that the service is reachable.
+  rpc Ping(PingRequest) returns (PingResponse) {}
+}
+
+message PingRequest {
+  string message = 1;
+}
+
+message PingResponse {
+  string message = 1;
+}
+`))
+
+var backendBufYAMLTemplate = template.Must(template.New("buf.yaml").Parse(`version: v2
+modules:
+  - path: proto
+lint:
+  use:
+    - STANDARD
+breaking:
+  use:
+    - FILE
+`))
+
+var backendBufGenYAMLTemplate = template.Must(template.New("buf.gen.yaml").Parse(`version: v2
+managed:
+  enabled: true
+  override:
+    - file_option: go_package_prefix
+      value: {{.Module}}/gen
+plugins:
+  - remote: buf.build/protocolbuffers/go
+    out: gen
+    opt: paths=source_relative
+  - remote: buf.build/connectrpc/go
+    out: gen
+    opt: paths=source_relative
+`))
+
+var backendConnectMainTemplate = template.Must(template.New("main.go").Parse(`package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"connectrpc.com/connect"
+
+	{{.Package}}v1 "{{.Module}}/gen/{{.Package}}/v1"
+	"{{.Module}}/gen/{{.Package}}/v1/{{.Package}}v1connect"
+)
+
+type server struct{}
+
+func (server) Ping(
+	_ context.Context, req *connect.Request[{{.Package}}v1.PingRequest],
+) (*connect.Response[{{.Package}}v1.PingResponse], error) {
+	return connect.NewResponse(&{{.Package}}v1.PingResponse{Message: req.Msg.GetMessage()}), nil
+}
+
+func main() {
+	port := os.Getenv("PORT")
+	if port == "" {
+		port = "8080"
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle({{.Package}}v1connect.New{{.Service}}Handler(server{}))
+	mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, _ *http.Request) {
+		w.Write([]byte("ok")) //nolint:errcheck // best effort
+	})
+
+	// gRPC needs HTTP/2, which the load balancer speaks to the tasks without TLS.
+	protocols := new(http.Protocols)
+	protocols.SetHTTP1(true)
+	protocols.SetUnencryptedHTTP2(true)
+
+	srv := &http.Server{
+		Addr:              ":" + port,
+		Handler:           mux,
+		Protocols:         protocols,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	log.Printf("Starting server on :%s", port)
+	if err := srv.ListenAndServe(); err != nil {
+		log.Fatalf("Server failed: %v", err)
+	}
+}
+`))
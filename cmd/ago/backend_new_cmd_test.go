@@ -0,0 +1,52 @@
+package main
+
+import (
+	"go/format"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRenderBackendCmdFiles(t *testing.T) {
+	t.Parallel()
+
+	files, err := renderBackendCmdFiles("example.com/myapp/backend", "order-api", backendCmdKindConnect)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	main, ok := files[filepath.Join("cmd", "order-api", "main.go")]
+	if !ok {
+		t.Fatalf("expected a main.go, got %v", files)
+	}
+	if _, err := format.Source(main); err != nil {
+		t.Errorf("main.go is not valid Go: %v\n%s", err, main)
+	}
+	if !strings.Contains(string(main), `"example.com/myapp/backend/gen/orderapi/v1/orderapiv1connect"`) ||
+		!strings.Contains(string(main), "orderapiv1connect.NewOrderApiServiceHandler") {
+		t.Errorf("expected main.go to use the generated handler, got:\n%s", main)
+	}
+
+	proto := string(files[filepath.Join("proto", "orderapi", "v1", "orderapi.proto")])
+	if !strings.Contains(proto, "package orderapi.v1;") || !strings.Contains(proto, "service OrderApiService {") {
+		t.Errorf("unexpected proto:\n%s", proto)
+	}
+	if !strings.Contains(string(files["buf.gen.yaml"]), "value: example.com/myapp/backend/gen") {
+		t.Errorf("expected buf.gen.yaml to generate into the backend module, got:\n%s", files["buf.gen.yaml"])
+	}
+	if _, ok := files["buf.yaml"]; !ok {
+		t.Error("expected a buf.yaml")
+	}
+
+	files, err = renderBackendCmdFiles("example.com/myapp/backend", "worker", backendCmdKindHTTP)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 {
+		t.Errorf("expected only a main.go for an http command, got %v", files)
+	}
+
+	if _, err := renderBackendCmdFiles("example.com/myapp/backend", "worker", "lambda"); err == nil {
+		t.Error("expected an error for an unknown kind")
+	}
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"sync"
+
+	"github.com/urfave/cli/v3"
+)
+
+// parallelFlag is the --parallel flag of the commands that build backend images.
+func parallelFlag() cli.Flag {
+	return &cli.IntFlag{
+		Name:  "parallel",
+		Usage: "Number of images to build and push at the same time",
+		Value: 1,
+	}
+}
+
+// prefixWriter writes the output of one of several concurrent builds to a writer that they share.
+// It prefixes every line with the name of the build and writes whole lines only, under a lock
+// that all builds hold while they write, so that interleaved output stays readable.
+type prefixWriter struct {
+	mu     *sync.Mutex
+	w      io.Writer
+	prefix []byte
+	buf    []byte
+}
+
+func newPrefixWriter(mu *sync.Mutex, w io.Writer, name string) *prefixWriter {
+	return &prefixWriter{mu: mu, w: w, prefix: []byte("[" + name + "] ")}
+}
+
+func (p *prefixWriter) Write(b []byte) (int, error) {
+	p.buf = append(p.buf, b...)
+	for {
+		i := bytes.IndexByte(p.buf, '\n')
+		if i < 0 {
+			return len(b), nil
+		}
+		if err := p.writeLine(p.buf[:i+1]); err != nil {
+			return len(b), err
+		}
+		p.buf = p.buf[i+1:]
+	}
+}
+
+// Flush writes the last line when it did not end in a newline.
+func (p *prefixWriter) Flush() error {
+	if len(p.buf) == 0 {
+		return nil
+	}
+	line := append(p.buf, '\n')
+	p.buf = nil
+	return p.writeLine(line)
+}
+
+func (p *prefixWriter) writeLine(line []byte) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	_, err := p.w.Write(append(append([]byte{}, p.prefix...), line...))
+	return err
+}
+
+// writePushSummary writes a table of the images with the tag of each, and whether it was pushed
+// or skipped because the tag already existed.
+func writePushSummary(w io.Writer, images []pushedImageResult) {
+	writeOutputf(w, "\n%-24s %-8s %s\n", "IMAGE", "STATUS", "TAG")
+	pushed := 0
+	for _, image := range images {
+		status := "skipped"
+		if !image.Existed {
+			status = "pushed"
+			pushed++
+		}
+		writeOutputf(w, "%-24s %-8s %s\n", image.Name, status, image.Tag)
+	}
+	writeOutputf(w, "\n%d pushed, %d skipped\n", pushed, len(images)-pushed)
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestPrefixWriter(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	var mu sync.Mutex
+	api := newPrefixWriter(&mu, &buf, "api")
+	worker := newPrefixWriter(&mu, &buf, "worker")
+
+	_, _ = api.Write([]byte("step 1/2\nstep"))
+	_, _ = worker.Write([]byte("step 1/1\n"))
+	_, _ = api.Write([]byte(" 2/2\ndone"))
+	if err := api.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := "[api] step 1/2\n[worker] step 1/1\n[api] step 2/2\n[api] done\n"
+	if got := buf.String(); got != want {
+		t.Errorf("output = %q, want %q", got, want)
+	}
+}
+
+func TestWritePushSummary(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	writePushSummary(&buf, []pushedImageResult{
+		{Name: "api", Tag: "api-dev-abc123"},
+		{Name: "worker", Tag: "worker-dev-def456", Existed: true},
+	})
+
+	out := buf.String()
+	for _, want := range []string{
+		"api                      pushed   api-dev-abc123",
+		"worker                   skipped  worker-dev-def456",
+		"1 pushed, 1 skipped",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("summary does not contain %q:\n%s", want, out)
+		}
+	}
+}
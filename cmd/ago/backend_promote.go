@@ -0,0 +1,316 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/advdv/ago/cmd/ago/internal/awsapi"
+	"github.com/advdv/ago/cmd/ago/internal/cdkcontext"
+	"github.com/advdv/ago/cmd/ago/internal/config"
+	"github.com/advdv/ago/cmd/ago/internal/ops"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecr"
+	ecrtypes "github.com/aws/aws-sdk-go-v2/service/ecr/types"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	ssmtypes "github.com/aws/aws-sdk-go-v2/service/ssm/types"
+	"github.com/cockroachdb/errors"
+	"github.com/urfave/cli/v3"
+)
+
+func backendPromoteCmd() *cli.Command {
+	return &cli.Command{
+		Name: "promote",
+		Usage: "Promote the backend images of one deployment to another by tagging the same digests for it, " +
+			"without rebuilding",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:     "from",
+				Usage:    "Deployment whose images to promote (e.g., stag)",
+				Required: true,
+			},
+			&cli.StringFlag{
+				Name:     "to",
+				Usage:    "Deployment to promote the images to (e.g., prod)",
+				Required: true,
+			},
+			&cli.StringFlag{
+				Name:  "stack-name",
+				Usage: "CloudFormation stack name containing the ECR repository (defaults to {qualifier}-Shared-{region-ident})",
+			},
+		},
+		Action: config.RunWithConfig(runBackendPromote),
+	}
+}
+
+type backendPromoteOptions struct {
+	From      string
+	To        string
+	Profile   string
+	Region    string
+	StackName string
+	Output    io.Writer
+	Result    io.Writer
+}
+
+func runBackendPromote(ctx context.Context, cmd *cli.Command, cfg config.Config) error {
+	output, result := commandOutput(cmd)
+	return doBackendPromote(ctx, cfg, backendPromoteOptions{
+		From:      cmd.String("from"),
+		To:        cmd.String("to"),
+		Profile:   cmd.String("profile"),
+		Region:    cmd.String("region"),
+		StackName: cmd.String("stack-name"),
+		Output:    output,
+		Result:    result,
+	})
+}
+
+// promotionRecord is a promotion of the images of one deployment to another, as recorded in the
+// promotion parameter of the target deployment. The parameter history is the audit trail.
+type promotionRecord struct {
+	From       string              `json:"from"`
+	To         string              `json:"to"`
+	Repository string              `json:"repository"`
+	PromotedBy string              `json:"promotedBy"`
+	PromotedAt time.Time           `json:"promotedAt"`
+	Images     []pushedImageResult `json:"images"`
+}
+
+// promotionParameterName returns the name of the SSM parameter that records the promotions to a
+// deployment.
+func promotionParameterName(qualifier, deployment string) string {
+	return "/" + qualifier + "/promotions/" + deployment
+}
+
+// doBackendPromote tags the images recorded for the source deployment for the target deployment.
+// The images are the same digests, so what was tested is what gets deployed. Replication copies
+// images to the secondary regions asynchronously, so a promotion only starts once every region
+// has every digest, and deploying the target cannot reference an image that a region lacks.
+func doBackendPromote(ctx context.Context, cfg config.Config, opts backendPromoteOptions) error {
+	if opts.From == opts.To {
+		return errors.Errorf("cannot promote %s to itself", opts.From)
+	}
+
+	cdkCtx, err := readCDKContext(cfg)
+	if err != nil {
+		return err
+	}
+	qualifier := cdkCtx.Qualifier
+
+	fromTags := cdkCtx.ImageTags[opts.From]
+	if len(fromTags) == 0 {
+		return errors.Errorf("no image tags recorded for %s, run 'ago backend build-and-push --deployment %s' first",
+			opts.From, opts.From)
+	}
+	fromDigests := cdkCtx.ImageDigests[opts.From]
+
+	repo, err := resolveBackendRepository(ctx, cfg, opts.Profile, opts.Region, opts.StackName)
+	if err != nil {
+		return err
+	}
+	repoName := extractRepoName(repo.URI)
+
+	replicas := map[string]awsapi.ECR{}
+	for _, region := range cdkCtx.SecondaryRegions {
+		clients, err := awsapi.New(ctx, repo.Profile, region)
+		if err != nil {
+			return err
+		}
+		replicas[region] = clients.ECR
+	}
+
+	writeOutputf(opts.Output, "Promoting backend images from %s to %s...\n", opts.From, opts.To)
+	images, err := promoteImages(ctx, repo.AWS.ECR, replicas, promoteImagesInput{
+		RepoName: repoName,
+		From:     opts.From,
+		To:       opts.To,
+		Tags:     fromTags,
+		Digests:  fromDigests,
+		Output:   opts.Output,
+	})
+	if err != nil {
+		return err
+	}
+
+	tags := make(map[string]string, len(images))
+	digests := make(map[string]string, len(images))
+	for _, image := range images {
+		tags[image.Name] = image.Tag
+		digests[image.Name] = image.Digest
+	}
+	if err := cdkcontext.UpdateImages(cfg.CDKDir(), opts.To, tags, digests); err != nil {
+		return err
+	}
+	writeOutputf(opts.Output, "\nUpdated cdk.context.json: image-tags and image-digests for %s\n", opts.To)
+
+	promotedBy, err := ops.CallerARN(ctx, repo.AWS)
+	if err != nil {
+		return err
+	}
+	record := promotionRecord{
+		From:       opts.From,
+		To:         opts.To,
+		Repository: repo.URI,
+		PromotedBy: promotedBy,
+		PromotedAt: time.Now().UTC(),
+		Images:     images,
+	}
+	name := promotionParameterName(qualifier, opts.To)
+	if err := recordPromotion(ctx, repo.AWS.SSM, name, record); err != nil {
+		return err
+	}
+	writeOutputf(opts.Output, "Recorded promotion in SSM parameter %s\n", name)
+
+	return writeResult(opts.Result, record)
+}
+
+type promoteImagesInput struct {
+	RepoName string
+	From     string
+	To       string
+	// Tags maps the image names to their tags for the source deployment.
+	Tags map[string]string
+	// Digests maps the image names to the digests recorded for the source deployment, if any.
+	Digests map[string]string
+	Output  io.Writer
+}
+
+// promoteImages tags the image of every tag for the target deployment in the primary repository,
+// after checking that the replicas in the secondary regions hold every digest. A tag keeps the
+// source hash of the image, only the deployment in it changes.
+func promoteImages(
+	ctx context.Context, primary awsapi.ECR, replicas map[string]awsapi.ECR, in promoteImagesInput,
+) ([]pushedImageResult, error) {
+	names := slices.Sorted(maps.Keys(in.Tags))
+	images := make([]pushedImageResult, 0, len(names))
+	for _, name := range names {
+		tag := in.Tags[name]
+		promoted, err := promotedTag(name, tag, in.From, in.To)
+		if err != nil {
+			return nil, err
+		}
+
+		digest, ok, err := lookupImageDigest(ctx, primary, in.RepoName, tag)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to look up %s:%s", in.RepoName, tag)
+		}
+		if !ok {
+			return nil, errors.Errorf("image %s:%s of %s does not exist", in.RepoName, tag, in.From)
+		}
+		if recorded := in.Digests[name]; recorded != "" && recorded != digest {
+			return nil, errors.Errorf("tag %s points at %s, but %s was recorded for %s", tag, digest, recorded, in.From)
+		}
+
+		images = append(images, pushedImageResult{Name: name, Tag: promoted, Digest: digest})
+	}
+
+	for _, region := range slices.Sorted(maps.Keys(replicas)) {
+		for _, image := range images {
+			if err := requireImageDigest(ctx, replicas[region], in.RepoName, image.Digest); err != nil {
+				return nil, errors.Wrapf(err, "image %s is not replicated to %s yet, retry in a few minutes",
+					image.Name, region)
+			}
+		}
+		writeOutputf(in.Output, "  %s: all images replicated\n", region)
+	}
+
+	for i, image := range images {
+		existed, err := tagImage(ctx, primary, in.RepoName, image.Digest, image.Tag)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to tag %s for %s", image.Name, in.To)
+		}
+		images[i].Existed = existed
+		if existed {
+			writeOutputf(in.Output, "Tagged %s@%s as %s (already exists)\n", in.RepoName, image.Digest, image.Tag)
+		} else {
+			writeOutputf(in.Output, "Tagged %s@%s as %s\n", in.RepoName, image.Digest, image.Tag)
+		}
+	}
+	return images, nil
+}
+
+// promotedTag returns the tag of an image for the target deployment, which build-and-push names
+// {name}-{deployment}-{source hash}.
+func promotedTag(name, tag, from, to string) (string, error) {
+	hash, ok := strings.CutPrefix(tag, name+"-"+from+"-")
+	if !ok || hash == "" {
+		return "", errors.Errorf("tag %q of %s is not a build-and-push tag of %s", tag, name, from)
+	}
+	return name + "-" + to + "-" + hash, nil
+}
+
+// requireImageDigest returns an error when the repository has no image with the digest.
+func requireImageDigest(ctx context.Context, client awsapi.ECR, repoName, digest string) error {
+	_, err := client.DescribeImages(ctx, &ecr.DescribeImagesInput{
+		RepositoryName: aws.String(repoName),
+		ImageIds:       []ecrtypes.ImageIdentifier{{ImageDigest: aws.String(digest)}},
+	})
+	if awsapi.IsErrorCode(err, "ImageNotFoundException") {
+		return errors.Errorf("no image with digest %s", digest)
+	}
+	return err
+}
+
+// tagImage adds a tag to the image with the digest by putting its manifest again under the tag.
+// It reports whether the tag already pointed at the image, and fails when it points at another.
+func tagImage(ctx context.Context, client awsapi.ECR, repoName, digest, tag string) (bool, error) {
+	current, ok, err := lookupImageDigest(ctx, client, repoName, tag)
+	if err != nil {
+		return false, err
+	}
+	if ok && current == digest {
+		return true, nil
+	}
+	if ok {
+		return false, errors.Errorf("tag %s already points at %s", tag, current)
+	}
+
+	out, err := client.BatchGetImage(ctx, &ecr.BatchGetImageInput{
+		RepositoryName: aws.String(repoName),
+		ImageIds:       []ecrtypes.ImageIdentifier{{ImageDigest: aws.String(digest)}},
+	})
+	if err != nil {
+		return false, errors.Wrapf(err, "failed to get manifest of %s", digest)
+	}
+	if len(out.Images) == 0 {
+		return false, errors.Errorf("no manifest for %s", digest)
+	}
+
+	image := out.Images[0]
+	if _, err := client.PutImage(ctx, &ecr.PutImageInput{
+		RepositoryName:         aws.String(repoName),
+		ImageManifest:          image.ImageManifest,
+		ImageManifestMediaType: image.ImageManifestMediaType,
+		ImageDigest:            aws.String(digest),
+		ImageTag:               aws.String(tag),
+	}); err != nil && !awsapi.IsErrorCode(err, "ImageAlreadyExistsException") {
+		return false, err
+	}
+	return false, nil
+}
+
+// recordPromotion writes the promotion to the parameter. Every write adds a version to the
+// parameter history, which keeps the earlier promotions.
+func recordPromotion(ctx context.Context, client awsapi.SSM, name string, record promotionRecord) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal promotion record")
+	}
+	if _, err := client.PutParameter(ctx, &ssm.PutParameterInput{
+		Name:      aws.String(name),
+		Type:      ssmtypes.ParameterTypeString,
+		Tier:      ssmtypes.ParameterTierIntelligentTiering,
+		Overwrite: aws.Bool(true),
+		Value:     aws.String(string(data)),
+		Description: aws.String("Backend image promotions to " + record.To +
+			", recorded by 'ago backend promote'"),
+	}); err != nil {
+		return errors.Wrapf(err, "failed to record promotion in %s", name)
+	}
+	return nil
+}
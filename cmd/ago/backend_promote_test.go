@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/advdv/ago/cmd/ago/internal/awsapi"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecr"
+	ecrtypes "github.com/aws/aws-sdk-go-v2/service/ecr/types"
+	"github.com/aws/smithy-go"
+)
+
+// fakeECR holds the tags and manifests of a repository in memory.
+type fakeECR struct {
+	awsapi.ECR
+	tags      map[string]string // tag to digest
+	manifests map[string]string // digest to manifest
+	puts      int
+}
+
+func (f *fakeECR) DescribeImages(
+	_ context.Context, in *ecr.DescribeImagesInput, _ ...func(*ecr.Options),
+) (*ecr.DescribeImagesOutput, error) {
+	id := in.ImageIds[0]
+	digest := aws.ToString(id.ImageDigest)
+	if id.ImageTag != nil {
+		digest = f.tags[aws.ToString(id.ImageTag)]
+	}
+	if _, ok := f.manifests[digest]; !ok {
+		return nil, &smithy.GenericAPIError{Code: "ImageNotFoundException"}
+	}
+	return &ecr.DescribeImagesOutput{ImageDetails: []ecrtypes.ImageDetail{{ImageDigest: aws.String(digest)}}}, nil
+}
+
+func (f *fakeECR) BatchGetImage(
+	_ context.Context, in *ecr.BatchGetImageInput, _ ...func(*ecr.Options),
+) (*ecr.BatchGetImageOutput, error) {
+	digest := aws.ToString(in.ImageIds[0].ImageDigest)
+	manifest, ok := f.manifests[digest]
+	if !ok {
+		return &ecr.BatchGetImageOutput{}, nil
+	}
+	return &ecr.BatchGetImageOutput{Images: []ecrtypes.Image{{ImageManifest: aws.String(manifest)}}}, nil
+}
+
+func (f *fakeECR) PutImage(
+	_ context.Context, in *ecr.PutImageInput, _ ...func(*ecr.Options),
+) (*ecr.PutImageOutput, error) {
+	if f.manifests[aws.ToString(in.ImageDigest)] != aws.ToString(in.ImageManifest) {
+		return nil, &smithy.GenericAPIError{Code: "ImageDigestDoesNotMatchException"}
+	}
+	f.tags[aws.ToString(in.ImageTag)] = aws.ToString(in.ImageDigest)
+	f.puts++
+	return &ecr.PutImageOutput{}, nil
+}
+
+func newFakeECR() *fakeECR {
+	return &fakeECR{
+		tags: map[string]string{
+			"coreapi-stag-abc123": "sha256:api",
+			"worker-stag-abc123":  "sha256:worker",
+		},
+		manifests: map[string]string{"sha256:api": "api manifest", "sha256:worker": "worker manifest"},
+	}
+}
+
+func TestPromotedTag(t *testing.T) {
+	t.Parallel()
+
+	got, err := promotedTag("coreapi", "coreapi-stag-abc123", "stag", "prod")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "coreapi-prod-abc123" {
+		t.Errorf("promotedTag() = %q, want %q", got, "coreapi-prod-abc123")
+	}
+
+	if _, err := promotedTag("coreapi", "coreapi-dev-abc123", "stag", "prod"); err == nil {
+		t.Error("expected error for a tag of another deployment")
+	}
+}
+
+func TestPromoteImages(t *testing.T) {
+	t.Parallel()
+
+	input := promoteImagesInput{
+		RepoName: "myapp-main",
+		From:     "stag",
+		To:       "prod",
+		Tags:     map[string]string{"coreapi": "coreapi-stag-abc123", "worker": "worker-stag-abc123"},
+		Digests:  map[string]string{"coreapi": "sha256:api"},
+		Output:   io.Discard,
+	}
+
+	t.Run("tags the same digests", func(t *testing.T) {
+		t.Parallel()
+
+		primary := newFakeECR()
+		replicas := map[string]awsapi.ECR{"eu-west-1": newFakeECR()}
+		images, err := promoteImages(context.Background(), primary, replicas, input)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(images) != 2 || images[0].Tag != "coreapi-prod-abc123" || images[1].Digest != "sha256:worker" {
+			t.Errorf("unexpected images: %+v", images)
+		}
+		if got := primary.tags["worker-prod-abc123"]; got != "sha256:worker" {
+			t.Errorf("worker-prod-abc123 points at %q, want %q", got, "sha256:worker")
+		}
+
+		// Promoting again finds the tags in place.
+		images, err = promoteImages(context.Background(), primary, replicas, input)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !images[0].Existed || primary.puts != 2 {
+			t.Errorf("expected second promotion to put nothing, got %d puts and %+v", primary.puts, images)
+		}
+	})
+
+	t.Run("waits for replication", func(t *testing.T) {
+		t.Parallel()
+
+		primary := newFakeECR()
+		replica := newFakeECR()
+		delete(replica.manifests, "sha256:worker")
+		_, err := promoteImages(context.Background(), primary, map[string]awsapi.ECR{"eu-west-1": replica}, input)
+		if err == nil || !strings.Contains(err.Error(), "not replicated to eu-west-1") {
+			t.Errorf("expected replication error, got %v", err)
+		}
+		if primary.puts != 0 {
+			t.Errorf("expected no tags to be put, got %d", primary.puts)
+		}
+	})
+
+	t.Run("rejects a moved tag", func(t *testing.T) {
+		t.Parallel()
+
+		primary := newFakeECR()
+		primary.tags["coreapi-stag-abc123"] = "sha256:worker"
+		if _, err := promoteImages(context.Background(), primary, nil, input); err == nil {
+			t.Error("expected error when the tag no longer points at the recorded digest")
+		}
+	})
+}
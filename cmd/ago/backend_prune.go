@@ -0,0 +1,264 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/advdv/ago/cmd/ago/internal/awsapi"
+	"github.com/advdv/ago/cmd/ago/internal/cdkcontext"
+	"github.com/advdv/ago/cmd/ago/internal/config"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecr"
+	ecrtypes "github.com/aws/aws-sdk-go-v2/service/ecr/types"
+	"github.com/cockroachdb/errors"
+	"github.com/urfave/cli/v3"
+)
+
+// batchDeleteImageLimit is the most image IDs that ECR deletes in a single request.
+const batchDeleteImageLimit = 100
+
+func backendPruneImagesCmd() *cli.Command {
+	return &cli.Command{
+		Name: "prune-images",
+		Usage: "Delete the image tags of deployments that no longer exist, such as those of removed " +
+			"deployers, from the repository in every region",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:  "stack-name",
+				Usage: "CloudFormation stack name containing the ECR repository (defaults to {qualifier}-Shared-{region-ident})",
+			},
+			&cli.BoolFlag{
+				Name:  "yes",
+				Usage: "Delete the tags without asking for confirmation",
+			},
+		},
+		Action: config.RunWithConfig(runBackendPruneImages),
+	}
+}
+
+type backendPruneImagesOptions struct {
+	Profile   string
+	Region    string
+	StackName string
+	Yes       bool
+	Output    io.Writer
+	Result    io.Writer
+}
+
+func runBackendPruneImages(ctx context.Context, cmd *cli.Command, cfg config.Config) error {
+	output, result := commandOutput(cmd)
+	return doBackendPruneImages(ctx, cfg, backendPruneImagesOptions{
+		Profile:   cmd.String("profile"),
+		Region:    cmd.String("region"),
+		StackName: cmd.String("stack-name"),
+		Yes:       cmd.Bool("yes"),
+		Output:    output,
+		Result:    result,
+	})
+}
+
+// orphanedImageTag is a tag of a deployment that no longer exists.
+type orphanedImageTag struct {
+	Region     string `json:"region"`
+	Deployment string `json:"deployment"`
+	Tag        string `json:"tag"`
+}
+
+// pruneImagesResult is the result of prune-images in the JSON output format.
+type pruneImagesResult struct {
+	Repository string             `json:"repository"`
+	Deleted    []orphanedImageTag `json:"deleted"`
+}
+
+// doBackendPruneImages deletes the tags that build-and-push pushed for deployments that are no
+// longer in cdk.context.json. Lifecycle rules only expire the oldest images, so without pruning
+// the tags of removed deployers stay around. Replication does not replicate deletes, so every
+// region is pruned on its own. Images that lose their last tag are expired by the lifecycle rules.
+func doBackendPruneImages(ctx context.Context, cfg config.Config, opts backendPruneImagesOptions) error {
+	cdkCtx, err := readCDKContext(cfg)
+	if err != nil {
+		return err
+	}
+	known := knownImageDeployments(cdkCtx)
+	recorded := map[string]bool{}
+	for _, tags := range cdkCtx.ImageTags {
+		for _, tag := range tags {
+			recorded[tag] = true
+		}
+	}
+
+	repo, err := resolveBackendRepository(ctx, cfg, opts.Profile, opts.Region, opts.StackName)
+	if err != nil {
+		return err
+	}
+	repoName := extractRepoName(repo.URI)
+
+	clients := map[string]awsapi.ECR{repo.Region: repo.AWS.ECR}
+	regions := []string{repo.Region}
+	for _, region := range cdkCtx.SecondaryRegions {
+		if region == repo.Region {
+			continue
+		}
+		regionClients, err := awsapi.New(ctx, repo.Profile, region)
+		if err != nil {
+			return err
+		}
+		clients[region] = regionClients.ECR
+		regions = append(regions, region)
+	}
+
+	var orphans []orphanedImageTag
+	for _, region := range regions {
+		tags, err := listImageTags(ctx, clients[region], repoName)
+		if err != nil {
+			return errors.Wrapf(err, "failed to list the images of %s in %s", repoName, region)
+		}
+		for _, orphan := range orphanedImageTags(tags, known, recorded) {
+			orphan.Region = region
+			orphans = append(orphans, orphan)
+		}
+	}
+
+	res := pruneImagesResult{Repository: repo.URI, Deleted: []orphanedImageTag{}}
+	if len(orphans) == 0 {
+		writeOutputf(opts.Output, "No image tags of removed deployments in %s\n", repoName)
+		return writeResult(opts.Result, res)
+	}
+
+	writeOutputf(opts.Output, "%-16s %-20s %s\n", "REGION", "DEPLOYMENT", "TAG")
+	for _, orphan := range orphans {
+		writeOutputf(opts.Output, "%-16s %-20s %s\n", orphan.Region, orphan.Deployment, orphan.Tag)
+	}
+
+	if !opts.Yes {
+		if !isTerminal(os.Stdin) {
+			return errors.New("refusing to delete image tags without confirmation, pass --yes")
+		}
+		confirmed, err := confirmPrompt(fmt.Sprintf("Delete %d image tags?", len(orphans)))
+		if err != nil {
+			return err
+		}
+		if !confirmed {
+			return errors.New("prune of image tags cancelled")
+		}
+	}
+
+	for _, region := range regions {
+		var tags []string
+		for _, orphan := range orphans {
+			if orphan.Region == region {
+				tags = append(tags, orphan.Tag)
+			}
+		}
+		if err := deleteImageTags(ctx, clients[region], repoName, tags); err != nil {
+			return errors.Wrapf(err, "failed to delete image tags in %s", region)
+		}
+	}
+
+	writeOutputf(opts.Output, "\nDeleted %d image tags of removed deployments\n", len(orphans))
+	res.Deleted = orphans
+	return writeResult(opts.Result, res)
+}
+
+// knownImageDeployments returns the deployments that images may be tagged for, lower-cased since
+// build-and-push takes the deployment as given: the deployments, those of the tenants, and the
+// shared deployment of the base image.
+func knownImageDeployments(cdkCtx *cdkcontext.Context) map[string]bool {
+	known := map[string]bool{"shared": true}
+	for _, deployment := range cdkCtx.Deployments {
+		known[strings.ToLower(deployment)] = true
+	}
+	for deployment := range tenantDeployments(cdkCtx) {
+		known[strings.ToLower(deployment)] = true
+	}
+	return known
+}
+
+// parseImageTag splits a tag that build-and-push pushed, {name}-{deployment}-{source hash}, into
+// the name and the deployment. Names may contain dashes, deployments may not.
+func parseImageTag(tag string) (name, deployment string, ok bool) {
+	rest, hash, ok := cutLast(tag, "-")
+	if !ok || hash == "" || strings.Trim(hash, "0123456789abcdef") != "" {
+		return "", "", false
+	}
+	name, deployment, ok = cutLast(rest, "-")
+	if !ok || name == "" || deployment == "" {
+		return "", "", false
+	}
+	return name, deployment, true
+}
+
+func cutLast(s, sep string) (before, after string, found bool) {
+	i := strings.LastIndex(s, sep)
+	if i < 0 {
+		return s, "", false
+	}
+	return s[:i], s[i+len(sep):], true
+}
+
+// orphanedImageTags returns the tags of deployments that are not known. Tags that build-and-push
+// did not push, and tags still recorded in cdk.context.json, are never orphaned.
+func orphanedImageTags(tags []string, known, recorded map[string]bool) []orphanedImageTag {
+	var orphans []orphanedImageTag
+	for _, tag := range tags {
+		_, deployment, ok := parseImageTag(tag)
+		if !ok || known[strings.ToLower(deployment)] || recorded[tag] {
+			continue
+		}
+		orphans = append(orphans, orphanedImageTag{Deployment: deployment, Tag: tag})
+	}
+	slices.SortFunc(orphans, func(a, b orphanedImageTag) int {
+		return strings.Compare(a.Deployment+"/"+a.Tag, b.Deployment+"/"+b.Tag)
+	})
+	return orphans
+}
+
+// listImageTags returns the tags of the images in the repository.
+func listImageTags(ctx context.Context, client awsapi.ECR, repoName string) ([]string, error) {
+	var tags []string
+	pages := ecr.NewListImagesPaginator(client, &ecr.ListImagesInput{
+		RepositoryName: aws.String(repoName),
+		Filter:         &ecrtypes.ListImagesFilter{TagStatus: ecrtypes.TagStatusTagged},
+	})
+	for pages.HasMorePages() {
+		page, err := pages.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, id := range page.ImageIds {
+			if id.ImageTag != nil {
+				tags = append(tags, aws.ToString(id.ImageTag))
+			}
+		}
+	}
+	return tags, nil
+}
+
+// deleteImageTags deletes the tags from the repository. An image with other tags keeps them.
+func deleteImageTags(ctx context.Context, client awsapi.ECR, repoName string, tags []string) error {
+	for chunk := range slices.Chunk(tags, batchDeleteImageLimit) {
+		ids := make([]ecrtypes.ImageIdentifier, 0, len(chunk))
+		for _, tag := range chunk {
+			ids = append(ids, ecrtypes.ImageIdentifier{ImageTag: aws.String(tag)})
+		}
+		out, err := client.BatchDeleteImage(ctx, &ecr.BatchDeleteImageInput{
+			RepositoryName: aws.String(repoName),
+			ImageIds:       ids,
+		})
+		if err != nil {
+			return err
+		}
+		for _, failure := range out.Failures {
+			if failure.FailureCode == ecrtypes.ImageFailureCodeImageNotFound {
+				continue
+			}
+			return errors.Errorf("failed to delete %s: %s", aws.ToString(failure.ImageId.ImageTag),
+				aws.ToString(failure.FailureReason))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestParseImageTag(t *testing.T) {
+	t.Parallel()
+
+	for tag, want := range map[string][2]string{
+		"coreapi-dev-abc123":         {"coreapi", "dev"},
+		"job-runner-DevAdam-0f9e8d":  {"job-runner", "DevAdam"},
+		"base-shared-1234567890abcd": {"base", "shared"},
+	} {
+		name, deployment, ok := parseImageTag(tag)
+		if !ok || name != want[0] || deployment != want[1] {
+			t.Errorf("parseImageTag(%q) = %q, %q, %v, want %q, %q", tag, name, deployment, ok, want[0], want[1])
+		}
+	}
+
+	for _, tag := range []string{"latest", "coreapi-abc123", "coreapi-dev-v1.2", "-dev-abc123"} {
+		if _, _, ok := parseImageTag(tag); ok {
+			t.Errorf("parseImageTag(%q) should not parse", tag)
+		}
+	}
+}
+
+func TestOrphanedImageTags(t *testing.T) {
+	t.Parallel()
+
+	known := knownImageDeployments(newTestCDKContext(t, map[string]any{
+		"myapp-deployments": []any{"Prod", "DevBob"},
+	}))
+	recorded := map[string]bool{"coreapi-devcarol-fedcba": true}
+
+	orphans := orphanedImageTags([]string{
+		"coreapi-prod-abc123",
+		"coreapi-devbob-abc123",
+		"base-shared-abc123",
+		"worker-devadam-abc123",
+		"coreapi-devadam-abc123",
+		"coreapi-devcarol-fedcba",
+		"latest",
+	}, known, recorded)
+
+	if len(orphans) != 2 {
+		t.Fatalf("expected 2 orphans, got %+v", orphans)
+	}
+	if orphans[0].Tag != "coreapi-devadam-abc123" || orphans[1].Tag != "worker-devadam-abc123" {
+		t.Errorf("unexpected orphans: %+v", orphans)
+	}
+	if orphans[0].Deployment != "devadam" {
+		t.Errorf("deployment = %q, want %q", orphans[0].Deployment, "devadam")
+	}
+}
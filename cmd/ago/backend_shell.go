@@ -0,0 +1,230 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/advdv/ago/cmd/ago/internal/awsapi"
+	"github.com/advdv/ago/cmd/ago/internal/cmdexec"
+	"github.com/advdv/ago/cmd/ago/internal/config"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecr"
+	ecrtypes "github.com/aws/aws-sdk-go-v2/service/ecr/types"
+	"github.com/cockroachdb/errors"
+	"github.com/urfave/cli/v3"
+)
+
+func backendShellCmd() *cli.Command {
+	return &cli.Command{
+		Name:      "shell",
+		Usage:     "Open a shell in the exact image a deployment runs for a backend command",
+		ArgsUsage: "<cmd>",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:  "deployment",
+				Usage: "Deployment identifier (e.g., dev, stag, prod)",
+				Value: "dev",
+			},
+			&cli.StringFlag{
+				Name:  "tag",
+				Usage: "Image tag to run (defaults to the most recently pushed tag for the command and deployment)",
+			},
+			&cli.StringFlag{
+				Name:  "stack-name",
+				Usage: "CloudFormation stack name containing the ECR repository (defaults to {qualifier}-Shared-{region-ident})",
+			},
+			&cli.StringFlag{
+				Name:  "platform",
+				Usage: "Platform of the image to pull and run",
+				Value: "linux/arm64",
+			},
+			&cli.StringFlag{
+				Name: "shell",
+				Usage: "Shell to use as the container entrypoint; fails when the image has none, " +
+					"like the default distroless runtime image",
+				Value: "/bin/sh",
+			},
+			&cli.StringSliceFlag{
+				Name:  "env",
+				Usage: "Additional environment variable for the container (KEY=VALUE, repeatable)",
+			},
+		},
+		Action: config.RunWithConfig(runBackendShell),
+	}
+}
+
+func runBackendShell(ctx context.Context, cmd *cli.Command, cfg config.Config) error {
+	cmdName := cmd.Args().First()
+	if cmdName == "" {
+		return errors.New("cmd argument is required")
+	}
+
+	return doBackendShell(ctx, cfg, backendShellOptions{
+		CmdName:    cmdName,
+		Deployment: cmd.String("deployment"),
+		Tag:        cmd.String("tag"),
+		Profile:    cmd.String("profile"),
+		Region:     cmd.String("region"),
+		StackName:  cmd.String("stack-name"),
+		Platform:   cmd.String("platform"),
+		Shell:      cmd.String("shell"),
+		Env:        cmd.StringSlice("env"),
+		Input:      os.Stdin,
+		Output:     os.Stdout,
+		ErrOut:     os.Stderr,
+	})
+}
+
+type backendShellOptions struct {
+	CmdName    string
+	Deployment string
+	Tag        string
+	Profile    string
+	Region     string
+	StackName  string
+	Platform   string
+	Shell      string
+	Env        []string
+	Input      io.Reader
+	Output     io.Writer
+	ErrOut     io.Writer
+}
+
+func doBackendShell(ctx context.Context, cfg config.Config, opts backendShellOptions) error {
+	if err := requireTools(ctx, cfg, "shell"); err != nil {
+		return err
+	}
+
+	exec := cmdexec.New(cfg).WithOutput(opts.Output, opts.ErrOut)
+
+	for _, kv := range opts.Env {
+		if !strings.Contains(kv, "=") {
+			return errors.Errorf("invalid --env value %q: expected KEY=VALUE", kv)
+		}
+	}
+
+	repo, err := resolveBackendRepository(ctx, cfg, opts.Profile, opts.Region, opts.StackName)
+	if err != nil {
+		return err
+	}
+
+	tag := opts.Tag
+	if tag == "" {
+		tag, err = latestImageTag(ctx, repo.AWS, extractRepoName(repo.URI), opts.CmdName+"-"+opts.Deployment+"-")
+		if err != nil {
+			return err
+		}
+	}
+
+	imageRef := fmt.Sprintf("%s:%s", repo.URI, tag)
+
+	if err := loginToECR(ctx, exec, repo.AWS, "docker"); err != nil {
+		return err
+	}
+
+	writeOutputf(opts.Output, "Pulling %s...\n", imageRef)
+	if err := exec.Run(ctx, "docker", "pull", "--platform", opts.Platform, imageRef); err != nil {
+		return errors.Wrap(err, "failed to pull image")
+	}
+
+	if err := checkImageShell(ctx, exec, opts.Platform, imageRef, opts.Shell); err != nil {
+		return err
+	}
+
+	args := []string{
+		"run", "--rm", "--interactive", "--tty",
+		"--platform", opts.Platform,
+		"--entrypoint", opts.Shell,
+	}
+	for _, kv := range backendShellEnv(opts, repo.Region) {
+		args = append(args, "--env", kv)
+	}
+	args = append(args, imageRef)
+
+	writeOutputf(opts.Output, "Starting %s in %s (deployment: %s)...\n", opts.Shell, imageRef, opts.Deployment)
+
+	return exec.RunWithStdin(ctx, opts.Input, "docker", args...)
+}
+
+// checkImageShell fails with a clear error when the image has no shell at the given path, as is
+// the case for the default distroless runtime image, instead of leaving docker run to fail on the
+// entrypoint. The image is inspected in a created container that never starts.
+func checkImageShell(ctx context.Context, exec cmdexec.Executor, platform, imageRef, shell string) error {
+	id, err := exec.Output(ctx, "docker", "create", "--platform", platform, "--entrypoint", shell, imageRef)
+	if err != nil {
+		return errors.Wrap(err, "failed to create container")
+	}
+	if id == "" {
+		return nil // dry run
+	}
+	defer func() { _, _ = exec.Output(ctx, "docker", "rm", id) }()
+
+	if _, err := exec.Output(ctx, "docker", "cp", id+":"+shell, "-"); err != nil {
+		return errors.Errorf("image %s has no shell at %s, distroless runtime images have none: pass a shell "+
+			"that the image has with --shell, or rebuild the image on a runtime image with a shell "+
+			"(see 'ago backend regen-dockerfile --runtime-image')", imageRef, shell)
+	}
+	return nil
+}
+
+// backendShellEnv returns the environment passed to the container: the deployment and
+// region the image would see when deployed, followed by user-provided overrides.
+func backendShellEnv(opts backendShellOptions, region string) []string {
+	env := []string{
+		"AGO_DEPLOYMENT=" + opts.Deployment,
+		"AGO_CMD_NAME=" + opts.CmdName,
+		"AWS_REGION=" + region,
+		"AWS_DEFAULT_REGION=" + region,
+	}
+	return append(env, opts.Env...)
+}
+
+func latestImageTag(ctx context.Context, clients *awsapi.Clients, repoName, tagPrefix string) (string, error) {
+	var images []ecrtypes.ImageDetail
+	pages := ecr.NewDescribeImagesPaginator(clients.ECR, &ecr.DescribeImagesInput{
+		RepositoryName: aws.String(repoName),
+		Filter:         &ecrtypes.DescribeImagesFilter{TagStatus: ecrtypes.TagStatusTagged},
+	})
+	for pages.HasMorePages() {
+		page, err := pages.NextPage(ctx)
+		if err != nil {
+			return "", errors.Wrap(err, "failed to list ECR images")
+		}
+		images = append(images, page.ImageDetails...)
+	}
+
+	tag, ok := selectLatestTag(images, tagPrefix)
+	if !ok {
+		return "", errors.Errorf("no image tag with prefix %q found in repository %q (run 'ago backend build-and-push' first)",
+			tagPrefix, repoName)
+	}
+
+	return tag, nil
+}
+
+// selectLatestTag returns the tag with the given prefix from the most recently pushed image.
+func selectLatestTag(images []ecrtypes.ImageDetail, tagPrefix string) (string, bool) {
+	var (
+		latestTag  string
+		latestTime time.Time
+		found      bool
+	)
+
+	for _, img := range images {
+		pushedAt := aws.ToTime(img.ImagePushedAt)
+		for _, tag := range img.ImageTags {
+			if !strings.HasPrefix(tag, tagPrefix) {
+				continue
+			}
+			if !found || pushedAt.After(latestTime) {
+				latestTag, latestTime, found = tag, pushedAt, true
+			}
+		}
+	}
+
+	return latestTag, found
+}
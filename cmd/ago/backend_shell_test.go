@@ -0,0 +1,47 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	ecrtypes "github.com/aws/aws-sdk-go-v2/service/ecr/types"
+)
+
+func TestSelectLatestTag(t *testing.T) {
+	t.Parallel()
+
+	pushedAt := func(day int) *time.Time {
+		return aws.Time(time.Date(2025, time.January, day, 10, 0, 0, 0, time.UTC))
+	}
+	images := []ecrtypes.ImageDetail{
+		{ImageTags: []string{"coreapi-dev-aaa"}, ImagePushedAt: pushedAt(1)},
+		{ImageTags: []string{"coreapi-dev-bbb"}, ImagePushedAt: pushedAt(3)},
+		{ImageTags: []string{"coreapi-prod-ccc"}, ImagePushedAt: pushedAt(5)},
+		{ImageTags: []string{"worker-dev-ddd"}, ImagePushedAt: pushedAt(6)},
+	}
+
+	tests := []struct {
+		prefix string
+		want   string
+		wantOK bool
+	}{
+		{"coreapi-dev-", "coreapi-dev-bbb", true},
+		{"coreapi-prod-", "coreapi-prod-ccc", true},
+		{"worker-dev-", "worker-dev-ddd", true},
+		{"worker-prod-", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.prefix, func(t *testing.T) {
+			t.Parallel()
+			got, ok := selectLatestTag(images, tt.prefix)
+			if ok != tt.wantOK {
+				t.Fatalf("expected ok=%v, got %v", tt.wantOK, ok)
+			}
+			if got != tt.want {
+				t.Errorf("expected tag %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
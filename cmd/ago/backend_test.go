@@ -0,0 +1,35 @@
+package main
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+
+	"github.com/advdv/ago/cmd/ago/internal/dirhash"
+)
+
+func TestDoBackendHashExplain(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	previous := filepath.Join(dir, "previous.json")
+	old := dirhash.Manifest{Hash: "aaa", Files: map[string]string{"go.mod": "1", "main.go": "2", "old.go": "3"}}
+	if err := dirhash.WriteManifest(previous, old); err != nil {
+		t.Fatal(err)
+	}
+
+	current := dirhash.Manifest{Hash: "bbb", Files: map[string]string{"go.mod": "1", "main.go": "4", "new.go": "5"}}
+	var out bytes.Buffer
+	next := filepath.Join(dir, "next.json")
+	if err := doBackendHash(&out, nil, current, next, previous); err != nil {
+		t.Fatal(err)
+	}
+
+	want := "bbb\nChanged since aaa:\n  + new.go\n  - old.go\n  ~ main.go\n"
+	if out.String() != want {
+		t.Errorf("output = %q, want %q", out.String(), want)
+	}
+	if written, err := dirhash.ReadManifest(next); err != nil || written.Hash != "bbb" {
+		t.Errorf("expected the current manifest to be written, got %+v, %v", written, err)
+	}
+}
@@ -7,8 +7,11 @@ import (
 
 func checkCmd() *cli.Command {
 	return &cli.Command{
-		Name:  "check",
-		Usage: "Run various checks",
+		Name: "check",
+		Usage: "Check the health of the project (tools, profiles, context, bootstrap, DNS, ECR and the " +
+			"checks in .ago.yml), or run one of the checks below",
+		Flags:  checkDoctorFlags(),
+		Action: config.RunWithConfig(runCheckDoctor),
 		Commands: []*cli.Command{
 			{
 				Name:   "test",
@@ -30,6 +33,9 @@ func checkCmd() *cli.Command {
 				Usage:  "Check generated code is checked-in",
 				Action: config.RunWithConfig(checkUncommittedChanges),
 			},
+			checkBackupsCmd(),
+			checkContextUsageCmd(),
+			checkIAMDiffCmd(),
 		},
 	}
 }
@@ -0,0 +1,263 @@
+package main
+
+import (
+	"context"
+	"io"
+	"os"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/advdv/ago/cmd/ago/internal/awsapi"
+	"github.com/advdv/ago/cmd/ago/internal/config"
+	"github.com/advdv/ago/cmd/ago/internal/ops"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	dynamodbtypes "github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/cockroachdb/errors"
+	"github.com/urfave/cli/v3"
+)
+
+func checkBackupsCmd() *cli.Command {
+	return &cli.Command{
+		Name:  "backups",
+		Usage: "Verify point-in-time recovery, recent backups and bucket versioning of deployed data",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:  "deployment",
+				Usage: "Only check the shared stacks and the stacks of this deployment",
+			},
+		},
+		Action: config.RunWithConfig(runCheckBackups),
+	}
+}
+
+type checkBackupsOptions struct {
+	Deployment string
+	Profile    string
+	Now        time.Time
+	Output     io.Writer
+}
+
+func runCheckBackups(ctx context.Context, cmd *cli.Command, cfg config.Config) error {
+	return doCheckBackups(ctx, cfg, checkBackupsOptions{
+		Deployment: cmd.String("deployment"),
+		Profile:    cmd.String("profile"),
+		Now:        time.Now(),
+		Output:     os.Stdout,
+	})
+}
+
+// Kinds of backup checks.
+const (
+	backupCheckPITR       = "point-in-time recovery"
+	backupCheckRecent     = "recent backup"
+	backupCheckVersioning = "bucket versioning"
+)
+
+// backupCheck is a single check to run against a resource.
+type backupCheck struct {
+	Kind     string
+	Resource stackResource
+}
+
+// backupFinding is the outcome of a backup check.
+type backupFinding struct {
+	Stack    string
+	Resource string
+	Check    string
+	OK       bool
+	Detail   string
+}
+
+func doCheckBackups(ctx context.Context, cfg config.Config, opts checkBackupsOptions) error {
+	cdk, err := loadCDKContext(cfg)
+	if err != nil {
+		return err
+	}
+
+	profile, err := resolveAWSProfile(opts.Profile, func() (string, error) { return getCDKProfile(cdk.Context) })
+	if err != nil {
+		return err
+	}
+
+	if err := cdk.Require("primary-region"); err != nil {
+		return err
+	}
+	regions := cdk.Regions()
+
+	deployments := cdk.Deployments
+	if opts.Deployment != "" {
+		if !slices.Contains(deployments, opts.Deployment) {
+			return errors.Errorf("deployment %q not found\n\nAvailable deployments: %s",
+				opts.Deployment, formatDeploymentsList(deployments))
+		}
+		deployments = []string{opts.Deployment}
+	}
+
+	policy := cfg.Inner.Backups
+
+	var findings []backupFinding
+	for _, stack := range inventoryStacks(cdk.Qualifier, regions, deployments) {
+		clients, err := awsapi.New(ctx, profile, stack.Region)
+		if err != nil {
+			return err
+		}
+		exists, err := ops.StackExists(ctx, clients, stack.Name)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			continue
+		}
+
+		resources, err := listStackResources(ctx, clients, stack.Name)
+		if err != nil {
+			return err
+		}
+		template, err := getDeployedTemplate(ctx, clients, stack.Name)
+		if err != nil {
+			return err
+		}
+
+		for _, check := range planBackupChecks(resources, protectedResources(template), policy) {
+			finding, err := runBackupCheck(ctx, clients, check, policy, opts.Now)
+			if err != nil {
+				return err
+			}
+			finding.Stack = stack.Name
+			findings = append(findings, finding)
+		}
+	}
+
+	return reportBackupFindings(opts.Output, findings)
+}
+
+// planBackupChecks returns the checks that the policy requires for the resources of a stack.
+// Tables always need point-in-time recovery; recent backups and bucket versioning depend on
+// the policy.
+func planBackupChecks(
+	resources []stackResource, protected map[string]string, policy config.BackupPolicy,
+) []backupCheck {
+	var checks []backupCheck
+	for _, r := range resources {
+		switch r.ResourceType {
+		case "AWS::DynamoDB::Table", "AWS::DynamoDB::GlobalTable":
+			checks = append(checks, backupCheck{Kind: backupCheckPITR, Resource: r})
+			if policy.MaxBackupAgeDays > 0 {
+				checks = append(checks, backupCheck{Kind: backupCheckRecent, Resource: r})
+			}
+		case "AWS::S3::Bucket":
+			_, isProtected := protected[r.LogicalResourceID]
+			switch policy.BucketVersioning() {
+			case config.VersionedBucketsAll:
+				checks = append(checks, backupCheck{Kind: backupCheckVersioning, Resource: r})
+			case config.VersionedBucketsProtected:
+				if isProtected {
+					checks = append(checks, backupCheck{Kind: backupCheckVersioning, Resource: r})
+				}
+			}
+		}
+	}
+	return checks
+}
+
+func runBackupCheck(
+	ctx context.Context, clients *awsapi.Clients, check backupCheck, policy config.BackupPolicy, now time.Time,
+) (backupFinding, error) {
+	name := check.Resource.PhysicalResourceID
+	finding := backupFinding{Resource: check.Resource.LogicalResourceID + " (" + name + ")", Check: check.Kind}
+
+	switch check.Kind {
+	case backupCheckPITR:
+		out, err := clients.DynamoDB.DescribeContinuousBackups(ctx, &dynamodb.DescribeContinuousBackupsInput{
+			TableName: aws.String(name),
+		})
+		if err != nil {
+			return finding, errors.Wrapf(err, "failed to describe continuous backups of table %q", name)
+		}
+		var status dynamodbtypes.PointInTimeRecoveryStatus
+		if d := out.ContinuousBackupsDescription; d != nil && d.PointInTimeRecoveryDescription != nil {
+			status = d.PointInTimeRecoveryDescription.PointInTimeRecoveryStatus
+		}
+		finding.OK = status == dynamodbtypes.PointInTimeRecoveryStatusEnabled
+		finding.Detail = "status " + string(status)
+
+	case backupCheckRecent:
+		count, err := countAvailableBackups(ctx, clients, name, now.AddDate(0, 0, -policy.MaxBackupAgeDays))
+		if err != nil {
+			return finding, err
+		}
+		finding.OK = count > 0
+		finding.Detail = strconv.Itoa(count) + " backup(s) in the last " +
+			strconv.Itoa(policy.MaxBackupAgeDays) + " day(s)"
+
+	case backupCheckVersioning:
+		out, err := clients.S3.GetBucketVersioning(ctx, &s3.GetBucketVersioningInput{Bucket: aws.String(name)})
+		if err != nil {
+			return finding, errors.Wrapf(err, "failed to get versioning of bucket %q", name)
+		}
+		status := string(out.Status)
+		if status == "" {
+			status = "never enabled"
+		}
+		finding.OK = out.Status == s3types.BucketVersioningStatusEnabled
+		finding.Detail = "status " + status
+	}
+
+	return finding, nil
+}
+
+// countAvailableBackups returns the number of available backups of a table that were created
+// since a time.
+func countAvailableBackups(ctx context.Context, clients *awsapi.Clients, table string, since time.Time) (int, error) {
+	in := &dynamodb.ListBackupsInput{
+		TableName:           aws.String(table),
+		BackupType:          dynamodbtypes.BackupTypeFilterAll,
+		TimeRangeLowerBound: aws.Time(since),
+	}
+
+	var count int
+	for {
+		out, err := clients.DynamoDB.ListBackups(ctx, in)
+		if err != nil {
+			return 0, errors.Wrapf(err, "failed to list backups of table %q", table)
+		}
+		for _, b := range out.BackupSummaries {
+			if b.BackupStatus == dynamodbtypes.BackupStatusAvailable {
+				count++
+			}
+		}
+		if out.LastEvaluatedBackupArn == nil {
+			return count, nil
+		}
+		in.ExclusiveStartBackupArn = out.LastEvaluatedBackupArn
+	}
+}
+
+func reportBackupFindings(w io.Writer, findings []backupFinding) error {
+	if len(findings) == 0 {
+		writeOutputf(w, "No tables or buckets to check.\n")
+		return nil
+	}
+
+	var failed int
+	for _, f := range findings {
+		result := "PASS"
+		if !f.OK {
+			result = "FAIL"
+			failed++
+		}
+		writeOutputf(w, "%s  %-24s %-22s %s: %s\n", result, f.Stack, f.Check, f.Resource, f.Detail)
+	}
+
+	if failed > 0 {
+		return errors.Errorf("%d of %d backup checks failed", failed, len(findings))
+	}
+
+	writeOutputf(w, "\nAll %d backup checks passed.\n", len(findings))
+
+	return nil
+}
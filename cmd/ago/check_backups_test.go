@@ -0,0 +1,85 @@
+package main
+
+import (
+	"bytes"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/advdv/ago/cmd/ago/internal/config"
+)
+
+func TestPlanBackupChecks(t *testing.T) {
+	t.Parallel()
+
+	resources := []stackResource{
+		{LogicalResourceID: "Orders", ResourceType: "AWS::DynamoDB::Table"},
+		{LogicalResourceID: "Uploads", ResourceType: "AWS::S3::Bucket"},
+		{LogicalResourceID: "Scratch", ResourceType: "AWS::S3::Bucket"},
+		{LogicalResourceID: "Handler", ResourceType: "AWS::Lambda::Function"},
+	}
+	protected := map[string]string{"Uploads": "customer uploads"}
+
+	tests := []struct {
+		name   string
+		policy config.BackupPolicy
+		want   []string
+	}{
+		{
+			name:   "default policy",
+			policy: config.BackupPolicy{},
+			want:   []string{"Orders " + backupCheckPITR, "Uploads " + backupCheckVersioning},
+		},
+		{
+			name:   "recent backups and all buckets",
+			policy: config.BackupPolicy{MaxBackupAgeDays: 1, VersionedBuckets: config.VersionedBucketsAll},
+			want: []string{
+				"Orders " + backupCheckPITR, "Orders " + backupCheckRecent,
+				"Uploads " + backupCheckVersioning, "Scratch " + backupCheckVersioning,
+			},
+		},
+		{
+			name:   "no bucket versioning",
+			policy: config.BackupPolicy{VersionedBuckets: config.VersionedBucketsNone},
+			want:   []string{"Orders " + backupCheckPITR},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var got []string
+			for _, check := range planBackupChecks(resources, protected, tt.policy) {
+				got = append(got, check.Resource.LogicalResourceID+" "+check.Kind)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("planBackupChecks() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReportBackupFindings(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	err := reportBackupFindings(&buf, []backupFinding{
+		{Stack: "myappUse1Prod", Resource: "Orders (orders-table)", Check: backupCheckPITR, OK: true},
+		{Stack: "myappUse1Prod", Resource: "Uploads (uploads-bucket)", Check: backupCheckVersioning, Detail: "status None"},
+	})
+	if err == nil {
+		t.Fatal("expected error for failed check")
+	}
+	if !strings.Contains(err.Error(), "1 of 2") {
+		t.Errorf("expected failure count in error, got %v", err)
+	}
+	if !strings.Contains(buf.String(), "FAIL  myappUse1Prod") {
+		t.Errorf("expected failed finding in output, got:\n%s", buf.String())
+	}
+
+	buf.Reset()
+	if err := reportBackupFindings(&buf, nil); err != nil {
+		t.Errorf("unexpected error without findings: %v", err)
+	}
+}
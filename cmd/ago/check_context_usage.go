@@ -0,0 +1,291 @@
+package main
+
+import (
+	"context"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/advdv/ago/cmd/ago/internal/cdkcontext"
+	"github.com/advdv/ago/cmd/ago/internal/config"
+	"github.com/cockroachdb/errors"
+	"github.com/urfave/cli/v3"
+)
+
+func checkContextUsageCmd() *cli.Command {
+	return &cli.Command{
+		Name:   "context-usage",
+		Usage:  "Cross-reference the context keys that the infra Go code reads against the CDK context",
+		Action: config.RunWithConfig(runCheckContextUsage),
+	}
+}
+
+type checkContextUsageOptions struct {
+	Output io.Writer
+}
+
+func runCheckContextUsage(_ context.Context, _ *cli.Command, cfg config.Config) error {
+	return doCheckContextUsage(cfg, checkContextUsageOptions{Output: os.Stdout})
+}
+
+// contextAccessors maps agcdkutil functions to the ago-owned context key they read. Accessors
+// of keys with a default are left out, since a missing key is not a mistake for them; the
+// others fail at synth when their key is missing.
+var contextAccessors = map[string]string{
+	"Qualifier":       "qualifier",
+	"PrimaryRegion":   "primary-region",
+	"BaseDomainName":  "base-domain-name",
+	"ImageTag":        "image-tags",
+	"BaseImageDigest": "base-image",
+}
+
+// contextRead is a context key that the infra code reads.
+type contextRead struct {
+	Key      string
+	Pos      string
+	Accessor bool
+}
+
+// contextUsageFinding is a problem with a context key.
+type contextUsageFinding struct {
+	Pos     string
+	Message string
+}
+
+func doCheckContextUsage(cfg config.Config, opts checkContextUsageOptions) error {
+	cdkCtx, err := cdkcontext.Load(cfg.CDKDir())
+	if err != nil {
+		return err
+	}
+
+	infraDir := cfg.InfraDir()
+	reads, err := scanContextReads(infraDir, cdkCtx.Prefix)
+	if err != nil {
+		return err
+	}
+	for i := range reads {
+		if rel, err := filepath.Rel(cfg.ProjectDir, reads[i].Pos); err == nil {
+			reads[i].Pos = rel
+		}
+	}
+
+	findings := checkContextUsage(cdkCtx.Values, cdkCtx.Prefix, reads)
+	for _, f := range findings {
+		writeOutputf(opts.Output, "%s: %s\n", f.Pos, f.Message)
+	}
+
+	if len(findings) > 0 {
+		return errors.Errorf("%d context usage problem(s) found", len(findings))
+	}
+
+	writeOutputf(opts.Output, "All %d context read(s) refer to existing keys and all project keys are read\n",
+		len(reads))
+	return nil
+}
+
+// checkContextUsage reports reads of keys that are not in the context, and prefixed keys in the
+// context that neither ago nor the infra code reads.
+func checkContextUsage(cdkCtx map[string]any, prefix string, reads []contextRead) []contextUsageFinding {
+	owned := map[string]bool{}
+	for _, key := range cdkcontext.Keys {
+		name := key.Name
+		if key.Prefixed {
+			name = prefix + key.Name
+		}
+		owned[name] = !key.Required
+	}
+
+	var findings []contextUsageFinding
+	read := map[string]bool{}
+	for _, r := range reads {
+		read[r.Key] = true
+		if contextHasKey(cdkCtx, r.Key) {
+			continue
+		}
+		if optional, ok := owned[r.Key]; ok && optional && !r.Accessor {
+			continue
+		}
+
+		msg := "reads context key " + strconv.Quote(r.Key) + ", which is not set"
+		if suggestion := suggestContextKey(cdkCtx, prefix, r.Key); suggestion != "" {
+			msg += " (did you mean " + strconv.Quote(suggestion) + "?)"
+		}
+		findings = append(findings, contextUsageFinding{Pos: r.Pos, Message: msg})
+	}
+
+	var unused []string
+	for name := range cdkCtx {
+		if _, ok := owned[name]; ok || read[name] || !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		unused = append(unused, name)
+	}
+	slices.Sort(unused)
+	for _, name := range unused {
+		findings = append(findings, contextUsageFinding{
+			Pos:     cdkcontext.FileName,
+			Message: "context key " + strconv.Quote(name) + " is never read",
+		})
+	}
+
+	return findings
+}
+
+// contextHasKey reports whether a key is set at the top level or in the "context" object of
+// cdk.json.
+func contextHasKey(cdkCtx map[string]any, key string) bool {
+	if _, ok := cdkCtx[key]; ok {
+		return true
+	}
+	nested, _ := cdkCtx["context"].(map[string]any)
+	_, ok := nested[key]
+	return ok
+}
+
+// suggestContextKey returns the project key a read of a missing key most likely meant: the same
+// name under the project prefix, which catches prefix typos.
+func suggestContextKey(cdkCtx map[string]any, prefix, key string) string {
+	idx := strings.Index(key, "-")
+	if idx < 0 || strings.HasPrefix(key, prefix) {
+		return ""
+	}
+	candidate := prefix + key[idx+1:]
+	if contextHasKey(cdkCtx, candidate) {
+		return candidate
+	}
+	return ""
+}
+
+// scanContextReads parses the non-test Go files under dir and returns the context keys they read
+// through TryGetContext/GetContext and the agcdkutil accessors.
+func scanContextReads(dir, prefix string) ([]contextRead, error) {
+	fset := token.NewFileSet()
+	var files []*ast.File
+
+	walkOpts := DefaultWalkOptions()
+	walkOpts.Extensions = []string{".go"}
+	err := WalkFiles(dir, walkOpts, func(path string, _ fs.DirEntry) error {
+		if strings.HasSuffix(path, "_test.go") {
+			return nil
+		}
+		f, err := parser.ParseFile(fset, path, nil, parser.SkipObjectResolution)
+		if err != nil {
+			return errors.Wrapf(err, "failed to parse %s", path)
+		}
+		files = append(files, f)
+		return nil
+	})
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, errors.Wrap(err, "failed to scan infra code")
+	}
+
+	consts := stringConstants(files)
+
+	var reads []contextRead
+	for _, f := range files {
+		ast.Inspect(f, func(n ast.Node) bool {
+			call, ok := n.(*ast.CallExpr)
+			if !ok {
+				return true
+			}
+			sel, ok := call.Fun.(*ast.SelectorExpr)
+			if !ok {
+				return true
+			}
+
+			read := contextRead{Pos: fset.Position(call.Pos()).String()}
+			switch {
+			case (sel.Sel.Name == "TryGetContext" || sel.Sel.Name == "GetContext") && len(call.Args) == 1:
+				read.Key, ok = resolveContextKey(call.Args[0], consts, prefix)
+				if !ok {
+					return true
+				}
+			case isPackageSelector(sel, "agcdkutil") && contextAccessors[sel.Sel.Name] != "":
+				read.Key, read.Accessor = prefix+contextAccessors[sel.Sel.Name], true
+			default:
+				return true
+			}
+
+			reads = append(reads, read)
+			return true
+		})
+	}
+
+	return reads, nil
+}
+
+func isPackageSelector(sel *ast.SelectorExpr, pkg string) bool {
+	ident, ok := sel.X.(*ast.Ident)
+	return ok && ident.Name == pkg
+}
+
+// stringConstants collects the package-level string constants of the files by name.
+func stringConstants(files []*ast.File) map[string]string {
+	consts := map[string]string{}
+	for _, f := range files {
+		for _, decl := range f.Decls {
+			gen, ok := decl.(*ast.GenDecl)
+			if !ok || gen.Tok != token.CONST {
+				continue
+			}
+			for _, spec := range gen.Specs {
+				vs, ok := spec.(*ast.ValueSpec)
+				if !ok || len(vs.Names) != len(vs.Values) {
+					continue
+				}
+				for i, name := range vs.Names {
+					if lit, ok := vs.Values[i].(*ast.BasicLit); ok && lit.Kind == token.STRING {
+						if s, err := strconv.Unquote(lit.Value); err == nil {
+							consts[name.Name] = s
+						}
+					}
+				}
+			}
+		}
+	}
+	return consts
+}
+
+// resolveContextKey evaluates a context key expression such as jsii.String(prefix+"qualifier").
+// A concatenation that starts with an expression that cannot be resolved is assumed to start
+// with the project prefix. Keys that cannot be resolved at all are skipped.
+func resolveContextKey(expr ast.Expr, consts map[string]string, prefix string) (string, bool) {
+	switch e := expr.(type) {
+	case *ast.CallExpr:
+		if sel, ok := e.Fun.(*ast.SelectorExpr); ok && isPackageSelector(sel, "jsii") &&
+			sel.Sel.Name == "String" && len(e.Args) == 1 {
+			return resolveContextKey(e.Args[0], consts, prefix)
+		}
+	case *ast.ParenExpr:
+		return resolveContextKey(e.X, consts, prefix)
+	case *ast.BasicLit:
+		if e.Kind == token.STRING {
+			s, err := strconv.Unquote(e.Value)
+			return s, err == nil
+		}
+	case *ast.Ident:
+		s, ok := consts[e.Name]
+		return s, ok
+	case *ast.BinaryExpr:
+		if e.Op != token.ADD {
+			return "", false
+		}
+		right, ok := resolveContextKey(e.Y, consts, prefix)
+		if !ok {
+			return "", false
+		}
+		left, ok := resolveContextKey(e.X, consts, prefix)
+		if !ok {
+			left = prefix
+		}
+		return left + right, true
+	}
+	return "", false
+}
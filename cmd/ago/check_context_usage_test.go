@@ -0,0 +1,78 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/advdv/ago/cmd/ago/internal/config"
+)
+
+func TestCheckContextUsage(t *testing.T) {
+	t.Parallel()
+
+	cfg := config.Config{ProjectDir: t.TempDir()}
+	cdkDir := cfg.CDKDir()
+	if err := os.MkdirAll(cdkDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	files := map[string]string{
+		"cdk.json": `{"app": "go run .", "profile": "myapp-deployer"}`,
+		"cdk.context.json": `{
+			"myapp-qualifier": "myapp",
+			"myapp-primary-region": "eu-central-1",
+			"myapp-feature-x": true,
+			"myapp-legacy-flag": "on"
+		}`,
+		"main.go": `package main
+
+const prefix = "myapp-"
+
+func main() {
+	app.Node().TryGetContext(jsii.String(prefix + "feature-x"))
+	app.Node().TryGetContext(jsii.String("myap-primary-region"))
+	app.Node().TryGetContext(jsii.String(cfg.Prefix + "feature-y"))
+	app.Node().TryGetContext(jsii.String(dynamicKey))
+	_ = agcdkutil.Qualifier(app)
+	_ = agcdkutil.ImageTag(app, "Dev", "backend")
+	_ = agcdkutil.DNSDelegated(app)
+}
+`,
+		"main_test.go": `package main
+
+func init() { app.Node().TryGetContext(jsii.String("myapp-only-in-tests")) }
+`,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(cdkDir, name), []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var buf bytes.Buffer
+	err := doCheckContextUsage(cfg, checkContextUsageOptions{Output: &buf})
+	if err == nil || !strings.Contains(err.Error(), "4 context usage problem(s)") {
+		t.Fatalf("expected 4 problems, got %v\n%s", err, buf.String())
+	}
+
+	for _, want := range []string{
+		filepath.Join("infra", "cdk", "cdk", "main.go") + `:7:2: reads context key "myap-primary-region", ` +
+			`which is not set (did you mean "myapp-primary-region"?)`,
+		`main.go:8:2: reads context key "myapp-feature-y", which is not set`,
+		`main.go:11:6: reads context key "myapp-image-tags", which is not set`,
+		`cdk.context.json: context key "myapp-legacy-flag" is never read`,
+	} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("output does not contain %q:\n%s", want, buf.String())
+		}
+	}
+
+	for _, unwanted := range []string{"myapp-feature-x", "myapp-qualifier", "dns-delegated", "only-in-tests"} {
+		if strings.Contains(buf.String(), unwanted) {
+			t.Errorf("output unexpectedly mentions %q:\n%s", unwanted, buf.String())
+		}
+	}
+}
@@ -0,0 +1,450 @@
+package main
+
+import (
+	"context"
+	"io"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/advdv/ago/agcdkutil"
+	"github.com/advdv/ago/cmd/ago/internal/awsapi"
+	"github.com/advdv/ago/cmd/ago/internal/cmdexec"
+	"github.com/advdv/ago/cmd/ago/internal/config"
+	"github.com/advdv/ago/cmd/ago/internal/doctor"
+	"github.com/advdv/ago/cmd/ago/internal/ops"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecr"
+	"github.com/cockroachdb/errors"
+	"github.com/urfave/cli/v3"
+)
+
+// checkDoctorFlags are the flags of 'ago check' itself, which runs the health checks.
+func checkDoctorFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringSliceFlag{
+			Name:  "only",
+			Usage: "Only run these checks (e.g. mise-tools,dns-delegation)",
+		},
+	}
+}
+
+type checkDoctorOptions struct {
+	Only    []string
+	Profile string
+	Output  io.Writer
+	Result  io.Writer
+}
+
+func runCheckDoctor(ctx context.Context, cmd *cli.Command, cfg config.Config) error {
+	output, result := commandOutput(cmd)
+	return doCheckDoctor(ctx, cfg, checkDoctorOptions{
+		Only:    cmd.StringSlice("only"),
+		Profile: cmd.String("profile"),
+		Output:  output,
+		Result:  result,
+	})
+}
+
+func doCheckDoctor(ctx context.Context, cfg config.Config, opts checkDoctorOptions) error {
+	reg, err := doctorRegistry(newDoctorProject(cfg, opts.Profile))
+	if err != nil {
+		return err
+	}
+
+	reports, err := reg.Run(ctx, opts.Only)
+	if err != nil {
+		return err
+	}
+
+	for _, r := range reports {
+		writeOutputf(opts.Output, "%s  %-22s %s\n", r.Status, r.Name, r.Detail)
+	}
+	if err := writeResult(opts.Result, reports); err != nil {
+		return err
+	}
+
+	if failed := doctor.Count(reports, doctor.Fail); failed > 0 {
+		return errors.Errorf("%d of %d checks failed", failed, len(reports))
+	}
+	writeOutputf(opts.Output, "\n%d checks passed, %d warnings, %d skipped.\n",
+		doctor.Count(reports, doctor.Pass), doctor.Count(reports, doctor.Warn), doctor.Count(reports, doctor.Skip))
+	return nil
+}
+
+// doctorRegistry registers the built-in checks, followed by the checks that .ago.yml declares.
+func doctorRegistry(p *doctorProject) (*doctor.Registry, error) {
+	reg := &doctor.Registry{}
+	checkers := []doctor.Checker{
+		doctor.CheckerFunc("mise-tools", p.checkMiseTools),
+		doctor.CheckerFunc("cdk-context", p.checkCDKContext),
+		doctor.CheckerFunc("aws-profiles", p.checkAWSProfiles),
+		doctor.CheckerFunc("bootstrap-version", p.checkBootstrapVersion),
+		doctor.CheckerFunc("permissions-boundary", p.checkPermissionsBoundary),
+		doctor.CheckerFunc("dns-delegation", p.checkDNSDelegation),
+		doctor.CheckerFunc("ecr-repository", p.checkECRRepository),
+		doctor.CheckerFunc("stack-outputs", p.checkStackOutputs),
+	}
+	for _, c := range p.cfg.Inner.Checks {
+		checkers = append(checkers, projectChecker{cfg: p.cfg, check: c})
+	}
+
+	for _, c := range checkers {
+		if err := reg.Register(c); err != nil {
+			return nil, errors.Wrap(err, "invalid checks in .ago.yml")
+		}
+	}
+	return reg, nil
+}
+
+// doctorProject is what the built-in checks inspect. The CDK context is loaded once; the checks
+// that need it are skipped when it cannot be loaded, which the cdk-context check reports.
+type doctorProject struct {
+	cfg         config.Config
+	profileFlag string
+	cdk         *cdkContext
+	cdkErr      error
+}
+
+func newDoctorProject(cfg config.Config, profileFlag string) *doctorProject {
+	cdk, err := loadCDKContext(cfg)
+	return &doctorProject{cfg: cfg, profileFlag: profileFlag, cdk: cdk, cdkErr: err}
+}
+
+// clients returns clients for the project's profile in its primary region.
+func (p *doctorProject) clients(ctx context.Context) (*awsapi.Clients, error) {
+	if p.cdkErr != nil {
+		return nil, errors.Wrap(p.cdkErr, "CDK context not available")
+	}
+	profile, err := resolveAWSProfile(p.profileFlag, func() (string, error) { return getCDKProfile(p.cdk.Context) })
+	if err != nil {
+		return nil, err
+	}
+	return awsapi.New(ctx, profile, p.cdk.PrimaryRegion)
+}
+
+func (p *doctorProject) checkMiseTools(ctx context.Context) doctor.Result {
+	output, err := cmdexec.New(p.cfg).Output(ctx, "mise", "ls", "--missing")
+	if err != nil {
+		return doctor.Failf("failed to list mise tools: %v", err)
+	}
+
+	var missing []string
+	for line := range strings.Lines(output) {
+		if fields := strings.Fields(line); len(fields) > 0 {
+			missing = append(missing, fields[0])
+		}
+	}
+	if len(missing) > 0 {
+		return doctor.Failf("missing tools: %s (run 'mise install')", strings.Join(missing, ", "))
+	}
+	return doctor.Passf("all tools are installed")
+}
+
+func (p *doctorProject) checkCDKContext(context.Context) doctor.Result {
+	if p.cdkErr != nil {
+		return doctor.Failf("%v", p.cdkErr)
+	}
+
+	var problems, unrecognized []string
+	reports := explainContext(p.cdk.Values, p.cdk.Prefix)
+	for _, r := range reports {
+		switch r.Status {
+		case contextStatusMissing:
+			problems = append(problems, r.Key+" is missing")
+		case contextStatusInvalid:
+			problems = append(problems, r.Key+": "+r.Problem)
+		case contextStatusUnrecognized:
+			unrecognized = append(unrecognized, r.Key)
+		}
+	}
+
+	switch {
+	case len(problems) > 0:
+		return doctor.Failf("%s (see 'ago context explain')", strings.Join(problems, "; "))
+	case len(unrecognized) > 0:
+		return doctor.Warnf("unrecognized keys: %s", strings.Join(unrecognized, ", "))
+	default:
+		return doctor.Passf("%d keys are valid", len(reports))
+	}
+}
+
+// checkAWSProfiles verifies that every profile the project uses resolves to credentials: the
+// profile of cdk.json, the admin and management profiles and the local deployer profiles.
+func (p *doctorProject) checkAWSProfiles(ctx context.Context) doctor.Result {
+	if p.cdkErr != nil {
+		return doctor.Skipf("CDK context not available")
+	}
+
+	var profiles []string
+	profile, err := resolveAWSProfile(p.profileFlag, func() (string, error) { return getCDKProfile(p.cdk.Context) })
+	if err == nil {
+		profiles = append(profiles, profile)
+	}
+	for _, profile := range []string{p.cdk.AdminProfile, p.cdk.String("management-profile")} {
+		if profile != "" {
+			profiles = append(profiles, profile)
+		}
+	}
+	deployerProfiles, _ := ops.DeployerProfiles(p.cdk.Qualifier)
+	profiles = append(profiles, deployerProfiles...)
+	slices.Sort(profiles)
+	profiles = slices.Compact(profiles)
+
+	if len(profiles) == 0 {
+		return doctor.Failf("no AWS profiles configured (was 'ago infra create-aws-account' run?)")
+	}
+
+	var broken []string
+	for _, profile := range profiles {
+		clients, err := awsapi.New(ctx, profile, p.cdk.PrimaryRegion)
+		if err == nil {
+			_, err = ops.CallerARN(ctx, clients)
+		}
+		if err != nil {
+			broken = append(broken, profile)
+		}
+	}
+	if len(broken) > 0 {
+		return doctor.Failf("profiles without working credentials: %s", strings.Join(broken, ", "))
+	}
+	return doctor.Passf("%s", strings.Join(profiles, ", "))
+}
+
+// checkBootstrapVersion compares the version of the deployed CDK toolkit stack with the version
+// of the bootstrap template that the project's CDK CLI would deploy.
+func (p *doctorProject) checkBootstrapVersion(ctx context.Context) doctor.Result {
+	clients, err := p.clients(ctx)
+	if err != nil {
+		return doctor.Skipf("%v", err)
+	}
+
+	stackName := ops.ToolkitStackName(p.cdk.Qualifier)
+	exists, err := ops.StackExists(ctx, clients, stackName)
+	if err != nil {
+		return doctor.Failf("%v", err)
+	}
+	if !exists {
+		return doctor.Failf("stack %s not found, run 'ago infra cdk bootstrap'", stackName)
+	}
+
+	deployedOutput, err := ops.StackOutput(ctx, clients, stackName, agcdkutil.BootstrapVersionOutputKey)
+	if err != nil {
+		return doctor.Failf("%v", err)
+	}
+	deployed, err := strconv.Atoi(deployedOutput)
+	if err != nil {
+		return doctor.Failf("unexpected bootstrap version %q", deployedOutput)
+	}
+
+	template, err := p.cdk.CDKExec.MiseOutput(ctx, "cdk", "bootstrap", "--show-template")
+	if err != nil {
+		return doctor.Warnf("deployed version %d, failed to get the latest version: %v", deployed, err)
+	}
+	latest, err := bootstrapTemplateVersion(template)
+	if err != nil {
+		return doctor.Warnf("deployed version %d, %v", deployed, err)
+	}
+
+	if deployed < latest {
+		return doctor.Warnf("deployed version %d, the CDK CLI bootstraps version %d: run 'ago infra cdk bootstrap'",
+			deployed, latest)
+	}
+	return doctor.Passf("version %d", deployed)
+}
+
+var bootstrapVersionPattern = regexp.MustCompile(`(?s)CdkBootstrapVersion:.*?Value:\s*['"]?(\d+)`)
+
+// bootstrapTemplateVersion returns the version that a CDK bootstrap template writes to its
+// CdkBootstrapVersion parameter.
+func bootstrapTemplateVersion(template string) (int, error) {
+	match := bootstrapVersionPattern.FindStringSubmatch(template)
+	if match == nil {
+		return 0, errors.New("no CdkBootstrapVersion in the bootstrap template")
+	}
+	return strconv.Atoi(match[1])
+}
+
+// checkPermissionsBoundary verifies that the boundary the CDK app applies is the one that the
+// pre-bootstrap stack created, as bootstrap does before it runs.
+func (p *doctorProject) checkPermissionsBoundary(ctx context.Context) doctor.Result {
+	clients, err := p.clients(ctx)
+	if err != nil {
+		return doctor.Skipf("%v", err)
+	}
+
+	boundary, _ := p.cdk.Values["@aws-cdk/core:permissionsBoundary"].(map[string]any)
+	contextName, _ := boundary["name"].(string)
+	if contextName == "" {
+		return doctor.Failf("@aws-cdk/core:permissionsBoundary.name not found in cdk.context.json")
+	}
+
+	deployedName, err := ops.StackOutput(ctx, clients, ops.PreBootstrapStackName(p.cdk.Qualifier),
+		agcdkutil.PermissionsBoundaryNameOutputKey)
+	if err != nil {
+		return doctor.Failf("%v", err)
+	}
+	if deployedName != contextName {
+		return doctor.Failf("context uses %q, the pre-bootstrap stack created %q", contextName, deployedName)
+	}
+	return doctor.Passf("%s", contextName)
+}
+
+func (p *doctorProject) checkDNSDelegation(ctx context.Context) doctor.Result {
+	if p.cdkErr != nil {
+		return doctor.Skipf("CDK context not available")
+	}
+	baseDomainName := p.cdk.BaseDomainName
+	if baseDomainName == "" {
+		return doctor.Skipf("the project has no base domain")
+	}
+	if !p.cdk.DNSDelegated {
+		return doctor.Warnf("%s is not delegated yet, run 'ago infra org dns-delegate'", baseDomainName)
+	}
+
+	clients, err := p.clients(ctx)
+	if err != nil {
+		return doctor.Skipf("%v", err)
+	}
+	stackName := agcdkutil.SharedStackName(p.cdk.Qualifier, agcdkutil.RegionIdentFor(p.cdk.PrimaryRegion))
+	nameServers, err := ops.StackOutput(ctx, clients, stackName, agcdkutil.NameServersOutputKey)
+	if err != nil {
+		return doctor.Failf("%v", err)
+	}
+
+	verified, err := ops.CheckDelegation(ctx, baseDomainName, strings.Split(nameServers, ","))
+	if err != nil {
+		return doctor.Failf("DNS lookup failed: %v", err)
+	}
+	if !verified {
+		return doctor.Failf("NS records of %s do not match the hosted zone, see 'ago infra org dns-verify'",
+			baseDomainName)
+	}
+	return doctor.Passf("%s", baseDomainName)
+}
+
+func (p *doctorProject) checkECRRepository(ctx context.Context) doctor.Result {
+	if p.cdkErr != nil {
+		return doctor.Skipf("CDK context not available")
+	}
+
+	repo, err := resolveBackendRepository(ctx, p.cfg, p.profileFlag, "", "")
+	if err != nil {
+		return doctor.Failf("%v", err)
+	}
+
+	_, name, _ := strings.Cut(repo.URI, "/")
+	if _, err := repo.AWS.ECR.DescribeImages(ctx, &ecr.DescribeImagesInput{
+		RepositoryName: aws.String(name),
+		MaxResults:     aws.Int32(1),
+	}); err != nil {
+		return doctor.Failf("repository %s is not reachable: %v", repo.URI, err)
+	}
+	return doctor.Passf("%s", repo.URI)
+}
+
+// outputStack is a stack whose outputs the stack-outputs check verifies.
+type outputStack struct {
+	Name    string
+	Region  string
+	Kind    agcdkutil.StackKind
+	Primary bool
+}
+
+// expectedOutputStacks returns the stacks with expected outputs: the pre-bootstrap stack in the
+// primary region, and the toolkit and shared stacks in every region.
+func expectedOutputStacks(qualifier string, regions []string) []outputStack {
+	stacks := []outputStack{{
+		Name: ops.PreBootstrapStackName(qualifier), Region: regions[0],
+		Kind: agcdkutil.StackKindPreBootstrap, Primary: true,
+	}}
+	for i, region := range regions {
+		stacks = append(stacks, outputStack{
+			Name: ops.ToolkitStackName(qualifier), Region: region,
+			Kind: agcdkutil.StackKindToolkit, Primary: i == 0,
+		}, outputStack{
+			Name: agcdkutil.SharedStackName(qualifier, agcdkutil.RegionIdentFor(region)), Region: region,
+			Kind: agcdkutil.StackKindShared, Primary: i == 0,
+		})
+	}
+	return stacks
+}
+
+// checkStackOutputs verifies that the deployed stacks expose the outputs that the CLI reads, as
+// listed by agcdkutil.ExpectedOutputs. Stacks that are not deployed yet are left to the other
+// checks.
+func (p *doctorProject) checkStackOutputs(ctx context.Context) doctor.Result {
+	if p.cdkErr != nil {
+		return doctor.Skipf("CDK context not available")
+	}
+	profile, err := resolveAWSProfile(p.profileFlag, func() (string, error) { return getCDKProfile(p.cdk.Context) })
+	if err != nil {
+		return doctor.Skipf("%v", err)
+	}
+
+	regions := p.cdk.Regions()
+
+	var missing []string
+	deployed := 0
+	for _, stack := range expectedOutputStacks(p.cdk.Qualifier, regions) {
+		clients, err := awsapi.New(ctx, profile, stack.Region)
+		if err != nil {
+			return doctor.Failf("%v", err)
+		}
+		outputs, err := ops.StackOutputs(ctx, clients, stack.Name)
+		if err != nil {
+			return doctor.Failf("%v", err)
+		}
+		if outputs == nil {
+			continue
+		}
+		deployed++
+		for _, m := range agcdkutil.MissingOutputs(stack.Kind, stack.Primary, outputs) {
+			missing = append(missing, stack.Name+" ("+stack.Region+"): "+m.Key+" from "+m.Producer)
+		}
+	}
+
+	switch {
+	case deployed == 0:
+		return doctor.Skipf("no stacks deployed yet")
+	case len(missing) > 0:
+		return doctor.Failf("missing outputs: %s", strings.Join(missing, "; "))
+	default:
+		return doctor.Passf("%d stacks expose the expected outputs", deployed)
+	}
+}
+
+// projectChecker runs a check that .ago.yml declares. It runs like a hook, through "sh -c"
+// inside mise, and is skipped in a dry run because its command may change state.
+type projectChecker struct {
+	cfg   config.Config
+	check config.Check
+}
+
+func (c projectChecker) Name() string { return c.check.Name }
+
+func (c projectChecker) Check(ctx context.Context) doctor.Result {
+	if cmdexec.DryRun() {
+		return doctor.Skipf("dry run")
+	}
+
+	output, err := cmdexec.New(c.cfg).MiseOutput(ctx, "sh", "-c", c.check.Run)
+	detail := lastLine(output)
+	if err != nil {
+		if detail == "" {
+			detail = err.Error()
+		}
+		return doctor.Failf("%s", detail)
+	}
+	if detail == "" {
+		detail = c.check.Run
+	}
+	return doctor.Passf("%s", detail)
+}
+
+// lastLine returns the last non-empty line of the output.
+func lastLine(output string) string {
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	return strings.TrimSpace(lines[len(lines)-1])
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/advdv/ago/agcdkutil"
+	"github.com/advdv/ago/cmd/ago/internal/config"
+)
+
+func TestBootstrapTemplateVersion(t *testing.T) {
+	t.Parallel()
+
+	template := `Resources:
+  StagingBucket:
+    Type: AWS::S3::Bucket
+  CdkBootstrapVersion:
+    Type: AWS::SSM::Parameter
+    Properties:
+      Type: String
+      Name:
+        Fn::Sub: /cdk-bootstrap/${Qualifier}/version
+      Value: "28"
+Outputs:
+  BootstrapVersion:
+    Value:
+      Fn::GetAtt:
+        - CdkBootstrapVersion
+        - Value
+`
+	got, err := bootstrapTemplateVersion(template)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got != 28 {
+		t.Errorf("bootstrapTemplateVersion() = %d, want 28", got)
+	}
+
+	if _, err := bootstrapTemplateVersion("Resources: {}\n"); err == nil {
+		t.Error("expected an error for a template without version")
+	}
+}
+
+func TestDoctorRegistry(t *testing.T) {
+	t.Parallel()
+
+	project := &doctorProject{cfg: config.Config{Inner: config.InnerConfig{
+		Checks: []config.Check{{Name: "migrations", Run: "go run ./cmd/migrate --check"}},
+	}}}
+	reg, err := doctorRegistry(project)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	names := reg.Names()
+	if names[0] != "mise-tools" || names[len(names)-1] != "migrations" {
+		t.Errorf("unexpected checks %v", names)
+	}
+
+	project.cfg.Inner.Checks = []config.Check{{Name: "dns-delegation", Run: "true"}}
+	if _, err := doctorRegistry(project); err == nil {
+		t.Error("expected an error for a project check that clashes with a built-in check")
+	}
+}
+
+func TestExpectedOutputStacks(t *testing.T) {
+	t.Parallel()
+
+	stacks := expectedOutputStacks("myapp", []string{"eu-central-1", "us-east-1"})
+	if len(stacks) != 5 {
+		t.Fatalf("expected 5 stacks, got %+v", stacks)
+	}
+	if stacks[0].Kind != agcdkutil.StackKindPreBootstrap || stacks[0].Region != "eu-central-1" {
+		t.Errorf("expected the pre-bootstrap stack in the primary region first, got %+v", stacks[0])
+	}
+	if last := stacks[4]; last.Name != "myappUse1Shared" || last.Primary {
+		t.Errorf("expected the secondary shared stack last, got %+v", last)
+	}
+}
+
+// TestPreBootstrapTemplateOutputs keeps the output keys of the pre-bootstrap template in sync
+// with the keys that the CLI reads.
+func TestPreBootstrapTemplateOutputs(t *testing.T) {
+	t.Parallel()
+
+	source := preBootstrapTemplate.Tree.Root.String()
+	_, outputs, ok := strings.Cut(source, "\nOutputs:")
+	if !ok {
+		t.Fatal("no Outputs in the pre-bootstrap template")
+	}
+	for _, expected := range agcdkutil.ExpectedOutputs(agcdkutil.StackKindPreBootstrap) {
+		if !strings.Contains(outputs, "\n  "+expected.Key+":\n") {
+			t.Errorf("pre-bootstrap template has no output %q", expected.Key)
+		}
+	}
+}
+
+func TestLastLine(t *testing.T) {
+	t.Parallel()
+
+	if got := lastLine("checking...\n3 migrations pending\n\n"); got != "3 migrations pending" {
+		t.Errorf("lastLine() = %q", got)
+	}
+	if got := lastLine(""); got != "" {
+		t.Errorf("lastLine() = %q, want empty", got)
+	}
+}
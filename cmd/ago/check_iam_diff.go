@@ -0,0 +1,405 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/advdv/ago/cmd/ago/internal/awsapi"
+	"github.com/advdv/ago/cmd/ago/internal/config"
+	"github.com/advdv/ago/cmd/ago/internal/ops"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudtrail"
+	cloudtrailtypes "github.com/aws/aws-sdk-go-v2/service/cloudtrail/types"
+	"github.com/cockroachdb/errors"
+	"github.com/urfave/cli/v3"
+)
+
+// maxCloudTrailLookbackDays is how far back CloudTrail event history goes.
+const maxCloudTrailLookbackDays = 90
+
+// maxIAMDiffEvents caps the events read per deployer and region, so that a busy deployer does not
+// turn the check into thousands of rate-limited lookups.
+const maxIAMDiffEvents = 5000
+
+func checkIAMDiffCmd() *cli.Command {
+	return &cli.Command{
+		Name: "iam-diff",
+		Usage: "Compare the deployer policy with the calls deployers made according to CloudTrail, " +
+			"reporting unused statements and denied actions",
+		Flags: []cli.Flag{
+			&cli.IntFlag{
+				Name:  "days",
+				Usage: "Number of days of CloudTrail event history to analyze (at most 90)",
+				Value: 30,
+			},
+		},
+		Action: config.RunWithConfig(runCheckIAMDiff),
+	}
+}
+
+type checkIAMDiffOptions struct {
+	Days    int
+	Profile string
+	Now     time.Time
+	Output  io.Writer
+	Result  io.Writer
+}
+
+func runCheckIAMDiff(ctx context.Context, cmd *cli.Command, cfg config.Config) error {
+	output, result := commandOutput(cmd)
+	return doCheckIAMDiff(ctx, cfg, checkIAMDiffOptions{
+		Days:    int(cmd.Int("days")),
+		Profile: cmd.String("profile"),
+		Now:     time.Now(),
+		Output:  output,
+		Result:  result,
+	})
+}
+
+// deployerEvent is a CloudTrail event of a call that a deployer made with their own credentials.
+// Calls made through the CDK roles are recorded for the role session and are not covered by the
+// deployer policy.
+type deployerEvent struct {
+	Action    string
+	ErrorCode string
+	Principal string
+}
+
+// iamDiffStatement is an Allow statement of the deployer policy with the actions it grants that
+// no deployer used.
+type iamDiffStatement struct {
+	Sid           string   `json:"sid"`
+	Actions       []string `json:"actions"`
+	UnusedActions []string `json:"unusedActions"`
+	Exercised     bool     `json:"exercised"`
+}
+
+// iamDiffDenied is an action that deployers attempted and were denied.
+type iamDiffDenied struct {
+	Action     string   `json:"action"`
+	Count      int      `json:"count"`
+	Principals []string `json:"principals"`
+}
+
+type iamDiffResult struct {
+	Policy     string             `json:"policy"`
+	VersionID  string             `json:"versionId"`
+	Days       int                `json:"days"`
+	Events     int                `json:"events"`
+	Statements []iamDiffStatement `json:"statements"`
+	Denied     []iamDiffDenied    `json:"denied"`
+}
+
+func doCheckIAMDiff(ctx context.Context, cfg config.Config, opts checkIAMDiffOptions) error {
+	if opts.Days < 1 || opts.Days > maxCloudTrailLookbackDays {
+		return errors.Errorf("--days must be between 1 and %d, got %d", maxCloudTrailLookbackDays, opts.Days)
+	}
+
+	cdk, err := loadCDKContext(cfg)
+	if err != nil {
+		return err
+	}
+
+	profile, err := resolveAWSProfile(opts.Profile, func() (string, error) { return getAdminProfile(cdk.Context) })
+	if err != nil {
+		return err
+	}
+
+	if err := cdk.Require("primary-region"); err != nil {
+		return err
+	}
+	primaryRegion := cdk.PrimaryRegion
+
+	principals := slices.Concat(
+		cdk.Deployers,
+		cdk.DevDeployers,
+	)
+	if len(principals) == 0 {
+		return errors.Errorf("no deployers found at context keys %q and %q",
+			cdk.Prefix+"deployers", cdk.Prefix+"dev-deployers")
+	}
+
+	clients, err := awsapi.New(ctx, profile, primaryRegion)
+	if err != nil {
+		return err
+	}
+	accountID, err := ops.AccountID(ctx, clients)
+	if err != nil {
+		return err
+	}
+	partition, err := getAWSPartition(ctx, clients)
+	if err != nil {
+		return err
+	}
+
+	policy, err := getManagedPolicy(ctx, clients, partition, accountID, cdk.Qualifier+"-deployer-policy")
+	if err != nil {
+		return err
+	}
+	statements, err := parsePolicyStatements(policy.Document)
+	if err != nil {
+		return errors.Wrapf(err, "failed to parse policy %q", policy.Name)
+	}
+
+	// IAM and other global services record their events in us-east-1.
+	regions := cdk.Regions()
+	if !slices.Contains(regions, "us-east-1") {
+		regions = append(regions, "us-east-1")
+	}
+
+	start := opts.Now.AddDate(0, 0, -opts.Days)
+	var events []deployerEvent
+	for _, region := range regions {
+		for _, principal := range principals {
+			writeOutputf(opts.Output, "Reading CloudTrail events of %s in %s...\n", principal, region)
+			found, err := lookupDeployerEvents(ctx, profile, region, principal, start)
+			if err != nil {
+				return err
+			}
+			events = append(events, found...)
+		}
+	}
+
+	result := iamDiffResult{
+		Policy:     policy.Name,
+		VersionID:  policy.VersionID,
+		Days:       opts.Days,
+		Events:     len(events),
+		Statements: diffPolicyStatements(statements, events),
+		Denied:     deniedActions(events),
+	}
+
+	reportIAMDiff(opts.Output, result)
+	return writeResult(opts.Result, result)
+}
+
+// policyStatement is a statement of an IAM policy document with its actions normalized to a list.
+type policyStatement struct {
+	Sid     string
+	Effect  string
+	Actions []string
+}
+
+func parsePolicyStatements(document []byte) ([]policyStatement, error) {
+	var doc struct {
+		Statement json.RawMessage `json:"Statement"` //nolint:tagliatelle // IAM uses PascalCase
+	}
+	if err := json.Unmarshal(document, &doc); err != nil {
+		return nil, errors.Wrap(err, "failed to parse policy document")
+	}
+
+	var raw []struct {
+		Sid    string          `json:"Sid"`    //nolint:tagliatelle // IAM uses PascalCase
+		Effect string          `json:"Effect"` //nolint:tagliatelle // IAM uses PascalCase
+		Action json.RawMessage `json:"Action"` //nolint:tagliatelle // IAM uses PascalCase
+	}
+	if len(doc.Statement) > 0 && doc.Statement[0] == '{' {
+		doc.Statement = append(append([]byte{'['}, doc.Statement...), ']')
+	}
+	if err := json.Unmarshal(doc.Statement, &raw); err != nil {
+		return nil, errors.Wrap(err, "failed to parse policy statements")
+	}
+
+	statements := make([]policyStatement, 0, len(raw))
+	for i, r := range raw {
+		actions, err := stringOrStrings(r.Action)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to parse actions of statement %d", i)
+		}
+		sid := r.Sid
+		if sid == "" {
+			sid = "#" + strconv.Itoa(i)
+		}
+		statements = append(statements, policyStatement{Sid: sid, Effect: r.Effect, Actions: actions})
+	}
+	return statements, nil
+}
+
+// stringOrStrings decodes a policy element that is either a single string or a list of strings.
+func stringOrStrings(data json.RawMessage) ([]string, error) {
+	if len(data) == 0 {
+		return nil, nil
+	}
+	var one string
+	if err := json.Unmarshal(data, &one); err == nil {
+		return []string{one}, nil
+	}
+	var many []string
+	if err := json.Unmarshal(data, &many); err != nil {
+		return nil, err
+	}
+	return many, nil
+}
+
+// lookupDeployerEvents returns the CloudTrail management events of a deployer in a region since
+// start, up to maxIAMDiffEvents of them.
+func lookupDeployerEvents(
+	ctx context.Context, profile, region, principal string, start time.Time,
+) ([]deployerEvent, error) {
+	clients, err := awsapi.New(ctx, profile, region)
+	if err != nil {
+		return nil, err
+	}
+
+	var records []string
+	pages := cloudtrail.NewLookupEventsPaginator(clients.CloudTrail, &cloudtrail.LookupEventsInput{
+		LookupAttributes: []cloudtrailtypes.LookupAttribute{{
+			AttributeKey:   cloudtrailtypes.LookupAttributeKeyUsername,
+			AttributeValue: aws.String(principal),
+		}},
+		StartTime: aws.Time(start.UTC()),
+	})
+	for pages.HasMorePages() && len(records) < maxIAMDiffEvents {
+		page, err := pages.NextPage(ctx)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to look up CloudTrail events of %q in %s", principal, region)
+		}
+		for _, event := range page.Events {
+			records = append(records, aws.ToString(event.CloudTrailEvent))
+		}
+	}
+	if len(records) > maxIAMDiffEvents {
+		records = records[:maxIAMDiffEvents]
+	}
+	return parseDeployerEvents(records, principal)
+}
+
+// parseDeployerEvents returns the events of the CloudTrail records of a deployer.
+func parseDeployerEvents(records []string, principal string) ([]deployerEvent, error) {
+	events := make([]deployerEvent, 0, len(records))
+	for _, record := range records {
+		var e struct {
+			EventSource string `json:"eventSource"`
+			EventName   string `json:"eventName"`
+			ErrorCode   string `json:"errorCode"`
+		}
+		if err := json.Unmarshal([]byte(record), &e); err != nil {
+			return nil, errors.Wrap(err, "failed to parse CloudTrail event")
+		}
+		events = append(events, deployerEvent{
+			Action:    eventAction(e.EventSource, e.EventName),
+			ErrorCode: e.ErrorCode,
+			Principal: principal,
+		})
+	}
+	return events, nil
+}
+
+// eventSourcePrefixes maps the CloudTrail event sources whose host differs from the IAM service
+// prefix of their actions.
+var eventSourcePrefixes = map[string]string{
+	"monitoring": "cloudwatch",
+	"email":      "ses",
+}
+
+// eventAction returns the IAM action of a CloudTrail event, such as ssm:GetParameter for event
+// GetParameter from ssm.amazonaws.com.
+func eventAction(source, name string) string {
+	prefix, _, _ := strings.Cut(source, ".")
+	if mapped, ok := eventSourcePrefixes[prefix]; ok {
+		prefix = mapped
+	}
+	return prefix + ":" + name
+}
+
+// isDeniedEvent reports whether the call of an event failed for lack of permissions.
+func isDeniedEvent(e deployerEvent) bool {
+	return strings.Contains(e.ErrorCode, "AccessDenied") || strings.Contains(e.ErrorCode, "UnauthorizedOperation")
+}
+
+// actionMatches reports whether an action of a policy, which may contain wildcards, covers the
+// action of an event. Actions are case-insensitive.
+func actionMatches(pattern, action string) bool {
+	ok, err := path.Match(strings.ToLower(pattern), strings.ToLower(action))
+	return err == nil && ok
+}
+
+// diffPolicyStatements returns the Allow statements with the actions that no event exercised.
+// Denied calls do not exercise a statement.
+func diffPolicyStatements(statements []policyStatement, events []deployerEvent) []iamDiffStatement {
+	diffs := make([]iamDiffStatement, 0, len(statements))
+	for _, s := range statements {
+		if s.Effect != "Allow" {
+			continue
+		}
+		diff := iamDiffStatement{Sid: s.Sid, Actions: s.Actions, UnusedActions: []string{}}
+		for _, pattern := range s.Actions {
+			used := slices.ContainsFunc(events, func(e deployerEvent) bool {
+				return !isDeniedEvent(e) && actionMatches(pattern, e.Action)
+			})
+			if used {
+				diff.Exercised = true
+			} else {
+				diff.UnusedActions = append(diff.UnusedActions, pattern)
+			}
+		}
+		diffs = append(diffs, diff)
+	}
+	return diffs
+}
+
+// deniedActions returns the actions that deployers were denied, most frequent first.
+func deniedActions(events []deployerEvent) []iamDiffDenied {
+	byAction := map[string]*iamDiffDenied{}
+	for _, e := range events {
+		if !isDeniedEvent(e) {
+			continue
+		}
+		d, ok := byAction[e.Action]
+		if !ok {
+			d = &iamDiffDenied{Action: e.Action}
+			byAction[e.Action] = d
+		}
+		d.Count++
+		if !slices.Contains(d.Principals, e.Principal) {
+			d.Principals = append(d.Principals, e.Principal)
+		}
+	}
+
+	denied := make([]iamDiffDenied, 0, len(byAction))
+	for _, d := range byAction {
+		slices.Sort(d.Principals)
+		denied = append(denied, *d)
+	}
+	slices.SortFunc(denied, func(a, b iamDiffDenied) int {
+		if a.Count != b.Count {
+			return b.Count - a.Count
+		}
+		return strings.Compare(a.Action, b.Action)
+	})
+	return denied
+}
+
+func reportIAMDiff(w io.Writer, result iamDiffResult) {
+	writeOutputf(w, "\nDeployer policy %s (%s), %d event(s) in the last %d day(s)\n",
+		result.Policy, result.VersionID, result.Events, result.Days)
+
+	writeOutputf(w, "\nStatements:\n")
+	for _, s := range result.Statements {
+		switch {
+		case !s.Exercised:
+			writeOutputf(w, "  UNUSED   %s\n", s.Sid)
+		case len(s.UnusedActions) > 0:
+			writeOutputf(w, "  PARTIAL  %s: unused %s\n", s.Sid, strings.Join(s.UnusedActions, ", "))
+		default:
+			writeOutputf(w, "  USED     %s\n", s.Sid)
+		}
+	}
+
+	writeOutputf(w, "\nDenied actions:\n")
+	if len(result.Denied) == 0 {
+		writeOutputf(w, "  none\n")
+	}
+	for _, d := range result.Denied {
+		writeOutputf(w, "  %-48s %4d  %s\n", d.Action, d.Count, strings.Join(d.Principals, ", "))
+	}
+
+	writeOutputf(w, "\nEvent history only holds management events: data events such as s3:GetObject "+
+		"never show up, so review unused statements before removing them from the pre-bootstrap template.\n")
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestDiffPolicyStatements(t *testing.T) {
+	t.Parallel()
+
+	statements, err := parsePolicyStatements([]byte(`{
+		"Version": "2012-10-17",
+		"Statement": [
+			{"Sid": "AssumeCDKRoles", "Effect": "Allow", "Action": ["sts:AssumeRole", "sts:TagSession"]},
+			{"Sid": "CloudFormationAccess", "Effect": "Allow", "Action": "cloudformation:Describe*"},
+			{"Sid": "CommandLocks", "Effect": "Allow", "Action": ["ssm:PutParameter"]},
+			{"Sid": "DenyAll", "Effect": "Deny", "Action": "*"}
+		]
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	events, err := parseDeployerEvents([]string{
+		`{"eventSource": "sts.amazonaws.com", "eventName": "AssumeRole"}`,
+		`{"eventSource": "cloudformation.amazonaws.com", "eventName": "DescribeStacks"}`,
+		`{"eventSource": "ssm.amazonaws.com", "eventName": "PutParameter", "errorCode": "AccessDenied"}`,
+		`{"eventSource": "ecr.amazonaws.com", "eventName": "DescribeRepositories", "errorCode": "AccessDeniedException"}`,
+		`{"eventSource": "ecr.amazonaws.com", "eventName": "DescribeRepositories", "errorCode": "AccessDeniedException"}`,
+	}, "adam")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	diffs := diffPolicyStatements(statements, events)
+	if len(diffs) != 3 {
+		t.Fatalf("expected 3 Allow statements, got %+v", diffs)
+	}
+	for _, tc := range []struct {
+		sid       string
+		exercised bool
+		unused    []string
+	}{
+		{"AssumeCDKRoles", true, []string{"sts:TagSession"}},
+		{"CloudFormationAccess", true, []string{}},
+		{"CommandLocks", false, []string{"ssm:PutParameter"}},
+	} {
+		i := slices.IndexFunc(diffs, func(d iamDiffStatement) bool { return d.Sid == tc.sid })
+		if i < 0 {
+			t.Fatalf("statement %s not found", tc.sid)
+		}
+		if diffs[i].Exercised != tc.exercised || !slices.Equal(diffs[i].UnusedActions, tc.unused) {
+			t.Errorf("%s: expected exercised %v and unused %v, got %+v", tc.sid, tc.exercised, tc.unused, diffs[i])
+		}
+	}
+
+	denied := deniedActions(events)
+	want := []iamDiffDenied{
+		{Action: "ecr:DescribeRepositories", Count: 2, Principals: []string{"adam"}},
+		{Action: "ssm:PutParameter", Count: 1, Principals: []string{"adam"}},
+	}
+	if len(denied) != len(want) {
+		t.Fatalf("expected %+v, got %+v", want, denied)
+	}
+	for i := range want {
+		if denied[i].Action != want[i].Action || denied[i].Count != want[i].Count ||
+			!slices.Equal(denied[i].Principals, want[i].Principals) {
+			t.Errorf("expected %+v, got %+v", want[i], denied[i])
+		}
+	}
+}
+
+func TestEventAction(t *testing.T) {
+	t.Parallel()
+
+	if got := eventAction("monitoring.amazonaws.com", "PutMetricData"); got != "cloudwatch:PutMetricData" {
+		t.Errorf("expected cloudwatch:PutMetricData, got %q", got)
+	}
+	if got := eventAction("ssm.amazonaws.com", "GetParameter"); got != "ssm:GetParameter" {
+		t.Errorf("expected ssm:GetParameter, got %q", got)
+	}
+}
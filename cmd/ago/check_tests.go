@@ -12,5 +12,5 @@ import (
 func checkTests(ctx context.Context, _ *cli.Command, cfg config.Config) error {
 	exec := cmdexec.New(cfg).WithOutput(os.Stdout, os.Stderr)
 
-	return runInGoModules(ctx, exec, "go", "test", "./...")
+	return runInGoModules(ctx, cfg, exec, "go", "test", "./...")
 }
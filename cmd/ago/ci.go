@@ -0,0 +1,55 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/advdv/ago/cmd/ago/internal/cmdexec"
+	"github.com/cockroachdb/errors"
+	"github.com/urfave/cli/v3"
+)
+
+func ciCmd() *cli.Command {
+	return &cli.Command{
+		Name:  "ci",
+		Usage: "Set up continuous delivery for the project",
+		Commands: []*cli.Command{
+			ciGeneratePipelineCmd(),
+			ciGenerateWorkflowCmd(),
+			ciRepoSetupCmd(),
+		},
+	}
+}
+
+// writeGeneratedFile writes a generated file at the path relative to the project directory. When
+// the file exists with other content, the changes are shown before it is replaced. It reports
+// whether the file was written, which it is not when it is up to date.
+func writeGeneratedFile(output io.Writer, projectDir, relPath string, rendered []byte) (bool, error) {
+	path := filepath.Join(projectDir, relPath)
+	current, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return false, errors.Wrapf(err, "failed to read %s", relPath)
+	}
+	if bytes.Equal(current, rendered) {
+		writeOutputf(output, "%s is up to date.\n", relPath)
+		return false, nil
+	}
+	if len(current) > 0 {
+		writeOutputf(output, "--- %s (current)\n+++ %s (generated)\n", relPath, relPath)
+		for _, line := range lineDiff(string(current), string(rendered)) {
+			writeOutputf(output, "%s\n", line)
+		}
+	}
+
+	if err := cmdexec.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return false, errors.Wrapf(err, "failed to create the directory of %s", relPath)
+	}
+	//nolint:gosec // generated file needs to be readable
+	if err := cmdexec.WriteFile(path, rendered, 0o644); err != nil {
+		return false, errors.Wrapf(err, "failed to write %s", relPath)
+	}
+	writeOutputf(output, "Wrote %s.\n", relPath)
+	return true, nil
+}
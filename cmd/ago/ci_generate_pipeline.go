@@ -0,0 +1,299 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"text/template"
+
+	"github.com/advdv/ago/cmd/ago/internal/config"
+	"github.com/cockroachdb/errors"
+	"github.com/urfave/cli/v3"
+)
+
+// pipelineStyleCDKPipelines generates a self-mutating CodePipeline with CDK Pipelines.
+const pipelineStyleCDKPipelines = "cdk-pipelines"
+
+// githubRepositoryPattern matches a GitHub repository in owner/name form.
+var githubRepositoryPattern = regexp.MustCompile(`^[A-Za-z0-9-]+/[A-Za-z0-9._-]+$`)
+
+func validateGitHubRepository(repo string) error {
+	if !githubRepositoryPattern.MatchString(repo) {
+		return errors.Errorf("invalid GitHub repository %q, expected owner/name", repo)
+	}
+	return nil
+}
+
+func ciGeneratePipelineCmd() *cli.Command {
+	return &cli.Command{
+		Name: "generate-pipeline",
+		Usage: "Generate infra/cdk/pipeline.go, a pipeline stack that builds the project from GitHub and " +
+			"deploys it in waves of deployments",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:  "style",
+				Usage: "Style of the pipeline (cdk-pipelines: a self-mutating CodePipeline built with CDK Pipelines)",
+				Value: pipelineStyleCDKPipelines,
+			},
+			&cli.StringFlag{
+				Name:     "github-repo",
+				Usage:    "GitHub repository the pipeline builds, as owner/name",
+				Required: true,
+			},
+			&cli.StringFlag{
+				Name:  "branch",
+				Usage: "Branch the pipeline builds",
+				Value: "main",
+			},
+			&cli.StringFlag{
+				Name:     "connection-arn",
+				Usage:    "ARN of the CodeConnections connection to GitHub that the pipeline reads the source with",
+				Required: true,
+			},
+		},
+		Action: config.RunWithConfig(runCIGeneratePipeline),
+	}
+}
+
+type generatePipelineOptions struct {
+	Style         string
+	Repository    string
+	Branch        string
+	ConnectionARN string
+	Output        io.Writer
+}
+
+func runCIGeneratePipeline(_ context.Context, cmd *cli.Command, cfg config.Config) error {
+	return doCIGeneratePipeline(cfg, generatePipelineOptions{
+		Style:         cmd.String("style"),
+		Repository:    cmd.String("github-repo"),
+		Branch:        cmd.String("branch"),
+		ConnectionARN: cmd.String("connection-arn"),
+		Output:        os.Stdout,
+	})
+}
+
+// pipelineConfig is the input of the pipeline template.
+type pipelineConfig struct {
+	Prefix        string
+	Qualifier     string
+	Repository    string
+	Branch        string
+	ConnectionARN string
+	Waves         []deploymentWave
+}
+
+// doCIGeneratePipeline renders the pipeline stack into the CDK package. An existing file is
+// replaced because the waves follow the deployments in the context.
+func doCIGeneratePipeline(cfg config.Config, opts generatePipelineOptions) error {
+	if opts.Style != pipelineStyleCDKPipelines {
+		return errors.Errorf("unsupported pipeline style %q, supported styles: %s",
+			opts.Style, pipelineStyleCDKPipelines)
+	}
+	if err := validateGitHubRepository(opts.Repository); err != nil {
+		return err
+	}
+
+	cdk, err := loadCDKContext(cfg)
+	if err != nil {
+		return err
+	}
+
+	deployments := cdk.Deployments
+	if len(deployments) == 0 {
+		return errors.Errorf("no deployments found at context key %q", cdk.Prefix+"deployments")
+	}
+
+	rendered, err := renderPipeline(pipelineConfig{
+		Prefix:        cdk.Prefix,
+		Qualifier:     cdk.Qualifier,
+		Repository:    opts.Repository,
+		Branch:        opts.Branch,
+		ConnectionARN: opts.ConnectionARN,
+		Waves:         deploymentWaves(deployments),
+	})
+	if err != nil {
+		return err
+	}
+
+	// The pipeline belongs to the Go module of the CDK app, one level above cdk.json.
+	pipelinePath := filepath.Join(filepath.Dir(cfg.Inner.Layout.CDK()), "pipeline.go")
+	written, err := writeGeneratedFile(opts.Output, cfg.ProjectDir, pipelinePath, rendered)
+	if err != nil || !written {
+		return err
+	}
+
+	writeOutputf(opts.Output, "\nNext steps:\n")
+	writeOutputf(opts.Output, "  1. Call cdk.NewPipeline(app) in infra/cdk/cdk/cdk.go, after agcdkutil.SetupApp\n")
+	writeOutputf(opts.Output, "  2. Run 'go mod tidy' in infra\n")
+	writeOutputf(opts.Output, "  3. Deploy the pipeline once: cdk deploy %sPipeline (from infra/cdk/cdk)\n", cdk.Qualifier)
+	writeOutputf(opts.Output, "After that the pipeline updates itself from %s@%s.\n", opts.Repository, opts.Branch)
+	return nil
+}
+
+func renderPipeline(cfg pipelineConfig) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := cdkPipelineTemplate.Execute(&buf, cfg); err != nil {
+		return nil, errors.Wrap(err, "failed to execute pipeline template")
+	}
+	return buf.Bytes(), nil
+}
+
+// deploymentWave is a group of deployments of the same class that are deployed side by side.
+// Waves of restricted deployments wait for a manual approval.
+type deploymentWave struct {
+	Class       string
+	Deployments []string
+	Approval    bool
+}
+
+// deploymentClassPrefixes are the prefixes that make up the well-known classes of deployments.
+var deploymentClassPrefixes = []string{"Dev", "Stag", "Prod"}
+
+// deploymentClass returns the class of a deployment: Dev, Stag or Prod for deployments that
+// start with it, otherwise the deployment itself.
+func deploymentClass(deployment string) string {
+	for _, class := range deploymentClassPrefixes {
+		if strings.HasPrefix(deployment, class) {
+			return class
+		}
+	}
+	return deployment
+}
+
+// deploymentWaves groups the deployments by class, in the order the classes are deployed: Dev
+// first, then other deployments in alphabetical order, then Stag and Prod last.
+func deploymentWaves(deployments []string) []deploymentWave {
+	byClass := map[string][]string{}
+	for _, d := range deployments {
+		byClass[deploymentClass(d)] = append(byClass[deploymentClass(d)], d)
+	}
+
+	rank := func(class string) int {
+		switch class {
+		case "Dev":
+			return 0
+		case "Stag":
+			return 2
+		case "Prod":
+			return 3
+		default:
+			return 1
+		}
+	}
+
+	classes := make([]string, 0, len(byClass))
+	for class := range byClass {
+		classes = append(classes, class)
+	}
+	slices.SortFunc(classes, func(a, b string) int {
+		if ra, rb := rank(a), rank(b); ra != rb {
+			return ra - rb
+		}
+		return strings.Compare(a, b)
+	})
+
+	waves := make([]deploymentWave, 0, len(classes))
+	for _, class := range classes {
+		waves = append(waves, deploymentWave{
+			Class:       class,
+			Deployments: byClass[class],
+			Approval:    isRestrictedDeployment(class),
+		})
+	}
+	return waves
+}
+
+var cdkPipelineTemplate = template.Must(template.New("pipeline.go").Parse(`package cdk
+
+// Code generated by 'ago ci generate-pipeline'. Re-run it when deployments are added or removed.
+
+import (
+	"os"
+
+	"github.com/advdv/ago/agcdkutil"
+	"github.com/aws/aws-cdk-go/awscdk/v2"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awsiam"
+	"github.com/aws/aws-cdk-go/awscdk/v2/pipelines"
+	"github.com/aws/jsii-runtime-go"
+)
+
+// NewPipeline creates the {{.Qualifier}}Pipeline stack: a self-mutating pipeline that builds the app
+// from {{.Repository}}@{{.Branch}} and deploys the Shared stacks, then every wave of deployments.
+// Call it after agcdkutil.SetupApp, which makes the project configuration available.
+func NewPipeline(app awscdk.App) {
+	cfg := agcdkutil.ConfigFromScope(app)
+	stack := awscdk.NewStack(app, jsii.String("{{.Qualifier}}Pipeline"), &awscdk.StackProps{
+		Env: &awscdk.Environment{
+			Account: jsii.String(os.Getenv("CDK_DEFAULT_ACCOUNT")),
+			Region:  jsii.String(cfg.PrimaryRegion),
+		},
+		Description: jsii.String("Delivery pipeline of {{.Qualifier}}"),
+		Synthesizer: awscdk.NewDefaultStackSynthesizer(&awscdk.DefaultStackSynthesizerProps{
+			Qualifier: jsii.String(cfg.Qualifier),
+		}),
+	})
+
+	source := pipelines.CodePipelineSource_Connection(jsii.String("{{.Repository}}"), jsii.String("{{.Branch}}"),
+		&pipelines.ConnectionSourceOptions{
+			ConnectionArn: jsii.String("{{.ConnectionARN}}"),
+		})
+
+	synth := pipelines.NewShellStep(jsii.String("Synth"), &pipelines.ShellStepProps{
+		Input: source,
+		Commands: jsii.Strings(
+			"curl -fsSL https://mise.run | sh",
+			"export PATH=\"$HOME/.local/bin:$HOME/.local/share/mise/shims:$PATH\"",
+			"mise trust --yes && mise install",
+			"cd infra/cdk/cdk && mise exec -- cdk synth -c {{.Prefix}}deployer-groups={{.Qualifier}}-deployers",
+		),
+		PrimaryOutputDirectory: jsii.String("infra/cdk/cdk/cdk.out"),
+	})
+
+	pipeline := pipelines.NewCodePipeline(stack, jsii.String("Pipeline"), &pipelines.CodePipelineProps{
+		PipelineName: jsii.String("{{.Qualifier}}-pipeline"),
+		Synth:        synth,
+		SelfMutation: jsii.Bool(true),
+	})
+
+	pipeline.AddWave(jsii.String("Shared"), &pipelines.WaveOptions{
+		Post: &[]pipelines.Step{pipelineDeployStep("Shared", synth, "{{.Qualifier}}*Shared")},
+	})
+{{- range .Waves}}
+
+	pipeline.AddWave(jsii.String("{{.Class}}"), &pipelines.WaveOptions{
+{{- if .Approval}}
+		Pre: &[]pipelines.Step{pipelines.NewManualApprovalStep(jsii.String("Approve{{.Class}}"), nil)},
+{{- end}}
+		Post: &[]pipelines.Step{
+{{- range .Deployments}}
+			pipelineDeployStep("{{.}}", synth, "{{$.Qualifier}}*{{.}}"),
+{{- end}}
+		},
+	})
+{{- end}}
+}
+
+// pipelineDeployStep deploys the stacks that match the pattern from the synthesized cloud
+// assembly. Dependencies are deployed by their own wave, so only the matching stacks are deployed.
+func pipelineDeployStep(name string, synth pipelines.ShellStep, pattern string) pipelines.Step {
+	return pipelines.NewCodeBuildStep(jsii.String("Deploy"+name), &pipelines.CodeBuildStepProps{
+		Input: synth.PrimaryOutput(),
+		Commands: jsii.Strings(
+			"npx --yes aws-cdk@2 deploy --app . --exclusively --require-approval never " +
+				"--qualifier {{.Qualifier}} --toolkit-stack-name {{.Qualifier}}Bootstrap '" + pattern + "'",
+		),
+		RolePolicyStatements: &[]awsiam.PolicyStatement{
+			awsiam.NewPolicyStatement(&awsiam.PolicyStatementProps{
+				Actions:   jsii.Strings("sts:AssumeRole"),
+				Resources: jsii.Strings("arn:*:iam::*:role/cdk-{{.Qualifier}}-*"),
+			}),
+		},
+	})
+}
+`))
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"bytes"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/advdv/ago/cmd/ago/internal/config"
+)
+
+func TestDeploymentWaves(t *testing.T) {
+	t.Parallel()
+
+	got := deploymentWaves([]string{"Prod", "DevBob", "Stag", "Preview", "DevAlice", "Demo"})
+	want := []deploymentWave{
+		{Class: "Dev", Deployments: []string{"DevBob", "DevAlice"}},
+		{Class: "Demo", Deployments: []string{"Demo"}},
+		{Class: "Preview", Deployments: []string{"Preview"}},
+		{Class: "Stag", Deployments: []string{"Stag"}, Approval: true},
+		{Class: "Prod", Deployments: []string{"Prod"}, Approval: true},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("deploymentWaves() = %+v, want %+v", got, want)
+	}
+}
+
+func TestCIGeneratePipeline(t *testing.T) {
+	t.Parallel()
+
+	cfg := config.Config{ProjectDir: t.TempDir()}
+	cdkDir := cfg.CDKDir()
+	if err := os.MkdirAll(cdkDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range map[string]string{
+		"cdk.json":         `{"app": "go run ."}`,
+		"cdk.context.json": `{"myapp-qualifier": "myapp", "myapp-deployments": ["DevAlice", "Stag", "Prod"]}`,
+	} {
+		if err := os.WriteFile(filepath.Join(cdkDir, name), []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	opts := generatePipelineOptions{
+		Style:         pipelineStyleCDKPipelines,
+		Repository:    "acme/myapp",
+		Branch:        "main",
+		ConnectionARN: "arn:aws:codeconnections:eu-central-1:123456789012:connection/abc",
+		Output:        &bytes.Buffer{},
+	}
+	if err := doCIGeneratePipeline(cfg, opts); err != nil {
+		t.Fatal(err)
+	}
+
+	pipelinePath := filepath.Join(filepath.Dir(cdkDir), "pipeline.go")
+	data, err := os.ReadFile(pipelinePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := parser.ParseFile(token.NewFileSet(), pipelinePath, data, 0); err != nil {
+		t.Fatalf("generated pipeline.go does not parse: %v\n%s", err, data)
+	}
+	for _, want := range []string{
+		`jsii.String("myappPipeline")`,
+		`CodePipelineSource_Connection(jsii.String("acme/myapp"), jsii.String("main")`,
+		`pipelineDeployStep("DevAlice", synth, "myapp*DevAlice")`,
+		`NewManualApprovalStep(jsii.String("ApproveProd"), nil)`,
+		`myapp-deployer-groups=myapp-deployers`,
+	} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("expected pipeline.go to contain %q", want)
+		}
+	}
+
+	var out bytes.Buffer
+	opts.Output = &out
+	if err := doCIGeneratePipeline(cfg, opts); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "up to date") {
+		t.Errorf("expected a second run to report the file is up to date, got %q", out.String())
+	}
+
+	opts.Style = "codebuild"
+	if err := doCIGeneratePipeline(cfg, opts); err == nil {
+		t.Error("expected an error for an unsupported style")
+	}
+}
@@ -0,0 +1,252 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"text/template"
+
+	"github.com/advdv/ago/agcdkutil"
+	"github.com/advdv/ago/cmd/ago/internal/awsapi"
+	"github.com/advdv/ago/cmd/ago/internal/cdkcontext"
+	"github.com/advdv/ago/cmd/ago/internal/config"
+	"github.com/advdv/ago/cmd/ago/internal/ops"
+	"github.com/cockroachdb/errors"
+	"github.com/urfave/cli/v3"
+)
+
+// workflowPath is where the generated GitHub Actions workflow is written, relative to the project.
+var workflowPath = filepath.Join(".github", "workflows", "deploy.yml")
+
+// workflowChecks are the 'ago check' checks that the workflow runs. The other checks look at local
+// profiles and tools that CI does not have.
+var workflowChecks = []string{"mise-tools", "cdk-context", "bootstrap-version"}
+
+var accountIDPattern = regexp.MustCompile(`^\d{12}$`)
+
+func ciGenerateWorkflowCmd() *cli.Command {
+	return &cli.Command{
+		Name: "generate-workflow",
+		Usage: "Generate .github/workflows/deploy.yml, a GitHub Actions workflow that checks the project " +
+			"and deploys it on the main branch with the CI deployer role",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:  "branch",
+				Usage: "Branch that deploys when pushed to",
+				Value: "main",
+			},
+			&cli.StringSliceFlag{
+				Name:  "deploy",
+				Usage: "Deployment that the workflow deploys, in order (repeatable, default: all but the Dev deployments)",
+			},
+			&cli.StringFlag{
+				Name:  "account-id",
+				Usage: "AWS account of the project (default: the account of the project's profile)",
+			},
+		},
+		Action: config.RunWithConfig(runCIGenerateWorkflow),
+	}
+}
+
+type generateWorkflowOptions struct {
+	Branch      string
+	Deployments []string
+	AccountID   string
+	Profile     string
+	Output      io.Writer
+}
+
+func runCIGenerateWorkflow(ctx context.Context, cmd *cli.Command, cfg config.Config) error {
+	return doCIGenerateWorkflow(ctx, cfg, generateWorkflowOptions{
+		Branch:      cmd.String("branch"),
+		Deployments: cmd.StringSlice("deploy"),
+		AccountID:   cmd.String("account-id"),
+		Profile:     cmd.String("profile"),
+		Output:      os.Stdout,
+	})
+}
+
+// workflowConfig is the input of the workflow template.
+type workflowConfig struct {
+	Branch      string
+	Region      string
+	RoleARN     string
+	Checks      []string
+	Deployments []string
+}
+
+// doCIGenerateWorkflow renders the deploy workflow. The workflow assumes the CI deployer role that
+// the pre-bootstrap stack created, through the GitHub OIDC provider, so no credentials are stored
+// in the repository.
+func doCIGenerateWorkflow(ctx context.Context, cfg config.Config, opts generateWorkflowOptions) error {
+	cdk, err := loadCDKContext(cfg)
+	if err != nil {
+		return err
+	}
+
+	if err := cdk.Require("primary-region"); err != nil {
+		return err
+	}
+	primaryRegion := cdk.PrimaryRegion
+
+	deployments, err := workflowDeployments(cdk.Deployments, opts.Deployments)
+	if err != nil {
+		return err
+	}
+
+	accountID := opts.AccountID
+	if accountID == "" {
+		if accountID, err = projectAccountID(ctx, cdk.Context, opts.Profile, primaryRegion); err != nil {
+			return errors.Wrap(err, "failed to determine the account, pass it with --account-id")
+		}
+	}
+	if !accountIDPattern.MatchString(accountID) {
+		return errors.Errorf("invalid AWS account ID %q", accountID)
+	}
+
+	rendered, err := renderWorkflow(workflowConfig{
+		Branch:      opts.Branch,
+		Region:      primaryRegion,
+		RoleARN:     ciDeployerRoleARN(primaryRegion, accountID, cdk.Qualifier),
+		Checks:      workflowChecks,
+		Deployments: deployments,
+	})
+	if err != nil {
+		return err
+	}
+
+	if _, err := writeGeneratedFile(opts.Output, cfg.ProjectDir, workflowPath, rendered); err != nil {
+		return err
+	}
+
+	if ciRepository(cdk.Context) == "" {
+		writeOutputf(opts.Output, "\nThe CI deployer role only exists once it trusts the repository, run:\n")
+		writeOutputf(opts.Output, "  ago infra cdk update-ci-trust --github-repo <owner>/<name>\n")
+	}
+	return nil
+}
+
+// workflowDeployments returns the deployments the workflow deploys: the selected ones, or all
+// deployments but the personal Dev deployments, in wave order.
+func workflowDeployments(all, selected []string) ([]string, error) {
+	for _, d := range selected {
+		if !slices.Contains(all, d) {
+			return nil, errors.Errorf("deployment %q not found\n\nAvailable deployments: %s",
+				d, formatDeploymentsList(all))
+		}
+	}
+	if len(selected) > 0 {
+		return selected, nil
+	}
+
+	var deployments []string
+	for _, wave := range deploymentWaves(all) {
+		if wave.Class != "Dev" {
+			deployments = append(deployments, wave.Deployments...)
+		}
+	}
+	if len(deployments) == 0 {
+		return nil, errors.New("the project has only Dev deployments, select the ones to deploy with --deploy")
+	}
+	return deployments, nil
+}
+
+// projectAccountID returns the account that the project's profile belongs to.
+func projectAccountID(ctx context.Context, cdkCtx *cdkcontext.Context, profileFlag, region string) (string, error) {
+	profile, err := resolveAWSProfile(profileFlag, func() (string, error) { return getCDKProfile(cdkCtx) })
+	if err != nil {
+		return "", err
+	}
+	clients, err := awsapi.New(ctx, profile, region)
+	if err != nil {
+		return "", err
+	}
+	return ops.AccountID(ctx, clients)
+}
+
+// ciDeployerRoleARN returns the ARN of the CI deployer role, in the partition of the region.
+func ciDeployerRoleARN(region, accountID, qualifier string) string {
+	return agcdkutil.ARN(agcdkutil.PartitionFor(region), "iam", "", accountID,
+		"role/"+ops.CIDeployerRoleName(qualifier))
+}
+
+func renderWorkflow(cfg workflowConfig) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := deployWorkflowTemplate.Execute(&buf, cfg); err != nil {
+		return nil, errors.Wrap(err, "failed to execute workflow template")
+	}
+	return buf.Bytes(), nil
+}
+
+// deployWorkflowTemplate uses [[ ]] as delimiters, GitHub Actions expressions use ${{ }}.
+var deployWorkflowTemplate = template.Must(template.New("deploy.yml").Delims("[[", "]]").Parse(
+	`# Generated by 'ago ci generate-workflow'.
+name: deploy
+
+on:
+  push:
+    branches:
+      - [[.Branch]]
+  pull_request:
+  workflow_dispatch:
+
+permissions:
+  id-token: write
+  contents: read
+
+concurrency:
+  group: deploy-${{ github.ref }}
+  cancel-in-progress: false
+
+env:
+  CI: "true"
+
+jobs:
+  check:
+    runs-on: ubuntu-latest
+    steps:
+      - uses: actions/checkout@v4
+      - uses: jdx/mise-action@v2
+      - uses: aws-actions/configure-aws-credentials@v4
+        with:
+          role-to-assume: [[.RoleARN]]
+          aws-region: [[.Region]]
+      - name: Configure the ci profile
+        run: |
+          aws configure set aws_access_key_id "$AWS_ACCESS_KEY_ID" --profile ci
+          aws configure set aws_secret_access_key "$AWS_SECRET_ACCESS_KEY" --profile ci
+          aws configure set aws_session_token "$AWS_SESSION_TOKEN" --profile ci
+          aws configure set region "$AWS_REGION" --profile ci
+          echo "AWS_PROFILE=ci" >> "$GITHUB_ENV"
+      - name: Check
+        run: ago check[[range .Checks]] --only [[.]][[end]]
+      - name: Synth
+        run: ago infra cdk ls
+
+  deploy:
+    if: github.event_name == 'push' && github.ref == 'refs/heads/[[.Branch]]'
+    needs: check
+    runs-on: ubuntu-latest
+    steps:
+      - uses: actions/checkout@v4
+      - uses: jdx/mise-action@v2
+      - uses: aws-actions/configure-aws-credentials@v4
+        with:
+          role-to-assume: [[.RoleARN]]
+          aws-region: [[.Region]]
+      - name: Configure the ci profile
+        run: |
+          aws configure set aws_access_key_id "$AWS_ACCESS_KEY_ID" --profile ci
+          aws configure set aws_secret_access_key "$AWS_SECRET_ACCESS_KEY" --profile ci
+          aws configure set aws_session_token "$AWS_SESSION_TOKEN" --profile ci
+          aws configure set region "$AWS_REGION" --profile ci
+          echo "AWS_PROFILE=ci" >> "$GITHUB_ENV"
+[[- range .Deployments]]
+      - name: Deploy [[.]]
+        run: ago infra cdk deploy [[.]] --yes
+[[- end]]
+`))
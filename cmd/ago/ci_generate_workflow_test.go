@@ -0,0 +1,105 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/advdv/ago/cmd/ago/internal/config"
+)
+
+func TestWorkflowDeployments(t *testing.T) {
+	t.Parallel()
+
+	all := []string{"Prod", "DevAlice", "Stag", "Preview"}
+
+	got, err := workflowDeployments(all, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"Preview", "Stag", "Prod"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("workflowDeployments() = %v, want %v", got, want)
+	}
+
+	got, err = workflowDeployments(all, []string{"DevAlice"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"DevAlice"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("workflowDeployments(DevAlice) = %v, want %v", got, want)
+	}
+
+	if _, err := workflowDeployments(all, []string{"Missing"}); err == nil {
+		t.Error("expected an error for an unknown deployment")
+	}
+	if _, err := workflowDeployments([]string{"DevAlice"}, nil); err == nil {
+		t.Error("expected an error when there are only Dev deployments")
+	}
+}
+
+func TestCIGenerateWorkflow(t *testing.T) {
+	t.Parallel()
+
+	cfg := config.Config{ProjectDir: t.TempDir()}
+	cdkDir := cfg.CDKDir()
+	if err := os.MkdirAll(cdkDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range map[string]string{
+		"cdk.json": `{"app": "go run ."}`,
+		"cdk.context.json": `{"myapp-qualifier": "myapp", "myapp-primary-region": "eu-central-1",
+			"myapp-deployments": ["DevAlice", "Stag", "Prod"]}`,
+	} {
+		if err := os.WriteFile(filepath.Join(cdkDir, name), []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	opts := generateWorkflowOptions{Branch: "main", AccountID: "123456789012", Output: &bytes.Buffer{}}
+	if err := doCIGenerateWorkflow(context.Background(), cfg, opts); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(cfg.ProjectDir, workflowPath))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"role-to-assume: arn:aws:iam::123456789012:role/myapp-ci-deployer",
+		"aws-region: eu-central-1",
+		"github.ref == 'refs/heads/main'",
+		"group: deploy-${{ github.ref }}",
+		"run: ago check --only mise-tools --only cdk-context --only bootstrap-version",
+		"run: ago infra cdk deploy Stag --yes\n      - name: Deploy Prod\n        run: ago infra cdk deploy Prod --yes",
+	} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("expected deploy.yml to contain %q, got:\n%s", want, data)
+		}
+	}
+	if strings.Contains(string(data), "DevAlice") {
+		t.Error("expected deploy.yml not to deploy Dev deployments")
+	}
+
+	opts.AccountID = "not-an-account"
+	if err := doCIGenerateWorkflow(context.Background(), cfg, opts); err == nil {
+		t.Error("expected an error for an invalid account ID")
+	}
+}
+
+func TestCIDeployerRoleARN(t *testing.T) {
+	t.Parallel()
+
+	for region, want := range map[string]string{
+		"eu-central-1":  "arn:aws:iam::123456789012:role/myapp-ci-deployer",
+		"us-gov-west-1": "arn:aws-us-gov:iam::123456789012:role/myapp-ci-deployer",
+		"cn-north-1":    "arn:aws-cn:iam::123456789012:role/myapp-ci-deployer",
+	} {
+		if got := ciDeployerRoleARN(region, "123456789012", "myapp"); got != want {
+			t.Errorf("ciDeployerRoleARN(%q) = %q, want %q", region, got, want)
+		}
+	}
+}
@@ -0,0 +1,251 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"strings"
+
+	"github.com/advdv/ago/cmd/ago/internal/cmdexec"
+	"github.com/advdv/ago/cmd/ago/internal/config"
+	"github.com/cockroachdb/errors"
+	"github.com/urfave/cli/v3"
+)
+
+// workflowCheckJob is the job of the generated workflow that checks pull requests, which branch
+// protection requires by default.
+const workflowCheckJob = "check"
+
+func ciRepoSetupCmd() *cli.Command {
+	return &cli.Command{
+		Name: "repo-setup",
+		Usage: "Configure the GitHub repository for the CI deployer role: protect the deploy branch, require " +
+			"the checks of the workflow and create an environment with the role's settings per deployment",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:  "github-repo",
+				Usage: "GitHub repository, as owner/name (default: the repository that the CI deployer role trusts)",
+			},
+			&cli.StringFlag{
+				Name:  "branch",
+				Usage: "Branch that deploys when pushed to",
+				Value: "main",
+			},
+			&cli.StringSliceFlag{
+				Name:  "required-check",
+				Usage: "Status check that must pass before a pull request merges (repeatable)",
+				Value: []string{workflowCheckJob},
+			},
+			&cli.IntFlag{
+				Name:  "reviews",
+				Usage: "Approving reviews that a pull request needs before it merges, 0 for none",
+				Value: 1,
+			},
+			&cli.StringSliceFlag{
+				Name: "deploy",
+				Usage: "Deployment to create an environment for (repeatable, default: all but the Dev " +
+					"deployments)",
+			},
+			&cli.StringFlag{
+				Name:  "account-id",
+				Usage: "AWS account of the project (default: the account of the project's profile)",
+			},
+		},
+		Action: config.RunWithConfig(runCIRepoSetup),
+	}
+}
+
+type repoSetupOptions struct {
+	Repository     string
+	Branch         string
+	RequiredChecks []string
+	Reviews        int
+	Deployments    []string
+	AccountID      string
+	Profile        string
+	Output         io.Writer
+	Result         io.Writer
+}
+
+func runCIRepoSetup(ctx context.Context, cmd *cli.Command, cfg config.Config) error {
+	output, result := commandOutput(cmd)
+	return doCIRepoSetup(ctx, cfg, repoSetupOptions{
+		Repository:     cmd.String("github-repo"),
+		Branch:         cmd.String("branch"),
+		RequiredChecks: cmd.StringSlice("required-check"),
+		Reviews:        cmd.Int("reviews"),
+		Deployments:    cmd.StringSlice("deploy"),
+		AccountID:      cmd.String("account-id"),
+		Profile:        cmd.String("profile"),
+		Output:         output,
+		Result:         result,
+	})
+}
+
+// repoSetupResult is the result of 'ago ci repo-setup' in the JSON output format.
+type repoSetupResult struct {
+	Repository     string   `json:"repository"`
+	Branch         string   `json:"branch"`
+	RequiredChecks []string `json:"requiredChecks"`
+	Environments   []string `json:"environments"`
+}
+
+// doCIRepoSetup configures the repository with the GitHub CLI. Every deployment that the workflow
+// deploys gets an environment of the same name whose secrets tell a job which role to assume, so
+// workflows other than the generated one can deploy without settings copied by hand.
+func doCIRepoSetup(ctx context.Context, cfg config.Config, opts repoSetupOptions) error {
+	if err := requireTools(ctx, cfg, "repo-setup"); err != nil {
+		return err
+	}
+
+	cdk, err := loadCDKContext(cfg)
+	if err != nil {
+		return err
+	}
+
+	repo := opts.Repository
+	if repo == "" {
+		repo = ciRepository(cdk.Context)
+	}
+	if repo == "" {
+		return errors.New("no GitHub repository, pass it with --github-repo or trust one with " +
+			"'ago infra cdk update-ci-trust'")
+	}
+	if err := validateGitHubRepository(repo); err != nil {
+		return err
+	}
+	if opts.Reviews < 0 {
+		return errors.Errorf("--reviews must not be negative, got %d", opts.Reviews)
+	}
+
+	if err := cdk.Require("primary-region"); err != nil {
+		return err
+	}
+	primaryRegion := cdk.PrimaryRegion
+
+	deployments, err := workflowDeployments(cdk.Deployments, opts.Deployments)
+	if err != nil {
+		return err
+	}
+
+	accountID := opts.AccountID
+	if accountID == "" {
+		if accountID, err = projectAccountID(ctx, cdk.Context, opts.Profile, primaryRegion); err != nil {
+			return errors.Wrap(err, "failed to determine the account, pass it with --account-id")
+		}
+	}
+	if !accountIDPattern.MatchString(accountID) {
+		return errors.Errorf("invalid AWS account ID %q", accountID)
+	}
+	roleARN := ciDeployerRoleARN(primaryRegion, accountID, cdk.Qualifier)
+
+	exec := cdk.Exec.WithOutput(opts.Output, opts.Output)
+
+	writeOutputf(opts.Output, "Protecting branch %s of %s...\n", opts.Branch, repo)
+	if err := ghAPIPut(ctx, exec, "repos/"+repo+"/branches/"+opts.Branch+"/protection",
+		newBranchProtection(opts.RequiredChecks, opts.Reviews)); err != nil {
+		return errors.Wrapf(err, "failed to protect branch %s", opts.Branch)
+	}
+
+	secrets := environmentSecrets(roleARN, primaryRegion, cdk.Qualifier)
+	for _, deployment := range deployments {
+		writeOutputf(opts.Output, "Configuring environment %s...\n", deployment)
+		if err := ghAPIPut(ctx, exec, "repos/"+repo+"/environments/"+deployment, newRepoEnvironment()); err != nil {
+			return errors.Wrapf(err, "failed to create environment %s", deployment)
+		}
+		for _, secret := range secrets {
+			if err := exec.RunWithStdin(ctx, strings.NewReader(secret.Value), "gh", "secret", "set", secret.Name,
+				"--repo", repo, "--env", deployment); err != nil {
+				return errors.Wrapf(err, "failed to set secret %s of environment %s", secret.Name, deployment)
+			}
+		}
+	}
+
+	writeOutputf(opts.Output, "\nConfigured %s: pull requests to %s need %s, environments %s\n", repo,
+		opts.Branch, strings.Join(opts.RequiredChecks, ", "), strings.Join(deployments, ", "))
+	return writeResult(opts.Result, repoSetupResult{
+		Repository:     repo,
+		Branch:         opts.Branch,
+		RequiredChecks: opts.RequiredChecks,
+		Environments:   deployments,
+	})
+}
+
+// branchProtection is the body of GitHub's update branch protection request. Every field must be
+// present, null turns a protection off.
+type branchProtection struct {
+	RequiredStatusChecks       *requiredStatusChecks       `json:"required_status_checks"`
+	EnforceAdmins              bool                        `json:"enforce_admins"`
+	RequiredPullRequestReviews *requiredPullRequestReviews `json:"required_pull_request_reviews"`
+	Restrictions               *struct{}                   `json:"restrictions"`
+}
+
+type requiredStatusChecks struct {
+	Strict   bool     `json:"strict"`
+	Contexts []string `json:"contexts"`
+}
+
+type requiredPullRequestReviews struct {
+	DismissStaleReviews          bool `json:"dismiss_stale_reviews"`
+	RequiredApprovingReviewCount int  `json:"required_approving_review_count"`
+}
+
+// newBranchProtection returns the protection of the deploy branch: pull requests must pass the
+// checks on an up-to-date branch and get the reviews before they merge, admins included.
+func newBranchProtection(checks []string, reviews int) branchProtection {
+	protection := branchProtection{EnforceAdmins: true}
+	if len(checks) > 0 {
+		protection.RequiredStatusChecks = &requiredStatusChecks{Strict: true, Contexts: checks}
+	}
+	if reviews > 0 {
+		protection.RequiredPullRequestReviews = &requiredPullRequestReviews{
+			DismissStaleReviews:          true,
+			RequiredApprovingReviewCount: reviews,
+		}
+	}
+	return protection
+}
+
+// repoEnvironment is the body of GitHub's create or update environment request.
+type repoEnvironment struct {
+	DeploymentBranchPolicy struct {
+		ProtectedBranches    bool `json:"protected_branches"`
+		CustomBranchPolicies bool `json:"custom_branch_policies"`
+	} `json:"deployment_branch_policy"`
+}
+
+// newRepoEnvironment returns an environment that only protected branches deploy to, so that its
+// secrets are out of reach of the workflows of unreviewed branches.
+func newRepoEnvironment() repoEnvironment {
+	var env repoEnvironment
+	env.DeploymentBranchPolicy.ProtectedBranches = true
+	return env
+}
+
+// environmentSecret is a secret of a GitHub environment.
+type environmentSecret struct {
+	Name  string
+	Value string
+}
+
+// environmentSecrets returns the secrets with which a job in an environment assumes the CI
+// deployer role and runs ago.
+func environmentSecrets(roleARN, region, qualifier string) []environmentSecret {
+	return []environmentSecret{
+		{Name: "AGO_CI_ROLE_ARN", Value: roleARN},
+		{Name: "AGO_REGION", Value: region},
+		{Name: "AGO_QUALIFIER", Value: qualifier},
+	}
+}
+
+// ghAPIPut sends a PUT request with the body as JSON to the GitHub API through the GitHub CLI,
+// which authenticates it.
+func ghAPIPut(ctx context.Context, exec cmdexec.Executor, path string, body any) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal request body")
+	}
+	return exec.RunWithStdin(ctx, bytes.NewReader(data), "gh", "api", "--method", "PUT", path,
+		"--input", "-", "--silent")
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestNewBranchProtection(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		checks  []string
+		reviews int
+		want    string
+	}{
+		{
+			name:    "checks and reviews",
+			checks:  []string{"check"},
+			reviews: 1,
+			want: `{"required_status_checks":{"strict":true,"contexts":["check"]},"enforce_admins":true,` +
+				`"required_pull_request_reviews":{"dismiss_stale_reviews":true,"required_approving_review_count":1},` +
+				`"restrictions":null}`,
+		},
+		{
+			name: "no checks or reviews",
+			want: `{"required_status_checks":null,"enforce_admins":true,"required_pull_request_reviews":null,` +
+				`"restrictions":null}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			got, err := json.Marshal(newBranchProtection(tt.checks, tt.reviews))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("body = %s\nwant   %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewRepoEnvironment(t *testing.T) {
+	t.Parallel()
+
+	got, err := json.Marshal(newRepoEnvironment())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"deployment_branch_policy":{"protected_branches":true,"custom_branch_policies":false}}`
+	if string(got) != want {
+		t.Errorf("body = %s, want %s", got, want)
+	}
+}
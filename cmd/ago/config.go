@@ -0,0 +1,223 @@
+package main
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/advdv/ago/cmd/ago/internal/cmdexec"
+	"github.com/advdv/ago/cmd/ago/internal/config"
+	"github.com/cockroachdb/errors"
+	"github.com/goccy/go-yaml"
+	"github.com/urfave/cli/v3"
+)
+
+func configCmd() *cli.Command {
+	return &cli.Command{
+		Name:  "config",
+		Usage: "Read and change the project configuration in " + config.FileName,
+		Commands: []*cli.Command{
+			{
+				Name: "get",
+				Usage: "Print a setting by its dotted key, such as layout.cdk_dir, with the default filled in " +
+					"when it is not set",
+				ArgsUsage: "<key>",
+				Action: config.RunWithConfig(func(_ context.Context, cmd *cli.Command, cfg config.Config) error {
+					key := cmd.Args().First()
+					if key == "" {
+						return errors.New("key argument is required")
+					}
+					progress, result := commandOutput(cmd)
+					return doConfigGet(progress, result, cfg, key)
+				}),
+			},
+			{
+				Name: "resolve",
+				Usage: "Print the effective configuration: where the profile, region, deployment and builder " +
+					"come from (flags > AGO_* variables > " + config.FileName + " > CDK context), and " +
+					config.FileName + " with its defaults",
+				Flags: []cli.Flag{
+					&cli.StringFlag{Name: "deployment", Usage: "Deployment to resolve the settings for"},
+					&cli.StringFlag{Name: "builder", Usage: "Image builder to resolve the settings for"},
+				},
+				Action: config.RunWithConfig(func(ctx context.Context, cmd *cli.Command, cfg config.Config) error {
+					progress, result := commandOutput(cmd)
+					return doConfigResolve(progress, result, cfg, config.ResolvedFromContext(ctx))
+				}),
+			},
+			{
+				Name: "set",
+				Usage: "Change a setting by its dotted key. The value is parsed as YAML, and an empty value " +
+					"removes the setting",
+				ArgsUsage: "<key> <value>",
+				Action: config.RunWithConfig(func(_ context.Context, cmd *cli.Command, cfg config.Config) error {
+					if cmd.Args().Len() != 2 {
+						return errors.New("key and value arguments are required")
+					}
+					progress, result := commandOutput(cmd)
+					return doConfigSet(progress, result, cfg, cmd.Args().Get(0), cmd.Args().Get(1))
+				}),
+			},
+		},
+	}
+}
+
+// configSetting is the result of 'ago config get' and 'ago config set'.
+type configSetting struct {
+	Key   string `json:"key"`
+	Value any    `json:"value"`
+}
+
+func doConfigGet(progress, result io.Writer, cfg config.Config, key string) error {
+	value, err := config.Get(cfg.Inner, key)
+	if err != nil {
+		return err
+	}
+	if result != nil {
+		return writeResult(result, configSetting{Key: key, Value: value})
+	}
+
+	switch value.(type) {
+	case nil:
+	case map[string]any, []any:
+		data, err := yaml.Marshal(value)
+		if err != nil {
+			return errors.Wrap(err, "failed to marshal setting")
+		}
+		writeOutputf(progress, "%s", data)
+	default:
+		writeOutputf(progress, "%v\n", value)
+	}
+	return nil
+}
+
+func doConfigSet(progress, result io.Writer, cfg config.Config, key, value string) error {
+	path := filepath.Join(cfg.ProjectDir, config.FileName)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return errors.Wrap(err, "failed to read config file")
+	}
+
+	updated, err := config.Set(data, key, value)
+	if err != nil {
+		return err
+	}
+	if err := cmdexec.WriteFile(path, updated, 0o644); err != nil {
+		return errors.Wrap(err, "failed to write config file")
+	}
+
+	if value == "" {
+		writeOutputf(progress, "Removed %s from %s\n", key, config.FileName)
+		return writeResult(result, configSetting{Key: key})
+	}
+	writeOutputf(progress, "Set %s to %s in %s\n", key, value, config.FileName)
+
+	var parsed any
+	if err := yaml.Unmarshal([]byte(value), &parsed); err != nil {
+		return errors.Wrapf(err, "failed to parse value of %s", key)
+	}
+	return writeResult(result, configSetting{Key: key, Value: parsed})
+}
+
+// unresolvedSettings describes what commands do when no layer sets a setting.
+var unresolvedSettings = map[string]string{
+	"profile":    "the deployer profile of the current user from the CDK context",
+	"region":     config.FallbackRegion,
+	"deployment": "none, commands that act on a deployment require --deployment",
+	"builder":    "the first of depot, docker and podman whose tools are installed",
+}
+
+// configResolveResult is the result of 'ago config resolve'.
+type configResolveResult struct {
+	ProjectDir string            `json:"project_dir"`
+	Settings   []config.Resolved `json:"settings"`
+	Config     map[string]any    `json:"config"`
+}
+
+// doConfigResolve reports the settings as the command line, the environment and .ago.yml
+// resolved them, with the fallbacks of the CDK context for the settings that none of them set.
+func doConfigResolve(progress, result io.Writer, cfg config.Config, resolved []config.Resolved) error {
+	settings := slices.Clone(resolved)
+	if cdk, err := loadCDKContext(cfg); err == nil {
+		for i, s := range settings {
+			if s.Source != "" {
+				continue
+			}
+			switch s.Name {
+			case "profile":
+				if cdk.Profile != "" {
+					settings[i].Value, settings[i].Source = cdk.Profile, "cdk.json profile"
+				}
+			case "region":
+				if cdk.PrimaryRegion != "" {
+					settings[i].Value, settings[i].Source = cdk.PrimaryRegion, "CDK context "+cdk.Key("primary-region")
+				}
+			}
+		}
+	}
+
+	data, err := yaml.Marshal(cfg.Inner.Effective())
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal config")
+	}
+	if result != nil {
+		var effective map[string]any
+		if err := yaml.Unmarshal(data, &effective); err != nil {
+			return errors.Wrap(err, "failed to unmarshal config")
+		}
+		return writeResult(result, configResolveResult{ProjectDir: cfg.ProjectDir, Settings: settings, Config: effective})
+	}
+
+	writeOutputf(progress, "Project: %s\n\n", cfg.ProjectDir)
+	for _, s := range settings {
+		if s.Source == "" {
+			writeOutputf(progress, "  %-11s (not set, defaults to %s)\n", s.Name, unresolvedSettings[s.Name])
+			continue
+		}
+		writeOutputf(progress, "  %-11s %s (from %s)\n", s.Name, s.Value, s.Source)
+	}
+	writeOutputf(progress, "\nEffective %s:\n%s\n", config.FileName,
+		indentLines(strings.TrimRight(string(data), "\n"), "  "))
+	return nil
+}
+
+// withConfigDefaults makes the commands apply the AGO_* variables and the flag defaults of
+// .ago.yml before they run, see config.ApplyDefaults. This happens before required flags are
+// checked, so a default deployment satisfies a required --deployment. Commands that run outside
+// a project, such as init, and projects whose config does not load, run without .ago.yml;
+// commands that need the config report its problems themselves.
+func withConfigDefaults(cmds []*cli.Command) {
+	for _, c := range cmds {
+		withConfigDefaults(c.Commands)
+		if c.Action == nil {
+			continue
+		}
+
+		before := c.Before
+		c.Before = func(ctx context.Context, cmd *cli.Command) (context.Context, error) {
+			if before != nil {
+				bctx, err := before(ctx, cmd)
+				if err != nil {
+					return ctx, err
+				}
+				if bctx != nil {
+					ctx = bctx
+				}
+			}
+
+			// Outside a project the flags and AGO_* variables still apply.
+			var inner config.InnerConfig
+			if loaded, cfg, err := config.Ensure(ctx); err == nil {
+				ctx, inner = loaded, cfg.Inner
+			}
+			resolved, err := config.ApplyDefaults(cmd, inner)
+			if err != nil {
+				return ctx, err
+			}
+			return config.WithResolved(ctx, resolved), nil
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/advdv/ago/cmd/ago/internal/config"
+)
+
+func TestDoConfigGetAndSet(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, config.FileName)
+	if err := os.WriteFile(path, []byte("version: \"1\"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var progress bytes.Buffer
+	cfg := config.Config{Inner: config.Default(), ProjectDir: dir}
+	if err := doConfigSet(&progress, nil, cfg, "layout.cdk_dir", "cdk"); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(progress.String(), "Set layout.cdk_dir to cdk") {
+		t.Errorf("unexpected output: %s", progress.String())
+	}
+
+	inner, err := config.NewLoader().Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := (config.Config{Inner: inner, ProjectDir: dir}).CDKDir(); got != filepath.Join(dir, "cdk") {
+		t.Errorf("CDKDir() = %q, want the configured directory", got)
+	}
+
+	progress.Reset()
+	if err := doConfigGet(&progress, nil, config.Config{Inner: inner, ProjectDir: dir}, "layout"); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"backend_dir: backend", "cdk_dir: cdk", "infra_dir: infra"} {
+		if !strings.Contains(progress.String(), want) {
+			t.Errorf("expected %q in:\n%s", want, progress.String())
+		}
+	}
+
+	var result bytes.Buffer
+	if err := doConfigGet(nil, &result, cfg, "default_region"); err != nil {
+		t.Fatal(err)
+	}
+	if want := `"value": "` + config.FallbackRegion + `"`; !strings.Contains(result.String(), want) {
+		t.Errorf("expected %q in:\n%s", want, result.String())
+	}
+
+	if err := doConfigSet(&progress, nil, cfg, "builder", "kaniko"); err == nil {
+		t.Error("expected error for invalid builder, got nil")
+	}
+}
+
+func TestDoConfigResolve(t *testing.T) {
+	t.Parallel()
+
+	cfg := config.Config{Inner: config.InnerConfig{Version: "1", DefaultRegion: "eu-west-1"}, ProjectDir: t.TempDir()}
+	resolved := []config.Resolved{
+		{Name: "profile", Value: "ci", Source: "AGO_PROFILE"},
+		{Name: "region", Value: "eu-west-1", Source: ".ago.yml default_region"},
+		{Name: "deployment"},
+	}
+
+	var progress bytes.Buffer
+	if err := doConfigResolve(&progress, nil, cfg, resolved); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"profile     ci (from AGO_PROFILE)",
+		"region      eu-west-1 (from .ago.yml default_region)",
+		"deployment  (not set, defaults to none",
+		"    cdk_dir: infra/cdk/cdk",
+	} {
+		if !strings.Contains(progress.String(), want) {
+			t.Errorf("expected %q in:\n%s", want, progress.String())
+		}
+	}
+
+	var result bytes.Buffer
+	if err := doConfigResolve(nil, &result, cfg, resolved); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{`"source": "AGO_PROFILE"`, `"lock_backend": "local"`} {
+		if !strings.Contains(result.String(), want) {
+			t.Errorf("expected %q in:\n%s", want, result.String())
+		}
+	}
+}
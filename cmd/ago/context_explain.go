@@ -0,0 +1,248 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/advdv/ago/agcdkutil"
+	"github.com/advdv/ago/cmd/ago/internal/cdkcontext"
+	"github.com/advdv/ago/cmd/ago/internal/config"
+	"github.com/cockroachdb/errors"
+	"github.com/urfave/cli/v3"
+)
+
+func contextCmd() *cli.Command {
+	return &cli.Command{
+		Name:  "context",
+		Usage: "Inspect the CDK context that ago reads and writes",
+		Commands: []*cli.Command{
+			{
+				Name:  "explain",
+				Usage: "Print every ago-owned context key with its value, consumers and validation status",
+				Flags: []cli.Flag{
+					&cli.BoolFlag{
+						Name:  "json",
+						Usage: "Print the keys as JSON",
+					},
+				},
+				Action: config.RunWithConfig(runContextExplain),
+			},
+		},
+	}
+}
+
+// contextKeyConsumers lists, by key name, the CLI commands and agcdkutil fields that read or write
+// each key of cdkcontext.Keys.
+var contextKeyConsumers = map[string][]string{
+	"qualifier": {
+		"agcdkutil Config.Qualifier", "all 'ago infra cdk' commands", "ago backend",
+	},
+	"primary-region": {
+		"agcdkutil Config.PrimaryRegion", "all 'ago infra cdk' commands", "ago infra org dns-*",
+	},
+	"secondary-regions": {
+		"agcdkutil Config.SecondaryRegions", "ago infra cdk bootstrap", "ago infra gameday",
+	},
+	"deployments": {
+		"agcdkutil Config.Deployments", "ago infra cdk deploy/diff/destroy/ls",
+		"ago backend prune-images",
+	},
+	agcdkutil.TenantsContextKey: {
+		"agcdkutil Config.Tenants", "ago infra cdk deploy/diff",
+	},
+	"base-domain-name": {
+		"agcdkutil Config.BaseDomainName", "ago infra org dns-delegate/dns-verify",
+	},
+	"dns-delegated": {
+		"agcdkutil Config.DNSDelegated", "ago infra org dns-delegate/dns-verify (write)",
+	},
+	"services": {
+		"ago infra cdk bootstrap", "ago infra cdk context-diff",
+	},
+	"deployers": {
+		"ago infra cdk bootstrap", "ago infra cdk add-deployer/remove-deployer (write)",
+	},
+	"dev-deployers": {
+		"ago infra cdk bootstrap", "ago infra cdk add-deployer/remove-deployer (write)",
+	},
+	"ci-repository": {
+		"ago infra cdk bootstrap", "ago infra cdk update-ci-trust (write)",
+		"ago ci generate-workflow",
+	},
+	"deployer-auth": {
+		"ago infra cdk bootstrap", "ago infra cdk context-diff", "ago infra cdk update-ci-trust",
+	},
+	"sso-start-url": {
+		"ago infra cdk bootstrap",
+	},
+	"sso-region": {
+		"ago infra cdk bootstrap",
+	},
+	"management-profile": {
+		"ago infra org dns-delegate/dns-undelegate", "ago setup",
+		"ago infra cdk bootstrap (sso deployer auth)",
+	},
+	"parent-zone-profile": {
+		"ago infra org dns-delegate/dns-undelegate",
+	},
+	"parent-zone-account": {
+		"ago infra org dns-delegate/dns-undelegate",
+	},
+	"parent-zone-role": {
+		"ago infra org dns-delegate/dns-undelegate",
+	},
+	"image-tags": {
+		"agcdkutil Config.ImageTags", "ago backend build-and-push (write)",
+		"ago backend promote (write)",
+	},
+	"image-digests": {
+		"agcdkutil Config.ImageDigests", "ago backend build-and-push (write)",
+		"ago backend promote (write)",
+	},
+	"base-image": {
+		"agcdkutil Config.BaseImage", "ago backend build-and-push", "ago backend bump-base (write)",
+	},
+	agcdkutil.DisabledJobsContextKey: {
+		"agcdkutil Config.DisabledJobs", "agcdkjobs", "ago jobs disable/enable (write)",
+	},
+	"deployer-groups": {
+		"agcdkutil Config.DeployerGroups", "ago infra cdk deploy/diff/destroy/ls",
+	},
+	"sandbox": {
+		"agcdkutil Config.Sandbox", "ago infra cdk sandbox-synth",
+	},
+	"profile": {
+		"cdk CLI", "ago backend", "ago report inventory", "ago check backups",
+	},
+	"admin-profile": {
+		"ago infra cdk bootstrap", "ago infra cdk context-diff", "ago report compliance",
+	},
+}
+
+type contextExplainOptions struct {
+	JSON   bool
+	Output io.Writer
+}
+
+func runContextExplain(_ context.Context, cmd *cli.Command, cfg config.Config) error {
+	return doContextExplain(cfg, contextExplainOptions{
+		JSON:   cmd.Bool("json") || jsonOutput(cmd),
+		Output: os.Stdout,
+	})
+}
+
+// contextKeyReport is the state of a context key in the current project.
+type contextKeyReport struct {
+	Key         string   `json:"key"`
+	File        string   `json:"file"`
+	Value       any      `json:"value,omitempty"`
+	Status      string   `json:"status"`
+	Problem     string   `json:"problem,omitempty"`
+	Description string   `json:"description"`
+	Consumers   []string `json:"consumers"`
+}
+
+// Validation statuses of context keys.
+const (
+	contextStatusOK           = "ok"
+	contextStatusUnset        = "unset"
+	contextStatusMissing      = "missing"
+	contextStatusInvalid      = "invalid"
+	contextStatusUnrecognized = "unrecognized"
+)
+
+func doContextExplain(cfg config.Config, opts contextExplainOptions) error {
+	cdkCtx, err := cdkcontext.Load(cfg.CDKDir())
+	if err != nil {
+		return err
+	}
+
+	reports := explainContext(cdkCtx.Values, cdkCtx.Prefix)
+
+	if opts.JSON {
+		data, err := json.MarshalIndent(reports, "", "  ")
+		if err != nil {
+			return errors.Wrap(err, "failed to marshal context keys")
+		}
+		writeOutputf(opts.Output, "%s\n", data)
+		return nil
+	}
+
+	for _, r := range reports {
+		status := r.Status
+		if r.Problem != "" {
+			status += ": " + r.Problem
+		}
+		value := "-"
+		if r.Value != nil {
+			data, _ := json.Marshal(r.Value)
+			value = string(data)
+		}
+
+		writeOutputf(opts.Output, "%s = %s [%s]\n", r.Key, value, status)
+		writeOutputf(opts.Output, "  %s (%s)\n", r.Description, r.File)
+		if len(r.Consumers) > 0 {
+			writeOutputf(opts.Output, "  Used by: %s\n", strings.Join(r.Consumers, ", "))
+		}
+	}
+
+	return nil
+}
+
+// explainContext reports every known context key, followed by prefixed keys that ago does not
+// know about, which usually are typos or leftovers of removed features.
+func explainContext(cdkCtx map[string]any, prefix string) []contextKeyReport {
+	known := map[string]bool{}
+	reports := make([]contextKeyReport, 0, len(cdkcontext.Keys))
+	for _, key := range cdkcontext.Keys {
+		name := key.Name
+		if key.Prefixed {
+			name = prefix + key.Name
+		}
+		known[name] = true
+
+		report := contextKeyReport{
+			Key: name, File: key.File, Description: key.Description, Consumers: contextKeyConsumers[key.Name],
+		}
+
+		value, ok := cdkCtx[name]
+		switch {
+		case !ok && key.Required:
+			report.Status = contextStatusMissing
+		case !ok:
+			report.Status = contextStatusUnset
+		default:
+			report.Value = value
+			report.Status = contextStatusOK
+			if err := key.Schema.Validate(value); err != nil {
+				report.Status = contextStatusInvalid
+				report.Problem = err.Error()
+			}
+		}
+
+		reports = append(reports, report)
+	}
+
+	var unknown []string
+	for name := range cdkCtx {
+		if strings.HasPrefix(name, prefix) && !known[name] {
+			unknown = append(unknown, name)
+		}
+	}
+	slices.Sort(unknown)
+	for _, name := range unknown {
+		reports = append(reports, contextKeyReport{
+			Key:         name,
+			File:        cdkcontext.FileName,
+			Value:       cdkCtx[name],
+			Status:      contextStatusUnrecognized,
+			Description: "Not an ago-owned key",
+		})
+	}
+
+	return reports
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/advdv/ago/cmd/ago/internal/cdkcontext"
+)
+
+func TestExplainContext(t *testing.T) {
+	t.Parallel()
+
+	cdkCtx := map[string]any{
+		"app":                    "go run .",
+		"profile":                "myapp-deployer",
+		"myapp-qualifier":        "myappqualifierx",
+		"myapp-primary-region":   "eu-central-1",
+		"myapp-secondary-region": []any{"us-east-1"},
+		"myapp-deployments":      []any{"Dev", "Prod"},
+		"myapp-dns-delegated":    "yes",
+		"myapp-image-tags":       map[string]any{"Dev": map[string]any{"backend": "abc123"}},
+		"myapp-disabled-jobs":    map[string]any{"Dev": map[string]any{"nightly-report": 1}},
+	}
+
+	statuses := map[string]string{}
+	for _, r := range explainContext(cdkCtx, "myapp-") {
+		statuses[r.Key] = r.Status
+	}
+
+	tests := []struct {
+		key  string
+		want string
+	}{
+		{key: "myapp-qualifier", want: contextStatusInvalid},
+		{key: "myapp-primary-region", want: contextStatusOK},
+		{key: "myapp-deployments", want: contextStatusOK},
+		{key: "myapp-base-domain-name", want: contextStatusMissing},
+		{key: "myapp-secondary-regions", want: contextStatusUnset},
+		{key: "myapp-dns-delegated", want: contextStatusInvalid},
+		{key: "myapp-image-tags", want: contextStatusOK},
+		{key: "myapp-disabled-jobs", want: contextStatusInvalid},
+		{key: "myapp-secondary-region", want: contextStatusUnrecognized},
+		{key: "profile", want: contextStatusOK},
+		{key: "admin-profile", want: contextStatusUnset},
+		{key: "app", want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			t.Parallel()
+
+			if got := statuses[tt.key]; got != tt.want {
+				t.Errorf("status of %q = %q, want %q", tt.key, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestContextKeyConsumers(t *testing.T) {
+	t.Parallel()
+
+	known := map[string]bool{}
+	for _, key := range cdkcontext.Keys {
+		known[key.Name] = true
+		if len(contextKeyConsumers[key.Name]) == 0 {
+			t.Errorf("context key %q has no consumers", key.Name)
+		}
+	}
+	for name := range contextKeyConsumers {
+		if !known[name] {
+			t.Errorf("consumers listed for %q, which is not in cdkcontext.Keys", name)
+		}
+	}
+}
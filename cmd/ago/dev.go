@@ -20,6 +20,8 @@ func devCmd() *cli.Command {
 				Usage:  "Run go generate",
 				Action: config.RunWithConfig(devGen),
 			},
+			devRunCmd(),
+			devDownCmd(),
 		},
 	}
 }
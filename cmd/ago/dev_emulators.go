@@ -0,0 +1,346 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"text/template"
+
+	"github.com/advdv/ago/agcdkutil"
+	"github.com/advdv/ago/cmd/ago/internal/awsapi"
+	"github.com/advdv/ago/cmd/ago/internal/cmdexec"
+	"github.com/advdv/ago/cmd/ago/internal/config"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/cockroachdb/errors"
+)
+
+// Endpoints and credentials of the local emulators. DynamoDB Local accepts any credentials, MinIO
+// is started with these as its root user.
+const (
+	dynamoDBLocalEndpoint = "http://localhost:8000"
+	minioEndpoint         = "http://localhost:9000"
+	emulatorAccessKeyID   = "agolocal"
+	emulatorSecretKey     = "agolocal-secret"
+)
+
+// emulatorComposeTemplate is the docker compose project of the emulators. Data is kept in named
+// volumes, so it survives 'ago dev down' unless --volumes is given.
+var emulatorComposeTemplate = template.Must(template.New("compose.yaml").Parse(
+	`# Generated by 'ago dev run --emulators'.
+name: {{.Project}}
+
+services:
+  dynamodb:
+    image: amazon/dynamodb-local:latest
+    command: ["-jar", "DynamoDBLocal.jar", "-sharedDb", "-dbPath", "/home/dynamodblocal/data"]
+    working_dir: /home/dynamodblocal
+    user: root
+    ports: ["8000:8000"]
+    volumes: ["dynamodb:/home/dynamodblocal/data"]
+  minio:
+    image: minio/minio:latest
+    command: ["server", "/data", "--console-address", ":9001"]
+    environment:
+      MINIO_ROOT_USER: {{.AccessKeyID}}
+      MINIO_ROOT_PASSWORD: {{.SecretKey}}
+      MINIO_DOMAIN: localhost
+    ports: ["9000:9000", "9001:9001"]
+    volumes: ["minio:/data"]
+    healthcheck:
+      test: ["CMD", "mc", "ready", "local"]
+      interval: 2s
+      timeout: 5s
+      retries: 15
+
+volumes:
+  dynamodb:
+  minio:
+`))
+
+// emulatorComposeProject returns the docker compose project name of the emulators of a project.
+func emulatorComposeProject(qualifier string) string {
+	return qualifier + "-dev"
+}
+
+// emulatorComposeFile writes the compose file of the emulators to the user cache directory and
+// returns its path.
+func emulatorComposeFile(qualifier string) (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", errors.Wrap(err, "failed to determine user cache directory")
+	}
+	dir = filepath.Join(dir, "ago", "dev", qualifier)
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return "", errors.Wrap(err, "failed to create emulator directory")
+	}
+
+	var buf bytes.Buffer
+	if err := emulatorComposeTemplate.Execute(&buf, map[string]string{
+		"Project":     emulatorComposeProject(qualifier),
+		"AccessKeyID": emulatorAccessKeyID,
+		"SecretKey":   emulatorSecretKey,
+	}); err != nil {
+		return "", errors.Wrap(err, "failed to execute compose template")
+	}
+
+	path := filepath.Join(dir, "compose.yaml")
+	if err := os.WriteFile(path, buf.Bytes(), 0o600); err != nil {
+		return "", errors.Wrap(err, "failed to write compose file")
+	}
+	return path, nil
+}
+
+// emulatorPlan holds what the emulators need to serve a deployment: the tables and buckets of its
+// stack under local names, and the environment variables that the stack's functions and tasks
+// get, with references to those tables and buckets resolved to the local names.
+type emulatorPlan struct {
+	Tables  []emulatorTable
+	Buckets []string
+	Env     map[string]string
+}
+
+// emulatorTable is a table to create in DynamoDB Local. Input is the CreateTable request.
+type emulatorTable struct {
+	Name  string
+	Input map[string]any
+}
+
+// planEmulators derives the emulator plan from a synthesized stack template. Tables and buckets
+// keep an explicit name, others are named after their logical ID.
+func planEmulators(tmpl map[string]any) emulatorPlan {
+	resources, _ := tmpl["Resources"].(map[string]any)
+	ids := slices.Sorted(func(yield func(string) bool) {
+		for id := range resources {
+			if !yield(id) {
+				return
+			}
+		}
+	})
+
+	plan := emulatorPlan{Env: map[string]string{}}
+	localNames := map[string]string{}
+	for _, id := range ids {
+		resource, _ := resources[id].(map[string]any)
+		props, _ := resource["Properties"].(map[string]any)
+		switch resource["Type"] {
+		case "AWS::DynamoDB::Table", "AWS::DynamoDB::GlobalTable":
+			name, ok := props["TableName"].(string)
+			if !ok {
+				name = id
+			}
+			localNames[id] = name
+			plan.Tables = append(plan.Tables, emulatorTable{Name: name, Input: createTableInput(name, props)})
+		case "AWS::S3::Bucket":
+			name, ok := props["BucketName"].(string)
+			if !ok {
+				name = strings.ToLower(id)
+			}
+			localNames[id] = name
+			plan.Buckets = append(plan.Buckets, name)
+		}
+	}
+
+	for _, id := range ids {
+		resource, _ := resources[id].(map[string]any)
+		props, _ := resource["Properties"].(map[string]any)
+		switch resource["Type"] {
+		case "AWS::Lambda::Function":
+			env, _ := props["Environment"].(map[string]any)
+			vars, _ := env["Variables"].(map[string]any)
+			for name, value := range vars {
+				addEmulatorEnv(plan.Env, localNames, name, value)
+			}
+		case "AWS::ECS::TaskDefinition":
+			containers, _ := props["ContainerDefinitions"].([]any)
+			for _, c := range containers {
+				container, _ := c.(map[string]any)
+				vars, _ := container["Environment"].([]any)
+				for _, v := range vars {
+					kv, _ := v.(map[string]any)
+					if name, ok := kv["Name"].(string); ok {
+						addEmulatorEnv(plan.Env, localNames, name, kv["Value"])
+					}
+				}
+			}
+		}
+	}
+
+	return plan
+}
+
+// addEmulatorEnv adds a variable whose value is a literal or a reference to an emulated table or
+// bucket. Values that only exist in AWS, such as ARNs, are left out. The first value of a
+// variable wins.
+func addEmulatorEnv(env, localNames map[string]string, name string, value any) {
+	if _, ok := env[name]; ok {
+		return
+	}
+	switch v := value.(type) {
+	case string:
+		env[name] = v
+	case map[string]any:
+		if ref, ok := v["Ref"].(string); ok && localNames[ref] != "" {
+			env[name] = localNames[ref]
+		}
+	}
+}
+
+// createTableInput translates the properties of a table resource into a CreateTable request.
+// DynamoDB Local ignores capacity, so every table is created on demand, and properties that have
+// no local meaning, such as point-in-time recovery or replicas, are dropped.
+func createTableInput(name string, props map[string]any) map[string]any {
+	input := map[string]any{
+		"TableName":   name,
+		"BillingMode": "PAY_PER_REQUEST",
+	}
+	for _, key := range []string{"KeySchema", "AttributeDefinitions"} {
+		if v, ok := props[key]; ok {
+			input[key] = v
+		}
+	}
+	for _, key := range []string{"GlobalSecondaryIndexes", "LocalSecondaryIndexes"} {
+		indexes, _ := props[key].([]any)
+		if len(indexes) == 0 {
+			continue
+		}
+		translated := make([]any, 0, len(indexes))
+		for _, idx := range indexes {
+			index, _ := idx.(map[string]any)
+			translated = append(translated, map[string]any{
+				"IndexName":  index["IndexName"],
+				"KeySchema":  index["KeySchema"],
+				"Projection": index["Projection"],
+			})
+		}
+		input[key] = translated
+	}
+	return input
+}
+
+// emulatorEnv returns the environment that points the AWS SDKs at the emulators.
+func emulatorEnv(region string) map[string]string {
+	return map[string]string{
+		"AWS_ENDPOINT_URL_DYNAMODB": dynamoDBLocalEndpoint,
+		"AWS_ENDPOINT_URL_S3":       minioEndpoint,
+		"AWS_ACCESS_KEY_ID":         emulatorAccessKeyID,
+		"AWS_SECRET_ACCESS_KEY":     emulatorSecretKey,
+		"AWS_SESSION_TOKEN":         "",
+		"AWS_PROFILE":               "",
+		"AWS_REGION":                region,
+	}
+}
+
+// startEmulators starts the emulators of the project and waits until they are healthy.
+func startEmulators(ctx context.Context, exec cmdexec.Executor, output io.Writer, qualifier string) error {
+	composeFile, err := emulatorComposeFile(qualifier)
+	if err != nil {
+		return err
+	}
+
+	writeOutputf(output, "Starting DynamoDB Local and MinIO...\n")
+	if err := exec.Run(ctx, "docker", "compose", "--file", composeFile, "up", "--detach", "--wait"); err != nil {
+		return errors.Wrap(err, "failed to start the emulators, is docker running?")
+	}
+	return nil
+}
+
+// stopEmulators stops the emulators of the project, and removes their data with volumes.
+func stopEmulators(ctx context.Context, exec cmdexec.Executor, qualifier string, volumes bool) error {
+	composeFile, err := emulatorComposeFile(qualifier)
+	if err != nil {
+		return err
+	}
+
+	args := []string{"compose", "--file", composeFile, "down"}
+	if volumes {
+		args = append(args, "--volumes")
+	}
+	if err := exec.Run(ctx, "docker", args...); err != nil {
+		return errors.Wrap(err, "failed to stop the emulators")
+	}
+	return nil
+}
+
+// provisionEmulators creates the tables and buckets of the plan that do not exist yet.
+func provisionEmulators(ctx context.Context, output io.Writer, region string, plan emulatorPlan) error {
+	dynamo := awsapi.NewLocal(region, dynamoDBLocalEndpoint, emulatorAccessKeyID, emulatorSecretKey)
+	for _, table := range plan.Tables {
+		if _, err := dynamo.DynamoDB.DescribeTable(ctx, &dynamodb.DescribeTableInput{
+			TableName: aws.String(table.Name),
+		}); err == nil {
+			continue
+		}
+
+		input, err := json.Marshal(table.Input)
+		if err != nil {
+			return errors.Wrapf(err, "failed to encode table %q", table.Name)
+		}
+		var create dynamodb.CreateTableInput
+		if err := json.Unmarshal(input, &create); err != nil {
+			return errors.Wrapf(err, "failed to decode table %q", table.Name)
+		}
+		writeOutputf(output, "Creating table %s...\n", table.Name)
+		if _, err := dynamo.DynamoDB.CreateTable(ctx, &create); err != nil {
+			return errors.Wrapf(err, "failed to create table %q", table.Name)
+		}
+	}
+
+	minio := awsapi.NewLocal(region, minioEndpoint, emulatorAccessKeyID, emulatorSecretKey)
+	for _, bucket := range plan.Buckets {
+		if _, err := minio.S3.HeadBucket(ctx, &s3.HeadBucketInput{Bucket: aws.String(bucket)}); err == nil {
+			continue
+		}
+
+		writeOutputf(output, "Creating bucket %s...\n", bucket)
+		if _, err := minio.S3.CreateBucket(ctx, &s3.CreateBucketInput{Bucket: aws.String(bucket)}); err != nil {
+			return errors.Wrapf(err, "failed to create bucket %q", bucket)
+		}
+	}
+
+	return nil
+}
+
+// prepareEmulators starts the emulators and creates the tables and buckets of the deployment's
+// primary stack, as synthesized by a sandbox synth so that no AWS access is needed. It returns
+// the environment that the command runs with.
+func prepareEmulators(
+	ctx context.Context, cfg config.Config, cdk *cdkContext, output io.Writer, deployment string,
+) (map[string]string, error) {
+	if err := cdk.Require("primary-region"); err != nil {
+		return nil, err
+	}
+	primaryRegion := cdk.PrimaryRegion
+
+	const out = "cdk.sandbox.out"
+	if err := doSandboxSynth(ctx, cfg, sandboxSynthOptions{Out: out, Output: output}); err != nil {
+		return nil, err
+	}
+
+	stackName := agcdkutil.DeploymentStackName(cdk.Qualifier, agcdkutil.RegionIdentFor(primaryRegion), deployment)
+	tmpl, err := readSynthesizedTemplate(filepath.Join(cdk.CDKDir, out), stackName)
+	if err != nil {
+		return nil, err
+	}
+	plan := planEmulators(tmpl)
+
+	exec := cdk.Exec.WithOutput(output, output)
+	if err := startEmulators(ctx, exec, output, cdk.Qualifier); err != nil {
+		return nil, err
+	}
+	if err := provisionEmulators(ctx, output, primaryRegion, plan); err != nil {
+		return nil, err
+	}
+
+	env := plan.Env
+	for key, value := range emulatorEnv(primaryRegion) {
+		env[key] = value
+	}
+	return env, nil
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestPlanEmulators(t *testing.T) {
+	t.Parallel()
+
+	var tmpl map[string]any
+	if err := json.Unmarshal([]byte(`{"Resources": {
+		"OrdersTable": {"Type": "AWS::DynamoDB::Table", "Properties": {
+			"KeySchema": [{"AttributeName": "pk", "KeyType": "HASH"}],
+			"AttributeDefinitions": [{"AttributeName": "pk", "AttributeType": "S"},
+				{"AttributeName": "gsi1pk", "AttributeType": "S"}],
+			"GlobalSecondaryIndexes": [{"IndexName": "gsi1",
+				"KeySchema": [{"AttributeName": "gsi1pk", "KeyType": "HASH"}],
+				"Projection": {"ProjectionType": "ALL"},
+				"ProvisionedThroughput": {"ReadCapacityUnits": 5, "WriteCapacityUnits": 5}}],
+			"PointInTimeRecoverySpecification": {"PointInTimeRecoveryEnabled": true}}},
+		"Uploads": {"Type": "AWS::S3::Bucket", "Properties": {}},
+		"Named": {"Type": "AWS::S3::Bucket", "Properties": {"BucketName": "myapp-assets"}},
+		"Handler": {"Type": "AWS::Lambda::Function", "Properties": {"Environment": {"Variables": {
+			"TABLE_NAME": {"Ref": "OrdersTable"},
+			"BUCKET_NAME": {"Ref": "Uploads"},
+			"QUEUE_ARN": {"Fn::GetAtt": ["Queue", "Arn"]},
+			"LOG_LEVEL": "debug"}}}},
+		"Task": {"Type": "AWS::ECS::TaskDefinition", "Properties": {"ContainerDefinitions": [
+			{"Environment": [{"Name": "LOG_LEVEL", "Value": "info"}, {"Name": "ASSETS", "Value": "myapp-assets"}]}]}}
+	}}`), &tmpl); err != nil {
+		t.Fatal(err)
+	}
+
+	plan := planEmulators(tmpl)
+
+	if len(plan.Tables) != 1 || plan.Tables[0].Name != "OrdersTable" {
+		t.Fatalf("expected table OrdersTable, got %+v", plan.Tables)
+	}
+	input := plan.Tables[0].Input
+	if input["BillingMode"] != "PAY_PER_REQUEST" {
+		t.Errorf("expected on-demand billing, got %v", input["BillingMode"])
+	}
+	if _, ok := input["PointInTimeRecoverySpecification"]; ok {
+		t.Error("expected point-in-time recovery to be dropped")
+	}
+	indexes, _ := input["GlobalSecondaryIndexes"].([]any)
+	if len(indexes) != 1 {
+		t.Fatalf("expected one global secondary index, got %v", input["GlobalSecondaryIndexes"])
+	}
+	if _, ok := indexes[0].(map[string]any)["ProvisionedThroughput"]; ok {
+		t.Error("expected the index throughput to be dropped")
+	}
+
+	if want := []string{"myapp-assets", "uploads"}; !reflect.DeepEqual(plan.Buckets, want) {
+		t.Errorf("Buckets = %v, want %v", plan.Buckets, want)
+	}
+
+	want := map[string]string{
+		"TABLE_NAME":  "OrdersTable",
+		"BUCKET_NAME": "uploads",
+		"LOG_LEVEL":   "debug",
+		"ASSETS":      "myapp-assets",
+	}
+	if !reflect.DeepEqual(plan.Env, want) {
+		t.Errorf("Env = %v, want %v", plan.Env, want)
+	}
+}
+
+func TestParseEnvFlags(t *testing.T) {
+	t.Parallel()
+
+	env, err := parseEnvFlags([]string{"A=1", "B=x=y", "C="})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]string{"A": "1", "B": "x=y", "C": ""}; !reflect.DeepEqual(env, want) {
+		t.Errorf("parseEnvFlags() = %v, want %v", env, want)
+	}
+
+	if _, err := parseEnvFlags([]string{"NOVALUE"}); err == nil {
+		t.Error("expected an error for a pair without '='")
+	}
+}
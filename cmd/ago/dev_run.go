@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"io"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/advdv/ago/cmd/ago/internal/cmdexec"
+	"github.com/advdv/ago/cmd/ago/internal/config"
+	"github.com/cockroachdb/errors"
+	"github.com/urfave/cli/v3"
+)
+
+func devRunCmd() *cli.Command {
+	return &cli.Command{
+		Name:      "run",
+		Usage:     "Run a backend command locally, optionally against DynamoDB Local and MinIO",
+		ArgsUsage: "<command> [-- args...]",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:  "deployment",
+				Usage: "Deployment whose tables, buckets and environment the command runs with",
+			},
+			&cli.BoolFlag{
+				Name: "emulators",
+				Usage: "Start DynamoDB Local and MinIO with docker compose, create the deployment's tables " +
+					"and buckets in them, and point the AWS SDK at them",
+			},
+			&cli.StringSliceFlag{
+				Name:  "env",
+				Usage: "Extra environment variable as KEY=VALUE (repeatable), overrides the deployment's",
+			},
+		},
+		Action: config.RunWithConfig(runDevRun),
+	}
+}
+
+func devDownCmd() *cli.Command {
+	return &cli.Command{
+		Name:  "down",
+		Usage: "Stop the emulators that 'ago dev run --emulators' started",
+		Flags: []cli.Flag{
+			&cli.BoolFlag{
+				Name:  "volumes",
+				Usage: "Also remove the tables and objects stored in the emulators",
+			},
+		},
+		Action: config.RunWithConfig(runDevDown),
+	}
+}
+
+type devRunOptions struct {
+	Command    string
+	Args       []string
+	Deployment string
+	Emulators  bool
+	Env        []string
+	Output     io.Writer
+	ErrOut     io.Writer
+}
+
+func runDevRun(ctx context.Context, cmd *cli.Command, cfg config.Config) error {
+	if cmd.Args().Len() < 1 {
+		return errors.New("command name required: ago dev run <command>")
+	}
+
+	return doDevRun(ctx, cfg, devRunOptions{
+		Command:    cmd.Args().First(),
+		Args:       cmd.Args().Tail(),
+		Deployment: cmd.String("deployment"),
+		Emulators:  cmd.Bool("emulators"),
+		Env:        cmd.StringSlice("env"),
+		Output:     os.Stdout,
+		ErrOut:     os.Stderr,
+	})
+}
+
+// doDevRun runs backend/cmd/<command> with 'go run'. With emulators the command gets the
+// environment of the deployment's functions and tasks, with its tables and buckets served by the
+// emulators instead of AWS.
+func doDevRun(ctx context.Context, cfg config.Config, opts devRunOptions) error {
+	exec := cmdexec.New(cfg).WithOutput(opts.Output, opts.ErrOut)
+	backendExec := exec.InSubdir(cfg.Inner.Layout.Backend())
+
+	cmdDir := filepath.Join(backendExec.Dir(), "cmd", opts.Command)
+	if info, err := os.Stat(cmdDir); err != nil || !info.IsDir() {
+		return errors.Errorf("command %q not found in backend/cmd", opts.Command)
+	}
+
+	extraEnv, err := parseEnvFlags(opts.Env)
+	if err != nil {
+		return err
+	}
+
+	env := map[string]string{}
+	if opts.Emulators {
+		if opts.Deployment == "" {
+			return errors.New("--emulators requires --deployment to select the tables and buckets to create")
+		}
+
+		cdk, err := loadCDKContext(cfg)
+		if err != nil {
+			return err
+		}
+
+		deployments := cdk.Deployments
+		if !slices.Contains(deployments, opts.Deployment) {
+			return errors.Errorf("deployment %q not found\n\nAvailable deployments: %s",
+				opts.Deployment, formatDeploymentsList(deployments))
+		}
+
+		if env, err = prepareEmulators(ctx, cfg, cdk, opts.Output, opts.Deployment); err != nil {
+			return err
+		}
+	}
+	if opts.Deployment != "" {
+		env["AGO_DEPLOYMENT"] = opts.Deployment
+	}
+	maps.Copy(env, extraEnv)
+
+	for _, key := range slices.Sorted(maps.Keys(env)) {
+		backendExec = backendExec.WithEnv(key, env[key])
+	}
+
+	args := append([]string{"run", "./cmd/" + opts.Command}, opts.Args...)
+	return backendExec.Mise(ctx, "go", args...)
+}
+
+// parseEnvFlags parses KEY=VALUE pairs.
+func parseEnvFlags(pairs []string) (map[string]string, error) {
+	env := make(map[string]string, len(pairs))
+	for _, pair := range pairs {
+		key, value, ok := strings.Cut(pair, "=")
+		if !ok || key == "" {
+			return nil, errors.Errorf("invalid --env %q, expected KEY=VALUE", pair)
+		}
+		env[key] = value
+	}
+	return env, nil
+}
+
+func runDevDown(ctx context.Context, cmd *cli.Command, cfg config.Config) error {
+	cdk, err := loadCDKContext(cfg)
+	if err != nil {
+		return err
+	}
+
+	exec := cdk.Exec.WithOutput(os.Stdout, os.Stderr)
+	return stopEmulators(ctx, exec, cdk.Qualifier, cmd.Bool("volumes"))
+}
@@ -0,0 +1,271 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"time"
+
+	"github.com/advdv/ago/agcdkutil"
+	"github.com/advdv/ago/cmd/ago/internal/awsapi"
+	"github.com/advdv/ago/cmd/ago/internal/config"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/eventbridge"
+	ebtypes "github.com/aws/aws-sdk-go-v2/service/eventbridge/types"
+	"github.com/cockroachdb/errors"
+	"github.com/urfave/cli/v3"
+)
+
+func eventsCmd() *cli.Command {
+	return &cli.Command{
+		Name:  "events",
+		Usage: "Publish test events to and replay archived events on a deployment's agcdkevents bus",
+		Commands: []*cli.Command{
+			eventsPublishCmd(),
+			eventsReplayCmd(),
+		},
+	}
+}
+
+func eventsPublishCmd() *cli.Command {
+	return &cli.Command{
+		Name:  "publish",
+		Usage: "Publish an event to the deployment's event bus",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:  "deployment",
+				Usage: "Deployment that owns the event bus (default: the caller's Dev deployment)",
+			},
+			&cli.StringFlag{
+				Name:     "detail-type",
+				Usage:    "Detail type of the event, which rules match on",
+				Required: true,
+			},
+			&cli.StringFlag{
+				Name:     "payload",
+				Usage:    "Path to a JSON file with the detail of the event",
+				Required: true,
+			},
+			&cli.StringFlag{
+				Name:  "source",
+				Usage: "Source of the event",
+				Value: "ago.cli",
+			},
+		},
+		Action: config.RunWithConfig(runEventsPublish),
+	}
+}
+
+func eventsReplayCmd() *cli.Command {
+	return &cli.Command{
+		Name:  "replay",
+		Usage: "Replay archived events to the event bus they were published to",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:  "deployment",
+				Usage: "Deployment that owns the event bus (default: the caller's Dev deployment)",
+			},
+			&cli.StringFlag{
+				Name:  "archive",
+				Usage: "Name of the archive to replay from (default: the deployment's archive)",
+			},
+			&cli.DurationFlag{
+				Name:     "since",
+				Usage:    "Replay the events published in this period before now, such as 1h",
+				Required: true,
+			},
+			&cli.DurationFlag{
+				Name:  "until",
+				Usage: "Only replay events published at least this long ago",
+			},
+		},
+		Action: config.RunWithConfig(runEventsReplay),
+	}
+}
+
+type eventsOptions struct {
+	Deployment    string
+	Profile       string
+	Region        string
+	ScopedSession bool
+	DetailType    string
+	Payload       string
+	Source        string
+	Archive       string
+	Since         time.Duration
+	Until         time.Duration
+	Output        io.Writer
+}
+
+func runEventsPublish(ctx context.Context, cmd *cli.Command, cfg config.Config) error {
+	return doEventsPublish(ctx, cfg, eventsOptions{
+		Deployment:    cmd.String("deployment"),
+		Profile:       cmd.String("profile"),
+		Region:        cmd.String("region"),
+		ScopedSession: cmd.Bool("scoped-session"),
+		DetailType:    cmd.String("detail-type"),
+		Payload:       cmd.String("payload"),
+		Source:        cmd.String("source"),
+		Output:        os.Stdout,
+	})
+}
+
+func runEventsReplay(ctx context.Context, cmd *cli.Command, cfg config.Config) error {
+	return doEventsReplay(ctx, cfg, eventsOptions{
+		Deployment:    cmd.String("deployment"),
+		Profile:       cmd.String("profile"),
+		Region:        cmd.String("region"),
+		ScopedSession: cmd.Bool("scoped-session"),
+		Archive:       cmd.String("archive"),
+		Since:         cmd.Duration("since"),
+		Until:         cmd.Duration("until"),
+		Output:        os.Stdout,
+	})
+}
+
+func doEventsPublish(ctx context.Context, cfg config.Config, opts eventsOptions) error {
+	payload, err := os.ReadFile(opts.Payload)
+	if err != nil {
+		return errors.Wrapf(err, "failed to read payload %s", opts.Payload)
+	}
+
+	outputs, clients, err := resolveEvents(ctx, cfg, opts, "events-publish",
+		func(outputs map[string]string, account sessionAccount) []sessionStatement {
+			return []sessionStatement{allow([]string{
+				account.arn("events", "event-bus/"+outputs[agcdkutil.EventBusNameOutputKey]),
+			}, "events:PutEvents")}
+		})
+	if err != nil {
+		return err
+	}
+
+	return publishEvent(ctx, clients.EventBridge, opts.Output, outputs[agcdkutil.EventBusNameOutputKey],
+		opts.Source, opts.DetailType, payload)
+}
+
+func doEventsReplay(ctx context.Context, cfg config.Config, opts eventsOptions) error {
+	if opts.Since <= opts.Until {
+		return errors.Errorf("--since (%s) must be longer ago than --until (%s)", opts.Since, opts.Until)
+	}
+
+	outputs, clients, err := resolveEvents(ctx, cfg, opts, "events-replay",
+		func(outputs map[string]string, account sessionAccount) []sessionStatement {
+			return []sessionStatement{allow([]string{
+				account.arn("events", "archive/"+cmp.Or(opts.Archive, outputs[agcdkutil.EventArchiveNameOutputKey])),
+				account.arn("events", "replay/*"),
+			}, "events:DescribeArchive", "events:StartReplay")}
+		})
+	if err != nil {
+		return err
+	}
+	archive := cmp.Or(opts.Archive, outputs[agcdkutil.EventArchiveNameOutputKey])
+
+	now := time.Now()
+	return replayEvents(ctx, clients.EventBridge, opts.Output, archive, now.Add(-opts.Since), now.Add(-opts.Until))
+}
+
+// resolveEvents reads the outputs that agcdkevents recorded in the deployment stack. A scoped
+// session may only do what scope allows on the resources in the outputs.
+func resolveEvents(
+	ctx context.Context, cfg config.Config, opts eventsOptions, command string,
+	scope func(outputs map[string]string, account sessionAccount) []sessionStatement,
+) (map[string]string, *awsapi.Clients, error) {
+	stacks, err := resolveDeploymentStacks(ctx, cfg, opts.Deployment, opts.Profile, opts.Region)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	outputs, err := stacks.outputs(ctx, stacks.Stack)
+	if err != nil {
+		return nil, nil, err
+	}
+	if outputs[agcdkutil.EventBusNameOutputKey] == "" {
+		return nil, nil, errors.Errorf("no event bus found in the outputs of %s, is agcdkevents.New used?",
+			stacks.Stack)
+	}
+
+	clients, err := stacks.clients(ctx, opts.ScopedSession, command, func(account sessionAccount) []sessionStatement {
+		return scope(outputs, account)
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return outputs, clients, nil
+}
+
+// publishEvent puts a single event with the payload as its detail on the bus.
+func publishEvent(
+	ctx context.Context, client awsapi.EventBridge, output io.Writer, bus, source, detailType string, payload []byte,
+) error {
+	if !json.Valid(payload) {
+		return errors.New("payload is not valid JSON")
+	}
+
+	out, err := client.PutEvents(ctx, &eventbridge.PutEventsInput{
+		Entries: []ebtypes.PutEventsRequestEntry{{
+			EventBusName: aws.String(bus),
+			Source:       aws.String(source),
+			DetailType:   aws.String(detailType),
+			Detail:       aws.String(string(payload)),
+		}},
+	})
+	if err != nil {
+		return errors.Wrapf(err, "failed to publish event to bus %s", bus)
+	}
+	if out.FailedEntryCount > 0 && len(out.Entries) > 0 {
+		return errors.Errorf("event was rejected by bus %s: %s: %s", bus,
+			aws.ToString(out.Entries[0].ErrorCode), aws.ToString(out.Entries[0].ErrorMessage))
+	}
+
+	eventID := ""
+	if len(out.Entries) > 0 {
+		eventID = aws.ToString(out.Entries[0].EventId)
+	}
+	writeOutputf(output, "Published %s event %s to bus %s.\n", detailType, eventID, bus)
+	return nil
+}
+
+// replayEvents replays the events that were archived between start and end to the bus that the
+// archive records events of. EventBridge delivers them to the bus's rules asynchronously; the
+// replay's progress shows in the console or with `aws events describe-replay`.
+func replayEvents(
+	ctx context.Context, client awsapi.EventBridge, output io.Writer, archive string, start, end time.Time,
+) error {
+	desc, err := client.DescribeArchive(ctx, &eventbridge.DescribeArchiveInput{
+		ArchiveName: aws.String(archive),
+	})
+	if err != nil {
+		return errors.Wrapf(err, "failed to describe archive %s", archive)
+	}
+
+	name := replayName(archive, end)
+	out, err := client.StartReplay(ctx, &eventbridge.StartReplayInput{
+		ReplayName:     aws.String(name),
+		EventSourceArn: desc.ArchiveArn,
+		EventStartTime: aws.Time(start),
+		EventEndTime:   aws.Time(end),
+		Destination:    &ebtypes.ReplayDestination{Arn: desc.EventSourceArn},
+	})
+	if err != nil {
+		return errors.Wrapf(err, "failed to start replay of archive %s", archive)
+	}
+
+	writeOutputf(output, "Started replay %s of events from %s to %s (%s).\n", name,
+		start.UTC().Format(time.RFC3339), end.UTC().Format(time.RFC3339), out.State)
+	return nil
+}
+
+// replayName derives a replay name from the archive and the end of the replayed period. Replay
+// names are limited to 64 characters, so long archive names are truncated from the start, which
+// CDK fills with the stack name.
+func replayName(archive string, end time.Time) string {
+	const maxLen = 64
+	suffix := "-" + end.UTC().Format("20060102T150405")
+	if len(archive)+len(suffix) > maxLen {
+		archive = archive[len(archive)+len(suffix)-maxLen:]
+	}
+	return archive + suffix
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/advdv/ago/cmd/ago/internal/awsapi"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/eventbridge"
+	ebtypes "github.com/aws/aws-sdk-go-v2/service/eventbridge/types"
+)
+
+// fakeEventBridge records the events put and the replays started, and rejects events when
+// rejectCode is set.
+type fakeEventBridge struct {
+	awsapi.EventBridge
+	rejectCode string
+	put        []ebtypes.PutEventsRequestEntry
+	replays    []*eventbridge.StartReplayInput
+}
+
+func (f *fakeEventBridge) PutEvents(
+	_ context.Context, in *eventbridge.PutEventsInput, _ ...func(*eventbridge.Options),
+) (*eventbridge.PutEventsOutput, error) {
+	f.put = append(f.put, in.Entries...)
+	if f.rejectCode != "" {
+		return &eventbridge.PutEventsOutput{FailedEntryCount: 1, Entries: []ebtypes.PutEventsResultEntry{
+			{ErrorCode: aws.String(f.rejectCode), ErrorMessage: aws.String("rejected")},
+		}}, nil
+	}
+	return &eventbridge.PutEventsOutput{Entries: []ebtypes.PutEventsResultEntry{{EventId: aws.String("ev-1")}}}, nil
+}
+
+func (f *fakeEventBridge) DescribeArchive(
+	_ context.Context, in *eventbridge.DescribeArchiveInput, _ ...func(*eventbridge.Options),
+) (*eventbridge.DescribeArchiveOutput, error) {
+	return &eventbridge.DescribeArchiveOutput{
+		ArchiveArn:     aws.String("arn:aws:events:eu-west-1:123:archive/" + aws.ToString(in.ArchiveName)),
+		EventSourceArn: aws.String("arn:aws:events:eu-west-1:123:event-bus/bus"),
+	}, nil
+}
+
+func (f *fakeEventBridge) StartReplay(
+	_ context.Context, in *eventbridge.StartReplayInput, _ ...func(*eventbridge.Options),
+) (*eventbridge.StartReplayOutput, error) {
+	f.replays = append(f.replays, in)
+	return &eventbridge.StartReplayOutput{State: ebtypes.ReplayStateStarting}, nil
+}
+
+func TestPublishEvent(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		payload    string
+		rejectCode string
+		wantErr    string
+		wantPut    int
+	}{
+		{name: "published", payload: `{"id":1}`, wantPut: 1},
+		{name: "invalid payload", payload: `{"id":`, wantErr: "not valid JSON"},
+		{name: "rejected", payload: `{}`, rejectCode: "InternalFailure", wantErr: "InternalFailure", wantPut: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			client := &fakeEventBridge{rejectCode: tt.rejectCode}
+			var out bytes.Buffer
+			err := publishEvent(context.Background(), client, &out, "bus", "ago.cli", "OrderPlaced",
+				[]byte(tt.payload))
+
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("expected error containing %q, got %v", tt.wantErr, err)
+				}
+			} else if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(client.put) != tt.wantPut {
+				t.Fatalf("expected %d events put, got %d", tt.wantPut, len(client.put))
+			}
+			if tt.wantPut > 0 && aws.ToString(client.put[0].Detail) != tt.payload {
+				t.Errorf("expected detail %q, got %q", tt.payload, aws.ToString(client.put[0].Detail))
+			}
+		})
+	}
+}
+
+func TestReplayEvents(t *testing.T) {
+	t.Parallel()
+
+	client := &fakeEventBridge{}
+	end := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	start := end.Add(-time.Hour)
+
+	var out bytes.Buffer
+	if err := replayEvents(context.Background(), client, &out, "archive", start, end); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(client.replays) != 1 {
+		t.Fatalf("expected 1 replay, got %d", len(client.replays))
+	}
+	replay := client.replays[0]
+	if got := aws.ToString(replay.ReplayName); got != "archive-20260301T120000" {
+		t.Errorf("unexpected replay name %q", got)
+	}
+	if got := aws.ToString(replay.Destination.Arn); got != "arn:aws:events:eu-west-1:123:event-bus/bus" {
+		t.Errorf("expected replay to the archive's bus, got %q", got)
+	}
+	if !replay.EventStartTime.Equal(start) || !replay.EventEndTime.Equal(end) {
+		t.Errorf("unexpected replay period %s to %s", replay.EventStartTime, replay.EventEndTime)
+	}
+}
+
+func TestReplayName(t *testing.T) {
+	t.Parallel()
+
+	end := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	name := replayName(strings.Repeat("a", 70)+"Archive", end)
+	if len(name) != 64 {
+		t.Errorf("expected name of 64 characters, got %d: %q", len(name), name)
+	}
+	if !strings.HasSuffix(name, "Archive-20260301T120000") {
+		t.Errorf("expected the end of the archive name to be kept, got %q", name)
+	}
+}
@@ -0,0 +1,300 @@
+package main
+
+import (
+	"context"
+	"io"
+	"io/fs"
+	"mime"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/advdv/ago/agcdk/agcdkstatic"
+	"github.com/advdv/ago/cmd/ago/internal/awsapi"
+	"github.com/advdv/ago/cmd/ago/internal/config"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudfront"
+	cftypes "github.com/aws/aws-sdk-go-v2/service/cloudfront/types"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/cockroachdb/errors"
+	"github.com/iancoleman/strcase"
+	"github.com/urfave/cli/v3"
+)
+
+func frontendCmd() *cli.Command {
+	return &cli.Command{
+		Name:  "frontend",
+		Usage: "Publish the static sites of agcdkstatic",
+		Commands: []*cli.Command{
+			frontendDeployCmd(),
+		},
+	}
+}
+
+func frontendDeployCmd() *cli.Command {
+	return &cli.Command{
+		Name: "deploy",
+		Usage: "Upload a build directory to the bucket of a static site, deleting the files that are no " +
+			"longer in it, and invalidate the cache of its distribution",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:  "deployment",
+				Usage: "Deployment that owns the site (default: the caller's Dev deployment)",
+			},
+			&cli.StringFlag{
+				Name:     "site",
+				Usage:    "Name of the site, as given to agcdkstatic.New",
+				Required: true,
+			},
+			&cli.StringFlag{
+				Name:     "dir",
+				Usage:    "Build directory to upload, relative to the project directory",
+				Required: true,
+			},
+			&cli.BoolFlag{
+				Name:  "wait",
+				Usage: "Wait until the invalidation completed and the new files are served everywhere",
+			},
+		},
+		Action: config.RunWithConfig(runFrontendDeploy),
+	}
+}
+
+type frontendDeployOptions struct {
+	Deployment string
+	Site       string
+	Dir        string
+	Wait       bool
+	Profile    string
+	Region     string
+	Output     io.Writer
+	Result     io.Writer
+}
+
+func runFrontendDeploy(ctx context.Context, cmd *cli.Command, cfg config.Config) error {
+	output, result := commandOutput(cmd)
+	return doFrontendDeploy(ctx, cfg, frontendDeployOptions{
+		Deployment: cmd.String("deployment"),
+		Site:       cmd.String("site"),
+		Dir:        cmd.String("dir"),
+		Wait:       cmd.Bool("wait"),
+		Profile:    cmd.String("profile"),
+		Region:     cmd.String("region"),
+		Output:     output,
+		Result:     result,
+	})
+}
+
+// staticSite is a site created by agcdkstatic, as recorded in the outputs of its stack.
+type staticSite struct {
+	Bucket         string `json:"bucket"`
+	DistributionID string `json:"distributionId"`
+	URL            string `json:"url"`
+}
+
+// frontendDeployResult is the result of 'ago frontend deploy' in the JSON output format.
+type frontendDeployResult struct {
+	staticSite
+
+	InvalidationID string `json:"invalidationId"`
+}
+
+func doFrontendDeploy(ctx context.Context, cfg config.Config, opts frontendDeployOptions) error {
+	dir := opts.Dir
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(cfg.ProjectDir, dir)
+	}
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		return errors.Errorf("build directory %q not found, build the site first", opts.Dir)
+	}
+
+	stacks, err := resolveDeploymentStacks(ctx, cfg, opts.Deployment, opts.Profile, opts.Region)
+	if err != nil {
+		return err
+	}
+	outputs, err := stacks.outputs(ctx, stacks.Stack)
+	if err != nil {
+		return err
+	}
+	site, err := resolveStaticSite(outputs, opts.Site)
+	if err != nil {
+		return errors.Wrapf(err, "in the outputs of %s", stacks.Stack)
+	}
+
+	writeOutputf(opts.Output, "Uploading %s to s3://%s...\n", opts.Dir, site.Bucket)
+	if err := syncSiteBucket(ctx, stacks.aws, dir, site.Bucket); err != nil {
+		return errors.Wrapf(err, "failed to upload %s to the bucket of site %q", opts.Dir, opts.Site)
+	}
+
+	// CloudFront is global: its API is served from us-east-1 whatever the region of the stack.
+	invalidation, err := stacks.aws.CloudFront.CreateInvalidation(ctx, &cloudfront.CreateInvalidationInput{
+		DistributionId: aws.String(site.DistributionID),
+		InvalidationBatch: &cftypes.InvalidationBatch{
+			CallerReference: aws.String("ago-" + strconv.FormatInt(time.Now().UnixNano(), 10)),
+			Paths:           &cftypes.Paths{Quantity: aws.Int32(1), Items: []string{"/*"}},
+		},
+	})
+	if err != nil {
+		return errors.Wrapf(err, "failed to invalidate the cache of site %q", opts.Site)
+	}
+	var invalidationID string
+	if invalidation.Invalidation != nil {
+		invalidationID = aws.ToString(invalidation.Invalidation.Id)
+	}
+	writeOutputf(opts.Output, "Invalidating the cache of distribution %s (invalidation %s)...\n",
+		site.DistributionID, invalidationID)
+
+	if opts.Wait && !stacks.aws.DryRun {
+		if err := cloudfront.NewInvalidationCompletedWaiter(stacks.aws.CloudFront).Wait(ctx,
+			&cloudfront.GetInvalidationInput{
+				DistributionId: aws.String(site.DistributionID),
+				Id:             aws.String(invalidationID),
+			}, invalidationWaitTimeout); err != nil {
+			return errors.Wrapf(err, "failed waiting for invalidation %s", invalidationID)
+		}
+	}
+
+	writeOutputf(opts.Output, "Deployed site %q to %s\n", opts.Site, site.URL)
+	return writeResult(opts.Result, frontendDeployResult{staticSite: site, InvalidationID: invalidationID})
+}
+
+// invalidationWaitTimeout is how long --wait waits for CloudFront to invalidate a site's cache.
+const invalidationWaitTimeout = 15 * time.Minute
+
+// siteFile is a file of a site's build directory, or an object in the bucket of the site.
+type siteFile struct {
+	Size    int64
+	ModTime time.Time
+}
+
+// syncSiteBucket makes the bucket of a site hold the files of the build directory, like 'aws s3
+// sync --delete' does: files that changed are uploaded and objects without a file are deleted.
+func syncSiteBucket(ctx context.Context, clients *awsapi.Clients, dir, bucket string) error {
+	files := map[string]siteFile{}
+	if err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		files[filepath.ToSlash(rel)] = siteFile{Size: info.Size(), ModTime: info.ModTime()}
+		return nil
+	}); err != nil {
+		return errors.Wrapf(err, "failed to read %s", dir)
+	}
+
+	objects := map[string]siteFile{}
+	pages := s3.NewListObjectsV2Paginator(clients.S3, &s3.ListObjectsV2Input{Bucket: aws.String(bucket)})
+	for pages.HasMorePages() {
+		page, err := pages.NextPage(ctx)
+		if err != nil {
+			return errors.Wrapf(err, "failed to list the objects in bucket %q", bucket)
+		}
+		for _, o := range page.Contents {
+			objects[aws.ToString(o.Key)] = siteFile{Size: aws.ToInt64(o.Size), ModTime: aws.ToTime(o.LastModified)}
+		}
+	}
+
+	upload, remove := planSiteSync(files, objects)
+	for _, key := range upload {
+		if err := uploadSiteFile(ctx, clients, filepath.Join(dir, filepath.FromSlash(key)), bucket, key); err != nil {
+			return err
+		}
+	}
+
+	for batch := range slices.Chunk(remove, 1000) {
+		ids := make([]s3types.ObjectIdentifier, 0, len(batch))
+		for _, key := range batch {
+			ids = append(ids, s3types.ObjectIdentifier{Key: aws.String(key)})
+		}
+		out, err := clients.S3.DeleteObjects(ctx, &s3.DeleteObjectsInput{
+			Bucket: aws.String(bucket),
+			Delete: &s3types.Delete{Objects: ids, Quiet: aws.Bool(true)},
+		})
+		if err != nil {
+			return errors.Wrapf(err, "failed to delete stale objects from bucket %q", bucket)
+		}
+		if len(out.Errors) > 0 {
+			return errors.Errorf("failed to delete %s from bucket %q: %s", aws.ToString(out.Errors[0].Key), bucket,
+				aws.ToString(out.Errors[0].Message))
+		}
+	}
+	return nil
+}
+
+// planSiteSync returns the keys of the files to upload and of the objects to delete, both sorted.
+// A file is uploaded when the bucket lacks it, when its size differs from the object's, or when it
+// was modified after the object was uploaded.
+func planSiteSync(files, objects map[string]siteFile) (upload, remove []string) {
+	for key, file := range files {
+		object, ok := objects[key]
+		if !ok || object.Size != file.Size || file.ModTime.After(object.ModTime) {
+			upload = append(upload, key)
+		}
+	}
+	for key := range objects {
+		if _, ok := files[key]; !ok {
+			remove = append(remove, key)
+		}
+	}
+	slices.Sort(upload)
+	slices.Sort(remove)
+	return upload, remove
+}
+
+// uploadSiteFile uploads a file with the content type of its extension, so that browsers render
+// the pages and assets of the site.
+func uploadSiteFile(ctx context.Context, clients *awsapi.Clients, path, bucket, key string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return errors.Wrapf(err, "failed to open %s", path)
+	}
+	defer f.Close()
+
+	in := &s3.PutObjectInput{Bucket: aws.String(bucket), Key: aws.String(key), Body: f}
+	if contentType := mime.TypeByExtension(filepath.Ext(path)); contentType != "" {
+		in.ContentType = aws.String(contentType)
+	}
+	if _, err := clients.S3.PutObject(ctx, in); err != nil {
+		return errors.Wrapf(err, "failed to upload %s", key)
+	}
+	return nil
+}
+
+// resolveStaticSite returns the named site from the outputs of a stack. When it is not there,
+// the error names the sites that are.
+func resolveStaticSite(outputs map[string]string, name string) (staticSite, error) {
+	site := staticSite{
+		Bucket:         outputs[agcdkstatic.BucketOutputKey(name)],
+		DistributionID: outputs[agcdkstatic.DistributionIDOutputKey(name)],
+		URL:            outputs[agcdkstatic.URLOutputKey(name)],
+	}
+	if site.Bucket != "" && site.DistributionID != "" {
+		return site, nil
+	}
+
+	var sites []string
+	for key := range outputs {
+		camel, isStatic := strings.CutPrefix(key, "Static")
+		camel, isBucket := strings.CutSuffix(camel, "Bucket")
+		if isStatic && isBucket && camel != "" {
+			sites = append(sites, strcase.ToKebab(camel))
+		}
+	}
+	if len(sites) == 0 {
+		return staticSite{}, errors.New("no static sites found, host them with agcdkstatic.New")
+	}
+	slices.Sort(sites)
+	return staticSite{}, errors.Errorf("static site %q not found, available sites: %s",
+		name, strings.Join(sites, ", "))
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestResolveStaticSite(t *testing.T) {
+	t.Parallel()
+
+	outputs := map[string]string{
+		"StaticWebBucket":                "web-bucket",
+		"StaticWebDistributionID":        "E123",
+		"StaticWebURL":                   "https://web-devadam.example.com",
+		"StaticAdminPanelBucket":         "admin-bucket",
+		"StaticAdminPanelDistributionID": "E456",
+		"WebAPIApiURL":                   "https://api-devadam.example.com",
+	}
+
+	site, err := resolveStaticSite(outputs, "web")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if site.Bucket != "web-bucket" || site.DistributionID != "E123" || site.URL != "https://web-devadam.example.com" {
+		t.Errorf("unexpected site: %+v", site)
+	}
+
+	_, err = resolveStaticSite(outputs, "docs")
+	if err == nil || !strings.Contains(err.Error(), "available sites: admin-panel, web") {
+		t.Errorf("expected error naming the available sites, got %v", err)
+	}
+
+	_, err = resolveStaticSite(map[string]string{}, "web")
+	if err == nil || !strings.Contains(err.Error(), "no static sites found") {
+		t.Errorf("expected error without sites, got %v", err)
+	}
+}
+
+func TestPlanSiteSync(t *testing.T) {
+	t.Parallel()
+
+	uploaded := time.Date(2025, 1, 1, 10, 0, 0, 0, time.UTC)
+	files := map[string]siteFile{
+		"index.html":       {Size: 100, ModTime: uploaded.Add(-time.Hour)},
+		"app.js":           {Size: 200, ModTime: uploaded.Add(-time.Hour)},
+		"style.css":        {Size: 300, ModTime: uploaded.Add(time.Hour)},
+		"assets/new.png":   {Size: 400, ModTime: uploaded.Add(-time.Hour)},
+		"assets/logo.webp": {Size: 500, ModTime: uploaded.Add(-time.Hour)},
+	}
+	objects := map[string]siteFile{
+		"index.html":       {Size: 100, ModTime: uploaded},
+		"app.js":           {Size: 250, ModTime: uploaded},
+		"style.css":        {Size: 300, ModTime: uploaded},
+		"assets/logo.webp": {Size: 500, ModTime: uploaded},
+		"old.js":           {Size: 600, ModTime: uploaded},
+	}
+
+	upload, remove := planSiteSync(files, objects)
+	if want := []string{"app.js", "assets/new.png", "style.css"}; !slices.Equal(upload, want) {
+		t.Errorf("expected uploads %v, got %v", want, upload)
+	}
+	if want := []string{"old.js"}; !slices.Equal(remove, want) {
+		t.Errorf("expected deletes %v, got %v", want, remove)
+	}
+}
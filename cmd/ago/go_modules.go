@@ -4,18 +4,24 @@ import (
 	"context"
 
 	"github.com/advdv/ago/cmd/ago/internal/cmdexec"
+	"github.com/advdv/ago/cmd/ago/internal/config"
 	"github.com/cockroachdb/errors"
 )
 
-var goModuleDirs = []string{"infra", "backend"}
+// goModuleDirs returns the directories of the project's Go modules, relative to the project
+// directory.
+func goModuleDirs(cfg config.Config) []string {
+	return []string{cfg.Inner.Layout.Infra(), cfg.Inner.Layout.Backend()}
+}
 
 func runInGoModules(
 	ctx context.Context,
+	cfg config.Config,
 	exec cmdexec.Executor,
 	cmd string,
 	args ...string,
 ) error {
-	for _, subdir := range goModuleDirs {
+	for _, subdir := range goModuleDirs(cfg) {
 		if err := exec.InSubdir(subdir).Run(ctx, cmd, args...); err != nil {
 			return errors.Wrapf(err, "failed in %s", subdir)
 		}
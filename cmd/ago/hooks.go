@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"io"
+	"strings"
+
+	"github.com/advdv/ago/cmd/ago/internal/cmdexec"
+	"github.com/advdv/ago/cmd/ago/internal/config"
+	"github.com/cockroachdb/errors"
+)
+
+// Hook phases.
+const (
+	hookPhasePre  = "pre"
+	hookPhasePost = "post"
+)
+
+// hookEnv is the information exposed to hooks through environment variables.
+type hookEnv struct {
+	Command     string
+	Deployment  string
+	Deployments []string
+	Profile     string
+	Qualifier   string
+	// Secret and SecretVersion name the secret that secrets-rotate rotates and its staged version.
+	Secret        string
+	SecretVersion string
+}
+
+// runHooks runs the hooks that .ago.yml declares for the command and phase. Each hook runs
+// through "sh -c" inside mise, so hooks can use the project's tools. The command, phase,
+// deployment, qualifier and AWS profile are passed as AGO_* and AWS_PROFILE variables.
+// AGO_DEPLOYMENT is only set when the command targets a single deployment; AGO_DEPLOYMENTS is
+// the comma-separated list of all deployments it targets. Hooks of secrets-rotate also see the
+// secret in AGO_SECRET and the ID of its new version in AGO_SECRET_VERSION.
+func runHooks(ctx context.Context, cfg config.Config, output io.Writer, phase string, env hookEnv) error {
+	hooks := cfg.Inner.HooksFor(env.Command)
+
+	commands := hooks.Pre
+	if phase == hookPhasePost {
+		commands = hooks.Post
+	}
+	if len(commands) == 0 {
+		return nil
+	}
+
+	exec := cmdexec.New(cfg).WithOutput(output, output).
+		WithEnv("AGO_COMMAND", env.Command).
+		WithEnv("AGO_HOOK", phase).
+		WithEnv("AGO_DEPLOYMENT", env.Deployment).
+		WithEnv("AGO_DEPLOYMENTS", strings.Join(env.Deployments, ",")).
+		WithEnv("AGO_QUALIFIER", env.Qualifier)
+	if env.Profile != "" {
+		exec = exec.WithEnv("AWS_PROFILE", env.Profile)
+	}
+	if env.Secret != "" {
+		exec = exec.WithEnv("AGO_SECRET", env.Secret).WithEnv("AGO_SECRET_VERSION", env.SecretVersion)
+	}
+
+	for _, command := range commands {
+		writeOutputf(output, "Running %s-%s hook: %s\n", phase, env.Command, command)
+		if err := exec.Mise(ctx, "sh", "-c", command); err != nil {
+			return errors.Wrapf(err, "%s-%s hook %q failed", phase, env.Command, command)
+		}
+	}
+
+	return nil
+}
+
+// withHooks runs fn between the pre and post hooks of the command. Post hooks only run when fn
+// succeeds.
+func withHooks(ctx context.Context, cfg config.Config, output io.Writer, env hookEnv, fn func() error) error {
+	if err := runHooks(ctx, cfg, output, hookPhasePre, env); err != nil {
+		return err
+	}
+
+	if err := fn(); err != nil {
+		return err
+	}
+
+	return runHooks(ctx, cfg, output, hookPhasePost, env)
+}
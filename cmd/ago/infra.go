@@ -7,9 +7,12 @@ func infraCmd() *cli.Command {
 		Name:  "infra",
 		Usage: "Infrastructure and cloud account management",
 		Commands: []*cli.Command{
+			infraDeployCmd(),
+			infraDiffCmd(),
 			cdkCmd(),
 			tfCmd(),
 			orgCmd(),
+			gamedayCmd(),
 		},
 	}
 }
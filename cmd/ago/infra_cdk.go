@@ -2,18 +2,19 @@ package main
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"io"
-	"maps"
 	"os"
-	"path/filepath"
 	"regexp"
 	"slices"
 	"strings"
 
+	"github.com/advdv/ago/agcdkutil"
+	"github.com/advdv/ago/cmd/ago/internal/awsapi"
+	"github.com/advdv/ago/cmd/ago/internal/cdkcontext"
 	"github.com/advdv/ago/cmd/ago/internal/cmdexec"
 	"github.com/advdv/ago/cmd/ago/internal/config"
+	"github.com/advdv/ago/cmd/ago/internal/ops"
 	"github.com/cockroachdb/errors"
 	"github.com/urfave/cli/v3"
 )
@@ -55,8 +56,16 @@ func cdkCmd() *cli.Command {
 			addDeployerCmd(),
 			removeDeployerCmd(),
 			deployCmd(),
+			deploySharedCmd(),
 			diffCmd(),
 			destroyCmd(),
+			lsCmd(),
+			synthCacheCmd(),
+			estimateCmd(),
+			contextDiffCmd(),
+			outputsCmd(),
+			sandboxSynthCmd(),
+			updateCITrustCmd(),
 		},
 	}
 }
@@ -67,62 +76,6 @@ func writeOutputf(w io.Writer, format string, args ...any) {
 	}
 }
 
-func getCDKContext(cdkDir string) (map[string]any, error) {
-	cdkJSONPath := filepath.Join(cdkDir, "cdk.json")
-	cdkContextPath := filepath.Join(cdkDir, "cdk.context.json")
-
-	result := make(map[string]any)
-
-	cdkJSONData, err := os.ReadFile(cdkJSONPath)
-	if err != nil {
-		return nil, errors.Wrap(err, "failed to read cdk.json")
-	}
-	var cdkJSON map[string]any
-	if err := json.Unmarshal(cdkJSONData, &cdkJSON); err != nil {
-		return nil, errors.Wrap(err, "failed to parse cdk.json")
-	}
-	maps.Copy(result, cdkJSON)
-
-	cdkContextData, err := os.ReadFile(cdkContextPath)
-	if err != nil {
-		return nil, errors.Wrap(err, "failed to read cdk.context.json")
-	}
-	var cdkContextJSON map[string]any
-	if err := json.Unmarshal(cdkContextData, &cdkContextJSON); err != nil {
-		return nil, errors.Wrap(err, "failed to parse cdk.context.json")
-	}
-	maps.Copy(result, cdkContextJSON)
-
-	return result, nil
-}
-
-func detectPrefix(context map[string]any) (string, error) {
-	for key := range context {
-		if idx := len(key) - len("qualifier"); idx > 0 && key[idx:] == "qualifier" {
-			return key[:idx], nil
-		}
-	}
-	return "", errors.New("could not detect context prefix - no key ending with 'qualifier' found")
-}
-
-func extractStringSlice(context map[string]any, key string) []string {
-	val, ok := context[key]
-	if !ok {
-		return nil
-	}
-	slice, ok := val.([]any)
-	if !ok {
-		return nil
-	}
-	result := make([]string, 0, len(slice))
-	for _, v := range slice {
-		if s, ok := v.(string); ok {
-			result = append(result, s)
-		}
-	}
-	return result
-}
-
 func parseCommaList(s string) []string {
 	if s == "" {
 		return nil
@@ -138,89 +91,40 @@ func parseCommaList(s string) []string {
 	return result
 }
 
-func readContextFile(path string) (map[string]any, error) {
-	data, err := os.ReadFile(path)
-	if err != nil {
-		return nil, errors.Wrap(err, "failed to read cdk.context.json")
-	}
-
-	var context map[string]any
-	if err := json.Unmarshal(data, &context); err != nil {
-		return nil, errors.Wrap(err, "failed to parse cdk.context.json")
-	}
-
-	return context, nil
-}
-
-func writeContextFile(path string, context map[string]any) error {
-	output, err := json.MarshalIndent(context, "", "  ")
-	if err != nil {
-		return errors.Wrap(err, "failed to marshal cdk.context.json")
-	}
-
-	//nolint:gosec // config file needs to be readable
-	if err := os.WriteFile(path, output, 0o644); err != nil {
-		return errors.Wrap(err, "failed to write cdk.context.json")
-	}
-
-	return nil
-}
-
 var errAssumedRole = errors.New("using assumed role")
 
 func isAssumedRoleARN(arn string) bool {
 	return strings.Contains(arn, ":assumed-role/")
 }
 
-func getCallerUsername(
-	ctx context.Context, exec cmdexec.Executor, qualifier string, cdkContext map[string]any,
-) (string, error) {
-	deployerProfile := findLocalDeployerProfile(ctx, exec, qualifier)
+func getCallerUsername(ctx context.Context, cdkCtx *cdkcontext.Context) (string, error) {
+	deployerProfile := findLocalDeployerProfile(cdkCtx.Qualifier)
 	if deployerProfile != "" {
-		username, err := getUsernameFromProfile(ctx, exec, deployerProfile)
+		username, err := getUsernameFromProfile(ctx, deployerProfile)
 		if err == nil {
 			return username, nil
 		}
 	}
 
-	profile, ok := cdkContext["admin-profile"].(string)
-	if !ok || profile == "" {
+	if cdkCtx.AdminProfile == "" {
 		return "", errors.New("admin-profile not found in cdk.json")
 	}
 
-	output, err := exec.MiseOutput(ctx, "aws", "sts", "get-caller-identity",
-		"--profile", profile,
-		"--query", "Arn",
-		"--output", "text",
-	)
-	if err != nil {
-		return "", errors.Wrap(err, "failed to get caller identity")
-	}
-
-	if isAssumedRoleARN(output) {
-		return "", errAssumedRole
-	}
-
-	parts := strings.Split(output, "/")
-	if len(parts) < 2 {
-		return "", errors.Errorf("unexpected ARN format: %s", output)
-	}
-
-	return parts[len(parts)-1], nil
+	return getUsernameFromProfile(ctx, cdkCtx.AdminProfile)
 }
 
-func findLocalDeployerProfile(ctx context.Context, exec cmdexec.Executor, qualifier string) string {
+func findLocalDeployerProfile(qualifier string) string {
 	if qualifier == "" {
 		return ""
 	}
 
-	output, err := exec.MiseOutput(ctx, "aws", "configure", "list-profiles")
+	profiles, err := ops.ListProfiles()
 	if err != nil {
 		return ""
 	}
 
 	prefix := qualifier + "-"
-	for profile := range strings.SplitSeq(output, "\n") {
+	for _, profile := range profiles {
 		if strings.HasPrefix(profile, prefix) && profile != qualifier+"-admin" {
 			return profile
 		}
@@ -229,23 +133,27 @@ func findLocalDeployerProfile(ctx context.Context, exec cmdexec.Executor, qualif
 	return ""
 }
 
-func getUsernameFromProfile(ctx context.Context, exec cmdexec.Executor, profile string) (string, error) {
-	output, err := exec.MiseOutput(ctx, "aws", "sts", "get-caller-identity",
-		"--profile", profile,
-		"--query", "Arn",
-		"--output", "text",
-	)
+func getUsernameFromProfile(ctx context.Context, profile string) (string, error) {
+	clients, err := awsapi.New(ctx, profile, "")
 	if err != nil {
-		return "", errors.Wrap(err, "failed to get caller identity")
+		return "", err
 	}
 
-	if isAssumedRoleARN(output) {
+	arn, err := ops.CallerARN(ctx, clients)
+	if err != nil {
+		return "", err
+	}
+
+	if _, username, ok := ssoRoleSession(arn); ok {
+		return username, nil
+	}
+	if isAssumedRoleARN(arn) {
 		return "", errAssumedRole
 	}
 
-	parts := strings.Split(output, "/")
+	parts := strings.Split(arn, "/")
 	if len(parts) < 2 {
-		return "", errors.Errorf("unexpected ARN format: %s", output)
+		return "", errors.Errorf("unexpected ARN format: %s", arn)
 	}
 
 	return parts[len(parts)-1], nil
@@ -259,22 +167,40 @@ func formatDeploymentsList(deployments []string) string {
 }
 
 type cdkCommandOptions struct {
-	Deployment string
-	All        bool
-	Hotswap    bool
-	Output     io.Writer
+	Deployment       string
+	Profile          string
+	Region           string
+	All              bool
+	Deployments      []string
+	AllDev           bool
+	Concurrency      int
+	Ordered          bool
+	DeploymentOnly   bool
+	Hotswap          bool
+	RequestIncreases bool
+	Yes              bool
+	AllowProtected   bool
+	IgnoreWindow     bool
+	Acknowledge      []string
+	Output           io.Writer
 }
 
 func resolveDeploymentIdent(
+	inner config.InnerConfig,
 	opts cdkCommandOptions,
-	prefix string,
-	cdkContext map[string]any,
+	cdkCtx *cdkcontext.Context,
 	username string,
 	usernameErr error,
 ) (string, error) {
-	deployments := extractStringSlice(cdkContext, prefix+"deployments")
+	deployments := cdkCtx.Deployments
 
+	if opts.Deployment == "" {
+		opts.Deployment = config.DefaultDeployment(inner).Value
+	}
 	if opts.Deployment != "" {
+		if _, ok := tenantDeployments(cdkCtx)[opts.Deployment]; ok {
+			return opts.Deployment, nil
+		}
 		if !slices.Contains(deployments, opts.Deployment) {
 			return "", errors.Errorf("deployment %q not found\n\nAvailable deployments: %s",
 				opts.Deployment, formatDeploymentsList(deployments))
@@ -283,7 +209,7 @@ func resolveDeploymentIdent(
 	}
 
 	if os.Getenv("CI") != "" {
-		return "", errors.New("deployment identifier required in CI mode")
+		return "", errors.New("deployment identifier required in CI mode, pass it as an argument or set AGO_DEPLOYMENT")
 	}
 
 	if errors.Is(usernameErr, errAssumedRole) {
@@ -315,32 +241,70 @@ Available deployments: %s`, deployment, username, formatDeploymentsList(deployme
 	return deployment, nil
 }
 
-func getUserGroups(ctx context.Context, exec cmdexec.Executor, profile, username string) ([]string, error) {
-	output, err := exec.MiseOutput(ctx, "aws", "iam", "list-groups-for-user",
-		"--user-name", username,
-		"--profile", profile,
-		"--query", "Groups[].GroupName",
-		"--output", "json",
-	)
+// getUserGroups returns the deployer groups of the caller. An IAM Identity Center session belongs
+// to the group that its permission set stands for, since it has no IAM user.
+func getUserGroups(ctx context.Context, profile, username string) ([]string, error) {
+	clients, err := awsapi.New(ctx, profile, "")
 	if err != nil {
-		return nil, errors.Wrap(err, "failed to list groups for user")
+		return nil, err
 	}
 
-	var groups []string
-	if err := json.Unmarshal([]byte(output), &groups); err != nil {
-		return nil, errors.Wrap(err, "failed to parse groups")
+	if arn, err := ops.CallerARN(ctx, clients); err == nil {
+		if permissionSet, _, ok := ssoRoleSession(arn); ok {
+			if groups, ok := ssoCallerGroups(permissionSet); ok {
+				return groups, nil
+			}
+			return nil, errors.Errorf("permission set %q is not a deployer permission set", permissionSet)
+		}
 	}
 
-	return groups, nil
+	return ops.UserGroups(ctx, clients, username)
+}
+
+// callerGroups returns the IAM groups of the caller. A caller without an IAM user, such as the CI
+// deployer role or an assumed admin role, counts as a full deployer. An IAM Identity Center
+// session gets the group of its permission set.
+func callerGroups(ctx context.Context, profile, qualifier, username string, usernameErr error) ([]string, error) {
+	if errors.Is(usernameErr, errAssumedRole) {
+		return []string{ops.DeployersGroupName(qualifier)}, nil
+	}
+	return getUserGroups(ctx, profile, username)
 }
 
 func isFullDeployer(groups []string, qualifier string) bool {
-	deployersGroup := qualifier + "-deployers"
+	deployersGroup := ops.DeployersGroupName(qualifier)
 	return slices.Contains(groups, deployersGroup)
 }
 
+// isRestrictedDeployment reports whether a deployment requires full deployer permissions. The
+// deployments of tenants serve customers, so they are restricted like production.
+func isRestrictedDeployment(deployment string) bool {
+	return strings.HasPrefix(deployment, "Prod") || strings.HasPrefix(deployment, "Stag") ||
+		strings.HasPrefix(deployment, "Tenant")
+}
+
+// tenantDeployments returns the tenants of the tenants context key by their deployment identifier.
+func tenantDeployments(cdkCtx *cdkcontext.Context) map[string]string {
+	tenants := map[string]string{}
+	for _, tenant := range cdkCtx.Strings(agcdkutil.TenantsContextKey) {
+		tenants[agcdkutil.TenantDeploymentIdent(tenant)] = tenant
+	}
+	return tenants
+}
+
+// tenantContextArgs returns the context assignment that limits a synth to the stacks of the tenant
+// when the deployment is the deployment of a tenant, so that a synth of an app with many tenants
+// does not build the stacks of all of them.
+func tenantContextArgs(cdkCtx *cdkcontext.Context, deployment string) []string {
+	tenant, ok := tenantDeployments(cdkCtx)[deployment]
+	if !ok {
+		return nil
+	}
+	return []string{"-c", cdkCtx.Key(agcdkutil.TenantContextKey) + "=" + tenant}
+}
+
 func checkDeploymentPermission(deployment string, isFullDep bool) error {
-	if (strings.HasPrefix(deployment, "Prod") || strings.HasPrefix(deployment, "Stag")) && !isFullDep {
+	if isRestrictedDeployment(deployment) && !isFullDep {
 		return errors.Errorf(
 			"deployment %q requires full deployer permissions (member of deployers group)",
 			deployment,
@@ -349,21 +313,16 @@ func checkDeploymentPermission(deployment string, isFullDep bool) error {
 	return nil
 }
 
-func resolveProfile(
-	ctx context.Context, exec cmdexec.Executor, cdkContext map[string]any, qualifier, username string,
-) string {
-	deployerProfile := qualifier + "-" + strings.ToLower(username)
+func resolveProfile(cdkCtx *cdkcontext.Context, username string) string {
+	deployerProfile := cdkCtx.Qualifier + "-" + strings.ToLower(username)
 
-	output, err := exec.MiseOutput(ctx, "aws", "configure", "list-profiles")
-	if err == nil {
-		profiles := strings.Split(output, "\n")
-		if slices.Contains(profiles, deployerProfile) {
-			return deployerProfile
-		}
+	profiles, err := ops.ListProfiles()
+	if err == nil && slices.Contains(profiles, deployerProfile) {
+		return deployerProfile
 	}
 
-	if adminProfile, ok := cdkContext["admin-profile"].(string); ok && adminProfile != "" {
-		return adminProfile
+	if cdkCtx.AdminProfile != "" {
+		return cdkCtx.AdminProfile
 	}
 
 	return deployerProfile
@@ -378,12 +337,18 @@ func buildCDKArgs(profile, qualifier, prefix string, userGroups []string) []stri
 	)
 
 	if len(userGroups) > 0 {
-		args = append(args, "-c", prefix+"deployer-groups="+strings.Join(userGroups, " "))
+		args = append(args, "-c", deployerGroupsContext(prefix, userGroups))
 	}
 
 	return args
 }
 
+// deployerGroupsContext returns the context assignment that tells the CDK app which
+// deployer groups the caller belongs to, which determines the deployments it synthesizes.
+func deployerGroupsContext(prefix string, userGroups []string) string {
+	return prefix + "deployer-groups=" + strings.Join(userGroups, " ")
+}
+
 func runCDKCommand(ctx context.Context, exec cmdexec.Executor, command string, args []string) error {
 	fullArgs := append([]string{command}, args...)
 	return exec.Mise(ctx, "cdk", fullArgs...)
@@ -391,38 +356,23 @@ func runCDKCommand(ctx context.Context, exec cmdexec.Executor, command string, a
 
 // cdkContext holds common CDK context needed by most CDK commands.
 type cdkContext struct {
-	Exec       cmdexec.Executor
-	CDKExec    cmdexec.Executor
-	CDKDir     string
-	CDKContext map[string]any
-	Prefix     string
-	Qualifier  string
+	*cdkcontext.Context
+
+	Exec    cmdexec.Executor
+	CDKExec cmdexec.Executor
+	CDKDir  string
 }
 
 func loadCDKContext(cfg config.Config) (*cdkContext, error) {
-	cdkDir := filepath.Join(cfg.ProjectDir, "infra", "cdk", "cdk")
-
-	cdkCtx, err := getCDKContext(cdkDir)
+	cdkCtx, err := readCDKContext(cfg)
 	if err != nil {
 		return nil, err
 	}
 
-	prefix, err := detectPrefix(cdkCtx)
-	if err != nil {
-		return nil, err
-	}
-
-	qualifier, ok := cdkCtx[prefix+"qualifier"].(string)
-	if !ok || qualifier == "" {
-		return nil, errors.Errorf("qualifier not found at context key %q", prefix+"qualifier")
-	}
-
 	return &cdkContext{
-		Exec:       cmdexec.New(cfg),
-		CDKExec:    cmdexec.New(cfg).InSubdir("infra/cdk/cdk"),
-		CDKDir:     cdkDir,
-		CDKContext: cdkCtx,
-		Prefix:     prefix,
-		Qualifier:  qualifier,
+		Context: cdkCtx,
+		Exec:    cmdexec.New(cfg),
+		CDKExec: cmdexec.New(cfg).InSubdir(cfg.Inner.Layout.CDK()),
+		CDKDir:  cfg.CDKDir(),
 	}, nil
 }
@@ -2,13 +2,15 @@ package main
 
 import (
 	"context"
-	"encoding/json"
 	"io"
+	"maps"
+	"net/url"
 	"os"
 	"path/filepath"
 	"slices"
 	"strings"
 
+	"github.com/advdv/ago/cmd/ago/internal/cdkcontext"
 	"github.com/advdv/ago/cmd/ago/internal/config"
 	"github.com/cockroachdb/errors"
 	"github.com/urfave/cli/v3"
@@ -24,15 +26,23 @@ func addDeployerCmd() *cli.Command {
 				Name:  "dev",
 				Usage: "Add to dev-deployers group instead of full deployers",
 			},
+			&cli.BoolFlag{
+				Name: "provision",
+				Usage: "Also bootstrap and deploy the new Dev deployment right away, and print its endpoints " +
+					"(requires the admin profile)",
+			},
 		},
 		Action: config.RunWithConfig(runAddDeployer),
 	}
 }
 
 type deployerOptions struct {
-	Username string
-	DevOnly  bool
-	Output   io.Writer
+	Username  string
+	DevOnly   bool
+	Provision bool
+	Profile   string
+	Region    string
+	Output    io.Writer
 }
 
 func runAddDeployer(ctx context.Context, cmd *cli.Command, cfg config.Config) error {
@@ -42,32 +52,28 @@ func runAddDeployer(ctx context.Context, cmd *cli.Command, cfg config.Config) er
 	}
 
 	return doAddDeployer(ctx, cfg, deployerOptions{
-		Username: username,
-		DevOnly:  cmd.Bool("dev"),
-		Output:   os.Stdout,
+		Username:  username,
+		DevOnly:   cmd.Bool("dev"),
+		Provision: cmd.Bool("provision"),
+		Profile:   cmd.String("profile"),
+		Region:    cmd.String("region"),
+		Output:    os.Stdout,
 	})
 }
 
-func doAddDeployer(_ context.Context, cfg config.Config, opts deployerOptions) error {
+func doAddDeployer(ctx context.Context, cfg config.Config, opts deployerOptions) error {
 	if err := validateDeployerUsername(opts.Username); err != nil {
 		return err
 	}
 
-	cdkDir := filepath.Join(cfg.ProjectDir, "infra", "cdk", "cdk")
-	contextPath := filepath.Join(cdkDir, "cdk.context.json")
-
-	cdkCtx, err := getCDKContext(cdkDir)
-	if err != nil {
-		return err
-	}
+	cdkDir := cfg.CDKDir()
 
-	prefix, err := detectPrefix(cdkCtx)
+	cdkCtx, err := readCDKContext(cfg)
 	if err != nil {
 		return err
 	}
 
-	deployers := extractStringSlice(cdkCtx, prefix+"deployers")
-	devDeployers := extractStringSlice(cdkCtx, prefix+"dev-deployers")
+	deployers, devDeployers := cdkCtx.Deployers, cdkCtx.DevDeployers
 	isFirstDeployer := len(deployers) == 0 && len(devDeployers) == 0
 
 	if slices.Contains(deployers, opts.Username) {
@@ -77,71 +83,119 @@ func doAddDeployer(_ context.Context, cfg config.Config, opts deployerOptions) e
 		return errors.Errorf("user %q already exists in dev-deployers list", opts.Username)
 	}
 
-	contextJSON, err := readContextFile(contextPath)
-	if err != nil {
-		return err
-	}
-
+	listKey, list := "deployers", deployers
 	if opts.DevOnly {
-		devDeployers = append(devDeployers, opts.Username)
-		contextJSON[prefix+"dev-deployers"] = devDeployers
-		writeOutputf(opts.Output, "Added %q to dev-deployers in cdk.context.json\n", opts.Username)
-	} else {
-		deployers = append(deployers, opts.Username)
-		contextJSON[prefix+"deployers"] = deployers
-		writeOutputf(opts.Output, "Added %q to deployers in cdk.context.json\n", opts.Username)
+		listKey, list = "dev-deployers", devDeployers
 	}
-
 	deploymentIdent := "Dev" + opts.Username
-	deployments := extractStringSlice(cdkCtx, prefix+"deployments")
-	if !slices.Contains(deployments, deploymentIdent) {
-		deployments = append(deployments, deploymentIdent)
-		contextJSON[prefix+"deployments"] = deployments
-		writeOutputf(opts.Output, "Added %q to deployments in cdk.context.json\n", deploymentIdent)
-	}
+	addDeployment := !slices.Contains(cdkCtx.Deployments, deploymentIdent)
 
-	if err := writeContextFile(contextPath, contextJSON); err != nil {
+	err = cdkcontext.Update(cdkDir, func(values map[string]any, prefix string) error {
+		values[prefix+listKey] = append(list, opts.Username)
+		if addDeployment {
+			values[prefix+"deployments"] = append(cdkCtx.Deployments, deploymentIdent)
+		}
+		return nil
+	})
+	if err != nil {
 		return err
 	}
+	writeOutputf(opts.Output, "Added %q to %s in %s\n", opts.Username, listKey, cdkcontext.FileName)
+	if addDeployment {
+		writeOutputf(opts.Output, "Added %q to deployments in %s\n", deploymentIdent, cdkcontext.FileName)
+	}
 
-	qualifier, _ := cdkCtx[prefix+"qualifier"].(string)
-	if isFirstDeployer && qualifier != "" {
-		if err := setCDKJSONProfile(cdkDir, qualifier, opts.Username); err != nil {
+	if isFirstDeployer {
+		if err := setCDKJSONProfile(cdkDir, cdkCtx.Qualifier, opts.Username); err != nil {
 			writeOutputf(opts.Output, "Warning: could not update cdk.json profile: %v\n", err)
 		} else {
-			writeOutputf(opts.Output, "Updated cdk.json profile to %q\n", qualifier+"-"+strings.ToLower(opts.Username))
+			writeOutputf(opts.Output, "Updated cdk.json profile to %q\n",
+				cdkCtx.Qualifier+"-"+strings.ToLower(opts.Username))
 		}
 	}
 
+	if opts.Provision {
+		return provisionDeployment(ctx, cfg, opts, deploymentIdent)
+	}
+
 	writeOutputf(opts.Output, "Run 'ago infra cdk bootstrap' to create the user and configure credentials.\n")
 	return nil
 }
 
-func setCDKJSONProfile(cdkDir, qualifier, username string) error {
-	cdkJSONPath := filepath.Join(cdkDir, "cdk.json")
-
-	data, err := os.ReadFile(cdkJSONPath)
+// provisionDeployment bootstraps the project, which creates the new deployer, deploys the stacks
+// of its Dev deployment and prints the endpoints that the deployment stack recorded.
+func provisionDeployment(ctx context.Context, cfg config.Config, opts deployerOptions, deployment string) error {
+	writeOutputf(opts.Output, "\nBootstrapping to create deployer %q...\n", opts.Username)
+	if err := doBootstrap(ctx, cfg, bootstrapOptions{
+		Profile: opts.Profile,
+		Output:  opts.Output,
+	}); err != nil {
+		return errors.Wrap(err, "bootstrap failed, rerun 'ago infra cdk bootstrap' and then 'ago infra cdk deploy "+
+			deployment+"'")
+	}
+
+	writeOutputf(opts.Output, "\nDeploying %s...\n", deployment)
+	if err := withNotification(ctx, cfg, opts.Output, "deploy", deployment, func() error {
+		return doDeploy(ctx, cfg, cdkCommandOptions{
+			Deployment: deployment,
+			Profile:    opts.Profile,
+			Region:     opts.Region,
+			Yes:        true,
+			Output:     opts.Output,
+		})
+	}); err != nil {
+		return errors.Wrapf(err, "deploy failed, rerun 'ago infra cdk deploy %s'", deployment)
+	}
+
+	stacks, err := resolveDeploymentStacks(ctx, cfg, deployment, opts.Profile, "")
 	if err != nil {
-		return errors.Wrap(err, "failed to read cdk.json")
+		return err
+	}
+	outputs, err := stacks.outputs(ctx, stacks.Stack)
+	if err != nil {
+		return err
 	}
 
-	var cdkJSON map[string]any
-	if err := json.Unmarshal(data, &cdkJSON); err != nil {
-		return errors.Wrap(err, "failed to parse cdk.json")
+	writeOutputf(opts.Output, "\n%s is ready.\n", deployment)
+	endpoints := deploymentEndpoints(outputs)
+	if len(endpoints) == 0 {
+		writeOutputf(opts.Output, "%s records no endpoints.\n", stacks.Stack)
+		return nil
 	}
+	writeOutputf(opts.Output, "Endpoints:\n")
+	for _, key := range slices.Sorted(maps.Keys(endpoints)) {
+		writeOutputf(opts.Output, "  %-32s %s\n", key, endpoints[key])
+	}
+	return nil
+}
 
-	profileName := qualifier + "-" + strings.ToLower(username)
-	cdkJSON["profile"] = profileName
+// deploymentEndpoints returns the outputs whose value is a URL that clients connect to: http,
+// https and wss URLs, except the URLs of SQS queues.
+func deploymentEndpoints(outputs map[string]string) map[string]string {
+	endpoints := map[string]string{}
+	for key, value := range outputs {
+		u, err := url.Parse(value)
+		if err != nil || u.Host == "" {
+			continue
+		}
+		if u.Scheme != "http" && u.Scheme != "https" && u.Scheme != "wss" {
+			continue
+		}
+		if strings.HasPrefix(u.Host, "sqs.") {
+			continue
+		}
+		endpoints[key] = value
+	}
+	return endpoints
+}
 
-	output, err := json.MarshalIndent(cdkJSON, "", "  ")
+func setCDKJSONProfile(cdkDir, qualifier, username string) error {
+	f, err := cdkcontext.ReadFile(filepath.Join(cdkDir, cdkcontext.JSONFileName))
 	if err != nil {
-		return errors.Wrap(err, "failed to marshal cdk.json")
+		return err
 	}
-
-	//nolint:gosec // config file needs to be readable
-	if err := os.WriteFile(cdkJSONPath, output, 0o644); err != nil {
-		return errors.Wrap(err, "failed to write cdk.json")
+	if err := f.Set("profile", qualifier+"-"+strings.ToLower(username)); err != nil {
+		return err
 	}
-
-	return nil
+	return f.Write()
 }
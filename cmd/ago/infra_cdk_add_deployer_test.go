@@ -0,0 +1,30 @@
+package main
+
+import (
+	"maps"
+	"testing"
+)
+
+func TestDeploymentEndpoints(t *testing.T) {
+	t.Parallel()
+
+	outputs := map[string]string{
+		"ServiceCoreapiURL":      "https://coreapi.dev.example.com",
+		"WebSocketChatURL":       "wss://abc123.execute-api.eu-central-1.amazonaws.com/live",
+		"QueueOrdersURL":         "https://sqs.eu-central-1.amazonaws.com/123456789012/orders",
+		"JobsScheduleGroup":      "myappEuc1DevAdam",
+		"DatabaseMainSecretARN":  "arn:aws:secretsmanager:eu-central-1:123456789012:secret:main",
+		"DatabaseProxyEndpoint":  "main.proxy-abc.eu-central-1.rds.amazonaws.com",
+		"ServiceLegacyURL":       "http://legacy-123.eu-central-1.elb.amazonaws.com",
+		"WebSocketChatCallbackX": "https://",
+	}
+
+	want := map[string]string{
+		"ServiceCoreapiURL": "https://coreapi.dev.example.com",
+		"WebSocketChatURL":  "wss://abc123.execute-api.eu-central-1.amazonaws.com/live",
+		"ServiceLegacyURL":  "http://legacy-123.eu-central-1.elb.amazonaws.com",
+	}
+	if got := deploymentEndpoints(outputs); !maps.Equal(got, want) {
+		t.Errorf("deploymentEndpoints() = %v, want %v", got, want)
+	}
+}
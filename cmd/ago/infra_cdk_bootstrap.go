@@ -2,395 +2,275 @@ package main
 
 import (
 	"context"
-	"encoding/json"
 	"io"
-	"os"
-	"path/filepath"
 	"strings"
 
+	"github.com/advdv/ago/agcdkutil"
+	"github.com/advdv/ago/cmd/ago/internal/awsapi"
+	"github.com/advdv/ago/cmd/ago/internal/cdkcontext"
 	"github.com/advdv/ago/cmd/ago/internal/cmdexec"
 	"github.com/advdv/ago/cmd/ago/internal/config"
+	"github.com/advdv/ago/cmd/ago/internal/ops"
 	"github.com/cockroachdb/errors"
 	"github.com/urfave/cli/v3"
 )
 
 func bootstrapCmd() *cli.Command {
 	return &cli.Command{
-		Name:   "bootstrap",
-		Usage:  "Bootstrap CDK in the AWS account",
+		Name:  "bootstrap",
+		Usage: "Bootstrap CDK in the AWS account",
+		Flags: []cli.Flag{
+			&cli.BoolFlag{
+				Name:  "request-increases",
+				Usage: "File Service Quotas increase requests for quotas the bootstrap would exceed",
+			},
+			&cli.StringFlag{
+				Name: "github-repo",
+				Usage: "GitHub repository, as owner/name, whose workflows may assume the CI deployer role " +
+					"(recorded in cdk.context.json, default: the recorded repository)",
+			},
+		},
 		Action: config.RunWithConfig(runBootstrap),
 	}
 }
 
 type bootstrapOptions struct {
-	Output io.Writer
+	RequestIncreases bool
+	GitHubRepository string
+	Profile          string
+	Region           string
+	Output           io.Writer
+	Result           io.Writer
 }
 
-func runBootstrap(ctx context.Context, _ *cli.Command, cfg config.Config) error {
-	return doBootstrap(ctx, cfg, bootstrapOptions{
-		Output: os.Stdout,
+// bootstrapResult is the result of bootstrap in the JSON output format.
+type bootstrapResult struct {
+	Qualifier               string   `json:"qualifier"`
+	Profile                 string   `json:"profile"`
+	Regions                 []string `json:"regions"`
+	PreBootstrapStack       string   `json:"preBootstrapStack"`
+	ToolkitStack            string   `json:"toolkitStack"`
+	ExecutionPolicyArn      string   `json:"executionPolicyArn"`
+	PermissionsBoundaryName string   `json:"permissionsBoundaryName"`
+}
+
+func runBootstrap(ctx context.Context, cmd *cli.Command, cfg config.Config) error {
+	output, result := commandOutput(cmd)
+	return withNotification(ctx, cfg, output, "bootstrap", "", func() error {
+		return doBootstrap(ctx, cfg, bootstrapOptions{
+			RequestIncreases: cmd.Bool("request-increases"),
+			GitHubRepository: cmd.String("github-repo"),
+			Profile:          cmd.String("profile"),
+			Region:           cmd.String("region"),
+			Output:           output,
+			Result:           result,
+		})
 	})
 }
 
 func doBootstrap(ctx context.Context, cfg config.Config, opts bootstrapOptions) error {
-	cdkDir := filepath.Join(cfg.ProjectDir, "infra", "cdk", "cdk")
-
-	exec := cmdexec.New(cfg).WithOutput(opts.Output, opts.Output)
-	cdkExec := cmdexec.New(cfg).InSubdir("infra/cdk/cdk").WithOutput(opts.Output, opts.Output)
-
-	writeOutputf(opts.Output, "Reading CDK context...\n")
-	cdkCtx, err := getCDKContext(cdkDir)
-	if err != nil {
+	if err := requireTools(ctx, cfg, "bootstrap"); err != nil {
 		return err
 	}
 
-	profile, ok := cdkCtx["admin-profile"].(string)
-	if !ok || profile == "" {
-		return errors.New("admin-profile not found in cdk.json - was 'ago infra create-aws-account' run?")
-	}
+	cdkDir := cfg.CDKDir()
 
-	prefix, err := detectPrefix(cdkCtx)
-	if err != nil {
-		return err
-	}
+	exec := cmdexec.New(cfg).WithOutput(opts.Output, opts.Output)
+	cdkExec := cmdexec.New(cfg).InSubdir(cfg.Inner.Layout.CDK()).WithOutput(opts.Output, opts.Output)
 
-	qualifier, ok := cdkCtx[prefix+"qualifier"].(string)
-	if !ok || qualifier == "" {
-		return errors.Errorf("qualifier not found at context key %q", prefix+"qualifier")
+	if opts.GitHubRepository != "" {
+		if err := validateGitHubRepository(opts.GitHubRepository); err != nil {
+			return err
+		}
 	}
 
-	secondaryRegions := extractStringSlice(cdkCtx, prefix+"secondary-regions")
-	deployers := extractStringSlice(cdkCtx, prefix+"deployers")
-	devDeployers := extractStringSlice(cdkCtx, prefix+"dev-deployers")
-
-	writeOutputf(opts.Output, "Verifying AWS access with profile %q...\n", profile)
-	if err := verifyAWSAccess(ctx, exec, profile); err != nil {
+	writeOutputf(opts.Output, "Reading CDK context...\n")
+	cdkCtx, err := readCDKContext(cfg)
+	if err != nil {
 		return err
 	}
 
-	services, err := ParseServicesFromContext(cdkCtx, prefix)
+	profile, err := resolveAWSProfile(opts.Profile, func() (string, error) { return getAdminProfile(cdkCtx) })
 	if err != nil {
-		return errors.Wrap(err, "failed to parse services from context")
-	}
-
-	writeOutputf(opts.Output, "Deploying pre-bootstrap stack...\n")
-	if len(deployers) > 0 {
-		writeOutputf(opts.Output, "  Deployers: %s\n", strings.Join(deployers, ", "))
-	}
-	if len(devDeployers) > 0 {
-		writeOutputf(opts.Output, "  Dev deployers: %s\n", strings.Join(devDeployers, ", "))
+		return err
 	}
-	writeOutputf(opts.Output, "  Services: %s\n", strings.Join(services, ", "))
 
-	preBootstrapStackName := qualifier + "-pre-bootstrap"
+	qualifier := cdkCtx.Qualifier
+	secondaryRegions := cdkCtx.SecondaryRegions
+	deployers := cdkCtx.Deployers
+	devDeployers := cdkCtx.DevDeployers
 
-	templatePath, cleanup, err := renderPreBootstrapTemplate(qualifier, services)
-	if err != nil {
-		return errors.Wrap(err, "failed to render pre-bootstrap template")
+	ciRepo := ciRepository(cdkCtx)
+	if opts.GitHubRepository != "" && opts.GitHubRepository != ciRepo {
+		if err := setCIRepository(cdkDir, opts.GitHubRepository); err != nil {
+			return err
+		}
+		ciRepo = opts.GitHubRepository
+		writeOutputf(opts.Output, "Set %s to %q in %s\n", cdkCtx.Key("ci-repository"), ciRepo, cdkcontext.FileName)
 	}
-	defer cleanup()
 
-	err = deployPreBootstrapStack(ctx, exec, profile, preBootstrapStackName, templatePath, qualifier,
-		secondaryRegions, deployers, devDeployers)
+	auth, err := deployerAuth(cdkCtx)
 	if err != nil {
 		return err
 	}
-
-	executionPolicyArn, err := getStackOutput(ctx, exec, profile, preBootstrapStackName, "ExecutionPolicyArn")
-	if err != nil {
-		return err
+	var ssoSettings deployerSSOSettings
+	if auth == ops.DeployerAuthSSO {
+		if ssoSettings, err = readDeployerSSOSettings(cdkCtx); err != nil {
+			return err
+		}
 	}
 
-	permissionsBoundaryName, err := getStackOutput(ctx, exec, profile, preBootstrapStackName, "PermissionsBoundaryName")
-	if err != nil {
+	if err := cdkCtx.Require("primary-region"); err != nil {
 		return err
 	}
+	primaryRegion := cdkCtx.PrimaryRegion
 
-	boundaryConfig, ok := cdkCtx["@aws-cdk/core:permissionsBoundary"].(map[string]any)
-	if !ok {
-		return errors.New("@aws-cdk/core:permissionsBoundary not found in cdk.context.json")
-	}
-	contextBoundaryName, ok := boundaryConfig["name"].(string)
-	if !ok || contextBoundaryName == "" {
-		return errors.New("@aws-cdk/core:permissionsBoundary.name not found in cdk.context.json")
-	}
-
-	if contextBoundaryName != permissionsBoundaryName {
-		return errors.Errorf(
-			"CDK context @aws-cdk/core:permissionsBoundary.name (%q) must match pre-bootstrap output (%q)",
-			contextBoundaryName, permissionsBoundaryName,
-		)
-	}
-
-	writeOutputf(opts.Output, "Running CDK bootstrap...\n")
-	err = runCDKBootstrap(ctx, cdkExec, profile, qualifier, executionPolicyArn, permissionsBoundaryName)
+	clients, err := awsapi.New(ctx, profile, primaryRegion)
 	if err != nil {
 		return err
 	}
 
-	writeOutputf(opts.Output, "Syncing deployer credentials...\n")
-	if err := syncDeployerCredentials(ctx, exec, opts.Output, profile, qualifier, deployers, devDeployers); err != nil {
+	writeOutputf(opts.Output, "Verifying AWS access with profile %q...\n", profile)
+	if err := ops.VerifyAccess(ctx, clients); err != nil {
 		return err
 	}
 
-	writeOutputf(opts.Output, "Bootstrap complete!\n")
-	return nil
-}
-
-func verifyAWSAccess(ctx context.Context, exec cmdexec.Executor, profile string) error {
-	return exec.Mise(ctx, "aws", "sts", "get-caller-identity", "--profile", profile)
-}
+	preBootstrapStackName := ops.PreBootstrapStackName(qualifier)
 
-func deployPreBootstrapStack(
-	ctx context.Context, exec cmdexec.Executor,
-	profile, stackName, templatePath, qualifier string,
-	secondaryRegions, deployers, devDeployers []string,
-) error {
-	secondaryRegionsParam := strings.Join(secondaryRegions, ",")
-	deployersParam := strings.Join(deployers, ",")
-	devDeployersParam := strings.Join(devDeployers, ",")
-
-	return exec.Mise(ctx, "aws", "cloudformation", "deploy",
-		"--stack-name", stackName,
-		"--template-file", templatePath,
-		"--parameter-overrides",
-		"Qualifier="+qualifier,
-		"SecondaryRegions="+secondaryRegionsParam,
-		"Deployers="+deployersParam,
-		"DevDeployers="+devDeployersParam,
-		"--capabilities", "CAPABILITY_NAMED_IAM",
-		"--no-fail-on-empty-changeset",
-		"--profile", profile,
-	)
-}
-
-func getStackOutput(ctx context.Context, exec cmdexec.Executor, profile, stackName, outputKey string) (string, error) {
-	output, err := exec.MiseOutput(ctx, "aws", "cloudformation", "describe-stacks",
-		"--stack-name", stackName,
-		"--query", "Stacks[0].Outputs",
-		"--output", "json",
-		"--profile", profile,
-	)
+	preBootstrapExists, err := ops.StackExists(ctx, clients, preBootstrapStackName)
 	if err != nil {
-		return "", errors.Wrap(err, "failed to describe stack")
+		return err
 	}
 
-	var outputs []struct {
-		OutputKey   string `json:"OutputKey"`   //nolint:tagliatelle // AWS API uses PascalCase
-		OutputValue string `json:"OutputValue"` //nolint:tagliatelle // AWS API uses PascalCase
+	plan := quotaPlan{
+		Partition: agcdkutil.PartitionFor(primaryRegion),
+		Stacks:    map[string][]string{primaryRegion: {preBootstrapStackName, ops.ToolkitStackName(qualifier)}},
 	}
-	if err := json.Unmarshal([]byte(output), &outputs); err != nil {
-		return "", errors.Wrap(err, "failed to parse stack outputs")
+	if !preBootstrapExists {
+		plan.ManagedPolicies = preBootstrapManagedPolicies
 	}
+	runQuotaPreflight(ctx, opts.Output, profile, plan, opts.RequestIncreases)
 
-	for _, o := range outputs {
-		if o.OutputKey == outputKey {
-			return o.OutputValue, nil
-		}
-	}
-
-	return "", errors.Errorf("output %q not found in stack %q", outputKey, stackName)
-}
-
-func runCDKBootstrap(
-	ctx context.Context, exec cmdexec.Executor,
-	profile, qualifier, executionPolicyArn, permissionsBoundaryName string,
-) error {
-	toolkitStackName := qualifier + "Bootstrap"
-
-	return exec.Mise(ctx, "cdk", "bootstrap",
-		"--profile", profile,
-		"--qualifier", qualifier,
-		"--toolkit-stack-name", toolkitStackName,
-		"--cloudformation-execution-policies", executionPolicyArn,
-		"--custom-permissions-boundary", permissionsBoundaryName,
-	)
-}
-
-func syncDeployerCredentials(
-	ctx context.Context, exec cmdexec.Executor, output io.Writer,
-	profile, qualifier string, deployers, devDeployers []string,
-) error {
-	existingProfiles, err := listDeployerProfiles(qualifier)
+	services, err := projectServices(cdkCtx)
 	if err != nil {
-		writeOutputf(output, "  Warning: could not list existing profiles: %v\n", err)
-		existingProfiles = nil
+		return errors.Wrap(err, "failed to parse services from context")
 	}
 
-	type deployerInfo struct {
-		username   string
-		secretPath string
-	}
-	expectedProfiles := make(map[string]deployerInfo)
-	for _, username := range deployers {
-		profileName := qualifier + "-" + strings.ToLower(username)
-		expectedProfiles[profileName] = deployerInfo{
-			username:   username,
-			secretPath: qualifier + "/deployers/" + username,
-		}
-	}
-	for _, username := range devDeployers {
-		profileName := qualifier + "-" + strings.ToLower(username)
-		expectedProfiles[profileName] = deployerInfo{
-			username:   username,
-			secretPath: qualifier + "/dev-deployers/" + username,
+	env := hookEnv{Command: "bootstrap", Profile: profile, Qualifier: qualifier}
+	lk := newLocker(cfg, profile, primaryRegion, qualifier)
+	return withLocks(ctx, lk, opts.Output, "bootstrap", []string{lockScopeBootstrap}, func() error {
+		if err := runHooks(ctx, cfg, opts.Output, hookPhasePre, env); err != nil {
+			return err
 		}
-	}
 
-	for _, existingProfile := range existingProfiles {
-		if _, expected := expectedProfiles[existingProfile]; !expected {
-			writeOutputf(output, "  Removing profile %q...\n", existingProfile)
-			if err := removeAWSProfile(existingProfile); err != nil {
-				writeOutputf(output, "    Warning: failed to remove profile: %v\n", err)
-			}
+		writeOutputf(opts.Output, "Deploying pre-bootstrap stack...\n")
+		if len(deployers) > 0 {
+			writeOutputf(opts.Output, "  Deployers: %s\n", strings.Join(deployers, ", "))
+		}
+		if len(devDeployers) > 0 {
+			writeOutputf(opts.Output, "  Dev deployers: %s\n", strings.Join(devDeployers, ", "))
+		}
+		writeOutputf(opts.Output, "  Deployer auth: %s\n", auth)
+		writeOutputf(opts.Output, "  Services: %s\n", strings.Join(services, ", "))
+		if ciRepo != "" {
+			writeOutputf(opts.Output, "  CI repository: %s\n", ciRepo)
+		} else {
+			writeOutputf(opts.Output, "  CI repository: none, so no CI deployer role (set one with --github-repo)\n")
 		}
-	}
 
-	for profileName, info := range expectedProfiles {
-		credentialsJSON, err := getSecretValue(ctx, exec, profile, info.secretPath)
+		templatePath, cleanup, err := renderPreBootstrapTemplate(qualifier, services)
 		if err != nil {
-			writeOutputf(output, "  Warning: could not fetch credentials for %s: %v\n", info.username, err)
-			continue
+			return errors.Wrap(err, "failed to render pre-bootstrap template")
 		}
+		defer cleanup()
 
-		var credentials struct {
-			AccessKeyID     string `json:"aws_access_key_id"`
-			SecretAccessKey string `json:"aws_secret_access_key"`
-		}
-		if err := json.Unmarshal([]byte(credentialsJSON), &credentials); err != nil {
-			writeOutputf(output, "  Warning: could not parse credentials for %s: %v\n", info.username, err)
-			continue
+		err = ops.DeployPreBootstrapStack(ctx, clients, preBootstrapStackName, templatePath,
+			ops.PreBootstrapParameters(qualifier, secondaryRegions, deployers, devDeployers, ciRepo, auth))
+		if err != nil {
+			return err
 		}
 
-		writeOutputf(output, "  Configuring profile %q for user %s...\n", profileName, info.username)
-		err = writeDeployerProfile(ctx, exec, profileName, credentials.AccessKeyID, credentials.SecretAccessKey)
+		executionPolicyArn, err := ops.StackOutput(ctx, clients, preBootstrapStackName,
+			agcdkutil.ExecutionPolicyARNOutputKey)
 		if err != nil {
-			writeOutputf(output, "    Warning: failed to write profile: %v\n", err)
+			return err
 		}
-	}
-
-	return nil
-}
-
-func listDeployerProfiles(qualifier string) ([]string, error) {
-	home, err := os.UserHomeDir()
-	if err != nil {
-		return nil, errors.Wrap(err, "failed to get home directory")
-	}
 
-	credentialsPath := filepath.Join(home, ".aws", "credentials")
-	data, err := os.ReadFile(credentialsPath)
-	if err != nil {
-		if os.IsNotExist(err) {
-			return nil, nil
+		permissionsBoundaryName, err := ops.StackOutput(ctx, clients, preBootstrapStackName,
+			agcdkutil.PermissionsBoundaryNameOutputKey)
+		if err != nil {
+			return err
 		}
-		return nil, errors.Wrap(err, "failed to read credentials file")
-	}
 
-	prefix := "[" + qualifier + "-"
-	var profiles []string
-	for line := range strings.SplitSeq(string(data), "\n") {
-		line = strings.TrimSpace(line)
-		if strings.HasPrefix(line, prefix) && strings.HasSuffix(line, "]") {
-			profileName := line[1 : len(line)-1]
-			profiles = append(profiles, profileName)
+		boundaryConfig, ok := cdkCtx.Values["@aws-cdk/core:permissionsBoundary"].(map[string]any)
+		if !ok {
+			return errors.New("@aws-cdk/core:permissionsBoundary not found in cdk.context.json")
 		}
-	}
-
-	return profiles, nil
-}
-
-func removeAWSProfile(profileName string) error {
-	home, err := os.UserHomeDir()
-	if err != nil {
-		return errors.Wrap(err, "failed to get home directory")
-	}
-
-	if err := removeProfileFromFile(
-		filepath.Join(home, ".aws", "credentials"), profileName); err != nil {
-		return err
-	}
-
-	if err := removeProfileFromFile(
-		filepath.Join(home, ".aws", "config"), "profile "+profileName); err != nil {
-		return err
-	}
-
-	return nil
-}
-
-func removeProfileFromFile(filePath, sectionName string) error {
-	data, err := os.ReadFile(filePath)
-	if err != nil {
-		if os.IsNotExist(err) {
-			return nil
+		contextBoundaryName, ok := boundaryConfig["name"].(string)
+		if !ok || contextBoundaryName == "" {
+			return errors.New("@aws-cdk/core:permissionsBoundary.name not found in cdk.context.json")
 		}
-		return errors.Wrapf(err, "failed to read %s", filePath)
-	}
 
-	lines := strings.Split(string(data), "\n")
-	var result []string
-	inSection := false
-	sectionHeader := "[" + sectionName + "]"
-
-	for _, line := range lines {
-		trimmed := strings.TrimSpace(line)
-
-		if trimmed == sectionHeader {
-			inSection = true
-			continue
+		if contextBoundaryName != permissionsBoundaryName {
+			return errors.Errorf(
+				"CDK context @aws-cdk/core:permissionsBoundary.name (%q) must match pre-bootstrap output (%q)",
+				contextBoundaryName, permissionsBoundaryName,
+			)
 		}
 
-		if inSection && strings.HasPrefix(trimmed, "[") {
-			inSection = false
+		writeOutputf(opts.Output, "Running CDK bootstrap...\n")
+		err = ops.CDKBootstrap(ctx, cdkExec, profile, qualifier, executionPolicyArn, permissionsBoundaryName)
+		if err != nil {
+			return err
 		}
 
-		if !inSection {
-			result = append(result, line)
+		writeOutputf(opts.Output, "Attaching deployment scope policy to deploy roles...\n")
+		regions := cdkCtx.Regions()
+		if err := ops.AttachDeploymentScopePolicy(ctx, clients, qualifier, regions); err != nil {
+			return err
 		}
-	}
-
-	for len(result) > 0 && strings.TrimSpace(result[len(result)-1]) == "" {
-		result = result[:len(result)-1]
-	}
-
-	output := strings.Join(result, "\n")
-	if output != "" {
-		output += "\n"
-	}
-
-	if err := os.WriteFile(filePath, []byte(output), 0o600); err != nil {
-		return errors.Wrapf(err, "failed to write %s", filePath)
-	}
-
-	return nil
-}
 
-func writeDeployerProfile(
-	ctx context.Context, exec cmdexec.Executor,
-	profileName, accessKeyID, secretAccessKey string,
-) error {
-	settings := []struct{ key, value string }{
-		{"aws_access_key_id", accessKeyID},
-		{"aws_secret_access_key", secretAccessKey},
-		{"region", "eu-central-1"},
-		{"cli_pager", ""},
-	}
+		sync := ops.DeployerSync{
+			Backend:      cfg.Inner.Credentials(),
+			Region:       resolveAWSRegion(opts.Region, primaryRegion, cfg.Inner.Region()),
+			Qualifier:    qualifier,
+			Deployers:    deployers,
+			DevDeployers: devDeployers,
+			Auth:         auth,
+		}
+		if auth == ops.DeployerAuthSSO {
+			accountID, err := ops.AccountID(ctx, clients)
+			if err != nil {
+				return err
+			}
+			writeOutputf(opts.Output, "Assigning IAM Identity Center permission sets to deployers...\n")
+			if err := deployDeployerSSO(ctx, opts.Output, ssoSettings, qualifier, accountID,
+				deployers, devDeployers); err != nil {
+				return err
+			}
+			sync.SSO = ops.SSOProfileConfig{
+				StartURL: ssoSettings.StartURL, Region: ssoSettings.Region, AccountID: accountID,
+			}
+		}
 
-	for _, s := range settings {
-		if err := exec.Mise(ctx, "aws", "configure", "set", s.key, s.value, "--profile", profileName); err != nil {
-			return errors.Wrapf(err, "failed to set %s for profile %s", s.key, profileName)
+		writeOutputf(opts.Output, "Syncing deployer credentials...\n")
+		if err := ops.SyncDeployerCredentials(ctx, exec, clients, opts.Output, sync); err != nil {
+			return err
 		}
-	}
 
-	return nil
-}
+		if err := runHooks(ctx, cfg, opts.Output, hookPhasePost, env); err != nil {
+			return err
+		}
 
-func getSecretValue(ctx context.Context, exec cmdexec.Executor, profile, secretName string) (string, error) {
-	return exec.MiseOutput(ctx, "aws", "secretsmanager", "get-secret-value",
-		"--secret-id", secretName,
-		"--query", "SecretString",
-		"--output", "text",
-		"--profile", profile,
-	)
+		writeOutputf(opts.Output, "Bootstrap complete!\n")
+		return writeResult(opts.Result, bootstrapResult{
+			Qualifier:               qualifier,
+			Profile:                 profile,
+			Regions:                 regions,
+			PreBootstrapStack:       preBootstrapStackName,
+			ToolkitStack:            ops.ToolkitStackName(qualifier),
+			ExecutionPolicyArn:      executionPolicyArn,
+			PermissionsBoundaryName: permissionsBoundaryName,
+		})
+	})
 }
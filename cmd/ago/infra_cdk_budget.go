@@ -0,0 +1,143 @@
+package main
+
+import (
+	"encoding/json"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/advdv/ago/agcdkutil"
+	"github.com/advdv/ago/cmd/ago/internal/config"
+	"github.com/advdv/ago/cmd/ago/internal/warnings"
+	"github.com/cockroachdb/errors"
+)
+
+// budgetWarnPercent is how full a stack's budget may get before synth warns about it, leaving
+// room to split the stack before a deploy fails on a CloudFormation limit.
+const budgetWarnPercent = 80
+
+// stackTemplateUsage is how much of the CloudFormation limits a synthesized stack uses. Deployment
+// is empty for the shared stacks.
+type stackTemplateUsage struct {
+	Stack         string
+	Deployment    string
+	Resources     int
+	TemplateBytes int
+	Outputs       int
+}
+
+// measureStackTemplates measures the synthesized templates of the project's stacks in a cloud
+// assembly. Stacks that were not synthesized are skipped.
+func measureStackTemplates(
+	assemblyDir, qualifier string, regions, deployments []string,
+) ([]stackTemplateUsage, error) {
+	stackDeployments := map[string]string{}
+	for _, region := range regions {
+		regionIdent := agcdkutil.RegionIdentFor(region)
+		stackDeployments[agcdkutil.SharedStackName(qualifier, regionIdent)] = ""
+		for _, deployment := range deployments {
+			stackDeployments[agcdkutil.DeploymentStackName(qualifier, regionIdent, deployment)] = deployment
+		}
+	}
+
+	var usage []stackTemplateUsage
+	err := filepath.WalkDir(assemblyDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		stack, ok := strings.CutSuffix(d.Name(), ".template.json")
+		if d.IsDir() || !ok {
+			return nil
+		}
+		deployment, ok := stackDeployments[stack]
+		if !ok {
+			return nil
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return errors.Wrapf(err, "failed to read template of stack %q", stack)
+		}
+		var template struct {
+			Resources map[string]json.RawMessage `json:"Resources"` //nolint:tagliatelle // CloudFormation
+			Outputs   map[string]json.RawMessage `json:"Outputs"`   //nolint:tagliatelle // CloudFormation
+		}
+		if err := json.Unmarshal(data, &template); err != nil {
+			return errors.Wrapf(err, "failed to parse template of stack %q", stack)
+		}
+
+		usage = append(usage, stackTemplateUsage{
+			Stack:         stack,
+			Deployment:    deployment,
+			Resources:     len(template.Resources),
+			TemplateBytes: len(data),
+			Outputs:       len(template.Outputs),
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to measure synthesized templates")
+	}
+	return usage, nil
+}
+
+// budgetFindings returns a line for every stack metric that reaches budgetWarnPercent of its
+// budget.
+func budgetFindings(usage []stackTemplateUsage, file agcdkutil.DeploymentsFile) []string {
+	var findings []string
+	for _, u := range usage {
+		budget := file.Budget(u.Deployment)
+		for _, m := range []struct {
+			name        string
+			used, limit int
+		}{
+			{"resources", u.Resources, budget.MaxResources},
+			{"template bytes", u.TemplateBytes, budget.MaxTemplateBytes},
+			{"outputs", u.Outputs, budget.MaxOutputs},
+		} {
+			percent := m.used * 100 / m.limit
+			if percent < budgetWarnPercent {
+				continue
+			}
+			line := u.Stack + ": " + strconv.Itoa(m.used) + " of " + strconv.Itoa(m.limit) + " " + m.name +
+				" (" + strconv.Itoa(percent) + "%)"
+			if m.used > m.limit {
+				line += ", over budget"
+			}
+			findings = append(findings, line)
+		}
+	}
+	return findings
+}
+
+// checkStackBudgets warns when synthesized stacks approach their budgets from
+// infra/deployments.yaml, which default to the CloudFormation limits.
+func checkStackBudgets(cfg config.Config, cdk *cdkContext, warn *warnings.Reporter, assemblyDir string) error {
+	deployments := cdk.Deployments
+	file, err := loadDeploymentsFile(cfg, deployments)
+	if err != nil {
+		return err
+	}
+
+	regions := cdk.Regions()
+	usage, err := measureStackTemplates(assemblyDir, cdk.Qualifier, regions, deployments)
+	if err != nil {
+		return err
+	}
+
+	findings := budgetFindings(usage, file)
+	if len(findings) == 0 {
+		return nil
+	}
+	warn.Report(warnings.Warning{
+		Code:     warnings.StackBudget,
+		Severity: warnings.Warn,
+		Summary:  "Stacks are approaching their CloudFormation budget",
+		Detail: strings.Join(findings, "\n") + "\n\n" +
+			"Move groups of constructs into an agcdkutil.NewSubStack, which has limits of its own, before a\n" +
+			"deploy fails on a CloudFormation limit. Budgets are set per deployment in infra/deployments.yaml.",
+	})
+	return nil
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"testing"
+
+	"github.com/advdv/ago/agcdkutil"
+)
+
+func TestStackBudgets(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	writeTemplate := func(name string, resources, outputs int) {
+		t.Helper()
+		template := map[string]map[string]any{"Resources": {}, "Outputs": {}}
+		for i := range resources {
+			template["Resources"]["R"+strconv.Itoa(i)] = map[string]any{"Type": "AWS::SNS::Topic"}
+		}
+		for i := range outputs {
+			template["Outputs"]["O"+strconv.Itoa(i)] = map[string]any{"Value": "x"}
+		}
+		data, err := json.Marshal(template)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, name+".template.json"), data, 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeTemplate("myappEuc1Shared", 450, 10)
+	writeTemplate("myappEuc1Prod", 100, 45)
+	writeTemplate("otherEuc1Prod", 499, 0)
+
+	usage, err := measureStackTemplates(dir, "myapp", []string{"eu-central-1"}, []string{"Prod"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	slices.SortFunc(usage, func(a, b stackTemplateUsage) int { return len(a.Stack) - len(b.Stack) })
+	if len(usage) != 2 || usage[0].Stack != "myappEuc1Prod" || usage[0].Deployment != "Prod" ||
+		usage[0].Resources != 100 || usage[0].Outputs != 45 || usage[1].Deployment != "" {
+		t.Fatalf("unexpected usage: %+v", usage)
+	}
+
+	file := agcdkutil.DeploymentsFile{Deployments: map[string]agcdkutil.DeploymentSettings{
+		"Prod": {Budget: agcdkutil.StackBudget{MaxOutputs: 40}},
+	}}
+	want := []string{
+		"myappEuc1Prod: 45 of 40 outputs (112%), over budget",
+		"myappEuc1Shared: 450 of 500 resources (90%)",
+	}
+	if got := budgetFindings(usage, file); !slices.Equal(got, want) {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+}
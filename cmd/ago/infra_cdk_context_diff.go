@@ -0,0 +1,191 @@
+package main
+
+import (
+	"context"
+	"io"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/advdv/ago/cmd/ago/internal/awsapi"
+	"github.com/advdv/ago/cmd/ago/internal/config"
+	"github.com/advdv/ago/cmd/ago/internal/ops"
+	"github.com/cockroachdb/errors"
+	"github.com/urfave/cli/v3"
+)
+
+func contextDiffCmd() *cli.Command {
+	return &cli.Command{
+		Name:   "context-diff",
+		Usage:  "Compare the CDK context with the deployed pre-bootstrap stack to see if a re-bootstrap is needed",
+		Action: config.RunWithConfig(runContextDiff),
+	}
+}
+
+type contextDiffOptions struct {
+	Profile string
+	Output  io.Writer
+}
+
+func runContextDiff(ctx context.Context, cmd *cli.Command, cfg config.Config) error {
+	return doContextDiff(ctx, cfg, contextDiffOptions{
+		Profile: cmd.String("profile"),
+		Output:  os.Stdout,
+	})
+}
+
+// parameterChange describes a pre-bootstrap parameter whose deployed value differs from the
+// value derived from the CDK context. Added and Removed hold the list items that differ.
+type parameterChange struct {
+	Key      string
+	Deployed string
+	Desired  string
+	Added    []string
+	Removed  []string
+}
+
+func doContextDiff(ctx context.Context, cfg config.Config, opts contextDiffOptions) error {
+	cdkCtx, err := readCDKContext(cfg)
+	if err != nil {
+		return err
+	}
+
+	profile, err := resolveAWSProfile(opts.Profile, func() (string, error) { return getAdminProfile(cdkCtx) })
+	if err != nil {
+		return err
+	}
+
+	if err := cdkCtx.Require("primary-region"); err != nil {
+		return err
+	}
+	qualifier, primaryRegion := cdkCtx.Qualifier, cdkCtx.PrimaryRegion
+
+	preBootstrapStackName := ops.PreBootstrapStackName(qualifier)
+
+	clients, err := awsapi.New(ctx, profile, primaryRegion)
+	if err != nil {
+		return err
+	}
+
+	exists, err := ops.StackExists(ctx, clients, preBootstrapStackName)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		writeOutputf(opts.Output, "Stack %q is not deployed. Run 'ago infra cdk bootstrap'.\n", preBootstrapStackName)
+		return nil
+	}
+
+	auth, err := deployerAuth(cdkCtx)
+	if err != nil {
+		return err
+	}
+
+	desired := ops.PreBootstrapParameters(qualifier,
+		cdkCtx.SecondaryRegions, cdkCtx.Deployers, cdkCtx.DevDeployers, ciRepository(cdkCtx), auth)
+
+	deployed, err := ops.StackParameters(ctx, clients, preBootstrapStackName)
+	if err != nil {
+		return err
+	}
+
+	services, err := projectServices(cdkCtx)
+	if err != nil {
+		return errors.Wrap(err, "failed to parse services from context")
+	}
+
+	templateChanged, err := preBootstrapTemplateChanged(ctx, clients, preBootstrapStackName, qualifier, services)
+	if err != nil {
+		return err
+	}
+
+	changes := diffParameters(desired, deployed)
+	if len(changes) == 0 && !templateChanged {
+		writeOutputf(opts.Output, "Stack %q is up to date with the CDK context.\n", preBootstrapStackName)
+		return nil
+	}
+
+	writeOutputf(opts.Output, "Pending changes for %q:\n", preBootstrapStackName)
+	for _, c := range changes {
+		writeOutputf(opts.Output, "  %s:\n", c.Key)
+		if len(c.Added) == 0 && len(c.Removed) == 0 {
+			writeOutputf(opts.Output, "    %q -> %q\n", c.Deployed, c.Desired)
+			continue
+		}
+		for _, v := range c.Added {
+			writeOutputf(opts.Output, "    + %s\n", v)
+		}
+		for _, v := range c.Removed {
+			writeOutputf(opts.Output, "    - %s\n", v)
+		}
+	}
+	if templateChanged {
+		writeOutputf(opts.Output, "  Template: differs from the deployed template (services: %s)\n",
+			strings.Join(services, ", "))
+	}
+
+	writeOutputf(opts.Output, "\nRun 'ago infra cdk bootstrap' to apply these changes.\n")
+
+	return nil
+}
+
+// diffParameters compares the desired parameters with the deployed values. List parameters are
+// compared as sets, so reordering a list is not reported as a change. A parameter that the
+// deployed stack does not have yet compares as its default.
+func diffParameters(desired []ops.CFNParameter, deployed map[string]string) []parameterChange {
+	var changes []parameterChange
+	for _, p := range desired {
+		current, ok := deployed[p.Key]
+		if !ok {
+			current = p.Default
+		}
+		if !p.List {
+			if current != p.Value {
+				changes = append(changes, parameterChange{Key: p.Key, Deployed: current, Desired: p.Value})
+			}
+			continue
+		}
+
+		want, have := parseCommaList(p.Value), parseCommaList(current)
+		change := parameterChange{Key: p.Key, Deployed: current, Desired: p.Value}
+		for _, v := range want {
+			if !slices.Contains(have, v) {
+				change.Added = append(change.Added, v)
+			}
+		}
+		for _, v := range have {
+			if !slices.Contains(want, v) {
+				change.Removed = append(change.Removed, v)
+			}
+		}
+		if len(change.Added) > 0 || len(change.Removed) > 0 {
+			changes = append(changes, change)
+		}
+	}
+
+	return changes
+}
+
+// preBootstrapTemplateChanged reports whether the template rendered from the current services
+// differs from the template of the deployed stack.
+func preBootstrapTemplateChanged(
+	ctx context.Context, clients *awsapi.Clients, stackName, qualifier string, services []string,
+) (bool, error) {
+	templatePath, cleanup, err := renderPreBootstrapTemplate(qualifier, services)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to render pre-bootstrap template")
+	}
+	defer cleanup()
+
+	rendered, err := os.ReadFile(templatePath)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to read rendered template")
+	}
+
+	deployed, err := ops.StackTemplate(ctx, clients, stackName)
+	if err != nil {
+		return false, err
+	}
+
+	return strings.TrimSpace(deployed) != strings.TrimSpace(string(rendered)), nil
+}
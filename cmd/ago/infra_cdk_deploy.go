@@ -1,10 +1,18 @@
 package main
 
 import (
+	"cmp"
 	"context"
+	"fmt"
 	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
 
 	"github.com/advdv/ago/cmd/ago/internal/config"
+	"github.com/advdv/ago/cmd/ago/internal/warnings"
+	"github.com/cockroachdb/errors"
 	"github.com/urfave/cli/v3"
 )
 
@@ -13,30 +21,90 @@ func deployCmd() *cli.Command {
 		Name:      "deploy",
 		Usage:     "Deploy CDK stacks",
 		ArgsUsage: "[deployment]",
-		Flags: []cli.Flag{
-			&cli.BoolFlag{
-				Name:  "hotswap",
-				Usage: "Enable CDK hotswap for faster iterations",
-			},
-			&cli.BoolFlag{
-				Name:  "all",
-				Usage: "Deploy all stacks",
-			},
+		Flags:     deployFlags(),
+		Action:    config.RunWithConfig(runDeploy),
+	}
+}
+
+// deployFlags returns the flags shared by the deploy commands.
+func deployFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.BoolFlag{
+			Name:  "hotswap",
+			Usage: "Enable CDK hotswap for faster iterations",
+		},
+		&cli.BoolFlag{
+			Name:  "all",
+			Usage: "Deploy all stacks",
+		},
+		&cli.StringSliceFlag{
+			Name:  "deployments",
+			Usage: "Deploy these deployments concurrently, after the shared stack of each region (e.g. DevAdam,DevEve)",
+		},
+		&cli.BoolFlag{
+			Name:  "all-dev",
+			Usage: "Deploy all Dev deployments concurrently, after the shared stack of each region",
+		},
+		&cli.IntFlag{
+			Name:  "concurrency",
+			Usage: "Maximum number of deployment stacks deployed at the same time with --deployments or --all-dev",
+			Value: defaultDeployConcurrency,
+		},
+		&cli.BoolFlag{
+			Name:  "request-increases",
+			Usage: "File Service Quotas increase requests for quotas the deploy would exceed",
+		},
+		&cli.BoolFlag{
+			Name:  "yes",
+			Usage: "Deploy restricted deployments without showing a cost estimate and asking for confirmation",
+		},
+		&cli.BoolFlag{
+			Name: "allow-protected",
+			Usage: "Allow the deploy to replace or delete resources marked with agcdkutil.Protect " +
+				"(same as --acknowledge protected-resource-change)",
+		},
+		&cli.BoolFlag{
+			Name:  "ignore-window",
+			Usage: "Deploy outside the deploy windows declared in infra/deployments.yaml",
 		},
-		Action: config.RunWithConfig(runDeploy),
 	}
 }
 
 func runDeploy(ctx context.Context, cmd *cli.Command, cfg config.Config) error {
-	return doDeploy(ctx, cfg, cdkCommandOptions{
-		Deployment: cmd.Args().First(),
-		All:        cmd.Bool("all"),
-		Hotswap:    cmd.Bool("hotswap"),
-		Output:     os.Stdout,
+	deployment := cmd.Args().First()
+	return withNotification(ctx, cfg, os.Stdout, "deploy", deployment, func() error {
+		return doDeploy(ctx, cfg, cdkCommandOptions{
+			Deployment:       deployment,
+			Profile:          cmd.String("profile"),
+			All:              cmd.Bool("all"),
+			Deployments:      cmd.StringSlice("deployments"),
+			AllDev:           cmd.Bool("all-dev"),
+			Concurrency:      int(cmd.Int("concurrency")),
+			Hotswap:          cmd.Bool("hotswap"),
+			RequestIncreases: cmd.Bool("request-increases"),
+			Yes:              cmd.Bool("yes"),
+			AllowProtected:   cmd.Bool("allow-protected"),
+			IgnoreWindow:     cmd.Bool("ignore-window"),
+			Acknowledge:      cmd.StringSlice("acknowledge"),
+			Output:           os.Stdout,
+		})
 	})
 }
 
 func doDeploy(ctx context.Context, cfg config.Config, opts cdkCommandOptions) error {
+	if err := requireTools(ctx, cfg, "deploy"); err != nil {
+		return err
+	}
+
+	acknowledge := slices.Clone(opts.Acknowledge)
+	if opts.AllowProtected {
+		acknowledge = append(acknowledge, string(warnings.ProtectedResourceChange))
+	}
+	warn, err := warnings.New(opts.Output, acknowledge)
+	if err != nil {
+		return err
+	}
+
 	cdk, err := loadCDKContext(cfg)
 	if err != nil {
 		return err
@@ -45,29 +113,93 @@ func doDeploy(ctx context.Context, cfg config.Config, opts cdkCommandOptions) er
 	exec := cdk.Exec.WithOutput(opts.Output, opts.Output)
 	cdkExec := cdk.CDKExec.WithOutput(opts.Output, opts.Output)
 
-	username, usernameErr := getCallerUsername(ctx, exec, cdk.Qualifier, cdk.CDKContext)
+	username, usernameErr := resolveCDKCallerUsername(ctx, opts.Profile, cdk.Context)
 
-	deployment, err := resolveDeploymentIdent(opts, cdk.Prefix, cdk.CDKContext, username, usernameErr)
-	if err != nil {
-		return err
+	multi := len(opts.Deployments) > 0 || opts.AllDev
+	if multi && (opts.All || opts.Deployment != "") {
+		return errors.New("--deployments and --all-dev cannot be combined with --all or a deployment argument")
+	}
+
+	var deployment string
+	if !multi {
+		deployment, err = resolveDeploymentIdent(cfg.Inner, opts, cdk.Context, username, usernameErr)
+		if err != nil {
+			return err
+		}
 	}
 
-	profile := resolveProfile(ctx, exec, cdk.CDKContext, cdk.Qualifier, username)
+	profile := resolveCDKProfile(opts.Profile, cdk.Context, username)
 
-	userGroups, err := getUserGroups(ctx, exec, profile, username)
+	userGroups, err := callerGroups(ctx, profile, cdk.Qualifier, username, usernameErr)
 	if err != nil {
 		return err
 	}
 
-	if err := checkDeploymentPermission(deployment, isFullDeployer(userGroups, cdk.Qualifier)); err != nil {
+	fullDeployer := isFullDeployer(userGroups, cdk.Qualifier)
+	if err := checkDeploymentPermission(deployment, fullDeployer); err != nil {
+		return err
+	}
+
+	deployments := []string{deployment}
+	switch {
+	case opts.All:
+		deployments = allowedDeployments(cdk.Deployments, fullDeployer)
+	case multi:
+		deployments, err = selectDeployments(cdk.Deployments,
+			opts.Deployments, opts.AllDev, fullDeployer, username)
+		if err != nil {
+			return err
+		}
+	}
+
+	if !opts.IgnoreWindow {
+		if err := checkDeployWindows(cfg, cdk.Deployments,
+			deployments, time.Now()); err != nil {
+			return err
+		}
+	}
+
+	if !opts.All && !opts.Yes {
+		for _, d := range deployments {
+			if !isRestrictedDeployment(d) {
+				continue
+			}
+			if err := confirmRestrictedDeploy(ctx, cdkExec, cdk, profile, d, userGroups,
+				opts.Output); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := cdk.Require("primary-region"); err != nil {
 		return err
 	}
+	primaryRegion := cdk.PrimaryRegion
+	regions := cdk.Regions()
+	if opts.Region != "" {
+		if !slices.Contains(regions, opts.Region) {
+			return errors.Errorf("region %q is not one of the project's regions: %s",
+				opts.Region, strings.Join(regions, ", "))
+		}
+		regions = []string{opts.Region}
+	}
+	baseDomainName := cdk.BaseDomainName
+	plan := deployQuotaPlan(cdk.Qualifier, regions, deployments, baseDomainName)
+	runQuotaPreflight(ctx, opts.Output, profile, plan, opts.RequestIncreases)
 
-	args := buildCDKArgs(profile, cdk.Qualifier, cdk.Prefix, userGroups)
+	baseArgs := buildCDKArgs(profile, cdk.Qualifier, cdk.Prefix, userGroups)
+	if !opts.All && !multi {
+		baseArgs = append(baseArgs, tenantContextArgs(cdk.Context, deployment)...)
+	}
+	args := slices.Clone(baseArgs)
 
-	if opts.All {
+	switch {
+	case opts.All:
 		args = append(args, "--all", "--require-approval", "never")
-	} else {
+	case opts.DeploymentOnly:
+		// Without --exclusively, CDK also deploys the shared stacks that the deployment depends on.
+		args = append(args, cdk.Qualifier+"*"+deployment, "--exclusively", "--require-approval", "never")
+	default:
 		args = append(args, cdk.Qualifier+"*Shared", cdk.Qualifier+"*"+deployment)
 		args = append(args, "--require-approval", "never")
 	}
@@ -76,5 +208,89 @@ func doDeploy(ctx context.Context, cfg config.Config, opts cdkCommandOptions) er
 		args = append(args, "--hotswap")
 	}
 
-	return runCDKCommand(ctx, cdkExec, "deploy", args)
+	lk := newLocker(cfg, profile, primaryRegion, cdk.Qualifier)
+	env := hookEnv{Command: "deploy", Deployments: deployments, Profile: profile, Qualifier: cdk.Qualifier}
+	if len(deployments) == 1 {
+		env.Deployment = deployments[0]
+	}
+	stacks := deployStacks(cdk.Qualifier, regions, deployments)
+	return withLocks(ctx, lk, opts.Output, "deploy", deploymentLockScopes(deployments), func() error {
+		return withHooks(ctx, cfg, opts.Output, env, func() error {
+			if err := recoverStuckStacks(ctx, opts.Output, warn, profile, stacks); err != nil {
+				return err
+			}
+
+			if !warn.Acknowledged(warnings.ProtectedResourceChange) {
+				if err := checkProtectedResources(ctx, cdkExec, opts.Output, warn, profile, baseArgs, stacks); err != nil {
+					return err
+				}
+			}
+
+			if err := preserveImportedExports(ctx, cfg, cdk, cdkExec, opts.Output, profile, baseArgs,
+				stacks); err != nil {
+				return err
+			}
+
+			before := stackChangeSetIDs(ctx, profile, stacks)
+			prov := deployProvenance{
+				Deployments: deployments,
+				Deployer:    cmp.Or(username, lockOwner()),
+				Profile:     profile,
+				Qualifier:   cdk.Qualifier,
+				Region:      primaryRegion,
+				Regions:     regions,
+				ImageTags:   cdk.ImageTags,
+				Stacks:      stacks,
+				StartedAt:   time.Now(),
+			}
+
+			var err error
+			if opts.Ordered || multi {
+				concurrency := 1
+				if multi {
+					concurrency = opts.Concurrency
+				}
+				err = runDeployRollout(ctx, cdkExec, opts.Output, baseArgs, opts.Hotswap, concurrency,
+					deployRollout(cdk.Qualifier, regions, deployments))
+			} else {
+				err = runCDKCommand(ctx, cdkExec, "deploy", args)
+			}
+			recordDeployProvenance(ctx, cfg, exec, opts.Output, prov, before, err)
+			return err
+		})
+	})
+}
+
+// deploymentsFilePath is the project's per-deployment settings file, see agcdkutil.DeploymentsFile.
+func deploymentsFilePath(cfg config.Config) string {
+	return filepath.Join(cfg.InfraDir(), "deployments.yaml")
+}
+
+// checkDeployWindows fails when one of the deployments declares deploy windows and now is outside
+// all of them. It reads the same infra/deployments.yaml that the CDK app loads at synth.
+func checkDeployWindows(cfg config.Config, known, deployments []string, now time.Time) error {
+	file, err := loadDeploymentsFile(cfg, known)
+	if err != nil {
+		return err
+	}
+
+	for _, d := range deployments {
+		settings := file.Settings(d)
+		if settings.InDeployWindow(now) {
+			continue
+		}
+
+		windows := make([]string, 0, len(settings.DeployWindows))
+		for _, w := range settings.DeployWindows {
+			days := "every day"
+			if len(w.Days) > 0 {
+				days = strings.Join(w.Days, ",")
+			}
+			tz := cmp.Or(w.Timezone, "UTC")
+			windows = append(windows, fmt.Sprintf("%s %s-%s %s", days, w.Start, w.End, tz))
+		}
+		return errors.Errorf("deployment %q is outside its deploy windows (%s)\n\n"+
+			"Deploy anyway with --ignore-window", d, strings.Join(windows, "; "))
+	}
+	return nil
 }
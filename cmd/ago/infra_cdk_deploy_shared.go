@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"io"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/advdv/ago/cmd/ago/internal/cdkcontext"
+	"github.com/advdv/ago/cmd/ago/internal/config"
+	"github.com/advdv/ago/cmd/ago/internal/ops"
+	"github.com/advdv/ago/cmd/ago/internal/warnings"
+	"github.com/cockroachdb/errors"
+	"github.com/urfave/cli/v3"
+)
+
+func deploySharedCmd() *cli.Command {
+	return &cli.Command{
+		Name: "deploy-shared",
+		Usage: "Deploy only the Shared stacks, primary region first, e.g. as the first deploy after " +
+			"bootstrap (with --region, only the Shared stack of that region)",
+		Flags: []cli.Flag{
+			&cli.BoolFlag{
+				Name: "allow-protected",
+				Usage: "Allow the deploy to replace or delete resources marked with agcdkutil.Protect " +
+					"(same as --acknowledge protected-resource-change)",
+			},
+		},
+		Action: config.RunWithConfig(runDeployShared),
+	}
+}
+
+type deploySharedOptions struct {
+	Profile        string
+	Region         string
+	AllowProtected bool
+	Acknowledge    []string
+	Output         io.Writer
+}
+
+func runDeployShared(ctx context.Context, cmd *cli.Command, cfg config.Config) error {
+	return withNotification(ctx, cfg, os.Stdout, "deploy-shared", "", func() error {
+		return doDeployShared(ctx, cfg, deploySharedOptions{
+			Profile:        cmd.String("profile"),
+			Region:         cmd.String("region"),
+			AllowProtected: cmd.Bool("allow-protected"),
+			Acknowledge:    cmd.StringSlice("acknowledge"),
+			Output:         os.Stdout,
+		})
+	})
+}
+
+// doDeployShared deploys the Shared stack of every region, or of a single one. The stacks are
+// deployed one region after another with the primary region first, because the secondary regions
+// may import what the primary region exports. Dev deployers may only deploy their own deployment,
+// so this needs a full deployer or the admin profile. Like deploy, it refuses to replace or delete
+// protected resources unless that is acknowledged.
+func doDeployShared(ctx context.Context, cfg config.Config, opts deploySharedOptions) error {
+	if err := requireTools(ctx, cfg, "deploy-shared"); err != nil {
+		return err
+	}
+
+	acknowledge := slices.Clone(opts.Acknowledge)
+	if opts.AllowProtected {
+		acknowledge = append(acknowledge, string(warnings.ProtectedResourceChange))
+	}
+	warn, err := warnings.New(opts.Output, acknowledge)
+	if err != nil {
+		return err
+	}
+
+	cdk, err := loadCDKContext(cfg)
+	if err != nil {
+		return err
+	}
+
+	cdkExec := cdk.CDKExec.WithOutput(opts.Output, opts.Output)
+
+	username, usernameErr := resolveCDKCallerUsername(ctx, opts.Profile, cdk.Context)
+	profile := resolveCDKProfile(opts.Profile, cdk.Context, username)
+
+	userGroups, err := callerGroups(ctx, profile, cdk.Qualifier, username, usernameErr)
+	if err != nil {
+		return err
+	}
+	if !isFullDeployer(userGroups, cdk.Qualifier) && profile == ops.DeployerProfileName(cdk.Qualifier, username) {
+		return errors.Errorf("deploying the Shared stacks requires full deployer permissions (member of %s) "+
+			"or the admin profile", ops.DeployersGroupName(cdk.Qualifier))
+	}
+
+	regions, err := sharedDeployRegions(cdk.Context, opts.Region)
+	if err != nil {
+		return err
+	}
+
+	baseArgs := buildCDKArgs(profile, cdk.Qualifier, cdk.Prefix, userGroups)
+	lk := newLocker(cfg, profile, cdk.PrimaryRegion, cdk.Qualifier)
+	env := hookEnv{Command: "deploy-shared", Profile: profile, Qualifier: cdk.Qualifier}
+	return withLocks(ctx, lk, opts.Output, "deploy-shared", []string{lockScopeShared}, func() error {
+		return withHooks(ctx, cfg, opts.Output, env, func() error {
+			if !warn.Acknowledged(warnings.ProtectedResourceChange) {
+				if err := checkProtectedResources(ctx, cdkExec, opts.Output, warn, profile, baseArgs,
+					deployStacks(cdk.Qualifier, regions, nil)); err != nil {
+					return err
+				}
+			}
+
+			return runDeployRollout(ctx, cdkExec, opts.Output, baseArgs, false, 1,
+				deployRollout(cdk.Qualifier, regions, nil))
+		})
+	})
+}
+
+// sharedDeployRegions returns the regions whose Shared stack is deployed, primary region first.
+// A region given with the global --region must be one of the project's regions.
+func sharedDeployRegions(cdkCtx *cdkcontext.Context, region string) ([]string, error) {
+	if err := cdkCtx.Require("primary-region"); err != nil {
+		return nil, err
+	}
+
+	regions := cdkCtx.Regions()
+	if region == "" {
+		return regions, nil
+	}
+	if !slices.Contains(regions, region) {
+		return nil, errors.Errorf("region %q is not one of the project's regions: %s",
+			region, strings.Join(regions, ", "))
+	}
+	return []string{region}, nil
+}
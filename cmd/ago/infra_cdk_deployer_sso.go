@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"io"
+	"strings"
+
+	"github.com/advdv/ago/cmd/ago/internal/awsapi"
+	"github.com/advdv/ago/cmd/ago/internal/cdkcontext"
+	"github.com/advdv/ago/cmd/ago/internal/ops"
+	"github.com/cockroachdb/errors"
+	"github.com/iancoleman/strcase"
+)
+
+// ssoSessionDuration is how long a deployer's IAM Identity Center session lasts before
+// 'aws sso login' is needed again.
+const ssoSessionDuration = "PT8H"
+
+// maxPermissionSetNameLength is the longest name IAM Identity Center accepts for a permission set.
+const maxPermissionSetNameLength = 32
+
+// deployerAuth returns how the project's deployers authenticate, as set by the deployer-auth
+// context key. Projects without the key use IAM users.
+func deployerAuth(cdkCtx *cdkcontext.Context) (string, error) {
+	auth := cdkCtx.String("deployer-auth")
+	switch auth {
+	case "":
+		return ops.DeployerAuthIAMUsers, nil
+	case ops.DeployerAuthIAMUsers, ops.DeployerAuthSSO:
+		return auth, nil
+	default:
+		return "", errors.Errorf("invalid %s %q, expected %q or %q", cdkCtx.Key("deployer-auth"), auth,
+			ops.DeployerAuthIAMUsers, ops.DeployerAuthSSO)
+	}
+}
+
+// deployerSSOSettings holds the context that SSO deployer authentication needs: where deployers
+// sign in, and the management account profile that administers IAM Identity Center.
+type deployerSSOSettings struct {
+	StartURL          string
+	Region            string
+	ManagementProfile string
+}
+
+func readDeployerSSOSettings(cdkCtx *cdkcontext.Context) (deployerSSOSettings, error) {
+	var settings deployerSSOSettings
+	for key, value := range map[string]*string{
+		"sso-start-url":      &settings.StartURL,
+		"sso-region":         &settings.Region,
+		"management-profile": &settings.ManagementProfile,
+	} {
+		s := cdkCtx.String(key)
+		if s == "" {
+			return deployerSSOSettings{}, errors.Errorf("%s is %q but context key %q is not set",
+				cdkCtx.Key("deployer-auth"), ops.DeployerAuthSSO, cdkCtx.Key(key))
+		}
+		*value = s
+	}
+	return settings, nil
+}
+
+// deployDeployerSSO creates the IAM Identity Center permission sets of the deployers and assigns
+// them to the project account: the deployers through the deployers group, dev deployers each
+// through their own permission set. The deployers must exist as Identity Center users with their
+// deployer name as user name.
+func deployDeployerSSO(
+	ctx context.Context, output io.Writer, settings deployerSSOSettings,
+	qualifier, accountID string, deployers, devDeployers []string,
+) error {
+	clients, err := awsapi.New(ctx, settings.ManagementProfile, settings.Region)
+	if err != nil {
+		return err
+	}
+
+	instance, err := ops.LookupSSOInstance(ctx, clients)
+	if err != nil {
+		return err
+	}
+
+	data := deployerSSOData{
+		Qualifier:             qualifier,
+		InstanceARN:           instance.InstanceARN,
+		IdentityStoreID:       instance.IdentityStoreID,
+		AccountID:             accountID,
+		DeployerPermissionSet: ops.DeployerPermissionSetName(qualifier),
+		SessionDuration:       ssoSessionDuration,
+	}
+	lookup := func(username string) (ssoDeployer, error) {
+		userID, err := ops.LookupSSOUserID(ctx, clients, instance.IdentityStoreID, username)
+		if err != nil {
+			return ssoDeployer{}, err
+		}
+		return ssoDeployer{LogicalID: strcase.ToCamel(username), Username: username, UserID: userID}, nil
+	}
+
+	for _, username := range deployers {
+		deployer, err := lookup(username)
+		if err != nil {
+			return err
+		}
+		data.Deployers = append(data.Deployers, deployer)
+	}
+	for _, username := range devDeployers {
+		deployer, err := lookup(username)
+		if err != nil {
+			return err
+		}
+		deployer.PermissionSet = ops.DevDeployerPermissionSetName(qualifier, username)
+		if len(deployer.PermissionSet) > maxPermissionSetNameLength {
+			return errors.Errorf("permission set name %q of dev deployer %q exceeds %d characters",
+				deployer.PermissionSet, username, maxPermissionSetNameLength)
+		}
+		deployer.Deployment = "Dev" + username
+		data.DevDeployers = append(data.DevDeployers, deployer)
+	}
+
+	templatePath, cleanup, err := renderDeployerSSOTemplate(data)
+	if err != nil {
+		return errors.Wrap(err, "failed to render deployer SSO template")
+	}
+	defer cleanup()
+
+	writeOutputf(output, "Deploying stack %q to the management account (profile: %s)...\n",
+		ops.DeployerSSOStackName(qualifier), settings.ManagementProfile)
+	return ops.DeployDeployerSSO(ctx, clients, qualifier, templatePath)
+}
+
+// ssoRoleSession returns the permission set and user name of an IAM Identity Center session, whose
+// caller ARN has the form arn:aws:sts::account:assumed-role/AWSReservedSSO_<permission set>_<id>/<user>.
+func ssoRoleSession(arn string) (permissionSet, username string, ok bool) {
+	_, rest, found := strings.Cut(arn, ":assumed-role/AWSReservedSSO_")
+	if !found {
+		return "", "", false
+	}
+	role, username, found := strings.Cut(rest, "/")
+	if !found || username == "" {
+		return "", "", false
+	}
+	idx := strings.LastIndex(role, "_")
+	if idx <= 0 {
+		return "", "", false
+	}
+	return role[:idx], username, true
+}
+
+// ssoCallerGroups maps the permission set of an IAM Identity Center session to the deployer group
+// it stands for: "<qualifier>-deployer" to the deployers, "<qualifier>-dev-<user>" to the dev
+// deployers.
+func ssoCallerGroups(permissionSet string) ([]string, bool) {
+	if qualifier, ok := strings.CutSuffix(permissionSet, "-deployer"); ok && qualifier != "" {
+		return []string{ops.DeployersGroupName(qualifier)}, true
+	}
+	if idx := strings.Index(permissionSet, "-dev-"); idx > 0 {
+		return []string{ops.DevDeployersGroupName(permissionSet[:idx])}, true
+	}
+	return nil, false
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"os"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/advdv/ago/cmd/ago/internal/ops"
+)
+
+func TestDeployerAuth(t *testing.T) {
+	t.Parallel()
+
+	for value, want := range map[any]string{
+		nil:         ops.DeployerAuthIAMUsers,
+		"iam-users": ops.DeployerAuthIAMUsers,
+		"sso":       ops.DeployerAuthSSO,
+	} {
+		values := map[string]any{}
+		if value != nil {
+			values["myapp-deployer-auth"] = value
+		}
+		got, err := deployerAuth(newTestCDKContext(t, values))
+		if err != nil {
+			t.Fatalf("deployer-auth %v: %v", value, err)
+		}
+		if got != want {
+			t.Errorf("deployer-auth %v: expected %q, got %q", value, want, got)
+		}
+	}
+
+	if _, err := deployerAuth(newTestCDKContext(t, map[string]any{"myapp-deployer-auth": "oidc"})); err == nil {
+		t.Error("expected an error for an unknown deployer-auth")
+	}
+}
+
+func TestSSOCallerGroups(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		arn   string
+		want  []string
+		valid bool
+	}{
+		{
+			arn:   "arn:aws:sts::123456789012:assumed-role/AWSReservedSSO_my-app-deployer_0123abcd/adam",
+			want:  []string{ops.DeployersGroupName("my-app")},
+			valid: true,
+		},
+		{
+			arn:   "arn:aws:sts::123456789012:assumed-role/AWSReservedSSO_my-app-dev-bob_0123abcd/bob",
+			want:  []string{ops.DevDeployersGroupName("my-app")},
+			valid: true,
+		},
+		{arn: "arn:aws:sts::123456789012:assumed-role/AWSReservedSSO_ReadOnly_0123abcd/carol"},
+		{arn: "arn:aws:iam::123456789012:user/myapp-adam"},
+	} {
+		permissionSet, _, ok := ssoRoleSession(tc.arn)
+		if !ok {
+			if tc.valid {
+				t.Errorf("%s: expected an SSO session", tc.arn)
+			}
+			continue
+		}
+		groups, ok := ssoCallerGroups(permissionSet)
+		if ok != tc.valid || !slices.Equal(groups, tc.want) {
+			t.Errorf("%s: expected %v (%v), got %v (%v)", tc.arn, tc.want, tc.valid, groups, ok)
+		}
+	}
+}
+
+func TestDeployerSSOTemplate(t *testing.T) {
+	t.Parallel()
+
+	path, cleanup, err := renderDeployerSSOTemplate(deployerSSOData{
+		Qualifier:             "myapp",
+		InstanceARN:           "arn:aws:sso:::instance/ssoins-0123",
+		IdentityStoreID:       "d-0123",
+		AccountID:             "123456789012",
+		DeployerPermissionSet: ops.DeployerPermissionSetName("myapp"),
+		SessionDuration:       ssoSessionDuration,
+		Deployers:             []ssoDeployer{{LogicalID: "Adam", Username: "adam", UserID: "u-adam"}},
+		DevDeployers: []ssoDeployer{{
+			LogicalID: "Bob", Username: "bob", UserID: "u-bob",
+			PermissionSet: ops.DevDeployerPermissionSetName("myapp", "bob"), Deployment: "Devbob",
+		}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cleanup()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"Name: myapp-deployer\n",
+		"DeployerMembershipAdam:",
+		"DevDeployerPermissionSetBob:",
+		"Name: myapp-dev-bob\n",
+		"DevDeployerAssignmentBob:",
+	} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("expected the deployer SSO template to contain %q", want)
+		}
+	}
+}
@@ -3,25 +3,50 @@ package main
 import (
 	"context"
 	"io"
+	"maps"
 	"os"
+	"slices"
+	"strings"
 
+	"github.com/advdv/ago/agcdkutil"
+	"github.com/advdv/ago/cmd/ago/internal/awsapi"
+	"github.com/advdv/ago/cmd/ago/internal/cmdexec"
 	"github.com/advdv/ago/cmd/ago/internal/config"
+	"github.com/advdv/ago/cmd/ago/internal/warnings"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecr"
+	ecrtypes "github.com/aws/aws-sdk-go-v2/service/ecr/types"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/cockroachdb/errors"
 	"github.com/urfave/cli/v3"
 )
 
 func destroyCmd() *cli.Command {
 	return &cli.Command{
-		Name:      "destroy",
-		Usage:     "Destroy CDK stacks",
+		Name: "destroy",
+		Usage: "Destroy the stacks of a deployment, or with --all every deployment and shared stack, in " +
+			"reverse dependency order. The pre-bootstrap and bootstrap stacks are kept",
 		ArgsUsage: "[deployment]",
 		Flags: []cli.Flag{
 			&cli.BoolFlag{
 				Name:  "all",
-				Usage: "Destroy all stacks",
+				Usage: "Destroy the stacks of all deployments and the shared stacks",
+			},
+			&cli.StringFlag{
+				Name:     "confirm",
+				Usage:    "The project's qualifier, to confirm that its stacks and their data are deleted",
+				Required: true,
+			},
+			&cli.BoolFlag{
+				Name: "empty",
+				Usage: "Empty the S3 buckets and ECR repositories that are deleted with their stack, which " +
+					"CloudFormation cannot delete while they hold objects or images",
 			},
 			&cli.BoolFlag{
-				Name:  "force",
-				Usage: "Skip confirmation prompts",
+				Name: "allow-protected",
+				Usage: "Allow destroying stacks with resources marked with agcdkutil.Protect " +
+					"(same as --acknowledge protected-resource-change)",
 			},
 		},
 		Action: config.RunWithConfig(runDestroy),
@@ -29,62 +54,334 @@ func destroyCmd() *cli.Command {
 }
 
 type cdkDestroyOptions struct {
-	Deployment string
-	All        bool
-	Force      bool
-	Output     io.Writer
+	Deployment     string
+	Profile        string
+	All            bool
+	Confirm        string
+	Empty          bool
+	AllowProtected bool
+	Acknowledge    []string
+	Output         io.Writer
 }
 
 func runDestroy(ctx context.Context, cmd *cli.Command, cfg config.Config) error {
 	return doDestroy(ctx, cfg, cdkDestroyOptions{
-		Deployment: cmd.Args().First(),
-		All:        cmd.Bool("all"),
-		Force:      cmd.Bool("force"),
-		Output:     os.Stdout,
+		Deployment:     cmd.Args().First(),
+		Profile:        cmd.String("profile"),
+		All:            cmd.Bool("all"),
+		Confirm:        cmd.String("confirm"),
+		Empty:          cmd.Bool("empty"),
+		AllowProtected: cmd.Bool("allow-protected"),
+		Acknowledge:    cmd.StringSlice("acknowledge"),
+		Output:         os.Stdout,
 	})
 }
 
 func doDestroy(ctx context.Context, cfg config.Config, opts cdkDestroyOptions) error {
+	if err := requireTools(ctx, cfg, "destroy"); err != nil {
+		return err
+	}
+
+	acknowledge := slices.Clone(opts.Acknowledge)
+	if opts.AllowProtected {
+		acknowledge = append(acknowledge, string(warnings.ProtectedResourceChange))
+	}
+	warn, err := warnings.New(opts.Output, acknowledge)
+	if err != nil {
+		return err
+	}
+
 	cdk, err := loadCDKContext(cfg)
 	if err != nil {
 		return err
 	}
 
-	exec := cdk.Exec.WithOutput(opts.Output, opts.Output)
+	if opts.Confirm != cdk.Qualifier {
+		return errors.Errorf("destroy deletes the stacks and the data they hold, "+
+			"rerun with --confirm %s to proceed", cdk.Qualifier)
+	}
+
 	cdkExec := cdk.CDKExec.WithOutput(opts.Output, opts.Output)
 
-	username, usernameErr := getCallerUsername(ctx, exec, cdk.Qualifier, cdk.CDKContext)
+	username, usernameErr := resolveCDKCallerUsername(ctx, opts.Profile, cdk.Context)
 
-	deployment, err := resolveDeploymentIdent(cdkCommandOptions{
+	deployment, err := resolveDeploymentIdent(cfg.Inner, cdkCommandOptions{
 		Deployment: opts.Deployment,
 		All:        opts.All,
-	}, cdk.Prefix, cdk.CDKContext, username, usernameErr)
+	}, cdk.Context, username, usernameErr)
 	if err != nil {
 		return err
 	}
 
-	profile := resolveProfile(ctx, exec, cdk.CDKContext, cdk.Qualifier, username)
+	profile := resolveCDKProfile(opts.Profile, cdk.Context, username)
 
-	userGroups, err := getUserGroups(ctx, exec, profile, username)
+	userGroups, err := callerGroups(ctx, profile, cdk.Qualifier, username, usernameErr)
 	if err != nil {
 		return err
 	}
 
-	if err := checkDeploymentPermission(deployment, isFullDeployer(userGroups, cdk.Qualifier)); err != nil {
+	fullDeployer := isFullDeployer(userGroups, cdk.Qualifier)
+	if opts.All && !fullDeployer {
+		return errors.New("destroying all stacks requires full deployer permissions (member of deployers group)")
+	}
+	if err := checkDeploymentPermission(deployment, fullDeployer); err != nil {
 		return err
 	}
 
-	args := buildCDKArgs(profile, cdk.Qualifier, cdk.Prefix, userGroups)
+	if err := cdk.Require("primary-region"); err != nil {
+		return err
+	}
+	primaryRegion := cdk.PrimaryRegion
+	regions := cdk.Regions()
 
+	deployments := []string{deployment}
+	scopes := deploymentLockScopes(deployments)
 	if opts.All {
-		args = append(args, "--all")
-	} else {
-		args = append(args, cdk.Qualifier+"*Shared", cdk.Qualifier+"*"+deployment)
+		deployments = cdk.Deployments
+		scopes = append(deploymentLockScopes(deployments), lockScopeShared)
 	}
+	stages := destroyStages(cdk.Qualifier, regions, deployments, opts.All)
+
+	synthArgs := buildCDKArgs(profile, cdk.Qualifier, cdk.Prefix, userGroups)
+	lk := newLocker(cfg, profile, primaryRegion, cdk.Qualifier)
 
-	if opts.Force {
-		args = append(args, "--force")
+	env := hookEnv{Command: "destroy", Deployments: deployments, Profile: profile, Qualifier: cdk.Qualifier}
+	if len(deployments) == 1 {
+		env.Deployment = deployments[0]
 	}
+	return withLocks(ctx, lk, opts.Output, "destroy", scopes, func() error {
+		return withHooks(ctx, cfg, opts.Output, env, func() error {
+			if err := prepareDestroy(ctx, opts.Output, warn, profile, stages, opts.Empty); err != nil {
+				return err
+			}
+
+			assemblyDir, err := synthAssembly(ctx, cdk, cdkExec, opts.Output, synthArgs)
+			if err != nil {
+				return err
+			}
+			return runDestroyStages(ctx, cdkExec, opts.Output, synthArgs, assemblyDir, stages)
+		})
+	})
+}
+
+// destroyStages orders the stacks of a destroy into stages, the reverse of the order deploy
+// creates them in: the deployment stacks before the shared stacks they import from, and within
+// each, the secondary regions before the primary region whose parameters they read. The shared
+// stacks are only destroyed with all deployments.
+func destroyStages(qualifier string, regions, deployments []string, shared bool) []deployStage {
+	reversed := slices.Clone(regions)
+	slices.Reverse(reversed)
 
-	return runCDKCommand(ctx, cdkExec, "destroy", args)
+	var stages []deployStage
+	for _, region := range reversed {
+		regionIdent := agcdkutil.RegionIdentFor(region)
+		stacks := make([]string, 0, len(deployments))
+		for _, deployment := range slices.Backward(deployments) {
+			stacks = append(stacks, agcdkutil.DeploymentStackName(qualifier, regionIdent, deployment))
+		}
+		stages = append(stages, deployStage{Region: region, Stacks: stacks})
+	}
+	if !shared {
+		return stages
+	}
+	for _, region := range reversed {
+		stages = append(stages, deployStage{
+			Region: region,
+			Stacks: []string{agcdkutil.SharedStackName(qualifier, agcdkutil.RegionIdentFor(region))},
+		})
+	}
+	return stages
+}
+
+// prepareDestroy refuses to destroy stacks that hold protected resources unless that was
+// acknowledged, and with empty empties the buckets and repositories that would block the
+// deletion. Everything is checked before the first stack is deleted, so a refusal leaves the
+// project intact.
+func prepareDestroy(
+	ctx context.Context, output io.Writer, warn *warnings.Reporter, profile string, stages []deployStage, empty bool,
+) error {
+	templates := map[string]map[string]any{}
+	var protected []string
+	for _, stage := range stages {
+		clients, err := awsapi.New(ctx, profile, stage.Region)
+		if err != nil {
+			return err
+		}
+		for _, stack := range stage.Stacks {
+			template, err := getDeployedTemplate(ctx, clients, stack)
+			if err != nil {
+				return err
+			}
+			templates[stack] = template
+
+			reasons := protectedResources(template)
+			for _, logicalID := range slices.Sorted(maps.Keys(reasons)) {
+				protected = append(protected, stack+"/"+logicalID+": "+reasons[logicalID])
+			}
+		}
+	}
+
+	if len(protected) > 0 {
+		if err := warn.Require(warnings.Warning{
+			Code:     warnings.ProtectedResourceChange,
+			Severity: warnings.Danger,
+			Summary:  "Destroy deletes protected resources",
+			Detail:   strings.Join(protected, "\n"),
+		}); err != nil {
+			return err
+		}
+	}
+
+	if !empty {
+		return nil
+	}
+	for _, stage := range stages {
+		clients, err := awsapi.New(ctx, profile, stage.Region)
+		if err != nil {
+			return err
+		}
+		for _, stack := range stage.Stacks {
+			blocking := blockingResources(templates[stack])
+			if len(blocking) == 0 {
+				continue
+			}
+			if err := emptyBlockingResources(ctx, clients, output, stack, blocking); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// blockingResources returns the logical IDs and types of the S3 buckets and ECR repositories in
+// a deployed template that CloudFormation deletes with the stack, and which it cannot delete
+// while they are not empty. Resources that are retained or snapshotted are left alone, so their
+// contents survive the destroy.
+func blockingResources(template map[string]any) map[string]string {
+	blocking := map[string]string{}
+	resources, _ := template["Resources"].(map[string]any)
+	for logicalID, resource := range resources {
+		r, _ := resource.(map[string]any)
+		resourceType, _ := r["Type"].(string)
+		if resourceType != "AWS::S3::Bucket" && resourceType != "AWS::ECR::Repository" {
+			continue
+		}
+		if policy, ok := r["DeletionPolicy"].(string); ok && policy != "Delete" {
+			continue
+		}
+		blocking[logicalID] = resourceType
+	}
+	return blocking
+}
+
+// emptyBlockingResources empties the blocking buckets and repositories of a deployed stack.
+func emptyBlockingResources(
+	ctx context.Context, clients *awsapi.Clients, output io.Writer, stackName string, blocking map[string]string,
+) error {
+	resources, err := listStackResources(ctx, clients, stackName)
+	if err != nil {
+		return err
+	}
+
+	for _, r := range resources {
+		if _, ok := blocking[r.LogicalResourceID]; !ok || r.PhysicalResourceID == "" {
+			continue
+		}
+
+		switch r.ResourceType {
+		case "AWS::S3::Bucket":
+			writeOutputf(output, "Emptying bucket %s...\n", r.PhysicalResourceID)
+			err = emptyBucket(ctx, clients, r.PhysicalResourceID)
+		case "AWS::ECR::Repository":
+			writeOutputf(output, "Deleting the images in repository %s...\n", r.PhysicalResourceID)
+			err = emptyRepository(ctx, clients, r.PhysicalResourceID)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// emptyBucket deletes every object version and delete marker of a bucket, a page of at most a
+// thousand at a time, which is the most that a single DeleteObjects call takes.
+func emptyBucket(ctx context.Context, clients *awsapi.Clients, bucket string) error {
+	pages := s3.NewListObjectVersionsPaginator(clients.S3, &s3.ListObjectVersionsInput{
+		Bucket: aws.String(bucket),
+	})
+	for pages.HasMorePages() {
+		page, err := pages.NextPage(ctx)
+		if err != nil {
+			return errors.Wrapf(err, "failed to list the objects in bucket %q", bucket)
+		}
+
+		objects := make([]s3types.ObjectIdentifier, 0, len(page.Versions)+len(page.DeleteMarkers))
+		for _, v := range page.Versions {
+			objects = append(objects, s3types.ObjectIdentifier{Key: v.Key, VersionId: v.VersionId})
+		}
+		for _, m := range page.DeleteMarkers {
+			objects = append(objects, s3types.ObjectIdentifier{Key: m.Key, VersionId: m.VersionId})
+		}
+		if len(objects) == 0 {
+			continue
+		}
+
+		out, err := clients.S3.DeleteObjects(ctx, &s3.DeleteObjectsInput{
+			Bucket: aws.String(bucket),
+			Delete: &s3types.Delete{Objects: objects, Quiet: aws.Bool(true)},
+		})
+		if err != nil {
+			return errors.Wrapf(err, "failed to delete the objects in bucket %q", bucket)
+		}
+		if len(out.Errors) > 0 {
+			return errors.Errorf("failed to delete %s from bucket %q: %s", aws.ToString(out.Errors[0].Key), bucket,
+				aws.ToString(out.Errors[0].Message))
+		}
+	}
+	return nil
+}
+
+// emptyRepository deletes every image of a repository, a hundred at a time, which is the most
+// that a single BatchDeleteImage call takes.
+func emptyRepository(ctx context.Context, clients *awsapi.Clients, repository string) error {
+	var imageIDs []ecrtypes.ImageIdentifier
+	pages := ecr.NewListImagesPaginator(clients.ECR, &ecr.ListImagesInput{RepositoryName: aws.String(repository)})
+	for pages.HasMorePages() {
+		page, err := pages.NextPage(ctx)
+		if err != nil {
+			return errors.Wrapf(err, "failed to list the images in repository %q", repository)
+		}
+		imageIDs = append(imageIDs, page.ImageIds...)
+	}
+
+	for batch := range slices.Chunk(imageIDs, batchDeleteImageLimit) {
+		if _, err := clients.ECR.BatchDeleteImage(ctx, &ecr.BatchDeleteImageInput{
+			RepositoryName: aws.String(repository),
+			ImageIds:       batch,
+		}); err != nil {
+			return errors.Wrapf(err, "failed to delete the images in repository %q", repository)
+		}
+	}
+	return nil
+}
+
+// runDestroyStages destroys the stages one after another from a synthesized assembly, and stops
+// at the first that fails, so no stack is deleted before the stacks that depend on it.
+func runDestroyStages(
+	ctx context.Context, cdkExec cmdexec.Executor, output io.Writer, baseArgs []string, assemblyDir string,
+	stages []deployStage,
+) error {
+	for i, stage := range stages {
+		writeOutputf(output, "\n[%d/%d] Destroying %s in %s...\n", i+1, len(stages),
+			strings.Join(stage.Stacks, ", "), stage.Region)
+
+		args := slices.Clone(baseArgs)
+		args = append(args, stage.Stacks...)
+		args = append(args, "--app", assemblyDir, "--exclusively", "--force")
+		if err := runCDKCommand(ctx, cdkExec, "destroy", args); err != nil {
+			return errors.Wrapf(err, "destroy of %s in %s failed, later stages were not destroyed",
+				strings.Join(stage.Stacks, ", "), stage.Region)
+		}
+	}
+	return nil
 }
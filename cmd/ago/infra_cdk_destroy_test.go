@@ -0,0 +1,54 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDestroyStages(t *testing.T) {
+	t.Parallel()
+
+	regions := []string{"eu-central-1", "us-east-1"}
+
+	got := destroyStages("myapp", regions, []string{"Stag", "Prod"}, true)
+	want := []deployStage{
+		{Region: "us-east-1", Stacks: []string{"myappUse1Prod", "myappUse1Stag"}},
+		{Region: "eu-central-1", Stacks: []string{"myappEuc1Prod", "myappEuc1Stag"}},
+		{Region: "us-east-1", Stacks: []string{"myappUse1Shared"}},
+		{Region: "eu-central-1", Stacks: []string{"myappEuc1Shared"}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("destroyStages(all) = %v, want %v", got, want)
+	}
+
+	got = destroyStages("myapp", regions, []string{"DevAlice"}, false)
+	want = []deployStage{
+		{Region: "us-east-1", Stacks: []string{"myappUse1DevAlice"}},
+		{Region: "eu-central-1", Stacks: []string{"myappEuc1DevAlice"}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("destroyStages(DevAlice) = %v, want %v", got, want)
+	}
+}
+
+func TestBlockingResources(t *testing.T) {
+	t.Parallel()
+
+	template := map[string]any{"Resources": map[string]any{
+		"Uploads":  map[string]any{"Type": "AWS::S3::Bucket", "DeletionPolicy": "Delete"},
+		"Assets":   map[string]any{"Type": "AWS::S3::Bucket"},
+		"Archive":  map[string]any{"Type": "AWS::S3::Bucket", "DeletionPolicy": "Retain"},
+		"Images":   map[string]any{"Type": "AWS::ECR::Repository", "DeletionPolicy": "Delete"},
+		"Snapshot": map[string]any{"Type": "AWS::RDS::DBCluster", "DeletionPolicy": "Snapshot"},
+		"Queue":    map[string]any{"Type": "AWS::SQS::Queue"},
+	}}
+
+	want := map[string]string{
+		"Uploads": "AWS::S3::Bucket",
+		"Assets":  "AWS::S3::Bucket",
+		"Images":  "AWS::ECR::Repository",
+	}
+	if got := blockingResources(template); !reflect.DeepEqual(got, want) {
+		t.Errorf("blockingResources() = %v, want %v", got, want)
+	}
+}
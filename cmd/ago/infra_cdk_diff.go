@@ -3,8 +3,10 @@ package main
 import (
 	"context"
 	"os"
+	"slices"
 
 	"github.com/advdv/ago/cmd/ago/internal/config"
+	"github.com/advdv/ago/cmd/ago/internal/warnings"
 	"github.com/urfave/cli/v3"
 )
 
@@ -25,36 +27,50 @@ func diffCmd() *cli.Command {
 
 func runDiff(ctx context.Context, cmd *cli.Command, cfg config.Config) error {
 	return doDiff(ctx, cfg, cdkCommandOptions{
-		Deployment: cmd.Args().First(),
-		All:        cmd.Bool("all"),
-		Output:     os.Stdout,
+		Deployment:  cmd.Args().First(),
+		Profile:     cmd.String("profile"),
+		All:         cmd.Bool("all"),
+		Acknowledge: cmd.StringSlice("acknowledge"),
+		Output:      os.Stdout,
 	})
 }
 
 func doDiff(ctx context.Context, cfg config.Config, opts cdkCommandOptions) error {
+	if err := requireTools(ctx, cfg, "diff"); err != nil {
+		return err
+	}
+
+	warn, err := warnings.New(opts.Output, opts.Acknowledge)
+	if err != nil {
+		return err
+	}
+
 	cdk, err := loadCDKContext(cfg)
 	if err != nil {
 		return err
 	}
 
-	exec := cdk.Exec.WithOutput(opts.Output, opts.Output)
 	cdkExec := cdk.CDKExec.WithOutput(opts.Output, opts.Output)
 
-	username, usernameErr := getCallerUsername(ctx, exec, cdk.Qualifier, cdk.CDKContext)
+	username, usernameErr := resolveCDKCallerUsername(ctx, opts.Profile, cdk.Context)
 
-	deployment, err := resolveDeploymentIdent(opts, cdk.Prefix, cdk.CDKContext, username, usernameErr)
+	deployment, err := resolveDeploymentIdent(cfg.Inner, opts, cdk.Context, username, usernameErr)
 	if err != nil {
 		return err
 	}
 
-	profile := resolveProfile(ctx, exec, cdk.CDKContext, cdk.Qualifier, username)
+	profile := resolveCDKProfile(opts.Profile, cdk.Context, username)
 
-	userGroups, err := getUserGroups(ctx, exec, profile, username)
+	userGroups, err := callerGroups(ctx, profile, cdk.Qualifier, username, usernameErr)
 	if err != nil {
 		return err
 	}
 
-	args := buildCDKArgs(profile, cdk.Qualifier, cdk.Prefix, userGroups)
+	synthArgs := buildCDKArgs(profile, cdk.Qualifier, cdk.Prefix, userGroups)
+	if !opts.All {
+		synthArgs = append(synthArgs, tenantContextArgs(cdk.Context, deployment)...)
+	}
+	args := slices.Clone(synthArgs)
 
 	if opts.All {
 		args = append(args, "--all")
@@ -62,5 +78,18 @@ func doDiff(ctx context.Context, cfg config.Config, opts cdkCommandOptions) erro
 		args = append(args, cdk.Qualifier+"*Shared", cdk.Qualifier+"*"+deployment)
 	}
 
-	return runCDKCommand(ctx, cdkExec, "diff", args)
+	env := hookEnv{
+		Command: "diff", Deployment: deployment, Deployments: []string{deployment}, Profile: profile,
+		Qualifier: cdk.Qualifier,
+	}
+	return withHooks(ctx, cfg, opts.Output, env, func() error {
+		assemblyDir, err := synthAssembly(ctx, cdk, cdkExec, opts.Output, synthArgs)
+		if err != nil {
+			return err
+		}
+		if err := checkStackBudgets(cfg, cdk, warn, assemblyDir); err != nil {
+			return err
+		}
+		return runCDKCommand(ctx, cdkExec, "diff", append(args, "--app", assemblyDir))
+	})
 }
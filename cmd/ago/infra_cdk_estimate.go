@@ -0,0 +1,320 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/advdv/ago/agcdkutil"
+	"github.com/advdv/ago/cmd/ago/internal/awsapi"
+	"github.com/advdv/ago/cmd/ago/internal/cmdexec"
+	"github.com/advdv/ago/cmd/ago/internal/config"
+	"github.com/advdv/ago/cmd/ago/internal/ops"
+	"github.com/charmbracelet/huh"
+	"github.com/cockroachdb/errors"
+	"github.com/urfave/cli/v3"
+)
+
+// resourceMonthlyCost is the approximate fixed monthly cost in USD of resource types that are
+// billed for merely existing, based on us-east-1 on-demand pricing. Usage-based resources such as
+// Lambda functions, DynamoDB tables and S3 buckets are not listed: their cost depends on traffic,
+// which a template does not describe.
+var resourceMonthlyCost = map[string]float64{
+	"AWS::CertificateManager::PrivateCertificate": 0.75,
+	"AWS::CloudWatch::Alarm":                      0.10,
+	"AWS::EC2::EIP":                               3.65,
+	"AWS::EC2::NatGateway":                        32.85,
+	"AWS::EC2::TransitGatewayAttachment":          36.50,
+	"AWS::EC2::VPCEndpoint":                       7.30,
+	"AWS::EKS::Cluster":                           73.00,
+	"AWS::ElastiCache::CacheCluster":              12.41,
+	"AWS::ElasticLoadBalancing::LoadBalancer":     18.25,
+	"AWS::ElasticLoadBalancingV2::LoadBalancer":   16.43,
+	"AWS::KMS::Key":                               1.00,
+	"AWS::NetworkFirewall::Firewall":              288.00,
+	"AWS::Route53::HealthCheck":                   0.50,
+	"AWS::Route53::HostedZone":                    0.50,
+	"AWS::SecretsManager::Secret":                 0.40,
+	"AWS::WAFv2::WebACL":                          5.00,
+}
+
+func estimateCmd() *cli.Command {
+	return &cli.Command{
+		Name:      "estimate",
+		Usage:     "Estimate the monthly cost delta of deploying the synthesized stacks of a deployment",
+		ArgsUsage: "[deployment]",
+		Action:    config.RunWithConfig(runEstimate),
+	}
+}
+
+type cdkEstimateOptions struct {
+	Deployment string
+	Profile    string
+	Output     io.Writer
+	ErrOut     io.Writer
+}
+
+func runEstimate(ctx context.Context, cmd *cli.Command, cfg config.Config) error {
+	return doEstimate(ctx, cfg, cdkEstimateOptions{
+		Deployment: cmd.Args().First(),
+		Profile:    cmd.String("profile"),
+		Output:     os.Stdout,
+		ErrOut:     os.Stderr,
+	})
+}
+
+func doEstimate(ctx context.Context, cfg config.Config, opts cdkEstimateOptions) error {
+	cdk, err := loadCDKContext(cfg)
+	if err != nil {
+		return err
+	}
+
+	cdkExec := cdk.CDKExec.WithOutput(opts.ErrOut, opts.ErrOut)
+
+	username, usernameErr := resolveCDKCallerUsername(ctx, opts.Profile, cdk.Context)
+
+	deployment, err := resolveDeploymentIdent(cfg.Inner, cdkCommandOptions{Deployment: opts.Deployment},
+		cdk.Context, username, usernameErr)
+	if err != nil {
+		return err
+	}
+
+	profile := resolveCDKProfile(opts.Profile, cdk.Context, username)
+
+	userGroups, err := getUserGroups(ctx, profile, username)
+	if err != nil {
+		return err
+	}
+
+	estimate, err := estimateDeployment(ctx, cdkExec, cdk, profile, deployment, userGroups)
+	if err != nil {
+		return err
+	}
+
+	printCostEstimate(opts.Output, deployment, estimate)
+
+	return nil
+}
+
+// resourceTypeChange is the change in the number of resources of a type across the stacks of a
+// deployment, with the monthly cost of that change.
+type resourceTypeChange struct {
+	Type   string
+	Delta  int
+	Cost   float64
+	Priced bool
+}
+
+// costEstimate is the outcome of comparing synthesized templates with the deployed ones.
+type costEstimate struct {
+	Changes      []resourceTypeChange
+	MonthlyDelta float64
+}
+
+// estimateDeployment synthesizes the shared and deployment stacks and compares their resources
+// with the deployed templates in every region of the deployment.
+func estimateDeployment(
+	ctx context.Context, cdkExec cmdexec.Executor, cdk *cdkContext,
+	profile, deployment string, userGroups []string,
+) (costEstimate, error) {
+	if err := cdk.Require("primary-region"); err != nil {
+		return costEstimate{}, err
+	}
+	regions := cdk.Regions()
+
+	outDir, err := synthAssembly(ctx, cdk, cdkExec, io.Discard,
+		buildCDKArgs(profile, cdk.Qualifier, cdk.Prefix, userGroups))
+	if err != nil {
+		return costEstimate{}, err
+	}
+
+	var desired, deployed []map[string]any
+	for _, region := range regions {
+		clients, err := awsapi.New(ctx, profile, region)
+		if err != nil {
+			return costEstimate{}, err
+		}
+		regionIdent := agcdkutil.RegionIdentFor(region)
+		for _, stackName := range []string{
+			agcdkutil.SharedStackName(cdk.Qualifier, regionIdent),
+			agcdkutil.DeploymentStackName(cdk.Qualifier, regionIdent, deployment),
+		} {
+			synthesized, err := readSynthesizedTemplate(outDir, stackName)
+			if err != nil {
+				return costEstimate{}, err
+			}
+			current, err := getDeployedTemplate(ctx, clients, stackName)
+			if err != nil {
+				return costEstimate{}, err
+			}
+			desired = append(desired, synthesized)
+			deployed = append(deployed, current)
+		}
+	}
+
+	return estimateCostDelta(deployed, desired), nil
+}
+
+// readSynthesizedTemplate finds the template of a stack in a cloud assembly, including nested
+// assemblies of stages. A stack that was not synthesized yields an empty template.
+func readSynthesizedTemplate(outDir, stackName string) (map[string]any, error) {
+	var found string
+	err := filepath.WalkDir(outDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() && d.Name() == stackName+".template.json" {
+			found = path
+			return fs.SkipAll
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to search synthesized templates")
+	}
+	if found == "" {
+		return map[string]any{}, nil
+	}
+
+	data, err := os.ReadFile(found)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read template of stack %q", stackName)
+	}
+
+	var template map[string]any
+	if err := json.Unmarshal(data, &template); err != nil {
+		return nil, errors.Wrapf(err, "failed to parse template of stack %q", stackName)
+	}
+
+	return template, nil
+}
+
+// getDeployedTemplate returns the template of a deployed stack, or an empty template if the
+// stack does not exist yet.
+func getDeployedTemplate(ctx context.Context, clients *awsapi.Clients, stackName string) (map[string]any, error) {
+	body, err := ops.StackTemplate(ctx, clients, stackName)
+	if err != nil {
+		return nil, err
+	}
+	if body == "" {
+		return map[string]any{}, nil
+	}
+
+	var template map[string]any
+	if err := json.Unmarshal([]byte(body), &template); err != nil {
+		return nil, errors.Wrapf(err, "failed to parse deployed template of stack %q", stackName)
+	}
+
+	return template, nil
+}
+
+// estimateCostDelta compares the resource types of the deployed and desired templates. Only the
+// number of resources per type is considered, so changed properties such as an instance size do
+// not affect the estimate.
+func estimateCostDelta(deployed, desired []map[string]any) costEstimate {
+	counts := map[string]int{}
+	for _, template := range deployed {
+		for _, typ := range templateResourceTypes(template) {
+			counts[typ]--
+		}
+	}
+	for _, template := range desired {
+		for _, typ := range templateResourceTypes(template) {
+			counts[typ]++
+		}
+	}
+
+	var estimate costEstimate
+	for typ, delta := range counts {
+		if delta == 0 {
+			continue
+		}
+		price, priced := resourceMonthlyCost[typ]
+		change := resourceTypeChange{Type: typ, Delta: delta, Cost: float64(delta) * price, Priced: priced}
+		estimate.Changes = append(estimate.Changes, change)
+		estimate.MonthlyDelta += change.Cost
+	}
+	slices.SortFunc(estimate.Changes, func(a, b resourceTypeChange) int { return strings.Compare(a.Type, b.Type) })
+
+	return estimate
+}
+
+func templateResourceTypes(template map[string]any) []string {
+	resources, _ := template["Resources"].(map[string]any)
+	types := make([]string, 0, len(resources))
+	for _, resource := range resources {
+		r, _ := resource.(map[string]any)
+		if typ, ok := r["Type"].(string); ok {
+			types = append(types, typ)
+		}
+	}
+	return types
+}
+
+// confirmRestrictedDeploy prints the cost estimate of a deployment and asks for confirmation.
+// Without a terminal to ask on, such as in CI, the deploy continues after printing the estimate.
+func confirmRestrictedDeploy(
+	ctx context.Context, cdkExec cmdexec.Executor, cdk *cdkContext,
+	profile, deployment string, userGroups []string, output io.Writer,
+) error {
+	writeOutputf(output, "Estimating the cost of deploying %s...\n", deployment)
+	estimate, err := estimateDeployment(ctx, cdkExec, cdk, profile, deployment, userGroups)
+	if err != nil {
+		writeOutputf(output, "Warning: could not estimate costs: %v\n", err)
+	} else {
+		printCostEstimate(output, deployment, estimate)
+	}
+
+	if !isTerminal(os.Stdin) {
+		return nil
+	}
+
+	confirmed, err := confirmPrompt("Deploy " + deployment + "?")
+	if err != nil {
+		return err
+	}
+	if !confirmed {
+		return errors.Errorf("deploy of %s cancelled", deployment)
+	}
+
+	return nil
+}
+
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// confirmPrompt asks a yes/no question on the terminal.
+func confirmPrompt(title string) (bool, error) {
+	var confirmed bool
+	if err := huh.NewConfirm().
+		Title(title).
+		Value(&confirmed).
+		Run(); err != nil {
+		return false, errors.Wrap(err, "failed to ask for confirmation")
+	}
+	return confirmed, nil
+}
+
+func printCostEstimate(w io.Writer, deployment string, estimate costEstimate) {
+	writeOutputf(w, "Estimated cost change for deployment %s:\n", deployment)
+	if len(estimate.Changes) == 0 {
+		writeOutputf(w, "  No resources added or removed.\n")
+		return
+	}
+
+	for _, change := range estimate.Changes {
+		if change.Priced {
+			writeOutputf(w, "  %+4d %-50s %+10.2f USD/month\n", change.Delta, change.Type, change.Cost)
+		} else {
+			writeOutputf(w, "  %+4d %-50s %10s\n", change.Delta, change.Type, "usage-based")
+		}
+	}
+	writeOutputf(w, "  Approximate monthly delta: %+.2f USD (excluding usage-based costs)\n", estimate.MonthlyDelta)
+}
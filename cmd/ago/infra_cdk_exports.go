@@ -0,0 +1,179 @@
+package main
+
+import (
+	"context"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/advdv/ago/agcdkutil"
+	"github.com/advdv/ago/cmd/ago/internal/awsapi"
+	"github.com/advdv/ago/cmd/ago/internal/cdkcontext"
+	"github.com/advdv/ago/cmd/ago/internal/cmdexec"
+	"github.com/advdv/ago/cmd/ago/internal/config"
+	"github.com/advdv/ago/cmd/ago/internal/ops"
+)
+
+// preserveImportedExports keeps the exports alive that the deploy would delete while other stacks
+// still import them. CloudFormation only notices this half-way through the deploy, after the
+// stacks before it were updated. The deployed values of these exports are recorded in
+// cdk.context.json, where agcdkutil.PreserveExports adds them back to the stacks. Exports that
+// are no longer imported are dropped from the context, so the deploy deletes them.
+//
+// The exports of each region are listed once. The app is only synthesized when the stacks export
+// anything, and importers are only looked up for the exports a stack loses.
+func preserveImportedExports(
+	ctx context.Context, cfg config.Config, cdk *cdkContext, cdkExec cmdexec.Executor, output io.Writer,
+	profile string, cdkArgs []string, stacks []stackRef,
+) error {
+	clients := map[string]*awsapi.Clients{}
+	deployed := map[string]map[string]string{}
+	for _, stack := range stacks {
+		if _, ok := clients[stack.Region]; ok {
+			continue
+		}
+
+		c, err := awsapi.New(ctx, profile, stack.Region)
+		if err != nil {
+			return err
+		}
+		clients[stack.Region] = c
+
+		exports, err := ops.RegionExports(ctx, c)
+		if err != nil {
+			return err
+		}
+		maps.Copy(deployed, exports)
+	}
+
+	preserved, _ := cdk.Values[cdk.Prefix+agcdkutil.PreservedExportsContextKey].(map[string]any)
+	if !slices.ContainsFunc(stacks, func(s stackRef) bool { return len(deployed[s.Name]) > 0 }) {
+		return writePreservedExports(cfg, preserved, nil, stacks)
+	}
+
+	outDir, err := synthAssembly(ctx, cdk, cdkExec, output, cdkArgs)
+	if err != nil {
+		return err
+	}
+
+	updated := map[string]map[string]string{}
+	for _, stack := range stacks {
+		if len(deployed[stack.Name]) == 0 {
+			continue
+		}
+
+		synthesized, err := readSynthesizedTemplate(outDir, stack.Name)
+		if err != nil {
+			return err
+		}
+
+		recorded, _ := preserved[stack.Name].(map[string]any)
+		for _, name := range removedExports(deployed[stack.Name], synthesized) {
+			importers, err := ops.ExportImporters(ctx, clients[stack.Region], name)
+			if err != nil {
+				return err
+			}
+			if len(importers) == 0 {
+				continue
+			}
+
+			if updated[stack.Name] == nil {
+				updated[stack.Name] = map[string]string{}
+			}
+			updated[stack.Name][name] = deployed[stack.Name][name]
+
+			if _, ok := recorded[name]; !ok {
+				writeOutputf(output, "Preserving export %s of %s, still imported by %s\n",
+					name, stack.Name, strings.Join(importers, ", "))
+			}
+		}
+
+		for name := range recorded {
+			if _, ok := updated[stack.Name][name]; !ok {
+				writeOutputf(output, "Releasing export %s of %s, no longer imported\n", name, stack.Name)
+			}
+		}
+	}
+
+	return writePreservedExports(cfg, preserved, updated, stacks)
+}
+
+// writePreservedExports replaces the preserved exports of the stacks in cdk.context.json when
+// they differ from those recorded.
+func writePreservedExports(
+	cfg config.Config, preserved map[string]any, updated map[string]map[string]string, stacks []stackRef,
+) error {
+	if !preservedExportsChanged(preserved, updated, stacks) {
+		return nil
+	}
+
+	return cdkcontext.Update(cfg.CDKDir(), func(context map[string]any, prefix string) error {
+		all, _ := context[prefix+agcdkutil.PreservedExportsContextKey].(map[string]any)
+		if all == nil {
+			all = map[string]any{}
+		}
+		for _, stack := range stacks {
+			delete(all, stack.Name)
+			if exports := updated[stack.Name]; len(exports) > 0 {
+				values := make(map[string]any, len(exports))
+				for name, value := range exports {
+					values[name] = value
+				}
+				all[stack.Name] = values
+			}
+		}
+		if len(all) == 0 {
+			delete(context, prefix+agcdkutil.PreservedExportsContextKey)
+			return nil
+		}
+		context[prefix+agcdkutil.PreservedExportsContextKey] = all
+		return nil
+	})
+}
+
+// removedExports returns the names of the deployed exports of a stack that its synthesized
+// template no longer exports itself, sorted. Outputs added by agcdkutil.PreserveExports do not
+// count, so exports stay preserved only as long as they are imported.
+func removedExports(deployed map[string]string, synthesized map[string]any) []string {
+	exported := map[string]bool{}
+	outputs, _ := synthesized["Outputs"].(map[string]any)
+	for id, output := range outputs {
+		if strings.HasPrefix(id, agcdkutil.PreservedExportIDPrefix) {
+			continue
+		}
+		o, _ := output.(map[string]any)
+		export, _ := o["Export"].(map[string]any)
+		if name, ok := export["Name"].(string); ok {
+			exported[name] = true
+		}
+	}
+
+	var removed []string
+	for _, name := range slices.Sorted(maps.Keys(deployed)) {
+		if !exported[name] {
+			removed = append(removed, name)
+		}
+	}
+	return removed
+}
+
+// preservedExportsChanged reports whether the preserved exports of the stacks differ from those
+// recorded in the context.
+func preservedExportsChanged(
+	recorded map[string]any, updated map[string]map[string]string, stacks []stackRef,
+) bool {
+	for _, stack := range stacks {
+		before, _ := recorded[stack.Name].(map[string]any)
+		after := updated[stack.Name]
+		if len(before) != len(after) {
+			return true
+		}
+		for name, value := range after {
+			if v, _ := before[name].(string); v != value {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"context"
+	"io"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/advdv/ago/agcdkutil"
+	"github.com/advdv/ago/cmd/ago/internal/config"
+	"github.com/advdv/ago/cmd/ago/internal/ops"
+	"github.com/cockroachdb/errors"
+	"github.com/urfave/cli/v3"
+)
+
+func lsCmd() *cli.Command {
+	return &cli.Command{
+		Name:  "ls",
+		Usage: "List CDK stacks grouped by deployment and region",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:  "deployment",
+				Usage: "Only list the shared stacks and the stacks of this deployment",
+			},
+		},
+		Action: config.RunWithConfig(runLs),
+	}
+}
+
+type cdkLsOptions struct {
+	Deployment string
+	Profile    string
+	Output     io.Writer
+	ErrOut     io.Writer
+}
+
+func runLs(ctx context.Context, cmd *cli.Command, cfg config.Config) error {
+	return doLs(ctx, cfg, cdkLsOptions{
+		Deployment: cmd.String("deployment"),
+		Profile:    cmd.String("profile"),
+		Output:     os.Stdout,
+		ErrOut:     os.Stderr,
+	})
+}
+
+// cdkStackGroup holds the stacks of a single deployment, or the shared stacks when
+// Deployment is empty.
+type cdkStackGroup struct {
+	Deployment string
+	Stacks     []cdkStackEntry
+}
+
+type cdkStackEntry struct {
+	Name   string
+	Region string
+}
+
+func doLs(ctx context.Context, cfg config.Config, opts cdkLsOptions) error {
+	cdk, err := loadCDKContext(cfg)
+	if err != nil {
+		return err
+	}
+
+	cdkExec := cdk.CDKExec.WithOutput(opts.ErrOut, opts.ErrOut)
+
+	deployments := cdk.Deployments
+	if opts.Deployment != "" && !slices.Contains(deployments, opts.Deployment) {
+		return errors.Errorf("deployment %q not found\n\nAvailable deployments: %s",
+			opts.Deployment, formatDeploymentsList(deployments))
+	}
+
+	if err := cdk.Require("primary-region"); err != nil {
+		return err
+	}
+	regions := cdk.Regions()
+
+	// Without a resolvable IAM user (e.g. an assumed admin role) we list as a full deployer,
+	// which is what the admin profile is allowed to deploy.
+	username, usernameErr := resolveCDKCallerUsername(ctx, opts.Profile, cdk.Context)
+	profile := resolveCDKProfile(opts.Profile, cdk.Context, username)
+	userGroups := []string{ops.DeployersGroupName(cdk.Qualifier)}
+	if usernameErr == nil {
+		userGroups, err = getUserGroups(ctx, profile, username)
+		if err != nil {
+			return err
+		}
+	}
+	fullDeployer := isFullDeployer(userGroups, cdk.Qualifier)
+
+	assemblyDir, err := synthAssembly(ctx, cdk, cdkExec, opts.ErrOut,
+		buildCDKArgs(profile, cdk.Qualifier, cdk.Prefix, userGroups))
+	if err != nil {
+		return err
+	}
+
+	output, err := cdkExec.MiseOutput(ctx, "cdk", "ls", "--app", assemblyDir)
+	if err != nil {
+		return errors.Wrap(err, "failed to list CDK stacks")
+	}
+
+	stacks := strings.Fields(output)
+	for _, group := range groupCDKStacks(stacks, cdk.Qualifier, regions, deployments) {
+		if opts.Deployment != "" && group.Deployment != "" && group.Deployment != opts.Deployment {
+			continue
+		}
+
+		switch {
+		case group.Deployment == "":
+			writeOutputf(opts.Output, "Shared\n")
+		case isRestrictedDeployment(group.Deployment) && !fullDeployer:
+			writeOutputf(opts.Output, "%s (not deployable: requires full deployer permissions)\n", group.Deployment)
+		default:
+			writeOutputf(opts.Output, "%s\n", group.Deployment)
+		}
+
+		for _, stack := range group.Stacks {
+			writeOutputf(opts.Output, "  %-16s %s\n", stack.Region, stack.Name)
+		}
+	}
+
+	return nil
+}
+
+// groupCDKStacks groups stack names by the deployment they belong to, matching them against
+// the names agcdkutil generates. The shared group comes first, followed by the deployments in
+// context order. Stacks that match no known name are put in a trailing "(other)" group.
+func groupCDKStacks(stacks []string, qualifier string, regions, deployments []string) []cdkStackGroup {
+	type key struct{ deployment, region string }
+	known := make(map[string]key)
+	for _, region := range regions {
+		regionIdent := agcdkutil.RegionIdentFor(region)
+		known[agcdkutil.SharedStackName(qualifier, regionIdent)] = key{"", region}
+		for _, deployment := range deployments {
+			known[agcdkutil.DeploymentStackName(qualifier, regionIdent, deployment)] = key{deployment, region}
+		}
+	}
+
+	order := append([]string{""}, deployments...)
+	byDeployment := make(map[string][]cdkStackEntry)
+	var other []cdkStackEntry
+	for _, stack := range stacks {
+		// Stacks inside stages are listed as "Stage/Stack"; only the stack name identifies it.
+		name := stack[strings.LastIndex(stack, "/")+1:]
+		k, ok := known[name]
+		if !ok {
+			other = append(other, cdkStackEntry{Name: stack})
+			continue
+		}
+		byDeployment[k.deployment] = append(byDeployment[k.deployment], cdkStackEntry{Name: stack, Region: k.region})
+	}
+
+	var groups []cdkStackGroup
+	for _, deployment := range order {
+		if entries, ok := byDeployment[deployment]; ok {
+			groups = append(groups, cdkStackGroup{Deployment: deployment, Stacks: entries})
+		}
+	}
+	if len(other) > 0 {
+		groups = append(groups, cdkStackGroup{Deployment: "(other)", Stacks: other})
+	}
+
+	return groups
+}
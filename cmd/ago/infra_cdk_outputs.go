@@ -0,0 +1,205 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"slices"
+
+	"github.com/advdv/ago/agcdkutil"
+	"github.com/advdv/ago/cmd/ago/internal/awsapi"
+	"github.com/advdv/ago/cmd/ago/internal/config"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	"github.com/cockroachdb/errors"
+	"github.com/urfave/cli/v3"
+)
+
+func outputsCmd() *cli.Command {
+	return &cli.Command{
+		Name:      "outputs",
+		Usage:     "Show the outputs recorded by agcdkutil.Output for the shared and deployment stacks",
+		ArgsUsage: "[deployment]",
+		Flags: []cli.Flag{
+			&cli.BoolFlag{
+				Name:  "json",
+				Usage: "Print the outputs as a JSON object keyed by stack name",
+			},
+		},
+		Action: config.RunWithConfig(runOutputs),
+	}
+}
+
+type cdkOutputsOptions struct {
+	Deployment string
+	Profile    string
+	Region     string
+	JSON       bool
+	Output     io.Writer
+}
+
+func runOutputs(ctx context.Context, cmd *cli.Command, cfg config.Config) error {
+	return doOutputs(ctx, cfg, cdkOutputsOptions{
+		Deployment: cmd.Args().First(),
+		Profile:    cmd.String("profile"),
+		Region:     cmd.String("region"),
+		JSON:       cmd.Bool("json") || jsonOutput(cmd),
+		Output:     os.Stdout,
+	})
+}
+
+func doOutputs(ctx context.Context, cfg config.Config, opts cdkOutputsOptions) error {
+	cdk, err := loadCDKContext(cfg)
+	if err != nil {
+		return err
+	}
+
+	username, usernameErr := resolveCDKCallerUsername(ctx, opts.Profile, cdk.Context)
+
+	deployment, err := resolveDeploymentIdent(cfg.Inner, cdkCommandOptions{Deployment: opts.Deployment},
+		cdk.Context, username, usernameErr)
+	if err != nil {
+		return err
+	}
+
+	profile := resolveCDKProfile(opts.Profile, cdk.Context, username)
+
+	primaryRegion := cdk.PrimaryRegion
+	region := resolveAWSRegion(opts.Region, primaryRegion)
+	if region == "" {
+		return errors.Errorf("primary region not found at context key %q", cdk.Prefix+"primary-region")
+	}
+
+	clients, err := awsapi.New(ctx, profile, region)
+	if err != nil {
+		return err
+	}
+
+	regionIdent := agcdkutil.RegionIdentFor(region)
+	stackNames := []string{
+		agcdkutil.SharedStackName(cdk.Qualifier, regionIdent),
+		agcdkutil.DeploymentStackName(cdk.Qualifier, regionIdent, deployment),
+	}
+
+	all := make(map[string]map[string]string, len(stackNames))
+	for _, stackName := range stackNames {
+		outputs, err := getOutputRegistry(ctx, clients, stackName)
+		if err != nil {
+			return err
+		}
+		all[stackName] = outputs
+	}
+
+	if opts.JSON {
+		data, err := json.MarshalIndent(all, "", "  ")
+		if err != nil {
+			return errors.Wrap(err, "failed to marshal outputs")
+		}
+		writeOutputf(opts.Output, "%s\n", data)
+		return nil
+	}
+
+	for _, stackName := range stackNames {
+		writeOutputf(opts.Output, "%s\n", stackName)
+		outputs := all[stackName]
+		if len(outputs) == 0 {
+			writeOutputf(opts.Output, "  (no recorded outputs)\n")
+			continue
+		}
+
+		keys := make([]string, 0, len(outputs))
+		for key := range outputs {
+			keys = append(keys, key)
+		}
+		slices.Sort(keys)
+		for _, key := range keys {
+			writeOutputf(opts.Output, "  %s=%s\n", key, outputs[key])
+		}
+	}
+
+	return nil
+}
+
+// getOutputRegistry reads the outputs that agcdkutil.Output recorded for a stack. A stack
+// without recorded outputs yields an empty map.
+func getOutputRegistry(ctx context.Context, clients *awsapi.Clients, stackName string) (map[string]string, error) {
+	out, err := clients.SSM.GetParameter(ctx, &ssm.GetParameterInput{
+		Name: aws.String(agcdkutil.OutputRegistryParameterName(stackName)),
+	})
+	if awsapi.IsErrorCode(err, "ParameterNotFound") {
+		return map[string]string{}, nil
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read output registry of stack %q", stackName)
+	}
+
+	var outputs map[string]string
+	if err := json.Unmarshal([]byte(aws.ToString(out.Parameter.Value)), &outputs); err != nil {
+		return nil, errors.Wrapf(err, "failed to parse output registry of stack %q", stackName)
+	}
+
+	return outputs, nil
+}
+
+// deploymentStacks locates the stacks of a deployment in one region, for commands that act on
+// the resources a deployment created.
+type deploymentStacks struct {
+	Qualifier   string
+	Deployment  string
+	Profile     string
+	Region      string
+	Stack       string
+	SharedStack string
+
+	aws *awsapi.Clients
+}
+
+// resolveDeploymentStacks resolves the deployment, profile and region like the cdk commands do:
+// an empty deployment is the default deployment, or else the caller's Dev deployment, and an
+// empty region the primary region.
+func resolveDeploymentStacks(
+	ctx context.Context, cfg config.Config, deployment, profile, region string,
+) (deploymentStacks, error) {
+	cdk, err := loadCDKContext(cfg)
+	if err != nil {
+		return deploymentStacks{}, err
+	}
+
+	username, usernameErr := resolveCDKCallerUsername(ctx, profile, cdk.Context)
+
+	deployment, err = resolveDeploymentIdent(cfg.Inner, cdkCommandOptions{Deployment: deployment},
+		cdk.Context, username, usernameErr)
+	if err != nil {
+		return deploymentStacks{}, err
+	}
+
+	primaryRegion := cdk.PrimaryRegion
+	region = resolveAWSRegion(region, primaryRegion)
+	if region == "" {
+		return deploymentStacks{}, errors.Errorf("primary region not found at context key %q",
+			cdk.Prefix+"primary-region")
+	}
+
+	profile = resolveCDKProfile(profile, cdk.Context, username)
+	clients, err := awsapi.New(ctx, profile, region)
+	if err != nil {
+		return deploymentStacks{}, err
+	}
+
+	regionIdent := agcdkutil.RegionIdentFor(region)
+	return deploymentStacks{
+		Qualifier:   cdk.Qualifier,
+		Deployment:  deployment,
+		Profile:     profile,
+		Region:      region,
+		Stack:       agcdkutil.DeploymentStackName(cdk.Qualifier, regionIdent, deployment),
+		SharedStack: agcdkutil.SharedStackName(cdk.Qualifier, regionIdent),
+		aws:         clients,
+	}, nil
+}
+
+// outputs reads the outputs that agcdkutil.Output recorded for one of the stacks.
+func (s deploymentStacks) outputs(ctx context.Context, stackName string) (map[string]string, error) {
+	return getOutputRegistry(ctx, s.aws, stackName)
+}
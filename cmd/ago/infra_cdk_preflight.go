@@ -0,0 +1,306 @@
+package main
+
+import (
+	"context"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/advdv/ago/agcdkutil"
+	"github.com/advdv/ago/cmd/ago/internal/awsapi"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation"
+	cfntypes "github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+	iamtypes "github.com/aws/aws-sdk-go-v2/service/iam/types"
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
+	"github.com/aws/aws-sdk-go-v2/service/route53"
+	route53types "github.com/aws/aws-sdk-go-v2/service/route53/types"
+	"github.com/aws/aws-sdk-go-v2/service/servicequotas"
+	"github.com/cockroachdb/errors"
+)
+
+// Service Quotas codes for the limits the project is most likely to run into.
+const (
+	quotaCodeIAMManagedPolicies  = "L-E95E4862"
+	quotaCodeCloudFormationStack = "L-0485CFC0"
+	quotaCodeLambdaConcurrency   = "L-B99A9384"
+	quotaCodeRoute53HostedZones  = "L-4EA4796A"
+)
+
+// minLambdaConcurrency is the account-level concurrency we expect to be available. New accounts
+// often start at 10, which leaves no room for reserved concurrency on any function.
+const minLambdaConcurrency = 100
+
+// preBootstrapManagedPolicies is the number of customer managed policies in the pre-bootstrap template.
+const preBootstrapManagedPolicies = 5
+
+// quotaPlan describes what a bootstrap or deploy is about to create.
+type quotaPlan struct {
+	// Partition is the AWS partition the project is deployed in.
+	Partition string
+	// Stacks maps each region to the stack names that will exist after the operation.
+	Stacks map[string][]string
+	// ManagedPolicies is the number of customer managed policies that will be created.
+	ManagedPolicies int
+	// HostedZone is the domain name of the hosted zone that will be created, if any.
+	HostedZone string
+}
+
+// quotaCheck is the outcome of comparing a single quota against planned usage.
+type quotaCheck struct {
+	Name        string
+	ServiceCode string
+	QuotaCode   string
+	Region      string
+	Limit       int
+	Usage       int
+	Required    int
+}
+
+func (q quotaCheck) exceeded() bool {
+	return q.Usage+q.Required > q.Limit
+}
+
+// desiredValue returns the limit to request so that the planned usage fits with some headroom.
+func (q quotaCheck) desiredValue() int {
+	needed := q.Usage + q.Required
+	return max(needed+needed/2, q.Limit*2)
+}
+
+// deployQuotaPlan returns the stacks a deploy will create for the given deployments in every region.
+// The first region is the primary region.
+func deployQuotaPlan(qualifier string, regions, deployments []string, baseDomainName string) quotaPlan {
+	plan := quotaPlan{
+		Partition:  agcdkutil.PartitionFor(regions[0]),
+		Stacks:     make(map[string][]string),
+		HostedZone: baseDomainName,
+	}
+	for _, region := range regions {
+		regionIdent := agcdkutil.RegionIdentFor(region)
+		plan.Stacks[region] = append(plan.Stacks[region], agcdkutil.SharedStackName(qualifier, regionIdent))
+		for _, deployment := range deployments {
+			plan.Stacks[region] = append(plan.Stacks[region],
+				agcdkutil.DeploymentStackName(qualifier, regionIdent, deployment))
+		}
+	}
+	return plan
+}
+
+// runQuotaPreflight checks the planned usage against the account's quotas and warns about any
+// quota that would be exceeded. With requestIncreases, a quota increase is filed for each of them.
+// Failing to read a quota is reported as a warning since deployers may lack the permissions to do so.
+func runQuotaPreflight(
+	ctx context.Context, output io.Writer, profile string, plan quotaPlan, requestIncreases bool,
+) {
+	writeOutputf(output, "Checking service quotas...\n")
+
+	var checks []quotaCheck
+	addCheck := func(check quotaCheck, err error) {
+		if err != nil {
+			writeOutputf(output, "  Warning: could not check %s: %v\n", check.Name, err)
+			return
+		}
+		checks = append(checks, check)
+	}
+
+	if plan.ManagedPolicies > 0 {
+		addCheck(checkIAMManagedPolicies(ctx, profile, plan.Partition, plan.ManagedPolicies))
+	}
+	if plan.HostedZone != "" {
+		addCheck(checkHostedZones(ctx, profile, plan.Partition, plan.HostedZone))
+	}
+
+	for _, region := range slices.Sorted(maps.Keys(plan.Stacks)) {
+		addCheck(checkCloudFormationStacks(ctx, profile, region, plan.Stacks[region]))
+		addCheck(checkLambdaConcurrency(ctx, profile, region))
+	}
+
+	exceeded := 0
+	for _, check := range checks {
+		if !check.exceeded() {
+			continue
+		}
+		exceeded++
+
+		writeOutputf(output, "  Warning: %s in %s: %d in use, %d required, limit is %d\n",
+			check.Name, check.Region, check.Usage, check.Required, check.Limit)
+
+		if !requestIncreases {
+			continue
+		}
+
+		if err := requestQuotaIncrease(ctx, profile, check); err != nil {
+			writeOutputf(output, "    Warning: failed to request increase: %v\n", err)
+			continue
+		}
+		writeOutputf(output, "    Requested increase to %d\n", check.desiredValue())
+	}
+
+	if exceeded == 0 {
+		writeOutputf(output, "  All checked quotas have sufficient headroom\n")
+	} else if !requestIncreases {
+		writeOutputf(output, "  Re-run with --request-increases to file quota increase requests\n")
+	}
+}
+
+func checkIAMManagedPolicies(ctx context.Context, profile, partition string, required int) (quotaCheck, error) {
+	check := quotaCheck{
+		Name:        "IAM customer managed policies",
+		ServiceCode: "iam",
+		QuotaCode:   quotaCodeIAMManagedPolicies,
+		Region:      agcdkutil.PartitionGlobalRegion(partition),
+		Required:    required,
+	}
+
+	clients, err := awsapi.New(ctx, profile, check.Region)
+	if err != nil {
+		return check, err
+	}
+	out, err := clients.IAM.GetAccountSummary(ctx, &iam.GetAccountSummaryInput{})
+	if err != nil {
+		return check, errors.Wrap(err, "failed to get IAM account summary")
+	}
+
+	usage, ok := out.SummaryMap[string(iamtypes.SummaryKeyTypePolicies)]
+	limit, hasLimit := out.SummaryMap[string(iamtypes.SummaryKeyTypePoliciesQuota)]
+	if !ok || !hasLimit {
+		return check, errors.Errorf("unexpected IAM account summary: %v", out.SummaryMap)
+	}
+
+	check.Usage, check.Limit = int(usage), int(limit)
+	return check, nil
+}
+
+func checkCloudFormationStacks(ctx context.Context, profile, region string, planned []string) (quotaCheck, error) {
+	check := quotaCheck{
+		Name:        "CloudFormation stacks",
+		ServiceCode: "cloudformation",
+		QuotaCode:   quotaCodeCloudFormationStack,
+		Region:      region,
+	}
+
+	clients, err := awsapi.New(ctx, profile, region)
+	if err != nil {
+		return check, err
+	}
+
+	var existing []string
+	stacks := cloudformation.NewListStacksPaginator(clients.CloudFormation, &cloudformation.ListStacksInput{})
+	for stacks.HasMorePages() {
+		page, err := stacks.NextPage(ctx)
+		if err != nil {
+			return check, errors.Wrap(err, "failed to list stacks")
+		}
+		for _, stack := range page.StackSummaries {
+			if stack.StackStatus != cfntypes.StackStatusDeleteComplete {
+				existing = append(existing, aws.ToString(stack.StackName))
+			}
+		}
+	}
+
+	check.Usage = len(existing)
+	for _, name := range planned {
+		if !slices.Contains(existing, name) {
+			check.Required++
+		}
+	}
+
+	limits := cloudformation.NewDescribeAccountLimitsPaginator(clients.CloudFormation,
+		&cloudformation.DescribeAccountLimitsInput{})
+	for limits.HasMorePages() {
+		page, err := limits.NextPage(ctx)
+		if err != nil {
+			return check, errors.Wrap(err, "failed to describe account limits")
+		}
+		for _, limit := range page.AccountLimits {
+			if aws.ToString(limit.Name) == "StackLimit" {
+				check.Limit = int(aws.ToInt32(limit.Value))
+				return check, nil
+			}
+		}
+	}
+
+	return check, errors.New("no stack limit in the account limits")
+}
+
+func checkLambdaConcurrency(ctx context.Context, profile, region string) (quotaCheck, error) {
+	check := quotaCheck{
+		Name:        "Lambda concurrent executions",
+		ServiceCode: "lambda",
+		QuotaCode:   quotaCodeLambdaConcurrency,
+		Region:      region,
+		Required:    minLambdaConcurrency,
+	}
+
+	clients, err := awsapi.New(ctx, profile, region)
+	if err != nil {
+		return check, err
+	}
+	out, err := clients.Lambda.GetAccountSettings(ctx, &lambda.GetAccountSettingsInput{})
+	if err != nil {
+		return check, errors.Wrap(err, "failed to get Lambda account settings")
+	}
+	if out.AccountLimit == nil {
+		return check, errors.New("no account limit in the Lambda account settings")
+	}
+
+	check.Limit = int(out.AccountLimit.ConcurrentExecutions)
+	return check, nil
+}
+
+func checkHostedZones(ctx context.Context, profile, partition, domainName string) (quotaCheck, error) {
+	check := quotaCheck{
+		Name:        "Route 53 hosted zones",
+		ServiceCode: "route53",
+		QuotaCode:   quotaCodeRoute53HostedZones,
+		Region:      agcdkutil.PartitionGlobalRegion(partition),
+	}
+
+	clients, err := awsapi.New(ctx, profile, check.Region)
+	if err != nil {
+		return check, err
+	}
+	limit, err := clients.Route53.GetAccountLimit(ctx, &route53.GetAccountLimitInput{
+		Type: route53types.AccountLimitTypeMaxHostedZonesByOwner,
+	})
+	if err != nil {
+		return check, errors.Wrap(err, "failed to get Route 53 account limit")
+	}
+	if limit.Limit == nil {
+		return check, errors.New("no limit in the Route 53 account limit")
+	}
+	check.Usage, check.Limit = int(limit.Count), int(aws.ToInt64(limit.Limit.Value))
+
+	zones, err := clients.Route53.ListHostedZonesByName(ctx, &route53.ListHostedZonesByNameInput{
+		DNSName:  aws.String(domainName),
+		MaxItems: aws.Int32(1),
+	})
+	if err != nil {
+		return check, errors.Wrap(err, "failed to list hosted zones")
+	}
+	if !slices.ContainsFunc(zones.HostedZones, func(zone route53types.HostedZone) bool {
+		return aws.ToString(zone.Name) == strings.TrimSuffix(domainName, ".")+"."
+	}) {
+		check.Required = 1
+	}
+
+	return check, nil
+}
+
+func requestQuotaIncrease(ctx context.Context, profile string, check quotaCheck) error {
+	clients, err := awsapi.New(ctx, profile, check.Region)
+	if err != nil {
+		return err
+	}
+	if _, err := clients.ServiceQuotas.RequestServiceQuotaIncrease(ctx,
+		&servicequotas.RequestServiceQuotaIncreaseInput{
+			ServiceCode:  aws.String(check.ServiceCode),
+			QuotaCode:    aws.String(check.QuotaCode),
+			DesiredValue: aws.Float64(float64(check.desiredValue())),
+		}); err != nil {
+		return errors.Wrapf(err, "failed to request increase for %s", check.Name)
+	}
+	return nil
+}
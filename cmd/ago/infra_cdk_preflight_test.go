@@ -0,0 +1,59 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestDeployQuotaPlan(t *testing.T) {
+	t.Parallel()
+
+	plan := deployQuotaPlan("myapp", []string{"eu-central-1", "eu-north-1"}, []string{"DevAdam"}, "example.com")
+
+	if plan.Partition != "aws" {
+		t.Errorf("expected partition %q, got %q", "aws", plan.Partition)
+	}
+	if plan.HostedZone != "example.com" {
+		t.Errorf("expected hosted zone %q, got %q", "example.com", plan.HostedZone)
+	}
+
+	want := map[string][]string{
+		"eu-central-1": {"myappEuc1Shared", "myappEuc1DevAdam"},
+		"eu-north-1":   {"myappEun1Shared", "myappEun1DevAdam"},
+	}
+	if len(plan.Stacks) != len(want) {
+		t.Fatalf("expected %d regions, got %d", len(want), len(plan.Stacks))
+	}
+	for region, stacks := range want {
+		if !slices.Equal(plan.Stacks[region], stacks) {
+			t.Errorf("region %s: expected %v, got %v", region, stacks, plan.Stacks[region])
+		}
+	}
+}
+
+func TestQuotaCheck(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name        string
+		check       quotaCheck
+		wantExceed  bool
+		wantDesired int
+	}{
+		{"headroom", quotaCheck{Limit: 200, Usage: 10, Required: 3}, false, 400},
+		{"exactly at limit", quotaCheck{Limit: 10, Usage: 7, Required: 3}, false, 20},
+		{"exceeded", quotaCheck{Limit: 10, Usage: 0, Required: 100}, true, 150},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if got := tt.check.exceeded(); got != tt.wantExceed {
+				t.Errorf("expected exceeded=%v, got %v", tt.wantExceed, got)
+			}
+			if got := tt.check.desiredValue(); got != tt.wantDesired {
+				t.Errorf("expected desired value %d, got %d", tt.wantDesired, got)
+			}
+		})
+	}
+}
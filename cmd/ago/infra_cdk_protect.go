@@ -0,0 +1,205 @@
+package main
+
+import (
+	"context"
+	"io"
+	"slices"
+	"strings"
+
+	"github.com/advdv/ago/agcdkutil"
+	"github.com/advdv/ago/cmd/ago/internal/awsapi"
+	"github.com/advdv/ago/cmd/ago/internal/cmdexec"
+	"github.com/advdv/ago/cmd/ago/internal/ops"
+	"github.com/advdv/ago/cmd/ago/internal/warnings"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	cfntypes "github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
+	"github.com/cockroachdb/errors"
+)
+
+// protectionChangeSetName is the change set that deploy prepares to inspect changes to protected
+// resources. It is deleted again before the actual deploy.
+const protectionChangeSetName = "ago-protection-check"
+
+// stackRef identifies a deployed stack.
+type stackRef struct {
+	Name   string
+	Region string
+}
+
+// deployStacks returns the shared stacks and the stacks of the given deployments in all regions.
+func deployStacks(qualifier string, regions, deployments []string) []stackRef {
+	var stacks []stackRef
+	for _, region := range regions {
+		regionIdent := agcdkutil.RegionIdentFor(region)
+		stacks = append(stacks, stackRef{Name: agcdkutil.SharedStackName(qualifier, regionIdent), Region: region})
+		for _, deployment := range deployments {
+			stacks = append(stacks, stackRef{
+				Name: agcdkutil.DeploymentStackName(qualifier, regionIdent, deployment), Region: region,
+			})
+		}
+	}
+	return stacks
+}
+
+// resourceChange is a single entry of a CloudFormation change set.
+type resourceChange struct {
+	Action            string `json:"Action"`            //nolint:tagliatelle // AWS API uses PascalCase
+	LogicalResourceID string `json:"LogicalResourceId"` //nolint:tagliatelle // AWS API uses PascalCase
+	ResourceType      string `json:"ResourceType"`      //nolint:tagliatelle // AWS API uses PascalCase
+	Replacement       string `json:"Replacement"`       //nolint:tagliatelle // AWS API uses PascalCase
+}
+
+// protectionViolation is a change that would replace or delete a protected resource.
+type protectionViolation struct {
+	Stack     string
+	LogicalID string
+	Reason    string
+	Change    string
+}
+
+// checkProtectedResources refuses the deploy when it would replace or delete a resource marked
+// with agcdkutil.Protect. Protection is read from the deployed templates, since only resources
+// that exist can be replaced or deleted. Change sets are only prepared for stacks that contain
+// protected resources.
+func checkProtectedResources(
+	ctx context.Context, cdkExec cmdexec.Executor, output io.Writer, warn *warnings.Reporter,
+	profile string, cdkArgs []string, stacks []stackRef,
+) error {
+	var violations []protectionViolation
+	for _, stack := range stacks {
+		clients, err := awsapi.New(ctx, profile, stack.Region)
+		if err != nil {
+			return err
+		}
+		deployed, err := getDeployedTemplate(ctx, clients, stack.Name)
+		if err != nil {
+			return err
+		}
+		protected := protectedResources(deployed)
+		if len(protected) == 0 {
+			continue
+		}
+
+		writeOutputf(output, "Checking changes to %d protected resource(s) in %s...\n", len(protected), stack.Name)
+		changes, err := prepareChangeSet(ctx, cdkExec, profile, cdkArgs, stack)
+		if err != nil {
+			return err
+		}
+		violations = append(violations, protectionViolations(stack.Name, changes, protected)...)
+	}
+
+	if len(violations) == 0 {
+		return nil
+	}
+
+	lines := make([]string, 0, len(violations))
+	for _, v := range violations {
+		lines = append(lines, v.Stack+"/"+v.LogicalID+" would be "+v.Change+" (protected: "+v.Reason+")")
+	}
+
+	return warn.Require(warnings.Warning{
+		Code:     warnings.ProtectedResourceChange,
+		Severity: warnings.Danger,
+		Summary:  "Deploy would change protected resources",
+		Detail:   strings.Join(lines, "\n") + "\n\nUse --allow-protected to deploy anyway.",
+	})
+}
+
+// protectedResources returns the logical IDs of the resources in a template that carry the
+// agcdkutil.Protect metadata, with the reason given for each.
+func protectedResources(template map[string]any) map[string]string {
+	protected := map[string]string{}
+	resources, _ := template["Resources"].(map[string]any)
+	for logicalID, resource := range resources {
+		r, _ := resource.(map[string]any)
+		metadata, _ := r["Metadata"].(map[string]any)
+		if reason, ok := metadata[agcdkutil.ProtectedMetadataKey].(string); ok {
+			protected[logicalID] = reason
+		}
+	}
+	return protected
+}
+
+// protectionViolations returns the changes that remove or (possibly) replace a protected
+// resource, sorted by logical ID.
+func protectionViolations(
+	stackName string, changes []resourceChange, protected map[string]string,
+) []protectionViolation {
+	var violations []protectionViolation
+	for _, change := range changes {
+		reason, ok := protected[change.LogicalResourceID]
+		if !ok {
+			continue
+		}
+
+		var what string
+		switch {
+		case change.Action == "Remove":
+			what = "deleted"
+		case change.Replacement == "True":
+			what = "replaced"
+		case change.Replacement == "Conditional":
+			what = "possibly replaced"
+		default:
+			continue
+		}
+
+		violations = append(violations, protectionViolation{
+			Stack: stackName, LogicalID: change.LogicalResourceID, Reason: reason, Change: what,
+		})
+	}
+
+	slices.SortFunc(violations, func(a, b protectionViolation) int {
+		return strings.Compare(a.LogicalID, b.LogicalID)
+	})
+
+	return violations
+}
+
+// prepareChangeSet lets CDK publish assets and create a change set for a single stack without
+// executing it, then returns its changes and deletes it.
+func prepareChangeSet(
+	ctx context.Context, cdkExec cmdexec.Executor, profile string, cdkArgs []string, stack stackRef,
+) ([]resourceChange, error) {
+	args := append([]string{"deploy"}, cdkArgs...)
+	args = append(args, "*"+stack.Name,
+		"--exclusively",
+		"--method=prepare-change-set",
+		"--change-set-name", protectionChangeSetName,
+		"--require-approval", "never",
+	)
+	if err := cdkExec.Mise(ctx, "cdk", args...); err != nil {
+		return nil, errors.Wrapf(err, "failed to prepare change set for stack %q", stack.Name)
+	}
+
+	clients, err := awsapi.New(ctx, profile, stack.Region)
+	if err != nil {
+		return nil, err
+	}
+
+	// CDK removes change sets without changes itself, which leaves none to describe.
+	resourceChanges, err := ops.ChangeSetChanges(ctx, clients, stack.Name, protectionChangeSetName)
+	if err != nil || resourceChanges == nil {
+		return nil, err
+	}
+
+	if err := ops.DeleteChangeSet(ctx, clients, stack.Name, protectionChangeSetName); err != nil {
+		return nil, err
+	}
+
+	return toResourceChanges(resourceChanges), nil
+}
+
+// toResourceChanges returns the resource changes of a change set as the CLI records them.
+func toResourceChanges(resourceChanges []cfntypes.ResourceChange) []resourceChange {
+	changes := make([]resourceChange, 0, len(resourceChanges))
+	for _, c := range resourceChanges {
+		changes = append(changes, resourceChange{
+			Action:            string(c.Action),
+			LogicalResourceID: aws.ToString(c.LogicalResourceId),
+			ResourceType:      aws.ToString(c.ResourceType),
+			Replacement:       string(c.Replacement),
+		})
+	}
+	return changes
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"io"
+	"strings"
+
+	"github.com/advdv/ago/cmd/ago/internal/awsapi"
+	"github.com/advdv/ago/cmd/ago/internal/ops"
+	"github.com/advdv/ago/cmd/ago/internal/warnings"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
+	"github.com/cockroachdb/errors"
+)
+
+// stackRemediation is what a deploy has to do first to a stack in a state it cannot update.
+type stackRemediation int
+
+const (
+	remediateNone stackRemediation = iota
+	// remediateRecreate deletes a stack whose creation failed. CloudFormation leaves it in
+	// ROLLBACK_COMPLETE, from which the only way forward is deleting it; the deploy recreates it.
+	remediateRecreate
+	// remediateContinueRollback resumes a rollback that failed on resources it could not restore.
+	remediateContinueRollback
+	// remediateWait is a stack with an operation in progress, which must finish first.
+	// REVIEW_IN_PROGRESS is not one: it is left by a CREATE change set that was never executed,
+	// never resolves by itself, and cdk deploy handles it.
+	remediateWait
+)
+
+// remediationFor returns what a stack in the status needs before it can be deployed.
+func remediationFor(status types.StackStatus) stackRemediation {
+	switch {
+	case status == types.StackStatusReviewInProgress:
+		return remediateNone
+	case status == types.StackStatusRollbackComplete:
+		return remediateRecreate
+	case status == types.StackStatusUpdateRollbackFailed:
+		return remediateContinueRollback
+	case strings.HasSuffix(string(status), "_IN_PROGRESS"):
+		return remediateWait
+	default:
+		return remediateNone
+	}
+}
+
+// recoverStuckStacks brings the stacks of a deploy into a state the deploy can update. Both
+// remediations change the stacks outside of the deploy, so each needs its warning acknowledged.
+func recoverStuckStacks(
+	ctx context.Context, output io.Writer, warn *warnings.Reporter, profile string, stacks []stackRef,
+) error {
+	for _, stack := range stacks {
+		clients, err := awsapi.New(ctx, profile, stack.Region)
+		if err != nil {
+			return err
+		}
+
+		status, err := ops.StackStatus(ctx, clients, stack.Name)
+		if err != nil {
+			return err
+		}
+
+		switch remediationFor(status) {
+		case remediateNone:
+			continue
+		case remediateWait:
+			return errors.Errorf("stack %s is %s, wait for the operation to finish and deploy again", stack.Name, status)
+		case remediateRecreate:
+			if err := warn.Require(warnings.Warning{
+				Code:     warnings.StackRecreate,
+				Severity: warnings.Danger,
+				Summary:  "Stack " + stack.Name + " is in ROLLBACK_COMPLETE and must be deleted before it can be deployed",
+				Detail: "Its creation failed and was rolled back, so CloudFormation only allows deleting it.\n" +
+					"The deploy deletes the stack and creates it again. Resources with a Retain policy\n" +
+					"that survived the rollback are left behind and may conflict with the new stack.",
+			}); err != nil {
+				return err
+			}
+
+			writeOutputf(output, "Deleting %s to recreate it...\n", stack.Name)
+			if err := ops.DeleteStack(ctx, clients, stack.Name); err != nil {
+				return err
+			}
+		case remediateContinueRollback:
+			skip, err := ops.FailedResources(ctx, clients, stack.Name)
+			if err != nil {
+				return err
+			}
+
+			detail := "The rollback of its last update failed. The deploy continues the rollback"
+			if len(skip) > 0 {
+				detail += ", skipping the\nresources that could not be rolled back:\n\n  " + strings.Join(skip, "\n  ") +
+					"\n\nSkipped resources keep their current state, which may no longer match the template.\n" +
+					"Fix them by hand if the next deploy fails on them."
+			} else {
+				detail += "."
+			}
+			if err := warn.Require(warnings.Warning{
+				Code:     warnings.StackRollbackSkip,
+				Severity: warnings.Danger,
+				Summary:  "Stack " + stack.Name + " is in UPDATE_ROLLBACK_FAILED and cannot be updated",
+				Detail:   detail,
+			}); err != nil {
+				return err
+			}
+
+			writeOutputf(output, "Continuing rollback of %s...\n", stack.Name)
+			if err := ops.ContinueUpdateRollback(ctx, clients, stack.Name, skip); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
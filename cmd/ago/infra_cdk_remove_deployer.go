@@ -4,9 +4,9 @@ import (
 	"context"
 	"io"
 	"os"
-	"path/filepath"
 	"slices"
 
+	"github.com/advdv/ago/cmd/ago/internal/cdkcontext"
 	"github.com/advdv/ago/cmd/ago/internal/config"
 	"github.com/cockroachdb/errors"
 	"github.com/urfave/cli/v3"
@@ -39,55 +39,48 @@ func runRemoveDeployer(ctx context.Context, cmd *cli.Command, cfg config.Config)
 }
 
 func doRemoveDeployer(_ context.Context, cfg config.Config, opts removeDeployerOptions) error {
-	cdkDir := filepath.Join(cfg.ProjectDir, "infra", "cdk", "cdk")
-	contextPath := filepath.Join(cdkDir, "cdk.context.json")
-
-	cdkCtx, err := getCDKContext(cdkDir)
-	if err != nil {
-		return err
-	}
-
-	prefix, err := detectPrefix(cdkCtx)
+	cdkCtx, err := readCDKContext(cfg)
 	if err != nil {
 		return err
 	}
 
-	deployers := extractStringSlice(cdkCtx, prefix+"deployers")
-	devDeployers := extractStringSlice(cdkCtx, prefix+"dev-deployers")
-
-	foundInDeployers := slices.Contains(deployers, opts.Username)
-	foundInDevDeployers := slices.Contains(devDeployers, opts.Username)
+	foundInDeployers := slices.Contains(cdkCtx.Deployers, opts.Username)
+	foundInDevDeployers := slices.Contains(cdkCtx.DevDeployers, opts.Username)
 
 	if !foundInDeployers && !foundInDevDeployers {
 		return errors.Errorf("user %q not found in deployers or dev-deployers list", opts.Username)
 	}
 
-	contextJSON, err := readContextFile(contextPath)
+	deploymentIdent := "Dev" + opts.Username
+	removeDeployment := slices.Contains(cdkCtx.Deployments, deploymentIdent)
+	without := func(list []string, name string) []string {
+		return slices.DeleteFunc(slices.Clone(list), func(s string) bool { return s == name })
+	}
+
+	err = cdkcontext.Update(cfg.CDKDir(), func(values map[string]any, prefix string) error {
+		if foundInDeployers {
+			values[prefix+"deployers"] = without(cdkCtx.Deployers, opts.Username)
+		}
+		if foundInDevDeployers {
+			values[prefix+"dev-deployers"] = without(cdkCtx.DevDeployers, opts.Username)
+		}
+		if removeDeployment {
+			values[prefix+"deployments"] = without(cdkCtx.Deployments, deploymentIdent)
+		}
+		return nil
+	})
 	if err != nil {
 		return err
 	}
 
 	if foundInDeployers {
-		deployers = slices.DeleteFunc(deployers, func(s string) bool { return s == opts.Username })
-		contextJSON[prefix+"deployers"] = deployers
-		writeOutputf(opts.Output, "Removed %q from deployers in cdk.context.json\n", opts.Username)
+		writeOutputf(opts.Output, "Removed %q from deployers in %s\n", opts.Username, cdkcontext.FileName)
 	}
 	if foundInDevDeployers {
-		devDeployers = slices.DeleteFunc(devDeployers, func(s string) bool { return s == opts.Username })
-		contextJSON[prefix+"dev-deployers"] = devDeployers
-		writeOutputf(opts.Output, "Removed %q from dev-deployers in cdk.context.json\n", opts.Username)
+		writeOutputf(opts.Output, "Removed %q from dev-deployers in %s\n", opts.Username, cdkcontext.FileName)
 	}
-
-	deploymentIdent := "Dev" + opts.Username
-	deployments := extractStringSlice(cdkCtx, prefix+"deployments")
-	if slices.Contains(deployments, deploymentIdent) {
-		deployments = slices.DeleteFunc(deployments, func(s string) bool { return s == deploymentIdent })
-		contextJSON[prefix+"deployments"] = deployments
-		writeOutputf(opts.Output, "Removed %q from deployments in cdk.context.json\n", deploymentIdent)
-	}
-
-	if err := writeContextFile(contextPath, contextJSON); err != nil {
-		return err
+	if removeDeployment {
+		writeOutputf(opts.Output, "Removed %q from deployments in %s\n", deploymentIdent, cdkcontext.FileName)
 	}
 
 	writeOutputf(opts.Output,
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/advdv/ago/cmd/ago/internal/config"
+	"github.com/advdv/ago/cmd/ago/internal/ops"
+	"github.com/advdv/ago/cmd/ago/internal/warnings"
+	"github.com/cockroachdb/errors"
+	"github.com/urfave/cli/v3"
+)
+
+// sandboxAccount is the account that a sandbox synth pretends to deploy to.
+const sandboxAccount = "123456789012"
+
+func sandboxSynthCmd() *cli.Command {
+	return &cli.Command{
+		Name:  "sandbox-synth",
+		Usage: "Synthesize all stacks without AWS access, using a stub account and placeholder context",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:  "out",
+				Usage: "Directory to write the cloud assembly to, relative to the CDK app",
+				Value: "cdk.sandbox.out",
+			},
+		},
+		Action: config.RunWithConfig(runSandboxSynth),
+	}
+}
+
+type sandboxSynthOptions struct {
+	Out         string
+	Acknowledge []string
+	Output      io.Writer
+}
+
+func runSandboxSynth(ctx context.Context, cmd *cli.Command, cfg config.Config) error {
+	return doSandboxSynth(ctx, cfg, sandboxSynthOptions{
+		Out:         cmd.String("out"),
+		Acknowledge: cmd.StringSlice("acknowledge"),
+		Output:      os.Stdout,
+	})
+}
+
+func doSandboxSynth(ctx context.Context, cfg config.Config, opts sandboxSynthOptions) error {
+	warn, err := warnings.New(opts.Output, opts.Acknowledge)
+	if err != nil {
+		return err
+	}
+
+	cdk, err := loadCDKContext(cfg)
+	if err != nil {
+		return err
+	}
+
+	if err := cdk.Require("primary-region"); err != nil {
+		return err
+	}
+	primaryRegion := cdk.PrimaryRegion
+
+	// Point the AWS SDKs at empty config files and disable the instance metadata service, so that
+	// nothing in the synth can pick up real credentials.
+	cdkExec := cdk.CDKExec.WithOutput(opts.Output, opts.Output).
+		WithEnv("CDK_DEFAULT_ACCOUNT", sandboxAccount).
+		WithEnv("CDK_DEFAULT_REGION", primaryRegion).
+		WithEnv("AWS_CONFIG_FILE", os.DevNull).
+		WithEnv("AWS_SHARED_CREDENTIALS_FILE", os.DevNull).
+		WithEnv("AWS_EC2_METADATA_DISABLED", "true").
+		WithEnv("AWS_PROFILE", "").
+		WithEnv("AWS_ACCESS_KEY_ID", "").
+		WithEnv("AWS_SECRET_ACCESS_KEY", "").
+		WithEnv("AWS_SESSION_TOKEN", "")
+
+	if err := runCDKCommand(ctx, cdkExec, "synth", sandboxSynthArgs(cdk.Qualifier, cdk.Prefix, opts.Out)); err != nil {
+		return errors.Wrap(err, "sandbox synth failed; constructs that look up values in AWS (such as "+
+			"fromLookup calls) need their results in cdk.context.json, so run 'ago infra cdk diff' "+
+			"once with credentials to record them")
+	}
+
+	writeOutputf(opts.Output, "\nSynthesized all stacks for stub account %s into %s\n", sandboxAccount, opts.Out)
+
+	assemblyDir := opts.Out
+	if !filepath.IsAbs(assemblyDir) {
+		assemblyDir = filepath.Join(cdk.CDKDir, assemblyDir)
+	}
+	return checkStackBudgets(cfg, cdk, warn, assemblyDir)
+}
+
+// sandboxSynthArgs returns the cdk synth arguments of a sandbox synth. It synthesizes as a full
+// deployer so every deployment is included, and disables lookups so that a construct that needs
+// one fails instead of calling AWS.
+func sandboxSynthArgs(qualifier, prefix, out string) []string {
+	return []string{
+		"--all",
+		"--quiet",
+		"--lookups=false",
+		"--qualifier", qualifier,
+		"--output", out,
+		"-c", deployerGroupsContext(prefix, []string{ops.DeployersGroupName(qualifier)}),
+		"-c", prefix + "sandbox=true",
+	}
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/advdv/ago/cmd/ago/internal/cmdexec"
+	"github.com/advdv/ago/cmd/ago/internal/config"
+	"github.com/advdv/ago/cmd/ago/internal/dirhash"
+	"github.com/cockroachdb/errors"
+	"github.com/urfave/cli/v3"
+)
+
+// Synth cache.
+//
+// Synthesizing the CDK app starts jsii and runs the whole app, which takes long enough to
+// dominate read-only commands such as ls and diff. These commands synthesize through
+// synthAssembly, which keeps the cloud assembly in the user cache directory under a key derived
+// from the infra sources, the CDK context files and the synth arguments. As long as none of them
+// change, later commands reuse the assembly instead of synthesizing again. Only the latest
+// assembly of each qualifier is kept.
+//
+// The key covers the infra directory, which holds the CDK app, its context files and the Go
+// module it is built with. Changes outside it, such as a local replace directive of the ago
+// module, are not detected: run 'ago infra cdk synth-cache clear' after changing those.
+
+// synthCacheExcludes are the paths in the infra directory that do not affect synthesis.
+var synthCacheExcludes = []string{"**/cdk.out", "**/.terraform"}
+
+func synthCacheCmd() *cli.Command {
+	return &cli.Command{
+		Name:  "synth-cache",
+		Usage: "Manage the cache of synthesized cloud assemblies used by read-only CDK commands",
+		Commands: []*cli.Command{
+			{
+				Name:   "clear",
+				Usage:  "Remove the cached assemblies of the project",
+				Action: config.RunWithConfig(runSynthCacheClear),
+			},
+		},
+	}
+}
+
+func runSynthCacheClear(_ context.Context, _ *cli.Command, cfg config.Config) error {
+	cdk, err := loadCDKContext(cfg)
+	if err != nil {
+		return err
+	}
+
+	dir, err := synthCacheDir(cdk.Qualifier)
+	if err != nil {
+		return err
+	}
+	if err := os.RemoveAll(dir); err != nil {
+		return errors.Wrap(err, "failed to clear synth cache")
+	}
+
+	writeOutputf(os.Stdout, "Cleared %s\n", dir)
+	return nil
+}
+
+// synthAssembly returns the directory of a cloud assembly with all stacks of the app,
+// synthesized with args. It reuses the assembly of an earlier call when the infra sources, the
+// context and args are unchanged.
+func synthAssembly(
+	ctx context.Context, cdk *cdkContext, cdkExec cmdexec.Executor, output io.Writer, args []string,
+) (string, error) {
+	infraDir := filepath.Dir(filepath.Dir(cdk.CDKDir))
+	key, err := synthCacheKey(infraDir, args)
+	if err != nil {
+		return "", err
+	}
+
+	cacheDir, err := synthCacheDir(cdk.Qualifier)
+	if err != nil {
+		return "", err
+	}
+
+	assemblyDir := filepath.Join(cacheDir, key)
+	if _, err := os.Stat(filepath.Join(assemblyDir, "manifest.json")); err == nil {
+		writeOutputf(output, "Using cached synthesis (%s)\n", key[:12])
+		return assemblyDir, nil
+	}
+
+	if err := os.MkdirAll(cacheDir, 0o750); err != nil {
+		return "", errors.Wrap(err, "failed to create synth cache directory")
+	}
+	tmpDir, err := os.MkdirTemp(cacheDir, "synth-*")
+	if err != nil {
+		return "", errors.Wrap(err, "failed to create synth output directory")
+	}
+	defer os.RemoveAll(tmpDir)
+
+	synthArgs := append([]string{"synth"}, args...)
+	synthArgs = append(synthArgs, "--quiet", "--all", "--output", tmpDir)
+	if err := cdkExec.Mise(ctx, "cdk", synthArgs...); err != nil {
+		return "", errors.Wrap(err, "failed to synthesize stacks")
+	}
+
+	if err := pruneSynthCache(cacheDir); err != nil {
+		return "", err
+	}
+	if err := os.Rename(tmpDir, assemblyDir); err != nil {
+		return "", errors.Wrap(err, "failed to store synthesized assembly")
+	}
+
+	return assemblyDir, nil
+}
+
+// synthCacheKey hashes everything that determines the synthesized assembly: the files of the
+// infra directory except synthCacheExcludes and what its .gitignore files ignore, and the
+// arguments, which carry the profile, qualifier and deployer groups.
+func synthCacheKey(infraDir string, args []string) (string, error) {
+	sources, err := dirhash.New(
+		dirhash.WithExcludePatterns(synthCacheExcludes...),
+		dirhash.WithIgnoreFiles(dirhash.GitIgnore()),
+		dirhash.WithTruncateLength(0),
+	).Hash(infraDir, "")
+	if err != nil {
+		return "", errors.Wrap(err, "failed to hash infra sources")
+	}
+
+	h := sha256.New()
+	_, _ = io.WriteString(h, sources)
+	for _, arg := range args {
+		_, _ = io.WriteString(h, "\x00"+arg)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// synthCacheDir returns the cache directory of the assemblies of a qualifier.
+func synthCacheDir(qualifier string) (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", errors.Wrap(err, "failed to determine user cache directory")
+	}
+	return filepath.Join(dir, "ago", "synth", qualifier), nil
+}
+
+// pruneSynthCache removes the cached assemblies, but not the syntheses in progress.
+func pruneSynthCache(cacheDir string) error {
+	entries, err := os.ReadDir(cacheDir)
+	if err != nil {
+		return errors.Wrap(err, "failed to read synth cache directory")
+	}
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.Name(), "synth-") {
+			continue
+		}
+		if err := os.RemoveAll(filepath.Join(cacheDir, entry.Name())); err != nil {
+			return errors.Wrap(err, "failed to prune synth cache")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSynthCacheKey(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	write := func(name, content string) {
+		t.Helper()
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	key := func(args ...string) string {
+		t.Helper()
+		k, err := synthCacheKey(dir, args)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return k
+	}
+
+	write("go.mod", "module infra")
+	write("cdk/.gitignore", "*.log\n")
+	write("cdk/cdk/main.go", "package main")
+	write("cdk/cdk/cdk.context.json", `{"app-deployments":["Prod"]}`)
+	base := key("--profile", "dev")
+
+	write("cdk/cdk/cdk.out/manifest.json", "{}")
+	write("cdk/cdk/cdk.out/Stack.template.json", "{}")
+	if got := key("--profile", "dev"); got != base {
+		t.Error("expected the synthesized output not to change the key")
+	}
+	write("cdk/cdk/synth.log", "debug output")
+	if got := key("--profile", "dev"); got != base {
+		t.Error("expected git-ignored files not to change the key")
+	}
+
+	if got := key("--profile", "prod"); got == base {
+		t.Error("expected other arguments to change the key")
+	}
+
+	write("cdk/cdk/cdk.context.json", `{"app-deployments":["Prod","Stag"]}`)
+	if got := key("--profile", "dev"); got == base {
+		t.Error("expected a context change to change the key")
+	}
+}
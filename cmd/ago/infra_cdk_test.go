@@ -1,8 +1,18 @@
 package main
 
 import (
+	"os"
+	"path/filepath"
+	"slices"
 	"strings"
 	"testing"
+	"time"
+
+	"github.com/advdv/ago/agcdkutil"
+	"github.com/advdv/ago/cmd/ago/internal/cdkcontext"
+	"github.com/advdv/ago/cmd/ago/internal/config"
+	"github.com/advdv/ago/cmd/ago/internal/ops"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
 )
 
 func TestCheckDeploymentPermission(t *testing.T) {
@@ -66,6 +76,13 @@ func TestCheckDeploymentPermission(t *testing.T) {
 			wantErr:     true,
 			errContains: "requires full deployer",
 		},
+		{
+			name:        "tenant deployment without full deployer",
+			deployment:  "TenantAcmeCorp",
+			isFullDep:   false,
+			wantErr:     true,
+			errContains: "requires full deployer",
+		},
 	}
 
 	for _, tt := range tests {
@@ -136,6 +153,86 @@ func TestBuildCDKArgs(t *testing.T) {
 	})
 }
 
+func TestTenantContextArgs(t *testing.T) {
+	t.Parallel()
+	cdkCtx := newTestCDKContext(t, map[string]any{
+		"myapp-deployments": []any{"Dev", "Prod"},
+		"myapp-tenants":     []any{"acme-corp", "globex"},
+	})
+
+	args := tenantContextArgs(cdkCtx, "TenantAcmeCorp")
+	if want := []string{"-c", "myapp-tenant=acme-corp"}; !slices.Equal(args, want) {
+		t.Errorf("tenant deployment: got %v, want %v", args, want)
+	}
+	if args := tenantContextArgs(cdkCtx, "Prod"); args != nil {
+		t.Errorf("regular deployment: got %v, want nil", args)
+	}
+
+	deployment, err := resolveDeploymentIdent(config.InnerConfig{}, cdkCommandOptions{Deployment: "TenantGlobex"},
+		cdkCtx, "", nil)
+	if err != nil || deployment != "TenantGlobex" {
+		t.Errorf("resolve tenant deployment: got %q, %v", deployment, err)
+	}
+}
+
+// TestResolveDeploymentIdentDefault covers the commands that take the deployment as an argument,
+// such as deploy, diff, destroy, estimate and outputs, which have no --deployment flag for
+// AGO_DEPLOYMENT or default_deployment to set.
+func TestResolveDeploymentIdentDefault(t *testing.T) {
+	cdkCtx := newTestCDKContext(t, map[string]any{"myapp-deployments": []any{"Dev", "Prod", "Staging"}})
+	inner := config.InnerConfig{}
+	resolve := func(deployment string) (string, error) {
+		return resolveDeploymentIdent(inner, cdkCommandOptions{Deployment: deployment}, cdkCtx, "", nil)
+	}
+	t.Setenv("CI", "true")
+	t.Setenv("AGO_DEPLOYMENT", "")
+
+	if _, err := resolve(""); err == nil {
+		t.Error("expected an error in CI without a deployment")
+	}
+
+	inner.DefaultDeployment = "Staging"
+	if got, err := resolve(""); err != nil || got != "Staging" {
+		t.Errorf("default_deployment: got %q, %v", got, err)
+	}
+
+	t.Setenv("AGO_DEPLOYMENT", "Prod")
+	if got, err := resolve(""); err != nil || got != "Prod" {
+		t.Errorf("AGO_DEPLOYMENT over default_deployment: got %q, %v", got, err)
+	}
+	if got, err := resolve("Dev"); err != nil || got != "Dev" {
+		t.Errorf("argument over AGO_DEPLOYMENT: got %q, %v", got, err)
+	}
+
+	t.Setenv("AGO_DEPLOYMENT", "Unknown")
+	if _, err := resolve(""); err == nil {
+		t.Error("expected an error for an unknown AGO_DEPLOYMENT")
+	}
+}
+
+func TestSandboxSynthArgs(t *testing.T) {
+	t.Parallel()
+
+	args := sandboxSynthArgs("myapp", "myapp-", "cdk.sandbox.out")
+
+	expected := []string{
+		"--all",
+		"--quiet",
+		"--lookups=false",
+		"--qualifier", "myapp",
+		"--output", "cdk.sandbox.out",
+		"-c", "myapp-deployer-groups=myapp-deployers",
+		"-c", "myapp-sandbox=true",
+	}
+
+	if !slices.Equal(args, expected) {
+		t.Errorf("expected %v, got %v", expected, args)
+	}
+	if slices.Contains(args, "--profile") {
+		t.Error("sandbox synth must not use a profile")
+	}
+}
+
 func TestValidateDeployerUsername(t *testing.T) {
 	t.Parallel()
 	tests := []struct {
@@ -196,92 +293,38 @@ func TestValidateProjectName(t *testing.T) {
 	}
 }
 
-func TestDetectPrefix(t *testing.T) {
-	t.Parallel()
-	tests := []struct {
-		name       string
-		context    map[string]any
-		wantPrefix string
-		wantErr    bool
-	}{
-		{
-			name:       "finds qualifier",
-			context:    map[string]any{"myapp-qualifier": "myapp"},
-			wantPrefix: "myapp-",
-			wantErr:    false,
-		},
-		{
-			name:       "finds qualifier with different prefix",
-			context:    map[string]any{"other-qualifier": "other"},
-			wantPrefix: "other-",
-			wantErr:    false,
-		},
-		{
-			name:       "no qualifier",
-			context:    map[string]any{"something": "value"},
-			wantPrefix: "",
-			wantErr:    true,
-		},
+// newTestCDKContext returns the CDK context of values, with the "myapp-" prefix unless values
+// has a qualifier of its own.
+func newTestCDKContext(t *testing.T, values map[string]any) *cdkcontext.Context {
+	t.Helper()
+	if _, err := cdkcontext.DetectPrefix(values); err != nil {
+		values["myapp-qualifier"] = "myapp"
 	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			t.Parallel()
-			prefix, err := detectPrefix(tt.context)
-			if tt.wantErr {
-				if err == nil {
-					t.Errorf("expected error but got nil")
-				}
-			} else {
-				if err != nil {
-					t.Errorf("unexpected error: %v", err)
-				}
-				if prefix != tt.wantPrefix {
-					t.Errorf("expected prefix %q, got %q", tt.wantPrefix, prefix)
-				}
-			}
-		})
+	cdkCtx, err := cdkcontext.New(values)
+	if err != nil {
+		t.Fatal(err)
 	}
+	return cdkCtx
 }
 
-func TestExtractStringSlice(t *testing.T) {
+func TestParseCommaList(t *testing.T) {
 	t.Parallel()
 	tests := []struct {
-		name    string
-		context map[string]any
-		key     string
-		want    []string
+		input string
+		want  []string
 	}{
-		{
-			name:    "extracts string slice",
-			context: map[string]any{"items": []any{"a", "b", "c"}},
-			key:     "items",
-			want:    []string{"a", "b", "c"},
-		},
-		{
-			name:    "missing key",
-			context: map[string]any{},
-			key:     "items",
-			want:    nil,
-		},
-		{
-			name:    "wrong type",
-			context: map[string]any{"items": "not a slice"},
-			key:     "items",
-			want:    nil,
-		},
-		{
-			name:    "mixed types in slice",
-			context: map[string]any{"items": []any{"a", 123, "c"}},
-			key:     "items",
-			want:    []string{"a", "c"},
-		},
+		{"a,b,c", []string{"a", "b", "c"}},
+		{"a, b, c", []string{"a", "b", "c"}},
+		{"  a  ,  b  ,  c  ", []string{"a", "b", "c"}},
+		{"single", []string{"single"}},
+		{"", nil},
+		{"a,,b", []string{"a", "b"}},
 	}
 
 	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
+		t.Run(tt.input, func(t *testing.T) {
 			t.Parallel()
-			got := extractStringSlice(tt.context, tt.key)
+			got := parseCommaList(tt.input)
 			if len(got) != len(tt.want) {
 				t.Fatalf("expected %v, got %v", tt.want, got)
 			}
@@ -294,32 +337,295 @@ func TestExtractStringSlice(t *testing.T) {
 	}
 }
 
-func TestParseCommaList(t *testing.T) {
+func TestGroupCDKStacks(t *testing.T) {
+	t.Parallel()
+
+	stacks := []string{
+		"myappEuc1Shared",
+		"myappEuc1DevAdam",
+		"myappEuc1Prod",
+		"myappEun1Shared",
+		"myappEun1Prod",
+		"SomethingElse",
+	}
+
+	groups := groupCDKStacks(stacks, "myapp", []string{"eu-central-1", "eu-north-1"}, []string{"Prod", "DevAdam"})
+
+	want := []cdkStackGroup{
+		{Deployment: "", Stacks: []cdkStackEntry{
+			{Name: "myappEuc1Shared", Region: "eu-central-1"},
+			{Name: "myappEun1Shared", Region: "eu-north-1"},
+		}},
+		{Deployment: "Prod", Stacks: []cdkStackEntry{
+			{Name: "myappEuc1Prod", Region: "eu-central-1"},
+			{Name: "myappEun1Prod", Region: "eu-north-1"},
+		}},
+		{Deployment: "DevAdam", Stacks: []cdkStackEntry{
+			{Name: "myappEuc1DevAdam", Region: "eu-central-1"},
+		}},
+		{Deployment: "(other)", Stacks: []cdkStackEntry{
+			{Name: "SomethingElse"},
+		}},
+	}
+
+	if len(groups) != len(want) {
+		t.Fatalf("expected %d groups, got %d: %v", len(want), len(groups), groups)
+	}
+	for i := range want {
+		if groups[i].Deployment != want[i].Deployment {
+			t.Errorf("group %d: expected deployment %q, got %q", i, want[i].Deployment, groups[i].Deployment)
+		}
+		if !slices.Equal(groups[i].Stacks, want[i].Stacks) {
+			t.Errorf("group %d: expected stacks %v, got %v", i, want[i].Stacks, groups[i].Stacks)
+		}
+	}
+}
+
+func TestDiffParameters(t *testing.T) {
+	t.Parallel()
+
+	desired := ops.PreBootstrapParameters("myapp", []string{"eu-north-1"}, []string{"Bob", "Adam"}, []string{"Carol"}, "",
+		ops.DeployerAuthIAMUsers)
+	deployed := map[string]string{
+		"Qualifier":        "myapp",
+		"SecondaryRegions": "eu-north-1",
+		"Deployers":        "Adam,Bob",
+		"DevDeployers":     "Dave",
+	}
+
+	changes := diffParameters(desired, deployed)
+	if len(changes) != 1 {
+		t.Fatalf("expected 1 change, got %d: %v", len(changes), changes)
+	}
+	if changes[0].Key != "DevDeployers" {
+		t.Errorf("expected change to DevDeployers, got %q", changes[0].Key)
+	}
+	if !slices.Equal(changes[0].Added, []string{"Carol"}) || !slices.Equal(changes[0].Removed, []string{"Dave"}) {
+		t.Errorf("expected +Carol -Dave, got +%v -%v", changes[0].Added, changes[0].Removed)
+	}
+
+	deployed["Qualifier"] = "other"
+	changes = diffParameters(desired, deployed)
+	if len(changes) != 2 || changes[0].Key != "Qualifier" || changes[0].Desired != "myapp" {
+		t.Errorf("expected qualifier change first, got %v", changes)
+	}
+}
+
+func TestEstimateCostDelta(t *testing.T) {
+	t.Parallel()
+
+	template := func(types ...string) map[string]any {
+		resources := map[string]any{}
+		for i, typ := range types {
+			resources["Resource"+strings.Repeat("X", i)] = map[string]any{"Type": typ}
+		}
+		return map[string]any{"Resources": resources}
+	}
+
+	deployed := []map[string]any{
+		template("AWS::KMS::Key", "AWS::Lambda::Function"),
+		{},
+	}
+	desired := []map[string]any{
+		template("AWS::KMS::Key", "AWS::KMS::Key", "AWS::Lambda::Function"),
+		template("AWS::EC2::NatGateway", "AWS::DynamoDB::Table"),
+	}
+
+	estimate := estimateCostDelta(deployed, desired)
+
+	types := make([]string, 0, len(estimate.Changes))
+	for _, change := range estimate.Changes {
+		types = append(types, change.Type)
+	}
+	if want := []string{"AWS::DynamoDB::Table", "AWS::EC2::NatGateway", "AWS::KMS::Key"}; !slices.Equal(types, want) {
+		t.Errorf("changed types = %v, want %v", types, want)
+	}
+	if estimate.Changes[0].Priced {
+		t.Error("expected DynamoDB table to be usage-based")
+	}
+	if want := resourceMonthlyCost["AWS::EC2::NatGateway"] + resourceMonthlyCost["AWS::KMS::Key"]; estimate.MonthlyDelta != want {
+		t.Errorf("monthly delta = %.2f, want %.2f", estimate.MonthlyDelta, want)
+	}
+
+	if estimate := estimateCostDelta(desired, desired); len(estimate.Changes) != 0 || estimate.MonthlyDelta != 0 {
+		t.Errorf("expected no changes for identical templates, got %+v", estimate)
+	}
+}
+
+func TestProtectionViolations(t *testing.T) {
 	t.Parallel()
+
+	protected := protectedResources(map[string]any{
+		"Resources": map[string]any{
+			"Table":  map[string]any{"Type": "AWS::DynamoDB::Table", "Metadata": map[string]any{"ago:protected": "orders"}},
+			"Bucket": map[string]any{"Type": "AWS::S3::Bucket", "Metadata": map[string]any{"ago:protected": "uploads"}},
+			"Pool":   map[string]any{"Type": "AWS::Cognito::UserPool", "Metadata": map[string]any{"ago:protected": "users"}},
+			"Func":   map[string]any{"Type": "AWS::Lambda::Function"},
+		},
+	})
+	if len(protected) != 3 {
+		t.Fatalf("expected 3 protected resources, got %v", protected)
+	}
+
+	changes := []resourceChange{
+		{Action: "Modify", LogicalResourceID: "Table", Replacement: "True"},
+		{Action: "Remove", LogicalResourceID: "Bucket"},
+		{Action: "Modify", LogicalResourceID: "Pool", Replacement: "False"},
+		{Action: "Remove", LogicalResourceID: "Func"},
+	}
+
+	violations := protectionViolations("myappUse1Prod", changes, protected)
+	want := []protectionViolation{
+		{Stack: "myappUse1Prod", LogicalID: "Bucket", Reason: "uploads", Change: "deleted"},
+		{Stack: "myappUse1Prod", LogicalID: "Table", Reason: "orders", Change: "replaced"},
+	}
+	if !slices.Equal(violations, want) {
+		t.Errorf("violations = %+v, want %+v", violations, want)
+	}
+}
+
+func TestCheckDeployWindows(t *testing.T) {
+	t.Parallel()
+
+	cfg := config.Config{ProjectDir: t.TempDir()}
+	if err := os.MkdirAll(filepath.Join(cfg.ProjectDir, "infra"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	content := "deployments:\n  Prod:\n    deploy_windows: [{days: [mon], start: \"09:00\", end: \"17:00\"}]\n"
+	if err := os.WriteFile(deploymentsFilePath(cfg), []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	known := []string{"Dev", "Prod"}
+	monday := time.Date(2026, 10, 19, 10, 0, 0, 0, time.UTC)
+	sunday := time.Date(2026, 10, 18, 10, 0, 0, 0, time.UTC)
+
+	if err := checkDeployWindows(cfg, known, []string{"Dev", "Prod"}, monday); err != nil {
+		t.Errorf("unexpected error inside window: %v", err)
+	}
+	if err := checkDeployWindows(cfg, known, []string{"Dev"}, sunday); err != nil {
+		t.Errorf("unexpected error for deployment without windows: %v", err)
+	}
+	err := checkDeployWindows(cfg, known, []string{"Dev", "Prod"}, sunday)
+	if err == nil || !strings.Contains(err.Error(), `deployment "Prod" is outside its deploy windows (mon 09:00-17:00 UTC)`) {
+		t.Errorf("expected deploy window error, got %v", err)
+	}
+}
+
+func TestRemediationFor(t *testing.T) {
+	t.Parallel()
+
 	tests := []struct {
-		input string
-		want  []string
+		status types.StackStatus
+		want   stackRemediation
 	}{
-		{"a,b,c", []string{"a", "b", "c"}},
-		{"a, b, c", []string{"a", "b", "c"}},
-		{"  a  ,  b  ,  c  ", []string{"a", "b", "c"}},
-		{"single", []string{"single"}},
-		{"", nil},
-		{"a,,b", []string{"a", "b"}},
+		{status: "", want: remediateNone},
+		{status: types.StackStatusCreateComplete, want: remediateNone},
+		{status: types.StackStatusUpdateRollbackComplete, want: remediateNone},
+		{status: types.StackStatusReviewInProgress, want: remediateNone},
+		{status: types.StackStatusRollbackComplete, want: remediateRecreate},
+		{status: types.StackStatusUpdateRollbackFailed, want: remediateContinueRollback},
+		{status: types.StackStatusUpdateInProgress, want: remediateWait},
+		{status: types.StackStatusUpdateRollbackCompleteCleanupInProgress, want: remediateWait},
 	}
 
 	for _, tt := range tests {
-		t.Run(tt.input, func(t *testing.T) {
+		t.Run(string(tt.status), func(t *testing.T) {
 			t.Parallel()
-			got := parseCommaList(tt.input)
-			if len(got) != len(tt.want) {
-				t.Fatalf("expected %v, got %v", tt.want, got)
-			}
-			for i := range got {
-				if got[i] != tt.want[i] {
-					t.Errorf("index %d: expected %q, got %q", i, tt.want[i], got[i])
-				}
+
+			if got := remediationFor(tt.status); got != tt.want {
+				t.Errorf("remediationFor(%q) = %d, want %d", tt.status, got, tt.want)
 			}
 		})
 	}
 }
+
+func TestRemovedExports(t *testing.T) {
+	t.Parallel()
+
+	deployed := map[string]string{
+		"myappUse1Shared:ExportsOutputRefVpc":    "vpc-123",
+		"myappUse1Shared:ExportsOutputRefBucket": "bucket",
+		"myappUse1Shared:ExportsOutputRefTable":  "table",
+	}
+	synthesized := map[string]any{"Outputs": map[string]any{
+		"ExportsOutputRefVpc": map[string]any{
+			"Value":  map[string]any{"Ref": "Vpc"},
+			"Export": map[string]any{"Name": "myappUse1Shared:ExportsOutputRefVpc"},
+		},
+		agcdkutil.PreservedExportIDPrefix + "myappUse1SharedExportsOutputRefTable": map[string]any{
+			"Value":  "table",
+			"Export": map[string]any{"Name": "myappUse1Shared:ExportsOutputRefTable"},
+		},
+	}}
+
+	got := removedExports(deployed, synthesized)
+	want := []string{"myappUse1Shared:ExportsOutputRefBucket", "myappUse1Shared:ExportsOutputRefTable"}
+	if !slices.Equal(got, want) {
+		t.Errorf("removedExports() = %v, want %v", got, want)
+	}
+}
+
+func TestSharedDeployRegions(t *testing.T) {
+	t.Parallel()
+
+	cdkCtx := newTestCDKContext(t, map[string]any{
+		"myapp-primary-region":    "eu-central-1",
+		"myapp-secondary-regions": []any{"us-east-1", "eu-west-1"},
+	})
+
+	got, err := sharedDeployRegions(cdkCtx, "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := []string{"eu-central-1", "us-east-1", "eu-west-1"}; !slices.Equal(got, want) {
+		t.Errorf("sharedDeployRegions() = %v, want %v", got, want)
+	}
+
+	got, err = sharedDeployRegions(cdkCtx, "us-east-1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := []string{"us-east-1"}; !slices.Equal(got, want) {
+		t.Errorf("sharedDeployRegions() = %v, want %v", got, want)
+	}
+
+	if _, err := sharedDeployRegions(cdkCtx, "ap-south-1"); err == nil {
+		t.Error("expected an error for a region outside the project")
+	}
+	if _, err := sharedDeployRegions(newTestCDKContext(t, map[string]any{}), ""); err == nil {
+		t.Error("expected an error without a primary region")
+	}
+}
+
+func TestPreBootstrapTemplateCITrust(t *testing.T) {
+	t.Parallel()
+
+	path, cleanup, err := renderPreBootstrapTemplate("myapp", DefaultServices())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cleanup()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), `"repo:*:*"`) {
+		t.Error("expected the CI deployer role not to trust every repository")
+	}
+	for _, want := range []string{
+		`token.actions.githubusercontent.com:sub: !Sub "repo:${CIRepository}:*"`,
+		"HasCIRepository: !Not [!Equals [!Ref CIRepository, \"\"]]",
+	} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("expected the pre-bootstrap template to contain %q", want)
+		}
+	}
+
+	params := ops.PreBootstrapParameters("myapp", nil, nil, nil, "acme/myapp", ops.DeployerAuthIAMUsers)
+	if i := slices.IndexFunc(params, func(p ops.CFNParameter) bool { return p.Key == "CIRepository" }); i < 0 ||
+		params[i].Value != "acme/myapp" {
+		t.Errorf("expected the CIRepository parameter to be acme/myapp, got %+v", params)
+	}
+}
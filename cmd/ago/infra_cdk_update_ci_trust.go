@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/advdv/ago/cmd/ago/internal/awsapi"
+	"github.com/advdv/ago/cmd/ago/internal/cdkcontext"
+	"github.com/advdv/ago/cmd/ago/internal/config"
+	"github.com/advdv/ago/cmd/ago/internal/ops"
+	"github.com/cockroachdb/errors"
+	"github.com/urfave/cli/v3"
+)
+
+func updateCITrustCmd() *cli.Command {
+	return &cli.Command{
+		Name: "update-ci-trust",
+		Usage: "Change the GitHub repository whose workflows may assume the CI deployer role, and update " +
+			"the pre-bootstrap stack",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:     "github-repo",
+				Usage:    "GitHub repository, as owner/name, that the CI deployer role trusts",
+				Required: true,
+			},
+		},
+		Action: config.RunWithConfig(runUpdateCITrust),
+	}
+}
+
+type updateCITrustOptions struct {
+	Repository string
+	Profile    string
+	Output     io.Writer
+}
+
+func runUpdateCITrust(ctx context.Context, cmd *cli.Command, cfg config.Config) error {
+	return doUpdateCITrust(ctx, cfg, updateCITrustOptions{
+		Repository: cmd.String("github-repo"),
+		Profile:    cmd.String("profile"),
+		Output:     os.Stdout,
+	})
+}
+
+// ciRepository returns the GitHub repository that the CI deployer role trusts, or "" when CI
+// has not been set up.
+func ciRepository(cdkCtx *cdkcontext.Context) string {
+	return cdkCtx.String("ci-repository")
+}
+
+// setCIRepository records the GitHub repository that the CI deployer role trusts in
+// cdk.context.json.
+func setCIRepository(cdkDir, repo string) error {
+	return cdkcontext.Update(cdkDir, func(values map[string]any, prefix string) error {
+		values[prefix+"ci-repository"] = repo
+		return nil
+	})
+}
+
+// doUpdateCITrust records the repository and updates the pre-bootstrap stack. Only the trust
+// changes: other pending changes to the stack's parameters need a full 'ago infra cdk bootstrap',
+// which also syncs the credentials of new deployers.
+func doUpdateCITrust(ctx context.Context, cfg config.Config, opts updateCITrustOptions) error {
+	if err := validateGitHubRepository(opts.Repository); err != nil {
+		return err
+	}
+
+	cdkCtx, err := readCDKContext(cfg)
+	if err != nil {
+		return err
+	}
+	if err := cdkCtx.Require("primary-region"); err != nil {
+		return err
+	}
+	qualifier, primaryRegion := cdkCtx.Qualifier, cdkCtx.PrimaryRegion
+
+	profile, err := resolveAWSProfile(opts.Profile, func() (string, error) { return getAdminProfile(cdkCtx) })
+	if err != nil {
+		return err
+	}
+
+	clients, err := awsapi.New(ctx, profile, primaryRegion)
+	if err != nil {
+		return err
+	}
+
+	stackName := ops.PreBootstrapStackName(qualifier)
+	exists, err := ops.StackExists(ctx, clients, stackName)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return errors.Errorf("stack %q is not deployed, run 'ago infra cdk bootstrap --github-repo %s'",
+			stackName, opts.Repository)
+	}
+
+	auth, err := deployerAuth(cdkCtx)
+	if err != nil {
+		return err
+	}
+
+	params := ops.PreBootstrapParameters(qualifier,
+		cdkCtx.SecondaryRegions, cdkCtx.Deployers, cdkCtx.DevDeployers, opts.Repository, auth)
+
+	deployed, err := ops.StackParameters(ctx, clients, stackName)
+	if err != nil {
+		return err
+	}
+	var pending []string
+	for _, change := range diffParameters(params, deployed) {
+		if change.Key != "CIRepository" {
+			pending = append(pending, change.Key)
+		}
+	}
+	if len(pending) > 0 {
+		return errors.Errorf("the CDK context has other pending changes to %q (%s), "+
+			"run 'ago infra cdk bootstrap --github-repo %s' instead", stackName, strings.Join(pending, ", "),
+			opts.Repository)
+	}
+
+	services, err := projectServices(cdkCtx)
+	if err != nil {
+		return errors.Wrap(err, "failed to parse services from context")
+	}
+
+	if err := setCIRepository(cfg.CDKDir(), opts.Repository); err != nil {
+		return err
+	}
+	writeOutputf(opts.Output, "Set %s to %q in %s\n", cdkCtx.Key("ci-repository"), opts.Repository,
+		cdkcontext.FileName)
+
+	lk := newLocker(cfg, profile, primaryRegion, qualifier)
+	return withLocks(ctx, lk, opts.Output, "update-ci-trust", []string{lockScopeBootstrap}, func() error {
+		templatePath, cleanup, err := renderPreBootstrapTemplate(qualifier, services)
+		if err != nil {
+			return errors.Wrap(err, "failed to render pre-bootstrap template")
+		}
+		defer cleanup()
+
+		writeOutputf(opts.Output, "Updating pre-bootstrap stack...\n")
+		if err := ops.DeployPreBootstrapStack(ctx, clients, stackName, templatePath, params); err != nil {
+			return err
+		}
+
+		writeOutputf(opts.Output, "Role %s now trusts workflows of %s.\n",
+			ops.CIDeployerRoleName(qualifier), opts.Repository)
+		return nil
+	})
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"context"
+	"io"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/advdv/ago/agcdkutil"
+	"github.com/advdv/ago/cmd/ago/internal/cmdexec"
+	"github.com/advdv/ago/cmd/ago/internal/config"
+	"github.com/cockroachdb/errors"
+	"github.com/urfave/cli/v3"
+)
+
+func infraDeployCmd() *cli.Command {
+	return &cli.Command{
+		Name: "deploy",
+		Usage: "Deploy the project's stacks in order: per region, primary region first, " +
+			"the shared stack before the deployment stacks",
+		Flags: append([]cli.Flag{
+			&cli.StringFlag{
+				Name:  "deployment",
+				Usage: "Deployment to deploy (default: the caller's Dev deployment)",
+			},
+		}, deployFlags()...),
+		Action: config.RunWithConfig(runInfraDeploy),
+	}
+}
+
+func runInfraDeploy(ctx context.Context, cmd *cli.Command, cfg config.Config) error {
+	deployment := cmd.String("deployment")
+	return withNotification(ctx, cfg, os.Stdout, "deploy", deployment, func() error {
+		return doDeploy(ctx, cfg, cdkCommandOptions{
+			Deployment:       deployment,
+			Profile:          cmd.String("profile"),
+			Region:           cmd.String("region"),
+			All:              cmd.Bool("all"),
+			Deployments:      cmd.StringSlice("deployments"),
+			AllDev:           cmd.Bool("all-dev"),
+			Concurrency:      int(cmd.Int("concurrency")),
+			Ordered:          true,
+			Hotswap:          cmd.Bool("hotswap"),
+			RequestIncreases: cmd.Bool("request-increases"),
+			Yes:              cmd.Bool("yes"),
+			AllowProtected:   cmd.Bool("allow-protected"),
+			IgnoreWindow:     cmd.Bool("ignore-window"),
+			Acknowledge:      cmd.StringSlice("acknowledge"),
+			Output:           os.Stdout,
+		})
+	})
+}
+
+// allowedDeployments returns the deployments the caller may deploy: all of them for full
+// deployers, and the unrestricted ones for everyone else.
+func allowedDeployments(deployments []string, fullDeployer bool) []string {
+	if fullDeployer {
+		return deployments
+	}
+	return slices.DeleteFunc(slices.Clone(deployments), isRestrictedDeployment)
+}
+
+// defaultDeployConcurrency is how many deployment stacks a stage deploys at the same time when
+// several deployments are deployed together.
+const defaultDeployConcurrency = 4
+
+// selectDeployments returns the deployments to deploy together: the named ones, or with allDev
+// every Dev deployment. Named deployments must exist. Full deployers may deploy any of them;
+// everyone else is limited by the dev deployer scope policy to their own Dev{username}, so
+// allDev and naming other deployments are refused before the rollout starts.
+func selectDeployments(known, names []string, allDev, fullDeployer bool, username string) ([]string, error) {
+	if allDev {
+		if !fullDeployer {
+			return nil, errors.New("--all-dev requires full deployer permissions (member of deployers group)")
+		}
+		dev := slices.DeleteFunc(slices.Clone(known), func(d string) bool { return !strings.HasPrefix(d, "Dev") })
+		if len(dev) == 0 {
+			return nil, errors.Errorf("no Dev deployments found\n\nAvailable deployments: %s",
+				formatDeploymentsList(known))
+		}
+		return dev, nil
+	}
+
+	selected := make([]string, 0, len(names))
+	for _, name := range names {
+		if !slices.Contains(known, name) {
+			return nil, errors.Errorf("deployment %q not found\n\nAvailable deployments: %s",
+				name, formatDeploymentsList(known))
+		}
+		if err := checkDeploymentPermission(name, fullDeployer); err != nil {
+			return nil, err
+		}
+		if !fullDeployer && name != "Dev"+username {
+			return nil, errors.Errorf("deployment %q requires full deployer permissions (member of deployers "+
+				"group), dev deployers may only deploy %q", name, "Dev"+username)
+		}
+		if !slices.Contains(selected, name) {
+			selected = append(selected, name)
+		}
+	}
+	return selected, nil
+}
+
+// deployStage is one step of an ordered rollout: stacks in one region that deploy together.
+type deployStage struct {
+	Region string
+	Stacks []string
+}
+
+// deployRollout orders the stacks of a deploy into stages. Regions deploy in the given order, which
+// starts with the primary region, and within a region the shared stack deploys before the
+// deployment stacks that depend on it.
+func deployRollout(qualifier string, regions, deployments []string) []deployStage {
+	stages := make([]deployStage, 0, 2*len(regions))
+	for _, region := range regions {
+		regionIdent := agcdkutil.RegionIdentFor(region)
+		stages = append(stages, deployStage{
+			Region: region,
+			Stacks: []string{agcdkutil.SharedStackName(qualifier, regionIdent)},
+		})
+
+		stacks := make([]string, 0, len(deployments))
+		for _, deployment := range deployments {
+			stacks = append(stacks, agcdkutil.DeploymentStackName(qualifier, regionIdent, deployment))
+		}
+		if len(stacks) > 0 {
+			stages = append(stages, deployStage{Region: region, Stacks: stacks})
+		}
+	}
+	return stages
+}
+
+// runDeployRollout deploys the stages one after another and stops at the first that fails, so a
+// broken change never reaches the secondary regions. Each stage deploys exclusively its own
+// stacks: the stages before it already deployed their dependencies.
+func runDeployRollout(
+	ctx context.Context, cdkExec cmdexec.Executor, output io.Writer, baseArgs []string, hotswap bool,
+	concurrency int, stages []deployStage,
+) error {
+	for i, stage := range stages {
+		writeOutputf(output, "\n[%d/%d] Deploying %s in %s...\n", i+1, len(stages),
+			strings.Join(stage.Stacks, ", "), stage.Region)
+
+		if err := runCDKCommand(ctx, cdkExec, "deploy", deployStageArgs(baseArgs, stage, hotswap,
+			concurrency)); err != nil {
+			return errors.Wrapf(err, "deploy of %s in %s failed, later stages were not deployed",
+				strings.Join(stage.Stacks, ", "), stage.Region)
+		}
+	}
+	return nil
+}
+
+// deployStageArgs returns the cdk deploy arguments of a stage. A stage with several stacks deploys
+// up to concurrency of them at the same time within a single cdk process, which publishes the
+// assets they share once.
+func deployStageArgs(baseArgs []string, stage deployStage, hotswap bool, concurrency int) []string {
+	args := slices.Clone(baseArgs)
+	args = append(args, stage.Stacks...)
+	args = append(args, "--exclusively", "--require-approval", "never")
+	if concurrency > 1 && len(stage.Stacks) > 1 {
+		args = append(args, "--concurrency", strconv.Itoa(min(concurrency, len(stage.Stacks))))
+	}
+	if hotswap {
+		args = append(args, "--hotswap")
+	}
+	return args
+}
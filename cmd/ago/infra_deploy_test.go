@@ -0,0 +1,158 @@
+package main
+
+import (
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestAllowedDeployments(t *testing.T) {
+	t.Parallel()
+
+	deployments := []string{"Dev", "DevAdam", "Stag", "Prod"}
+
+	if got := allowedDeployments(deployments, true); !slices.Equal(got, deployments) {
+		t.Errorf("full deployer: got %v, want %v", got, deployments)
+	}
+	if got, want := allowedDeployments(deployments, false), []string{"Dev", "DevAdam"}; !slices.Equal(got, want) {
+		t.Errorf("dev deployer: got %v, want %v", got, want)
+	}
+	if len(deployments) != 4 {
+		t.Errorf("input was modified: %v", deployments)
+	}
+}
+
+func TestDeployRollout(t *testing.T) {
+	t.Parallel()
+
+	got := deployRollout("myapp", []string{"eu-central-1", "us-east-1"}, []string{"Dev", "Prod"})
+	want := []deployStage{
+		{Region: "eu-central-1", Stacks: []string{"myappEuc1Shared"}},
+		{Region: "eu-central-1", Stacks: []string{"myappEuc1Dev", "myappEuc1Prod"}},
+		{Region: "us-east-1", Stacks: []string{"myappUse1Shared"}},
+		{Region: "us-east-1", Stacks: []string{"myappUse1Dev", "myappUse1Prod"}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+
+	got = deployRollout("myapp", []string{"us-east-1"}, nil)
+	want = []deployStage{{Region: "us-east-1", Stacks: []string{"myappUse1Shared"}}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("without deployments: got %+v, want %+v", got, want)
+	}
+}
+
+func TestDiffTemplates(t *testing.T) {
+	t.Parallel()
+
+	deployed := map[string]any{"Resources": map[string]any{
+		"Bucket": map[string]any{"Type": "AWS::S3::Bucket", "Metadata": map[string]any{"aws:cdk:path": "a"}},
+		"Table":  map[string]any{"Type": "AWS::DynamoDB::Table", "Properties": map[string]any{"BillingMode": "PROVISIONED"}},
+		"Topic":  map[string]any{"Type": "AWS::SNS::Topic"},
+		"Store":  map[string]any{"Type": "AWS::SSM::Parameter"},
+	}}
+	synthesized := map[string]any{"Resources": map[string]any{
+		"Bucket": map[string]any{"Type": "AWS::S3::Bucket", "Metadata": map[string]any{"aws:cdk:path": "b"}},
+		"Table":  map[string]any{"Type": "AWS::DynamoDB::Table", "Properties": map[string]any{"BillingMode": "PAY_PER_REQUEST"}},
+		"Store":  map[string]any{"Type": "AWS::SecretsManager::Secret"},
+		"Queue":  map[string]any{"Type": "AWS::SQS::Queue"},
+	}}
+
+	stack := stackRef{Name: "myappUse1Dev", Region: "us-east-1"}
+	got := diffTemplates(stack, deployed, synthesized)
+	want := stackDiff{Stack: stack, Added: 2, Changed: 1, Destroyed: 2}
+	if got != want {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+	if !got.Destructive() {
+		t.Error("expected diff to be destructive")
+	}
+
+	if got := diffTemplates(stack, map[string]any{}, synthesized); got.Added != 4 || got.Destructive() {
+		t.Errorf("new stack: got %+v", got)
+	}
+}
+
+func TestSelectDeployments(t *testing.T) {
+	t.Parallel()
+
+	known := []string{"Dev", "DevAdam", "DevEve", "Stag", "Prod"}
+
+	tests := []struct {
+		name         string
+		names        []string
+		allDev       bool
+		fullDeployer bool
+		want         []string
+		wantErr      string
+	}{
+		{name: "all dev", allDev: true, fullDeployer: true, want: []string{"Dev", "DevAdam", "DevEve"}},
+		{name: "all dev dev deployer", allDev: true, wantErr: "--all-dev requires full deployer permissions"},
+		{name: "named", names: []string{"DevEve", "DevAdam", "DevEve"}, fullDeployer: true,
+			want: []string{"DevEve", "DevAdam"}},
+		{name: "own", names: []string{"DevAdam"}, want: []string{"DevAdam"}},
+		{name: "other dev deployer", names: []string{"DevAdam", "DevEve"},
+			wantErr: `dev deployers may only deploy "DevAdam"`},
+		{name: "unknown", names: []string{"DevBob"}, wantErr: `deployment "DevBob" not found`},
+		{name: "restricted", names: []string{"DevAdam", "Prod"}, wantErr: "requires full deployer permissions"},
+		{name: "restricted full deployer", names: []string{"Prod", "Stag"}, fullDeployer: true,
+			want: []string{"Prod", "Stag"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := selectDeployments(known, tt.names, tt.allDev, tt.fullDeployer, "Adam")
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("expected error containing %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDeployStageArgs(t *testing.T) {
+	t.Parallel()
+
+	base := []string{"--profile", "dev"}
+	shared := deployStage{Region: "us-east-1", Stacks: []string{"myappUse1Shared"}}
+	devs := deployStage{Region: "us-east-1", Stacks: []string{"myappUse1DevAdam", "myappUse1DevEve"}}
+
+	tests := []struct {
+		name        string
+		stage       deployStage
+		hotswap     bool
+		concurrency int
+		want        []string
+	}{
+		{name: "shared stack is never concurrent", stage: shared, concurrency: 4,
+			want: []string{"--profile", "dev", "myappUse1Shared", "--exclusively", "--require-approval", "never"}},
+		{name: "bounded by stacks", stage: devs, concurrency: 4, hotswap: true,
+			want: []string{"--profile", "dev", "myappUse1DevAdam", "myappUse1DevEve", "--exclusively",
+				"--require-approval", "never", "--concurrency", "2", "--hotswap"}},
+		{name: "serial", stage: devs, concurrency: 1,
+			want: []string{"--profile", "dev", "myappUse1DevAdam", "myappUse1DevEve", "--exclusively",
+				"--require-approval", "never"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := deployStageArgs(base, tt.stage, tt.hotswap, tt.concurrency); !slices.Equal(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
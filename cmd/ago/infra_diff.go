@@ -0,0 +1,184 @@
+package main
+
+import (
+	"context"
+	"io"
+	"maps"
+	"os"
+	"reflect"
+	"slices"
+
+	"github.com/advdv/ago/cmd/ago/internal/awsapi"
+	"github.com/advdv/ago/cmd/ago/internal/config"
+	"github.com/cockroachdb/errors"
+	"github.com/urfave/cli/v3"
+)
+
+func infraDiffCmd() *cli.Command {
+	return &cli.Command{
+		Name: "diff",
+		Usage: "Summarize the changes of every stack the caller may deploy, across all deployments " +
+			"and regions",
+		Flags: []cli.Flag{
+			&cli.BoolFlag{
+				Name:  "fail-on-destructive",
+				Usage: "Exit non-zero when a stack would destroy or replace resources",
+			},
+		},
+		Action: config.RunWithConfig(runInfraDiff),
+	}
+}
+
+type infraDiffOptions struct {
+	Profile           string
+	FailOnDestructive bool
+	Output            io.Writer
+	ErrOut            io.Writer
+}
+
+func runInfraDiff(ctx context.Context, cmd *cli.Command, cfg config.Config) error {
+	return doInfraDiff(ctx, cfg, infraDiffOptions{
+		Profile:           cmd.String("profile"),
+		FailOnDestructive: cmd.Bool("fail-on-destructive"),
+		Output:            os.Stdout,
+		ErrOut:            os.Stderr,
+	})
+}
+
+func doInfraDiff(ctx context.Context, cfg config.Config, opts infraDiffOptions) error {
+	cdk, err := loadCDKContext(cfg)
+	if err != nil {
+		return err
+	}
+
+	cdkExec := cdk.CDKExec.WithOutput(opts.ErrOut, opts.ErrOut)
+
+	username, err := resolveCDKCallerUsername(ctx, opts.Profile, cdk.Context)
+	if err != nil {
+		return errors.Wrap(err, "failed to detect username")
+	}
+
+	profile := resolveCDKProfile(opts.Profile, cdk.Context, username)
+
+	userGroups, err := getUserGroups(ctx, profile, username)
+	if err != nil {
+		return err
+	}
+
+	if err := cdk.Require("primary-region"); err != nil {
+		return err
+	}
+	regions := cdk.Regions()
+	deployments := allowedDeployments(cdk.Deployments,
+		isFullDeployer(userGroups, cdk.Qualifier))
+
+	outDir, err := synthAssembly(ctx, cdk, cdkExec, opts.ErrOut,
+		buildCDKArgs(profile, cdk.Qualifier, cdk.Prefix, userGroups))
+	if err != nil {
+		return err
+	}
+
+	var diffs []stackDiff
+	for _, stack := range deployStacks(cdk.Qualifier, regions, deployments) {
+		synthesized, err := readSynthesizedTemplate(outDir, stack.Name)
+		if err != nil {
+			return err
+		}
+		clients, err := awsapi.New(ctx, profile, stack.Region)
+		if err != nil {
+			return err
+		}
+		deployed, err := getDeployedTemplate(ctx, clients, stack.Name)
+		if err != nil {
+			return err
+		}
+		diffs = append(diffs, diffTemplates(stack, deployed, synthesized))
+	}
+
+	destructive := printStackDiffs(opts.Output, diffs)
+	if opts.FailOnDestructive && destructive > 0 {
+		return errors.Errorf("%d stack(s) would destroy or replace resources", destructive)
+	}
+
+	return nil
+}
+
+// stackDiff counts the resources that deploying a stack would add, change and destroy. A
+// resource whose type changes is replaced, so it counts as both destroyed and added.
+type stackDiff struct {
+	Stack     stackRef
+	Added     int
+	Changed   int
+	Destroyed int
+}
+
+// Destructive reports whether deploying the stack would destroy resources.
+func (d stackDiff) Destructive() bool {
+	return d.Destroyed > 0
+}
+
+// diffTemplates compares the resources of the deployed and synthesized templates of a stack by
+// logical ID. Metadata is ignored, since CDK updates it without changing the resource.
+func diffTemplates(stack stackRef, deployed, synthesized map[string]any) stackDiff {
+	before, _ := deployed["Resources"].(map[string]any)
+	after, _ := synthesized["Resources"].(map[string]any)
+
+	diff := stackDiff{Stack: stack}
+	for id, resource := range after {
+		old, ok := before[id]
+		switch {
+		case !ok:
+			diff.Added++
+		case resourceType(old) != resourceType(resource):
+			diff.Destroyed++
+			diff.Added++
+		case !reflect.DeepEqual(withoutMetadata(old), withoutMetadata(resource)):
+			diff.Changed++
+		}
+	}
+	for id := range before {
+		if _, ok := after[id]; !ok {
+			diff.Destroyed++
+		}
+	}
+	return diff
+}
+
+func resourceType(resource any) string {
+	r, _ := resource.(map[string]any)
+	typ, _ := r["Type"].(string)
+	return typ
+}
+
+func withoutMetadata(resource any) map[string]any {
+	r, _ := resource.(map[string]any)
+	clone := maps.Clone(r)
+	delete(clone, "Metadata")
+	return clone
+}
+
+// printStackDiffs prints a table of the stack diffs and returns the number of destructive ones.
+func printStackDiffs(w io.Writer, diffs []stackDiff) int {
+	writeOutputf(w, "%-40s %-16s %7s %7s %9s\n", "STACK", "REGION", "ADDED", "CHANGED", "DESTROYED")
+
+	destructive := 0
+	for _, d := range diffs {
+		marker := ""
+		if d.Destructive() {
+			marker = "  !"
+			destructive++
+		}
+		writeOutputf(w, "%-40s %-16s %7d %7d %9d%s\n", d.Stack.Name, d.Stack.Region,
+			d.Added, d.Changed, d.Destroyed, marker)
+	}
+
+	unchanged := slices.IndexFunc(diffs, func(d stackDiff) bool { return d.Added+d.Changed+d.Destroyed > 0 }) < 0
+	switch {
+	case unchanged:
+		writeOutputf(w, "\nNo changes.\n")
+	case destructive > 0:
+		writeOutputf(w, "\n%d stack(s) would destroy or replace resources (marked with !).\n", destructive)
+	}
+
+	return destructive
+}
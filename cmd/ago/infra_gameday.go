@@ -0,0 +1,434 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/advdv/ago/agcdkutil"
+	"github.com/advdv/ago/cmd/ago/internal/awsapi"
+	"github.com/advdv/ago/cmd/ago/internal/config"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/route53"
+	route53types "github.com/aws/aws-sdk-go-v2/service/route53/types"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	"github.com/cockroachdb/errors"
+	"github.com/urfave/cli/v3"
+)
+
+// gamedayPollInterval is how often the DNS answers are checked while waiting for traffic to shift.
+const gamedayPollInterval = 10 * time.Second
+
+func gamedayCmd() *cli.Command {
+	return &cli.Command{
+		Name:  "gameday",
+		Usage: "Temporarily take a secondary region out of DNS routing and verify that traffic shifts",
+		Description: "Finds the weighted, latency and failover records of a deployment that route to the region, " +
+			"inverts their Route53 health checks or sets their weight to 0, and waits until Route53 no longer " +
+			"answers a client in the region with the region's values. The original routing is restored " +
+			"afterwards, also on failure or interrupt.",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:     "deployment",
+				Usage:    "Deployment whose records are rerouted",
+				Required: true,
+			},
+			&cli.StringFlag{
+				Name:     "disable-region",
+				Usage:    "Secondary region to take out of routing",
+				Required: true,
+			},
+			&cli.StringSliceFlag{
+				Name:  "record",
+				Usage: "Record name to reroute (repeatable, defaults to records with a label equal to the deployment)",
+			},
+			&cli.DurationFlag{
+				Name:  "timeout",
+				Usage: "How long to wait for traffic to shift",
+				Value: 5 * time.Minute,
+			},
+			&cli.DurationFlag{
+				Name:  "hold",
+				Usage: "How long to keep the region out of routing after traffic shifted",
+				Value: 5 * time.Minute,
+			},
+			&cli.BoolFlag{
+				Name:  "yes",
+				Usage: "Run against restricted deployments without asking for confirmation",
+			},
+		},
+		Action: config.RunWithConfig(runGameday),
+	}
+}
+
+type gamedayOptions struct {
+	Deployment    string
+	DisableRegion string
+	Records       []string
+	Profile       string
+	Timeout       time.Duration
+	Hold          time.Duration
+	Yes           bool
+	Output        io.Writer
+}
+
+func runGameday(ctx context.Context, cmd *cli.Command, cfg config.Config) error {
+	return doGameday(ctx, cfg, gamedayOptions{
+		Deployment:    cmd.String("deployment"),
+		DisableRegion: cmd.String("disable-region"),
+		Records:       cmd.StringSlice("record"),
+		Profile:       cmd.String("profile"),
+		Timeout:       cmd.Duration("timeout"),
+		Hold:          cmd.Duration("hold"),
+		Yes:           cmd.Bool("yes"),
+		Output:        os.Stdout,
+	})
+}
+
+// Kinds of gameday actions.
+const (
+	gamedayInvertHealthCheck = "invert health check"
+	gamedayZeroWeight        = "set weight to 0"
+)
+
+// gamedayTarget is a record set that routes to the disabled region, with the action that takes
+// it out of routing.
+type gamedayTarget struct {
+	Action string
+	Record route53types.ResourceRecordSet
+}
+
+func (t gamedayTarget) name() string          { return aws.ToString(t.Record.Name) }
+func (t gamedayTarget) recordType() string    { return string(t.Record.Type) }
+func (t gamedayTarget) setIdentifier() string { return aws.ToString(t.Record.SetIdentifier) }
+func (t gamedayTarget) healthCheckID() string { return aws.ToString(t.Record.HealthCheckId) }
+
+//nolint:cyclop // sequential steps of the gameday, each with its own error handling
+func doGameday(ctx context.Context, cfg config.Config, opts gamedayOptions) error {
+	// Cancel on interrupt instead of exiting, so that the original routing is restored.
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	cdk, err := loadCDKContext(cfg)
+	if err != nil {
+		return err
+	}
+
+	deployments := cdk.Deployments
+	if !slices.Contains(deployments, opts.Deployment) {
+		return errors.Errorf("deployment %q not found\n\nAvailable deployments: %s",
+			opts.Deployment, formatDeploymentsList(deployments))
+	}
+
+	secondaryRegions := cdk.SecondaryRegions
+	if !slices.Contains(secondaryRegions, opts.DisableRegion) {
+		return errors.Errorf("region %q is not a secondary region (secondary regions: %s)",
+			opts.DisableRegion, strings.Join(secondaryRegions, ", "))
+	}
+
+	if err := cdk.Require("primary-region"); err != nil {
+		return err
+	}
+	primaryRegion := cdk.PrimaryRegion
+
+	profile, err := resolveAWSProfile(opts.Profile, func() (string, error) { return getCDKProfile(cdk.Context) })
+	if err != nil {
+		return err
+	}
+
+	if isRestrictedDeployment(opts.Deployment) && !opts.Yes && isTerminal(os.Stdin) {
+		confirmed, err := confirmPrompt("Take " + opts.DisableRegion + " out of routing for " + opts.Deployment + "?")
+		if err != nil {
+			return err
+		}
+		if !confirmed {
+			return errors.Errorf("gameday for %s cancelled", opts.Deployment)
+		}
+	}
+
+	clients, err := awsapi.New(ctx, profile, primaryRegion)
+	if err != nil {
+		return err
+	}
+	zone, err := clients.SSM.GetParameter(ctx, &ssm.GetParameterInput{
+		Name: aws.String("/" + cdk.Qualifier + "/dns/hosted-zone-id"),
+	})
+	if err != nil {
+		return errors.Wrap(err, "failed to look up the hosted zone ID")
+	}
+	if zone.Parameter == nil {
+		return errors.New("hosted zone ID not found")
+	}
+	zoneID := aws.ToString(zone.Parameter.Value)
+
+	records, err := listRecordSets(ctx, clients, zoneID)
+	if err != nil {
+		return err
+	}
+
+	targets := selectGamedayTargets(records, opts.Deployment, opts.DisableRegion,
+		agcdkutil.RegionIdentFor(opts.DisableRegion), opts.Records)
+	if len(targets) == 0 {
+		return errors.Errorf("no weighted, latency or failover records of %s route to %s",
+			opts.Deployment, opts.DisableRegion)
+	}
+
+	// Route53 is asked as if by a client in the disabled region, which latency records send to
+	// that region for as long as it is in routing.
+	clientIP, err := regionAddress(ctx, opts.DisableRegion)
+	if err != nil {
+		return err
+	}
+	disabled, err := gamedayDisabledValues(ctx, clients, zoneID, clientIP, targets)
+	if err != nil {
+		return err
+	}
+
+	var applied []gamedayTarget
+	defer func() {
+		// Restore even when the context was cancelled, e.g. by an interrupt.
+		restoreCtx := context.WithoutCancel(ctx)
+		for _, target := range slices.Backward(applied) {
+			writeOutputf(opts.Output, "Restoring %s (%s)...\n", target.name(), target.setIdentifier())
+			if err := applyGamedayTarget(restoreCtx, clients, zoneID, target, true); err != nil {
+				writeOutputf(opts.Output, "Warning: failed to restore %s: %v\n", target.name(), err)
+			}
+		}
+	}()
+
+	for _, target := range targets {
+		writeOutputf(opts.Output, "%s: %s (%s)\n", target.Action, target.name(), target.setIdentifier())
+		if err := applyGamedayTarget(ctx, clients, zoneID, target, false); err != nil {
+			return err
+		}
+		applied = append(applied, target)
+	}
+
+	writeOutputf(opts.Output, "Waiting for traffic to shift away from %s...\n", opts.DisableRegion)
+	deadline := time.Now().Add(opts.Timeout)
+	for {
+		shifted, err := gamedayTrafficShifted(ctx, clients, zoneID, clientIP, disabled)
+		if err != nil {
+			return err
+		}
+		if shifted {
+			break
+		}
+		if time.Now().After(deadline) {
+			return errors.Errorf("traffic did not shift away from %s within %s", opts.DisableRegion, opts.Timeout)
+		}
+		select {
+		case <-ctx.Done():
+			return errors.Wrap(ctx.Err(), "gameday interrupted")
+		case <-time.After(gamedayPollInterval):
+		}
+	}
+
+	writeOutputf(opts.Output, "Traffic shifted away from %s. Holding for %s...\n", opts.DisableRegion, opts.Hold)
+	select {
+	case <-ctx.Done():
+		return errors.Wrap(ctx.Err(), "gameday interrupted")
+	case <-time.After(opts.Hold):
+	}
+
+	return nil
+}
+
+// selectGamedayTargets returns the records with a routing policy that send traffic to the region,
+// either by their latency region or by a set identifier equal to the region or its ident. Unless
+// names are given, only records with a label equal to the deployment are considered.
+func selectGamedayTargets(
+	records []route53types.ResourceRecordSet, deployment, region, regionIdent string, names []string,
+) []gamedayTarget {
+	var targets []gamedayTarget
+	for _, record := range records {
+		target := gamedayTarget{Record: record}
+
+		setIdentifier := target.setIdentifier()
+		if setIdentifier == "" {
+			continue
+		}
+
+		name := strings.ToLower(strings.TrimSuffix(target.name(), "."))
+		if len(names) > 0 {
+			if !slices.ContainsFunc(names, func(n string) bool {
+				return strings.EqualFold(strings.TrimSuffix(n, "."), name)
+			}) {
+				continue
+			}
+		} else if !slices.Contains(strings.Split(name, "."), strings.ToLower(deployment)) {
+			continue
+		}
+
+		if string(record.Region) != region && !strings.EqualFold(setIdentifier, region) &&
+			!strings.EqualFold(setIdentifier, regionIdent) {
+			continue
+		}
+
+		switch {
+		case target.healthCheckID() != "":
+			target.Action = gamedayInvertHealthCheck
+		case record.Weight != nil:
+			target.Action = gamedayZeroWeight
+		default:
+			continue
+		}
+
+		targets = append(targets, target)
+	}
+	return targets
+}
+
+func applyGamedayTarget(
+	ctx context.Context, clients *awsapi.Clients, zoneID string, target gamedayTarget, restore bool,
+) error {
+	switch target.Action {
+	case gamedayInvertHealthCheck:
+		if _, err := clients.Route53.UpdateHealthCheck(ctx, &route53.UpdateHealthCheckInput{
+			HealthCheckId: aws.String(target.healthCheckID()),
+			Inverted:      aws.Bool(!restore),
+		}); err != nil {
+			return errors.Wrapf(err, "failed to update health check %q", target.healthCheckID())
+		}
+
+	case gamedayZeroWeight:
+		record := target.Record
+		if !restore {
+			record.Weight = aws.Int64(0)
+		}
+		if _, err := clients.Route53.ChangeResourceRecordSets(ctx, &route53.ChangeResourceRecordSetsInput{
+			HostedZoneId: aws.String(zoneID),
+			ChangeBatch: &route53types.ChangeBatch{Changes: []route53types.Change{{
+				Action:            route53types.ChangeActionUpsert,
+				ResourceRecordSet: &record,
+			}}},
+		}); err != nil {
+			return errors.Wrapf(err, "failed to change weight of %q", target.name())
+		}
+	}
+
+	return nil
+}
+
+// gamedayRecord identifies the records with one name and type, which Route53 answers together.
+type gamedayRecord struct {
+	Name string
+	Type string
+}
+
+// gamedayDisabledValues returns the values that the targets answer with, by record. Alias records
+// have no values of their own, so for those the answer that a client in the disabled region gets
+// before the gameday is taken instead.
+func gamedayDisabledValues(
+	ctx context.Context, clients *awsapi.Clients, zoneID, clientIP string, targets []gamedayTarget,
+) (map[gamedayRecord][]string, error) {
+	disabled := map[gamedayRecord][]string{}
+	for _, target := range targets {
+		record := gamedayRecord{Name: target.name(), Type: target.recordType()}
+		values := target.values()
+		if len(values) == 0 {
+			answer, err := testDNSAnswer(ctx, clients, zoneID, clientIP, record.Name, record.Type)
+			if err != nil {
+				return nil, err
+			}
+			values = answer
+		}
+		disabled[record] = append(disabled[record], values...)
+	}
+	return disabled, nil
+}
+
+// values returns the values of a record that is not an alias.
+func (t gamedayTarget) values() []string {
+	values := make([]string, 0, len(t.Record.ResourceRecords))
+	for _, rr := range t.Record.ResourceRecords {
+		if value := aws.ToString(rr.Value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+// gamedayTrafficShifted reports whether Route53 answers every rerouted record, for a client in the
+// disabled region, without the values of the disabled region. Only the disabled values count: the
+// answer from before the gameday may not have included them, e.g. for weighted records.
+func gamedayTrafficShifted(
+	ctx context.Context, clients *awsapi.Clients, zoneID, clientIP string, disabled map[gamedayRecord][]string,
+) (bool, error) {
+	for record, values := range disabled {
+		answer, err := testDNSAnswer(ctx, clients, zoneID, clientIP, record.Name, record.Type)
+		if err != nil {
+			return false, err
+		}
+		if !trafficShiftedFrom(answer, values) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// trafficShiftedFrom reports whether an answer routes somewhere, but not to any of the disabled
+// values.
+func trafficShiftedFrom(answer, disabled []string) bool {
+	return len(answer) > 0 && !slices.ContainsFunc(answer, func(value string) bool {
+		return slices.Contains(disabled, value)
+	})
+}
+
+// regionAddress returns an IPv4 address located in the region, that of its EC2 endpoint, so that
+// Route53 answers test queries as it answers the clients in that region.
+func regionAddress(ctx context.Context, region string) (string, error) {
+	host := "ec2." + region + "." + agcdkutil.PartitionDNSSuffix(agcdkutil.PartitionFor(region))
+	addrs, err := net.DefaultResolver.LookupIP(ctx, "ip4", host)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to resolve an address in %s", region)
+	}
+	if len(addrs) == 0 {
+		return "", errors.Errorf("no address found in %s", region)
+	}
+	return addrs[0].String(), nil
+}
+
+// testDNSAnswer returns the values that Route53 answers for a record to a client with the given
+// address, which is used both as the resolver and as the EDNS0 client subnet.
+func testDNSAnswer(
+	ctx context.Context, clients *awsapi.Clients, zoneID, clientIP, name, recordType string,
+) ([]string, error) {
+	out, err := clients.Route53.TestDNSAnswer(ctx, &route53.TestDNSAnswerInput{
+		HostedZoneId:        aws.String(zoneID),
+		RecordName:          aws.String(name),
+		RecordType:          route53types.RRType(recordType),
+		ResolverIP:          aws.String(clientIP),
+		EDNS0ClientSubnetIP: aws.String(clientIP),
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to test DNS answer for %q", name)
+	}
+
+	answer := slices.Clone(out.RecordData)
+	slices.Sort(answer)
+	return answer, nil
+}
+
+func listRecordSets(
+	ctx context.Context, clients *awsapi.Clients, zoneID string,
+) ([]route53types.ResourceRecordSet, error) {
+	var records []route53types.ResourceRecordSet
+	pages := route53.NewListResourceRecordSetsPaginator(clients.Route53, &route53.ListResourceRecordSetsInput{
+		HostedZoneId: aws.String(zoneID),
+	})
+	for pages.HasMorePages() {
+		page, err := pages.NextPage(ctx)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to list records in hosted zone %q", zoneID)
+		}
+		records = append(records, page.ResourceRecordSets...)
+	}
+	return records, nil
+}
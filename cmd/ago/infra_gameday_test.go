@@ -0,0 +1,105 @@
+package main
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	route53types "github.com/aws/aws-sdk-go-v2/service/route53/types"
+)
+
+func TestSelectGamedayTargets(t *testing.T) {
+	t.Parallel()
+
+	record := func(name string, recordType route53types.RRType, setIdentifier string) route53types.ResourceRecordSet {
+		r := route53types.ResourceRecordSet{Name: aws.String(name), Type: recordType}
+		if setIdentifier != "" {
+			r.SetIdentifier = aws.String(setIdentifier)
+		}
+		return r
+	}
+	weighted := func(r route53types.ResourceRecordSet, weight int64, healthCheckID string) route53types.ResourceRecordSet {
+		r.Weight = aws.Int64(weight)
+		if healthCheckID != "" {
+			r.HealthCheckId = aws.String(healthCheckID)
+		}
+		return r
+	}
+	latency := func(r route53types.ResourceRecordSet, healthCheckID string) route53types.ResourceRecordSet {
+		r.Region = route53types.ResourceRecordSetRegionEuNorth1
+		if healthCheckID != "" {
+			r.HealthCheckId = aws.String(healthCheckID)
+		}
+		return r
+	}
+
+	records := []route53types.ResourceRecordSet{
+		weighted(record("api.stag.example.com.", route53types.RRTypeA, "eun1"), 50, "hc-1"),
+		weighted(record("api.stag.example.com.", route53types.RRTypeA, "use1"), 50, "hc-2"),
+		weighted(record("web.stag.example.com.", route53types.RRTypeA, "eu-north-1"), 10, ""),
+		latency(record("app.stag.example.com.", route53types.RRTypeCname, "north"), "hc-3"),
+		latency(record("latency.stag.example.com.", route53types.RRTypeA, "north"), ""),
+		weighted(record("api.prod.example.com.", route53types.RRTypeA, "eun1"), 50, ""),
+		record("stag.example.com.", route53types.RRTypeA, ""),
+	}
+
+	tests := []struct {
+		name  string
+		names []string
+		want  []string
+	}{
+		{
+			name: "records of the deployment",
+			want: []string{
+				"api.stag.example.com. " + gamedayInvertHealthCheck,
+				"web.stag.example.com. " + gamedayZeroWeight,
+				"app.stag.example.com. " + gamedayInvertHealthCheck,
+			},
+		},
+		{
+			name:  "explicit record names",
+			names: []string{"web.stag.example.com", "api.prod.example.com."},
+			want: []string{
+				"web.stag.example.com. " + gamedayZeroWeight,
+				"api.prod.example.com. " + gamedayZeroWeight,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var got []string
+			for _, target := range selectGamedayTargets(records, "Stag", "eu-north-1", "eun1", tt.names) {
+				got = append(got, target.name()+" "+target.Action)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("selectGamedayTargets() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTrafficShiftedFrom(t *testing.T) {
+	t.Parallel()
+
+	disabled := []string{"10.0.1.1", "10.0.1.2"}
+	tests := []struct {
+		name   string
+		answer []string
+		want   bool
+	}{
+		{name: "only other regions", answer: []string{"10.0.2.1"}, want: true},
+		{name: "still a disabled value", answer: []string{"10.0.1.2", "10.0.2.1"}, want: false},
+		{name: "no answer", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if got := trafficShiftedFrom(tt.answer, disabled); got != tt.want {
+				t.Errorf("trafficShiftedFrom(%v) = %v, want %v", tt.answer, got, tt.want)
+			}
+		})
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"io"
 	"os"
+	"path/filepath"
 
 	"github.com/advdv/ago/cmd/ago/internal/cmdexec"
 	"github.com/advdv/ago/cmd/ago/internal/config"
@@ -43,7 +44,7 @@ type tfApplyOptions struct {
 }
 
 func doTFApply(ctx context.Context, cfg config.Config, opts tfApplyOptions) error {
-	exec := cmdexec.New(cfg).InSubdir("infra/tf").WithOutput(opts.Output, opts.Output)
+	exec := cmdexec.New(cfg).InSubdir(filepath.Join(cfg.Inner.Layout.Infra(), "tf")).WithOutput(opts.Output, opts.Output)
 
 	args := []string{"apply"}
 	if opts.AutoApprove {
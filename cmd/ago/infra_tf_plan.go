@@ -4,6 +4,7 @@ import (
 	"context"
 	"io"
 	"os"
+	"path/filepath"
 
 	"github.com/advdv/ago/cmd/ago/internal/cmdexec"
 	"github.com/advdv/ago/cmd/ago/internal/config"
@@ -37,7 +38,7 @@ type tfPlanOptions struct {
 }
 
 func doTFPlan(ctx context.Context, cfg config.Config, opts tfPlanOptions) error {
-	exec := cmdexec.New(cfg).InSubdir("infra/tf").WithOutput(opts.Output, opts.Output)
+	exec := cmdexec.New(cfg).InSubdir(filepath.Join(cfg.Inner.Layout.Infra(), "tf")).WithOutput(opts.Output, opts.Output)
 
 	args := []string{"plan"}
 	if opts.Destroy {
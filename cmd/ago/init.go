@@ -9,9 +9,11 @@ import (
 	"strings"
 	"text/template"
 
+	"github.com/advdv/ago/agcdkutil"
 	"github.com/advdv/ago/cmd/ago/internal/cmdexec"
 	"github.com/advdv/ago/cmd/ago/internal/config"
 	"github.com/advdv/ago/cmd/ago/internal/initwizard"
+	"github.com/advdv/ago/cmd/ago/internal/ops"
 	"github.com/cockroachdb/errors"
 	"github.com/urfave/cli/v3"
 )
@@ -28,6 +30,9 @@ shellcheck = "{{.ShellcheckVersion}}"
 shfmt = "{{.ShfmtVersion}}"
 depot = "{{.DepotVersion}}"
 "github:advdv/ago" = "{{.AgoVersion}}"
+{{- range .ExtraTools}}
+"{{.Name}}" = "{{.Version}}"
+{{- end}}
 `))
 
 var cdkMainTemplate = template.Must(template.New("cdk.go").Parse(`package main
@@ -175,6 +180,21 @@ go.work.sum
 var backendDockerfileTemplate = template.Must(template.New("Dockerfile").Parse(`# syntax=docker/dockerfile:1
 # Based on: https://depot.dev/docs/container-builds/optimal-dockerfiles/go-dockerfile
 # Reproducible builds: https://go.dev/blog/rebuild
+{{- if .BaseImage}}
+# BASE_IMAGE is passed by 'ago backend build-and-push', pinned by 'ago backend bump-base'.
+ARG BASE_IMAGE={{.RuntimeImage}}
+{{- end}}
+{{- if .BufVersion}}
+FROM golang:{{.GoVersion}} AS proto
+
+COPY --from=bufbuild/buf:{{.BufVersion}} /usr/local/bin/buf /usr/local/bin/buf
+
+WORKDIR /src
+
+COPY buf.yaml buf.gen.yaml ./
+COPY proto ./proto/
+RUN buf generate
+{{ end}}
 FROM golang:{{.GoVersion}} AS build
 
 WORKDIR /src
@@ -193,6 +213,9 @@ RUN --mount=type=cache,target=/go/pkg/mod \
     fi
 
 COPY . .
+{{- if .BufVersion}}
+COPY --from=proto /src/gen ./gen/
+{{- end}}
 
 ARG CMD_NAME=coreapi
 RUN --mount=type=cache,target=/go/pkg/mod \
@@ -203,7 +226,7 @@ RUN --mount=type=cache,target=/go/pkg/mod \
     -o /bin/app \
     ./cmd/${CMD_NAME}
 
-FROM ubuntu:24.04 AS runtime
+FROM {{if .BaseImage}}${BASE_IMAGE}{{else}}{{.RuntimeImage}}{{end}} AS runtime
 
 COPY --from=build --chown=1001:1001 /bin/app /usr/local/bin/app
 
@@ -227,6 +250,11 @@ var backendDockerignoreTemplate = template.Must(template.New(".dockerignore").Pa
 
 # Allow vendor directory if present
 !vendor/
+
+# Allow protobuf sources for the proto stage
+!buf.yaml
+!buf.gen.yaml
+!proto/
 `))
 
 var backendDepotJSONTemplate = template.Must(template.New("depot.json").Parse(`{
@@ -423,8 +451,20 @@ type BackendConfig struct {
 	ModuleName     string
 	GoVersion      string
 	DepotProjectID string
+	// RuntimeImage is the base image of the final Dockerfile stage.
+	RuntimeImage string
+	// BaseImage makes the final stage build on the BASE_IMAGE build argument, with RuntimeImage
+	// as its default.
+	BaseImage bool
+	// BufVersion adds a stage that generates the protobuf code of backend/proto with this
+	// version of buf, for Connect services. Empty means the backend has no protobuf sources.
+	BufVersion string
 }
 
+// defaultBackendRuntimeImage is a distroless base that contains CA certificates and tzdata but
+// no shell or package manager, which suits statically linked Go binaries.
+const defaultBackendRuntimeImage = "gcr.io/distroless/static-debian12"
+
 func DefaultCDKConfigFromDir(dir string) CDKConfig {
 	name := filepath.Base(dir)
 	return CDKConfig{
@@ -445,6 +485,7 @@ func DefaultBackendConfigFromDir(dir string) BackendConfig {
 		ModuleName:     "github.com/example/" + name,
 		GoVersion:      "1.25",
 		DepotProjectID: "",
+		RuntimeImage:   defaultBackendRuntimeImage,
 	}
 }
 
@@ -477,8 +518,45 @@ type MiseConfig struct {
 	ShfmtVersion        string
 	DepotVersion        string
 	AgoVersion          string
+	// ExtraTools are the tools that the project template adds to the base tools.
+	ExtraTools []MiseTool
+}
+
+// toolRefs returns the name and version of every tool in the config, in mise.toml order. The
+// versions point into the config, so that they can be pinned in place.
+func (c *MiseConfig) toolRefs() []miseToolRef {
+	refs := []miseToolRef{
+		{"go", &c.GoVersion},
+		{"node", &c.NodeVersion},
+		{"npm:aws-cdk", &c.AwsCdkVersion},
+		{"aws-cli", &c.AwsCliVersion},
+		{"amp", &c.AmpVersion},
+		{"granted", &c.GrantedVersion},
+		{"golangci-lint", &c.GolangciLintVersion},
+		{"shellcheck", &c.ShellcheckVersion},
+		{"shfmt", &c.ShfmtVersion},
+		{"depot", &c.DepotVersion},
+		{"github:advdv/ago", &c.AgoVersion},
+	}
+	for i := range c.ExtraTools {
+		refs = append(refs, miseToolRef{c.ExtraTools[i].Name, &c.ExtraTools[i].Version})
+	}
+	return refs
+}
+
+type miseToolRef struct {
+	name    string
+	version *string
 }
 
+// MiseTool is a tool in mise.toml by its mise name, such as "pnpm" or "npm:vite".
+type MiseTool struct {
+	Name    string
+	Version string
+}
+
+// DefaultMiseConfig returns the version specs of the base tools. Init pins them to the current
+// stable versions with resolveMiseVersions before it writes mise.toml.
 func DefaultMiseConfig() MiseConfig {
 	return MiseConfig{
 		GoVersion:           "latest",
@@ -502,56 +580,108 @@ func initCmd() *cli.Command {
 		ArgsUsage: "[directory]",
 		Flags: []cli.Flag{
 			&cli.BoolFlag{
-				Name:    "yes",
-				Aliases: []string{"y"},
+				Name:    "non-interactive",
+				Aliases: []string{"yes", "y"},
 				Usage:   "Accept all defaults without prompting",
 			},
+			&cli.StringFlag{
+				Name: "template",
+				Usage: "Project template: " + strings.Join(projectTemplateNames(), ", ") +
+					", or a git URL with an optional #ref",
+				Value: defaultProjectTemplate,
+			},
 			&cli.StringFlag{
 				Name:  "local-ago",
 				Usage: "Path to local ago module (adds replace directive to go.mod)",
 			},
+			&cli.BoolFlag{
+				Name:  "ci-workflow",
+				Usage: "Generate a GitHub Actions workflow that deploys the project with the CI deployer role",
+			},
 		},
 		Action: runInit,
 	}
 }
 
 func runInit(ctx context.Context, cmd *cli.Command) error {
-	dir := cmd.Args().First()
+	dir, err := absProjectDir(cmd.Args().First())
+	if err != nil {
+		return err
+	}
+
+	tmpl, err := resolveProjectTemplate(ctx, cmd.String("template"))
+	if err != nil {
+		return err
+	}
+
+	result, err := runInitWizard(cmd.Bool("non-interactive"), filepath.Base(dir), tmpl)
+	if err != nil {
+		return err
+	}
+
+	opts := initOptionsFromResult(dir, result, tmpl)
+	opts.LocalAgoPath = cmd.String("local-ago")
+	opts.CIWorkflow = cmd.Bool("ci-workflow")
+
+	return doInit(ctx, opts)
+}
+
+// absProjectDir returns the absolute path of dir, defaulting to the working directory.
+func absProjectDir(dir string) (string, error) {
 	if dir == "" {
 		var err error
 		dir, err = os.Getwd()
 		if err != nil {
-			return errors.Wrap(err, "failed to get current working directory")
+			return "", errors.Wrap(err, "failed to get current working directory")
 		}
 	}
 
 	absDir, err := filepath.Abs(dir)
 	if err != nil {
-		return errors.Wrap(err, "failed to get absolute path")
+		return "", errors.Wrap(err, "failed to get absolute path")
 	}
-	dir = absDir
 
-	defaultIdent := filepath.Base(dir)
+	return absDir, nil
+}
+
+// runInitWizard asks for the project settings, or returns the defaults when nonInteractive is
+// set. Without a terminal to prompt on it fails rather than silently using the defaults. The
+// services of the template are preselected.
+func runInitWizard(nonInteractive bool, defaultIdent string, tmpl projectTemplate) (initwizard.Result, error) {
+	if nonInteractive {
+		return initwizard.DefaultResult(defaultIdent), nil
+	}
+	if !isTerminal(os.Stdin) {
+		return initwizard.Result{}, errors.New("stdin is not a terminal: pass --non-interactive to use the defaults")
+	}
 
-	var result initwizard.Result
-	if cmd.Bool("yes") {
-		result = initwizard.DefaultResult(defaultIdent)
-	} else {
-		wizard := initwizard.New(initwizard.NewFormBuilder(), initwizard.NewInteractiveRunner())
-		var err error
-		result, err = wizard.Run(defaultIdent)
-		if err != nil {
-			return errors.Wrap(err, "wizard failed")
-		}
+	builder := initwizard.NewFormBuilder(initwizard.Services{
+		Supported: SupportedServices(),
+		Defaults:  tmpl.Services,
+	})
+	wizard := initwizard.New(builder, initwizard.NewInteractiveRunner())
+	result, err := wizard.Run(defaultIdent)
+	if err != nil {
+		return initwizard.Result{}, errors.Wrap(err, "wizard failed")
 	}
 
+	return result, nil
+}
+
+func initOptionsFromResult(dir string, result initwizard.Result, tmpl projectTemplate) InitOptions {
 	cdkConfig := DefaultCDKConfigFromDir(dir)
 	cdkConfig.Prefix = result.ProjectIdent + "-"
-	cdkConfig.Qualifier = result.ProjectIdent
+	cdkConfig.Qualifier = result.Qualifier
 	cdkConfig.PrimaryRegion = result.PrimaryRegion
 	cdkConfig.SecondaryRegions = result.SecondaryRegions
 	cdkConfig.BaseDomainName = result.BaseDomainName
+	cdkConfig.Deployments = result.Deployments
+	cdkConfig.EmailPattern = result.EmailPattern
 	cdkConfig.ManagementProfile = result.ManagementProfile
+	cdkConfig.Services = tmpl.Services
+	if len(result.Services) > 0 {
+		cdkConfig.Services = result.Services
+	}
 
 	tfConfig := TFConfig{
 		TerraformCloudOrg: result.TerraformCloudOrg,
@@ -561,9 +691,13 @@ func runInit(ctx context.Context, cmd *cli.Command) error {
 	backendConfig := DefaultBackendConfigFromDir(dir)
 	backendConfig.DepotProjectID = result.DepotProjectID
 
-	return doInit(ctx, InitOptions{
+	miseConfig := DefaultMiseConfig()
+	miseConfig.ExtraTools = tmpl.Tools
+
+	return InitOptions{
 		Dir:               dir,
-		MiseConfig:        DefaultMiseConfig(),
+		MiseConfig:        miseConfig,
+		Template:          tmpl,
 		CDKConfig:         cdkConfig,
 		TFConfig:          tfConfig,
 		BackendConfig:     backendConfig,
@@ -571,13 +705,14 @@ func runInit(ctx context.Context, cmd *cli.Command) error {
 		ManagementProfile: result.ManagementProfile,
 		Region:            result.PrimaryRegion,
 		InitialDeployer:   result.InitialDeployer,
-		LocalAgoPath:      cmd.String("local-ago"),
-	})
+	}
 }
 
 type InitOptions struct {
-	Dir                 string
-	MiseConfig          MiseConfig
+	Dir        string
+	MiseConfig MiseConfig
+	// Template provides the CDK Go files. Without files, the default template is used.
+	Template            projectTemplate
 	CDKConfig           CDKConfig
 	TFConfig            TFConfig
 	BackendConfig       BackendConfig
@@ -591,6 +726,8 @@ type InitOptions struct {
 	// to use the local ago module instead of fetching from the module proxy.
 	// This is useful for testing with unpublished changes.
 	LocalAgoPath string
+	// CIWorkflow generates .github/workflows/deploy.yml once the project account exists.
+	CIWorkflow bool
 }
 
 func doInit(ctx context.Context, opts InitOptions) error {
@@ -608,11 +745,18 @@ func doInit(ctx context.Context, opts InitOptions) error {
 		return errors.Wrap(err, "git init failed")
 	}
 
-	if err := config.WriteToFile(opts.Dir, config.Default(), config.NewWriter()); err != nil {
+	if err := writeAgoConfig(opts.Dir); err != nil {
 		return err
 	}
 
-	if err := writeMiseToml(opts.Dir, opts.MiseConfig); err != nil {
+	// The project directory does not exist in a dry run, and the versions do not depend on it.
+	writeOutputf(os.Stdout, "Resolving tool versions...\n")
+	miseConfig, err := resolveMiseVersions(ctx, cmdexec.NewWithDir("."), opts.MiseConfig)
+	if err != nil {
+		return err
+	}
+
+	if err := writeMiseToml(opts.Dir, miseConfig); err != nil {
 		return err
 	}
 
@@ -638,7 +782,11 @@ func doInit(ctx context.Context, opts InitOptions) error {
 		return err
 	}
 
-	if err := configureCDKProject(ctx, exec, opts.Dir, opts.CDKConfig, opts.LocalAgoPath); err != nil {
+	tmpl := opts.Template
+	if len(tmpl.Files) == 0 {
+		tmpl = embeddedProjectTemplates()[defaultProjectTemplate]
+	}
+	if err := configureCDKProject(ctx, exec, opts.Dir, tmpl, opts.CDKConfig, opts.LocalAgoPath); err != nil {
 		return err
 	}
 
@@ -671,6 +819,15 @@ func doInit(ctx context.Context, opts InitOptions) error {
 		}
 	}
 
+	if opts.CIWorkflow {
+		if err := doCIGenerateWorkflow(ctx, config.Config{ProjectDir: opts.Dir}, generateWorkflowOptions{
+			Branch: "main",
+			Output: os.Stdout,
+		}); err != nil {
+			return errors.Wrap(err, "failed to generate the CI workflow")
+		}
+	}
+
 	if !opts.SkipCDKVerify {
 		if err := verifyCDKSetup(ctx, exec, opts.CDKConfig); err != nil {
 			return err
@@ -690,7 +847,7 @@ func doInit(ctx context.Context, opts InitOptions) error {
 
 func verifyCDKSetup(ctx context.Context, exec cmdexec.Executor, cfg CDKConfig) error {
 	cdkExec := exec.InSubdir("infra/cdk/cdk")
-	deployerGroupsCtx := cfg.Prefix + "deployer-groups=" + cfg.Qualifier + "-deployers"
+	deployerGroupsCtx := deployerGroupsContext(cfg.Prefix, []string{ops.DeployersGroupName(cfg.Qualifier)})
 
 	return cdkExec.Mise(ctx, "cdk", "ls", "--context", deployerGroupsCtx)
 }
@@ -723,13 +880,28 @@ func ensureEmptyDir(dir string) error {
 		return errors.Wrap(err, "failed to check directory")
 	}
 
-	if err := os.MkdirAll(dir, 0o755); err != nil {
+	if err := cmdexec.MkdirAll(dir, 0o755); err != nil {
 		return errors.Wrap(err, "failed to create directory")
 	}
 
 	return nil
 }
 
+// writeAgoConfig writes the default .ago.yml into the project directory.
+func writeAgoConfig(dir string) error {
+	var buf bytes.Buffer
+	if err := config.NewWriter().Write(&buf, config.Default()); err != nil {
+		return errors.Wrap(err, "failed to render config file")
+	}
+
+	//nolint:gosec // config file needs to be readable
+	if err := cmdexec.WriteFile(filepath.Join(dir, config.FileName), buf.Bytes(), 0o644); err != nil {
+		return errors.Wrap(err, "failed to create config file")
+	}
+
+	return nil
+}
+
 func writeMiseToml(dir string, cfg MiseConfig) error {
 	var buf bytes.Buffer
 	if err := miseTomlTemplate.Execute(&buf, cfg); err != nil {
@@ -737,7 +909,7 @@ func writeMiseToml(dir string, cfg MiseConfig) error {
 	}
 
 	path := filepath.Join(dir, "mise.toml")
-	if err := os.WriteFile(path, buf.Bytes(), 0o644); err != nil { //nolint:gosec // config file needs to be readable
+	if err := cmdexec.WriteFile(path, buf.Bytes(), 0o644); err != nil { //nolint:gosec // config file needs to be readable
 		return errors.Wrap(err, "failed to write mise.toml")
 	}
 
@@ -745,19 +917,23 @@ func writeMiseToml(dir string, cfg MiseConfig) error {
 }
 
 func configureCDKProject(
-	ctx context.Context, exec cmdexec.Executor, dir string, cfg CDKConfig, localAgoPath string,
+	ctx context.Context, exec cmdexec.Executor, dir string, tmpl projectTemplate, cfg CDKConfig, localAgoPath string,
 ) error {
 	infraDir := filepath.Join(dir, "infra")
 	cdkPkgDir := filepath.Join(infraDir, "cdk")
 	cdkDir := filepath.Join(cdkPkgDir, "cdk")
 
 	moduleName, err := readModuleName(infraDir)
+	if err != nil && cmdexec.DryRun() {
+		// cdk init did not run, and names the module after its directory.
+		moduleName, err = "cdk", nil
+	}
 	if err != nil {
 		return err
 	}
 	cfg.ModuleName = moduleName
 
-	if err := writeCDKGoFiles(cdkPkgDir, cdkDir, cfg); err != nil {
+	if err := writeCDKGoFiles(cdkPkgDir, tmpl, cfg); err != nil {
 		return err
 	}
 
@@ -789,24 +965,19 @@ func configureCDKProject(
 	return nil
 }
 
-func writeCDKGoFiles(cdkPkgDir, cdkDir string, cfg CDKConfig) error {
-	templates := map[string]struct {
-		tmpl *template.Template
-		dir  string
-	}{
-		"cdk.go":        {cdkMainTemplate, cdkDir},
-		"shared.go":     {cdkSharedTemplate, cdkPkgDir},
-		"deployment.go": {cdkDeploymentTemplate, cdkPkgDir},
-	}
-
-	for filename, t := range templates {
+// writeCDKGoFiles renders the CDK Go files of the project template into infra/cdk.
+func writeCDKGoFiles(cdkPkgDir string, tmpl projectTemplate, cfg CDKConfig) error {
+	for filename, t := range tmpl.Files {
 		var buf bytes.Buffer
-		if err := t.tmpl.Execute(&buf, cfg); err != nil {
+		if err := t.Execute(&buf, cfg); err != nil {
 			return errors.Wrapf(err, "failed to execute %s template", filename)
 		}
 
-		path := filepath.Join(t.dir, filename)
-		if err := os.WriteFile(path, buf.Bytes(), 0o644); err != nil { //nolint:gosec // source file needs to be readable
+		path := filepath.Join(cdkPkgDir, filepath.FromSlash(filename))
+		if err := cmdexec.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return errors.Wrapf(err, "failed to create directory of %s", filename)
+		}
+		if err := cmdexec.WriteFile(path, buf.Bytes(), 0o644); err != nil { //nolint:gosec // source file needs to be readable
 			return errors.Wrapf(err, "failed to write %s", filename)
 		}
 	}
@@ -825,7 +996,7 @@ func writeCDKContextJSON(cdkDir string, cfg CDKConfig) error {
 		cfg.Prefix + "dns-delegated":      false,
 		cfg.Prefix + "management-profile": cfg.ManagementProfile,
 		"@aws-cdk/core:permissionsBoundary": map[string]string{
-			"name": cfg.Qualifier + "-permissions-boundary",
+			"name": agcdkutil.PermissionsBoundaryName(cfg.Qualifier),
 		},
 		"cli-telemetry":              false,
 		"acknowledged-issue-numbers": []int{34892},
@@ -837,7 +1008,7 @@ func writeCDKContextJSON(cdkDir string, cfg CDKConfig) error {
 	}
 
 	contextPath := filepath.Join(cdkDir, "cdk.context.json")
-	if err := os.WriteFile(contextPath, output, 0o644); err != nil { //nolint:gosec // config file needs to be readable
+	if err := cmdexec.WriteFile(contextPath, output, 0o644); err != nil { //nolint:gosec // config file needs to be readable
 		return errors.Wrap(err, "failed to write cdk.context.json")
 	}
 
@@ -851,7 +1022,7 @@ func writeGolangciLintConfig(infraDir string) error {
 	}
 
 	path := filepath.Join(infraDir, ".golangci.yml")
-	if err := os.WriteFile(path, buf.Bytes(), 0o644); err != nil { //nolint:gosec // config file needs to be readable
+	if err := cmdexec.WriteFile(path, buf.Bytes(), 0o644); err != nil { //nolint:gosec // config file needs to be readable
 		return errors.Wrap(err, "failed to write .golangci.yml")
 	}
 
@@ -862,7 +1033,7 @@ func setupCDKProject(ctx context.Context, exec cmdexec.Executor, dir string) err
 	infraDir := filepath.Join(dir, "infra")
 	cdkDir := filepath.Join(infraDir, "cdk", "cdk")
 
-	if err := os.MkdirAll(cdkDir, 0o755); err != nil {
+	if err := cmdexec.MkdirAll(cdkDir, 0o755); err != nil {
 		return errors.Wrap(err, "failed to create CDK directory")
 	}
 
@@ -875,22 +1046,23 @@ func setupCDKProject(ctx context.Context, exec cmdexec.Executor, dir string) err
 		src := filepath.Join(cdkDir, filename)
 		dst := filepath.Join(infraDir, filename)
 		if _, err := os.Stat(src); err == nil {
-			if err := os.Rename(src, dst); err != nil {
+			if err := cmdexec.Rename(src, dst); err != nil {
 				return errors.Wrapf(err, "failed to move %s to infra directory", filename)
 			}
 		}
 	}
 
-	gitignorePath := filepath.Join(cdkDir, ".gitignore")
-	f, err := os.OpenFile(gitignorePath, os.O_APPEND|os.O_WRONLY, 0o644)
-	if err != nil {
-		return errors.Wrap(err, "failed to open .gitignore")
+	// The files of cdk init only exist when it ran.
+	if cmdexec.DryRun() {
+		cmdexec.ReportDryRun("would ignore cdk in %s and remove the tests and README.md of cdk init",
+			filepath.Join(cdkDir, ".gitignore"))
+		return nil
 	}
-	if _, err := f.WriteString("\ncdk\n"); err != nil {
-		f.Close()
+
+	gitignorePath := filepath.Join(cdkDir, ".gitignore")
+	if err := cmdexec.AppendFile(gitignorePath, []byte("\ncdk\n")); err != nil {
 		return errors.Wrap(err, "failed to write to .gitignore")
 	}
-	f.Close()
 
 	entries, err := os.ReadDir(cdkDir)
 	if err != nil {
@@ -899,14 +1071,14 @@ func setupCDKProject(ctx context.Context, exec cmdexec.Executor, dir string) err
 	for _, entry := range entries {
 		name := entry.Name()
 		if strings.HasSuffix(name, "_test.go") {
-			if err := os.Remove(filepath.Join(cdkDir, name)); err != nil {
+			if err := cmdexec.Remove(filepath.Join(cdkDir, name)); err != nil {
 				return errors.Wrapf(err, "failed to remove %s", name)
 			}
 		}
 	}
 
 	readmePath := filepath.Join(cdkDir, "README.md")
-	if err := os.Remove(readmePath); err != nil && !os.IsNotExist(err) {
+	if err := cmdexec.Remove(readmePath); err != nil && !os.IsNotExist(err) {
 		return errors.Wrap(err, "failed to remove README.md")
 	}
 
@@ -916,7 +1088,7 @@ func setupCDKProject(ctx context.Context, exec cmdexec.Executor, dir string) err
 func setupTFProject(dir string, cfg TFConfig) error {
 	tfDir := filepath.Join(dir, "infra", "tf")
 
-	if err := os.MkdirAll(tfDir, 0o755); err != nil {
+	if err := cmdexec.MkdirAll(tfDir, 0o755); err != nil {
 		return errors.Wrap(err, "failed to create tf directory")
 	}
 
@@ -927,7 +1099,7 @@ func setupTFProject(dir string, cfg TFConfig) error {
 
 	gitignorePath := filepath.Join(tfDir, ".gitignore")
 	//nolint:gosec // config file needs to be readable
-	if err := os.WriteFile(gitignorePath, gitignoreBuf.Bytes(), 0o644); err != nil {
+	if err := cmdexec.WriteFile(gitignorePath, gitignoreBuf.Bytes(), 0o644); err != nil {
 		return errors.Wrap(err, "failed to write tf .gitignore")
 	}
 
@@ -938,7 +1110,7 @@ func setupTFProject(dir string, cfg TFConfig) error {
 
 	mainPath := filepath.Join(tfDir, "main.tf")
 	//nolint:gosec // source file needs to be readable
-	if err := os.WriteFile(mainPath, mainBuf.Bytes(), 0o644); err != nil {
+	if err := cmdexec.WriteFile(mainPath, mainBuf.Bytes(), 0o644); err != nil {
 		return errors.Wrap(err, "failed to write tf main.tf")
 	}
 
@@ -948,7 +1120,7 @@ func setupTFProject(dir string, cfg TFConfig) error {
 func setupBackendProject(ctx context.Context, exec cmdexec.Executor, dir string, cfg BackendConfig) error {
 	backendDir := filepath.Join(dir, "backend")
 
-	if err := os.MkdirAll(backendDir, 0o755); err != nil {
+	if err := cmdexec.MkdirAll(backendDir, 0o755); err != nil {
 		return errors.Wrap(err, "failed to create backend directory")
 	}
 
@@ -959,7 +1131,7 @@ func setupBackendProject(ctx context.Context, exec cmdexec.Executor, dir string,
 
 	goModPath := filepath.Join(backendDir, "go.mod")
 	//nolint:gosec // config file needs to be readable
-	if err := os.WriteFile(goModPath, goModBuf.Bytes(), 0o644); err != nil {
+	if err := cmdexec.WriteFile(goModPath, goModBuf.Bytes(), 0o644); err != nil {
 		return errors.Wrap(err, "failed to write backend go.mod")
 	}
 
@@ -970,7 +1142,7 @@ func setupBackendProject(ctx context.Context, exec cmdexec.Executor, dir string,
 
 	gitignorePath := filepath.Join(backendDir, ".gitignore")
 	//nolint:gosec // config file needs to be readable
-	if err := os.WriteFile(gitignorePath, gitignoreBuf.Bytes(), 0o644); err != nil {
+	if err := cmdexec.WriteFile(gitignorePath, gitignoreBuf.Bytes(), 0o644); err != nil {
 		return errors.Wrap(err, "failed to write backend .gitignore")
 	}
 
@@ -978,14 +1150,14 @@ func setupBackendProject(ctx context.Context, exec cmdexec.Executor, dir string,
 		return errors.Wrap(err, "failed to write backend .golangci.yml")
 	}
 
-	var dockerfileBuf bytes.Buffer
-	if err := backendDockerfileTemplate.Execute(&dockerfileBuf, cfg); err != nil {
-		return errors.Wrap(err, "failed to execute backend Dockerfile template")
+	dockerfile, err := renderBackendDockerfile(cfg)
+	if err != nil {
+		return err
 	}
 
 	dockerfilePath := filepath.Join(backendDir, "Dockerfile")
 	//nolint:gosec // config file needs to be readable
-	if err := os.WriteFile(dockerfilePath, dockerfileBuf.Bytes(), 0o644); err != nil {
+	if err := cmdexec.WriteFile(dockerfilePath, dockerfile, 0o644); err != nil {
 		return errors.Wrap(err, "failed to write backend Dockerfile")
 	}
 
@@ -996,7 +1168,7 @@ func setupBackendProject(ctx context.Context, exec cmdexec.Executor, dir string,
 
 	dockerignorePath := filepath.Join(backendDir, ".dockerignore")
 	//nolint:gosec // config file needs to be readable
-	if err := os.WriteFile(dockerignorePath, dockerignoreBuf.Bytes(), 0o644); err != nil {
+	if err := cmdexec.WriteFile(dockerignorePath, dockerignoreBuf.Bytes(), 0o644); err != nil {
 		return errors.Wrap(err, "failed to write backend .dockerignore")
 	}
 
@@ -1008,13 +1180,13 @@ func setupBackendProject(ctx context.Context, exec cmdexec.Executor, dir string,
 
 		depotJSONPath := filepath.Join(backendDir, "depot.json")
 		//nolint:gosec // config file needs to be readable
-		if err := os.WriteFile(depotJSONPath, depotJSONBuf.Bytes(), 0o644); err != nil {
+		if err := cmdexec.WriteFile(depotJSONPath, depotJSONBuf.Bytes(), 0o644); err != nil {
 			return errors.Wrap(err, "failed to write backend depot.json")
 		}
 	}
 
 	coreAPIDir := filepath.Join(backendDir, "cmd", "coreapi")
-	if err := os.MkdirAll(coreAPIDir, 0o755); err != nil {
+	if err := cmdexec.MkdirAll(coreAPIDir, 0o755); err != nil {
 		return errors.Wrap(err, "failed to create backend cmd/coreapi directory")
 	}
 
@@ -1025,7 +1197,7 @@ func setupBackendProject(ctx context.Context, exec cmdexec.Executor, dir string,
 
 	mainPath := filepath.Join(coreAPIDir, "main.go")
 	//nolint:gosec // source file needs to be readable
-	if err := os.WriteFile(mainPath, mainBuf.Bytes(), 0o644); err != nil {
+	if err := cmdexec.WriteFile(mainPath, mainBuf.Bytes(), 0o644); err != nil {
 		return errors.Wrap(err, "failed to write backend main.go")
 	}
 
@@ -1061,18 +1233,12 @@ func trySetDNSDelegatedIfResolved(ctx context.Context, dir string, cdkCfg CDKCon
 		return nil
 	}
 
-	verified, err := checkDNSOnce(ctx, cdkCfg.BaseDomainName, expectedNS)
+	verified, err := ops.CheckDelegation(ctx, cdkCfg.BaseDomainName, expectedNS)
 	if err != nil || !verified {
 		return nil
 	}
 
-	cfg := config.Config{ProjectDir: dir}
-	cdkContext, err := readCDKContext(cfg)
-	if err != nil {
-		return nil
-	}
-
-	if err := setDNSDelegatedFlag(cfg, cdkContext.prefix); err != nil {
+	if err := setDNSDelegatedFlag(config.Config{ProjectDir: dir}); err != nil {
 		return errors.Wrap(err, "failed to set dns-delegated flag")
 	}
 
@@ -1081,17 +1247,5 @@ func trySetDNSDelegatedIfResolved(ctx context.Context, dir string, cdkCfg CDKCon
 }
 
 func lookupExpectedNSFromParent(ctx context.Context, baseDomainName string) ([]string, error) {
-	resolver := newPublicDNSResolver()
-
-	nsRecords, err := lookupNSWithRetry(ctx, resolver, baseDomainName)
-	if err != nil {
-		return nil, err
-	}
-
-	result := make([]string, 0, len(nsRecords))
-	for _, ns := range nsRecords {
-		result = append(result, ns.Host)
-	}
-
-	return result, nil
+	return ops.LookupNS(ctx, baseDomainName)
 }
@@ -0,0 +1,341 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/advdv/ago/cmd/ago/internal/cmdexec"
+	"github.com/cockroachdb/errors"
+	"github.com/goccy/go-yaml"
+)
+
+// defaultProjectTemplate is the name of the template that ago init uses without --template.
+const defaultProjectTemplate = "default"
+
+// projectTemplateManifest is the file at the root of a git template that describes it. The CDK
+// Go files of the template are the files under infra/cdk next to it.
+const projectTemplateManifest = "ago-template.yaml"
+
+// projectTemplate is a scaffold layout for ago init. It contributes the CDK Go files, extra mise
+// tools and the default services of a new project; the rest of the scaffold is shared.
+type projectTemplate struct {
+	Name        string
+	Description string
+	// Files are the CDK Go files by their path relative to infra/cdk, rendered with the CDKConfig.
+	// The app entrypoint is cdk/cdk.go.
+	Files map[string]*template.Template
+	// Tools are added to the base tools of mise.toml.
+	Tools []MiseTool
+	// Services are the default services of the project.
+	Services []string
+}
+
+var cdkAPIDeploymentTemplate = template.Must(template.New("deployment.go").Parse(`package cdk
+
+import (
+	"github.com/advdv/ago/agcdk/agcdkservice"
+	"github.com/advdv/ago/agcdkutil"
+	"github.com/aws/aws-cdk-go/awscdk/v2"
+)
+
+func NewDeployment(stack awscdk.Stack, shared *Shared, deploymentIdent string) {
+	if !shared.Base.IsValidated() {
+		// Shared base not yet validated - skip deployment resources.
+		return
+	}
+
+	// The service runs the coreapi image of the deployment, so it is only added once
+	// 'ago backend build-and-push --deployment <deployment>' pushed one.
+	cfg := agcdkutil.ConfigFromScope(stack)
+	if _, ok := cfg.ImageTags[deploymentIdent]["coreapi"]; !ok && !cfg.Sandbox {
+		return
+	}
+
+	agcdkservice.New(stack, agcdkservice.Props{
+		Name:       "coreapi",
+		Deployment: deploymentIdent,
+		Repository: shared.Base.Repositories().MainRepository(),
+		Frontend:   agcdkservice.FrontendVPCLink,
+	})
+
+	// Add deployment-specific resources below
+}
+`))
+
+var cdkFullstackDeploymentTemplate = template.Must(template.New("deployment.go").Parse(`package cdk
+
+import (
+	"github.com/aws/aws-cdk-go/awscdk/v2"
+)
+
+func NewDeployment(stack awscdk.Stack, shared *Shared, deploymentIdent string) {
+	if !shared.Base.IsValidated() {
+		// Shared base not yet validated - skip deployment resources.
+		return
+	}
+
+	NewFrontend(stack)
+
+	// Add deployment-specific resources below
+}
+`))
+
+var cdkFullstackFrontendTemplate = template.Must(template.New("frontend.go").Parse(`package cdk
+
+import (
+	"github.com/advdv/ago/agcdkutil"
+	"github.com/aws/aws-cdk-go/awscdk/v2"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awscloudfront"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awscloudfrontorigins"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awss3"
+	"github.com/aws/jsii-runtime-go"
+)
+
+// NewFrontend serves the built frontend from a private bucket through CloudFront.
+func NewFrontend(stack awscdk.Stack) awscloudfront.Distribution {
+	bucket := awss3.NewBucket(stack, jsii.String("FrontendBucket"), &awss3.BucketProps{
+		BlockPublicAccess: awss3.BlockPublicAccess_BLOCK_ALL(),
+		EnforceSSL:        jsii.Bool(true),
+	})
+
+	distribution := awscloudfront.NewDistribution(stack, jsii.String("Frontend"), &awscloudfront.DistributionProps{
+		DefaultBehavior: &awscloudfront.BehaviorOptions{
+			Origin:               awscloudfrontorigins.S3BucketOrigin_WithOriginAccessControl(bucket, nil),
+			ViewerProtocolPolicy: awscloudfront.ViewerProtocolPolicy_REDIRECT_TO_HTTPS,
+		},
+		DefaultRootObject: jsii.String("index.html"),
+	})
+
+	agcdkutil.Output(stack, "FrontendBucketName", bucket.BucketName(), agcdkutil.OutputOptions{})
+	agcdkutil.Output(stack, "FrontendURL", jsii.String("https://"+*distribution.DistributionDomainName()),
+		agcdkutil.OutputOptions{Description: "URL of the frontend"})
+
+	return distribution
+}
+`))
+
+var cdkEventDrivenDeploymentTemplate = template.Must(template.New("deployment.go").Parse(`package cdk
+
+import (
+	"github.com/advdv/ago/agcdk/agcdkevents"
+	"github.com/advdv/ago/agcdk/agcdkqueue"
+	"github.com/aws/aws-cdk-go/awscdk/v2"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awsevents"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awseventstargets"
+	"github.com/aws/jsii-runtime-go"
+)
+
+func NewDeployment(stack awscdk.Stack, shared *Shared, deploymentIdent string) {
+	if !shared.Base.IsValidated() {
+		// Shared base not yet validated - skip deployment resources.
+		return
+	}
+
+	events := agcdkevents.New(stack, agcdkevents.Props{})
+	orders := agcdkqueue.New(stack, agcdkqueue.Props{Name: "orders"})
+
+	// Route the events that the orders consumer handles to its queue.
+	awsevents.NewRule(stack, jsii.String("OrdersRule"), &awsevents.RuleProps{
+		EventBus: events.EventBus(),
+		EventPattern: &awsevents.EventPattern{
+			DetailType: jsii.Strings("OrderPlaced"),
+		},
+		Targets: &[]awsevents.IRuleTarget{awseventstargets.NewSqsQueue(orders.Queue(), nil)},
+	})
+
+	// Add deployment-specific resources below
+}
+`))
+
+// baseTemplateServices are the services that the shared base of every template needs.
+var baseTemplateServices = []string{
+	"acm", "cloudwatch", "ecr", "iam", "kms", "logs", "route53", "s3", "secretsmanager", "ssm",
+}
+
+// templateServices returns the base services together with the extra services of a template.
+func templateServices(extra ...string) []string {
+	services := slices.Concat(baseTemplateServices, extra)
+	sort.Strings(services)
+	return slices.Compact(services)
+}
+
+// embeddedProjectTemplates returns the templates that ship with ago, by name.
+func embeddedProjectTemplates() map[string]projectTemplate {
+	return map[string]projectTemplate{
+		defaultProjectTemplate: {
+			Name:        defaultProjectTemplate,
+			Description: "Shared base with empty deployments, for any kind of project",
+			Files: map[string]*template.Template{
+				"cdk/cdk.go":    cdkMainTemplate,
+				"shared.go":     cdkSharedTemplate,
+				"deployment.go": cdkDeploymentTemplate,
+			},
+			Services: DefaultServices(),
+		},
+		"api": {
+			Name:        "api",
+			Description: "API-only backend: the coreapi command as a Fargate service behind an HTTP API",
+			Files: map[string]*template.Template{
+				"cdk/cdk.go":    cdkMainTemplate,
+				"shared.go":     cdkSharedTemplate,
+				"deployment.go": cdkAPIDeploymentTemplate,
+			},
+			Services: templateServices("apigateway", "application-autoscaling", "ec2"),
+		},
+		"fullstack": {
+			Name:        "fullstack",
+			Description: "Backend with a frontend served from S3 through CloudFront",
+			Files: map[string]*template.Template{
+				"cdk/cdk.go":    cdkMainTemplate,
+				"shared.go":     cdkSharedTemplate,
+				"deployment.go": cdkFullstackDeploymentTemplate,
+				"frontend.go":   cdkFullstackFrontendTemplate,
+			},
+			Tools:    []MiseTool{{Name: "pnpm", Version: "latest"}},
+			Services: templateServices("apigateway", "cloudfront", "cognito-idp", "dynamodb", "lambda"),
+		},
+		"event-driven": {
+			Name:        "event-driven",
+			Description: "Event bus with an archive and a queue per consumer",
+			Files: map[string]*template.Template{
+				"cdk/cdk.go":    cdkMainTemplate,
+				"shared.go":     cdkSharedTemplate,
+				"deployment.go": cdkEventDrivenDeploymentTemplate,
+			},
+			Services: templateServices("dynamodb", "events", "lambda", "sns", "sqs", "states"),
+		},
+	}
+}
+
+// projectTemplateNames returns the names of the embedded templates, sorted.
+func projectTemplateNames() []string {
+	templates := embeddedProjectTemplates()
+	names := make([]string, 0, len(templates))
+	for name := range templates {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// isGitTemplateSource reports whether a --template value refers to a git repository rather than
+// an embedded template.
+func isGitTemplateSource(source string) bool {
+	return strings.Contains(source, "://") || strings.HasPrefix(source, "git@") ||
+		strings.HasSuffix(strings.SplitN(source, "#", 2)[0], ".git")
+}
+
+// resolveProjectTemplate returns the template for a --template value: the name of an embedded
+// template, or a git URL with an optional "#ref" suffix. An empty value selects the default.
+func resolveProjectTemplate(ctx context.Context, source string) (projectTemplate, error) {
+	if source == "" {
+		source = defaultProjectTemplate
+	}
+	if isGitTemplateSource(source) {
+		return loadGitProjectTemplate(ctx, source)
+	}
+
+	tmpl, ok := embeddedProjectTemplates()[source]
+	if !ok {
+		return projectTemplate{}, errors.Newf("unknown template %q (available: %s, or a git URL)",
+			source, strings.Join(projectTemplateNames(), ", "))
+	}
+	return tmpl, nil
+}
+
+// loadGitProjectTemplate shallow-clones a template repository and loads it.
+func loadGitProjectTemplate(ctx context.Context, source string) (projectTemplate, error) {
+	url, ref, _ := strings.Cut(source, "#")
+
+	tmpDir, err := os.MkdirTemp("", "ago-template-*")
+	if err != nil {
+		return projectTemplate{}, errors.Wrap(err, "failed to create temporary directory")
+	}
+	defer os.RemoveAll(tmpDir)
+
+	args := []string{"clone", "--quiet", "--depth", "1"}
+	if ref != "" {
+		args = append(args, "--branch", ref)
+	}
+	args = append(args, url, tmpDir)
+	if err := cmdexec.NewWithDir(".").WithOutput(os.Stderr, os.Stderr).Run(ctx, "git", args...); err != nil {
+		return projectTemplate{}, errors.Wrapf(err, "failed to clone template %s", source)
+	}
+
+	return loadProjectTemplateDir(tmpDir, source)
+}
+
+// loadProjectTemplateDir loads a template from a directory with an ago-template.yaml manifest.
+// Every file under infra/cdk becomes a CDK Go file, with a .tmpl suffix stripped from its name.
+func loadProjectTemplateDir(dir, source string) (projectTemplate, error) {
+	data, err := os.ReadFile(filepath.Join(dir, projectTemplateManifest))
+	if err != nil {
+		return projectTemplate{}, errors.Wrapf(err, "template %s has no %s", source, projectTemplateManifest)
+	}
+
+	var manifest struct {
+		Name        string            `yaml:"name"`
+		Description string            `yaml:"description"`
+		Tools       map[string]string `yaml:"tools"`
+		Services    []string          `yaml:"services"`
+	}
+	if err := yaml.UnmarshalWithOptions(data, &manifest, yaml.Strict()); err != nil {
+		return projectTemplate{}, errors.Wrapf(err, "failed to parse %s of template %s",
+			projectTemplateManifest, source)
+	}
+	if err := ValidateServices(manifest.Services); err != nil {
+		return projectTemplate{}, errors.Wrapf(err, "invalid services in template %s", source)
+	}
+
+	tmpl := projectTemplate{
+		Name:        cmp.Or(manifest.Name, source),
+		Description: manifest.Description,
+		Files:       map[string]*template.Template{},
+		Services:    manifest.Services,
+	}
+	if len(tmpl.Services) == 0 {
+		tmpl.Services = DefaultServices()
+	}
+	for name, version := range manifest.Tools {
+		tmpl.Tools = append(tmpl.Tools, MiseTool{Name: name, Version: version})
+	}
+	slices.SortFunc(tmpl.Tools, func(a, b MiseTool) int { return strings.Compare(a.Name, b.Name) })
+
+	cdkDir := filepath.Join(dir, "infra", "cdk")
+	err = filepath.WalkDir(cdkDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(cdkDir, path)
+		if err != nil {
+			return err
+		}
+		rel = strings.TrimSuffix(filepath.ToSlash(rel), ".tmpl")
+
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		parsed, err := template.New(filepath.Base(rel)).Parse(string(content))
+		if err != nil {
+			return errors.Wrapf(err, "failed to parse %s", rel)
+		}
+		tmpl.Files[rel] = parsed
+		return nil
+	})
+	if err != nil {
+		return projectTemplate{}, errors.Wrapf(err, "failed to load the CDK files of template %s", source)
+	}
+	if _, ok := tmpl.Files["cdk/cdk.go"]; !ok {
+		return projectTemplate{}, errors.Newf("template %s has no infra/cdk/cdk/cdk.go entrypoint", source)
+	}
+
+	return tmpl, nil
+}
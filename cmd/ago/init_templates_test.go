@@ -0,0 +1,132 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"go/format"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestEmbeddedProjectTemplates(t *testing.T) {
+	t.Parallel()
+
+	cfg := DefaultCDKConfigFromDir("/tmp/myapp")
+	cfg.ModuleName = "myapp"
+	for name, tmpl := range embeddedProjectTemplates() {
+		if tmpl.Name != name {
+			t.Errorf("template %q has name %q", name, tmpl.Name)
+		}
+		if _, ok := tmpl.Files["cdk/cdk.go"]; !ok {
+			t.Errorf("template %q has no entrypoint", name)
+		}
+		if err := ValidateServices(tmpl.Services); err != nil {
+			t.Errorf("template %q: %v", name, err)
+		}
+		for filename, file := range tmpl.Files {
+			var buf bytes.Buffer
+			if err := file.Execute(&buf, cfg); err != nil {
+				t.Fatalf("%s/%s: %v", name, filename, err)
+			}
+			if _, err := format.Source(buf.Bytes()); err != nil {
+				t.Errorf("%s/%s is not valid Go: %v", name, filename, err)
+			}
+		}
+	}
+}
+
+func TestResolveProjectTemplate(t *testing.T) {
+	t.Parallel()
+
+	tmpl, err := resolveProjectTemplate(context.Background(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tmpl.Name != defaultProjectTemplate {
+		t.Errorf("expected the default template, got %q", tmpl.Name)
+	}
+
+	tmpl, err = resolveProjectTemplate(context.Background(), "fullstack")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := tmpl.Files["frontend.go"]; !ok {
+		t.Error("expected the fullstack template to contribute frontend.go")
+	}
+
+	_, err = resolveProjectTemplate(context.Background(), "serverless")
+	if err == nil || !strings.Contains(err.Error(), "event-driven") {
+		t.Errorf("expected an error listing the available templates, got %v", err)
+	}
+}
+
+func TestIsGitTemplateSource(t *testing.T) {
+	t.Parallel()
+
+	for source, want := range map[string]bool{
+		"api":          false,
+		"event-driven": false,
+		"https://github.com/example/ago-templates": true,
+		"git@github.com:example/ago-templates.git": true,
+		"../ago-templates.git#v1":                  true,
+		"ssh://git@example.com/templates.git#main": true,
+	} {
+		if got := isGitTemplateSource(source); got != want {
+			t.Errorf("isGitTemplateSource(%q) = %v, want %v", source, got, want)
+		}
+	}
+}
+
+func TestLoadProjectTemplateDir(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	writeTestFile(t, filepath.Join(dir, projectTemplateManifest), `name: worker
+description: Queue workers
+tools:
+  task: "3"
+services: [sqs, lambda]
+`)
+	writeTestFile(t, filepath.Join(dir, "infra", "cdk", "cdk", "cdk.go.tmpl"), "package main // {{.Qualifier}}\n")
+	writeTestFile(t, filepath.Join(dir, "infra", "cdk", "worker.go"), "package cdk\n")
+
+	tmpl, err := loadProjectTemplateDir(dir, "https://example.com/worker.git")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tmpl.Name != "worker" || !slices.Equal(tmpl.Services, []string{"sqs", "lambda"}) {
+		t.Errorf("unexpected template %+v", tmpl)
+	}
+	if len(tmpl.Tools) != 1 || tmpl.Tools[0] != (MiseTool{Name: "task", Version: "3"}) {
+		t.Errorf("unexpected tools %+v", tmpl.Tools)
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Files["cdk/cdk.go"].Execute(&buf, CDKConfig{Qualifier: "myapp"}); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != "package main // myapp\n" {
+		t.Errorf("unexpected entrypoint %q", buf.String())
+	}
+	if _, ok := tmpl.Files["worker.go"]; !ok {
+		t.Error("expected worker.go to be loaded")
+	}
+
+	writeTestFile(t, filepath.Join(dir, projectTemplateManifest), "services: [mainframe]\n")
+	if _, err := loadProjectTemplateDir(dir, "worker"); err == nil {
+		t.Error("expected an error for an unknown service")
+	}
+}
+
+func writeTestFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -180,6 +180,25 @@ func TestWriteMiseToml(t *testing.T) {
 			t.Errorf("mise.toml should contain granted 0.35.0, got: %s", contentStr)
 		}
 	})
+
+	t.Run("writes the extra tools of the template", func(t *testing.T) {
+		t.Parallel()
+		tmpDir := t.TempDir()
+
+		cfg := DefaultMiseConfig()
+		cfg.ExtraTools = []MiseTool{{Name: "pnpm", Version: "9"}}
+		if err := writeMiseToml(tmpDir, cfg); err != nil {
+			t.Fatalf("writeMiseToml failed: %v", err)
+		}
+
+		content, err := os.ReadFile(filepath.Join(tmpDir, "mise.toml"))
+		if err != nil {
+			t.Fatalf("failed to read mise.toml: %v", err)
+		}
+		if !strings.HasSuffix(string(content), "\"github:advdv/ago\" = \"latest\"\n\"pnpm\" = \"9\"\n") {
+			t.Errorf("mise.toml should end with pnpm 9, got: %s", content)
+		}
+	})
 }
 
 func TestCheckMiseInstalled(t *testing.T) {
@@ -0,0 +1,527 @@
+// Package awsapi gives the CLI access to the AWS APIs it calls through aws-sdk-go-v2, so that
+// commands do not need the AWS CLI to be installed. Each service is used through a narrow
+// interface that the SDK client satisfies, which lets tests substitute fakes.
+package awsapi
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
+	"github.com/aws/aws-sdk-go-v2/service/account"
+	"github.com/aws/aws-sdk-go-v2/service/apigatewaymanagementapi"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation"
+	"github.com/aws/aws-sdk-go-v2/service/cloudfront"
+	"github.com/aws/aws-sdk-go-v2/service/cloudtrail"
+	"github.com/aws/aws-sdk-go-v2/service/cognitoidentityprovider"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/ecr"
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+	"github.com/aws/aws-sdk-go-v2/service/eventbridge"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+	"github.com/aws/aws-sdk-go-v2/service/identitystore"
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
+	"github.com/aws/aws-sdk-go-v2/service/organizations"
+	"github.com/aws/aws-sdk-go-v2/service/route53"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/scheduler"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	"github.com/aws/aws-sdk-go-v2/service/servicequotas"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	"github.com/aws/aws-sdk-go-v2/service/ssoadmin"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	ststypes "github.com/aws/aws-sdk-go-v2/service/sts/types"
+	"github.com/aws/smithy-go"
+	"github.com/cockroachdb/errors"
+)
+
+// CloudFormation is the part of the CloudFormation API that the CLI uses.
+type CloudFormation interface {
+	DescribeStacks(ctx context.Context, in *cloudformation.DescribeStacksInput,
+		opts ...func(*cloudformation.Options)) (*cloudformation.DescribeStacksOutput, error)
+	DeleteStack(ctx context.Context, in *cloudformation.DeleteStackInput,
+		opts ...func(*cloudformation.Options)) (*cloudformation.DeleteStackOutput, error)
+	CreateChangeSet(ctx context.Context, in *cloudformation.CreateChangeSetInput,
+		opts ...func(*cloudformation.Options)) (*cloudformation.CreateChangeSetOutput, error)
+	DescribeChangeSet(ctx context.Context, in *cloudformation.DescribeChangeSetInput,
+		opts ...func(*cloudformation.Options)) (*cloudformation.DescribeChangeSetOutput, error)
+	ExecuteChangeSet(ctx context.Context, in *cloudformation.ExecuteChangeSetInput,
+		opts ...func(*cloudformation.Options)) (*cloudformation.ExecuteChangeSetOutput, error)
+	DeleteChangeSet(ctx context.Context, in *cloudformation.DeleteChangeSetInput,
+		opts ...func(*cloudformation.Options)) (*cloudformation.DeleteChangeSetOutput, error)
+	ListStackResources(ctx context.Context, in *cloudformation.ListStackResourcesInput,
+		opts ...func(*cloudformation.Options)) (*cloudformation.ListStackResourcesOutput, error)
+	ContinueUpdateRollback(ctx context.Context, in *cloudformation.ContinueUpdateRollbackInput,
+		opts ...func(*cloudformation.Options)) (*cloudformation.ContinueUpdateRollbackOutput, error)
+	ListExports(ctx context.Context, in *cloudformation.ListExportsInput,
+		opts ...func(*cloudformation.Options)) (*cloudformation.ListExportsOutput, error)
+	ListImports(ctx context.Context, in *cloudformation.ListImportsInput,
+		opts ...func(*cloudformation.Options)) (*cloudformation.ListImportsOutput, error)
+	ListStacks(ctx context.Context, in *cloudformation.ListStacksInput,
+		opts ...func(*cloudformation.Options)) (*cloudformation.ListStacksOutput, error)
+	GetTemplate(ctx context.Context, in *cloudformation.GetTemplateInput,
+		opts ...func(*cloudformation.Options)) (*cloudformation.GetTemplateOutput, error)
+	DescribeAccountLimits(ctx context.Context, in *cloudformation.DescribeAccountLimitsInput,
+		opts ...func(*cloudformation.Options)) (*cloudformation.DescribeAccountLimitsOutput, error)
+}
+
+// STS is the part of the STS API that the CLI uses.
+type STS interface {
+	GetCallerIdentity(ctx context.Context, in *sts.GetCallerIdentityInput,
+		opts ...func(*sts.Options)) (*sts.GetCallerIdentityOutput, error)
+}
+
+// IAM is the part of the IAM API that the CLI uses.
+type IAM interface {
+	AttachRolePolicy(ctx context.Context, in *iam.AttachRolePolicyInput,
+		opts ...func(*iam.Options)) (*iam.AttachRolePolicyOutput, error)
+	ListGroupsForUser(ctx context.Context, in *iam.ListGroupsForUserInput,
+		opts ...func(*iam.Options)) (*iam.ListGroupsForUserOutput, error)
+	GetGroup(ctx context.Context, in *iam.GetGroupInput,
+		opts ...func(*iam.Options)) (*iam.GetGroupOutput, error)
+	GetPolicy(ctx context.Context, in *iam.GetPolicyInput,
+		opts ...func(*iam.Options)) (*iam.GetPolicyOutput, error)
+	GetPolicyVersion(ctx context.Context, in *iam.GetPolicyVersionInput,
+		opts ...func(*iam.Options)) (*iam.GetPolicyVersionOutput, error)
+	ListAccessKeys(ctx context.Context, in *iam.ListAccessKeysInput,
+		opts ...func(*iam.Options)) (*iam.ListAccessKeysOutput, error)
+	GetAccountSummary(ctx context.Context, in *iam.GetAccountSummaryInput,
+		opts ...func(*iam.Options)) (*iam.GetAccountSummaryOutput, error)
+}
+
+// SSM is the part of the SSM API that the CLI uses.
+type SSM interface {
+	PutParameter(ctx context.Context, in *ssm.PutParameterInput,
+		opts ...func(*ssm.Options)) (*ssm.PutParameterOutput, error)
+	DeleteParameter(ctx context.Context, in *ssm.DeleteParameterInput,
+		opts ...func(*ssm.Options)) (*ssm.DeleteParameterOutput, error)
+	GetParametersByPath(ctx context.Context, in *ssm.GetParametersByPathInput,
+		opts ...func(*ssm.Options)) (*ssm.GetParametersByPathOutput, error)
+	GetParameter(ctx context.Context, in *ssm.GetParameterInput,
+		opts ...func(*ssm.Options)) (*ssm.GetParameterOutput, error)
+}
+
+// SecretsManager is the part of the Secrets Manager API that the CLI uses.
+type SecretsManager interface {
+	GetSecretValue(ctx context.Context, in *secretsmanager.GetSecretValueInput,
+		opts ...func(*secretsmanager.Options)) (*secretsmanager.GetSecretValueOutput, error)
+	BatchGetSecretValue(ctx context.Context, in *secretsmanager.BatchGetSecretValueInput,
+		opts ...func(*secretsmanager.Options)) (*secretsmanager.BatchGetSecretValueOutput, error)
+	PutSecretValue(ctx context.Context, in *secretsmanager.PutSecretValueInput,
+		opts ...func(*secretsmanager.Options)) (*secretsmanager.PutSecretValueOutput, error)
+	CreateSecret(ctx context.Context, in *secretsmanager.CreateSecretInput,
+		opts ...func(*secretsmanager.Options)) (*secretsmanager.CreateSecretOutput, error)
+	DescribeSecret(ctx context.Context, in *secretsmanager.DescribeSecretInput,
+		opts ...func(*secretsmanager.Options)) (*secretsmanager.DescribeSecretOutput, error)
+	ListSecrets(ctx context.Context, in *secretsmanager.ListSecretsInput,
+		opts ...func(*secretsmanager.Options)) (*secretsmanager.ListSecretsOutput, error)
+	GetRandomPassword(ctx context.Context, in *secretsmanager.GetRandomPasswordInput,
+		opts ...func(*secretsmanager.Options)) (*secretsmanager.GetRandomPasswordOutput, error)
+	ReplicateSecretToRegions(ctx context.Context, in *secretsmanager.ReplicateSecretToRegionsInput,
+		opts ...func(*secretsmanager.Options)) (*secretsmanager.ReplicateSecretToRegionsOutput, error)
+	UpdateSecretVersionStage(ctx context.Context, in *secretsmanager.UpdateSecretVersionStageInput,
+		opts ...func(*secretsmanager.Options)) (*secretsmanager.UpdateSecretVersionStageOutput, error)
+}
+
+// Route53 is the part of the Route 53 API that the CLI uses.
+type Route53 interface {
+	ListHostedZonesByName(ctx context.Context, in *route53.ListHostedZonesByNameInput,
+		opts ...func(*route53.Options)) (*route53.ListHostedZonesByNameOutput, error)
+	ListResourceRecordSets(ctx context.Context, in *route53.ListResourceRecordSetsInput,
+		opts ...func(*route53.Options)) (*route53.ListResourceRecordSetsOutput, error)
+	ChangeResourceRecordSets(ctx context.Context, in *route53.ChangeResourceRecordSetsInput,
+		opts ...func(*route53.Options)) (*route53.ChangeResourceRecordSetsOutput, error)
+	UpdateHealthCheck(ctx context.Context, in *route53.UpdateHealthCheckInput,
+		opts ...func(*route53.Options)) (*route53.UpdateHealthCheckOutput, error)
+	TestDNSAnswer(ctx context.Context, in *route53.TestDNSAnswerInput,
+		opts ...func(*route53.Options)) (*route53.TestDNSAnswerOutput, error)
+	GetAccountLimit(ctx context.Context, in *route53.GetAccountLimitInput,
+		opts ...func(*route53.Options)) (*route53.GetAccountLimitOutput, error)
+}
+
+// ECR is the part of the ECR API that the CLI uses.
+type ECR interface {
+	GetAuthorizationToken(ctx context.Context, in *ecr.GetAuthorizationTokenInput,
+		opts ...func(*ecr.Options)) (*ecr.GetAuthorizationTokenOutput, error)
+	DescribeImages(ctx context.Context, in *ecr.DescribeImagesInput,
+		opts ...func(*ecr.Options)) (*ecr.DescribeImagesOutput, error)
+	BatchGetImage(ctx context.Context, in *ecr.BatchGetImageInput,
+		opts ...func(*ecr.Options)) (*ecr.BatchGetImageOutput, error)
+	PutImage(ctx context.Context, in *ecr.PutImageInput,
+		opts ...func(*ecr.Options)) (*ecr.PutImageOutput, error)
+	ListImages(ctx context.Context, in *ecr.ListImagesInput,
+		opts ...func(*ecr.Options)) (*ecr.ListImagesOutput, error)
+	BatchDeleteImage(ctx context.Context, in *ecr.BatchDeleteImageInput,
+		opts ...func(*ecr.Options)) (*ecr.BatchDeleteImageOutput, error)
+}
+
+// Organizations is the part of the Organizations API that the CLI uses.
+type Organizations interface {
+	DescribeOrganization(ctx context.Context, in *organizations.DescribeOrganizationInput,
+		opts ...func(*organizations.Options)) (*organizations.DescribeOrganizationOutput, error)
+	CreateOrganization(ctx context.Context, in *organizations.CreateOrganizationInput,
+		opts ...func(*organizations.Options)) (*organizations.CreateOrganizationOutput, error)
+	CloseAccount(ctx context.Context, in *organizations.CloseAccountInput,
+		opts ...func(*organizations.Options)) (*organizations.CloseAccountOutput, error)
+	EnableAWSServiceAccess(ctx context.Context, in *organizations.EnableAWSServiceAccessInput,
+		opts ...func(*organizations.Options)) (*organizations.EnableAWSServiceAccessOutput, error)
+}
+
+// SQS is the part of the SQS API that the CLI uses.
+type SQS interface {
+	GetQueueAttributes(ctx context.Context, in *sqs.GetQueueAttributesInput,
+		opts ...func(*sqs.Options)) (*sqs.GetQueueAttributesOutput, error)
+	ReceiveMessage(ctx context.Context, in *sqs.ReceiveMessageInput,
+		opts ...func(*sqs.Options)) (*sqs.ReceiveMessageOutput, error)
+	ChangeMessageVisibility(ctx context.Context, in *sqs.ChangeMessageVisibilityInput,
+		opts ...func(*sqs.Options)) (*sqs.ChangeMessageVisibilityOutput, error)
+	SendMessage(ctx context.Context, in *sqs.SendMessageInput,
+		opts ...func(*sqs.Options)) (*sqs.SendMessageOutput, error)
+	DeleteMessage(ctx context.Context, in *sqs.DeleteMessageInput,
+		opts ...func(*sqs.Options)) (*sqs.DeleteMessageOutput, error)
+	StartMessageMoveTask(ctx context.Context, in *sqs.StartMessageMoveTaskInput,
+		opts ...func(*sqs.Options)) (*sqs.StartMessageMoveTaskOutput, error)
+}
+
+// EventBridge is the part of the EventBridge API that the CLI uses.
+type EventBridge interface {
+	PutEvents(ctx context.Context, in *eventbridge.PutEventsInput,
+		opts ...func(*eventbridge.Options)) (*eventbridge.PutEventsOutput, error)
+	DescribeArchive(ctx context.Context, in *eventbridge.DescribeArchiveInput,
+		opts ...func(*eventbridge.Options)) (*eventbridge.DescribeArchiveOutput, error)
+	StartReplay(ctx context.Context, in *eventbridge.StartReplayInput,
+		opts ...func(*eventbridge.Options)) (*eventbridge.StartReplayOutput, error)
+}
+
+// S3 is the part of the S3 API that the CLI uses.
+type S3 interface {
+	HeadBucket(ctx context.Context, in *s3.HeadBucketInput,
+		opts ...func(*s3.Options)) (*s3.HeadBucketOutput, error)
+	CreateBucket(ctx context.Context, in *s3.CreateBucketInput,
+		opts ...func(*s3.Options)) (*s3.CreateBucketOutput, error)
+	GetBucketVersioning(ctx context.Context, in *s3.GetBucketVersioningInput,
+		opts ...func(*s3.Options)) (*s3.GetBucketVersioningOutput, error)
+	ListObjectsV2(ctx context.Context, in *s3.ListObjectsV2Input,
+		opts ...func(*s3.Options)) (*s3.ListObjectsV2Output, error)
+	ListObjectVersions(ctx context.Context, in *s3.ListObjectVersionsInput,
+		opts ...func(*s3.Options)) (*s3.ListObjectVersionsOutput, error)
+	GetObject(ctx context.Context, in *s3.GetObjectInput,
+		opts ...func(*s3.Options)) (*s3.GetObjectOutput, error)
+	PutObject(ctx context.Context, in *s3.PutObjectInput,
+		opts ...func(*s3.Options)) (*s3.PutObjectOutput, error)
+	DeleteObjects(ctx context.Context, in *s3.DeleteObjectsInput,
+		opts ...func(*s3.Options)) (*s3.DeleteObjectsOutput, error)
+}
+
+// CloudFront is the part of the CloudFront API that the CLI uses.
+type CloudFront interface {
+	CreateInvalidation(ctx context.Context, in *cloudfront.CreateInvalidationInput,
+		opts ...func(*cloudfront.Options)) (*cloudfront.CreateInvalidationOutput, error)
+	GetInvalidation(ctx context.Context, in *cloudfront.GetInvalidationInput,
+		opts ...func(*cloudfront.Options)) (*cloudfront.GetInvalidationOutput, error)
+}
+
+// DynamoDB is the part of the DynamoDB API that the CLI uses.
+type DynamoDB interface {
+	DescribeTable(ctx context.Context, in *dynamodb.DescribeTableInput,
+		opts ...func(*dynamodb.Options)) (*dynamodb.DescribeTableOutput, error)
+	CreateTable(ctx context.Context, in *dynamodb.CreateTableInput,
+		opts ...func(*dynamodb.Options)) (*dynamodb.CreateTableOutput, error)
+	Scan(ctx context.Context, in *dynamodb.ScanInput,
+		opts ...func(*dynamodb.Options)) (*dynamodb.ScanOutput, error)
+	DescribeContinuousBackups(ctx context.Context, in *dynamodb.DescribeContinuousBackupsInput,
+		opts ...func(*dynamodb.Options)) (*dynamodb.DescribeContinuousBackupsOutput, error)
+	ListBackups(ctx context.Context, in *dynamodb.ListBackupsInput,
+		opts ...func(*dynamodb.Options)) (*dynamodb.ListBackupsOutput, error)
+}
+
+// Lambda is the part of the Lambda API that the CLI uses.
+type Lambda interface {
+	Invoke(ctx context.Context, in *lambda.InvokeInput,
+		opts ...func(*lambda.Options)) (*lambda.InvokeOutput, error)
+	GetAccountSettings(ctx context.Context, in *lambda.GetAccountSettingsInput,
+		opts ...func(*lambda.Options)) (*lambda.GetAccountSettingsOutput, error)
+	ListFunctions(ctx context.Context, in *lambda.ListFunctionsInput,
+		opts ...func(*lambda.Options)) (*lambda.ListFunctionsOutput, error)
+	UpdateFunctionConfiguration(ctx context.Context, in *lambda.UpdateFunctionConfigurationInput,
+		opts ...func(*lambda.Options)) (*lambda.UpdateFunctionConfigurationOutput, error)
+}
+
+// ECS is the part of the ECS API that the CLI uses.
+type ECS interface {
+	RunTask(ctx context.Context, in *ecs.RunTaskInput,
+		opts ...func(*ecs.Options)) (*ecs.RunTaskOutput, error)
+}
+
+// Scheduler is the part of the EventBridge Scheduler API that the CLI uses.
+type Scheduler interface {
+	GetSchedule(ctx context.Context, in *scheduler.GetScheduleInput,
+		opts ...func(*scheduler.Options)) (*scheduler.GetScheduleOutput, error)
+	ListSchedules(ctx context.Context, in *scheduler.ListSchedulesInput,
+		opts ...func(*scheduler.Options)) (*scheduler.ListSchedulesOutput, error)
+	UpdateSchedule(ctx context.Context, in *scheduler.UpdateScheduleInput,
+		opts ...func(*scheduler.Options)) (*scheduler.UpdateScheduleOutput, error)
+}
+
+// Cognito is the part of the Cognito user pools API that the CLI uses.
+type Cognito interface {
+	AdminCreateUser(ctx context.Context, in *cognitoidentityprovider.AdminCreateUserInput,
+		opts ...func(*cognitoidentityprovider.Options)) (*cognitoidentityprovider.AdminCreateUserOutput, error)
+	ListUsers(ctx context.Context, in *cognitoidentityprovider.ListUsersInput,
+		opts ...func(*cognitoidentityprovider.Options)) (*cognitoidentityprovider.ListUsersOutput, error)
+	AdminSetUserPassword(ctx context.Context, in *cognitoidentityprovider.AdminSetUserPasswordInput,
+		opts ...func(*cognitoidentityprovider.Options)) (*cognitoidentityprovider.AdminSetUserPasswordOutput, error)
+}
+
+// CloudTrail is the part of the CloudTrail API that the CLI uses.
+type CloudTrail interface {
+	DescribeTrails(ctx context.Context, in *cloudtrail.DescribeTrailsInput,
+		opts ...func(*cloudtrail.Options)) (*cloudtrail.DescribeTrailsOutput, error)
+	GetTrailStatus(ctx context.Context, in *cloudtrail.GetTrailStatusInput,
+		opts ...func(*cloudtrail.Options)) (*cloudtrail.GetTrailStatusOutput, error)
+	LookupEvents(ctx context.Context, in *cloudtrail.LookupEventsInput,
+		opts ...func(*cloudtrail.Options)) (*cloudtrail.LookupEventsOutput, error)
+}
+
+// ServiceQuotas is the part of the Service Quotas API that the CLI uses.
+type ServiceQuotas interface {
+	RequestServiceQuotaIncrease(ctx context.Context, in *servicequotas.RequestServiceQuotaIncreaseInput,
+		opts ...func(*servicequotas.Options)) (*servicequotas.RequestServiceQuotaIncreaseOutput, error)
+}
+
+// Account is the part of the Account Management API that the CLI uses.
+type Account interface {
+	PutAccountName(ctx context.Context, in *account.PutAccountNameInput,
+		opts ...func(*account.Options)) (*account.PutAccountNameOutput, error)
+	PutAlternateContact(ctx context.Context, in *account.PutAlternateContactInput,
+		opts ...func(*account.Options)) (*account.PutAlternateContactOutput, error)
+}
+
+// SSOAdmin is the part of the IAM Identity Center admin API that the CLI uses.
+type SSOAdmin interface {
+	ListInstances(ctx context.Context, in *ssoadmin.ListInstancesInput,
+		opts ...func(*ssoadmin.Options)) (*ssoadmin.ListInstancesOutput, error)
+}
+
+// IdentityStore is the part of the Identity Store API that the CLI uses.
+type IdentityStore interface {
+	GetUserId(ctx context.Context, in *identitystore.GetUserIdInput,
+		opts ...func(*identitystore.Options)) (*identitystore.GetUserIdOutput, error)
+}
+
+// APIGatewayManagement is the part of the API Gateway management API that the CLI uses. Its
+// endpoint is the callback URL of a WebSocket API, see NewAPIGatewayManagement.
+type APIGatewayManagement interface {
+	PostToConnection(ctx context.Context, in *apigatewaymanagementapi.PostToConnectionInput,
+		opts ...func(*apigatewaymanagementapi.Options)) (*apigatewaymanagementapi.PostToConnectionOutput, error)
+}
+
+// Clients holds a client for every service, all for the same profile and region.
+type Clients struct {
+	Region         string
+	CloudFormation CloudFormation
+	STS            STS
+	IAM            IAM
+	SSM            SSM
+	SecretsManager SecretsManager
+	Route53        Route53
+	ECR            ECR
+	Organizations  Organizations
+	SQS            SQS
+	EventBridge    EventBridge
+	S3             S3
+	CloudFront     CloudFront
+	DynamoDB       DynamoDB
+	Lambda         Lambda
+	ECS            ECS
+	Scheduler      Scheduler
+	Cognito        Cognito
+	CloudTrail     CloudTrail
+	ServiceQuotas  ServiceQuotas
+	Account        Account
+	SSOAdmin       SSOAdmin
+	IdentityStore  IdentityStore
+
+	// DryRun is set when the clients skip mutating operations, see EnableDryRun.
+	DryRun bool
+}
+
+// New returns the clients for a profile from the shared AWS config. An empty region uses the
+// profile's region.
+func New(ctx context.Context, profile, region string) (*Clients, error) {
+	cfg, err := loadConfig(ctx, profile, region)
+	if err != nil {
+		return nil, err
+	}
+	return fromLoadedConfig(cfg), nil
+}
+
+// SessionRole is a role that clients assume, and what limits the session.
+type SessionRole struct {
+	// ARN is the role to assume.
+	ARN string
+	// SessionName shows in CloudTrail as the name of the session.
+	SessionName string
+	// Policy is an inline session policy: the session may only do what both the role and the
+	// policy allow.
+	Policy string
+	// Tags are the session tags, which the role's policies may condition on.
+	Tags map[string]string
+}
+
+// NewWithRole returns clients that act through a session of the role, assumed with the
+// credentials of the profile. The session is renewed when it expires.
+func NewWithRole(ctx context.Context, profile, region string, role SessionRole) (*Clients, error) {
+	cfg, err := loadConfig(ctx, profile, region)
+	if err != nil {
+		return nil, err
+	}
+
+	tags := make([]ststypes.Tag, 0, len(role.Tags))
+	for key, value := range role.Tags {
+		tags = append(tags, ststypes.Tag{Key: aws.String(key), Value: aws.String(value)})
+	}
+	provider := stscreds.NewAssumeRoleProvider(sts.NewFromConfig(cfg), role.ARN,
+		func(o *stscreds.AssumeRoleOptions) {
+			o.RoleSessionName = role.SessionName
+			o.Tags = tags
+			if role.Policy != "" {
+				o.Policy = aws.String(role.Policy)
+			}
+		})
+	cfg.Credentials = aws.NewCredentialsCache(provider)
+
+	// Fail here rather than at the first call when the role cannot be assumed.
+	if _, err := cfg.Credentials.Retrieve(ctx); err != nil {
+		return nil, errors.Wrapf(err, "failed to assume role %s", role.ARN)
+	}
+	return fromLoadedConfig(cfg), nil
+}
+
+func loadConfig(ctx context.Context, profile, region string) (aws.Config, error) {
+	var opts []func(*config.LoadOptions) error
+	if profile != "" {
+		opts = append(opts, config.WithSharedConfigProfile(profile))
+	}
+	if region != "" {
+		opts = append(opts, config.WithRegion(region))
+	}
+
+	cfg, err := config.LoadDefaultConfig(ctx, opts...)
+	if err != nil {
+		return aws.Config{}, errors.Wrapf(err, "failed to load AWS config for profile %q", profile)
+	}
+
+	sessionsMu.Lock()
+	defer sessionsMu.Unlock()
+	if credentials, ok := sessions[profile]; ok {
+		cfg.Credentials = credentials
+	} else {
+		sessions[profile] = cfg.Credentials
+	}
+	return cfg, nil
+}
+
+// sessions holds the credentials that loadConfig resolved for each profile. Clients for another
+// region of the same profile share them, so a command that works in several regions resolves
+// its credentials once, rather than assuming a role or prompting for MFA per region.
+var (
+	sessionsMu sync.Mutex
+	sessions   = map[string]aws.CredentialsProvider{}
+)
+
+// fromLoadedConfig returns the clients for a config that New loaded, in dry-run mode when it is
+// enabled.
+func fromLoadedConfig(cfg aws.Config) *Clients {
+	if dryRunOutput == nil {
+		return FromConfig(cfg)
+	}
+
+	cfg.APIOptions = append(cfg.APIOptions, withDryRun(dryRunOutput))
+	clients := FromConfig(cfg)
+	clients.DryRun = true
+	return clients
+}
+
+// FromConfig returns the clients for an SDK config.
+func FromConfig(cfg aws.Config) *Clients {
+	return &Clients{
+		Region:         cfg.Region,
+		CloudFormation: cloudformation.NewFromConfig(cfg),
+		STS:            sts.NewFromConfig(cfg),
+		IAM:            iam.NewFromConfig(cfg),
+		SSM:            ssm.NewFromConfig(cfg),
+		SecretsManager: secretsmanager.NewFromConfig(cfg),
+		Route53:        route53.NewFromConfig(cfg),
+		ECR:            ecr.NewFromConfig(cfg),
+		Organizations:  organizations.NewFromConfig(cfg),
+		SQS:            sqs.NewFromConfig(cfg),
+		EventBridge:    eventbridge.NewFromConfig(cfg),
+		S3:             s3.NewFromConfig(cfg),
+		CloudFront:     cloudfront.NewFromConfig(cfg),
+		DynamoDB:       dynamodb.NewFromConfig(cfg),
+		Lambda:         lambda.NewFromConfig(cfg),
+		ECS:            ecs.NewFromConfig(cfg),
+		Scheduler:      scheduler.NewFromConfig(cfg),
+		Cognito:        cognitoidentityprovider.NewFromConfig(cfg),
+		CloudTrail:     cloudtrail.NewFromConfig(cfg),
+		ServiceQuotas:  servicequotas.NewFromConfig(cfg),
+		Account:        account.NewFromConfig(cfg),
+		SSOAdmin:       ssoadmin.NewFromConfig(cfg),
+		IdentityStore:  identitystore.NewFromConfig(cfg),
+	}
+}
+
+// NewLocal returns the clients for local emulators of the AWS services, such as DynamoDB Local
+// and MinIO, that serve at endpoint and accept static credentials. S3 addresses buckets by path
+// there, since the emulators have no per-bucket host names.
+func NewLocal(region, endpoint, accessKeyID, secretAccessKey string) *Clients {
+	cfg := aws.Config{
+		Region:       region,
+		BaseEndpoint: aws.String(endpoint),
+		Credentials:  credentials.NewStaticCredentialsProvider(accessKeyID, secretAccessKey, ""),
+	}
+	if dryRunOutput != nil {
+		cfg.APIOptions = append(cfg.APIOptions, withDryRun(dryRunOutput))
+	}
+	clients := FromConfig(cfg)
+	clients.S3 = s3.NewFromConfig(cfg, func(o *s3.Options) { o.UsePathStyle = true })
+	clients.DryRun = dryRunOutput != nil
+	return clients
+}
+
+// NewAPIGatewayManagement returns a client of the API Gateway management API of a WebSocket API,
+// which is reached at the callback URL of the API.
+func NewAPIGatewayManagement(
+	ctx context.Context, profile, region, callbackURL string,
+) (APIGatewayManagement, error) {
+	cfg, err := loadConfig(ctx, profile, region)
+	if err != nil {
+		return nil, err
+	}
+	if dryRunOutput != nil {
+		cfg.APIOptions = append(cfg.APIOptions, withDryRun(dryRunOutput))
+	}
+	return apigatewaymanagementapi.NewFromConfig(cfg, func(o *apigatewaymanagementapi.Options) {
+		o.BaseEndpoint = aws.String(callbackURL)
+	}), nil
+}
+
+// IsStackNotFound reports whether the error is CloudFormation's answer for a stack that does
+// not exist.
+func IsStackNotFound(err error) bool {
+	var apiErr smithy.APIError
+	return errors.As(err, &apiErr) && apiErr.ErrorCode() == "ValidationError" &&
+		strings.Contains(apiErr.ErrorMessage(), "does not exist")
+}
+
+// IsErrorCode reports whether the error is an AWS API error with the given code.
+func IsErrorCode(err error, code string) bool {
+	var apiErr smithy.APIError
+	return errors.As(err, &apiErr) && apiErr.ErrorCode() == code
+}
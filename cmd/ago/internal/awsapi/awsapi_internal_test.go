@@ -0,0 +1,36 @@
+package awsapi
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+//nolint:paralleltest // the test points the shared config at a temporary file
+func TestLoadConfigSharesSession(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config")
+	profile := "awsapi-session-test"
+	data := "[profile " + profile + "]\naws_access_key_id = AKID\naws_secret_access_key = secret\n"
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("AWS_CONFIG_FILE", path)
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", filepath.Join(t.TempDir(), "credentials"))
+
+	primary, err := loadConfig(context.Background(), profile, "eu-central-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	secondary, err := loadConfig(context.Background(), profile, "eu-west-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if secondary.Region != "eu-west-1" {
+		t.Errorf("expected region eu-west-1, got %q", secondary.Region)
+	}
+	if primary.Credentials != secondary.Credentials {
+		t.Error("expected both regions to share the credentials of the profile")
+	}
+}
@@ -0,0 +1,46 @@
+package awsapi_test
+
+import (
+	"testing"
+
+	"github.com/advdv/ago/cmd/ago/internal/awsapi"
+	"github.com/aws/smithy-go"
+	"github.com/cockroachdb/errors"
+)
+
+func TestIsStackNotFound(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{
+			name: "missing stack",
+			err: errors.Wrap(&smithy.GenericAPIError{
+				Code: "ValidationError", Message: "Stack with id myapp-pre-bootstrap does not exist",
+			}, "describe stacks"),
+			want: true,
+		},
+		{
+			name: "other validation error",
+			err:  &smithy.GenericAPIError{Code: "ValidationError", Message: "Template format error"},
+		},
+		{
+			name: "other error",
+			err:  &smithy.GenericAPIError{Code: "ExpiredToken", Message: "does not exist"},
+		},
+		{name: "no error"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := awsapi.IsStackNotFound(tt.err); got != tt.want {
+				t.Errorf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
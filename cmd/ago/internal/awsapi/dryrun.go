@@ -0,0 +1,78 @@
+package awsapi
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	awsmiddleware "github.com/aws/aws-sdk-go-v2/aws/middleware"
+	"github.com/aws/smithy-go/middleware"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+)
+
+// dryRunOutput is where clients created with New report the operations they skip. It is nil, and
+// dry-run disabled, until EnableDryRun is called.
+var dryRunOutput io.Writer
+
+// EnableDryRun makes clients created with New report operations that mutate state to w, with
+// their input, instead of calling them. A skipped operation returns an empty output; Clients.DryRun
+// tells callers not to wait for its effect. Operations that only read state still call AWS.
+func EnableDryRun(w io.Writer) {
+	dryRunOutput = w
+}
+
+type dryRunSkipKey struct{}
+
+// withDryRun returns an API option that reports mutating operations to w and answers them with an
+// empty response instead of sending them. The response is produced below the deserializer, so
+// every operation returns its own, zero, output type.
+func withDryRun(w io.Writer) func(*middleware.Stack) error {
+	return func(stack *middleware.Stack) error {
+		report := middleware.InitializeMiddlewareFunc("DryRunReport", func(
+			ctx context.Context, in middleware.InitializeInput, next middleware.InitializeHandler,
+		) (middleware.InitializeOutput, middleware.Metadata, error) {
+			operation := awsmiddleware.GetOperationName(ctx)
+			if readOnlyOperation(operation) {
+				return next.HandleInitialize(ctx, in)
+			}
+
+			input, err := json.MarshalIndent(in.Parameters, "  ", "  ")
+			if err != nil {
+				input = []byte(fmt.Sprintf("%+v", in.Parameters))
+			}
+			_, _ = fmt.Fprintf(w, "[dry-run] would call %s.%s\n  %s\n", awsmiddleware.GetServiceID(ctx), operation, input)
+
+			return next.HandleInitialize(middleware.WithStackValue(ctx, dryRunSkipKey{}, true), in)
+		})
+		if err := stack.Initialize.Add(report, middleware.After); err != nil {
+			return err
+		}
+
+		skip := middleware.DeserializeMiddlewareFunc("DryRunSkip", func(
+			ctx context.Context, in middleware.DeserializeInput, next middleware.DeserializeHandler,
+		) (middleware.DeserializeOutput, middleware.Metadata, error) {
+			if skip, _ := middleware.GetStackValue(ctx, dryRunSkipKey{}).(bool); !skip {
+				return next.HandleDeserialize(ctx, in)
+			}
+			return middleware.DeserializeOutput{RawResponse: &smithyhttp.Response{Response: &http.Response{
+				StatusCode: http.StatusOK,
+				Header:     http.Header{},
+				Body:       http.NoBody,
+			}}}, middleware.Metadata{}, nil
+		})
+		return stack.Deserialize.Add(skip, middleware.After)
+	}
+}
+
+// readOnlyOperation reports whether an API operation only reads state.
+func readOnlyOperation(operation string) bool {
+	for _, prefix := range []string{"Describe", "Get", "BatchGet", "List", "Head", "Scan", "Lookup", "TestDNS"} {
+		if strings.HasPrefix(operation, prefix) {
+			return true
+		}
+	}
+	return false
+}
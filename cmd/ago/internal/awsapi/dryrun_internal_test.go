@@ -0,0 +1,64 @@
+package awsapi
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/smithy-go/middleware"
+)
+
+func TestWithDryRun(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	cfg := aws.Config{
+		Region: "us-east-1",
+		Credentials: aws.CredentialsProviderFunc(func(context.Context) (aws.Credentials, error) {
+			return aws.Credentials{AccessKeyID: "AKID", SecretAccessKey: "secret"}, nil
+		}),
+		// Any request that is sent fails, so the test also proves nothing reaches AWS.
+		BaseEndpoint:     aws.String("http://127.0.0.1:1"),
+		RetryMaxAttempts: 1,
+		APIOptions:       []func(*middleware.Stack) error{withDryRun(&buf)},
+	}
+	clients := FromConfig(cfg)
+
+	if _, err := clients.CloudFormation.DeleteStack(context.Background(), &cloudformation.DeleteStackInput{
+		StackName: aws.String("myapp-pre-bootstrap"),
+	}); err != nil {
+		t.Fatalf("unexpected error from skipped query operation: %v", err)
+	}
+	out, err := clients.SQS.SendMessage(context.Background(), &sqs.SendMessageInput{
+		QueueUrl: aws.String("https://sqs/orders"), MessageBody: aws.String("{}"),
+	})
+	if err != nil {
+		t.Fatalf("unexpected error from skipped JSON operation: %v", err)
+	}
+	if out.MessageId != nil {
+		t.Errorf("expected an empty output, got message ID %q", *out.MessageId)
+	}
+
+	if _, err := clients.CloudFormation.DescribeStacks(context.Background(),
+		&cloudformation.DescribeStacksInput{}); err == nil {
+		t.Error("expected read operation to be sent")
+	}
+
+	got := buf.String()
+	for _, want := range []string{
+		"[dry-run] would call CloudFormation.DeleteStack",
+		`"StackName": "myapp-pre-bootstrap"`,
+		"[dry-run] would call SQS.SendMessage",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("expected output to contain %q, got:\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, "DescribeStacks") {
+		t.Errorf("read operation was reported:\n%s", got)
+	}
+}
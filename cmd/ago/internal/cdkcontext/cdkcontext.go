@@ -0,0 +1,260 @@
+// Package cdkcontext reads and writes the CDK context of a project: the settings of cdk.json and
+// the context of cdk.context.json, whose ago-owned keys are stored as {prefix}{name}.
+//
+// Keys lists every ago-owned key with its schema. Load decodes the keys that most commands read
+// into a typed Context, and Update edits cdk.context.json in place, keeping the formatting of what
+// it does not change.
+package cdkcontext
+
+import (
+	"maps"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/advdv/ago/cmd/ago/internal/schema"
+	"github.com/cockroachdb/errors"
+)
+
+// Names of the files that hold the CDK context.
+const (
+	FileName     = "cdk.context.json"
+	JSONFileName = "cdk.json"
+)
+
+// Context is the merged context of cdk.json and cdk.context.json.
+type Context struct {
+	// Prefix is the prefix of the ago-owned keys, such as "myapp-".
+	Prefix string
+	// Qualifier is the CDK bootstrap qualifier, also the first part of every stack name.
+	Qualifier string
+	// PrimaryRegion is the region of the primary shared and deployment stacks.
+	PrimaryRegion string
+	// SecondaryRegions are the regions that every deployment is replicated to.
+	SecondaryRegions []string
+	// Deployments are the deployment identifiers, such as Dev, Stag and Prod.
+	Deployments []string
+	// Deployers and DevDeployers are the IAM users with full and development deployer permissions.
+	Deployers    []string
+	DevDeployers []string
+	// Services are the AWS services that deployers may use, nil when the project does not list them.
+	Services []string
+	// BaseDomainName is the domain of the project's hosted zone.
+	BaseDomainName string
+	// DNSDelegated reports whether the hosted zone is delegated from its parent zone.
+	DNSDelegated bool
+	// Profile and AdminProfile are the AWS profiles of cdk.json.
+	Profile      string
+	AdminProfile string
+	// ImageTags and ImageDigests map deployments to image names to the tag and digest that
+	// build-and-push or promote recorded for them.
+	ImageTags    map[string]map[string]string
+	ImageDigests map[string]map[string]string
+
+	// Values holds every key of both files, for the keys that have no field.
+	Values map[string]any
+}
+
+// Load reads the CDK context from the cdk.json and cdk.context.json in dir. Keys of
+// cdk.context.json win over those of cdk.json.
+func Load(dir string) (*Context, error) {
+	values := map[string]any{}
+	for _, name := range []string{JSONFileName, FileName} {
+		f, err := ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			return nil, err
+		}
+		fileValues, err := f.Values()
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to parse %s", name)
+		}
+		maps.Copy(values, fileValues)
+	}
+	return New(values)
+}
+
+// New decodes the typed fields of a Context from the values of the CDK context. Values of the
+// wrong type leave their field empty; Validate reports them.
+func New(values map[string]any) (*Context, error) {
+	prefix, err := DetectPrefix(values)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Context{Prefix: prefix, Values: values}
+	c.Qualifier = c.String("qualifier")
+	if c.Qualifier == "" {
+		return nil, errors.Errorf("qualifier not found at context key %q", c.Key("qualifier"))
+	}
+	c.PrimaryRegion = c.String("primary-region")
+	c.SecondaryRegions = c.Strings("secondary-regions")
+	c.Deployments = c.Strings("deployments")
+	c.Deployers = c.Strings("deployers")
+	c.DevDeployers = c.Strings("dev-deployers")
+	if _, ok := values[c.Key("services")]; ok {
+		c.Services = c.Strings("services")
+	}
+	c.BaseDomainName = c.String("base-domain-name")
+	c.DNSDelegated, _ = values[c.Key("dns-delegated")].(bool)
+	c.ImageTags = c.StringMaps("image-tags")
+	c.ImageDigests = c.StringMaps("image-digests")
+	c.Profile, _ = values["profile"].(string)
+	c.AdminProfile, _ = values["admin-profile"].(string)
+	return c, nil
+}
+
+// DetectPrefix returns the prefix of the ago-owned keys, which is whatever comes before the key
+// that ends in "qualifier".
+func DetectPrefix(values map[string]any) (string, error) {
+	for _, key := range slices.Sorted(maps.Keys(values)) {
+		if prefix, ok := strings.CutSuffix(key, "qualifier"); ok && prefix != "" {
+			return prefix, nil
+		}
+	}
+	return "", errors.New("could not detect context prefix - no key ending with 'qualifier' found")
+}
+
+// Key returns the context key of an ago-owned name, such as "myapp-deployments".
+func (c *Context) Key(name string) string {
+	return c.Prefix + name
+}
+
+// String returns the string at the prefixed key of name, or "" when it is not a set string.
+func (c *Context) String(name string) string {
+	s, _ := c.Values[c.Key(name)].(string)
+	return s
+}
+
+// Strings returns the strings of the list at the prefixed key of name. Items that are not
+// strings are skipped.
+func (c *Context) Strings(name string) []string {
+	list, ok := c.Values[c.Key(name)].([]any)
+	if !ok {
+		return nil
+	}
+	result := make([]string, 0, len(list))
+	for _, v := range list {
+		if s, ok := v.(string); ok {
+			result = append(result, s)
+		}
+	}
+	return result
+}
+
+// StringMaps returns the maps of strings in the object at the prefixed key of name, such as the
+// image tags by deployment. Values that are not non-empty strings are skipped.
+func (c *Context) StringMaps(name string) map[string]map[string]string {
+	object, _ := c.Values[c.Key(name)].(map[string]any)
+	result := make(map[string]map[string]string, len(object))
+	for key, value := range object {
+		m, _ := value.(map[string]any)
+		entries := make(map[string]string, len(m))
+		for k, v := range m {
+			if s, ok := v.(string); ok && s != "" {
+				entries[k] = s
+			}
+		}
+		result[key] = entries
+	}
+	return result
+}
+
+// Regions returns the primary region followed by the secondary regions.
+func (c *Context) Regions() []string {
+	return append([]string{c.PrimaryRegion}, c.SecondaryRegions...)
+}
+
+// Require returns an error for the first name whose prefixed key is not set to a non-empty
+// string.
+func (c *Context) Require(names ...string) error {
+	for _, name := range names {
+		value, ok := c.Values[c.Key(name)]
+		switch s, isString := value.(string); {
+		case !ok:
+			return errors.Errorf("context key %q not found", c.Key(name))
+		case !isString:
+			return errors.Errorf("context key %q is not a string", c.Key(name))
+		case s == "":
+			return errors.Errorf("context key %q is empty", c.Key(name))
+		}
+	}
+	return nil
+}
+
+// Validate checks the values of the context against a schema, such as the schema of the
+// ago-owned keys, so every problem is reported before any command acts on the context.
+func (c *Context) Validate(s *schema.Schema) error {
+	if err := s.Validate(c.Values); err != nil {
+		return errors.Wrap(err, "invalid CDK context")
+	}
+	return nil
+}
+
+// Update reads the cdk.context.json in dir, lets fn change its values and writes back the keys
+// that fn changed, added or deleted. The other keys keep their order and formatting. Nothing is
+// written when fn returns an error or changes nothing.
+func Update(dir string, fn func(values map[string]any, prefix string) error) error {
+	f, err := ReadFile(filepath.Join(dir, FileName))
+	if err != nil {
+		return err
+	}
+	values, err := f.Values()
+	if err != nil {
+		return errors.Wrapf(err, "failed to parse %s", FileName)
+	}
+	prefix, err := DetectPrefix(values)
+	if err != nil {
+		return err
+	}
+
+	before := f.Bytes()
+	if err := fn(values, prefix); err != nil {
+		return err
+	}
+	for _, key := range f.Keys() {
+		if _, ok := values[key]; !ok {
+			f.Delete(key)
+		}
+	}
+	for _, key := range slices.Sorted(maps.Keys(values)) {
+		if err := f.Set(key, values[key]); err != nil {
+			return err
+		}
+	}
+
+	if string(f.Bytes()) == string(before) {
+		return nil
+	}
+	return f.Write()
+}
+
+// UpdateImages records the image tags and digests of a deployment in the cdk.context.json in dir,
+// under the image-tags and image-digests keys that CDK code reads with agcdkutil.ImageTag and
+// agcdkutil.ImageDigest. Images of the deployment that are not given keep their values.
+func UpdateImages(dir, deployment string, tags, digests map[string]string) error {
+	return Update(dir, func(values map[string]any, prefix string) error {
+		mergeImages(values, prefix+"image-tags", deployment, tags)
+		if len(digests) > 0 {
+			mergeImages(values, prefix+"image-digests", deployment, digests)
+		}
+		return nil
+	})
+}
+
+// mergeImages sets the values of the images of a deployment in a key that maps deployments to
+// image names to values, and keeps the values of other images.
+func mergeImages(values map[string]any, key, deployment string, images map[string]string) {
+	all, _ := values[key].(map[string]any)
+	if all == nil {
+		all = map[string]any{}
+	}
+	deploymentValues, _ := all[deployment].(map[string]any)
+	if deploymentValues == nil {
+		deploymentValues = map[string]any{}
+	}
+	for name, value := range images {
+		deploymentValues[name] = value
+	}
+	all[deployment] = deploymentValues
+	values[key] = all
+}
@@ -0,0 +1,253 @@
+package cdkcontext_test
+
+import (
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/advdv/ago/cmd/ago/internal/cdkcontext"
+	"github.com/advdv/ago/cmd/ago/internal/schema"
+)
+
+// writeProject writes a cdk.json and a cdk.context.json to a new directory.
+func writeProject(t *testing.T, cdkJSON, contextJSON string) string {
+	t.Helper()
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, cdkcontext.JSONFileName), []byte(cdkJSON), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, cdkcontext.FileName), []byte(contextJSON), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func TestLoad(t *testing.T) {
+	t.Parallel()
+
+	dir := writeProject(t, `{"app": "go run .", "profile": "myapp-dev", "admin-profile": "myapp-admin"}`, `{
+  "myapp-qualifier": "myapp",
+  "myapp-primary-region": "eu-central-1",
+  "myapp-secondary-regions": ["eu-west-1"],
+  "myapp-deployments": ["Dev", "Prod"],
+  "myapp-deployers": ["Adam"],
+  "myapp-dev-deployers": ["Bob", 3],
+  "myapp-base-domain-name": "example.com",
+  "myapp-dns-delegated": true,
+  "myapp-image-tags": {"Dev": {"api": "api-Dev-abc", "bogus": 42}},
+  "profile": "myapp-ci"
+}`)
+
+	c, err := cdkcontext.Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Prefix != "myapp-" || c.Qualifier != "myapp" || c.BaseDomainName != "example.com" || !c.DNSDelegated {
+		t.Errorf("unexpected context: %+v", c)
+	}
+	if got := c.Regions(); !slices.Equal(got, []string{"eu-central-1", "eu-west-1"}) {
+		t.Errorf("Regions() = %v", got)
+	}
+	if !slices.Equal(c.Deployments, []string{"Dev", "Prod"}) || !slices.Equal(c.DevDeployers, []string{"Bob"}) {
+		t.Errorf("unexpected lists: %v %v", c.Deployments, c.DevDeployers)
+	}
+	if c.Services != nil {
+		t.Errorf("expected no services, got %v", c.Services)
+	}
+	if c.Profile != "myapp-ci" || c.AdminProfile != "myapp-admin" {
+		t.Errorf("expected cdk.context.json to win over cdk.json, got %q %q", c.Profile, c.AdminProfile)
+	}
+	if len(c.ImageTags["Dev"]) != 1 || c.ImageTags["Dev"]["api"] != "api-Dev-abc" || c.ImageTags["Prod"] != nil {
+		t.Errorf("unexpected image tags: %v", c.ImageTags)
+	}
+	if c.Values["app"] != "go run ." {
+		t.Errorf("expected the other keys in Values, got %v", c.Values)
+	}
+
+	if err := c.Require("qualifier", "primary-region"); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := c.Require("management-profile"); err == nil || !strings.Contains(err.Error(), "myapp-management-profile") {
+		t.Errorf("expected error for missing key, got %v", err)
+	}
+}
+
+func TestNew(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name       string
+		values     map[string]any
+		wantPrefix string
+		wantErr    string
+	}{
+		{
+			name:       "finds qualifier prefix",
+			values:     map[string]any{"myapp-qualifier": "myapp"},
+			wantPrefix: "myapp-",
+		},
+		{
+			name:       "finds qualifier with different prefix",
+			values:     map[string]any{"other-qualifier": "other"},
+			wantPrefix: "other-",
+		},
+		{
+			name:    "no qualifier",
+			values:  map[string]any{"something": "value"},
+			wantErr: "could not detect context prefix",
+		},
+		{
+			name:    "empty qualifier",
+			values:  map[string]any{"myapp-qualifier": ""},
+			wantErr: `qualifier not found at context key "myapp-qualifier"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			c, err := cdkcontext.New(tt.values)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("expected error containing %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if c.Prefix != tt.wantPrefix {
+				t.Errorf("expected prefix %q, got %q", tt.wantPrefix, c.Prefix)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	t.Parallel()
+
+	c, err := cdkcontext.New(map[string]any{"myapp-qualifier": "myapp", "myapp-dns-delegated": "yes"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &schema.Schema{
+		Type:       schema.TypeObject,
+		Properties: map[string]*schema.Schema{"myapp-dns-delegated": schema.Boolean()},
+	}
+	if err := c.Validate(s); err == nil || !strings.Contains(err.Error(), "myapp-dns-delegated") {
+		t.Errorf("expected error for invalid key, got %v", err)
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	t.Parallel()
+
+	initial := `{
+    "myapp-qualifier": "myapp",
+    "myapp-deployments": [ "Dev",   "Prod" ],
+    "myapp-image-tags": {"Dev": {"api": "api-1"}},
+    "myapp-ci-repository": "acme/app"
+}
+`
+	dir := writeProject(t, `{}`, initial)
+	path := filepath.Join(dir, cdkcontext.FileName)
+
+	err := cdkcontext.Update(dir, func(values map[string]any, prefix string) error {
+		values[prefix+"dns-delegated"] = true
+		values[prefix+"deployments"] = []string{"Dev", "Prod"}
+		values[prefix+"image-tags"] = map[string]any{"Dev": map[string]any{"api": "api-2"}}
+		delete(values, prefix+"ci-repository")
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{
+    "myapp-qualifier": "myapp",
+    "myapp-deployments": [ "Dev",   "Prod" ],
+    "myapp-image-tags": {
+        "Dev": {
+            "api": "api-2"
+        }
+    },
+    "myapp-dns-delegated": true
+}
+`
+	if string(data) != want {
+		t.Errorf("unexpected file:\n%s\nwant:\n%s", data, want)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cdkcontext.Update(dir, func(map[string]any, string) error { return nil }); err != nil {
+		t.Fatal(err)
+	}
+	if after, err := os.Stat(path); err != nil || !after.ModTime().Equal(info.ModTime()) {
+		t.Errorf("expected an update without changes to leave the file alone, got %v", err)
+	}
+}
+
+func TestUpdateImages(t *testing.T) {
+	t.Parallel()
+
+	dir := writeProject(t, `{}`,
+		`{"myapp-qualifier": "myapp", "myapp-image-tags": {"dev": {"coreapi": "coreapi-dev-old"}}}`)
+
+	if err := cdkcontext.UpdateImages(dir, "dev", map[string]string{"worker": "worker-dev-new"},
+		map[string]string{"worker": "sha256:abc"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := cdkcontext.UpdateImages(dir, "prod", map[string]string{"coreapi": "coreapi-prod-abc"}, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := cdkcontext.Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]map[string]string{
+		"dev":  {"coreapi": "coreapi-dev-old", "worker": "worker-dev-new"},
+		"prod": {"coreapi": "coreapi-prod-abc"},
+	}
+	for deployment, tags := range want {
+		if !maps.Equal(c.ImageTags[deployment], tags) {
+			t.Errorf("%s: expected tags %v, got %v", deployment, tags, c.ImageTags[deployment])
+		}
+	}
+	if got := c.ImageDigests["dev"]["worker"]; got != "sha256:abc" {
+		t.Errorf("dev/worker: expected digest %q, got %q", "sha256:abc", got)
+	}
+	if _, ok := c.ImageDigests["prod"]; ok {
+		t.Errorf("expected no digests for prod, got %v", c.ImageDigests["prod"])
+	}
+}
+
+func TestFileSetAndDelete(t *testing.T) {
+	t.Parallel()
+
+	dir := writeProject(t, `{}`, `{}`)
+	f, err := cdkcontext.ReadFile(filepath.Join(dir, cdkcontext.FileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Set("url", "https://example.com/?a=1&b=2"); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Set("gone", 1); err != nil {
+		t.Fatal(err)
+	}
+	f.Delete("gone")
+
+	if got, want := string(f.Bytes()), "{\n  \"url\": \"https://example.com/?a=1&b=2\"\n}"; got != want {
+		t.Errorf("Bytes() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,204 @@
+package cdkcontext
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+
+	"github.com/advdv/ago/cmd/ago/internal/cmdexec"
+	"github.com/cockroachdb/errors"
+)
+
+// defaultIndent indents the keys of files that do not show an indentation of their own.
+const defaultIndent = "  "
+
+// File is a JSON object file, such as cdk.context.json, that is edited in place. Writing it keeps
+// the order of the keys, their indentation and the text of the values that did not change, so an
+// edit only shows up in a diff where a value actually changed.
+type File struct {
+	path     string
+	keys     []string
+	raw      map[string]json.RawMessage
+	indent   string
+	trailing bool
+}
+
+// ReadFile reads the JSON object at path.
+func ReadFile(path string) (*File, error) {
+	name := filepath.Base(path)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read %s", name)
+	}
+
+	f, err := parseFile(path, data)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to parse %s", name)
+	}
+	return f, nil
+}
+
+func parseFile(path string, data []byte) (*File, error) {
+	f := &File{
+		path:     path,
+		raw:      map[string]json.RawMessage{},
+		indent:   detectIndent(data),
+		trailing: bytes.HasSuffix(data, []byte("\n")),
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if tok, err := dec.Token(); err != nil {
+		return nil, err
+	} else if tok != json.Delim('{') {
+		return nil, errors.New("expected a JSON object")
+	}
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		key, _ := tok.(string)
+
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err != nil {
+			return nil, errors.Wrapf(err, "invalid value of %q", key)
+		}
+		if _, ok := f.raw[key]; !ok {
+			f.keys = append(f.keys, key)
+		}
+		f.raw[key] = raw
+	}
+	if _, err := dec.Token(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// detectIndent returns the whitespace in front of the first key of a JSON object.
+func detectIndent(data []byte) string {
+	start := bytes.IndexByte(data, '{')
+	if start < 0 {
+		return defaultIndent
+	}
+	rest := data[start+1:]
+	newline := bytes.IndexByte(rest, '\n')
+	if newline < 0 {
+		return defaultIndent
+	}
+	line := rest[newline+1:]
+	indent := line[:len(line)-len(bytes.TrimLeft(line, " \t"))]
+	if len(indent) == 0 {
+		return defaultIndent
+	}
+	return string(indent)
+}
+
+// Path returns the path of the file.
+func (f *File) Path() string {
+	return f.path
+}
+
+// Keys returns the keys of the file in the order in which they are written.
+func (f *File) Keys() []string {
+	return append([]string(nil), f.keys...)
+}
+
+// Values decodes every value of the file.
+func (f *File) Values() (map[string]any, error) {
+	values := make(map[string]any, len(f.keys))
+	for _, key := range f.keys {
+		var v any
+		if err := json.Unmarshal(f.raw[key], &v); err != nil {
+			return nil, errors.Wrapf(err, "failed to decode %q", key)
+		}
+		values[key] = v
+	}
+	return values, nil
+}
+
+// Set changes the value of a key. New keys are added at the end of the file, and a value that
+// encodes the same as the current one leaves the key as it is written.
+func (f *File) Set(key string, value any) error {
+	data, err := f.marshal(value)
+	if err != nil {
+		return errors.Wrapf(err, "failed to encode %q", key)
+	}
+
+	raw, ok := f.raw[key]
+	if !ok {
+		f.keys = append(f.keys, key)
+	} else if current, err := f.normalize(raw); err == nil && bytes.Equal(current, data) {
+		return nil
+	}
+	f.raw[key] = data
+	return nil
+}
+
+// Delete removes a key from the file.
+func (f *File) Delete(key string) {
+	if _, ok := f.raw[key]; !ok {
+		return
+	}
+	delete(f.raw, key)
+	for i, k := range f.keys {
+		if k == key {
+			f.keys = append(f.keys[:i], f.keys[i+1:]...)
+			break
+		}
+	}
+}
+
+// Bytes returns the content of the file.
+func (f *File) Bytes() []byte {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, key := range f.keys {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		name, _ := f.marshal(key)
+		buf.WriteString("\n" + f.indent)
+		buf.Write(name)
+		buf.WriteString(": ")
+		buf.Write(f.raw[key])
+	}
+	if len(f.keys) > 0 {
+		buf.WriteByte('\n')
+	}
+	buf.WriteByte('}')
+	if f.trailing {
+		buf.WriteByte('\n')
+	}
+	return buf.Bytes()
+}
+
+// Write writes the file back to its path, or reports the write when dry-run is enabled.
+func (f *File) Write() error {
+	if err := cmdexec.WriteFile(f.path, f.Bytes(), 0o644); err != nil {
+		return errors.Wrapf(err, "failed to write %s", filepath.Base(f.path))
+	}
+	return nil
+}
+
+// marshal encodes a value as it is written at the first level of the file. HTML characters are
+// not escaped, so values such as URLs stay readable.
+func (f *File) marshal(value any) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent(f.indent, f.indent)
+	if err := enc.Encode(value); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
+
+// normalize re-encodes a written value, so it compares with a value encoded by marshal.
+func (f *File) normalize(raw json.RawMessage) ([]byte, error) {
+	var v any
+	if err := json.Unmarshal(raw, &v); err != nil {
+		return nil, err
+	}
+	return f.marshal(v)
+}
@@ -0,0 +1,197 @@
+package cdkcontext
+
+import (
+	"regexp"
+
+	"github.com/advdv/ago/agcdkutil"
+	"github.com/advdv/ago/cmd/ago/internal/schema"
+)
+
+// Deployer authentication modes, the values of the deployer-auth key. With IAM users the
+// pre-bootstrap stack creates a user and access key per deployer; with SSO deployers sign in
+// through IAM Identity Center and no long-lived keys exist.
+const (
+	DeployerAuthIAMUsers = "iam-users"
+	DeployerAuthSSO      = "sso"
+)
+
+// Key is a context key that ago owns. Prefixed keys are stored as {prefix}{name}.
+type Key struct {
+	Name        string
+	Prefixed    bool
+	File        string
+	Required    bool
+	Description string
+	Schema      *schema.Schema
+}
+
+// Keys is the context contract between the CLI, agcdkutil and the agcdk constructs: every key
+// that ago reads or writes, with the schema that Validate checks its value against.
+var Keys = []Key{
+	{
+		Name: "qualifier", Prefixed: true, File: FileName, Required: true,
+		Description: "CDK bootstrap qualifier, also the first part of every stack name",
+		Schema:      qualifierSchema(),
+	},
+	{
+		Name: "primary-region", Prefixed: true, File: FileName, Required: true,
+		Description: "Region of the primary shared and deployment stacks",
+		Schema:      regionSchema(),
+	},
+	{
+		Name: "secondary-regions", Prefixed: true, File: FileName,
+		Description: "Additional regions that every deployment is replicated to",
+		Schema:      schema.ArrayOf(regionSchema()),
+	},
+	{
+		Name: "deployments", Prefixed: true, File: FileName, Required: true,
+		Description: "Deployment identifiers, such as Dev, Stag and Prod",
+		Schema:      schema.ArrayOf(schema.String()),
+	},
+	{
+		Name: agcdkutil.TenantsContextKey, Prefixed: true, File: FileName,
+		Description: "Tenants of a multi-tenant app, each deployed as Tenant{Ident}",
+		Schema:      schema.ArrayOf(schema.String()),
+	},
+	{
+		Name: "base-domain-name", Prefixed: true, File: FileName, Required: true,
+		Description: "Domain of the project's hosted zone",
+		Schema:      schema.String(),
+	},
+	{
+		Name: "dns-delegated", Prefixed: true, File: FileName,
+		Description: "Whether the hosted zone is delegated from its parent zone",
+		Schema:      schema.Boolean(),
+	},
+	{
+		Name: "services", Prefixed: true, File: FileName,
+		Description: "AWS services that deployers may use, as allowed by the pre-bootstrap policies",
+		Schema:      schema.ArrayOf(schema.String()),
+	},
+	{
+		Name: "deployers", Prefixed: true, File: FileName,
+		Description: "IAM users with full deployer permissions",
+		Schema:      schema.ArrayOf(schema.String()),
+	},
+	{
+		Name: "dev-deployers", Prefixed: true, File: FileName,
+		Description: "IAM users that may only deploy their own development deployment",
+		Schema:      schema.ArrayOf(schema.String()),
+	},
+	{
+		Name: "ci-repository", Prefixed: true, File: FileName,
+		Description: "GitHub repository, as owner/name, whose workflows may assume the CI deployer role",
+		Schema:      schema.String(),
+	},
+	{
+		Name: "deployer-auth", Prefixed: true, File: FileName,
+		Description: "How deployers authenticate: iam-users (default) or sso through IAM Identity Center",
+		Schema:      schema.Enum(DeployerAuthIAMUsers, DeployerAuthSSO),
+	},
+	{
+		Name: "sso-start-url", Prefixed: true, File: FileName,
+		Description: "IAM Identity Center start URL that deployers sign in at, when deployer-auth is sso",
+		Schema:      schema.String(),
+	},
+	{
+		Name: "sso-region", Prefixed: true, File: FileName,
+		Description: "Region of the IAM Identity Center instance, when deployer-auth is sso",
+		Schema:      regionSchema(),
+	},
+	{
+		Name: "management-profile", Prefixed: true, File: FileName,
+		Description: "AWS profile of the organization's management account",
+		Schema:      schema.String(),
+	},
+	{
+		Name: "parent-zone-profile", Prefixed: true, File: FileName,
+		Description: "AWS profile of the account that holds the parent hosted zone",
+		Schema:      schema.String(),
+	},
+	{
+		Name: "parent-zone-account", Prefixed: true, File: FileName,
+		Description: "Account that holds the parent hosted zone, assumed from the management profile",
+		Schema:      schema.String(),
+	},
+	{
+		Name: "parent-zone-role", Prefixed: true, File: FileName,
+		Description: "Role assumed in the parent zone account",
+		Schema:      schema.String(),
+	},
+	{
+		Name: "image-tags", Prefixed: true, File: FileName,
+		Description: "Backend image tags per deployment and image name",
+		Schema:      schema.MapOf(schema.MapOf(schema.String())),
+	},
+	{
+		Name: "image-digests", Prefixed: true, File: FileName,
+		Description: "Backend image digests per deployment and image name",
+		Schema:      schema.MapOf(schema.MapOf(schema.String())),
+	},
+	{
+		Name: "base-image", Prefixed: true, File: FileName,
+		Description: "Digest of the pinned base image of the backend images",
+		Schema:      schema.String(),
+	},
+	{
+		Name: agcdkutil.DisabledJobsContextKey, Prefixed: true, File: FileName,
+		Description: "Jobs whose schedule is switched off, with the reason, per deployment and job name",
+		Schema:      schema.MapOf(schema.MapOf(&schema.Schema{Type: schema.TypeString})),
+	},
+	{
+		Name: "deployer-groups", Prefixed: true, File: FileName,
+		Description: "IAM groups of the caller; passed with -c by the CLI rather than stored",
+		Schema:      schema.String(),
+	},
+	{
+		Name: "sandbox", Prefixed: true, File: FileName,
+		Description: "Set to \"true\" with -c by a sandbox synth; missing image tags and digests become placeholders",
+		Schema:      schema.String(),
+	},
+	{
+		Name: "profile", File: JSONFileName,
+		Description: "AWS profile that the cdk CLI and read-only ago commands use by default",
+		Schema:      schema.String(),
+	},
+	{
+		Name: "admin-profile", File: JSONFileName,
+		Description: "AWS profile with administrator access to the project account",
+		Schema:      schema.String(),
+	},
+}
+
+// Schema returns the schema of the ago-owned keys of a context with the given prefix. Keys that
+// ago does not own are allowed. Without a prefix the prefixed keys are matched by their suffix, so
+// the schema works for any project.
+func Schema(prefix string) *schema.Schema {
+	s := &schema.Schema{Type: schema.TypeObject, Properties: map[string]*schema.Schema{}}
+	for _, key := range Keys {
+		prop := *key.Schema
+		prop.Description = key.Description
+		switch {
+		case !key.Prefixed:
+			s.Properties[key.Name] = &prop
+		case prefix != "":
+			s.Properties[prefix+key.Name] = &prop
+		default:
+			if s.PatternProperties == nil {
+				s.PatternProperties = map[string]*schema.Schema{}
+			}
+			s.PatternProperties["^(.+-)?"+regexp.QuoteMeta(key.Name)+"$"] = &prop
+		}
+	}
+	return s
+}
+
+// qualifierSchema is the schema of the bootstrap qualifier, which CDK limits to 10 characters.
+func qualifierSchema() *schema.Schema {
+	maxLength := 10
+	s := schema.String()
+	s.MaxLength = &maxLength
+	return s
+}
+
+// regionSchema is the schema of a region that agcdkutil knows the identifier of.
+func regionSchema() *schema.Schema {
+	return schema.Enum(agcdkutil.AllKnownRegions()...)
+}
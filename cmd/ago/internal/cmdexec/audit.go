@@ -0,0 +1,180 @@
+package cmdexec
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cockroachdb/errors"
+)
+
+// AuditLogDir is where the audit log is written, relative to the project directory.
+const AuditLogDir = ".ago/logs"
+
+// auditLogFile is the name of the audit log in AuditLogDir. Records are appended as JSON lines.
+const auditLogFile = "commands.jsonl"
+
+// defaultAuditLog and defaultVerbose configure the auditors of executors created with New and
+// NewWithDir. Both are off until EnableAuditLog and EnableVerbose are called.
+var (
+	defaultAuditLog bool
+	defaultVerbose  io.Writer
+)
+
+// EnableAuditLog makes executors append a record of every command they run to
+// {project}/.ago/logs/commands.jsonl. Only executors created after the call are affected.
+func EnableAuditLog() {
+	defaultAuditLog = true
+}
+
+// EnableVerbose makes executors echo every command they run to w, with its exit code and
+// duration once it finishes. Only executors created after the call are affected.
+func EnableVerbose(w io.Writer) {
+	defaultVerbose = w
+}
+
+// CommandRecord is an entry of the audit log.
+type CommandRecord struct {
+	Time       time.Time `json:"time"`
+	Argv       []string  `json:"argv"`
+	Dir        string    `json:"dir"`
+	Env        []string  `json:"env,omitempty"`
+	DurationMS int64     `json:"duration_ms"`
+	ExitCode   int       `json:"exit_code"`
+	Error      string    `json:"error,omitempty"`
+}
+
+// auditor records the commands of executors. The log path is empty when only verbose output is
+// enabled.
+type auditor struct {
+	mu      sync.Mutex
+	path    string
+	verbose io.Writer
+}
+
+// newAuditor returns the auditor of executors in projectDir, or nil if neither the audit log nor
+// verbose output is enabled.
+func newAuditor(projectDir string) *auditor {
+	if !defaultAuditLog && defaultVerbose == nil {
+		return nil
+	}
+	a := &auditor{verbose: defaultVerbose}
+	if defaultAuditLog {
+		a.path = filepath.Join(projectDir, AuditLogDir, auditLogFile)
+	}
+	return a
+}
+
+// start echoes a command that is about to run and returns the function that records its result.
+// It is safe to call on a nil auditor.
+func (a *auditor) start(dir string, env []string, name string, args []string) func(err error) {
+	if a == nil {
+		return func(error) {}
+	}
+
+	if a.verbose != nil {
+		_, _ = fmt.Fprintf(a.verbose, "[exec] %s (in %s)\n", formatCommand(name, args), dir)
+	}
+
+	started := time.Now()
+	return func(err error) {
+		argv := make([]string, 0, 1+len(args))
+		for _, arg := range append([]string{name}, args...) {
+			argv = append(argv, Redact(arg))
+		}
+		record := CommandRecord{
+			Time:       started.UTC(),
+			Argv:       argv,
+			Dir:        dir,
+			Env:        redactEnv(env),
+			DurationMS: time.Since(started).Milliseconds(),
+			ExitCode:   exitCode(err),
+		}
+		if err != nil {
+			record.Error = Redact(err.Error())
+		}
+		a.record(record)
+	}
+}
+
+func (a *auditor) record(record CommandRecord) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.verbose != nil {
+		_, _ = fmt.Fprintf(a.verbose, "[exec] %s exited %d after %s\n", record.Argv[0], record.ExitCode,
+			time.Duration(record.DurationMS)*time.Millisecond)
+	}
+	if a.path != "" {
+		// The audit log must not fail the command it records.
+		_ = appendRecord(a.path, record)
+	}
+}
+
+func appendRecord(path string, record CommandRecord) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return errors.Wrap(err, "failed to encode command record")
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return errors.Wrap(err, "failed to create audit log directory")
+	}
+	// Logs hold project paths and arguments, so keep them out of the repository.
+	gitignore := filepath.Join(filepath.Dir(path), ".gitignore")
+	if _, err := os.Stat(gitignore); os.IsNotExist(err) {
+		_ = os.WriteFile(gitignore, []byte("*\n"), 0o644)
+	}
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return errors.Wrap(err, "failed to open audit log")
+	}
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		f.Close()
+		return errors.Wrap(err, "failed to write audit log")
+	}
+	return f.Close()
+}
+
+// exitCode returns the exit code of a command from the error of running it: 0 on success, the
+// code of the process if it exited, and -1 if it did not start or was killed.
+func exitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode()
+	}
+	return -1
+}
+
+// sensitiveEnvWords mark environment variables whose values the audit log does not record.
+var sensitiveEnvWords = []string{"SECRET", "TOKEN", "PASSWORD", "CREDENTIAL", "KEY", "AUTH"}
+
+// redactEnv returns the environment variables that an executor adds, with the values of the
+// sensitive ones masked.
+func redactEnv(env []string) []string {
+	if len(env) == 0 {
+		return nil
+	}
+	redacted := make([]string, 0, len(env))
+	for _, kv := range env {
+		key, _, _ := strings.Cut(kv, "=")
+		upper := strings.ToUpper(key)
+		for _, word := range sensitiveEnvWords {
+			if strings.Contains(upper, word) {
+				kv = key + "=***"
+				break
+			}
+		}
+		redacted = append(redacted, kv)
+	}
+	return redacted
+}
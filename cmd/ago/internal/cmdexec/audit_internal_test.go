@@ -0,0 +1,70 @@
+package cmdexec
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestAuditLog(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	var verbose bytes.Buffer
+	e := (&executor{
+		dir:   dir,
+		audit: &auditor{path: filepath.Join(dir, AuditLogDir, auditLogFile), verbose: &verbose},
+	}).WithEnv("AWS_PROFILE", "dev").WithEnv("GITHUB_TOKEN", "ghp_secret")
+
+	ctx := context.Background()
+	if _, err := e.Output(ctx, "echo", "hello world"); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.Run(ctx, "sh", "-c", "exit 3"); err == nil {
+		t.Fatal("expected error, got nil")
+	}
+
+	f, err := os.Open(filepath.Join(dir, ".ago", "logs", "commands.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	var records []CommandRecord
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var record CommandRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			t.Fatal(err)
+		}
+		records = append(records, record)
+	}
+	if len(records) != 2 {
+		t.Fatalf("expected 2 records, got %d", len(records))
+	}
+
+	if got := records[0]; !slices.Equal(got.Argv, []string{"echo", "hello world"}) || got.ExitCode != 0 ||
+		got.Dir != dir || got.Error != "" {
+		t.Errorf("unexpected record: %+v", got)
+	}
+	if got := records[1]; got.ExitCode != 3 || got.Error == "" {
+		t.Errorf("unexpected record: %+v", got)
+	}
+	if want := []string{"AWS_PROFILE=dev", "GITHUB_TOKEN=***"}; !slices.Equal(records[0].Env, want) {
+		t.Errorf("env = %v, want %v", records[0].Env, want)
+	}
+
+	for _, want := range []string{
+		`[exec] echo "hello world" (in ` + dir + ")", "[exec] echo exited 0 after", "[exec] sh exited 3 after",
+	} {
+		if !strings.Contains(verbose.String(), want) {
+			t.Errorf("expected %q in verbose output:\n%s", want, verbose.String())
+		}
+	}
+}
@@ -7,12 +7,14 @@ import (
 	"os/exec"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/advdv/ago/cmd/ago/internal/config"
 	"github.com/cockroachdb/errors"
 )
 
-// Executor provides a common interface for executing external commands.
+// Executor provides a common interface for executing external commands. Executors created
+// while dry-run is enabled skip commands that mutate state, see EnableDryRun.
 type Executor interface {
 	// WithOutput returns a new Executor that writes to the given stdout/stderr.
 	WithOutput(stdout, stderr io.Writer) Executor
@@ -23,6 +25,10 @@ type Executor interface {
 	// WithEnv returns a new Executor with an additional environment variable.
 	WithEnv(key, value string) Executor
 
+	// WithTimeout returns a new Executor that limits each command to d, overriding the default
+	// of its command class. Zero means no limit.
+	WithTimeout(d time.Duration) Executor
+
 	// Dir returns the working directory for this executor.
 	Dir() string
 
@@ -48,12 +54,22 @@ type executor struct {
 	stdout io.Writer
 	stderr io.Writer
 	env    []string
+	tools  *toolCache
+	dryRun io.Writer
+	audit  *auditor
+
+	// timeout limits each command when set, see WithTimeout. Nil applies the default of the
+	// command's class.
+	timeout *time.Duration
 }
 
 // New creates an Executor from config.Config.
 func New(cfg config.Config) Executor {
 	return &executor{
-		dir: cfg.ProjectDir,
+		dir:    cfg.ProjectDir,
+		tools:  defaultToolCache,
+		dryRun: defaultDryRun,
+		audit:  newAuditor(cfg.ProjectDir),
 	}
 }
 
@@ -61,25 +77,36 @@ func New(cfg config.Config) Executor {
 // Use this for commands like init where no config exists yet.
 func NewWithDir(dir string) Executor {
 	return &executor{
-		dir: dir,
+		dir:    dir,
+		tools:  defaultToolCache,
+		dryRun: defaultDryRun,
+		audit:  newAuditor(dir),
 	}
 }
 
 func (e *executor) WithOutput(stdout, stderr io.Writer) Executor {
 	return &executor{
-		dir:    e.dir,
-		stdout: stdout,
-		stderr: stderr,
-		env:    e.env,
+		dir:     e.dir,
+		stdout:  stdout,
+		stderr:  stderr,
+		env:     e.env,
+		tools:   e.tools,
+		dryRun:  e.dryRun,
+		audit:   e.audit,
+		timeout: e.timeout,
 	}
 }
 
 func (e *executor) InSubdir(subdir string) Executor {
 	return &executor{
-		dir:    filepath.Join(e.dir, subdir),
-		stdout: e.stdout,
-		stderr: e.stderr,
-		env:    e.env,
+		dir:     filepath.Join(e.dir, subdir),
+		stdout:  e.stdout,
+		stderr:  e.stderr,
+		env:     e.env,
+		tools:   e.tools,
+		dryRun:  e.dryRun,
+		audit:   e.audit,
+		timeout: e.timeout,
 	}
 }
 
@@ -89,10 +116,14 @@ func (e *executor) WithEnv(key, value string) Executor {
 	newEnv = append(newEnv, key+"="+value)
 
 	return &executor{
-		dir:    e.dir,
-		stdout: e.stdout,
-		stderr: e.stderr,
-		env:    newEnv,
+		dir:     e.dir,
+		stdout:  e.stdout,
+		stderr:  e.stderr,
+		env:     newEnv,
+		tools:   e.tools,
+		dryRun:  e.dryRun,
+		audit:   e.audit,
+		timeout: e.timeout,
 	}
 }
 
@@ -101,13 +132,34 @@ func (e *executor) Dir() string {
 }
 
 func (e *executor) Run(ctx context.Context, name string, args ...string) error {
-	cmd := exec.CommandContext(ctx, name, args...)
+	if e.skip(name, args) {
+		return nil
+	}
+
+	runCtx, cancel, timeout := e.withTimeout(ctx, name, args)
+	defer cancel()
+
+	cmd := exec.CommandContext(runCtx, name, args...)
 	cmd.Dir = e.dir
-	cmd.Stdout = e.stdout
-	cmd.Stderr = e.stderr
+	cmd.WaitDelay = waitDelay
+	stdout, flushStdout := redactOutput(e.stdout)
+	stderr, flushStderr := redactOutput(e.stderr)
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
 	e.applyEnv(cmd)
 
-	if err := cmd.Run(); err != nil {
+	done := e.audit.start(e.dir, e.env, name, args)
+	err := cmd.Run()
+	flushStdout()
+	flushStderr()
+	if err != nil {
+		if interruptErr := interrupted(ctx, runCtx, timeout, name, args); interruptErr != nil {
+			done(interruptErr)
+			return interruptErr
+		}
+	}
+	done(err)
+	if err != nil {
 		return errors.Wrapf(err, "%s failed", name)
 	}
 
@@ -115,14 +167,35 @@ func (e *executor) Run(ctx context.Context, name string, args ...string) error {
 }
 
 func (e *executor) RunWithStdin(ctx context.Context, stdin io.Reader, name string, args ...string) error {
-	cmd := exec.CommandContext(ctx, name, args...)
+	if e.skip(name, args) {
+		return nil
+	}
+
+	runCtx, cancel, timeout := e.withTimeout(ctx, name, args)
+	defer cancel()
+
+	cmd := exec.CommandContext(runCtx, name, args...)
 	cmd.Dir = e.dir
+	cmd.WaitDelay = waitDelay
 	cmd.Stdin = stdin
-	cmd.Stdout = e.stdout
-	cmd.Stderr = e.stderr
+	stdout, flushStdout := redactOutput(e.stdout)
+	stderr, flushStderr := redactOutput(e.stderr)
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
 	e.applyEnv(cmd)
 
-	if err := cmd.Run(); err != nil {
+	done := e.audit.start(e.dir, e.env, name, args)
+	err := cmd.Run()
+	flushStdout()
+	flushStderr()
+	if err != nil {
+		if interruptErr := interrupted(ctx, runCtx, timeout, name, args); interruptErr != nil {
+			done(interruptErr)
+			return interruptErr
+		}
+	}
+	done(err)
+	if err != nil {
 		return errors.Wrapf(err, "%s failed", name)
 	}
 
@@ -130,12 +203,33 @@ func (e *executor) RunWithStdin(ctx context.Context, stdin io.Reader, name strin
 }
 
 func (e *executor) Output(ctx context.Context, name string, args ...string) (string, error) {
-	cmd := exec.CommandContext(ctx, name, args...)
+	if e.skip(name, args) {
+		return "", nil
+	}
+
+	runCtx, cancel, timeout := e.withTimeout(ctx, name, args)
+	defer cancel()
+
+	cmd := exec.CommandContext(runCtx, name, args...)
 	cmd.Dir = e.dir
+	cmd.WaitDelay = waitDelay
 	e.applyEnv(cmd)
 
+	done := e.audit.start(e.dir, e.env, name, args)
 	output, err := cmd.Output()
 	if err != nil {
+		if interruptErr := interrupted(ctx, runCtx, timeout, name, args); interruptErr != nil {
+			done(interruptErr)
+			return "", interruptErr
+		}
+	}
+	done(err)
+	if err != nil {
+		// Include stderr so callers can recognize specific failures (e.g. AWS error codes).
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && len(exitErr.Stderr) > 0 {
+			return "", errors.Wrapf(err, "%s failed: %s", name, Redact(strings.TrimSpace(string(exitErr.Stderr))))
+		}
 		return "", errors.Wrapf(err, "%s failed", name)
 	}
 
@@ -143,6 +237,14 @@ func (e *executor) Output(ctx context.Context, name string, args ...string) (str
 }
 
 func (e *executor) Mise(ctx context.Context, name string, args ...string) error {
+	if e.skip(name, args) {
+		return nil
+	}
+
+	if tool, path := e.resolveTool(ctx, name); path != "" {
+		return tool.Run(ctx, path, args...)
+	}
+
 	miseArgs := make([]string, 0, 2+len(args))
 	miseArgs = append(miseArgs, "exec", "--", name)
 	miseArgs = append(miseArgs, args...)
@@ -151,6 +253,14 @@ func (e *executor) Mise(ctx context.Context, name string, args ...string) error
 }
 
 func (e *executor) MiseOutput(ctx context.Context, name string, args ...string) (string, error) {
+	if e.skip(name, args) {
+		return "", nil
+	}
+
+	if tool, path := e.resolveTool(ctx, name); path != "" {
+		return tool.Output(ctx, path, args...)
+	}
+
 	miseArgs := make([]string, 0, 2+len(args))
 	miseArgs = append(miseArgs, "exec", "--", name)
 	miseArgs = append(miseArgs, args...)
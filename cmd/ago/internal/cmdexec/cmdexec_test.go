@@ -5,6 +5,7 @@ import (
 	"context"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/advdv/ago/cmd/ago/internal/cmdexec"
@@ -127,6 +128,21 @@ func TestOutputError(t *testing.T) {
 	}
 }
 
+func TestOutputErrorIncludesStderr(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	exec := cmdexec.NewWithDir(dir)
+
+	_, err := exec.Output(context.Background(), "sh", "-c", "echo 'SomeException: boom' >&2; exit 1")
+	if err == nil {
+		t.Fatal("expected error, got nil")
+	}
+	if !strings.Contains(err.Error(), "SomeException: boom") {
+		t.Errorf("expected error to contain stderr, got %q", err.Error())
+	}
+}
+
 func TestWithOutputImmutability(t *testing.T) {
 	t.Parallel()
 
@@ -174,3 +190,31 @@ func TestMiseOutput(t *testing.T) {
 		t.Errorf("expected 'from mise', got %q", output)
 	}
 }
+
+func TestDryRun(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	var report bytes.Buffer
+	exec := cmdexec.NewDryRun(dir, &report)
+
+	if err := exec.Run(context.Background(), "touch", "created file"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "created file")); !os.IsNotExist(err) {
+		t.Errorf("expected the command not to run, stat returned: %v", err)
+	}
+
+	output, err := exec.Output(context.Background(), "git", "--version")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if output != "" {
+		t.Errorf("expected git --version to be skipped, got %q", output)
+	}
+
+	want := "[dry-run] would run: touch \"created file\"\n[dry-run] would run: git --version\n"
+	if report.String() != want {
+		t.Errorf("expected report %q, got %q", want, report.String())
+	}
+}
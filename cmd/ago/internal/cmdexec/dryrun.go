@@ -0,0 +1,178 @@
+package cmdexec
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// defaultDryRun is where executors created with New and NewWithDir report the commands they skip.
+// It is nil, and dry-run disabled, until EnableDryRun is called.
+var defaultDryRun io.Writer
+
+// EnableDryRun makes executors report mutating commands to w instead of running them, and makes
+// WriteFile report writes instead of performing them. Commands that only read state still run,
+// since the rest of a command usually depends on what they return. Only executors created after
+// the call are affected.
+func EnableDryRun(w io.Writer) {
+	defaultDryRun = w
+}
+
+// DryRun reports whether EnableDryRun was called.
+func DryRun() bool {
+	return defaultDryRun != nil
+}
+
+// NewDryRun creates an Executor in dir that reports mutating commands to w instead of running
+// them, regardless of EnableDryRun.
+func NewDryRun(dir string, w io.Writer) Executor {
+	return &executor{
+		dir:    dir,
+		tools:  defaultToolCache,
+		dryRun: w,
+		audit:  newAuditor(dir),
+	}
+}
+
+// WriteFile writes a file like os.WriteFile, or reports the path and the content that would be
+// written when dry-run is enabled.
+func WriteFile(name string, data []byte, perm os.FileMode) error {
+	if defaultDryRun != nil {
+		reportDryRun(defaultDryRun, "would write %s:\n%s", name, indent(string(data)))
+		return nil
+	}
+	return os.WriteFile(name, data, perm)
+}
+
+// AppendFile appends data to an existing file, or reports the path and the data that would be
+// appended when dry-run is enabled.
+func AppendFile(name string, data []byte) error {
+	if defaultDryRun != nil {
+		reportDryRun(defaultDryRun, "would append to %s:\n%s", name, indent(string(data)))
+		return nil
+	}
+
+	f, err := os.OpenFile(name, os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// MkdirAll creates a directory like os.MkdirAll, or reports it when dry-run is enabled.
+func MkdirAll(path string, perm os.FileMode) error {
+	if defaultDryRun != nil {
+		reportDryRun(defaultDryRun, "would create directory %s", path)
+		return nil
+	}
+	return os.MkdirAll(path, perm)
+}
+
+// Remove removes a file like os.Remove, or reports it when dry-run is enabled.
+func Remove(name string) error {
+	if defaultDryRun != nil {
+		reportDryRun(defaultDryRun, "would remove %s", name)
+		return nil
+	}
+	return os.Remove(name)
+}
+
+// Rename moves a file like os.Rename, or reports it when dry-run is enabled.
+func Rename(oldpath, newpath string) error {
+	if defaultDryRun != nil {
+		reportDryRun(defaultDryRun, "would move %s to %s", oldpath, newpath)
+		return nil
+	}
+	return os.Rename(oldpath, newpath)
+}
+
+// ReportDryRun reports an action that a dry run skips. It does nothing unless dry-run is enabled.
+func ReportDryRun(format string, args ...any) {
+	if defaultDryRun != nil {
+		reportDryRun(defaultDryRun, format, args...)
+	}
+}
+
+// skip reports the command and returns true if e is a dry-run executor and the command mutates.
+func (e *executor) skip(name string, args []string) bool {
+	if e.dryRun == nil || readOnly(name, args) {
+		return false
+	}
+	reportDryRun(e.dryRun, "would run: %s", formatCommand(name, args))
+	return true
+}
+
+// readOnly reports whether a command only reads state. AWS CLI calls are read-only when their
+// operation describes, gets or lists; cdk, git and mise are read-only for the subcommands that
+// the CLI uses to inspect, including printing the bootstrap template, and "mise exec" is as
+// read-only as the command it runs. Any other command is assumed to mutate.
+func readOnly(name string, args []string) bool {
+	if len(args) == 0 {
+		return false
+	}
+
+	switch name {
+	case "aws":
+		if len(args) < 2 {
+			return false
+		}
+		service, op := args[0], args[1]
+		switch service {
+		case "configure":
+			return op != "set" && op != "import"
+		case "s3":
+			return op == "ls" ||
+				(op == "cp" && len(args) > 2 && strings.HasPrefix(args[2], "s3://") && slices.Contains(args, "-"))
+		}
+		for _, prefix := range []string{"describe-", "get-", "list-", "head-"} {
+			if strings.HasPrefix(op, prefix) {
+				return true
+			}
+		}
+		return false
+	case "cdk":
+		return slices.Contains([]string{"synth", "ls", "list", "diff", "doctor"}, args[0]) ||
+			(args[0] == "bootstrap" && slices.Contains(args, "--show-template"))
+	case "git":
+		return slices.Contains([]string{"rev-parse", "status", "diff", "log", "ls-files", "show"}, args[0])
+	case "mise":
+		if i := slices.Index(args, "--"); args[0] == "exec" && i >= 0 && i+1 < len(args) {
+			return readOnly(args[i+1], args[i+2:])
+		}
+		return slices.Contains([]string{"which", "env", "--version", "ls", "latest"}, args[0])
+	default:
+		return false
+	}
+}
+
+// reportDryRun reports a skipped action, with registered secrets masked, as writes of credentials
+// would otherwise print them.
+func reportDryRun(w io.Writer, format string, args ...any) {
+	_, _ = io.WriteString(w, Redact(fmt.Sprintf("[dry-run] "+format+"\n", args...)))
+}
+
+// formatCommand renders a command line, quoting arguments that the shell would split and
+// masking registered secrets.
+func formatCommand(name string, args []string) string {
+	parts := make([]string, 0, 1+len(args))
+	parts = append(parts, name)
+	for _, arg := range args {
+		arg = Redact(arg)
+		if arg == "" || strings.ContainsAny(arg, " \t\n\"'$*?;&|<>()") {
+			arg = strconv.Quote(arg)
+		}
+		parts = append(parts, arg)
+	}
+	return strings.Join(parts, " ")
+}
+
+func indent(s string) string {
+	return "  " + strings.ReplaceAll(strings.TrimRight(s, "\n"), "\n", "\n  ")
+}
@@ -0,0 +1,85 @@
+package cmdexec
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestReadOnly(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		args []string
+		want bool
+	}{
+		{"aws", []string{"cloudformation", "describe-stacks"}, true},
+		{"aws", []string{"sts", "get-caller-identity"}, true},
+		{"aws", []string{"cloudformation", "delete-stack"}, false},
+		{"aws", []string{"configure", "get", "region"}, true},
+		{"aws", []string{"configure", "set", "region", "eu-west-1"}, false},
+		{"aws", []string{"s3", "cp", "s3://bucket/key", "-"}, true},
+		{"aws", []string{"s3", "cp", "file", "s3://bucket/key"}, false},
+		{"cdk", []string{"synth", "--quiet"}, true},
+		{"cdk", []string{"deploy", "--all"}, false},
+		{"cdk", []string{"bootstrap", "--show-template"}, true},
+		{"cdk", []string{"bootstrap"}, false},
+		{"git", []string{"rev-parse", "HEAD"}, true},
+		{"git", []string{"commit", "-m", "x"}, false},
+		{"mise", []string{"latest", "node@22"}, true},
+		{"mise", []string{"which", "cdk"}, true},
+		{"mise", []string{"exec", "--", "aws", "sts", "get-caller-identity"}, true},
+		{"mise", []string{"exec", "--", "cdk", "deploy"}, false},
+		{"mise", []string{"install"}, false},
+		{"touch", []string{"file"}, false},
+		{"aws", nil, false},
+	}
+
+	for _, tt := range tests {
+		if got := readOnly(tt.name, tt.args); got != tt.want {
+			t.Errorf("readOnly(%q, %q) = %v, want %v", tt.name, tt.args, got, tt.want)
+		}
+	}
+}
+
+//nolint:paralleltest // switches the package-wide dry-run writer
+func TestFileOperationsDryRun(t *testing.T) {
+	dir := t.TempDir()
+	existing := filepath.Join(dir, "existing")
+	if err := os.WriteFile(existing, []byte("a\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	var report bytes.Buffer
+	EnableDryRun(&report)
+	t.Cleanup(func() { defaultDryRun = nil })
+
+	if err := MkdirAll(filepath.Join(dir, "sub"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := AppendFile(existing, []byte("b\n")); err != nil {
+		t.Fatal(err)
+	}
+	if err := Rename(existing, filepath.Join(dir, "moved")); err != nil {
+		t.Fatal(err)
+	}
+	if err := Remove(existing); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(existing)
+	if err != nil || string(data) != "a\n" {
+		t.Errorf("expected %s to be untouched, got %q, %v", existing, data, err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "sub")); !os.IsNotExist(err) {
+		t.Errorf("expected no directory to be created, got %v", err)
+	}
+	for _, want := range []string{"would create directory", "would append to", "would move", "would remove"} {
+		if !strings.Contains(report.String(), want) {
+			t.Errorf("expected report to contain %q, got:\n%s", want, report.String())
+		}
+	}
+}
@@ -0,0 +1,150 @@
+package cmdexec
+
+import (
+	"cmp"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// redactedMask replaces secrets in output.
+const redactedMask = "***"
+
+// minSecretLength is the shortest value that RegisterSecret accepts. Masking shorter values would
+// garble unrelated output more than it protects.
+const minSecretLength = 8
+
+// secrets holds the values that executors mask for the rest of the process.
+var secrets secretRegistry
+
+type secretRegistry struct {
+	mu       sync.RWMutex
+	values   []string
+	replacer *strings.Replacer
+}
+
+// RegisterSecret marks values, such as access keys, secret strings and registry passwords, as
+// sensitive. From then on executors mask them in the output they stream, terminals included, in
+// the stderr of failed commands, in the audit log and in verbose and dry-run reports. Values
+// shorter than 8 characters are ignored.
+func RegisterSecret(values ...string) {
+	secrets.mu.Lock()
+	defer secrets.mu.Unlock()
+
+	for _, v := range values {
+		if len(v) >= minSecretLength && !slices.Contains(secrets.values, v) {
+			secrets.values = append(secrets.values, v)
+		}
+	}
+
+	// Longer values go first, so a secret that contains another is masked as a whole.
+	slices.SortFunc(secrets.values, func(a, b string) int { return cmp.Compare(len(b), len(a)) })
+	pairs := make([]string, 0, 2*len(secrets.values))
+	for _, v := range secrets.values {
+		pairs = append(pairs, v, redactedMask)
+	}
+	secrets.replacer = strings.NewReplacer(pairs...)
+}
+
+// Redact masks the registered secrets in s.
+func Redact(s string) string {
+	secrets.mu.RLock()
+	defer secrets.mu.RUnlock()
+
+	if secrets.replacer == nil {
+		return s
+	}
+	return secrets.replacer.Replace(s)
+}
+
+// redactingWriter masks registered secrets in the output of a command. Output is written as it
+// arrives, except for a tail that could be the start of a secret, so a secret that arrives in
+// two writes is still masked while prompts and progress show without delay. Flush writes the
+// tail that is left after the command exited.
+type redactingWriter struct {
+	mu  sync.Mutex
+	w   io.Writer
+	buf []byte
+}
+
+// redactOutput wraps w in a redacting writer. Terminals are wrapped too, so commands see a pipe
+// rather than a terminal once a secret is registered; before that, terminals are passed through
+// so commands keep their interactive behavior.
+func redactOutput(w io.Writer) (io.Writer, func()) {
+	if w == nil {
+		return nil, func() {}
+	}
+	if f, ok := w.(*os.File); ok && !hasSecrets() {
+		if info, err := f.Stat(); err == nil && info.Mode()&os.ModeCharDevice != 0 {
+			return w, func() {}
+		}
+	}
+	rw := &redactingWriter{w: w}
+	return rw, rw.Flush
+}
+
+func (rw *redactingWriter) Write(p []byte) (int, error) {
+	rw.mu.Lock()
+	defer rw.mu.Unlock()
+
+	rw.buf = append(rw.buf, p...)
+	end := len(rw.buf) - heldBack(string(rw.buf))
+	if end == 0 {
+		return len(p), nil
+	}
+
+	_, err := io.WriteString(rw.w, Redact(string(rw.buf[:end])))
+	rw.buf = append(rw.buf[:0], rw.buf[end:]...)
+	return len(p), err
+}
+
+// Flush writes the output that was held back.
+func (rw *redactingWriter) Flush() {
+	rw.mu.Lock()
+	defer rw.mu.Unlock()
+
+	if len(rw.buf) > 0 {
+		_, _ = io.WriteString(rw.w, Redact(string(rw.buf)))
+		rw.buf = rw.buf[:0]
+	}
+}
+
+// hasSecrets reports whether any secret is registered.
+func hasSecrets() bool {
+	secrets.mu.RLock()
+	defer secrets.mu.RUnlock()
+
+	return len(secrets.values) > 0
+}
+
+// heldBack returns the length of the tail of s that must wait for more output: the longest
+// suffix that is the start of a registered secret. A secret that begins before that suffix and
+// ends inside it extends the tail to its start, so it is never split when the rest is written.
+func heldBack(s string) int {
+	secrets.mu.RLock()
+	defer secrets.mu.RUnlock()
+
+	start := len(s)
+	for _, v := range secrets.values {
+		for i := max(0, len(s)-len(v)+1); i < start; i++ {
+			if strings.HasPrefix(v, s[i:]) {
+				start = i
+				break
+			}
+		}
+	}
+	for moved := true; moved; {
+		moved = false
+		for _, v := range secrets.values {
+			for i := max(0, start-len(v)+1); i < start; i++ {
+				if strings.HasPrefix(s[i:], v) {
+					start, moved = i, true
+					break
+				}
+			}
+		}
+	}
+	return len(s) - start
+}
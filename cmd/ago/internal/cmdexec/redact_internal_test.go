@@ -0,0 +1,90 @@
+package cmdexec
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestRedact(t *testing.T) {
+	t.Parallel()
+
+	RegisterSecret("redact-test-secret", "redact-test-secret-longer", "short")
+	got := Redact("a redact-test-secret-longer and a redact-test-secret but not short")
+	if want := "a *** and a *** but not short"; got != want {
+		t.Errorf("Redact() = %q, want %q", got, want)
+	}
+}
+
+func TestRedactingWriter(t *testing.T) {
+	t.Parallel()
+
+	RegisterSecret("writer-test-password")
+	var out bytes.Buffer
+	w, flush := redactOutput(&out)
+	for _, chunk := range []string{"login with writer-te", "st-password\nprogress\r", "$ ", "done writer-"} {
+		if _, err := w.Write([]byte(chunk)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if want := "login with ***\nprogress\r$ done "; out.String() != want {
+		t.Errorf("before flush = %q, want %q", out.String(), want)
+	}
+
+	if _, err := w.Write([]byte("test-password")); err != nil {
+		t.Fatal(err)
+	}
+	flush()
+	if want := "login with ***\nprogress\r$ done ***"; out.String() != want {
+		t.Errorf("after flush = %q, want %q", out.String(), want)
+	}
+}
+
+func TestHeldBack(t *testing.T) {
+	t.Parallel()
+
+	RegisterSecret("held-back-secret", "back-secret-and-more")
+	tests := map[string]int{
+		"nothing to hide.":             0,
+		"output held-ba":               len("held-ba"),
+		"output held-back-secret.":     0,
+		"output held-back-secret":      len("held-back-secret"),
+		"output held-back-secret-and":  len("held-back-secret-and"),
+		"output held-back-secret-and-": len("held-back-secret-and-"),
+	}
+	for s, want := range tests {
+		if got := heldBack(s); got != want {
+			t.Errorf("heldBack(%q) = %d, want %d", s, got, want)
+		}
+	}
+}
+
+func TestRunRedactsOutput(t *testing.T) {
+	t.Parallel()
+
+	RegisterSecret("run-test-secret-value")
+	var stdout, stderr, dryRun bytes.Buffer
+	e := &executor{dir: t.TempDir(), stdout: &stdout, stderr: &stderr}
+
+	ctx := context.Background()
+	if err := e.Run(ctx, "sh", "-c", "echo key=run-test-secret-value; echo run-test-secret-value >&2"); err != nil {
+		t.Fatal(err)
+	}
+	if stdout.String() != "key=***\n" || stderr.String() != "***\n" {
+		t.Errorf("unexpected output: %q, %q", stdout.String(), stderr.String())
+	}
+
+	_, err := e.Output(ctx, "sh", "-c", "echo run-test-secret-value >&2; exit 1")
+	if err == nil || strings.Contains(err.Error(), "run-test-secret-value") {
+		t.Errorf("expected the secret to be masked in the error, got %v", err)
+	}
+
+	e.dryRun = &dryRun
+	if err := e.Run(ctx, "aws", "configure", "set", "aws_secret_access_key", "run-test-secret-value"); err != nil {
+		t.Fatal(err)
+	}
+	if want := "[dry-run] would run: aws configure set aws_secret_access_key \"***\"\n"; dryRun.String() != want {
+		t.Errorf("dry-run report = %q, want %q", dryRun.String(), want)
+	}
+}
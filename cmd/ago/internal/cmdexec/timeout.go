@@ -0,0 +1,125 @@
+package cmdexec
+
+import (
+	"context"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/cockroachdb/errors"
+)
+
+// waitDelay is how long a command may keep its output open after it was killed, which happens
+// when it started processes of its own, such as "mise exec" does.
+const waitDelay = 10 * time.Second
+
+// WithTimeout returns a new Executor that limits every command to d instead of the default of its
+// command class, see defaultTimeout. Zero removes the limit.
+func (e *executor) WithTimeout(d time.Duration) Executor {
+	return &executor{
+		dir:     e.dir,
+		stdout:  e.stdout,
+		stderr:  e.stderr,
+		env:     e.env,
+		tools:   e.tools,
+		dryRun:  e.dryRun,
+		audit:   e.audit,
+		timeout: &d,
+	}
+}
+
+// commandTimeout returns the limit of a command: the one set with WithTimeout, or the default of
+// its command class.
+func (e *executor) commandTimeout(name string, args []string) time.Duration {
+	if e.timeout != nil {
+		return *e.timeout
+	}
+	return defaultTimeout(name, args)
+}
+
+// withTimeout derives the context that a command runs with. The cancel function must be called
+// once the command finished.
+func (e *executor) withTimeout(
+	ctx context.Context, name string, args []string,
+) (context.Context, context.CancelFunc, time.Duration) {
+	d := e.commandTimeout(name, args)
+	if d <= 0 {
+		return ctx, func() {}, 0
+	}
+	runCtx, cancel := context.WithTimeout(ctx, d)
+	return runCtx, cancel, d
+}
+
+// interrupted explains why a command failed when its context ended it: its timeout expired, or
+// the caller cancelled it, for example with Ctrl-C. It returns nil for other failures.
+func interrupted(parent, runCtx context.Context, d time.Duration, name string, args []string) error {
+	switch {
+	case parent.Err() != nil:
+		return errors.Wrapf(parent.Err(), "cancelled while running %s", formatCommand(name, args))
+	case runCtx.Err() != nil:
+		return errors.Newf("timed out after %s running %s", d, formatCommand(name, args))
+	default:
+		return nil
+	}
+}
+
+// defaultTimeout returns the limit of a command by its class. Commands that take long but do
+// finish, such as CloudFormation waiters and deploys, get generous limits so a hung call does not
+// block forever; interactive and unknown commands, such as shells and dev servers, get none.
+func defaultTimeout(name string, args []string) time.Duration {
+	if len(args) == 0 {
+		return 0
+	}
+
+	// Tools that the tool cache resolved are run by their absolute path.
+	switch filepath.Base(name) {
+	case "mise":
+		if i := slices.Index(args, "--"); args[0] == "exec" && i >= 0 && i+1 < len(args) {
+			return defaultTimeout(args[i+1], args[i+2:])
+		}
+		if args[0] == "install" {
+			return 30 * time.Minute
+		}
+		if slices.Contains([]string{"which", "env", "--version", "ls", "latest"}, args[0]) {
+			return 2 * time.Minute
+		}
+		return 0
+	case "aws":
+		switch {
+		case len(args) < 2:
+			return 0
+		case args[1] == "wait":
+			return time.Hour
+		case args[0] == "s3":
+			return 30 * time.Minute
+		case readOnly("aws", args):
+			return 5 * time.Minute
+		default:
+			return 10 * time.Minute
+		}
+	case "cdk":
+		switch args[0] {
+		case "deploy", "destroy":
+			return 3 * time.Hour
+		case "bootstrap":
+			return time.Hour
+		case "synth", "ls", "list", "diff", "doctor":
+			return 30 * time.Minute
+		}
+		return 0
+	case "docker":
+		switch args[0] {
+		case "login":
+			return 2 * time.Minute
+		case "build", "buildx", "push", "pull":
+			return time.Hour
+		}
+		return 0
+	case "ko":
+		return time.Hour
+	case "git":
+		return 5 * time.Minute
+	default:
+		return 0
+	}
+}
@@ -0,0 +1,75 @@
+package cmdexec
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWithTimeout(t *testing.T) {
+	t.Parallel()
+
+	e := (&executor{dir: t.TempDir()}).WithTimeout(50 * time.Millisecond)
+	ctx := context.Background()
+
+	started := time.Now()
+	err := e.Run(ctx, "sleep", "5")
+	if err == nil {
+		t.Fatal("expected error, got nil")
+	}
+	if want := "timed out after 50ms running sleep 5"; err.Error() != want {
+		t.Errorf("Run error = %q, want %q", err, want)
+	}
+	if elapsed := time.Since(started); elapsed > 4*time.Second {
+		t.Errorf("Run took %s, want it to stop at the timeout", elapsed)
+	}
+
+	if _, err := e.Output(ctx, "sleep", "5"); err == nil || !strings.HasPrefix(err.Error(), "timed out after 50ms") {
+		t.Errorf("Output error = %v, want a timeout", err)
+	}
+
+	if out, err := e.WithTimeout(0).Output(ctx, "sh", "-c", "sleep 0.1; echo done"); err != nil || out != "done" {
+		t.Errorf("Output without timeout = %q, %v, want done", out, err)
+	}
+}
+
+func TestCancelledCommand(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	err := (&executor{dir: t.TempDir()}).Run(ctx, "sleep", "5")
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Run error = %v, want context.Canceled", err)
+	}
+	if !strings.Contains(err.Error(), "cancelled while running sleep 5") {
+		t.Errorf("Run error = %q, want it to name the command", err)
+	}
+}
+
+func TestDefaultTimeout(t *testing.T) {
+	t.Parallel()
+
+	for _, tt := range []struct {
+		argv []string
+		want time.Duration
+	}{
+		{[]string{"aws", "cloudformation", "wait", "stack-create-complete"}, time.Hour},
+		{[]string{"aws", "sts", "get-caller-identity"}, 5 * time.Minute},
+		{[]string{"aws", "ecr", "put-image"}, 10 * time.Minute},
+		{[]string{"/home/u/.local/share/mise/installs/aws/bin/aws", "cloudfront", "wait", "x"}, time.Hour},
+		{[]string{"mise", "exec", "--", "cdk", "bootstrap"}, time.Hour},
+		{[]string{"mise", "exec", "--", "cdk", "deploy", "--all"}, 3 * time.Hour},
+		{[]string{"mise", "exec", "--", "psql"}, 0},
+		{[]string{"git", "rev-parse", "HEAD"}, 5 * time.Minute},
+		{[]string{"docker", "run", "-it", "postgres"}, 0},
+		{[]string{"go", "test", "./..."}, 0},
+	} {
+		if got := defaultTimeout(tt.argv[0], tt.argv[1:]); got != tt.want {
+			t.Errorf("defaultTimeout(%v) = %s, want %s", tt.argv, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package cmdexec
+
+import (
+	"context"
+	"encoding/json"
+	"os/exec"
+	"strings"
+	"sync"
+)
+
+// defaultToolCache is used by executors created with New and NewWithDir. It is nil, and tool
+// caching disabled, until EnableToolCache is called.
+var defaultToolCache *toolCache
+
+// EnableToolCache makes Mise and MiseOutput resolve each tool only once per process, with
+// "mise which" and "mise env", and run its binary directly afterwards. Every "mise exec" pays
+// the cost of loading the mise configuration and tool versions, which adds up for commands that
+// run many tools, such as bootstrap and deploy with their cdk calls; BenchmarkMiseOutput
+// measures the difference. Tools that mise cannot resolve still run through "mise exec". Only
+// executors created after the call use the cache.
+func EnableToolCache() {
+	defaultToolCache = newToolCache()
+}
+
+// toolCache holds, per working directory, the environment that mise activates and the paths of
+// the tools it resolved. Mise configuration is directory specific, so nothing is shared between
+// directories.
+type toolCache struct {
+	mu    sync.Mutex
+	envs  map[string][]string
+	paths map[string]string
+}
+
+func newToolCache() *toolCache {
+	return &toolCache{
+		envs:  map[string][]string{},
+		paths: map[string]string{},
+	}
+}
+
+// resolveTool returns an executor with the mise environment of e's directory and the path of the
+// named tool. The path is empty when caching is disabled or mise cannot resolve the tool, in
+// which case the caller should fall back to "mise exec".
+func (e *executor) resolveTool(ctx context.Context, name string) (*executor, string) {
+	if e.tools == nil {
+		return nil, ""
+	}
+
+	e.tools.mu.Lock()
+	defer e.tools.mu.Unlock()
+
+	env, ok := e.tools.envs[e.dir]
+	if !ok {
+		env = e.miseEnv(ctx)
+		e.tools.envs[e.dir] = env
+	}
+
+	key := e.dir + "\x00" + name
+	path, ok := e.tools.paths[key]
+	if !ok {
+		path = e.miseWhich(ctx, name)
+		e.tools.paths[key] = path
+	}
+
+	if env == nil || path == "" {
+		return nil, ""
+	}
+
+	// Variables set with WithEnv come last so that they take precedence over the mise environment.
+	return &executor{
+		dir:     e.dir,
+		stdout:  e.stdout,
+		stderr:  e.stderr,
+		env:     append(append([]string{}, env...), e.env...),
+		audit:   e.audit,
+		timeout: e.timeout,
+	}, path
+}
+
+// miseEnv returns the environment that "mise exec" would set in e's directory, or nil if it
+// cannot be determined.
+func (e *executor) miseEnv(ctx context.Context) []string {
+	output, err := e.Output(ctx, "mise", "env", "--json")
+	if err != nil {
+		return nil
+	}
+
+	var vars map[string]string
+	if err := json.Unmarshal([]byte(output), &vars); err != nil {
+		return nil
+	}
+
+	env := make([]string, 0, len(vars))
+	for key, value := range vars {
+		env = append(env, key+"="+value)
+	}
+
+	return env
+}
+
+// miseWhich returns the path of the binary mise would run for name in e's directory, or "" if
+// mise does not manage it.
+func (e *executor) miseWhich(ctx context.Context, name string) string {
+	output, err := e.Output(ctx, "mise", "which", name)
+	if err != nil {
+		return ""
+	}
+
+	path := strings.TrimSpace(output)
+	if _, err := exec.LookPath(path); err != nil {
+		return ""
+	}
+
+	return path
+}
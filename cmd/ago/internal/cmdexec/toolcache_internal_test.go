@@ -0,0 +1,143 @@
+//nolint:paralleltest // tests modify PATH to install a fake mise
+package cmdexec
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// installFakeMise puts a mise script on PATH that logs its invocations, resolves "tool" to a
+// script printing $GREETING, and reports GREETING=hello as its environment.
+func installFakeMise(t testing.TB) (logPath string) {
+	t.Helper()
+
+	dir := t.TempDir()
+	logPath = filepath.Join(dir, "mise.log")
+	toolPath := filepath.Join(dir, "tool")
+
+	writeScript(t, toolPath, `echo "$GREETING $*"`)
+	writeScript(t, filepath.Join(dir, "mise"), `echo "$1" >> `+logPath+`
+case "$1" in
+  which) [ "$2" = tool ] && echo `+toolPath+` || exit 1 ;;
+  env) echo '{"GREETING":"hello"}' ;;
+  exec) shift 2; echo "via-exec $*" ;;
+esac`)
+
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	return logPath
+}
+
+func writeScript(t testing.TB, path, body string) {
+	t.Helper()
+
+	//nolint:gosec // test scripts must be executable
+	if err := os.WriteFile(path, []byte("#!/bin/sh\n"+body+"\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func readLog(t *testing.T, path string) []string {
+	t.Helper()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return strings.Fields(string(data))
+}
+
+func TestMiseOutputWithToolCache(t *testing.T) {
+	logPath := installFakeMise(t)
+	exec := &executor{dir: t.TempDir(), tools: newToolCache()}
+
+	for range 3 {
+		output, err := exec.MiseOutput(context.Background(), "tool", "world")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if output != "hello world" {
+			t.Errorf("expected 'hello world', got %q", output)
+		}
+	}
+
+	if got, want := strings.Join(readLog(t, logPath), " "), "env which"; got != want {
+		t.Errorf("mise invocations = %q, want %q", got, want)
+	}
+}
+
+func TestMiseOutputWithToolCacheEnvPrecedence(t *testing.T) {
+	installFakeMise(t)
+	exec := (&executor{dir: t.TempDir(), tools: newToolCache()}).WithEnv("GREETING", "hi")
+
+	output, err := exec.MiseOutput(context.Background(), "tool", "there")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if output != "hi there" {
+		t.Errorf("expected 'hi there', got %q", output)
+	}
+}
+
+func TestMiseOutputWithToolCacheFallback(t *testing.T) {
+	logPath := installFakeMise(t)
+	exec := &executor{dir: t.TempDir(), tools: newToolCache()}
+
+	for range 2 {
+		output, err := exec.MiseOutput(context.Background(), "unknown", "arg")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if output != "via-exec unknown arg" {
+			t.Errorf("expected 'via-exec unknown arg', got %q", output)
+		}
+	}
+
+	if got, want := strings.Join(readLog(t, logPath), " "), "env which exec exec"; got != want {
+		t.Errorf("mise invocations = %q, want %q", got, want)
+	}
+}
+
+func TestMiseOutputWithoutToolCache(t *testing.T) {
+	logPath := installFakeMise(t)
+	exec := &executor{dir: t.TempDir()}
+
+	output, err := exec.MiseOutput(context.Background(), "tool", "world")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if output != "via-exec tool world" {
+		t.Errorf("expected 'via-exec tool world', got %q", output)
+	}
+
+	if got, want := strings.Join(readLog(t, logPath), " "), "exec"; got != want {
+		t.Errorf("mise invocations = %q, want %q", got, want)
+	}
+}
+
+// BenchmarkMiseOutput compares running a tool through "mise exec" with running it from the tool
+// cache. The fake mise is much faster than the real one, which loads its configuration and tool
+// versions on every call, so the difference is a lower bound.
+func BenchmarkMiseOutput(b *testing.B) {
+	for _, bm := range []struct {
+		name  string
+		tools *toolCache
+	}{
+		{name: "mise-exec"},
+		{name: "cached", tools: newToolCache()},
+	} {
+		b.Run(bm.name, func(b *testing.B) {
+			installFakeMise(b)
+			exec := &executor{dir: b.TempDir(), tools: bm.tools}
+			for b.Loop() {
+				if _, err := exec.MiseOutput(context.Background(), "tool", "world"); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
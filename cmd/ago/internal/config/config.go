@@ -5,7 +5,11 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"reflect"
+	"slices"
+	"time"
 
+	"github.com/advdv/ago/cmd/ago/internal/schema"
 	"github.com/cockroachdb/errors"
 	"github.com/go-playground/validator/v10"
 	"github.com/goccy/go-yaml"
@@ -13,8 +17,301 @@ import (
 
 const FileName = ".ago.yml"
 
+// SchemaID identifies the JSON Schema of version 1 of .ago.yml, as printed by 'ago meta schema'.
+// Editors load it from this URL when the file starts with:
+//
+//	# yaml-language-server: $schema=https://raw.githubusercontent.com/advdv/ago/main/cmd/ago/schemas/ago.v1.json
+const SchemaID = "https://raw.githubusercontent.com/advdv/ago/main/cmd/ago/schemas/ago.v1.json"
+
+// FallbackRegion is the AWS region used when neither the command nor .ago.yml specify one.
+const FallbackRegion = "eu-central-1"
+
+// Credentials backends for deployer credentials.
+const (
+	// CredentialsBackendFile stores deployer credentials in plaintext in ~/.aws/credentials.
+	CredentialsBackendFile = "file"
+	// CredentialsBackendAWSVault stores deployer credentials in aws-vault (OS keychain) and
+	// makes the profile reference them through credential_process.
+	CredentialsBackendAWSVault = "aws-vault"
+)
+
 type InnerConfig struct {
 	Version string `yaml:"version" validate:"required,oneof=1"`
+
+	// DefaultRegion overrides FallbackRegion for AWS profiles and account stacks, so that
+	// organizations can standardize on a region other than Frankfurt.
+	DefaultRegion string `yaml:"default_region,omitempty"`
+
+	// CredentialsBackend selects where deployer credentials are stored. Defaults to
+	// CredentialsBackendFile.
+	CredentialsBackend string `yaml:"credentials_backend,omitempty" validate:"omitempty,oneof=file aws-vault"`
+
+	// Hooks declares shell commands to run around commands, keyed by command name.
+	Hooks map[string]Hooks `yaml:"hooks,omitempty" validate:"omitempty,dive,keys,oneof=bootstrap deploy deploy-shared diff destroy secrets-rotate,endkeys"`
+
+	// Components declares additional backend images, such as a Python worker or a Node server,
+	// that are built and pushed together with the Go commands in backend/cmd.
+	Components []Component `yaml:"components,omitempty" validate:"dive"`
+
+	// BaseImage declares a shared base image that 'ago backend bump-base' builds and pins, and
+	// that the backend images are built on. Optional.
+	BaseImage *BaseImage `yaml:"base_image,omitempty"`
+
+	// Backups configures the data-safety policy that 'ago check backups' verifies.
+	Backups BackupPolicy `yaml:"backups,omitempty"`
+
+	// LockBackend selects where the locks of mutating commands are held. Defaults to
+	// LockBackendLocal.
+	LockBackend string `yaml:"lock_backend,omitempty" validate:"omitempty,oneof=local ssm"`
+
+	// Checks declares project-specific health checks that 'ago check' runs after its built-in
+	// checks.
+	Checks []Check `yaml:"checks,omitempty" validate:"dive"`
+
+	// Notifications configures the desktop notifications that long-running commands send when
+	// they finish or fail.
+	Notifications Notifications `yaml:"notifications,omitempty"`
+
+	// Layout declares where the backend, infra and CDK directories live, for projects that do
+	// not follow the layout of 'ago init'.
+	Layout Layout `yaml:"layout,omitempty"`
+
+	// DefaultDeployment is the deployment that commands act on when --deployment is not given.
+	DefaultDeployment string `yaml:"default_deployment,omitempty"`
+
+	// Profile is the AWS profile that commands use when neither --profile nor AWS_PROFILE is
+	// given, instead of the deployer profile that they look up in the CDK context.
+	Profile string `yaml:"profile,omitempty"`
+
+	// Builder selects the tool that builds backend images when --builder is not given. Defaults
+	// to the first builder whose tools are installed.
+	Builder string `yaml:"builder,omitempty" validate:"omitempty,oneof=depot docker podman"`
+
+	// Defaults sets the flags that the command line leaves unset, keyed by the command below
+	// ago, such as "backend deploy", and then by flag name. They take precedence over
+	// DefaultDeployment, Profile and Builder.
+	Defaults map[string]map[string]any `yaml:"defaults,omitempty"`
+}
+
+// Default directories of the project layout, relative to the project directory.
+const (
+	DefaultBackendDir = "backend"
+	DefaultInfraDir   = "infra"
+)
+
+// Layout declares the directories of a project, relative to the project directory. Directories
+// must stay inside the project directory.
+type Layout struct {
+	// BackendDir holds the Go module of the backend. Defaults to DefaultBackendDir.
+	BackendDir string `yaml:"backend_dir,omitempty" validate:"omitempty,reldir"`
+	// InfraDir holds the Go module of the infrastructure and deployments.yaml. Defaults to
+	// DefaultInfraDir.
+	InfraDir string `yaml:"infra_dir,omitempty" validate:"omitempty,reldir"`
+	// CDKDir holds cdk.json and cdk.context.json. Defaults to cdk/cdk in InfraDir.
+	CDKDir string `yaml:"cdk_dir,omitempty" validate:"omitempty,reldir"`
+}
+
+// Backend returns the configured backend directory, or DefaultBackendDir if none is set.
+func (l Layout) Backend() string {
+	if l.BackendDir != "" {
+		return filepath.Clean(l.BackendDir)
+	}
+	return DefaultBackendDir
+}
+
+// Infra returns the configured infra directory, or DefaultInfraDir if none is set.
+func (l Layout) Infra() string {
+	if l.InfraDir != "" {
+		return filepath.Clean(l.InfraDir)
+	}
+	return DefaultInfraDir
+}
+
+// CDK returns the configured CDK directory, or cdk/cdk in the infra directory if none is set.
+func (l Layout) CDK() string {
+	if l.CDKDir != "" {
+		return filepath.Clean(l.CDKDir)
+	}
+	return filepath.Join(l.Infra(), "cdk", "cdk")
+}
+
+// validRelDir reports whether a layout directory is relative and stays inside the project
+// directory.
+func validRelDir(fl validator.FieldLevel) bool {
+	return filepath.IsLocal(fl.Field().String())
+}
+
+// DefaultNotifyAfterSeconds is how long a command must run before it sends a notification when
+// Notifications.MinDurationSeconds is not set.
+const DefaultNotifyAfterSeconds = 60
+
+// Notifications configures desktop notifications for long-running commands, so developers can
+// switch to other work during bootstraps and deploys. Notifications are off unless enabled.
+type Notifications struct {
+	Enabled bool `yaml:"enabled,omitempty"`
+	// MinDurationSeconds is how long a command must run before it notifies, so quick runs stay
+	// quiet. Defaults to DefaultNotifyAfterSeconds.
+	MinDurationSeconds int `yaml:"min_duration_seconds,omitempty" validate:"omitempty,min=1"`
+	// Commands limits the notifications to these commands. Defaults to all commands that notify.
+	Commands []string `yaml:"commands,omitempty" validate:"omitempty,dive,oneof=bootstrap deploy deploy-shared create-account dns-verify"`
+}
+
+// MinDuration returns how long a command must run before it notifies.
+func (n Notifications) MinDuration() time.Duration {
+	if n.MinDurationSeconds > 0 {
+		return time.Duration(n.MinDurationSeconds) * time.Second
+	}
+	return DefaultNotifyAfterSeconds * time.Second
+}
+
+// Notify reports whether the command sends a notification after running for elapsed.
+func (n Notifications) Notify(command string, elapsed time.Duration) bool {
+	if !n.Enabled || elapsed < n.MinDuration() {
+		return false
+	}
+	return len(n.Commands) == 0 || slices.Contains(n.Commands, command)
+}
+
+// Check is a project-specific health check: a shell command that runs like a hook and fails the
+// check when it exits non-zero. The last line of its output is shown as the check's detail.
+type Check struct {
+	// Name identifies the check for 'ago check --only' and must not clash with a built-in check.
+	Name string `yaml:"name" validate:"required"`
+	// Run is the shell command of the check.
+	Run string `yaml:"run" validate:"required"`
+}
+
+// Lock backends.
+const (
+	// LockBackendLocal holds locks in files in the project directory, which only guards against
+	// concurrent runs from the same checkout.
+	LockBackendLocal = "local"
+	// LockBackendSSM holds locks in SSM parameters of the project account, which guards against
+	// concurrent runs by different deployers and CI.
+	LockBackendSSM = "ssm"
+)
+
+// Bucket versioning requirements of the backup policy.
+const (
+	// VersionedBucketsProtected requires versioning on buckets marked with agcdkutil.Protect.
+	VersionedBucketsProtected = "protected"
+	// VersionedBucketsAll requires versioning on every bucket.
+	VersionedBucketsAll = "all"
+	// VersionedBucketsNone disables the bucket versioning check.
+	VersionedBucketsNone = "none"
+)
+
+// BackupPolicy describes the backups that the data of deployments must have. Point-in-time
+// recovery is always required for DynamoDB tables.
+type BackupPolicy struct {
+	// MaxBackupAgeDays requires every DynamoDB table to have an on-demand backup that is at most
+	// this many days old. Zero disables the check.
+	MaxBackupAgeDays int `yaml:"max_backup_age_days,omitempty" validate:"omitempty,min=1"`
+	// VersionedBuckets selects the S3 buckets that must have versioning enabled. Defaults to
+	// VersionedBucketsProtected.
+	VersionedBuckets string `yaml:"versioned_buckets,omitempty" validate:"omitempty,oneof=all protected none"`
+}
+
+// BucketVersioning returns the configured bucket versioning requirement, or
+// VersionedBucketsProtected if none is set.
+func (p BackupPolicy) BucketVersioning() string {
+	if p.VersionedBuckets != "" {
+		return p.VersionedBuckets
+	}
+	return VersionedBucketsProtected
+}
+
+// Hash strategies for components.
+const (
+	// HashStrategyDockerignore hashes the files of the build context that .dockerignore allows.
+	HashStrategyDockerignore = "dockerignore"
+	// HashStrategyGit uses the git tree hash of the build context at HEAD.
+	HashStrategyGit = "git"
+)
+
+// Component is a backend image that is built from its own context and Dockerfile.
+type Component struct {
+	// Name identifies the component in image tags and must not clash with a backend/cmd name.
+	Name string `yaml:"name" validate:"required,lowercase,alphanum"`
+	// Context is the build context directory, relative to the project directory.
+	Context string `yaml:"context" validate:"required"`
+	// Dockerfile is the path of the Dockerfile relative to Context. Defaults to "Dockerfile".
+	Dockerfile string `yaml:"dockerfile,omitempty"`
+	// Hash selects how the source hash in the image tag is computed. Defaults to
+	// HashStrategyDockerignore.
+	Hash string `yaml:"hash,omitempty" validate:"omitempty,oneof=dockerignore git"`
+}
+
+// DockerfileName returns the configured Dockerfile, or "Dockerfile" if none is set.
+func (c Component) DockerfileName() string {
+	if c.Dockerfile != "" {
+		return c.Dockerfile
+	}
+	return "Dockerfile"
+}
+
+// HashStrategy returns the configured hash strategy, or HashStrategyDockerignore if none is set.
+func (c Component) HashStrategy() string {
+	if c.Hash != "" {
+		return c.Hash
+	}
+	return HashStrategyDockerignore
+}
+
+// BaseImage is the shared base image of the backend images, holding things like CA certificates
+// and shared configuration.
+type BaseImage struct {
+	// Context is the build context directory, relative to the project directory.
+	Context string `yaml:"context" validate:"required"`
+	// Dockerfile is the path of the Dockerfile relative to Context. Defaults to "Dockerfile.base".
+	Dockerfile string `yaml:"dockerfile,omitempty"`
+}
+
+// DockerfileName returns the configured Dockerfile, or "Dockerfile.base" if none is set.
+func (b BaseImage) DockerfileName() string {
+	if b.Dockerfile != "" {
+		return b.Dockerfile
+	}
+	return "Dockerfile.base"
+}
+
+// Hooks lists the shell commands that run before and after a command. Commands run in the
+// project directory, in order, and a failing pre hook aborts the command. They see the target
+// of the command in AGO_DEPLOYMENT, for a single deployment, and AGO_DEPLOYMENTS, a
+// comma-separated list of all deployments. The pre hooks of secrets-rotate run once the new value
+// is staged, to validate it; when one fails, the current value stays in place.
+type Hooks struct {
+	Pre  []string `yaml:"pre,omitempty"`
+	Post []string `yaml:"post,omitempty"`
+}
+
+// HooksFor returns the hooks declared for the given command.
+func (c InnerConfig) HooksFor(command string) Hooks {
+	return c.Hooks[command]
+}
+
+// Region returns the configured default region, or FallbackRegion if none is set.
+func (c InnerConfig) Region() string {
+	if c.DefaultRegion != "" {
+		return c.DefaultRegion
+	}
+	return FallbackRegion
+}
+
+// Locks returns the configured lock backend, or LockBackendLocal if none is set.
+func (c InnerConfig) Locks() string {
+	if c.LockBackend != "" {
+		return c.LockBackend
+	}
+	return LockBackendLocal
+}
+
+// Credentials returns the configured credentials backend, or CredentialsBackendFile if none is set.
+func (c InnerConfig) Credentials() string {
+	if c.CredentialsBackend != "" {
+		return c.CredentialsBackend
+	}
+	return CredentialsBackendFile
 }
 
 func Default() InnerConfig {
@@ -35,13 +332,31 @@ type Finder interface {
 	Find(startDir string) (cfg InnerConfig, projectDir string, err error)
 }
 
+// Schema returns the JSON Schema of .ago.yml, generated from InnerConfig.
+func Schema() *schema.Schema {
+	s := schema.For(reflect.TypeFor[InnerConfig](), "yaml")
+	s.Schema = schema.Dialect
+	s.ID = SchemaID
+	s.Title = FileName
+	s.Description = "Project configuration of ago"
+	return s
+}
+
 type yamlLoader struct {
 	validate *validator.Validate
+	schema   *schema.Schema
 }
 
 func NewLoader() Loader {
+	return newYAMLLoader()
+}
+
+func newYAMLLoader() *yamlLoader {
+	validate := validator.New()
+	_ = validate.RegisterValidation("reldir", validRelDir)
 	return &yamlLoader{
-		validate: validator.New(),
+		validate: validate,
+		schema:   Schema(),
 	}
 }
 
@@ -50,6 +365,19 @@ func (l *yamlLoader) Load(path string) (InnerConfig, error) {
 	if err != nil {
 		return InnerConfig{}, errors.Wrap(err, "failed to read config file")
 	}
+	return l.parse(path, data)
+}
+
+// parse validates and decodes the content of the config file at path.
+func (l *yamlLoader) parse(path string, data []byte) (InnerConfig, error) {
+	// The schema reports every problem at once, the decoder below only the first one.
+	var doc any
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return InnerConfig{}, errors.Wrap(err, "failed to parse config file")
+	}
+	if err := l.schema.Validate(doc); err != nil {
+		return InnerConfig{}, errors.Wrapf(err, "invalid %s", path)
+	}
 
 	dec := yaml.NewDecoder(
 		bytes.NewReader(data),
@@ -4,7 +4,9 @@ import (
 	"bytes"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/advdv/ago/cmd/ago/internal/config"
 )
@@ -75,6 +77,377 @@ func TestLoader(t *testing.T) {
 		}
 	})
 
+	t.Run("reports every problem against the schema", func(t *testing.T) {
+		t.Parallel()
+		dir := t.TempDir()
+		path := filepath.Join(dir, config.FileName)
+		content := "version: \"1\"\ncredentials_backend: keyring\nlock_backnd: ssm\nhooks:\n  deplyo:\n    pre: [make test]\n"
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+
+		loader := config.NewLoader()
+		_, err := loader.Load(path)
+		if err == nil {
+			t.Fatal("expected error, got nil")
+		}
+		for _, want := range []string{
+			"credentials_backend: must be one of", `unknown property "lock_backnd"`, "hooks.deplyo",
+		} {
+			if !strings.Contains(err.Error(), want) {
+				t.Errorf("expected %q in error:\n%v", want, err)
+			}
+		}
+	})
+
+	t.Run("loads default region", func(t *testing.T) {
+		t.Parallel()
+		dir := t.TempDir()
+		path := filepath.Join(dir, config.FileName)
+		content := "version: \"1\"\ndefault_region: us-west-2\n"
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+
+		loader := config.NewLoader()
+		cfg, err := loader.Load(path)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if cfg.Region() != "us-west-2" {
+			t.Errorf("expected region 'us-west-2', got %q", cfg.Region())
+		}
+	})
+
+	t.Run("falls back to default region", func(t *testing.T) {
+		t.Parallel()
+		cfg := config.Default()
+		if cfg.Region() != config.FallbackRegion {
+			t.Errorf("expected region %q, got %q", config.FallbackRegion, cfg.Region())
+		}
+	})
+
+	t.Run("loads credentials backend", func(t *testing.T) {
+		t.Parallel()
+		dir := t.TempDir()
+		path := filepath.Join(dir, config.FileName)
+		content := "version: \"1\"\ncredentials_backend: aws-vault\n"
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+
+		loader := config.NewLoader()
+		cfg, err := loader.Load(path)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if cfg.Credentials() != config.CredentialsBackendAWSVault {
+			t.Errorf("expected backend %q, got %q", config.CredentialsBackendAWSVault, cfg.Credentials())
+		}
+	})
+
+	t.Run("returns error for unknown credentials backend", func(t *testing.T) {
+		t.Parallel()
+		dir := t.TempDir()
+		path := filepath.Join(dir, config.FileName)
+		content := "version: \"1\"\ncredentials_backend: keyring\n"
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+
+		loader := config.NewLoader()
+		_, err := loader.Load(path)
+		if err == nil {
+			t.Fatal("expected error for unknown credentials backend, got nil")
+		}
+	})
+
+	t.Run("loads hooks", func(t *testing.T) {
+		t.Parallel()
+		dir := t.TempDir()
+		path := filepath.Join(dir, config.FileName)
+		content := "version: \"1\"\nhooks:\n  deploy:\n    pre: [\"mise run codegen\"]\n    post: [\"./notify.sh\"]\n"
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+
+		loader := config.NewLoader()
+		cfg, err := loader.Load(path)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		hooks := cfg.HooksFor("deploy")
+		if len(hooks.Pre) != 1 || hooks.Pre[0] != "mise run codegen" {
+			t.Errorf("expected pre hook 'mise run codegen', got %v", hooks.Pre)
+		}
+		if len(hooks.Post) != 1 || hooks.Post[0] != "./notify.sh" {
+			t.Errorf("expected post hook './notify.sh', got %v", hooks.Post)
+		}
+		if len(cfg.HooksFor("bootstrap").Pre) != 0 {
+			t.Errorf("expected no bootstrap hooks, got %v", cfg.HooksFor("bootstrap"))
+		}
+	})
+
+	t.Run("returns error for hooks on unknown command", func(t *testing.T) {
+		t.Parallel()
+		dir := t.TempDir()
+		path := filepath.Join(dir, config.FileName)
+		content := "version: \"1\"\nhooks:\n  launch:\n    pre: [\"true\"]\n"
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+
+		loader := config.NewLoader()
+		_, err := loader.Load(path)
+		if err == nil {
+			t.Fatal("expected error for hooks on unknown command, got nil")
+		}
+	})
+
+	t.Run("loads components", func(t *testing.T) {
+		t.Parallel()
+		dir := t.TempDir()
+		path := filepath.Join(dir, config.FileName)
+		content := "version: \"1\"\ncomponents:\n  - name: worker\n    context: workers/python\n  - name: web\n    context: web\n    dockerfile: Dockerfile.ssr\n    hash: git\n"
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+
+		loader := config.NewLoader()
+		cfg, err := loader.Load(path)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(cfg.Components) != 2 {
+			t.Fatalf("expected 2 components, got %d", len(cfg.Components))
+		}
+		worker, web := cfg.Components[0], cfg.Components[1]
+		if worker.DockerfileName() != "Dockerfile" || worker.HashStrategy() != config.HashStrategyDockerignore {
+			t.Errorf("expected defaults for worker, got %+v", worker)
+		}
+		if web.DockerfileName() != "Dockerfile.ssr" || web.HashStrategy() != config.HashStrategyGit {
+			t.Errorf("expected overrides for web, got %+v", web)
+		}
+	})
+
+	t.Run("returns error for invalid component", func(t *testing.T) {
+		t.Parallel()
+		dir := t.TempDir()
+		path := filepath.Join(dir, config.FileName)
+		content := "version: \"1\"\ncomponents:\n  - name: Worker-1\n"
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+
+		loader := config.NewLoader()
+		_, err := loader.Load(path)
+		if err == nil {
+			t.Fatal("expected error for invalid component, got nil")
+		}
+	})
+
+	t.Run("loads backup policy", func(t *testing.T) {
+		t.Parallel()
+		dir := t.TempDir()
+		path := filepath.Join(dir, config.FileName)
+		content := "version: \"1\"\nbackups:\n  max_backup_age_days: 2\n  versioned_buckets: all\n"
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+
+		loader := config.NewLoader()
+		cfg, err := loader.Load(path)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if cfg.Backups.MaxBackupAgeDays != 2 || cfg.Backups.BucketVersioning() != config.VersionedBucketsAll {
+			t.Errorf("unexpected backup policy %+v", cfg.Backups)
+		}
+		if got := config.Default().Backups.BucketVersioning(); got != config.VersionedBucketsProtected {
+			t.Errorf("expected default bucket versioning %q, got %q", config.VersionedBucketsProtected, got)
+		}
+	})
+
+	t.Run("loads lock backend", func(t *testing.T) {
+		t.Parallel()
+		dir := t.TempDir()
+		path := filepath.Join(dir, config.FileName)
+		content := "version: \"1\"\nlock_backend: ssm\n"
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+
+		loader := config.NewLoader()
+		cfg, err := loader.Load(path)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got := cfg.Locks(); got != config.LockBackendSSM {
+			t.Errorf("expected lock backend %q, got %q", config.LockBackendSSM, got)
+		}
+		if got := config.Default().Locks(); got != config.LockBackendLocal {
+			t.Errorf("expected default lock backend %q, got %q", config.LockBackendLocal, got)
+		}
+	})
+
+	t.Run("loads notifications", func(t *testing.T) {
+		t.Parallel()
+		dir := t.TempDir()
+		path := filepath.Join(dir, config.FileName)
+		content := "version: \"1\"\nnotifications:\n  enabled: true\n  min_duration_seconds: 120\n" +
+			"  commands: [deploy]\n"
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+
+		loader := config.NewLoader()
+		cfg, err := loader.Load(path)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !cfg.Notifications.Notify("deploy", 3*time.Minute) {
+			t.Error("expected a long deploy to notify")
+		}
+		if cfg.Notifications.Notify("deploy", time.Minute) {
+			t.Error("expected a deploy shorter than the minimum duration not to notify")
+		}
+		if cfg.Notifications.Notify("bootstrap", 3*time.Minute) {
+			t.Error("expected commands that are not listed not to notify")
+		}
+		if config.Default().Notifications.Notify("deploy", time.Hour) {
+			t.Error("expected notifications to be off by default")
+		}
+	})
+
+	t.Run("returns error for notifications on unknown command", func(t *testing.T) {
+		t.Parallel()
+		dir := t.TempDir()
+		path := filepath.Join(dir, config.FileName)
+		content := "version: \"1\"\nnotifications:\n  enabled: true\n  commands: [synth]\n"
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+
+		loader := config.NewLoader()
+		if _, err := loader.Load(path); err == nil {
+			t.Fatal("expected error for unknown command, got nil")
+		}
+	})
+
+	t.Run("loads base image", func(t *testing.T) {
+		t.Parallel()
+		dir := t.TempDir()
+		path := filepath.Join(dir, config.FileName)
+		content := "version: \"1\"\nbase_image:\n  context: backend\n"
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+
+		loader := config.NewLoader()
+		cfg, err := loader.Load(path)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if cfg.BaseImage == nil || cfg.BaseImage.Context != "backend" {
+			t.Fatalf("unexpected base image %+v", cfg.BaseImage)
+		}
+		if got := cfg.BaseImage.DockerfileName(); got != "Dockerfile.base" {
+			t.Errorf("expected default Dockerfile %q, got %q", "Dockerfile.base", got)
+		}
+		if config.Default().BaseImage != nil {
+			t.Error("expected no base image by default")
+		}
+	})
+
+	t.Run("returns error for base image without context", func(t *testing.T) {
+		t.Parallel()
+		dir := t.TempDir()
+		path := filepath.Join(dir, config.FileName)
+		content := "version: \"1\"\nbase_image:\n  dockerfile: Dockerfile.base\n"
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+
+		loader := config.NewLoader()
+		if _, err := loader.Load(path); err == nil {
+			t.Fatal("expected error for base image without context, got nil")
+		}
+	})
+
+	t.Run("returns error for invalid backup policy", func(t *testing.T) {
+		t.Parallel()
+		dir := t.TempDir()
+		path := filepath.Join(dir, config.FileName)
+		content := "version: \"1\"\nbackups:\n  versioned_buckets: some\n"
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+
+		loader := config.NewLoader()
+		_, err := loader.Load(path)
+		if err == nil {
+			t.Fatal("expected error for invalid backup policy, got nil")
+		}
+	})
+
+	t.Run("loads project layout", func(t *testing.T) {
+		t.Parallel()
+		dir := t.TempDir()
+		path := filepath.Join(dir, config.FileName)
+		content := "version: \"1\"\nlayout:\n  backend_dir: services/api\n  infra_dir: deploy\n"
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+
+		loader := config.NewLoader()
+		inner, err := loader.Load(path)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		cfg := config.Config{Inner: inner, ProjectDir: "/p"}
+		if got := cfg.BackendDir(); got != filepath.Join("/p", "services", "api") {
+			t.Errorf("expected backend dir /p/services/api, got %q", got)
+		}
+		if got := cfg.CDKDir(); got != filepath.Join("/p", "deploy", "cdk", "cdk") {
+			t.Errorf("expected CDK dir below the infra dir, got %q", got)
+		}
+		if got := (config.Config{ProjectDir: "/p"}).CDKDir(); got != filepath.Join("/p", "infra", "cdk", "cdk") {
+			t.Errorf("expected default CDK dir, got %q", got)
+		}
+	})
+
+	t.Run("returns error for layout outside the project", func(t *testing.T) {
+		t.Parallel()
+		dir := t.TempDir()
+		path := filepath.Join(dir, config.FileName)
+		content := "version: \"1\"\nlayout:\n  cdk_dir: ../shared/cdk\n"
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+
+		loader := config.NewLoader()
+		_, err := loader.Load(path)
+		if err == nil {
+			t.Fatal("expected error for layout outside the project, got nil")
+		}
+	})
+
+	t.Run("returns error for invalid builder", func(t *testing.T) {
+		t.Parallel()
+		dir := t.TempDir()
+		path := filepath.Join(dir, config.FileName)
+		content := "version: \"1\"\nbuilder: kaniko\n"
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+
+		loader := config.NewLoader()
+		_, err := loader.Load(path)
+		if err == nil {
+			t.Fatal("expected error for invalid builder, got nil")
+		}
+	})
+
 	t.Run("strict mode rejects unknown fields", func(t *testing.T) {
 		t.Parallel()
 		dir := t.TempDir()
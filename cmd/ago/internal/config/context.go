@@ -15,9 +15,19 @@ type Config struct {
 	ProjectDir string
 }
 
-// CDKDir returns the path to the CDK directory (infra/cdk/cdk).
+// BackendDir returns the path to the backend directory (backend unless .ago.yml moves it).
+func (c Config) BackendDir() string {
+	return filepath.Join(c.ProjectDir, c.Inner.Layout.Backend())
+}
+
+// InfraDir returns the path to the infra directory (infra unless .ago.yml moves it).
+func (c Config) InfraDir() string {
+	return filepath.Join(c.ProjectDir, c.Inner.Layout.Infra())
+}
+
+// CDKDir returns the path to the CDK directory (infra/cdk/cdk unless .ago.yml moves it).
 func (c Config) CDKDir() string {
-	return filepath.Join(c.ProjectDir, "infra", "cdk", "cdk")
+	return filepath.Join(c.ProjectDir, c.Inner.Layout.CDK())
 }
 
 // CDKContextPath returns the path to cdk.context.json.
@@ -30,6 +40,20 @@ func (c Config) CDKJSONPath() string {
 	return filepath.Join(c.CDKDir(), "cdk.json")
 }
 
+type resolvedKey struct{}
+
+// WithResolved returns a context that carries how the Settings of the running command were
+// resolved, see ApplyDefaults.
+func WithResolved(ctx context.Context, resolved []Resolved) context.Context {
+	return context.WithValue(ctx, resolvedKey{}, resolved)
+}
+
+// ResolvedFromContext returns the resolved Settings that WithResolved stored.
+func ResolvedFromContext(ctx context.Context) []Resolved {
+	resolved, _ := ctx.Value(resolvedKey{}).([]Resolved)
+	return resolved
+}
+
 func WithContext(ctx context.Context, cfg Config) context.Context {
 	return context.WithValue(ctx, contextKey{}, cfg)
 }
@@ -0,0 +1,212 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+	"github.com/urfave/cli/v3"
+)
+
+// Settings that every layer of configuration can provide. The value of a setting is taken from
+// the first layer that has one:
+//
+//	--flag > AGO_* variable > variable of the tool > .ago.yml defaults > .ago.yml key > CDK context > built-in
+//
+// ApplyDefaults applies the layers up to .ago.yml to the flags, so commands only add the
+// fallbacks of the CDK context. Variables of the tools, such as AWS_PROFILE, are read by the
+// tools and the AWS resolution of ago themselves, so they only stop .ago.yml from applying.
+var Settings = []Setting{
+	{
+		Flag: "profile", Env: "AGO_PROFILE", ToolEnv: []string{"AWS_PROFILE"}, Key: "profile",
+		value: func(c InnerConfig) string { return c.Profile },
+	},
+	{
+		Flag: "region", Env: "AGO_REGION", ToolEnv: []string{"AWS_REGION", "AWS_DEFAULT_REGION"}, Key: "default_region",
+		value: func(c InnerConfig) string { return c.DefaultRegion },
+	},
+	{
+		Flag: "deployment", Env: "AGO_DEPLOYMENT", Key: "default_deployment",
+		value: func(c InnerConfig) string { return c.DefaultDeployment },
+	},
+	{
+		Flag: "builder", Env: "AGO_BUILDER", Key: "builder",
+		value: func(c InnerConfig) string { return c.Builder },
+	},
+}
+
+// Setting is a value that flags, environment variables and .ago.yml can each provide.
+type Setting struct {
+	// Flag is the name of the flag that takes the setting.
+	Flag string
+	// Env is the variable that overrides .ago.yml, for example in CI.
+	Env string
+	// ToolEnv are the variables of the tools that ago runs, in order of precedence.
+	ToolEnv []string
+	// Key is the key of the setting in .ago.yml.
+	Key string
+
+	value func(InnerConfig) string
+}
+
+// Resolved is the value of a setting and the layer that it came from, such as "--region",
+// "AGO_REGION" or ".ago.yml default_region". Source is empty when no layer sets the value.
+type Resolved struct {
+	Name   string `json:"name"`
+	Value  string `json:"value"`
+	Source string `json:"source"`
+}
+
+// Resolve returns the value of the setting for a command, from the first layer up to .ago.yml
+// that has one.
+func (s Setting) Resolve(cmd *cli.Command, inner InnerConfig) (Resolved, error) {
+	resolved := Resolved{Name: s.Flag}
+	if hasFlag(cmd, s.Flag) && cmd.IsSet(s.Flag) {
+		resolved.Value, resolved.Source = cmd.String(s.Flag), "--"+s.Flag
+		return resolved, nil
+	}
+	for _, env := range append([]string{s.Env}, s.ToolEnv...) {
+		if value := os.Getenv(env); value != "" {
+			resolved.Value, resolved.Source = value, env
+			return resolved, nil
+		}
+	}
+
+	command := CommandPath(cmd)
+	if value, ok := inner.Defaults[command][s.Flag]; ok {
+		values, err := flagValues(value)
+		if err != nil {
+			return resolved, errors.Wrapf(err, "invalid default of --%s for '%s'", s.Flag, command)
+		}
+		if len(values) > 0 {
+			resolved.Value, resolved.Source = values[len(values)-1], fmt.Sprintf("%s defaults.%s", FileName, command)
+			return resolved, nil
+		}
+	}
+	if value := s.value(inner); value != "" {
+		resolved.Value, resolved.Source = value, FileName+" "+s.Key
+	}
+	return resolved, nil
+}
+
+// DefaultDeployment returns the deployment that the layers below the command line select, for
+// commands that take the deployment as an argument rather than as the --deployment flag that
+// ApplyDefaults sets: AGO_DEPLOYMENT, then the default_deployment of .ago.yml. Source is empty
+// when no layer selects one.
+func DefaultDeployment(inner InnerConfig) Resolved {
+	resolved := Resolved{Name: "deployment"}
+	for _, s := range Settings {
+		if s.Flag != resolved.Name {
+			continue
+		}
+		if value := os.Getenv(s.Env); value != "" {
+			resolved.Value, resolved.Source = value, s.Env
+		} else if value := s.value(inner); value != "" {
+			resolved.Value, resolved.Source = value, FileName+" "+s.Key
+		}
+	}
+	return resolved
+}
+
+// CommandPath returns the path of a command below the root command, such as "backend deploy",
+// which keys the Defaults of .ago.yml.
+func CommandPath(cmd *cli.Command) string {
+	return strings.TrimPrefix(strings.TrimPrefix(cmd.FullName(), cmd.Root().Name), " ")
+}
+
+// flagValues converts a YAML value to the values that set a flag: one for a scalar and one per
+// item for a list, which repeats the flag.
+func flagValues(value any) ([]string, error) {
+	switch v := value.(type) {
+	case []any:
+		values := make([]string, 0, len(v))
+		for _, item := range v {
+			item, err := flagValues(item)
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, item...)
+		}
+		return values, nil
+	case map[string]any:
+		return nil, errors.New("must be a scalar or a list of scalars")
+	case nil:
+		return nil, nil
+	default:
+		return []string{fmt.Sprint(v)}, nil
+	}
+}
+
+// ApplyDefaults sets the flags of cmd, and of its parents, that the command line leaves unset,
+// and returns how the Settings of the command were resolved. The flags of Settings take their
+// AGO_* variable or their .ago.yml value, unless a variable of the tool is set, see Settings.
+// Other flags take the Defaults of the command, which must not name flags that the command does
+// not have.
+func ApplyDefaults(cmd *cli.Command, inner InnerConfig) ([]Resolved, error) {
+	var settings []Resolved
+	for _, s := range Settings {
+		if !hasFlag(cmd, s.Flag) {
+			continue
+		}
+		resolved, err := s.Resolve(cmd, inner)
+		if err != nil {
+			return nil, err
+		}
+		settings = append(settings, resolved)
+
+		switch {
+		case resolved.Source == "", resolved.Source == "--"+s.Flag, slices.Contains(s.ToolEnv, resolved.Source):
+			continue
+		}
+		if err := cmd.Set(s.Flag, resolved.Value); err != nil {
+			return nil, errors.Wrapf(err, "failed to apply --%s from %s", s.Flag, resolved.Source)
+		}
+	}
+
+	command := CommandPath(cmd)
+	names := make([]string, 0, len(inner.Defaults[command]))
+	for name := range inner.Defaults[command] {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	for _, name := range names {
+		switch {
+		case !hasFlag(cmd, name):
+			return nil, errors.Newf("%s sets defaults for 'ago %s', which has no --%s flag", FileName, command, name)
+		case cmd.IsSet(name), isSetting(name):
+			continue
+		}
+
+		values, err := flagValues(inner.Defaults[command][name])
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid default of --%s for '%s'", name, command)
+		}
+		for _, value := range values {
+			if err := cmd.Set(name, value); err != nil {
+				return nil, errors.Wrapf(err, "failed to apply the default of --%s from %s", name, FileName)
+			}
+		}
+	}
+	return settings, nil
+}
+
+// isSetting reports whether the flag takes one of the Settings, which ApplyDefaults resolves
+// with the other layers.
+func isSetting(flag string) bool {
+	return slices.ContainsFunc(Settings, func(s Setting) bool { return s.Flag == flag })
+}
+
+// hasFlag reports whether cmd or one of its parents defines the named flag.
+func hasFlag(cmd *cli.Command, name string) bool {
+	for _, c := range cmd.Lineage() {
+		for _, f := range c.Flags {
+			if slices.Contains(f.Names(), name) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,169 @@
+package config_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/advdv/ago/cmd/ago/internal/config"
+	"github.com/urfave/cli/v3"
+)
+
+// runWithDefaults runs "ago backend deploy" with args, applying the defaults of inner, and
+// returns the flags that the action sees.
+func runWithDefaults(t *testing.T, inner config.InnerConfig, args ...string) (map[string]any, error) {
+	t.Helper()
+	got, _, err := runWithSettings(t, inner, args...)
+	return got, err
+}
+
+// runWithSettings is runWithDefaults that also returns how the settings were resolved.
+func runWithSettings(
+	t *testing.T, inner config.InnerConfig, args ...string,
+) (map[string]any, []config.Resolved, error) {
+	t.Helper()
+
+	var resolved []config.Resolved
+	got := map[string]any{}
+	deploy := &cli.Command{
+		Name: "deploy",
+		Flags: []cli.Flag{
+			&cli.StringFlag{Name: "deployment", Required: true},
+			&cli.StringFlag{Name: "builder"},
+			&cli.IntFlag{Name: "parallelism"},
+			&cli.StringSliceFlag{Name: "only"},
+		},
+		Before: func(ctx context.Context, cmd *cli.Command) (context.Context, error) {
+			var err error
+			resolved, err = config.ApplyDefaults(cmd, inner)
+			return ctx, err
+		},
+		Action: func(_ context.Context, cmd *cli.Command) error {
+			got["deployment"] = cmd.String("deployment")
+			got["builder"] = cmd.String("builder")
+			got["profile"] = cmd.String("profile")
+			got["region"] = cmd.String("region")
+			got["parallelism"] = cmd.Int("parallelism")
+			got["only"] = strings.Join(cmd.StringSlice("only"), ",")
+			return nil
+		},
+	}
+	root := &cli.Command{
+		Name:     "ago",
+		Flags:    []cli.Flag{&cli.StringFlag{Name: "profile"}, &cli.StringFlag{Name: "region"}},
+		Commands: []*cli.Command{{Name: "backend", Commands: []*cli.Command{deploy}}},
+	}
+
+	err := root.Run(context.Background(), append([]string{"ago", "backend", "deploy"}, args...))
+	return got, resolved, err
+}
+
+func TestApplyDefaults(t *testing.T) {
+	t.Parallel()
+
+	inner := config.InnerConfig{
+		DefaultDeployment: "Dev",
+		Builder:           "docker",
+		Defaults: map[string]map[string]any{
+			"backend deploy": {"builder": "depot", "parallelism": 4, "only": []any{"api", "worker"}},
+		},
+	}
+
+	got, err := runWithDefaults(t, inner)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]any{"deployment": "Dev", "builder": "depot", "parallelism": 4, "only": "api,worker"}
+	for name, value := range want {
+		if got[name] != value {
+			t.Errorf("--%s = %v, want %v", name, got[name], value)
+		}
+	}
+
+	got, err = runWithDefaults(t, inner, "--deployment", "Prod", "--builder", "podman")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got["deployment"] != "Prod" || got["builder"] != "podman" {
+		t.Errorf("expected flags to override the defaults, got %v", got)
+	}
+
+	inner.Defaults["backend deploy"]["dry"] = true
+	if _, err := runWithDefaults(t, inner); err == nil || !strings.Contains(err.Error(), "no --dry flag") {
+		t.Errorf("expected error for unknown flag, got %v", err)
+	}
+}
+
+func TestApplyDefaultsPrecedence(t *testing.T) {
+	inner := config.InnerConfig{
+		DefaultDeployment: "Dev",
+		DefaultRegion:     "eu-west-1",
+		Profile:           "myapp-ci",
+		Defaults:          map[string]map[string]any{"backend deploy": {"deployment": "Staging"}},
+	}
+	t.Setenv("AGO_DEPLOYMENT", "")
+	t.Setenv("AGO_REGION", "us-east-1")
+	t.Setenv("AWS_REGION", "ap-south-1")
+	t.Setenv("AGO_PROFILE", "")
+	t.Setenv("AWS_PROFILE", "alice")
+
+	got, resolved, err := runWithSettings(t, inner)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The command defaults beat default_deployment, AGO_REGION beats AWS_REGION and .ago.yml,
+	// and AWS_PROFILE, which the AWS resolution reads itself, keeps the .ago.yml profile away.
+	want := map[string]any{"deployment": "Staging", "region": "us-east-1", "profile": ""}
+	for name, value := range want {
+		if got[name] != value {
+			t.Errorf("--%s = %v, want %v", name, got[name], value)
+		}
+	}
+
+	sources := map[string]string{}
+	for _, r := range resolved {
+		sources[r.Name] = r.Source
+	}
+	wantSources := map[string]string{
+		"profile":    "AWS_PROFILE",
+		"region":     "AGO_REGION",
+		"deployment": ".ago.yml defaults.backend deploy",
+		"builder":    "",
+	}
+	for name, source := range wantSources {
+		if sources[name] != source {
+			t.Errorf("source of %s = %q, want %q", name, sources[name], source)
+		}
+	}
+
+	t.Setenv("AGO_DEPLOYMENT", "Prod")
+	got, resolved, err = runWithSettings(t, inner, "--region", "sa-east-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got["deployment"] != "Prod" || got["region"] != "sa-east-1" {
+		t.Errorf("expected AGO_DEPLOYMENT and --region to win, got %v", got)
+	}
+	if resolved[1].Source != "--region" {
+		t.Errorf("expected --region as the source of the region, got %q", resolved[1].Source)
+	}
+}
+
+func TestDefaultDeployment(t *testing.T) {
+	t.Setenv("AGO_DEPLOYMENT", "")
+	if got := config.DefaultDeployment(config.InnerConfig{}); got.Value != "" || got.Source != "" {
+		t.Errorf("expected no default deployment, got %+v", got)
+	}
+
+	inner := config.InnerConfig{DefaultDeployment: "Staging"}
+	got := config.DefaultDeployment(inner)
+	if got.Value != "Staging" || got.Source != ".ago.yml default_deployment" {
+		t.Errorf("expected the default_deployment of .ago.yml, got %+v", got)
+	}
+
+	t.Setenv("AGO_DEPLOYMENT", "Prod")
+	if got := config.DefaultDeployment(inner); got.Value != "Prod" || got.Source != "AGO_DEPLOYMENT" {
+		t.Errorf("expected AGO_DEPLOYMENT to win, got %+v", got)
+	}
+}
@@ -0,0 +1,169 @@
+package config
+
+import (
+	"strings"
+
+	"github.com/cockroachdb/errors"
+	"github.com/goccy/go-yaml"
+)
+
+// Effective returns the configuration with the defaults of unset settings filled in, as the
+// commands see it.
+func (c InnerConfig) Effective() InnerConfig {
+	c.DefaultRegion = c.Region()
+	c.CredentialsBackend = c.Credentials()
+	c.LockBackend = c.Locks()
+	c.Backups.VersionedBuckets = c.Backups.BucketVersioning()
+	c.Layout = Layout{BackendDir: c.Layout.Backend(), InfraDir: c.Layout.Infra(), CDKDir: c.Layout.CDK()}
+	return c
+}
+
+// splitKey splits a dotted key, such as "layout.cdk_dir" or "defaults.backend deploy.builder",
+// into its parts.
+func splitKey(key string) ([]string, error) {
+	parts := strings.Split(key, ".")
+	if key == "" || len(parts) == 0 {
+		return nil, errors.New("empty key")
+	}
+	for _, part := range parts {
+		if part == "" {
+			return nil, errors.Newf("invalid key %q", key)
+		}
+	}
+	return parts, nil
+}
+
+// knownKey reports whether the schema of .ago.yml allows the key.
+func knownKey(parts []string) bool {
+	s := Schema()
+	for _, part := range parts {
+		switch {
+		case s.Properties[part] != nil:
+			s = s.Properties[part]
+		case s.AdditionalProperties != nil:
+			s = s.AdditionalProperties
+		case s.Type == "":
+			// Free-form values, such as the flag defaults, may nest anything.
+			return true
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// Get returns the value of a dotted key in the effective configuration, see Effective, or nil if
+// the key is not set. Objects are returned as maps.
+func Get(inner InnerConfig, key string) (any, error) {
+	parts, err := splitKey(key)
+	if err != nil {
+		return nil, err
+	}
+	if !knownKey(parts) {
+		return nil, errors.Newf("unknown key %q", key)
+	}
+
+	data, err := yaml.Marshal(inner.Effective())
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to marshal config")
+	}
+	var value any
+	if err := yaml.Unmarshal(data, &value); err != nil {
+		return nil, errors.Wrap(err, "failed to unmarshal config")
+	}
+
+	for _, part := range parts {
+		m, ok := value.(map[string]any)
+		if !ok {
+			return nil, nil
+		}
+		value = m[part]
+	}
+	return value, nil
+}
+
+// Set returns the content of a config file with a dotted key set to value, which is parsed as
+// YAML, so "true" sets a boolean and "[a, b]" a list. An empty value removes the key. Comments
+// and the order of keys are kept, and the result must be a valid config.
+func Set(data []byte, key, value string) ([]byte, error) {
+	parts, err := splitKey(key)
+	if err != nil {
+		return nil, err
+	}
+	if !knownKey(parts) {
+		return nil, errors.Newf("unknown key %q", key)
+	}
+
+	comments := yaml.CommentMap{}
+	var doc yaml.MapSlice
+	if err := yaml.UnmarshalWithOptions(data, &doc, yaml.UseOrderedMap(), yaml.CommentToMap(comments)); err != nil {
+		return nil, errors.Wrap(err, "failed to parse config file")
+	}
+
+	var parsed any
+	if value != "" {
+		if err := yaml.Unmarshal([]byte(value), &parsed); err != nil {
+			return nil, errors.Wrapf(err, "failed to parse value of %s", key)
+		}
+	}
+	doc, err = setIn(doc, parts, parsed, value == "")
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to set %s", key)
+	}
+
+	out, err := yaml.MarshalWithOptions(doc, yaml.WithComment(comments))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to marshal config")
+	}
+	if _, err := newYAMLLoader().parse(FileName, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// setIn sets, or removes, the value at the path of keys in m, creating the objects on the way.
+func setIn(m yaml.MapSlice, keys []string, value any, remove bool) (yaml.MapSlice, error) {
+	idx := -1
+	for i, item := range m {
+		if k, ok := item.Key.(string); ok && k == keys[0] {
+			idx = i
+			break
+		}
+	}
+
+	if len(keys) == 1 {
+		switch {
+		case remove && idx >= 0:
+			return append(m[:idx], m[idx+1:]...), nil
+		case remove:
+			return m, nil
+		case idx >= 0:
+			m[idx].Value = value
+			return m, nil
+		default:
+			return append(m, yaml.MapItem{Key: keys[0], Value: value}), nil
+		}
+	}
+
+	var child yaml.MapSlice
+	if idx >= 0 && m[idx].Value != nil {
+		var ok bool
+		if child, ok = m[idx].Value.(yaml.MapSlice); !ok {
+			return nil, errors.Newf("%s is not an object", keys[0])
+		}
+	}
+	child, err := setIn(child, keys[1:], value, remove)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case idx < 0 && len(child) > 0:
+		return append(m, yaml.MapItem{Key: keys[0], Value: child}), nil
+	case idx >= 0 && len(child) == 0:
+		return append(m[:idx], m[idx+1:]...), nil
+	case idx >= 0:
+		m[idx].Value = child
+	}
+	return m, nil
+}
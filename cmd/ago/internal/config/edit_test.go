@@ -0,0 +1,79 @@
+package config_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/advdv/ago/cmd/ago/internal/config"
+)
+
+func TestGet(t *testing.T) {
+	t.Parallel()
+
+	inner := config.InnerConfig{
+		Version:           "1",
+		DefaultDeployment: "Dev",
+		Defaults:          map[string]map[string]any{"backend deploy": {"builder": "depot"}},
+	}
+
+	for key, want := range map[string]any{
+		"default_deployment":              "Dev",
+		"default_region":                  config.FallbackRegion,
+		"layout.cdk_dir":                  "infra/cdk/cdk",
+		"defaults.backend deploy.builder": "depot",
+		"builder":                         nil,
+	} {
+		got, err := config.Get(inner, key)
+		if err != nil {
+			t.Fatalf("Get(%q): unexpected error: %v", key, err)
+		}
+		if got != want {
+			t.Errorf("Get(%q) = %v, want %v", key, got, want)
+		}
+	}
+
+	if _, err := config.Get(inner, "layout.frontend_dir"); err == nil {
+		t.Error("expected error for unknown key, got nil")
+	}
+}
+
+func TestSet(t *testing.T) {
+	t.Parallel()
+
+	data := []byte("# yaml-language-server: $schema=" + config.SchemaID + "\nversion: \"1\"\n" +
+		"# Deploy from CI only.\nlock_backend: ssm\n")
+
+	got, err := config.Set(data, "layout.cdk_dir", "cdk")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err = config.Set(got, "defaults.backend deploy.builder", "depot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err = config.Set(got, "lock_backend", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range []string{
+		"$schema=" + config.SchemaID, "layout:\n  cdk_dir: cdk\n", "backend deploy:\n    builder: depot\n",
+	} {
+		if !strings.Contains(string(got), want) {
+			t.Errorf("expected %q in:\n%s", want, got)
+		}
+	}
+	if strings.Contains(string(got), "lock_backend") {
+		t.Errorf("expected lock_backend to be removed:\n%s", got)
+	}
+
+	if _, err := config.Set(data, "builder", "kaniko"); err == nil {
+		t.Error("expected error for invalid value, got nil")
+	}
+	if _, err := config.Set(data, "layout.frontend_dir", "web"); err == nil {
+		t.Error("expected error for unknown key, got nil")
+	}
+	if _, err := config.Set(data, "version.major", "1"); err == nil {
+		t.Error("expected error for key below a scalar, got nil")
+	}
+}
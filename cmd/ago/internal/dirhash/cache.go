@@ -0,0 +1,149 @@
+package dirhash
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/cockroachdb/errors"
+)
+
+// racyWindow is how recent a modification must be for the digest of a file not to be cached. A
+// file written again within the resolution of its modification time would otherwise keep the
+// digest of its earlier content, as git's "racy" entries would.
+const racyWindow = 2 * time.Second
+
+// WithCache stores the digests of the hashed files in dir, keyed by their path, size,
+// modification time and inode, so that hashing again only reads the files that changed. The
+// cache of a directory is discarded when its ignore patterns change. An empty dir disables the
+// cache.
+func WithCache(dir string) Option {
+	return func(h *Hasher) {
+		h.cacheDir = dir
+	}
+}
+
+// digestCache holds the file digests of one hashed directory.
+type digestCache struct {
+	// Patterns fingerprints the ignore patterns and always included paths that the digests were
+	// collected with.
+	Patterns string                 `json:"patterns"`
+	Files    map[string]cachedEntry `json:"files"`
+}
+
+type cachedEntry struct {
+	Size    int64  `json:"size"`
+	ModTime int64  `json:"mod_time"`
+	Inode   uint64 `json:"inode"`
+	Digest  []byte `json:"digest"`
+}
+
+// cachePath returns the cache file of a hashed directory.
+func (h *Hasher) cachePath(dir string) (string, error) {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to resolve directory")
+	}
+	sum := sha256.Sum256([]byte(abs))
+	return filepath.Join(h.cacheDir, hex.EncodeToString(sum[:8])+".json"), nil
+}
+
+// patternsFingerprint identifies the ignore patterns and always included paths of a hash.
+func (h *Hasher) patternsFingerprint(matcher *mobyMatcher) string {
+	always := make([]string, 0, len(h.alwaysInclude))
+	for p := range h.alwaysInclude {
+		always = append(always, p)
+	}
+	slices.Sort(always)
+
+	sum := sha256.Sum256([]byte(strings.Join(matcher.patterns, "\n") + "\x00" + strings.Join(always, "\n")))
+	return hex.EncodeToString(sum[:])
+}
+
+// loadCache reads the cache of a directory. A missing or unreadable cache, or one collected with
+// other patterns, yields an empty cache.
+func (h *Hasher) loadCache(path, patterns string) *digestCache {
+	empty := &digestCache{Patterns: patterns, Files: map[string]cachedEntry{}}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return empty
+	}
+	var cache digestCache
+	if err := json.Unmarshal(data, &cache); err != nil || cache.Patterns != patterns || cache.Files == nil {
+		return empty
+	}
+	return &cache
+}
+
+// saveCache writes the cache atomically, so concurrent hashes never read a partial file.
+func (h *Hasher) saveCache(path string, cache *digestCache) error {
+	data, err := json.Marshal(cache)
+	if err != nil {
+		return errors.Wrap(err, "failed to encode dirhash cache")
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return errors.Wrap(err, "failed to create dirhash cache directory")
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return errors.Wrap(err, "failed to create dirhash cache file")
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return errors.Wrap(err, "failed to write dirhash cache file")
+	}
+	if err := tmp.Close(); err != nil {
+		return errors.Wrap(err, "failed to write dirhash cache file")
+	}
+	return errors.Wrap(os.Rename(tmp.Name(), path), "failed to store dirhash cache file")
+}
+
+// cachedDigests returns the digests of the files, reading only those whose cache entry is
+// missing or stale, and stores the digests of the files for the next hash.
+func (h *Hasher) cachedDigests(dir string, files []string, matcher *mobyMatcher) ([][]byte, error) {
+	path, err := h.cachePath(dir)
+	if err != nil {
+		return nil, err
+	}
+	cache := h.loadCache(path, h.patternsFingerprint(matcher))
+
+	now := time.Now()
+	next := &digestCache{Patterns: cache.Patterns, Files: make(map[string]cachedEntry, len(files))}
+	digests := make([][]byte, 0, len(files))
+	for _, relPath := range files {
+		absPath := filepath.Join(dir, relPath)
+		info, err := os.Stat(absPath)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to stat %s", relPath)
+		}
+
+		entry := cachedEntry{Size: info.Size(), ModTime: info.ModTime().UnixNano(), Inode: inode(info)}
+		if cached, ok := cache.Files[relPath]; ok &&
+			cached.Size == entry.Size && cached.ModTime == entry.ModTime && cached.Inode == entry.Inode {
+			entry.Digest = cached.Digest
+		} else {
+			entry.Digest, err = h.fileDigest(absPath, relPath)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		digests = append(digests, entry.Digest)
+		if now.Sub(info.ModTime()) >= racyWindow {
+			next.Files[relPath] = entry
+		}
+	}
+
+	// The cache only saves reads, so failing to store it must not fail the hash.
+	_ = h.saveCache(path, next)
+	return digests, nil
+}
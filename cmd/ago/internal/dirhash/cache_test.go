@@ -0,0 +1,80 @@
+package dirhash_test
+
+import (
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/advdv/ago/cmd/ago/internal/dirhash"
+)
+
+// countingReader counts the files that the hasher reads.
+type countingReader struct {
+	reads atomic.Int32
+}
+
+func (r *countingReader) ReadFile(path string) ([]byte, error) {
+	r.reads.Add(1)
+	return os.ReadFile(path)
+}
+
+// writeOldFile writes a file with a modification time outside the racy window of the cache.
+func writeOldFile(t *testing.T, dir, name, content string, age time.Duration) {
+	t.Helper()
+	writeFile(t, dir, name, content)
+	mtime := time.Now().Add(-age)
+	if err := os.Chtimes(filepath.Join(dir, name), mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestHash_Cache(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	cacheDir := t.TempDir()
+
+	writeOldFile(t, dir, "a.go", "package a", time.Hour)
+	writeOldFile(t, dir, "b.go", "package b", time.Hour)
+
+	reader := &countingReader{}
+	cached := dirhash.New(dirhash.WithCache(cacheDir), dirhash.WithFileReader(reader))
+	hash := func(wantReads int32) string {
+		t.Helper()
+		reader.reads.Store(0)
+		got, err := cached.Hash(dir, ".dockerignore")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if n := reader.reads.Load(); n != wantReads {
+			t.Errorf("expected %d reads, got %d", wantReads, n)
+		}
+		want, err := dirhash.New().Hash(dir, ".dockerignore")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got != want {
+			t.Errorf("cached hash %s differs from uncached hash %s", got, want)
+		}
+		return got
+	}
+
+	first := hash(2)
+	if again := hash(0); again != first {
+		t.Errorf("expected the same hash, got %s and %s", first, again)
+	}
+
+	writeOldFile(t, dir, "b.go", "package b // modified", 30*time.Minute)
+	if changed := hash(1); changed == first {
+		t.Error("expected the hash to change with the content")
+	}
+
+	writeOldFile(t, dir, ".dockerignore", "c.go", time.Hour)
+	hash(3)
+	hash(0)
+
+	writeFile(t, dir, "d.go", "package d")
+	hash(1)
+	hash(1)
+}
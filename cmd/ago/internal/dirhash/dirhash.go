@@ -1,15 +1,20 @@
 // Package dirhash provides content-based hashing of directories
-// with support for dockerignore-style pattern filtering.
+// with support for dockerignore-style pattern filtering. Patterns can be
+// layered from several sources, such as .gitignore files throughout the tree,
+// a .dockerignore at the root and explicit patterns.
 package dirhash
 
 import (
 	"bufio"
 	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"io"
 	"io/fs"
 	"os"
+	"path"
 	"path/filepath"
+	"slices"
 	"sort"
 	"strings"
 
@@ -42,11 +47,31 @@ type Logger interface {
 
 // Hasher computes a content-based hash of a directory.
 type Hasher struct {
-	ignoreParser   IgnoreParser
-	fileReader     FileReader
-	logger         Logger
-	alwaysInclude  map[string]bool
-	truncateLength int
+	ignoreParser    IgnoreParser
+	fileReader      FileReader
+	logger          Logger
+	alwaysInclude   map[string]bool
+	excludePatterns []string
+	ignoreFiles     []IgnoreFile
+	cacheDir        string
+	truncateLength  int
+}
+
+// IgnoreFile is an ignore file that is read in addition to the one passed to Hash.
+type IgnoreFile struct {
+	// Name is the file name, such as ".gitignore".
+	Name string
+	// Parser parses the file. Nil uses the ignore parser of the Hasher.
+	Parser IgnoreParser
+	// Nested also reads the file in subdirectories, where its patterns apply relative to the
+	// subdirectory, as git does.
+	Nested bool
+}
+
+// GitIgnore returns the .gitignore files of the tree, with git's matching rules: patterns without
+// a slash match at any depth.
+func GitIgnore() IgnoreFile {
+	return IgnoreFile{Name: ".gitignore", Parser: &gitignoreParser{}, Nested: true}
 }
 
 // Option configures a Hasher.
@@ -83,6 +108,24 @@ func WithAlwaysInclude(paths ...string) Option {
 	}
 }
 
+// WithExcludePatterns sets ignore patterns that apply in addition to the ignore file. They come
+// before the patterns of the ignore file, which can re-include paths with negations.
+func WithExcludePatterns(patterns ...string) Option {
+	return func(h *Hasher) {
+		h.excludePatterns = patterns
+	}
+}
+
+// WithIgnoreFiles layers ignore files in the given order. Their patterns come after the exclude
+// patterns and before the patterns of the ignore file passed to Hash, so each source can
+// re-include paths that an earlier one excluded. The patterns of nested files come after those
+// of the root file, parents before children.
+func WithIgnoreFiles(files ...IgnoreFile) Option {
+	return func(h *Hasher) {
+		h.ignoreFiles = files
+	}
+}
+
 // WithTruncateLength sets the hash output length (0 for full hash).
 func WithTruncateLength(n int) Option {
 	return func(h *Hasher) {
@@ -106,19 +149,39 @@ func New(opts ...Option) *Hasher {
 }
 
 // Hash computes the content hash of a directory.
-// It reads the ignore file (e.g., .dockerignore) from the directory root.
+// It reads the ignore file (e.g., .dockerignore) from the directory root. An empty
+// ignoreFileName hashes with the exclude patterns and ignore files of the options only.
 func (h *Hasher) Hash(dir string, ignoreFileName string) (string, error) {
-	matcher, err := h.loadIgnorePatterns(dir, ignoreFileName)
+	m, err := h.Manifest(dir, ignoreFileName)
 	if err != nil {
 		return "", err
 	}
+	return m.Hash, nil
+}
 
-	files, err := h.collectFiles(dir, matcher)
+// Manifest computes the content hash of a directory like Hash, along with the digest of each
+// file that went into it.
+func (h *Hasher) Manifest(dir string, ignoreFileName string) (Manifest, error) {
+	matcher, err := h.loadIgnorePatterns(dir, ignoreFileName)
 	if err != nil {
-		return "", err
+		return Manifest{}, err
+	}
+
+	files, err := h.collectFiles(dir, matcher, h.logger)
+	if err != nil {
+		return Manifest{}, err
+	}
+
+	digests, err := h.fileDigests(dir, files, matcher)
+	if err != nil {
+		return Manifest{}, err
 	}
 
-	return h.hashFiles(dir, files)
+	m := Manifest{Hash: h.hashFiles(files, digests), Files: make(map[string]string, len(files))}
+	for i, relPath := range files {
+		m.Files[relPath] = hex.EncodeToString(digests[i])
+	}
+	return m, nil
 }
 
 // CollectedFiles returns the list of files that would be hashed (for testing/debugging).
@@ -128,30 +191,80 @@ func (h *Hasher) CollectedFiles(dir string, ignoreFileName string) ([]string, er
 		return nil, err
 	}
 
-	return h.collectFiles(dir, matcher)
+	return h.collectFiles(dir, matcher, h.logger)
 }
 
 func (h *Hasher) loadIgnorePatterns(dir, ignoreFileName string) (*mobyMatcher, error) {
-	ignorePath := filepath.Join(dir, ignoreFileName)
+	patterns := slices.Clone(h.excludePatterns)
+	for _, f := range h.ignoreFiles {
+		filePatterns, err := h.readIgnoreFile(filepath.Join(dir, f.Name), f.Parser)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to read %s", f.Name)
+		}
+		patterns = append(patterns, filePatterns...)
+	}
+
+	var tail []string
+	if ignoreFileName != "" {
+		filePatterns, err := h.readIgnoreFile(filepath.Join(dir, ignoreFileName), nil)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to read %s", ignoreFileName)
+		}
+		tail = filePatterns
+	}
 
-	f, err := os.Open(ignorePath)
+	matcher, err := newMobyMatcher(append(slices.Clone(patterns), tail...))
+	if err != nil || !slices.ContainsFunc(h.ignoreFiles, func(f IgnoreFile) bool { return f.Nested }) {
+		return matcher, err
+	}
+
+	nested, err := h.nestedIgnorePatterns(dir, matcher)
 	if err != nil {
-		if os.IsNotExist(err) {
-			pm, err := patternmatcher.New(nil)
+		return nil, err
+	}
+	return newMobyMatcher(slices.Concat(patterns, nested, tail))
+}
+
+// nestedIgnorePatterns reads the nested ignore files in the directories that the root patterns
+// leave in, and anchors their patterns at the directory of the file. Like git, it does not read
+// ignore files in excluded directories.
+func (h *Hasher) nestedIgnorePatterns(dir string, matcher *mobyMatcher) ([]string, error) {
+	files, err := h.collectFiles(dir, matcher, &nullLogger{})
+	if err != nil {
+		return nil, err
+	}
+
+	var patterns []string
+	for _, f := range h.ignoreFiles {
+		if !f.Nested {
+			continue
+		}
+		for _, relPath := range files {
+			subdir, name := path.Split(relPath)
+			if subdir == "" || name != f.Name {
+				continue
+			}
+			filePatterns, err := h.readIgnoreFile(filepath.Join(dir, filepath.FromSlash(relPath)), f.Parser)
 			if err != nil {
-				return nil, err
+				return nil, errors.Wrapf(err, "failed to read %s", relPath)
+			}
+			for _, p := range filePatterns {
+				patterns = append(patterns, anchorPattern(subdir, p))
 			}
-			return &mobyMatcher{pm: pm, hasNegation: false}, nil
 		}
-		return nil, errors.Wrapf(err, "failed to open %s", ignoreFileName)
 	}
-	defer f.Close()
+	return patterns, nil
+}
 
-	patterns, err := h.ignoreParser.Parse(f)
-	if err != nil {
-		return nil, errors.Wrapf(err, "failed to parse %s", ignoreFileName)
+// anchorPattern makes a pattern of an ignore file in subdir relative to the root.
+func anchorPattern(subdir, pattern string) string {
+	if rest, ok := strings.CutPrefix(pattern, "!"); ok {
+		return "!" + subdir + strings.TrimPrefix(rest, "/")
 	}
+	return subdir + strings.TrimPrefix(pattern, "/")
+}
 
+func newMobyMatcher(patterns []string) (*mobyMatcher, error) {
 	hasNegation := false
 	for _, p := range patterns {
 		if strings.HasPrefix(p, "!") {
@@ -162,13 +275,31 @@ func (h *Hasher) loadIgnorePatterns(dir, ignoreFileName string) (*mobyMatcher, e
 
 	pm, err := patternmatcher.New(patterns)
 	if err != nil {
-		return nil, errors.Wrapf(err, "failed to compile patterns from %s", ignoreFileName)
+		return nil, errors.Wrap(err, "failed to compile ignore patterns")
+	}
+
+	return &mobyMatcher{pm: pm, patterns: patterns, hasNegation: hasNegation}, nil
+}
+
+// readIgnoreFile returns the patterns of an ignore file, or none if the file does not exist. A nil
+// parser uses the ignore parser of the Hasher.
+func (h *Hasher) readIgnoreFile(path string, parser IgnoreParser) ([]string, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
 	}
+	defer f.Close()
 
-	return &mobyMatcher{pm: pm, hasNegation: hasNegation}, nil
+	if parser == nil {
+		parser = h.ignoreParser
+	}
+	return parser.Parse(f)
 }
 
-func (h *Hasher) collectFiles(dir string, matcher *mobyMatcher) ([]string, error) {
+func (h *Hasher) collectFiles(dir string, matcher *mobyMatcher, logger Logger) ([]string, error) {
 	parentMatchInfo := make(map[string]patternmatcher.MatchInfo)
 	var files []string
 
@@ -189,7 +320,7 @@ func (h *Hasher) collectFiles(dir string, matcher *mobyMatcher) ([]string, error
 
 		if h.alwaysInclude[relPath] {
 			if !isDir {
-				h.logger.LogFile(relPath, "always included")
+				logger.LogFile(relPath, "always included")
 				files = append(files, relPath)
 			}
 			return nil
@@ -213,19 +344,19 @@ func (h *Hasher) collectFiles(dir string, matcher *mobyMatcher) ([]string, error
 		if isDir {
 			parentMatchInfo[relPath] = matchInfo
 			if matched && !matcher.hasNegation {
-				h.logger.LogSkip(relPath, true)
+				logger.LogSkip(relPath, true)
 				return filepath.SkipDir
 			}
-			h.logger.LogDir(relPath)
+			logger.LogDir(relPath)
 			return nil
 		}
 
 		if matched {
-			h.logger.LogSkip(relPath, false)
+			logger.LogSkip(relPath, false)
 			return nil
 		}
 
-		h.logger.LogFile(relPath, "")
+		logger.LogFile(relPath, "")
 		files = append(files, relPath)
 		return nil
 	})
@@ -237,32 +368,52 @@ func (h *Hasher) collectFiles(dir string, matcher *mobyMatcher) ([]string, error
 	return files, nil
 }
 
-func (h *Hasher) hashFiles(dir string, files []string) (string, error) {
+// hashFiles hashes the path and content digest of each file, so that the digests can be cached.
+func (h *Hasher) hashFiles(files []string, digests [][]byte) string {
 	hash := sha256.New()
-
-	for _, relPath := range files {
-		absPath := filepath.Join(dir, relPath)
-
-		content, err := h.fileReader.ReadFile(absPath)
-		if err != nil {
-			return "", errors.Wrapf(err, "failed to read %s", relPath)
-		}
-
+	for i, relPath := range files {
 		hash.Write([]byte(relPath))
 		hash.Write([]byte{0})
-		hash.Write(content)
+		hash.Write(digests[i])
 	}
 
 	fullHash := fmt.Sprintf("%x", hash.Sum(nil))
 	if h.truncateLength > 0 && len(fullHash) > h.truncateLength {
-		return fullHash[:h.truncateLength], nil
+		return fullHash[:h.truncateLength]
 	}
-	return fullHash, nil
+	return fullHash
+}
+
+// fileDigests returns the content digest of each file, from the cache if one is configured.
+func (h *Hasher) fileDigests(dir string, files []string, matcher *mobyMatcher) ([][]byte, error) {
+	if h.cacheDir != "" {
+		return h.cachedDigests(dir, files, matcher)
+	}
+
+	digests := make([][]byte, 0, len(files))
+	for _, relPath := range files {
+		digest, err := h.fileDigest(filepath.Join(dir, relPath), relPath)
+		if err != nil {
+			return nil, err
+		}
+		digests = append(digests, digest)
+	}
+	return digests, nil
+}
+
+func (h *Hasher) fileDigest(absPath, relPath string) ([]byte, error) {
+	content, err := h.fileReader.ReadFile(absPath)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read %s", relPath)
+	}
+	sum := sha256.Sum256(content)
+	return sum[:], nil
 }
 
 // mobyMatcher wraps patternmatcher.PatternMatcher.
 type mobyMatcher struct {
 	pm          *patternmatcher.PatternMatcher
+	patterns    []string
 	hasNegation bool
 }
 
@@ -285,6 +436,36 @@ func (p *dockerignoreParser) Parse(r io.Reader) ([]string, error) {
 	return patterns, nil
 }
 
+// gitignoreParser parses .gitignore files into dockerignore-style patterns. Patterns without a
+// slash other than a trailing one match at any depth, and a trailing slash is dropped, so a
+// directory pattern also matches a file of that name.
+type gitignoreParser struct{}
+
+func (p *gitignoreParser) Parse(r io.Reader) ([]string, error) {
+	var patterns []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), " \t\r")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		negate := ""
+		if rest, ok := strings.CutPrefix(line, "!"); ok {
+			negate, line = "!", rest
+		}
+		line = strings.TrimSuffix(line, "/")
+		if !strings.Contains(line, "/") {
+			line = "**/" + line
+		}
+		patterns = append(patterns, negate+strings.TrimPrefix(line, "/"))
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return patterns, nil
+}
+
 // osFileReader reads files from the OS filesystem.
 type osFileReader struct{}
 
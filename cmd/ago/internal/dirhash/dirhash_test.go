@@ -731,3 +731,126 @@ func equalSlices(a, b []string) bool {
 func contains(slice []string, item string) bool {
 	return slices.Contains(slice, item)
 }
+
+func TestHash_ExcludePatterns(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+
+	writeFile(t, dir, ".dockerignore", "!cdk.out/keep.json")
+	writeFile(t, dir, "main.go", "package main")
+	writeFile(t, dir, "cdk.out/manifest.json", "{}")
+	writeFile(t, dir, "cdk.out/keep.json", "{}")
+	writeFile(t, dir, "nested/cdk.out/manifest.json", "{}")
+
+	h := dirhash.New(dirhash.WithExcludePatterns("**/cdk.out", "cdk.out/*"))
+
+	files, err := h.CollectedFiles(dir, ".dockerignore")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	slices.Sort(files)
+	want := []string{".dockerignore", "cdk.out/keep.json", "main.go"}
+	if !slices.Equal(files, want) {
+		t.Errorf("expected %v, got %v", want, files)
+	}
+}
+
+func TestHash_NoIgnoreFile(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+
+	writeFile(t, dir, ".gitignore", "main.go")
+	writeFile(t, dir, "main.go", "package main")
+	writeFile(t, dir, "cdk.out/manifest.json", "{}")
+
+	files, err := dirhash.New(dirhash.WithExcludePatterns("**/cdk.out")).CollectedFiles(dir, "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	slices.Sort(files)
+	want := []string{".gitignore", "main.go"}
+	if !slices.Equal(files, want) {
+		t.Errorf("expected %v, got %v", want, files)
+	}
+}
+
+func TestHash_GitIgnore(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+
+	writeFile(t, dir, ".gitignore", "*.log\n/build/\n")
+	writeFile(t, dir, "main.go", "package main")
+	writeFile(t, dir, "debug.log", "root log")
+	writeFile(t, dir, "build/out", "binary")
+	writeFile(t, dir, "web/.gitignore", "# generated\nnode_modules/\ndist\n!keep.log\n")
+	writeFile(t, dir, "web/index.ts", "export {}")
+	writeFile(t, dir, "web/keep.log", "kept")
+	writeFile(t, dir, "web/error.log", "ignored")
+	writeFile(t, dir, "web/node_modules/pkg/index.js", "module")
+	writeFile(t, dir, "web/src/dist/bundle.js", "bundle")
+	writeFile(t, dir, "web/build/out", "not anchored at web")
+	writeFile(t, dir, "api/dist/bundle.js", "nested patterns only apply below their directory")
+
+	files, err := dirhash.New(dirhash.WithIgnoreFiles(dirhash.GitIgnore())).CollectedFiles(dir, "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := []string{
+		".gitignore", "api/dist/bundle.js", "main.go",
+		"web/.gitignore", "web/build/out", "web/index.ts", "web/keep.log",
+	}
+	if !slices.Equal(files, want) {
+		t.Errorf("expected %v, got %v", want, files)
+	}
+}
+
+func TestHash_IgnoreFileChain(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+
+	writeFile(t, dir, ".gitignore", "*.env\nvendor/\n")
+	writeFile(t, dir, ".dockerignore", "docs\n!vendor/modules.txt\n")
+	writeFile(t, dir, "main.go", "package main")
+	writeFile(t, dir, "local.env", "SECRET=1")
+	writeFile(t, dir, "docs/README.md", "# docs")
+	writeFile(t, dir, "vendor/modules.txt", "# modules")
+	writeFile(t, dir, "vendor/pkg/pkg.go", "package pkg")
+	writeFile(t, dir, "tmp/scratch", "scratch")
+
+	h := dirhash.New(
+		dirhash.WithExcludePatterns("tmp"),
+		dirhash.WithIgnoreFiles(dirhash.GitIgnore()),
+	)
+	files, err := h.CollectedFiles(dir, ".dockerignore")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := []string{".dockerignore", ".gitignore", "main.go", "vendor/modules.txt"}
+	if !slices.Equal(files, want) {
+		t.Errorf("expected %v, got %v", want, files)
+	}
+}
+
+func TestHash_NestedIgnoreFileInExcludedDirectory(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+
+	writeFile(t, dir, ".gitignore", "third_party/\n")
+	writeFile(t, dir, "main.go", "package main")
+	writeFile(t, dir, "third_party/.gitignore", "!*\n")
+	writeFile(t, dir, "third_party/lib.go", "package lib")
+
+	files, err := dirhash.New(dirhash.WithIgnoreFiles(dirhash.GitIgnore())).CollectedFiles(dir, "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := []string{".gitignore", "main.go"}
+	if !slices.Equal(files, want) {
+		t.Errorf("expected %v, got %v", want, files)
+	}
+}
@@ -0,0 +1,10 @@
+//go:build !unix
+
+package dirhash
+
+import "io/fs"
+
+// inode returns 0, as there is no portable inode number on this platform.
+func inode(fs.FileInfo) uint64 {
+	return 0
+}
@@ -0,0 +1,17 @@
+//go:build unix
+
+package dirhash
+
+import (
+	"io/fs"
+	"syscall"
+)
+
+// inode returns the inode number of a file, which changes when a file is replaced by another one
+// of the same size and modification time.
+func inode(info fs.FileInfo) uint64 {
+	if st, ok := info.Sys().(*syscall.Stat_t); ok {
+		return st.Ino
+	}
+	return 0
+}
@@ -0,0 +1,77 @@
+package dirhash
+
+import (
+	"encoding/json"
+	"os"
+	"slices"
+
+	"github.com/cockroachdb/errors"
+)
+
+// Manifest lists the files that a hash covers with the hex SHA-256 digest of their content, so a
+// later hash can be explained by the files that changed.
+type Manifest struct {
+	Hash  string            `json:"hash"`
+	Files map[string]string `json:"files"`
+}
+
+// ManifestDiff holds the files that changed between two manifests, each sorted by path.
+type ManifestDiff struct {
+	Added    []string `json:"added"`
+	Removed  []string `json:"removed"`
+	Modified []string `json:"modified"`
+}
+
+// Empty reports whether no file changed.
+func (d ManifestDiff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Modified) == 0
+}
+
+// Diff returns the files that were added, removed or modified since the old manifest.
+func (m Manifest) Diff(old Manifest) ManifestDiff {
+	diff := ManifestDiff{Added: []string{}, Removed: []string{}, Modified: []string{}}
+	for path, digest := range m.Files {
+		oldDigest, ok := old.Files[path]
+		switch {
+		case !ok:
+			diff.Added = append(diff.Added, path)
+		case oldDigest != digest:
+			diff.Modified = append(diff.Modified, path)
+		}
+	}
+	for path := range old.Files {
+		if _, ok := m.Files[path]; !ok {
+			diff.Removed = append(diff.Removed, path)
+		}
+	}
+
+	slices.Sort(diff.Added)
+	slices.Sort(diff.Removed)
+	slices.Sort(diff.Modified)
+	return diff
+}
+
+// WriteManifest writes a manifest as JSON.
+func WriteManifest(path string, m Manifest) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "failed to encode manifest")
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0o644); err != nil {
+		return errors.Wrapf(err, "failed to write manifest %s", path)
+	}
+	return nil
+}
+
+// ReadManifest reads a manifest that WriteManifest wrote.
+func ReadManifest(path string) (Manifest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Manifest{}, errors.Wrapf(err, "failed to read manifest %s", path)
+	}
+	var m Manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return Manifest{}, errors.Wrapf(err, "failed to parse manifest %s", path)
+	}
+	return m, nil
+}
@@ -0,0 +1,64 @@
+package dirhash_test
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/advdv/ago/cmd/ago/internal/dirhash"
+)
+
+func TestManifest(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+
+	writeFile(t, dir, ".dockerignore", "*.md")
+	writeFile(t, dir, "main.go", "package main")
+	writeFile(t, dir, "util.go", "package main // util")
+	writeFile(t, dir, "old.go", "package main // old")
+	writeFile(t, dir, "README.md", "# ignored")
+
+	h := dirhash.New()
+	old, err := h.Manifest(dir, ".dockerignore")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if hash, _ := h.Hash(dir, ".dockerignore"); old.Hash != hash {
+		t.Errorf("manifest hash %s differs from hash %s", old.Hash, hash)
+	}
+	if _, ok := old.Files["README.md"]; ok || len(old.Files) != 4 {
+		t.Errorf("unexpected files: %v", old.Files)
+	}
+
+	path := filepath.Join(t.TempDir(), "manifest.json")
+	if err := dirhash.WriteManifest(path, old); err != nil {
+		t.Fatal(err)
+	}
+	read, err := dirhash.ReadManifest(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	writeFile(t, dir, "util.go", "package main // changed")
+	writeFile(t, dir, "new.go", "package main // new")
+	writeFile(t, dir, "README.md", "# still ignored")
+	if err := os.Remove(filepath.Join(dir, "old.go")); err != nil {
+		t.Fatal(err)
+	}
+
+	current, err := h.Manifest(dir, ".dockerignore")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	diff := current.Diff(read)
+	if !slices.Equal(diff.Added, []string{"new.go"}) ||
+		!slices.Equal(diff.Removed, []string{"old.go"}) ||
+		!slices.Equal(diff.Modified, []string{"util.go"}) {
+		t.Errorf("unexpected diff: %+v", diff)
+	}
+	if !current.Diff(current).Empty() {
+		t.Error("expected no changes against itself")
+	}
+}
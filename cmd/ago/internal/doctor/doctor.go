@@ -0,0 +1,163 @@
+// Package doctor runs health checks of a project. Every check implements Checker and is added to
+// a Registry, which runs the checks in the order they were registered. The CLI registers its
+// built-in checks first and then the checks that a project declares in .ago.yml.
+package doctor
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+)
+
+// Status is the outcome of a check.
+type Status int
+
+const (
+	// Pass means the check found nothing wrong.
+	Pass Status = iota
+	// Skip means the check did not apply, or could not run because something it needs is missing.
+	Skip
+	// Warn means the project works, but something should be looked at.
+	Warn
+	// Fail means something is broken.
+	Fail
+)
+
+func (s Status) String() string {
+	switch s {
+	case Pass:
+		return "PASS"
+	case Skip:
+		return "SKIP"
+	case Warn:
+		return "WARN"
+	case Fail:
+		return "FAIL"
+	default:
+		return fmt.Sprintf("Status(%d)", int(s))
+	}
+}
+
+// MarshalText encodes the status as its lower-case name.
+func (s Status) MarshalText() ([]byte, error) {
+	return []byte(strings.ToLower(s.String())), nil
+}
+
+// Result is what a check found. Detail tells the user what was checked or what to do about it.
+type Result struct {
+	Status Status
+	Detail string
+}
+
+// Passf returns a passing result.
+func Passf(format string, args ...any) Result {
+	return Result{Status: Pass, Detail: fmt.Sprintf(format, args...)}
+}
+
+// Skipf returns a result for a check that did not run.
+func Skipf(format string, args ...any) Result {
+	return Result{Status: Skip, Detail: fmt.Sprintf(format, args...)}
+}
+
+// Warnf returns a warning result.
+func Warnf(format string, args ...any) Result {
+	return Result{Status: Warn, Detail: fmt.Sprintf(format, args...)}
+}
+
+// Failf returns a failing result.
+func Failf(format string, args ...any) Result {
+	return Result{Status: Fail, Detail: fmt.Sprintf(format, args...)}
+}
+
+// Checker is a single health check. Name identifies it on the command line, so it must be
+// stable and unique within a registry.
+type Checker interface {
+	Name() string
+	Check(ctx context.Context) Result
+}
+
+// CheckerFunc returns a Checker that runs fn.
+func CheckerFunc(name string, fn func(ctx context.Context) Result) Checker {
+	return checkerFunc{name: name, fn: fn}
+}
+
+type checkerFunc struct {
+	name string
+	fn   func(ctx context.Context) Result
+}
+
+func (c checkerFunc) Name() string                     { return c.name }
+func (c checkerFunc) Check(ctx context.Context) Result { return c.fn(ctx) }
+
+// Registry holds the checks to run.
+type Registry struct {
+	checkers []Checker
+}
+
+// Register adds a check. It fails when a check with the same name is registered already.
+func (r *Registry) Register(c Checker) error {
+	if slices.Contains(r.Names(), c.Name()) {
+		return errors.Errorf("check %q is registered already", c.Name())
+	}
+	r.checkers = append(r.checkers, c)
+	return nil
+}
+
+// Names returns the names of the registered checks, in registration order.
+func (r *Registry) Names() []string {
+	names := make([]string, 0, len(r.checkers))
+	for _, c := range r.checkers {
+		names = append(names, c.Name())
+	}
+	return names
+}
+
+// Report is the result of a check that ran.
+type Report struct {
+	Name   string `json:"name"`
+	Status Status `json:"status"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// Run runs the registered checks, or only those named, and reports their results in
+// registration order. A check that panics is reported as failed.
+func (r *Registry) Run(ctx context.Context, only []string) ([]Report, error) {
+	for _, name := range only {
+		if !slices.Contains(r.Names(), name) {
+			return nil, errors.Errorf("unknown check %q, available checks: %s", name, strings.Join(r.Names(), ", "))
+		}
+	}
+
+	reports := make([]Report, 0, len(r.checkers))
+	for _, c := range r.checkers {
+		if len(only) > 0 && !slices.Contains(only, c.Name()) {
+			continue
+		}
+		result := runCheck(ctx, c)
+		reports = append(reports, Report{Name: c.Name(), Status: result.Status, Detail: result.Detail})
+	}
+	return reports, nil
+}
+
+func runCheck(ctx context.Context, c Checker) (result Result) {
+	defer func() {
+		if r := recover(); r != nil {
+			result = Failf("check panicked: %v", r)
+		}
+	}()
+	return c.Check(ctx)
+}
+
+// Count returns the number of reports with the status.
+func Count(reports []Report, status Status) int {
+	var n int
+	for _, r := range reports {
+		if r.Status == status {
+			n++
+		}
+	}
+	return n
+}
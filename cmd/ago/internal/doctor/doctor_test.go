@@ -0,0 +1,66 @@
+package doctor_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/advdv/ago/cmd/ago/internal/doctor"
+)
+
+func TestRegistry(t *testing.T) {
+	t.Parallel()
+
+	var reg doctor.Registry
+	for _, c := range []doctor.Checker{
+		doctor.CheckerFunc("tools", func(context.Context) doctor.Result { return doctor.Passf("all installed") }),
+		doctor.CheckerFunc("dns", func(context.Context) doctor.Result { return doctor.Failf("not delegated") }),
+		doctor.CheckerFunc("broken", func(context.Context) doctor.Result { panic("boom") }),
+	} {
+		if err := reg.Register(c); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := reg.Register(doctor.CheckerFunc("dns", nil)); err == nil {
+		t.Error("expected an error for a duplicate check")
+	}
+
+	reports, err := reg.Run(context.Background(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reports) != 3 || reports[0].Name != "tools" || reports[1].Status != doctor.Fail {
+		t.Errorf("unexpected reports %+v", reports)
+	}
+	if reports[2].Status != doctor.Fail || reports[2].Detail != "check panicked: boom" {
+		t.Errorf("expected the panic to be reported as a failure, got %+v", reports[2])
+	}
+	if got := doctor.Count(reports, doctor.Fail); got != 2 {
+		t.Errorf("Count(Fail) = %d, want 2", got)
+	}
+
+	reports, err = reg.Run(context.Background(), []string{"dns"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reports) != 1 || reports[0].Name != "dns" {
+		t.Errorf("expected only the dns check, got %+v", reports)
+	}
+
+	if _, err := reg.Run(context.Background(), []string{"nope"}); err == nil {
+		t.Error("expected an error for an unknown check")
+	}
+}
+
+func TestReportJSON(t *testing.T) {
+	t.Parallel()
+
+	data, err := json.Marshal(doctor.Report{Name: "dns", Status: doctor.Warn})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"name":"dns","status":"warn"}`; string(data) != want {
+		t.Errorf("got %s, want %s", data, want)
+	}
+}
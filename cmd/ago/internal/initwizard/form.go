@@ -1,6 +1,10 @@
 package initwizard
 
 import (
+	"net/mail"
+	"slices"
+	"strings"
+
 	"github.com/advdv/ago/agcdkutil"
 	"github.com/charmbracelet/huh"
 	"github.com/cockroachdb/errors"
@@ -10,26 +14,44 @@ type FormBuilder interface {
 	Build(defaultIdent string, result *Result) *huh.Form
 }
 
-type formBuilder struct{}
+// Services are the AWS services that a project can be initialized with, and those selected by
+// default. Without supported services the form does not ask for them.
+type Services struct {
+	Supported []string
+	Defaults  []string
+}
+
+type formBuilder struct {
+	services Services
+}
 
-func NewFormBuilder() FormBuilder {
-	return &formBuilder{}
+func NewFormBuilder(services Services) FormBuilder {
+	return &formBuilder{services: services}
 }
 
 func (b *formBuilder) Build(defaultIdent string, result *Result) *huh.Form {
 	*result = DefaultResult(defaultIdent)
-	return huh.NewForm(
-		huh.NewGroup(
-			b.managementProfileInput(&result.ManagementProfile),
-			b.projectIdentInput(&result.ProjectIdent),
-			b.primaryRegionSelect(&result.PrimaryRegion),
-			b.secondaryRegionsSelect(&result.PrimaryRegion, &result.SecondaryRegions),
-			b.initialDeployerInput(&result.InitialDeployer),
-			b.terraformCloudOrgInput(&result.TerraformCloudOrg),
-			b.baseDomainNameInput(&result.BaseDomainName),
-			b.depotProjectIDInput(&result.DepotProjectID),
-		),
+	result.Services = slices.Clone(b.services.Defaults)
+
+	project := huh.NewGroup(
+		b.managementProfileInput(&result.ManagementProfile),
+		b.projectIdentInput(&result.ProjectIdent),
+		b.qualifierInput(&result.Qualifier),
+		b.primaryRegionSelect(&result.PrimaryRegion),
+		b.secondaryRegionsSelect(&result.PrimaryRegion, &result.SecondaryRegions),
+		b.baseDomainNameInput(&result.BaseDomainName),
+		b.emailPatternInput(&result.EmailPattern),
+	)
+	deployments := huh.NewGroup(
+		b.deploymentsInput(&result.Deployments),
+		b.initialDeployerInput(&result.InitialDeployer),
+		b.terraformCloudOrgInput(&result.TerraformCloudOrg),
+		b.depotProjectIDInput(&result.DepotProjectID),
 	)
+	if len(b.services.Supported) == 0 {
+		return huh.NewForm(project, deployments)
+	}
+	return huh.NewForm(project, deployments, huh.NewGroup(b.servicesSelect(&result.Services)))
 }
 
 func (b *formBuilder) projectIdentInput(value *string) *huh.Input {
@@ -40,6 +62,14 @@ func (b *formBuilder) projectIdentInput(value *string) *huh.Input {
 		Validate(ValidateProjectIdent)
 }
 
+func (b *formBuilder) qualifierInput(value *string) *huh.Input {
+	return huh.NewInput().
+		Title("CDK qualifier").
+		Description("Qualifier of the CDK bootstrap resources and first part of every stack name").
+		Value(value).
+		Validate(ValidateQualifier)
+}
+
 func (b *formBuilder) primaryRegionSelect(value *string) *huh.Select[string] {
 	regions := agcdkutil.AllKnownRegions()
 	return huh.NewSelect[string]().
@@ -94,6 +124,46 @@ func (b *formBuilder) baseDomainNameInput(value *string) *huh.Input {
 		Validate(ValidateBaseDomainName)
 }
 
+func (b *formBuilder) emailPatternInput(value *string) *huh.Input {
+	return huh.NewInput().
+		Title("Account email pattern").
+		Description("Root email of the project account, {project} is replaced with the qualifier").
+		Value(value).
+		Validate(ValidateEmailPattern)
+}
+
+// deploymentsInput asks for the deployments as a comma-separated list. The form only binds
+// strings, so the list is updated whenever the text validates.
+func (b *formBuilder) deploymentsInput(value *[]string) *huh.Input {
+	text := strings.Join(*value, ", ")
+	return huh.NewInput().
+		Title("Deployments").
+		Description("Comma-separated deployment identifiers, such as Prod, Stag, Dev1").
+		Value(&text).
+		Validate(func(s string) error {
+			deployments, err := ParseDeployments(s)
+			if err != nil {
+				return err
+			}
+			*value = deployments
+			return nil
+		})
+}
+
+func (b *formBuilder) servicesSelect(value *[]string) *huh.MultiSelect[string] {
+	return huh.NewMultiSelect[string]().
+		Title("AWS services").
+		Description("Services that deployers may use, as allowed by the pre-bootstrap policies").
+		Options(huh.NewOptions(b.services.Supported...)...).
+		Value(value).
+		Validate(func(s []string) error {
+			if len(s) == 0 {
+				return errors.New("select at least one service")
+			}
+			return nil
+		})
+}
+
 func (b *formBuilder) depotProjectIDInput(value *string) *huh.Input {
 	return huh.NewInput().
 		Title("Depot project ID").
@@ -119,6 +189,61 @@ func ValidateProjectIdent(s string) error {
 	return nil
 }
 
+// ValidateQualifier checks a CDK qualifier, which CDK bootstrap limits to 10 characters.
+func ValidateQualifier(s string) error {
+	if s == "" {
+		return errors.New("qualifier is required")
+	}
+	if len(s) > 10 {
+		return errors.New("qualifier must be 10 characters or less")
+	}
+	for _, c := range s {
+		if !IsValidIdentChar(c) {
+			return errors.Newf("invalid character %q: use lowercase letters, numbers, and hyphens only", c)
+		}
+	}
+	return nil
+}
+
+// ParseDeployments parses a comma-separated list of deployment identifiers. Identifiers become
+// part of stack names, so they start with an uppercase letter followed by letters and digits.
+func ParseDeployments(s string) ([]string, error) {
+	var deployments []string
+	for part := range strings.SplitSeq(s, ",") {
+		d := strings.TrimSpace(part)
+		if d == "" {
+			continue
+		}
+		if d[0] < 'A' || d[0] > 'Z' {
+			return nil, errors.Newf("deployment %q must start with an uppercase letter", d)
+		}
+		for _, c := range d {
+			if (c < 'a' || c > 'z') && (c < 'A' || c > 'Z') && (c < '0' || c > '9') {
+				return nil, errors.Newf("invalid character %q in deployment %q: use letters and numbers only", c, d)
+			}
+		}
+		if slices.Contains(deployments, d) {
+			return nil, errors.Newf("deployment %q is listed twice", d)
+		}
+		deployments = append(deployments, d)
+	}
+	if len(deployments) == 0 {
+		return nil, errors.New("at least one deployment is required")
+	}
+	return deployments, nil
+}
+
+// ValidateEmailPattern checks that the account email pattern yields a valid address.
+func ValidateEmailPattern(s string) error {
+	if s == "" {
+		return errors.New("email pattern is required")
+	}
+	if _, err := mail.ParseAddress(strings.ReplaceAll(s, "{project}", "project")); err != nil {
+		return errors.Newf("invalid email pattern %q", s)
+	}
+	return nil
+}
+
 func IsValidIdentChar(c rune) bool {
 	return (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') || c == '-'
 }
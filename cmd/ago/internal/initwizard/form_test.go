@@ -11,7 +11,7 @@ func TestFormBuilder_Build(t *testing.T) {
 
 	t.Run("creates form with default values", func(t *testing.T) {
 		t.Parallel()
-		builder := initwizard.NewFormBuilder()
+		builder := initwizard.NewFormBuilder(initwizard.Services{})
 		var result initwizard.Result
 		form := builder.Build("myproject", &result)
 
@@ -29,9 +29,26 @@ func TestFormBuilder_Build(t *testing.T) {
 		}
 	})
 
+	t.Run("selects the default services", func(t *testing.T) {
+		t.Parallel()
+		builder := initwizard.NewFormBuilder(initwizard.Services{
+			Supported: []string{"dynamodb", "lambda", "s3"},
+			Defaults:  []string{"lambda"},
+		})
+		var result initwizard.Result
+		builder.Build("myproject", &result)
+
+		if len(result.Services) != 1 || result.Services[0] != "lambda" {
+			t.Errorf("expected default services ['lambda'], got %v", result.Services)
+		}
+		if result.Qualifier != "myproject" {
+			t.Errorf("expected default qualifier 'myproject', got %q", result.Qualifier)
+		}
+	})
+
 	t.Run("uses provided default ident", func(t *testing.T) {
 		t.Parallel()
-		builder := initwizard.NewFormBuilder()
+		builder := initwizard.NewFormBuilder(initwizard.Services{})
 		var result initwizard.Result
 		builder.Build("custom-project", &result)
 
@@ -2,24 +2,33 @@ package initwizard
 
 type Result struct {
 	ProjectIdent      string
+	Qualifier         string
 	PrimaryRegion     string
 	SecondaryRegions  []string
+	BaseDomainName    string
+	Deployments       []string
+	EmailPattern      string
+	Services          []string
 	ManagementProfile string
 	InitialDeployer   string
 	TerraformCloudOrg string
-	BaseDomainName    string
 	DepotProjectID    string
 }
 
+// DefaultResult returns the settings that a non-interactive init uses. Services is left empty,
+// which stands for the CLI's default services.
 func DefaultResult(defaultIdent string) Result {
 	return Result{
 		ProjectIdent:      defaultIdent,
+		Qualifier:         defaultIdent,
 		PrimaryRegion:     "eu-central-1",
 		SecondaryRegions:  []string{"eu-north-1"},
+		BaseDomainName:    defaultIdent + ".basewarp.app",
+		Deployments:       []string{"Prod", "Stag", "Dev1", "Dev2", "Dev3"},
+		EmailPattern:      "admin+{project}@crewlinker.com",
 		ManagementProfile: "crewlinker-management-account",
 		InitialDeployer:   "Adam",
 		TerraformCloudOrg: "basewarp",
-		BaseDomainName:    defaultIdent + ".basewarp.app",
 		DepotProjectID:    "m4k6zm1749",
 	}
 }
@@ -1,6 +1,7 @@
 package initwizard_test
 
 import (
+	"slices"
 	"testing"
 
 	"github.com/advdv/ago/cmd/ago/internal/initwizard"
@@ -56,3 +57,52 @@ func TestIsValidIdentChar(t *testing.T) {
 		}
 	}
 }
+
+func TestValidateQualifier(t *testing.T) {
+	t.Parallel()
+
+	for input, wantErr := range map[string]bool{
+		"myapp":       false,
+		"my-app":      false,
+		"":            true,
+		"abcdefghijk": true,
+		"MyApp":       true,
+	} {
+		if err := initwizard.ValidateQualifier(input); (err != nil) != wantErr {
+			t.Errorf("ValidateQualifier(%q) error = %v, wantErr %v", input, err, wantErr)
+		}
+	}
+}
+
+func TestParseDeployments(t *testing.T) {
+	t.Parallel()
+
+	got, err := initwizard.ParseDeployments(" Prod, Stag,,Dev1 ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"Prod", "Stag", "Dev1"}; !slices.Equal(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+
+	for _, input := range []string{"", " , ", "prod", "Dev-1", "Prod, Prod"} {
+		if _, err := initwizard.ParseDeployments(input); err == nil {
+			t.Errorf("ParseDeployments(%q): expected an error", input)
+		}
+	}
+}
+
+func TestValidateEmailPattern(t *testing.T) {
+	t.Parallel()
+
+	for input, wantErr := range map[string]bool{
+		"admin+{project}@example.com": false,
+		"admin@example.com":           false,
+		"":                            true,
+		"{project}":                   true,
+	} {
+		if err := initwizard.ValidateEmailPattern(input); (err != nil) != wantErr {
+			t.Errorf("ValidateEmailPattern(%q) error = %v, wantErr %v", input, err, wantErr)
+		}
+	}
+}
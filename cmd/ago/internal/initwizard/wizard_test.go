@@ -24,7 +24,7 @@ func TestWizard_Run(t *testing.T) {
 
 	t.Run("returns result from successful form run", func(t *testing.T) {
 		t.Parallel()
-		builder := initwizard.NewFormBuilder()
+		builder := initwizard.NewFormBuilder(initwizard.Services{})
 		runner := &mockRunner{
 			runFunc: func(_ *huh.Form) error {
 				return nil
@@ -46,7 +46,7 @@ func TestWizard_Run(t *testing.T) {
 
 	t.Run("propagates runner error", func(t *testing.T) {
 		t.Parallel()
-		builder := initwizard.NewFormBuilder()
+		builder := initwizard.NewFormBuilder(initwizard.Services{})
 		expectedErr := errors.New("user aborted")
 		runner := &mockRunner{
 			runFunc: func(_ *huh.Form) error {
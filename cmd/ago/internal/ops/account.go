@@ -0,0 +1,132 @@
+package ops
+
+import (
+	"context"
+	"strings"
+
+	"github.com/advdv/ago/agcdkutil"
+	"github.com/advdv/ago/cmd/ago/internal/awsapi"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/account"
+	accounttypes "github.com/aws/aws-sdk-go-v2/service/account/types"
+	"github.com/aws/aws-sdk-go-v2/service/organizations"
+	"github.com/cockroachdb/errors"
+)
+
+// Account is a project account that is managed from the organization's management account.
+type Account struct {
+	ProjectName       string
+	ManagementProfile string
+	Region            string
+}
+
+// StackName returns the management account stack that creates the account.
+func (a Account) StackName() string {
+	return "ago-account-" + a.ProjectName
+}
+
+// AdminProfileName returns the profile that assumes the organization access role in the account.
+func (a Account) AdminProfileName() string {
+	return a.ProjectName + "-admin"
+}
+
+// CreateAccount deploys the rendered account stack and returns the ID of the created account.
+// The clients must be those of the management profile.
+func CreateAccount(ctx context.Context, c *awsapi.Clients, account Account, templatePath string) (string, error) {
+	if err := DeployTemplate(ctx, c, account.StackName(), templatePath, nil); err != nil {
+		return "", err
+	}
+
+	return AccountStackID(ctx, c, account)
+}
+
+// AccountStackID returns the ID of the account created by the account stack.
+func AccountStackID(ctx context.Context, c *awsapi.Clients, account Account) (string, error) {
+	return StackOutput(ctx, c, account.StackName(), agcdkutil.AccountIDOutputKey)
+}
+
+// WriteAdminProfile writes the profile that reaches the account through the organization
+// access role of the management account.
+func WriteAdminProfile(account Account, accountID string) error {
+	roleArn := agcdkutil.ARN(agcdkutil.PartitionFor(account.Region), "iam", "", accountID,
+		"role/OrganizationAccountAccessRole")
+
+	return SetProfile(account.AdminProfileName(), []ProfileSetting{
+		{Key: "role_arn", Value: roleArn},
+		{Key: "source_profile", Value: account.ManagementProfile},
+		{Key: "region", Value: account.Region},
+		{Key: "cli_pager", Value: ""},
+	})
+}
+
+// CloseAccount closes the account. Closed accounts remain in a post-closure period before AWS
+// deletes them permanently; the account stack is deleted separately with DeleteAccountStack.
+func CloseAccount(ctx context.Context, c *awsapi.Clients, accountID string) error {
+	if _, err := c.Organizations.CloseAccount(ctx, &organizations.CloseAccountInput{
+		AccountId: aws.String(accountID),
+	}); err != nil {
+		return errors.Wrapf(err, "failed to close account %s", accountID)
+	}
+	return nil
+}
+
+// DeleteAccountStack deletes the account stack from the management account.
+func DeleteAccountStack(ctx context.Context, c *awsapi.Clients, account Account) error {
+	return DeleteStack(ctx, c, account.StackName())
+}
+
+// Alternate contact types of the Account Management API.
+const (
+	ContactBilling    = "BILLING"
+	ContactSecurity   = "SECURITY"
+	ContactOperations = "OPERATIONS"
+)
+
+// AlternateContact is a billing, security or operations contact of an account. The Account
+// Management API requires every field.
+type AlternateContact struct {
+	Type  string
+	Name  string
+	Title string
+	Email string
+	Phone string
+}
+
+// EnableAccountManagement enables trusted access for the Account Management API, which the
+// management account needs to change the details of member accounts. It is a no-op when already
+// enabled. The clients must be those of the management profile.
+func EnableAccountManagement(ctx context.Context, c *awsapi.Clients) error {
+	if _, err := c.Organizations.EnableAWSServiceAccess(ctx, &organizations.EnableAWSServiceAccessInput{
+		ServicePrincipal: aws.String("account.amazonaws.com"),
+	}); err != nil {
+		return errors.Wrap(err, "failed to enable trusted access for account management")
+	}
+	return nil
+}
+
+// PutAccountName renames a member account. The clients must be those of the management profile.
+func PutAccountName(ctx context.Context, c *awsapi.Clients, accountID, name string) error {
+	if _, err := c.Account.PutAccountName(ctx, &account.PutAccountNameInput{
+		AccountId:   aws.String(accountID),
+		AccountName: aws.String(name),
+	}); err != nil {
+		return errors.Wrapf(err, "failed to rename account %s", accountID)
+	}
+	return nil
+}
+
+// PutAlternateContact sets an alternate contact of a member account, replacing the contact of
+// the same type.
+func PutAlternateContact(ctx context.Context, c *awsapi.Clients, accountID string, contact AlternateContact) error {
+	if _, err := c.Account.PutAlternateContact(ctx, &account.PutAlternateContactInput{
+		AccountId:            aws.String(accountID),
+		AlternateContactType: accounttypes.AlternateContactType(contact.Type),
+		Name:                 aws.String(contact.Name),
+		Title:                aws.String(contact.Title),
+		EmailAddress:         aws.String(contact.Email),
+		PhoneNumber:          aws.String(contact.Phone),
+	}); err != nil {
+		return errors.Wrapf(err, "failed to set %s contact of account %s", strings.ToLower(contact.Type), accountID)
+	}
+	return nil
+}
@@ -0,0 +1,104 @@
+package ops
+
+import (
+	"context"
+	"strings"
+
+	"github.com/advdv/ago/agcdkutil"
+	"github.com/advdv/ago/cmd/ago/internal/awsapi"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+	"github.com/cockroachdb/errors"
+)
+
+// CFNParameter is a CloudFormation stack parameter. List is set for CommaDelimitedList parameters.
+// Default is the default the template declares, which a stack deployed before the parameter was
+// added effectively has.
+type CFNParameter struct {
+	Key     string
+	Value   string
+	List    bool
+	Default string
+}
+
+// PreBootstrapStackName returns the name of the stack that holds the policies and deployer users
+// that CDK bootstrap builds on.
+func PreBootstrapStackName(qualifier string) string {
+	return qualifier + "-pre-bootstrap"
+}
+
+// ToolkitStackName returns the name of the CDK bootstrap stack.
+func ToolkitStackName(qualifier string) string {
+	return qualifier + "Bootstrap"
+}
+
+// PreBootstrapParameters returns the parameters of the pre-bootstrap stack as derived from the
+// CDK context, in the order they are declared in the template. The CI deployer role only exists
+// when ciRepository is set, and deployer users and access keys only with DeployerAuthIAMUsers.
+func PreBootstrapParameters(
+	qualifier string, secondaryRegions, deployers, devDeployers []string, ciRepository, deployerAuth string,
+) []CFNParameter {
+	return []CFNParameter{
+		{Key: "Qualifier", Value: qualifier},
+		{Key: "SecondaryRegions", Value: strings.Join(secondaryRegions, ","), List: true},
+		{Key: "Deployers", Value: strings.Join(deployers, ","), List: true},
+		{Key: "DevDeployers", Value: strings.Join(devDeployers, ","), List: true},
+		{Key: "CIRepository", Value: ciRepository},
+		{Key: "DeployerAuth", Value: deployerAuth, Default: DeployerAuthIAMUsers},
+	}
+}
+
+// DeployPreBootstrapStack deploys the rendered pre-bootstrap template.
+func DeployPreBootstrapStack(
+	ctx context.Context, c *awsapi.Clients, stackName, templatePath string, params []CFNParameter,
+) error {
+	return DeployTemplate(ctx, c, stackName, templatePath, params, types.CapabilityCapabilityNamedIam)
+}
+
+// CDKBootstrap runs 'cdk bootstrap' with the execution policy and permissions boundary that the
+// pre-bootstrap stack created. The runner must run in the CDK app directory.
+func CDKBootstrap(
+	ctx context.Context, r Runner, profile, qualifier, executionPolicyArn, permissionsBoundaryName string,
+) error {
+	return r.Mise(ctx, "cdk", "bootstrap",
+		"--profile", profile,
+		"--qualifier", qualifier,
+		"--toolkit-stack-name", ToolkitStackName(qualifier),
+		"--cloudformation-execution-policies", executionPolicyArn,
+		"--custom-permissions-boundary", permissionsBoundaryName,
+	)
+}
+
+// DeployRoleName returns the name of the deploy role that CDK bootstrap creates in a region.
+func DeployRoleName(qualifier, accountID, region string) string {
+	return "cdk-" + qualifier + "-deploy-role-" + accountID + "-" + region
+}
+
+// AttachDeploymentScopePolicy attaches the pre-bootstrap deployment scope policy to the CDK deploy
+// role of every region. The deploy roles are created by CDK bootstrap, so the policy cannot be attached
+// from the pre-bootstrap template itself. Combined with the session tag agcdkutil sets per stack, it
+// prevents dev deployers from modifying stacks of other deployments.
+func AttachDeploymentScopePolicy(ctx context.Context, c *awsapi.Clients, qualifier string, regions []string) error {
+	policyArn, err := StackOutput(ctx, c, PreBootstrapStackName(qualifier), agcdkutil.DeploymentScopePolicyARNOutputKey)
+	if err != nil {
+		return err
+	}
+
+	accountID, err := AccountID(ctx, c)
+	if err != nil {
+		return err
+	}
+
+	for _, region := range regions {
+		roleName := DeployRoleName(qualifier, accountID, region)
+		if _, err := c.IAM.AttachRolePolicy(ctx, &iam.AttachRolePolicyInput{
+			RoleName:  aws.String(roleName),
+			PolicyArn: aws.String(policyArn),
+		}); err != nil {
+			return errors.Wrapf(err, "failed to attach deployment scope policy to %s", roleName)
+		}
+	}
+
+	return nil
+}
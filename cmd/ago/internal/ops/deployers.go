@@ -0,0 +1,532 @@
+package ops
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/advdv/ago/cmd/ago/internal/awsapi"
+	"github.com/advdv/ago/cmd/ago/internal/cmdexec"
+	"github.com/advdv/ago/cmd/ago/internal/config"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	"github.com/cockroachdb/errors"
+)
+
+// DeployerSync describes the deployer credentials that should be configured locally.
+type DeployerSync struct {
+	// Backend is where credentials are stored, one of the config.CredentialsBackend* values.
+	Backend string
+	// Region is written to the deployer profiles.
+	Region       string
+	Qualifier    string
+	Deployers    []string
+	DevDeployers []string
+	// Auth is how deployers authenticate, one of the DeployerAuth* values. Defaults to IAM users.
+	Auth string
+	// SSO configures the profiles when deployers authenticate through IAM Identity Center.
+	SSO SSOProfileConfig
+}
+
+// SSOProfileConfig holds what deployer profiles need to sign in through IAM Identity Center.
+type SSOProfileConfig struct {
+	StartURL  string
+	Region    string
+	AccountID string
+}
+
+// DeployerProfileName returns the AWS CLI profile of a deployer.
+func DeployerProfileName(qualifier, username string) string {
+	return qualifier + "-" + strings.ToLower(username)
+}
+
+// DeployersGroupName returns the IAM group of the deployers with full deployer permissions.
+func DeployersGroupName(qualifier string) string {
+	return qualifier + "-deployers"
+}
+
+// DevDeployersGroupName returns the group of the dev deployers, who may only deploy their own
+// deployment.
+func DevDeployersGroupName(qualifier string) string {
+	return qualifier + "-dev-deployers"
+}
+
+// CIDeployerRoleName returns the IAM role that CI assumes through GitHub OIDC to deploy.
+func CIDeployerRoleName(qualifier string) string {
+	return qualifier + "-ci-deployer"
+}
+
+// DeployerSecretName returns the Secrets Manager secret that holds the access key of a deployer.
+func DeployerSecretName(qualifier, username string) string {
+	return qualifier + "/deployers/" + username
+}
+
+// DevDeployerSecretName returns the Secrets Manager secret that holds the access key of a dev
+// deployer.
+func DevDeployerSecretName(qualifier, username string) string {
+	return qualifier + "/dev-deployers/" + username
+}
+
+// SyncDeployerCredentials configures a profile for every deployer from the access keys that the
+// pre-bootstrap stack stored in Secrets Manager, and removes profiles of deployers that are gone.
+// The secrets are read with the clients. With SSO the profiles sign in through IAM Identity Center
+// instead, with the permission set of the deployer, and no secrets are read. Problems with
+// individual profiles are reported to w and do not stop the sync. Storing keys in aws-vault needs
+// to pass them through the environment, hence the full executor.
+func SyncDeployerCredentials(
+	ctx context.Context, exec cmdexec.Executor, c *awsapi.Clients, w io.Writer, sync DeployerSync,
+) error {
+	existingProfiles, err := DeployerProfiles(sync.Qualifier)
+	if err != nil {
+		logf(w, "  Warning: could not list existing profiles: %v\n", err)
+		existingProfiles = nil
+	}
+
+	type deployerInfo struct {
+		username      string
+		secretPath    string
+		permissionSet string
+	}
+	expectedProfiles := make(map[string]deployerInfo)
+	for _, username := range sync.Deployers {
+		expectedProfiles[DeployerProfileName(sync.Qualifier, username)] = deployerInfo{
+			username:      username,
+			secretPath:    DeployerSecretName(sync.Qualifier, username),
+			permissionSet: DeployerPermissionSetName(sync.Qualifier),
+		}
+	}
+	for _, username := range sync.DevDeployers {
+		expectedProfiles[DeployerProfileName(sync.Qualifier, username)] = deployerInfo{
+			username:      username,
+			secretPath:    DevDeployerSecretName(sync.Qualifier, username),
+			permissionSet: DevDeployerPermissionSetName(sync.Qualifier, username),
+		}
+	}
+
+	for _, existingProfile := range existingProfiles {
+		if _, expected := expectedProfiles[existingProfile]; !expected {
+			logf(w, "  Removing profile %q...\n", existingProfile)
+			if err := RemoveProfile(existingProfile); err != nil {
+				logf(w, "    Warning: failed to remove profile: %v\n", err)
+			}
+			if sync.Backend == config.CredentialsBackendAWSVault {
+				if err := exec.Run(ctx, "aws-vault", "remove", existingProfile, "--force"); err != nil {
+					logf(w, "    Warning: failed to remove credentials from aws-vault: %v\n", err)
+				}
+			}
+		}
+	}
+
+	configData, credentialsData, err := readAWSFiles()
+	if err != nil {
+		logf(w, "  Warning: could not read existing profiles: %v\n", err)
+	}
+	profileNames := slices.Sorted(maps.Keys(expectedProfiles))
+
+	if sync.Auth == DeployerAuthSSO {
+		if err := writeSSOSession(sync.Qualifier, sync.SSO); err != nil {
+			return err
+		}
+		for _, profileName := range profileNames {
+			info := expectedProfiles[profileName]
+			settings := ssoDeployerProfileSettings(sync, info.permissionSet)
+			if ProfileUpToDate(configData, credentialsData, profileName, settings) {
+				logf(w, "  Profile %q for user %s is up to date\n", profileName, info.username)
+				continue
+			}
+			logf(w, "  Configuring SSO profile %q for user %s...\n", profileName, info.username)
+			if err := writeSSODeployerProfile(profileName, settings); err != nil {
+				logf(w, "    Warning: failed to write profile: %v\n", err)
+			}
+		}
+		logf(w, "  Sign in with: aws sso login --sso-session %s\n", SSOSessionName(sync.Qualifier))
+		return nil
+	}
+
+	secretNames := make([]string, 0, len(profileNames))
+	for _, profileName := range profileNames {
+		secretNames = append(secretNames, expectedProfiles[profileName].secretPath)
+	}
+	keys, failed, err := ReadDeployerAccessKeys(ctx, c, secretNames)
+	if err != nil {
+		logf(w, "  Warning: could not fetch deployer credentials: %v\n", err)
+		return nil
+	}
+
+	for _, profileName := range profileNames {
+		info := expectedProfiles[profileName]
+		credentials, ok := keys[info.secretPath]
+		if !ok {
+			logf(w, "  Warning: could not fetch credentials for %s: %v\n", info.username, failed[info.secretPath])
+			continue
+		}
+
+		// aws-vault keeps the keys in the keychain, where they cannot be compared without
+		// unlocking it, so vault profiles are always rewritten.
+		settings := []ProfileSetting{
+			{Key: "aws_access_key_id", Value: credentials.AccessKeyID},
+			{Key: "aws_secret_access_key", Value: credentials.SecretAccessKey},
+			{Key: "region", Value: sync.Region},
+			{Key: "cli_pager", Value: ""},
+		}
+		if sync.Backend != config.CredentialsBackendAWSVault &&
+			ProfileUpToDate(configData, credentialsData, profileName, settings) {
+			logf(w, "  Profile %q for user %s is up to date\n", profileName, info.username)
+			continue
+		}
+
+		logf(w, "  Configuring profile %q for user %s...\n", profileName, info.username)
+		if sync.Backend == config.CredentialsBackendAWSVault {
+			err = writeVaultDeployerProfile(ctx, exec, profileName, sync.Region,
+				credentials.AccessKeyID, credentials.SecretAccessKey)
+		} else {
+			err = SetProfile(profileName, settings)
+		}
+		if err != nil {
+			logf(w, "    Warning: failed to write profile: %v\n", err)
+		}
+	}
+
+	return nil
+}
+
+// readAWSFiles returns the contents of the shared AWS config and credentials files. A file that
+// does not exist is empty.
+func readAWSFiles() (configData, credentialsData string, err error) {
+	configPath, credentialsPath, err := awsFilePaths()
+	if err != nil {
+		return "", "", err
+	}
+
+	configBytes, err := os.ReadFile(configPath)
+	if err != nil && !os.IsNotExist(err) {
+		return "", "", errors.Wrap(err, "failed to read config file")
+	}
+	credentialsBytes, err := os.ReadFile(credentialsPath)
+	if err != nil && !os.IsNotExist(err) {
+		return "", "", errors.Wrap(err, "failed to read credentials file")
+	}
+	return string(configBytes), string(credentialsBytes), nil
+}
+
+// deployerCredentialKeys are the profile settings that give a deployer profile its credentials,
+// one kind per backend.
+var deployerCredentialKeys = []string{
+	"aws_access_key_id", "aws_secret_access_key", "credential_process", "sso_session",
+}
+
+// ProfileUpToDate reports whether a profile in the AWS config and credentials files has the
+// settings already, and no credentials of another kind that writing the settings would replace.
+func ProfileUpToDate(configData, credentialsData, profileName string, settings []ProfileSetting) bool {
+	current := sectionSettings(credentialsData, profileName)
+	maps.Copy(current, sectionSettings(configData, "profile "+profileName))
+
+	for _, setting := range settings {
+		if value, ok := current[setting.Key]; !ok || value != setting.Value {
+			return false
+		}
+	}
+	for _, key := range deployerCredentialKeys {
+		_, has := current[key]
+		if has && !slices.ContainsFunc(settings, func(s ProfileSetting) bool { return s.Key == key }) {
+			return false
+		}
+	}
+	return true
+}
+
+// sectionSettings returns the settings in a section of an AWS config or credentials file.
+func sectionSettings(data, sectionName string) map[string]string {
+	settings := map[string]string{}
+	header := "[" + sectionName + "]"
+	inSection := false
+	for line := range strings.SplitSeq(data, "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "[") {
+			inSection = line == header
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if inSection && ok {
+			settings[strings.TrimSpace(key)] = strings.TrimSpace(value)
+		}
+	}
+	return settings
+}
+
+// DeployerProfiles returns the deployer profiles of the project: those with plaintext credentials
+// in ~/.aws/credentials, and those backed by aws-vault or IAM Identity Center in ~/.aws/config.
+func DeployerProfiles(qualifier string) ([]string, error) {
+	configData, credentialsData, err := readAWSFiles()
+	if err != nil {
+		return nil, err
+	}
+
+	prefix := "[" + qualifier + "-"
+	var profiles []string
+	for line := range strings.SplitSeq(credentialsData, "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, prefix) && strings.HasSuffix(line, "]") {
+			profileName := line[1 : len(line)-1]
+			profiles = append(profiles, profileName)
+		}
+	}
+
+	configProfiles := append(VaultProfiles(configData, qualifier), SSOProfiles(configData, qualifier)...)
+	for _, profileName := range configProfiles {
+		if !slices.Contains(profiles, profileName) {
+			profiles = append(profiles, profileName)
+		}
+	}
+
+	return profiles, nil
+}
+
+// VaultProfiles returns the project's profiles in an AWS config file whose credentials
+// are provided by aws-vault.
+func VaultProfiles(configData, qualifier string) []string {
+	return matchingProfiles(configData, qualifier, func(key, value string) bool {
+		return key == "credential_process" && strings.HasPrefix(value, "aws-vault ")
+	})
+}
+
+// SSOProfiles returns the project's profiles in an AWS config file that sign in through the
+// project's sso-session.
+func SSOProfiles(configData, qualifier string) []string {
+	return matchingProfiles(configData, qualifier, func(key, value string) bool {
+		return key == "sso_session" && value == SSOSessionName(qualifier)
+	})
+}
+
+// matchingProfiles returns the project's profiles in an AWS config file that have a setting
+// for which match returns true.
+func matchingProfiles(configData, qualifier string, match func(key, value string) bool) []string {
+	prefix := "[profile " + qualifier + "-"
+	var (
+		profiles []string
+		current  string
+	)
+	for line := range strings.SplitSeq(configData, "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "[") {
+			current = ""
+			if strings.HasPrefix(line, prefix) && strings.HasSuffix(line, "]") {
+				current = strings.TrimPrefix(line[1:len(line)-1], "profile ")
+			}
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, "=")
+		if current == "" || !ok {
+			continue
+		}
+		if match(strings.TrimSpace(key), strings.TrimSpace(value)) {
+			profiles = append(profiles, current)
+		}
+	}
+
+	return profiles
+}
+
+// RemoveProfile removes a profile from the shared credentials and config files.
+func RemoveProfile(profileName string) error {
+	configPath, credentialsPath, err := awsFilePaths()
+	if err != nil {
+		return err
+	}
+
+	if err := removeProfileFromFile(credentialsPath, profileName); err != nil {
+		return err
+	}
+
+	if err := removeProfileFromFile(configPath, configSectionName(profileName)); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func removeProfileFromFile(filePath, sectionName string) error {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return errors.Wrapf(err, "failed to read %s", filePath)
+	}
+
+	lines := strings.Split(string(data), "\n")
+	var result []string
+	inSection := false
+	sectionHeader := "[" + sectionName + "]"
+
+	for _, line := range lines {
+		trimmed := strings.TrimSpace(line)
+
+		if trimmed == sectionHeader {
+			inSection = true
+			continue
+		}
+
+		if inSection && strings.HasPrefix(trimmed, "[") {
+			inSection = false
+		}
+
+		if !inSection {
+			result = append(result, line)
+		}
+	}
+
+	for len(result) > 0 && strings.TrimSpace(result[len(result)-1]) == "" {
+		result = result[:len(result)-1]
+	}
+
+	output := strings.Join(result, "\n")
+	if output != "" {
+		output += "\n"
+	}
+
+	if cmdexec.DryRun() {
+		cmdexec.ReportDryRun("would remove [%s] from %s", sectionName, filePath)
+		return nil
+	}
+
+	if err := os.WriteFile(filePath, []byte(output), 0o600); err != nil {
+		return errors.Wrapf(err, "failed to write %s", filePath)
+	}
+
+	return nil
+}
+
+// writeVaultDeployerProfile stores the deployer's credentials in aws-vault and configures
+// the profile to obtain them through credential_process, so that no plaintext secret is
+// written to ~/.aws/credentials.
+func writeVaultDeployerProfile(
+	ctx context.Context, exec cmdexec.Executor,
+	profileName, region, accessKeyID, secretAccessKey string,
+) error {
+	_, credentialsPath, err := awsFilePaths()
+	if err != nil {
+		return err
+	}
+
+	if err := removeProfileFromFile(credentialsPath, profileName); err != nil {
+		return err
+	}
+
+	vaultExec := exec.
+		WithEnv("AWS_ACCESS_KEY_ID", accessKeyID).
+		WithEnv("AWS_SECRET_ACCESS_KEY", secretAccessKey)
+	if err := vaultExec.Run(ctx, "aws-vault", "add", profileName, "--env", "--no-add-config"); err != nil {
+		return errors.Wrapf(err, "failed to add credentials for profile %s to aws-vault", profileName)
+	}
+
+	return SetProfile(profileName, []ProfileSetting{
+		{Key: "credential_process", Value: "aws-vault export --format=json " + profileName},
+		{Key: "region", Value: region},
+		{Key: "cli_pager", Value: ""},
+	})
+}
+
+// writeSSOSession writes the project's sso-session section to the shared config file. The section
+// is removed first, so settings of an earlier session do not linger.
+func writeSSOSession(qualifier string, sso SSOProfileConfig) error {
+	configPath, _, err := awsFilePaths()
+	if err != nil {
+		return err
+	}
+
+	sectionName := "sso-session " + SSOSessionName(qualifier)
+	if err := removeProfileFromFile(configPath, sectionName); err != nil {
+		return err
+	}
+
+	return setSectionInFile(configPath, sectionName, []ProfileSetting{
+		{Key: "sso_start_url", Value: sso.StartURL},
+		{Key: "sso_region", Value: sso.Region},
+		{Key: "sso_registration_scopes", Value: "sso:account:access"},
+	})
+}
+
+// ssoDeployerProfileSettings returns the settings of a deployer profile that signs in through the
+// project's sso-session with the deployer's permission set.
+func ssoDeployerProfileSettings(sync DeployerSync, permissionSet string) []ProfileSetting {
+	return []ProfileSetting{
+		{Key: "sso_session", Value: SSOSessionName(sync.Qualifier)},
+		{Key: "sso_account_id", Value: sync.SSO.AccountID},
+		{Key: "sso_role_name", Value: permissionSet},
+		{Key: "region", Value: sync.Region},
+		{Key: "cli_pager", Value: ""},
+	}
+}
+
+// writeSSODeployerProfile configures a deployer profile that signs in through IAM Identity
+// Center. Static keys or an aws-vault credential process that an earlier sync configured for the
+// profile are removed first.
+func writeSSODeployerProfile(profileName string, settings []ProfileSetting) error {
+	if err := RemoveProfile(profileName); err != nil {
+		return err
+	}
+
+	return SetProfile(profileName, settings)
+}
+
+// DeployerAccessKey is the access key of a deployer's IAM user, as the pre-bootstrap stack stores
+// it in Secrets Manager.
+type DeployerAccessKey struct {
+	AccessKeyID     string `json:"aws_access_key_id"`
+	SecretAccessKey string `json:"aws_secret_access_key"`
+}
+
+// ReadDeployerAccessKey reads the access key of a deployer from its secret, see
+// DeployerSecretName and DevDeployerSecretName.
+func ReadDeployerAccessKey(ctx context.Context, c *awsapi.Clients, secretName string) (DeployerAccessKey, error) {
+	credentialsJSON, err := secretValue(ctx, c, secretName)
+	if err != nil {
+		return DeployerAccessKey{}, err
+	}
+
+	var key DeployerAccessKey
+	if err := json.Unmarshal([]byte(credentialsJSON), &key); err != nil {
+		return DeployerAccessKey{}, errors.Wrapf(err, "failed to parse secret %s", secretName)
+	}
+	return key, nil
+}
+
+// ReadDeployerAccessKeys reads the access keys of several deployers with as few requests as
+// possible, see SecretStrings. Secrets that cannot be read or parsed are left out of the keys,
+// and why is in the returned errors by secret name.
+func ReadDeployerAccessKeys(
+	ctx context.Context, c *awsapi.Clients, secretNames []string,
+) (map[string]DeployerAccessKey, map[string]error, error) {
+	values, failed, err := SecretStrings(ctx, c, secretNames)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	keys := make(map[string]DeployerAccessKey, len(values))
+	for secretName, value := range values {
+		var key DeployerAccessKey
+		if err := json.Unmarshal([]byte(value), &key); err != nil {
+			failed[secretName] = errors.Wrapf(err, "failed to parse secret %s", secretName)
+			continue
+		}
+		keys[secretName] = key
+	}
+	return keys, failed, nil
+}
+
+func secretValue(ctx context.Context, c *awsapi.Clients, secretName string) (string, error) {
+	out, err := c.SecretsManager.GetSecretValue(ctx, &secretsmanager.GetSecretValueInput{
+		SecretId: aws.String(secretName),
+	})
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to get secret %s", secretName)
+	}
+	registerSecretValue(aws.ToString(out.SecretString))
+	return aws.ToString(out.SecretString), nil
+}
@@ -0,0 +1,110 @@
+package ops_test
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/advdv/ago/cmd/ago/internal/ops"
+)
+
+func TestVaultProfiles(t *testing.T) {
+	t.Parallel()
+
+	configData := `[profile myapp-admin]
+role_arn = arn:aws:iam::123456789012:role/OrganizationAccountAccessRole
+source_profile = management
+
+[profile myapp-adam]
+credential_process = aws-vault export --format=json myapp-adam
+region = eu-central-1
+
+[profile myapp-bob]
+region = eu-central-1
+
+[profile other-carol]
+credential_process = aws-vault export --format=json other-carol
+`
+
+	got := ops.VaultProfiles(configData, "myapp")
+	if !slices.Equal(got, []string{"myapp-adam"}) {
+		t.Errorf("expected [myapp-adam], got %v", got)
+	}
+}
+
+func TestDeployerProfileName(t *testing.T) {
+	t.Parallel()
+
+	if got := ops.DeployerProfileName("myapp", "Adam"); got != "myapp-adam" {
+		t.Errorf("expected myapp-adam, got %q", got)
+	}
+}
+
+func TestSSOProfiles(t *testing.T) {
+	t.Parallel()
+
+	configData := `[sso-session myapp]
+sso_start_url = https://example.awsapps.com/start
+
+[profile myapp-adam]
+sso_session = myapp
+sso_role_name = myapp-deployer
+
+[profile myapp-bob]
+credential_process = aws-vault export --format=json myapp-bob
+
+[profile other-carol]
+sso_session = other
+`
+
+	got := ops.SSOProfiles(configData, "myapp")
+	if !slices.Equal(got, []string{"myapp-adam"}) {
+		t.Errorf("expected [myapp-adam], got %v", got)
+	}
+}
+
+func TestProfileUpToDate(t *testing.T) {
+	t.Parallel()
+
+	credentialsData := `[myapp-adam]
+aws_access_key_id = AKIAOLD
+aws_secret_access_key = secret
+`
+	configData := `[profile myapp-adam]
+region = eu-central-1
+cli_pager =
+
+[profile myapp-bob]
+sso_session = myapp
+region = eu-central-1
+cli_pager =
+`
+	keys := func(id string) []ops.ProfileSetting {
+		return []ops.ProfileSetting{
+			{Key: "aws_access_key_id", Value: id},
+			{Key: "aws_secret_access_key", Value: "secret"},
+			{Key: "region", Value: "eu-central-1"},
+			{Key: "cli_pager", Value: ""},
+		}
+	}
+
+	if !ops.ProfileUpToDate(configData, credentialsData, "myapp-adam", keys("AKIAOLD")) {
+		t.Error("expected the profile with the same keys to be up to date")
+	}
+	if ops.ProfileUpToDate(configData, credentialsData, "myapp-adam", keys("AKIANEW")) {
+		t.Error("expected the profile with a rotated key not to be up to date")
+	}
+	if ops.ProfileUpToDate(configData, credentialsData, "myapp-carol", keys("AKIAOLD")) {
+		t.Error("expected a missing profile not to be up to date")
+	}
+
+	sso := []ops.ProfileSetting{
+		{Key: "sso_session", Value: "myapp"},
+		{Key: "region", Value: "eu-central-1"},
+	}
+	if !ops.ProfileUpToDate(configData, credentialsData, "myapp-bob", sso) {
+		t.Error("expected the SSO profile to be up to date")
+	}
+	if ops.ProfileUpToDate(configData, credentialsData, "myapp-adam", append(sso, keys("AKIAOLD")[2:]...)) {
+		t.Error("expected a profile with static keys not to be up to date as an SSO profile")
+	}
+}
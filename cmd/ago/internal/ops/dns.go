@@ -0,0 +1,173 @@
+package ops
+
+import (
+	"context"
+	"io"
+	"net"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/advdv/ago/cmd/ago/internal/awsapi"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/route53"
+	"github.com/cockroachdb/errors"
+)
+
+// PublicDNSServer is the resolver used to verify that a delegation is visible on the internet.
+const PublicDNSServer = "8.8.8.8:53"
+
+const (
+	dnsPollingInterval = 10 * time.Second
+	dnsQueryTimeout    = 5 * time.Second
+	dnsLookupRetries   = 3
+	dnsRetryDelay      = 100 * time.Millisecond
+)
+
+// DelegationStackName returns the stack in the parent zone account that holds the NS records
+// delegating the project's domain.
+func DelegationStackName(qualifier string) string {
+	return "ago-dns-delegate-" + qualifier
+}
+
+// ParentDomain returns the domain of the zone that the base domain is delegated from.
+func ParentDomain(baseDomainName string) (string, error) {
+	parts := strings.Split(baseDomainName, ".")
+	if len(parts) < 3 {
+		return "", errors.Errorf(
+			"base domain %q has no parent domain (need at least 3 labels like 'sub.example.com')",
+			baseDomainName)
+	}
+	return strings.Join(parts[1:], "."), nil
+}
+
+// LookupParentZoneID returns the ID of the public hosted zone of the base domain's parent domain.
+// The clients must be those of the parent zone account.
+func LookupParentZoneID(ctx context.Context, c *awsapi.Clients, baseDomainName string) (string, error) {
+	parentDomain, err := ParentDomain(baseDomainName)
+	if err != nil {
+		return "", err
+	}
+
+	dnsName := parentDomain + "."
+
+	out, err := c.Route53.ListHostedZonesByName(ctx, &route53.ListHostedZonesByNameInput{
+		DNSName:  aws.String(parentDomain),
+		MaxItems: aws.Int32(1),
+	})
+	if err != nil {
+		return "", errors.Wrap(err, "failed to list hosted zones in parent zone account")
+	}
+
+	for _, zone := range out.HostedZones {
+		if aws.ToString(zone.Name) == dnsName && (zone.Config == nil || !zone.Config.PrivateZone) {
+			zoneID := strings.TrimPrefix(aws.ToString(zone.Id), "/hostedzone/")
+			return zoneID, nil
+		}
+	}
+
+	return "", errors.Errorf("no public hosted zone found for %q in parent zone account", parentDomain)
+}
+
+// DeployDelegation deploys the rendered NS delegation template into the parent zone account.
+// The clients must be those of the parent zone account.
+func DeployDelegation(ctx context.Context, c *awsapi.Clients, qualifier, templatePath string) error {
+	if err := DeployTemplate(ctx, c, DelegationStackName(qualifier), templatePath, nil); err != nil {
+		return errors.Wrap(err, "failed to deploy NS delegation stack")
+	}
+	return nil
+}
+
+// LookupNS returns the name servers of the domain as seen by the public resolver.
+func LookupNS(ctx context.Context, domain string) ([]string, error) {
+	resolver := &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			d := net.Dialer{Timeout: dnsQueryTimeout}
+			return d.DialContext(ctx, "udp", PublicDNSServer)
+		},
+	}
+
+	var lastErr error
+	for range dnsLookupRetries {
+		records, err := resolver.LookupNS(ctx, domain)
+		if err == nil {
+			hosts := make([]string, 0, len(records))
+			for _, r := range records {
+				hosts = append(hosts, r.Host)
+			}
+			return hosts, nil
+		}
+		lastErr = err
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(dnsRetryDelay):
+		}
+	}
+	return nil, lastErr
+}
+
+// NameServersMatch reports whether every expected name server is among the found ones. Names
+// are compared case-insensitively and with or without the trailing dot.
+func NameServersMatch(found, expected []string) bool {
+	if len(found) == 0 {
+		return false
+	}
+
+	normalize := func(ns string) string {
+		return strings.TrimSuffix(strings.ToLower(ns), ".") + "."
+	}
+	normalized := make([]string, 0, len(found))
+	for _, ns := range found {
+		normalized = append(normalized, normalize(ns))
+	}
+
+	for _, ns := range expected {
+		if !slices.Contains(normalized, normalize(ns)) {
+			return false
+		}
+	}
+	return true
+}
+
+// CheckDelegation reports whether the public resolver returns the expected name servers.
+func CheckDelegation(ctx context.Context, baseDomainName string, expectedNS []string) (bool, error) {
+	found, err := LookupNS(ctx, baseDomainName)
+	if err != nil {
+		return false, err
+	}
+	return NameServersMatch(found, expectedNS), nil
+}
+
+// WaitForDelegation polls the public resolver until it returns the expected name servers. A dot
+// is written to w for every failed lookup and an 'o' for every mismatch.
+func WaitForDelegation(
+	ctx context.Context, w io.Writer, baseDomainName string, expectedNS []string, timeout time.Duration,
+) error {
+	deadline := time.Now().Add(timeout)
+
+	for {
+		if time.Now().After(deadline) {
+			return errors.Errorf("DNS propagation timeout after %v", timeout)
+		}
+
+		found, err := LookupNS(ctx, baseDomainName)
+		if err == nil && NameServersMatch(found, expectedNS) {
+			logf(w, "\nDNS records verified via %s\n", PublicDNSServer)
+			return nil
+		}
+
+		if err != nil {
+			logf(w, ".")
+		} else {
+			logf(w, "o")
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(dnsPollingInterval):
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package ops_test
+
+import (
+	"testing"
+
+	"github.com/advdv/ago/cmd/ago/internal/ops"
+)
+
+func TestParentDomain(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		domain  string
+		want    string
+		wantErr bool
+	}{
+		{domain: "myapp.example.com", want: "example.com"},
+		{domain: "a.b.example.com", want: "b.example.com"},
+		{domain: "example.com", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.domain, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := ops.ParentDomain(tt.domain)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestNameServersMatch(t *testing.T) {
+	t.Parallel()
+
+	expected := []string{"ns-1.awsdns-01.org", "NS-2.awsdns-02.com."}
+
+	tests := []struct {
+		name  string
+		found []string
+		want  bool
+	}{
+		{name: "all found", found: []string{"ns-2.awsdns-02.com.", "ns-1.awsdns-01.org.", "ns-3.awsdns-03.net."}, want: true},
+		{name: "one missing", found: []string{"ns-1.awsdns-01.org."}},
+		{name: "none found"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := ops.NameServersMatch(tt.found, expected); got != tt.want {
+				t.Errorf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,453 @@
+// Package ops implements the AWS-facing operations behind the ago CLI: bootstrapping, the
+// account lifecycle, deployer credential sync and DNS delegation. The CLI commands only gather
+// their inputs and report progress, so other automation can drive the same operations without
+// executing the CLI. AWS APIs are called through awsapi and profiles are written to the shared
+// config files directly; only the cdk CLI and aws-vault still go through a Runner.
+package ops
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/advdv/ago/cmd/ago/internal/awsapi"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/cockroachdb/errors"
+)
+
+// Runner runs tools through mise. It is implemented by cmdexec.Executor.
+type Runner interface {
+	Mise(ctx context.Context, name string, args ...string) error
+	MiseOutput(ctx context.Context, name string, args ...string) (string, error)
+}
+
+// stackWaitTimeout bounds how long a stack operation may take before giving up on it.
+const stackWaitTimeout = 30 * time.Minute
+
+// stackPollDelay is how often a stack operation is polled for completion.
+const stackPollDelay = 5 * time.Second
+
+// VerifyAccess checks that the clients have working credentials.
+func VerifyAccess(ctx context.Context, c *awsapi.Clients) error {
+	if _, err := c.STS.GetCallerIdentity(ctx, &sts.GetCallerIdentityInput{}); err != nil {
+		return errors.Wrap(err, "failed to verify AWS access")
+	}
+	return nil
+}
+
+// AccountID returns the account of the caller identity.
+func AccountID(ctx context.Context, c *awsapi.Clients) (string, error) {
+	out, err := c.STS.GetCallerIdentity(ctx, &sts.GetCallerIdentityInput{})
+	if err != nil {
+		return "", errors.Wrap(err, "failed to get AWS account ID")
+	}
+
+	return aws.ToString(out.Account), nil
+}
+
+// CallerARN returns the ARN of the identity that the clients call AWS as.
+func CallerARN(ctx context.Context, c *awsapi.Clients) (string, error) {
+	out, err := c.STS.GetCallerIdentity(ctx, &sts.GetCallerIdentityInput{})
+	if err != nil {
+		return "", errors.Wrap(err, "failed to get caller identity")
+	}
+
+	return aws.ToString(out.Arn), nil
+}
+
+// UserGroups returns the names of the IAM groups that a user is a member of.
+func UserGroups(ctx context.Context, c *awsapi.Clients, username string) ([]string, error) {
+	var groups []string
+	pages := iam.NewListGroupsForUserPaginator(c.IAM, &iam.ListGroupsForUserInput{
+		UserName: aws.String(username),
+	})
+	for pages.HasMorePages() {
+		page, err := pages.NextPage(ctx)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to list groups for user %q", username)
+		}
+		for _, group := range page.Groups {
+			groups = append(groups, aws.ToString(group.GroupName))
+		}
+	}
+	return groups, nil
+}
+
+// StackExists reports whether the CloudFormation stack exists.
+func StackExists(ctx context.Context, c *awsapi.Clients, stackName string) (bool, error) {
+	_, err := c.CloudFormation.DescribeStacks(ctx, &cloudformation.DescribeStacksInput{
+		StackName: aws.String(stackName),
+	})
+	if awsapi.IsStackNotFound(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, errors.Wrapf(err, "failed to check if stack %q exists", stackName)
+	}
+	return true, nil
+}
+
+// StackOutputs returns the outputs of a CloudFormation stack by output key, or nil when the stack
+// does not exist.
+func StackOutputs(ctx context.Context, c *awsapi.Clients, stackName string) (map[string]string, error) {
+	out, err := c.CloudFormation.DescribeStacks(ctx, &cloudformation.DescribeStacksInput{
+		StackName: aws.String(stackName),
+	})
+	if awsapi.IsStackNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to describe stack %q", stackName)
+	}
+
+	outputs := map[string]string{}
+	for _, stack := range out.Stacks {
+		for _, o := range stack.Outputs {
+			outputs[aws.ToString(o.OutputKey)] = aws.ToString(o.OutputValue)
+		}
+	}
+	return outputs, nil
+}
+
+// StackOutput returns an output value of a CloudFormation stack. In a dry run, an output of a
+// stack that was not deployed yet is a placeholder, so that the steps that use it can still be
+// reported.
+func StackOutput(ctx context.Context, c *awsapi.Clients, stackName, outputKey string) (string, error) {
+	out, err := c.CloudFormation.DescribeStacks(ctx, &cloudformation.DescribeStacksInput{
+		StackName: aws.String(stackName),
+	})
+	if c.DryRun && (awsapi.IsStackNotFound(err) || (err == nil && len(out.Stacks) == 0)) {
+		return dryRunPlaceholder(stackName, outputKey), nil
+	}
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to describe stack %q", stackName)
+	}
+
+	for _, stack := range out.Stacks {
+		for _, o := range stack.Outputs {
+			if aws.ToString(o.OutputKey) == outputKey {
+				return aws.ToString(o.OutputValue), nil
+			}
+		}
+	}
+
+	if c.DryRun {
+		return dryRunPlaceholder(stackName, outputKey), nil
+	}
+
+	return "", errors.Errorf("output %q not found in stack %q", outputKey, stackName)
+}
+
+func dryRunPlaceholder(stackName, outputKey string) string {
+	return "<" + stackName + "." + outputKey + ">"
+}
+
+// DeployTemplate creates or updates a CloudFormation stack from a template file through a change
+// set, and waits for the stack to settle. A template without changes is not an error.
+func DeployTemplate(
+	ctx context.Context, c *awsapi.Clients, stackName, templatePath string,
+	params []CFNParameter, capabilities ...types.Capability,
+) error {
+	body, err := os.ReadFile(templatePath)
+	if err != nil {
+		return errors.Wrapf(err, "failed to read template %s", templatePath)
+	}
+
+	exists, err := StackExists(ctx, c, stackName)
+	if err != nil {
+		return err
+	}
+	changeSetType := types.ChangeSetTypeCreate
+	if exists {
+		changeSetType = types.ChangeSetTypeUpdate
+	}
+
+	parameters := make([]types.Parameter, 0, len(params))
+	for _, p := range params {
+		parameters = append(parameters, types.Parameter{
+			ParameterKey:   aws.String(p.Key),
+			ParameterValue: aws.String(p.Value),
+		})
+	}
+
+	created, err := c.CloudFormation.CreateChangeSet(ctx, &cloudformation.CreateChangeSetInput{
+		StackName:     aws.String(stackName),
+		ChangeSetName: aws.String("ago-deploy-" + time.Now().UTC().Format("20060102150405")),
+		ChangeSetType: changeSetType,
+		TemplateBody:  aws.String(string(body)),
+		Parameters:    parameters,
+		Capabilities:  capabilities,
+	})
+	if err != nil {
+		return errors.Wrapf(err, "failed to create change set for stack %q", stackName)
+	}
+	if c.DryRun {
+		return nil
+	}
+
+	describe := &cloudformation.DescribeChangeSetInput{ChangeSetName: created.Id}
+	if err := cloudformation.NewChangeSetCreateCompleteWaiter(c.CloudFormation,
+		func(o *cloudformation.ChangeSetCreateCompleteWaiterOptions) { o.MinDelay = stackPollDelay },
+	).Wait(ctx, describe, stackWaitTimeout); err != nil {
+		changeSet, descErr := c.CloudFormation.DescribeChangeSet(ctx, describe)
+		if descErr == nil && isEmptyChangeSet(changeSet) {
+			_, _ = c.CloudFormation.DeleteChangeSet(ctx, &cloudformation.DeleteChangeSetInput{ChangeSetName: created.Id})
+			return nil
+		}
+		if descErr == nil && changeSet.StatusReason != nil {
+			return errors.Errorf("change set for stack %q failed: %s", stackName, *changeSet.StatusReason)
+		}
+		return errors.Wrapf(err, "failed to create change set for stack %q", stackName)
+	}
+
+	if _, err := c.CloudFormation.ExecuteChangeSet(ctx, &cloudformation.ExecuteChangeSetInput{
+		ChangeSetName: created.Id,
+	}); err != nil {
+		return errors.Wrapf(err, "failed to execute change set for stack %q", stackName)
+	}
+
+	stacks := &cloudformation.DescribeStacksInput{StackName: aws.String(stackName)}
+	if exists {
+		err = cloudformation.NewStackUpdateCompleteWaiter(c.CloudFormation,
+			func(o *cloudformation.StackUpdateCompleteWaiterOptions) { o.MinDelay = stackPollDelay },
+		).Wait(ctx, stacks, stackWaitTimeout)
+	} else {
+		err = cloudformation.NewStackCreateCompleteWaiter(c.CloudFormation,
+			func(o *cloudformation.StackCreateCompleteWaiterOptions) { o.MinDelay = stackPollDelay },
+		).Wait(ctx, stacks, stackWaitTimeout)
+	}
+	if err != nil {
+		return errors.Wrapf(err, "failed waiting for deployment of stack %q", stackName)
+	}
+
+	return nil
+}
+
+// ChangeSetChanges returns the resource changes of a change set. The result is nil when the change
+// set does not exist, and empty but not nil when it has no resource changes.
+func ChangeSetChanges(
+	ctx context.Context, c *awsapi.Clients, stackName, changeSetName string,
+) ([]types.ResourceChange, error) {
+	changes := []types.ResourceChange{}
+	in := &cloudformation.DescribeChangeSetInput{
+		StackName:     aws.String(stackName),
+		ChangeSetName: aws.String(changeSetName),
+	}
+	for {
+		out, err := c.CloudFormation.DescribeChangeSet(ctx, in)
+		if awsapi.IsErrorCode(err, "ChangeSetNotFound") {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to describe change set of stack %q", stackName)
+		}
+		for _, change := range out.Changes {
+			if change.ResourceChange != nil {
+				changes = append(changes, *change.ResourceChange)
+			}
+		}
+		if out.NextToken == nil {
+			return changes, nil
+		}
+		in.NextToken = out.NextToken
+	}
+}
+
+// DeleteChangeSet deletes a change set of a stack.
+func DeleteChangeSet(ctx context.Context, c *awsapi.Clients, stackName, changeSetName string) error {
+	if _, err := c.CloudFormation.DeleteChangeSet(ctx, &cloudformation.DeleteChangeSetInput{
+		StackName:     aws.String(stackName),
+		ChangeSetName: aws.String(changeSetName),
+	}); err != nil {
+		return errors.Wrapf(err, "failed to delete change set of stack %q", stackName)
+	}
+	return nil
+}
+
+// isEmptyChangeSet reports whether a change set failed only because the template has no changes.
+func isEmptyChangeSet(changeSet *cloudformation.DescribeChangeSetOutput) bool {
+	if changeSet.Status != types.ChangeSetStatusFailed {
+		return false
+	}
+	reason := aws.ToString(changeSet.StatusReason)
+	return strings.Contains(reason, "didn't contain changes") ||
+		strings.Contains(reason, "No updates are to be performed")
+}
+
+// DeleteStack deletes a CloudFormation stack and waits for the deletion to complete.
+func DeleteStack(ctx context.Context, c *awsapi.Clients, stackName string) error {
+	if _, err := c.CloudFormation.DeleteStack(ctx, &cloudformation.DeleteStackInput{
+		StackName: aws.String(stackName),
+	}); err != nil {
+		return errors.Wrapf(err, "failed to delete stack %q", stackName)
+	}
+	if c.DryRun {
+		return nil
+	}
+
+	if err := cloudformation.NewStackDeleteCompleteWaiter(c.CloudFormation,
+		func(o *cloudformation.StackDeleteCompleteWaiterOptions) { o.MinDelay = stackPollDelay },
+	).Wait(ctx, &cloudformation.DescribeStacksInput{StackName: aws.String(stackName)}, stackWaitTimeout); err != nil {
+		return errors.Wrapf(err, "failed waiting for deletion of stack %q", stackName)
+	}
+
+	return nil
+}
+
+// StackStatus returns the status of a CloudFormation stack, or an empty status when the stack
+// does not exist.
+func StackStatus(ctx context.Context, c *awsapi.Clients, stackName string) (types.StackStatus, error) {
+	out, err := c.CloudFormation.DescribeStacks(ctx, &cloudformation.DescribeStacksInput{
+		StackName: aws.String(stackName),
+	})
+	if awsapi.IsStackNotFound(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to describe stack %q", stackName)
+	}
+	if len(out.Stacks) == 0 {
+		return "", nil
+	}
+	return out.Stacks[0].StackStatus, nil
+}
+
+// StackParameters returns the parameter values of a CloudFormation stack by parameter key.
+func StackParameters(ctx context.Context, c *awsapi.Clients, stackName string) (map[string]string, error) {
+	out, err := c.CloudFormation.DescribeStacks(ctx, &cloudformation.DescribeStacksInput{
+		StackName: aws.String(stackName),
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to describe stack %q", stackName)
+	}
+
+	params := map[string]string{}
+	for _, stack := range out.Stacks {
+		for _, p := range stack.Parameters {
+			params[aws.ToString(p.ParameterKey)] = aws.ToString(p.ParameterValue)
+		}
+	}
+	return params, nil
+}
+
+// StackTemplate returns the template body of a deployed CloudFormation stack, or an empty body
+// when the stack does not exist.
+func StackTemplate(ctx context.Context, c *awsapi.Clients, stackName string) (string, error) {
+	out, err := c.CloudFormation.GetTemplate(ctx, &cloudformation.GetTemplateInput{
+		StackName: aws.String(stackName),
+	})
+	if awsapi.IsStackNotFound(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to get template of stack %q", stackName)
+	}
+	return aws.ToString(out.TemplateBody), nil
+}
+
+// RegionExports returns the exports of all stacks in the region of the clients, by stack name
+// and export name. It lists them in one paginated call instead of describing every stack.
+func RegionExports(ctx context.Context, c *awsapi.Clients) (map[string]map[string]string, error) {
+	exports := map[string]map[string]string{}
+	pages := cloudformation.NewListExportsPaginator(c.CloudFormation, &cloudformation.ListExportsInput{})
+	for pages.HasMorePages() {
+		page, err := pages.NextPage(ctx)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to list exports")
+		}
+		for _, export := range page.Exports {
+			stackName := stackNameFromID(aws.ToString(export.ExportingStackId))
+			if exports[stackName] == nil {
+				exports[stackName] = map[string]string{}
+			}
+			exports[stackName][aws.ToString(export.Name)] = aws.ToString(export.Value)
+		}
+	}
+	return exports, nil
+}
+
+// stackNameFromID returns the stack name in a stack ID of the form
+// arn:aws:cloudformation:region:account:stack/name/uuid.
+func stackNameFromID(stackID string) string {
+	parts := strings.Split(stackID, "/")
+	if len(parts) < 2 {
+		return stackID
+	}
+	return parts[1]
+}
+
+// ExportImporters returns the names of the stacks that import an export.
+func ExportImporters(ctx context.Context, c *awsapi.Clients, exportName string) ([]string, error) {
+	var importers []string
+	pages := cloudformation.NewListImportsPaginator(c.CloudFormation, &cloudformation.ListImportsInput{
+		ExportName: aws.String(exportName),
+	})
+	for pages.HasMorePages() {
+		page, err := pages.NextPage(ctx)
+		if awsapi.IsErrorCode(err, "ValidationError") && strings.Contains(err.Error(), "is not imported") {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to list imports of export %q", exportName)
+		}
+		importers = append(importers, page.Imports...)
+	}
+	return importers, nil
+}
+
+// FailedResources returns the logical IDs of the resources of a stack that failed to update,
+// which are the resources that block a stack in UPDATE_ROLLBACK_FAILED.
+func FailedResources(ctx context.Context, c *awsapi.Clients, stackName string) ([]string, error) {
+	var failed []string
+	pages := cloudformation.NewListStackResourcesPaginator(c.CloudFormation, &cloudformation.ListStackResourcesInput{
+		StackName: aws.String(stackName),
+	})
+	for pages.HasMorePages() {
+		page, err := pages.NextPage(ctx)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to list resources of stack %q", stackName)
+		}
+		for _, r := range page.StackResourceSummaries {
+			if r.ResourceStatus == types.ResourceStatusUpdateFailed {
+				failed = append(failed, aws.ToString(r.LogicalResourceId))
+			}
+		}
+	}
+	return failed, nil
+}
+
+// ContinueUpdateRollback resumes the rollback of a stack in UPDATE_ROLLBACK_FAILED, skipping the
+// given resources, and waits for the rollback to complete. Skipped resources are marked as
+// rolled back without CloudFormation touching them.
+func ContinueUpdateRollback(ctx context.Context, c *awsapi.Clients, stackName string, skip []string) error {
+	if _, err := c.CloudFormation.ContinueUpdateRollback(ctx, &cloudformation.ContinueUpdateRollbackInput{
+		StackName:       aws.String(stackName),
+		ResourcesToSkip: skip,
+	}); err != nil {
+		return errors.Wrapf(err, "failed to continue rollback of stack %q", stackName)
+	}
+	if c.DryRun {
+		return nil
+	}
+
+	if err := cloudformation.NewStackRollbackCompleteWaiter(c.CloudFormation,
+		func(o *cloudformation.StackRollbackCompleteWaiterOptions) { o.MinDelay = stackPollDelay },
+	).Wait(ctx, &cloudformation.DescribeStacksInput{StackName: aws.String(stackName)}, stackWaitTimeout); err != nil {
+		return errors.Wrapf(err, "failed waiting for rollback of stack %q", stackName)
+	}
+
+	return nil
+}
+
+func logf(w io.Writer, format string, args ...any) {
+	if w != nil {
+		_, _ = fmt.Fprintf(w, format, args...)
+	}
+}
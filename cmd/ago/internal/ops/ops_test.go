@@ -0,0 +1,428 @@
+package ops_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/advdv/ago/cmd/ago/internal/awsapi"
+	"github.com/advdv/ago/cmd/ago/internal/ops"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+	iamtypes "github.com/aws/aws-sdk-go-v2/service/iam/types"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/aws/smithy-go"
+)
+
+// fakeCloudFormation answers DescribeStacks with the given stacks and records the change sets
+// it is asked to create. Stacks it does not know do not exist.
+type fakeCloudFormation struct {
+	awsapi.CloudFormation
+	stacks     map[string]types.Stack
+	changeSet  *cloudformation.DescribeChangeSetOutput
+	resources  []types.StackResourceSummary
+	exports    []types.Export
+	imports    map[string][]string
+	created    []*cloudformation.CreateChangeSetInput
+	executed   int
+	deletedCSs int
+}
+
+func (f *fakeCloudFormation) DescribeStacks(
+	_ context.Context, in *cloudformation.DescribeStacksInput, _ ...func(*cloudformation.Options),
+) (*cloudformation.DescribeStacksOutput, error) {
+	stack, ok := f.stacks[aws.ToString(in.StackName)]
+	if !ok {
+		return nil, &smithy.GenericAPIError{
+			Code:    "ValidationError",
+			Message: "Stack with id " + aws.ToString(in.StackName) + " does not exist",
+		}
+	}
+	return &cloudformation.DescribeStacksOutput{Stacks: []types.Stack{stack}}, nil
+}
+
+func (f *fakeCloudFormation) ListStackResources(
+	_ context.Context, _ *cloudformation.ListStackResourcesInput, _ ...func(*cloudformation.Options),
+) (*cloudformation.ListStackResourcesOutput, error) {
+	return &cloudformation.ListStackResourcesOutput{StackResourceSummaries: f.resources}, nil
+}
+
+func (f *fakeCloudFormation) ListExports(
+	_ context.Context, _ *cloudformation.ListExportsInput, _ ...func(*cloudformation.Options),
+) (*cloudformation.ListExportsOutput, error) {
+	return &cloudformation.ListExportsOutput{Exports: f.exports}, nil
+}
+
+func (f *fakeCloudFormation) ListImports(
+	_ context.Context, in *cloudformation.ListImportsInput, _ ...func(*cloudformation.Options),
+) (*cloudformation.ListImportsOutput, error) {
+	imports, ok := f.imports[aws.ToString(in.ExportName)]
+	if !ok {
+		return nil, &smithy.GenericAPIError{
+			Code:    "ValidationError",
+			Message: "Export '" + aws.ToString(in.ExportName) + "' is not imported by any stack.",
+		}
+	}
+	return &cloudformation.ListImportsOutput{Imports: imports}, nil
+}
+
+func (f *fakeCloudFormation) CreateChangeSet(
+	_ context.Context, in *cloudformation.CreateChangeSetInput, _ ...func(*cloudformation.Options),
+) (*cloudformation.CreateChangeSetOutput, error) {
+	f.created = append(f.created, in)
+	return &cloudformation.CreateChangeSetOutput{Id: aws.String("arn:changeset")}, nil
+}
+
+func (f *fakeCloudFormation) DescribeChangeSet(
+	_ context.Context, _ *cloudformation.DescribeChangeSetInput, _ ...func(*cloudformation.Options),
+) (*cloudformation.DescribeChangeSetOutput, error) {
+	return f.changeSet, nil
+}
+
+func (f *fakeCloudFormation) ExecuteChangeSet(
+	_ context.Context, in *cloudformation.ExecuteChangeSetInput, _ ...func(*cloudformation.Options),
+) (*cloudformation.ExecuteChangeSetOutput, error) {
+	f.executed++
+	stackName := aws.ToString(f.created[len(f.created)-1].StackName)
+	f.stacks[stackName] = types.Stack{StackName: aws.String(stackName), StackStatus: types.StackStatusCreateComplete}
+	return &cloudformation.ExecuteChangeSetOutput{}, nil
+}
+
+func (f *fakeCloudFormation) DeleteChangeSet(
+	_ context.Context, _ *cloudformation.DeleteChangeSetInput, _ ...func(*cloudformation.Options),
+) (*cloudformation.DeleteChangeSetOutput, error) {
+	f.deletedCSs++
+	return &cloudformation.DeleteChangeSetOutput{}, nil
+}
+
+type fakeSTS struct {
+	awsapi.STS
+	account string
+	arn     string
+}
+
+func (f *fakeSTS) GetCallerIdentity(
+	_ context.Context, _ *sts.GetCallerIdentityInput, _ ...func(*sts.Options),
+) (*sts.GetCallerIdentityOutput, error) {
+	return &sts.GetCallerIdentityOutput{Account: aws.String(f.account), Arn: aws.String(f.arn)}, nil
+}
+
+type fakeIAM struct {
+	awsapi.IAM
+	attached []string
+	groups   map[string][]string
+}
+
+func (f *fakeIAM) ListGroupsForUser(
+	_ context.Context, in *iam.ListGroupsForUserInput, _ ...func(*iam.Options),
+) (*iam.ListGroupsForUserOutput, error) {
+	out := &iam.ListGroupsForUserOutput{}
+	for _, name := range f.groups[aws.ToString(in.UserName)] {
+		out.Groups = append(out.Groups, iamtypes.Group{GroupName: aws.String(name)})
+	}
+	return out, nil
+}
+
+func (f *fakeIAM) AttachRolePolicy(
+	_ context.Context, in *iam.AttachRolePolicyInput, _ ...func(*iam.Options),
+) (*iam.AttachRolePolicyOutput, error) {
+	f.attached = append(f.attached, aws.ToString(in.RoleName))
+	return &iam.AttachRolePolicyOutput{}, nil
+}
+
+func stackWithOutputs(name string, outputs map[string]string) types.Stack {
+	stack := types.Stack{StackName: aws.String(name), StackStatus: types.StackStatusCreateComplete}
+	for k, v := range outputs {
+		stack.Outputs = append(stack.Outputs, types.Output{OutputKey: aws.String(k), OutputValue: aws.String(v)})
+	}
+	return stack
+}
+
+func TestStackOutput(t *testing.T) {
+	t.Parallel()
+
+	c := &awsapi.Clients{CloudFormation: &fakeCloudFormation{stacks: map[string]types.Stack{
+		"ago-account-myapp": stackWithOutputs("ago-account-myapp", map[string]string{"AccountId": "123456789012"}),
+	}}}
+
+	got, err := ops.StackOutput(context.Background(), c, "ago-account-myapp", "AccountId")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got != "123456789012" {
+		t.Errorf("expected account ID, got %q", got)
+	}
+
+	if _, err := ops.StackOutput(context.Background(), c, "ago-account-myapp", "Missing"); err == nil {
+		t.Error("expected error for missing output")
+	}
+	if _, err := ops.StackOutput(context.Background(), c, "ago-account-other", "AccountId"); err == nil {
+		t.Error("expected error for missing stack")
+	}
+}
+
+func TestStackOutputs(t *testing.T) {
+	t.Parallel()
+
+	c := &awsapi.Clients{CloudFormation: &fakeCloudFormation{stacks: map[string]types.Stack{
+		"myappEuc1Shared": stackWithOutputs("myappEuc1Shared", map[string]string{"RepositoryURI": "repo"}),
+	}}}
+
+	got, err := ops.StackOutputs(context.Background(), c, "myappEuc1Shared")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(got) != 1 || got["RepositoryURI"] != "repo" {
+		t.Errorf("unexpected outputs: %v", got)
+	}
+
+	got, err = ops.StackOutputs(context.Background(), c, "myappEuc1DevAlice")
+	if err != nil || got != nil {
+		t.Errorf("expected no outputs and no error for a missing stack, got %v, %v", got, err)
+	}
+}
+
+func TestStackExists(t *testing.T) {
+	t.Parallel()
+
+	c := &awsapi.Clients{CloudFormation: &fakeCloudFormation{stacks: map[string]types.Stack{
+		"myapp-pre-bootstrap": stackWithOutputs("myapp-pre-bootstrap", nil),
+	}}}
+
+	tests := []struct {
+		stackName string
+		want      bool
+	}{
+		{stackName: "myapp-pre-bootstrap", want: true},
+		{stackName: "other-pre-bootstrap"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.stackName, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := ops.StackExists(context.Background(), c, tt.stackName)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestStackStatusAndFailedResources(t *testing.T) {
+	t.Parallel()
+
+	c := &awsapi.Clients{CloudFormation: &fakeCloudFormation{
+		stacks: map[string]types.Stack{"myappUse1Prod": {
+			StackName: aws.String("myappUse1Prod"), StackStatus: types.StackStatusUpdateRollbackFailed,
+		}},
+		resources: []types.StackResourceSummary{
+			{LogicalResourceId: aws.String("Table"), ResourceStatus: types.ResourceStatusUpdateFailed},
+			{LogicalResourceId: aws.String("Bucket"), ResourceStatus: types.ResourceStatusUpdateComplete},
+		},
+	}}
+
+	status, err := ops.StackStatus(context.Background(), c, "myappUse1Prod")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if status != types.StackStatusUpdateRollbackFailed {
+		t.Errorf("expected UPDATE_ROLLBACK_FAILED, got %q", status)
+	}
+
+	status, err = ops.StackStatus(context.Background(), c, "myappUse1Dev")
+	if err != nil || status != "" {
+		t.Errorf("expected empty status for missing stack, got %q, %v", status, err)
+	}
+
+	failed, err := ops.FailedResources(context.Background(), c, "myappUse1Prod")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !slices.Equal(failed, []string{"Table"}) {
+		t.Errorf("expected [Table], got %v", failed)
+	}
+}
+
+func TestRegionExportsAndImporters(t *testing.T) {
+	t.Parallel()
+
+	c := &awsapi.Clients{CloudFormation: &fakeCloudFormation{
+		exports: []types.Export{
+			{
+				ExportingStackId: aws.String("arn:aws:cloudformation:us-east-1:123456789012:stack/myappUse1Shared/abc"),
+				Name:             aws.String("myappUse1Shared:ExportsOutputRefVpc"),
+				Value:            aws.String("vpc-123"),
+			},
+		},
+		imports: map[string][]string{"myappUse1Shared:ExportsOutputRefVpc": {"myappUse1Dev"}},
+	}}
+
+	exports, err := ops.RegionExports(context.Background(), c)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(exports) != 1 || exports["myappUse1Shared"]["myappUse1Shared:ExportsOutputRefVpc"] != "vpc-123" {
+		t.Errorf("unexpected exports %v", exports)
+	}
+
+	importers, err := ops.ExportImporters(context.Background(), c, "myappUse1Shared:ExportsOutputRefVpc")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !slices.Equal(importers, []string{"myappUse1Dev"}) {
+		t.Errorf("expected [myappUse1Dev], got %v", importers)
+	}
+
+	importers, err = ops.ExportImporters(context.Background(), c, "myappUse1Shared:Unused")
+	if err != nil || importers != nil {
+		t.Errorf("expected no importers for unused export, got %v, %v", importers, err)
+	}
+}
+
+func TestCallerARNAndUserGroups(t *testing.T) {
+	t.Parallel()
+
+	c := &awsapi.Clients{
+		STS: &fakeSTS{arn: "arn:aws:iam::123456789012:user/adam"},
+		IAM: &fakeIAM{groups: map[string][]string{"adam": {"myapp-deployers", "myapp-dev-deployers"}}},
+	}
+
+	arn, err := ops.CallerARN(context.Background(), c)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if arn != "arn:aws:iam::123456789012:user/adam" {
+		t.Errorf("unexpected ARN %q", arn)
+	}
+
+	groups, err := ops.UserGroups(context.Background(), c, "adam")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !slices.Equal(groups, []string{"myapp-deployers", "myapp-dev-deployers"}) {
+		t.Errorf("unexpected groups %v", groups)
+	}
+}
+
+func TestChangeSetChanges(t *testing.T) {
+	t.Parallel()
+
+	cf := &fakeCloudFormation{changeSet: &cloudformation.DescribeChangeSetOutput{
+		Changes: []types.Change{
+			{ResourceChange: &types.ResourceChange{
+				Action: types.ChangeActionRemove, LogicalResourceId: aws.String("Table"),
+			}},
+			{},
+		},
+	}}
+	c := &awsapi.Clients{CloudFormation: cf}
+
+	changes, err := ops.ChangeSetChanges(context.Background(), c, "myappUse1Prod", "ago-protection-check")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(changes) != 1 || aws.ToString(changes[0].LogicalResourceId) != "Table" {
+		t.Errorf("unexpected changes %v", changes)
+	}
+
+	if err := ops.DeleteChangeSet(context.Background(), c, "myappUse1Prod", "ago-protection-check"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cf.deletedCSs != 1 {
+		t.Errorf("expected the change set to be deleted, got %d deletions", cf.deletedCSs)
+	}
+}
+
+func TestDeployTemplate(t *testing.T) {
+	t.Parallel()
+
+	templatePath := filepath.Join(t.TempDir(), "template.yaml")
+	if err := os.WriteFile(templatePath, []byte("Resources: {}\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("creates a new stack", func(t *testing.T) {
+		t.Parallel()
+
+		cfn := &fakeCloudFormation{
+			stacks: map[string]types.Stack{},
+			changeSet: &cloudformation.DescribeChangeSetOutput{
+				Status: types.ChangeSetStatusCreateComplete,
+			},
+		}
+		err := ops.DeployTemplate(context.Background(), &awsapi.Clients{CloudFormation: cfn}, "myapp-pre-bootstrap",
+			templatePath, []ops.CFNParameter{{Key: "Qualifier", Value: "myapp"}}, types.CapabilityCapabilityNamedIam)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if len(cfn.created) != 1 || cfn.created[0].ChangeSetType != types.ChangeSetTypeCreate {
+			t.Fatalf("expected one CREATE change set, got %v", cfn.created)
+		}
+		if got := aws.ToString(cfn.created[0].Parameters[0].ParameterValue); got != "myapp" {
+			t.Errorf("expected Qualifier parameter, got %q", got)
+		}
+		if cfn.executed != 1 {
+			t.Errorf("expected the change set to be executed once, got %d", cfn.executed)
+		}
+	})
+
+	t.Run("skips a template without changes", func(t *testing.T) {
+		t.Parallel()
+
+		cfn := &fakeCloudFormation{
+			stacks: map[string]types.Stack{"myapp-pre-bootstrap": stackWithOutputs("myapp-pre-bootstrap", nil)},
+			changeSet: &cloudformation.DescribeChangeSetOutput{
+				Status:       types.ChangeSetStatusFailed,
+				StatusReason: aws.String("The submitted information didn't contain changes."),
+			},
+		}
+		err := ops.DeployTemplate(context.Background(), &awsapi.Clients{CloudFormation: cfn}, "myapp-pre-bootstrap",
+			templatePath, nil)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if cfn.created[0].ChangeSetType != types.ChangeSetTypeUpdate {
+			t.Errorf("expected an UPDATE change set, got %s", cfn.created[0].ChangeSetType)
+		}
+		if cfn.executed != 0 || cfn.deletedCSs != 1 {
+			t.Errorf("expected the empty change set to be deleted, executed %d deleted %d", cfn.executed, cfn.deletedCSs)
+		}
+	})
+}
+
+func TestAttachDeploymentScopePolicy(t *testing.T) {
+	t.Parallel()
+
+	roles := &fakeIAM{}
+	c := &awsapi.Clients{
+		CloudFormation: &fakeCloudFormation{stacks: map[string]types.Stack{
+			"myapp-pre-bootstrap": stackWithOutputs("myapp-pre-bootstrap",
+				map[string]string{"DeploymentScopePolicyArn": "arn:policy"}),
+		}},
+		STS: &fakeSTS{account: "123456789012"},
+		IAM: roles,
+	}
+
+	err := ops.AttachDeploymentScopePolicy(context.Background(), c, "myapp", []string{"eu-central-1", "us-east-1"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := []string{
+		"cdk-myapp-deploy-role-123456789012-eu-central-1",
+		"cdk-myapp-deploy-role-123456789012-us-east-1",
+	}
+	if !slices.Equal(roles.attached, want) {
+		t.Errorf("expected roles %v, got %v", want, roles.attached)
+	}
+}
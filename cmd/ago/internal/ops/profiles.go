@@ -0,0 +1,200 @@
+package ops
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/advdv/ago/cmd/ago/internal/cmdexec"
+	"github.com/cockroachdb/errors"
+)
+
+// ProfileSetting is a key of an AWS CLI profile.
+type ProfileSetting struct {
+	Key   string
+	Value string
+}
+
+// credentialsFileKeys are the profile settings that belong in the credentials file rather than
+// the config file, as 'aws configure set' writes them.
+var credentialsFileKeys = []string{"aws_access_key_id", "aws_secret_access_key", "aws_session_token"}
+
+// awsFilePaths returns the paths of the shared AWS config and credentials files, honoring
+// AWS_CONFIG_FILE and AWS_SHARED_CREDENTIALS_FILE like the SDK does.
+func awsFilePaths() (configPath, credentialsPath string, err error) {
+	configPath, credentialsPath = os.Getenv("AWS_CONFIG_FILE"), os.Getenv("AWS_SHARED_CREDENTIALS_FILE")
+	if configPath != "" && credentialsPath != "" {
+		return configPath, credentialsPath, nil
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", "", errors.Wrap(err, "failed to get home directory")
+	}
+	if configPath == "" {
+		configPath = filepath.Join(home, ".aws", "config")
+	}
+	if credentialsPath == "" {
+		credentialsPath = filepath.Join(home, ".aws", "credentials")
+	}
+	return configPath, credentialsPath, nil
+}
+
+// SetProfile writes the settings of an AWS CLI profile to the shared config and credentials
+// files, the same way 'aws configure set' does, so no AWS CLI is needed. Keys that the profile has
+// already are replaced in place.
+func SetProfile(profileName string, settings []ProfileSetting) error {
+	configPath, credentialsPath, err := awsFilePaths()
+	if err != nil {
+		return err
+	}
+
+	var configSettings, credentialsSettings []ProfileSetting
+	for _, s := range settings {
+		if slices.Contains(credentialsFileKeys, s.Key) {
+			credentialsSettings = append(credentialsSettings, s)
+		} else {
+			configSettings = append(configSettings, s)
+		}
+	}
+
+	if err := setSectionInFile(credentialsPath, profileName, credentialsSettings); err != nil {
+		return err
+	}
+	return setSectionInFile(configPath, configSectionName(profileName), configSettings)
+}
+
+// configSectionName returns the section of a profile in the config file, which prefixes all but
+// the default profile with "profile".
+func configSectionName(profileName string) string {
+	if profileName == "default" {
+		return profileName
+	}
+	return "profile " + profileName
+}
+
+// setSectionInFile writes settings to a section of an ini file, creating the file and the
+// section when needed.
+func setSectionInFile(filePath, sectionName string, settings []ProfileSetting) error {
+	if len(settings) == 0 {
+		return nil
+	}
+
+	data, err := os.ReadFile(filePath)
+	if err != nil && !os.IsNotExist(err) {
+		return errors.Wrapf(err, "failed to read %s", filePath)
+	}
+
+	if cmdexec.DryRun() {
+		keys := make([]string, 0, len(settings))
+		for _, s := range settings {
+			keys = append(keys, s.Key)
+		}
+		cmdexec.ReportDryRun("would set %s in [%s] of %s", strings.Join(keys, ", "), sectionName, filePath)
+		return nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(filePath), 0o700); err != nil {
+		return errors.Wrapf(err, "failed to create %s", filepath.Dir(filePath))
+	}
+	if err := os.WriteFile(filePath, []byte(setSectionSettings(string(data), sectionName, settings)), 0o600); err != nil {
+		return errors.Wrapf(err, "failed to write %s", filePath)
+	}
+	return nil
+}
+
+// setSectionSettings returns ini data with settings written to a section. Keys that the section
+// has already keep their position, new keys are added at the end of the section, and a missing
+// section is appended to the data.
+func setSectionSettings(data, sectionName string, settings []ProfileSetting) string {
+	lines := strings.Split(strings.TrimRight(data, "\n"), "\n")
+	if data == "" {
+		lines = nil
+	}
+
+	header := "[" + sectionName + "]"
+	start := slices.IndexFunc(lines, func(line string) bool { return strings.TrimSpace(line) == header })
+	if start < 0 {
+		if len(lines) > 0 {
+			lines = append(lines, "")
+		}
+		lines = append(lines, header)
+		start = len(lines) - 1
+	}
+
+	end := len(lines)
+	for i := start + 1; i < len(lines); i++ {
+		if strings.HasPrefix(strings.TrimSpace(lines[i]), "[") {
+			end = i
+			break
+		}
+	}
+	// Blank lines before the next section stay there, after the added keys.
+	for end > start+1 && strings.TrimSpace(lines[end-1]) == "" {
+		end--
+	}
+
+	var added []string
+	for _, s := range settings {
+		line := strings.TrimSpace(s.Key + " = " + s.Value)
+		i := slices.IndexFunc(lines[start+1:end], func(line string) bool {
+			key, _, ok := strings.Cut(line, "=")
+			return ok && strings.TrimSpace(key) == s.Key
+		})
+		if i >= 0 {
+			lines[start+1+i] = line
+		} else {
+			added = append(added, line)
+		}
+	}
+
+	lines = slices.Insert(lines, end, added...)
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// ListProfiles returns the names of the profiles in the shared config and credentials files, like
+// 'aws configure list-profiles' does.
+func ListProfiles() ([]string, error) {
+	configData, credentialsData, err := readAWSFiles()
+	if err != nil {
+		return nil, err
+	}
+	return ProfileNames(configData, credentialsData), nil
+}
+
+// ProfileNames returns the names of the profiles in the contents of an AWS config and credentials
+// file, in the order that they appear, without duplicates.
+func ProfileNames(configData, credentialsData string) []string {
+	var profiles []string
+	add := func(name string) {
+		if name != "" && !slices.Contains(profiles, name) {
+			profiles = append(profiles, name)
+		}
+	}
+
+	for _, section := range iniSections(configData) {
+		switch {
+		case section == "default":
+			add(section)
+		case strings.HasPrefix(section, "profile "):
+			add(strings.TrimSpace(strings.TrimPrefix(section, "profile ")))
+		}
+	}
+	for _, section := range iniSections(credentialsData) {
+		add(section)
+	}
+	return profiles
+}
+
+// iniSections returns the names of the sections in ini data.
+func iniSections(data string) []string {
+	var sections []string
+	for line := range strings.SplitSeq(data, "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			sections = append(sections, strings.TrimSpace(line[1:len(line)-1]))
+		}
+	}
+	return sections
+}
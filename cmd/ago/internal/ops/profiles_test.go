@@ -0,0 +1,94 @@
+package ops_test
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/advdv/ago/cmd/ago/internal/ops"
+)
+
+func TestSetProfile(t *testing.T) {
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "config")
+	credentialsPath := filepath.Join(dir, "credentials")
+	t.Setenv("AWS_CONFIG_FILE", configPath)
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", credentialsPath)
+
+	if err := os.WriteFile(configPath, []byte(`[default]
+region = us-east-1
+
+[profile myapp-adam]
+region = eu-west-1
+output = json
+
+[profile other]
+region = us-west-2
+`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ops.SetProfile("myapp-adam", []ops.ProfileSetting{
+		{Key: "aws_access_key_id", Value: "AKIA"},
+		{Key: "aws_secret_access_key", Value: "secret"},
+		{Key: "region", Value: "eu-central-1"},
+		{Key: "cli_pager", Value: ""},
+	}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := ops.SetProfile("myapp-bob", []ops.ProfileSetting{
+		{Key: "region", Value: "eu-central-1"},
+	}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	config, err := os.ReadFile(configPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `[default]
+region = us-east-1
+
+[profile myapp-adam]
+region = eu-central-1
+output = json
+cli_pager =
+
+[profile other]
+region = us-west-2
+
+[profile myapp-bob]
+region = eu-central-1
+`; string(config) != want {
+		t.Errorf("unexpected config file:\n%s\nwant:\n%s", config, want)
+	}
+
+	credentials, err := os.ReadFile(credentialsPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `[myapp-adam]
+aws_access_key_id = AKIA
+aws_secret_access_key = secret
+`; string(credentials) != want {
+		t.Errorf("unexpected credentials file:\n%s\nwant:\n%s", credentials, want)
+	}
+
+	profiles, err := ops.ListProfiles()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := []string{"default", "myapp-adam", "other", "myapp-bob"}; !slices.Equal(profiles, want) {
+		t.Errorf("expected %v, got %v", want, profiles)
+	}
+}
+
+func TestProfileNames(t *testing.T) {
+	t.Parallel()
+
+	got := ops.ProfileNames("[default]\n[profile a]\n[sso-session s]\n[ profile b ]\n", "[a]\n[c]\n")
+	if want := []string{"default", "a", "b", "c"}; !slices.Equal(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+}
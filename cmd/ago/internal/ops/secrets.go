@@ -0,0 +1,280 @@
+package ops
+
+import (
+	"context"
+	"encoding/json"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/advdv/ago/cmd/ago/internal/awsapi"
+	"github.com/advdv/ago/cmd/ago/internal/cmdexec"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	smtypes "github.com/aws/aws-sdk-go-v2/service/secretsmanager/types"
+	"github.com/cockroachdb/errors"
+)
+
+// mainSecretLength is the length of a generated main secret.
+const mainSecretLength = 32
+
+// MainSecretName returns the Secrets Manager secret that the pre-bootstrap stack creates for the
+// project. It is replicated to the secondary regions.
+func MainSecretName(qualifier string) string {
+	return qualifier + "/main-secret"
+}
+
+// SharedSecretName returns the Secrets Manager secret with the name that is shared by all
+// deployments.
+func SharedSecretName(qualifier, name string) string {
+	return qualifier + "/" + name
+}
+
+// DeploymentSecretName returns the Secrets Manager secret with the name that belongs to a
+// deployment.
+func DeploymentSecretName(qualifier, deployment, name string) string {
+	return qualifier + "/" + deployment + "/" + name
+}
+
+// SecretString returns the string value of a secret. It returns false when the secret does not
+// exist.
+func SecretString(ctx context.Context, c *awsapi.Clients, secretName string) (string, bool, error) {
+	out, err := c.SecretsManager.GetSecretValue(ctx, &secretsmanager.GetSecretValueInput{
+		SecretId: aws.String(secretName),
+	})
+	if awsapi.IsErrorCode(err, "ResourceNotFoundException") {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, errors.Wrapf(err, "failed to get secret %s", secretName)
+	}
+	registerSecretValue(aws.ToString(out.SecretString))
+	return aws.ToString(out.SecretString), true, nil
+}
+
+// registerSecretValue masks the value of a secret in the output of commands, along with the
+// string values in it when it is a JSON object, such as the keys of a deployer.
+func registerSecretValue(value string) {
+	cmdexec.RegisterSecret(value)
+
+	var fields map[string]any
+	if json.Unmarshal([]byte(value), &fields) != nil {
+		return
+	}
+	for _, field := range fields {
+		if s, ok := field.(string); ok {
+			cmdexec.RegisterSecret(s)
+		}
+	}
+}
+
+// batchGetSecretLimit is the most secrets that a single BatchGetSecretValue request may name.
+const batchGetSecretLimit = 20
+
+// SecretStrings returns the string values of the secrets by name, with a request per 20 secrets
+// instead of one per secret. Secrets that cannot be read are left out of the values, and why is
+// in the returned errors by secret name.
+func SecretStrings(
+	ctx context.Context, c *awsapi.Clients, secretNames []string,
+) (map[string]string, map[string]error, error) {
+	values := make(map[string]string, len(secretNames))
+	failed := map[string]error{}
+	for chunk := range slices.Chunk(secretNames, batchGetSecretLimit) {
+		in := &secretsmanager.BatchGetSecretValueInput{SecretIdList: chunk}
+		for {
+			out, err := c.SecretsManager.BatchGetSecretValue(ctx, in)
+			if err != nil {
+				return nil, nil, errors.Wrapf(err, "failed to get secrets %s", strings.Join(chunk, ", "))
+			}
+			for _, entry := range out.SecretValues {
+				values[aws.ToString(entry.Name)] = aws.ToString(entry.SecretString)
+				registerSecretValue(aws.ToString(entry.SecretString))
+			}
+			for _, e := range out.Errors {
+				failed[aws.ToString(e.SecretId)] = errors.Newf("failed to get secret %s: %s: %s",
+					aws.ToString(e.SecretId), aws.ToString(e.ErrorCode), aws.ToString(e.Message))
+			}
+			if out.NextToken == nil {
+				break
+			}
+			in.NextToken = out.NextToken
+		}
+	}
+	return values, failed, nil
+}
+
+// PutSecretString stores a new value of a secret, and creates the secret when it does not exist.
+func PutSecretString(ctx context.Context, c *awsapi.Clients, secretName, value string) error {
+	registerSecretValue(value)
+	_, err := c.SecretsManager.PutSecretValue(ctx, &secretsmanager.PutSecretValueInput{
+		SecretId:     aws.String(secretName),
+		SecretString: aws.String(value),
+	})
+	if err == nil {
+		return nil
+	}
+	if !awsapi.IsErrorCode(err, "ResourceNotFoundException") {
+		return errors.Wrapf(err, "failed to put secret %s", secretName)
+	}
+
+	if _, err := c.SecretsManager.CreateSecret(ctx, &secretsmanager.CreateSecretInput{
+		Name:         aws.String(secretName),
+		SecretString: aws.String(value),
+	}); err != nil {
+		return errors.Wrapf(err, "failed to create secret %s", secretName)
+	}
+	return nil
+}
+
+// SecretInfo describes a secret without its value.
+type SecretInfo struct {
+	Name          string `json:"name"`
+	Description   string `json:"description,omitempty"`
+	LastChanged   string `json:"last_changed,omitempty"`
+	LastRotated   string `json:"last_rotated,omitempty"`
+	PrimaryRegion string `json:"primary_region,omitempty"`
+}
+
+// ListSecrets returns the secrets whose name starts with the prefix, sorted by name.
+func ListSecrets(ctx context.Context, c *awsapi.Clients, prefix string) ([]SecretInfo, error) {
+	var secrets []SecretInfo
+	paginator := secretsmanager.NewListSecretsPaginator(c.SecretsManager, &secretsmanager.ListSecretsInput{
+		Filters: []smtypes.Filter{{Key: smtypes.FilterNameStringTypeName, Values: []string{prefix}}},
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to list secrets")
+		}
+		for _, entry := range page.SecretList {
+			info := SecretInfo{
+				Name:          aws.ToString(entry.Name),
+				Description:   aws.ToString(entry.Description),
+				PrimaryRegion: aws.ToString(entry.PrimaryRegion),
+			}
+			if entry.LastChangedDate != nil {
+				info.LastChanged = entry.LastChangedDate.UTC().Format(time.RFC3339)
+			}
+			if entry.LastRotatedDate != nil {
+				info.LastRotated = entry.LastRotatedDate.UTC().Format(time.RFC3339)
+			}
+			secrets = append(secrets, info)
+		}
+	}
+
+	slices.SortFunc(secrets, func(a, b SecretInfo) int { return strings.Compare(a.Name, b.Name) })
+	return secrets, nil
+}
+
+// Version stages that Secrets Manager gives special meaning. Clients read AWSCURRENT unless they
+// ask for another stage; AWSPENDING holds a new value until it is promoted.
+const (
+	SecretStageCurrent = "AWSCURRENT"
+	SecretStagePending = "AWSPENDING"
+)
+
+// GenerateSecretValue returns a new random value for a secret, of letters and digits only so that
+// it can be used in URLs and connection strings without escaping.
+func GenerateSecretValue(ctx context.Context, c *awsapi.Clients) (string, error) {
+	out, err := c.SecretsManager.GetRandomPassword(ctx, &secretsmanager.GetRandomPasswordInput{
+		PasswordLength:     aws.Int64(mainSecretLength),
+		ExcludePunctuation: aws.Bool(true),
+	})
+	if err != nil {
+		return "", errors.Wrap(err, "failed to generate a secret value")
+	}
+	return aws.ToString(out.RandomPassword), nil
+}
+
+// StageSecretValue stores the value as a new version of the secret under AWSPENDING, so that it
+// can be validated before clients that read AWSCURRENT see it. It returns the ID of the version.
+func StageSecretValue(ctx context.Context, c *awsapi.Clients, secretName, value string) (string, error) {
+	out, err := c.SecretsManager.PutSecretValue(ctx, &secretsmanager.PutSecretValueInput{
+		SecretId:      aws.String(secretName),
+		SecretString:  aws.String(value),
+		VersionStages: []string{SecretStagePending},
+	})
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to stage a new value of secret %s", secretName)
+	}
+	return aws.ToString(out.VersionId), nil
+}
+
+// PromoteSecretVersion moves AWSCURRENT to the staged version, which makes Secrets Manager label
+// the version it replaces AWSPREVIOUS, and then takes AWSPENDING off the staged version.
+func PromoteSecretVersion(ctx context.Context, c *awsapi.Clients, secretName, versionID string) error {
+	desc, err := c.SecretsManager.DescribeSecret(ctx, &secretsmanager.DescribeSecretInput{
+		SecretId: aws.String(secretName),
+	})
+	if err != nil {
+		return errors.Wrapf(err, "failed to describe secret %s", secretName)
+	}
+
+	in := &secretsmanager.UpdateSecretVersionStageInput{
+		SecretId:        aws.String(secretName),
+		VersionStage:    aws.String(SecretStageCurrent),
+		MoveToVersionId: aws.String(versionID),
+	}
+	for id, stages := range desc.VersionIdsToStages {
+		if id != versionID && slices.Contains(stages, SecretStageCurrent) {
+			in.RemoveFromVersionId = aws.String(id)
+		}
+	}
+	if _, err := c.SecretsManager.UpdateSecretVersionStage(ctx, in); err != nil {
+		return errors.Wrapf(err, "failed to promote version %s of secret %s", versionID, secretName)
+	}
+
+	return DiscardSecretVersion(ctx, c, secretName, versionID)
+}
+
+// DiscardSecretVersion takes AWSPENDING off a staged version. A version without stages is
+// deprecated, and Secrets Manager deletes it eventually.
+func DiscardSecretVersion(ctx context.Context, c *awsapi.Clients, secretName, versionID string) error {
+	if _, err := c.SecretsManager.UpdateSecretVersionStage(ctx, &secretsmanager.UpdateSecretVersionStageInput{
+		SecretId:            aws.String(secretName),
+		VersionStage:        aws.String(SecretStagePending),
+		RemoveFromVersionId: aws.String(versionID),
+	}); err != nil {
+		return errors.Wrapf(err, "failed to remove %s from version %s of secret %s",
+			SecretStagePending, versionID, secretName)
+	}
+	return nil
+}
+
+// ReplicateSecret makes sure the secret is replicated to every one of the regions. Secrets
+// Manager copies new values to existing replicas by itself, so only regions without a replica are
+// added. It returns the regions that were added.
+func ReplicateSecret(ctx context.Context, c *awsapi.Clients, secretName string, regions []string) ([]string, error) {
+	desc, err := c.SecretsManager.DescribeSecret(ctx, &secretsmanager.DescribeSecretInput{
+		SecretId: aws.String(secretName),
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to describe secret %s", secretName)
+	}
+
+	replicated := make([]string, 0, len(desc.ReplicationStatus))
+	for _, status := range desc.ReplicationStatus {
+		replicated = append(replicated, aws.ToString(status.Region))
+	}
+
+	var added []string
+	var replicas []smtypes.ReplicaRegionType
+	for _, region := range regions {
+		if slices.Contains(replicated, region) || slices.Contains(added, region) {
+			continue
+		}
+		added = append(added, region)
+		replicas = append(replicas, smtypes.ReplicaRegionType{Region: aws.String(region)})
+	}
+	if len(replicas) == 0 {
+		return nil, nil
+	}
+
+	if _, err := c.SecretsManager.ReplicateSecretToRegions(ctx, &secretsmanager.ReplicateSecretToRegionsInput{
+		SecretId:          aws.String(secretName),
+		AddReplicaRegions: replicas,
+	}); err != nil {
+		return nil, errors.Wrapf(err, "failed to replicate secret %s", secretName)
+	}
+	return added, nil
+}
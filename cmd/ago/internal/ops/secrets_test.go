@@ -0,0 +1,256 @@
+package ops_test
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"testing"
+
+	"github.com/advdv/ago/cmd/ago/internal/awsapi"
+	"github.com/advdv/ago/cmd/ago/internal/ops"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	smtypes "github.com/aws/aws-sdk-go-v2/service/secretsmanager/types"
+	"github.com/aws/smithy-go"
+)
+
+// fakeSecretsManager keeps secret values in memory. Secrets it does not know do not exist. The
+// AWSCURRENT version of every secret is "v1", a staged version is "v2".
+type fakeSecretsManager struct {
+	awsapi.SecretsManager
+	values     map[string]string
+	pending    map[string]string
+	replicas   map[string][]string
+	replicated []string
+	demoted    []string
+	batches    int
+}
+
+func (f *fakeSecretsManager) GetSecretValue(
+	_ context.Context, in *secretsmanager.GetSecretValueInput, _ ...func(*secretsmanager.Options),
+) (*secretsmanager.GetSecretValueOutput, error) {
+	value, ok := f.values[aws.ToString(in.SecretId)]
+	if !ok {
+		return nil, &smithy.GenericAPIError{Code: "ResourceNotFoundException"}
+	}
+	return &secretsmanager.GetSecretValueOutput{SecretString: aws.String(value)}, nil
+}
+
+func (f *fakeSecretsManager) BatchGetSecretValue(
+	_ context.Context, in *secretsmanager.BatchGetSecretValueInput, _ ...func(*secretsmanager.Options),
+) (*secretsmanager.BatchGetSecretValueOutput, error) {
+	f.batches++
+	out := &secretsmanager.BatchGetSecretValueOutput{}
+	for _, id := range in.SecretIdList {
+		value, ok := f.values[id]
+		if !ok {
+			out.Errors = append(out.Errors, smtypes.APIErrorType{
+				SecretId: aws.String(id), ErrorCode: aws.String("ResourceNotFoundException"),
+			})
+			continue
+		}
+		out.SecretValues = append(out.SecretValues, smtypes.SecretValueEntry{
+			Name: aws.String(id), SecretString: aws.String(value),
+		})
+	}
+	return out, nil
+}
+
+func (f *fakeSecretsManager) PutSecretValue(
+	_ context.Context, in *secretsmanager.PutSecretValueInput, _ ...func(*secretsmanager.Options),
+) (*secretsmanager.PutSecretValueOutput, error) {
+	if _, ok := f.values[aws.ToString(in.SecretId)]; !ok {
+		return nil, &smithy.GenericAPIError{Code: "ResourceNotFoundException"}
+	}
+	if slices.Contains(in.VersionStages, ops.SecretStagePending) {
+		f.pending[aws.ToString(in.SecretId)] = aws.ToString(in.SecretString)
+		return &secretsmanager.PutSecretValueOutput{VersionId: aws.String("v2")}, nil
+	}
+	f.values[aws.ToString(in.SecretId)] = aws.ToString(in.SecretString)
+	return &secretsmanager.PutSecretValueOutput{}, nil
+}
+
+func (f *fakeSecretsManager) CreateSecret(
+	_ context.Context, in *secretsmanager.CreateSecretInput, _ ...func(*secretsmanager.Options),
+) (*secretsmanager.CreateSecretOutput, error) {
+	f.values[aws.ToString(in.Name)] = aws.ToString(in.SecretString)
+	return &secretsmanager.CreateSecretOutput{}, nil
+}
+
+func (f *fakeSecretsManager) GetRandomPassword(
+	_ context.Context, _ *secretsmanager.GetRandomPasswordInput, _ ...func(*secretsmanager.Options),
+) (*secretsmanager.GetRandomPasswordOutput, error) {
+	return &secretsmanager.GetRandomPasswordOutput{RandomPassword: aws.String("rotated")}, nil
+}
+
+func (f *fakeSecretsManager) DescribeSecret(
+	_ context.Context, in *secretsmanager.DescribeSecretInput, _ ...func(*secretsmanager.Options),
+) (*secretsmanager.DescribeSecretOutput, error) {
+	out := &secretsmanager.DescribeSecretOutput{
+		Name:               in.SecretId,
+		VersionIdsToStages: map[string][]string{"v1": {ops.SecretStageCurrent}},
+	}
+	if _, ok := f.pending[aws.ToString(in.SecretId)]; ok {
+		out.VersionIdsToStages["v2"] = []string{ops.SecretStagePending}
+	}
+	for _, region := range f.replicas[aws.ToString(in.SecretId)] {
+		out.ReplicationStatus = append(out.ReplicationStatus, smtypes.ReplicationStatusType{Region: aws.String(region)})
+	}
+	return out, nil
+}
+
+func (f *fakeSecretsManager) ReplicateSecretToRegions(
+	_ context.Context, in *secretsmanager.ReplicateSecretToRegionsInput, _ ...func(*secretsmanager.Options),
+) (*secretsmanager.ReplicateSecretToRegionsOutput, error) {
+	for _, r := range in.AddReplicaRegions {
+		f.replicated = append(f.replicated, aws.ToString(r.Region))
+	}
+	return &secretsmanager.ReplicateSecretToRegionsOutput{}, nil
+}
+
+func (f *fakeSecretsManager) UpdateSecretVersionStage(
+	_ context.Context, in *secretsmanager.UpdateSecretVersionStageInput, _ ...func(*secretsmanager.Options),
+) (*secretsmanager.UpdateSecretVersionStageOutput, error) {
+	name := aws.ToString(in.SecretId)
+	switch aws.ToString(in.VersionStage) {
+	case ops.SecretStageCurrent:
+		f.values[name] = f.pending[name]
+		f.demoted = append(f.demoted, aws.ToString(in.RemoveFromVersionId))
+	case ops.SecretStagePending:
+		delete(f.pending, name)
+	}
+	return &secretsmanager.UpdateSecretVersionStageOutput{}, nil
+}
+
+func TestSecretString(t *testing.T) {
+	t.Parallel()
+
+	sm := &fakeSecretsManager{values: map[string]string{"myapp/Dev/api-key": "abc"}}
+	c := &awsapi.Clients{SecretsManager: sm}
+
+	value, found, err := ops.SecretString(context.Background(), c, "myapp/Dev/api-key")
+	if err != nil || !found || value != "abc" {
+		t.Errorf("SecretString = %q, %v, %v", value, found, err)
+	}
+
+	if _, found, err := ops.SecretString(context.Background(), c, "myapp/Dev/missing"); err != nil || found {
+		t.Errorf("expected a missing secret to be reported as not found, got %v, %v", found, err)
+	}
+}
+
+func TestSecretStrings(t *testing.T) {
+	t.Parallel()
+
+	sm := &fakeSecretsManager{values: map[string]string{}}
+	names := []string{"myapp/deployers/missing"}
+	for i := range 25 {
+		name := fmt.Sprintf("myapp/deployers/user%d", i)
+		sm.values[name] = fmt.Sprintf("key%d", i)
+		names = append(names, name)
+	}
+	c := &awsapi.Clients{SecretsManager: sm}
+
+	values, failed, err := ops.SecretStrings(context.Background(), c, names)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sm.batches != 2 {
+		t.Errorf("expected 26 secrets to be read in 2 requests, got %d", sm.batches)
+	}
+	if len(values) != 25 || values["myapp/deployers/user24"] != "key24" {
+		t.Errorf("unexpected values %v", values)
+	}
+	if _, ok := failed["myapp/deployers/missing"]; !ok || len(failed) != 1 {
+		t.Errorf("expected only the missing secret to fail, got %v", failed)
+	}
+}
+
+func TestPutSecretString(t *testing.T) {
+	t.Parallel()
+
+	sm := &fakeSecretsManager{values: map[string]string{"myapp/Dev/api-key": "abc"}}
+	c := &awsapi.Clients{SecretsManager: sm}
+
+	if err := ops.PutSecretString(context.Background(), c, "myapp/Dev/api-key", "def"); err != nil {
+		t.Fatal(err)
+	}
+	if err := ops.PutSecretString(context.Background(), c, "myapp/Dev/new", "ghi"); err != nil {
+		t.Fatal(err)
+	}
+	if sm.values["myapp/Dev/api-key"] != "def" || sm.values["myapp/Dev/new"] != "ghi" {
+		t.Errorf("unexpected values %v", sm.values)
+	}
+}
+
+func TestStageAndPromoteSecret(t *testing.T) {
+	t.Parallel()
+
+	sm := &fakeSecretsManager{
+		values:  map[string]string{"myapp/main-secret": "old"},
+		pending: map[string]string{},
+	}
+	c := &awsapi.Clients{SecretsManager: sm}
+	ctx := context.Background()
+
+	value, err := ops.GenerateSecretValue(ctx, c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	versionID, err := ops.StageSecretValue(ctx, c, ops.MainSecretName("myapp"), value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sm.values["myapp/main-secret"] != "old" || sm.pending["myapp/main-secret"] != "rotated" {
+		t.Fatalf("expected the new value to be pending only, got %v and %v", sm.values, sm.pending)
+	}
+
+	if err := ops.PromoteSecretVersion(ctx, c, ops.MainSecretName("myapp"), versionID); err != nil {
+		t.Fatal(err)
+	}
+	if sm.values["myapp/main-secret"] != "rotated" {
+		t.Errorf("expected the secret to be rotated, got %q", sm.values["myapp/main-secret"])
+	}
+	if len(sm.pending) != 0 {
+		t.Errorf("expected AWSPENDING to be removed, got %v", sm.pending)
+	}
+	if !slices.Equal(sm.demoted, []string{"v1"}) {
+		t.Errorf("expected AWSCURRENT to move off v1, got %v", sm.demoted)
+	}
+}
+
+func TestDiscardSecretVersion(t *testing.T) {
+	t.Parallel()
+
+	sm := &fakeSecretsManager{
+		values:  map[string]string{"myapp/main-secret": "old"},
+		pending: map[string]string{"myapp/main-secret": "rejected"},
+	}
+	c := &awsapi.Clients{SecretsManager: sm}
+
+	if err := ops.DiscardSecretVersion(context.Background(), c, "myapp/main-secret", "v2"); err != nil {
+		t.Fatal(err)
+	}
+	if sm.values["myapp/main-secret"] != "old" || len(sm.pending) != 0 {
+		t.Errorf("expected the current value to be kept and the pending one dropped, got %v and %v",
+			sm.values, sm.pending)
+	}
+}
+
+func TestReplicateSecret(t *testing.T) {
+	t.Parallel()
+
+	sm := &fakeSecretsManager{
+		values:   map[string]string{"myapp/main-secret": "old"},
+		replicas: map[string][]string{"myapp/main-secret": {"eu-west-1"}},
+	}
+	c := &awsapi.Clients{SecretsManager: sm}
+
+	added, err := ops.ReplicateSecret(context.Background(), c, ops.MainSecretName("myapp"),
+		[]string{"eu-west-1", "us-east-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(added, []string{"us-east-1"}) || !slices.Equal(sm.replicated, []string{"us-east-1"}) {
+		t.Errorf("expected only us-east-1 to be added as a replica, got %v and %v", added, sm.replicated)
+	}
+}
@@ -0,0 +1,92 @@
+package ops
+
+import (
+	"context"
+	"strings"
+
+	"github.com/advdv/ago/cmd/ago/internal/awsapi"
+	"github.com/advdv/ago/cmd/ago/internal/cdkcontext"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/identitystore"
+	"github.com/aws/aws-sdk-go-v2/service/identitystore/document"
+	identitystoretypes "github.com/aws/aws-sdk-go-v2/service/identitystore/types"
+	"github.com/aws/aws-sdk-go-v2/service/ssoadmin"
+	"github.com/cockroachdb/errors"
+)
+
+// Deployer authentication modes, see cdkcontext.DeployerAuthIAMUsers.
+const (
+	DeployerAuthIAMUsers = cdkcontext.DeployerAuthIAMUsers
+	DeployerAuthSSO      = cdkcontext.DeployerAuthSSO
+)
+
+// DeployerSSOStackName returns the stack in the management account that holds the Identity
+// Center permission sets, groups and assignments of the project's deployers.
+func DeployerSSOStackName(qualifier string) string {
+	return qualifier + "-deployer-sso"
+}
+
+// DeployerPermissionSetName returns the Identity Center permission set of the full deployers.
+func DeployerPermissionSetName(qualifier string) string {
+	return qualifier + "-deployer"
+}
+
+// DevDeployerPermissionSetName returns the Identity Center permission set of a dev deployer, which
+// limits the deployer to their own deployment.
+func DevDeployerPermissionSetName(qualifier, username string) string {
+	return qualifier + "-dev-" + strings.ToLower(username)
+}
+
+// SSOSessionName returns the sso-session section that the deployer profiles of a project share,
+// so that one 'aws sso login' signs in all of them.
+func SSOSessionName(qualifier string) string {
+	return qualifier
+}
+
+// SSOInstance identifies the IAM Identity Center instance of the organization.
+type SSOInstance struct {
+	InstanceARN     string
+	IdentityStoreID string
+}
+
+// LookupSSOInstance returns the Identity Center instance that the clients of the management
+// account administer.
+func LookupSSOInstance(ctx context.Context, c *awsapi.Clients) (SSOInstance, error) {
+	out, err := c.SSOAdmin.ListInstances(ctx, &ssoadmin.ListInstancesInput{})
+	if err != nil {
+		return SSOInstance{}, errors.Wrap(err, "failed to list IAM Identity Center instances")
+	}
+	if len(out.Instances) == 0 || out.Instances[0].InstanceArn == nil || out.Instances[0].IdentityStoreId == nil {
+		return SSOInstance{}, errors.Errorf("no IAM Identity Center instance found in %s", c.Region)
+	}
+	return SSOInstance{
+		InstanceARN:     aws.ToString(out.Instances[0].InstanceArn),
+		IdentityStoreID: aws.ToString(out.Instances[0].IdentityStoreId),
+	}, nil
+}
+
+// LookupSSOUserID returns the identity store ID of the user with the given user name. Deployers
+// are listed by the user name they sign in with.
+func LookupSSOUserID(ctx context.Context, c *awsapi.Clients, identityStoreID, username string) (string, error) {
+	out, err := c.IdentityStore.GetUserId(ctx, &identitystore.GetUserIdInput{
+		IdentityStoreId: aws.String(identityStoreID),
+		AlternateIdentifier: &identitystoretypes.AlternateIdentifierMemberUniqueAttribute{
+			Value: identitystoretypes.UniqueAttribute{
+				AttributePath:  aws.String("userName"),
+				AttributeValue: document.NewLazyDocument(username),
+			},
+		},
+	})
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to find IAM Identity Center user %q", username)
+	}
+	return aws.ToString(out.UserId), nil
+}
+
+// DeployDeployerSSO deploys the rendered deployer SSO template to the management account.
+func DeployDeployerSSO(ctx context.Context, c *awsapi.Clients, qualifier, templatePath string) error {
+	if err := DeployTemplate(ctx, c, DeployerSSOStackName(qualifier), templatePath, nil); err != nil {
+		return errors.Wrap(err, "failed to deploy deployer SSO stack")
+	}
+	return nil
+}
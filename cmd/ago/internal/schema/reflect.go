@@ -0,0 +1,210 @@
+package schema
+
+import (
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// For returns the schema of a Go type as decoded from a document. Struct fields are named by
+// their tag, such as "yaml" or "json", and structs are closed, as the strict decoders of ago
+// reject unknown keys. The go-playground/validator rules in the "validate" tags become the
+// matching constraints, so the schema and the decoder agree on what is valid. Rules that JSON
+// Schema cannot express, such as gtefield, are left to the decoder.
+func For(t reflect.Type, tag string) *Schema {
+	return forType(t, tag)
+}
+
+func forType(t reflect.Type, tag string) *Schema {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		return forStruct(t, tag)
+	case reflect.Slice, reflect.Array:
+		return &Schema{Type: TypeArray, Items: forType(t.Elem(), tag)}
+	case reflect.Map:
+		return &Schema{Type: TypeObject, AdditionalProperties: forType(t.Elem(), tag)}
+	case reflect.String:
+		return &Schema{Type: TypeString}
+	case reflect.Bool:
+		return &Schema{Type: TypeBoolean}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: TypeInteger}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: TypeNumber}
+	default:
+		return &Schema{}
+	}
+}
+
+func forStruct(t reflect.Type, tag string) *Schema {
+	s := &Schema{Type: TypeObject, Properties: map[string]*Schema{}, Closed: true}
+	for i := range t.NumField() {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(field.Tag.Get(tag), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		prop := forType(field.Type, tag)
+		rules := field.Tag.Get("validate")
+		if rules != "" && rules != "-" {
+			required := applyRules(prop, strings.Split(rules, ","))
+			if required {
+				s.Required = append(s.Required, name)
+			}
+		}
+		s.Properties[name] = prop
+	}
+	return s
+}
+
+// applyRules adds the constraints of validator rules to s, and reports whether the rules require
+// the value. After "dive" the rules apply to the items of an array or the values of an object,
+// and the rules between "keys" and "endkeys" to the property names.
+func applyRules(s *Schema, rules []string) (required bool) {
+	omitempty := false
+	var pattern charset
+	for i := 0; i < len(rules); i++ {
+		name, param, _ := strings.Cut(rules[i], "=")
+		switch name {
+		case "required":
+			required = true
+			switch s.Type {
+			case TypeString:
+				s.MinLength = ptr(1)
+			case TypeArray:
+				s.MinItems = ptr(1)
+			}
+		case "omitempty":
+			omitempty = true
+		case "oneof":
+			for _, value := range strings.Fields(param) {
+				s.Enum = append(s.Enum, enumValue(s.Type, value))
+			}
+		case "min", "max":
+			applyBound(s, name, param)
+		case "lowercase":
+			pattern.lower = true
+		case "alphanum":
+			pattern.alphanum = true
+		case "datetime":
+			if param == "15:04" {
+				s.Pattern = `^([01][0-9]|2[0-3]):[0-5][0-9]$`
+			}
+		case "fqdn":
+			s.Format = "hostname"
+		case "dive":
+			next := s.Items
+			if s.Type == TypeObject {
+				next = s.AdditionalProperties
+			}
+			rest := rules[i+1:]
+			if len(rest) > 0 && rest[0] == "keys" {
+				end := slices.Index(rest, "endkeys")
+				if end < 0 {
+					end = len(rest)
+				}
+				s.PropertyNames = &Schema{Type: TypeString}
+				applyRules(s.PropertyNames, rest[1:end])
+				rest = rest[min(end+1, len(rest)):]
+			}
+			if next != nil {
+				applyRules(next, rest)
+			}
+			i = len(rules)
+		}
+	}
+
+	if p := pattern.regexp(); p != "" {
+		s.Pattern = p
+	}
+	if omitempty {
+		allowZero(s)
+	}
+	return required
+}
+
+// allowZero lets the zero value of s through, which validator skips with omitempty.
+func allowZero(s *Schema) {
+	switch s.Type {
+	case TypeString:
+		if len(s.Enum) > 0 {
+			s.Enum = append(s.Enum, "")
+		}
+		if s.Pattern != "" {
+			s.Pattern = "^$|" + s.Pattern
+		}
+	case TypeInteger, TypeNumber:
+		if s.Minimum != nil && *s.Minimum > 0 {
+			s.AnyOf = []*Schema{{Const: 0}, {Type: s.Type, Minimum: s.Minimum}}
+			s.Minimum = nil
+		}
+	}
+}
+
+func applyBound(s *Schema, name, param string) {
+	n, err := strconv.ParseFloat(param, 64)
+	if err != nil {
+		return
+	}
+	switch s.Type {
+	case TypeString:
+		if name == "min" {
+			s.MinLength = ptr(int(n))
+		} else {
+			s.MaxLength = ptr(int(n))
+		}
+	case TypeArray:
+		if name == "min" {
+			s.MinItems = ptr(int(n))
+		} else {
+			s.MaxItems = ptr(int(n))
+		}
+	case TypeInteger, TypeNumber:
+		if name == "min" {
+			s.Minimum = &n
+		} else {
+			s.Maximum = &n
+		}
+	}
+}
+
+func enumValue(typ, value string) any {
+	if typ == TypeInteger || typ == TypeNumber {
+		if n, err := strconv.ParseFloat(value, 64); err == nil {
+			return n
+		}
+	}
+	return value
+}
+
+// charset collects the character class rules of a string.
+type charset struct {
+	lower    bool
+	alphanum bool
+}
+
+func (c charset) regexp() string {
+	switch {
+	case c.lower && c.alphanum:
+		return "^[a-z0-9]+$"
+	case c.alphanum:
+		return "^[a-zA-Z0-9]+$"
+	case c.lower:
+		return "^[^A-Z]*$"
+	default:
+		return ""
+	}
+}
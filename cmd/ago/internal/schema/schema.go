@@ -0,0 +1,376 @@
+// Package schema generates JSON Schemas from Go types and validates decoded documents against
+// them.
+//
+// The schemas describe the files that ago reads, such as .ago.yml, so editors can complete and
+// check them, and the loaders validate against the same schemas to report every problem in a
+// file at once, with its path, before any AWS call. Only the subset of JSON Schema that these
+// files need is supported.
+package schema
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+)
+
+// Dialect is the JSON Schema dialect of the generated schemas.
+const Dialect = "https://json-schema.org/draft/2020-12/schema"
+
+// JSON types.
+const (
+	TypeObject  = "object"
+	TypeArray   = "array"
+	TypeString  = "string"
+	TypeInteger = "integer"
+	TypeNumber  = "number"
+	TypeBoolean = "boolean"
+)
+
+// Schema is a JSON Schema. A Closed object rejects properties that it does not declare, which is
+// how misspelled keys are caught.
+//
+//nolint:tagliatelle // JSON Schema keywords are camelCase
+type Schema struct {
+	Schema      string `json:"$schema,omitempty"`
+	ID          string `json:"$id,omitempty"`
+	Title       string `json:"title,omitempty"`
+	Description string `json:"description,omitempty"`
+
+	Type  string    `json:"type,omitempty"`
+	Const any       `json:"const,omitempty"`
+	Enum  []any     `json:"enum,omitempty"`
+	AnyOf []*Schema `json:"anyOf,omitempty"`
+
+	Pattern   string `json:"pattern,omitempty"`
+	Format    string `json:"format,omitempty"`
+	MinLength *int   `json:"minLength,omitempty"`
+	MaxLength *int   `json:"maxLength,omitempty"`
+
+	Minimum *float64 `json:"minimum,omitempty"`
+	Maximum *float64 `json:"maximum,omitempty"`
+
+	Items    *Schema `json:"items,omitempty"`
+	MinItems *int    `json:"minItems,omitempty"`
+	MaxItems *int    `json:"maxItems,omitempty"`
+
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	PatternProperties    map[string]*Schema `json:"patternProperties,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	PropertyNames        *Schema            `json:"propertyNames,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	Closed               bool               `json:"-"`
+}
+
+// MarshalJSON encodes a Closed object with "additionalProperties": false.
+func (s *Schema) MarshalJSON() ([]byte, error) {
+	type plain Schema
+	if !s.Closed {
+		return json.Marshal((*plain)(s))
+	}
+	return json.Marshal(struct {
+		*plain
+		AdditionalProperties bool `json:"additionalProperties"` //nolint:tagliatelle // JSON Schema keyword
+	}{plain: (*plain)(s)})
+}
+
+// String returns the schema of a non-empty string.
+func String() *Schema {
+	return &Schema{Type: TypeString, MinLength: ptr(1)}
+}
+
+// Boolean returns the schema of a boolean.
+func Boolean() *Schema {
+	return &Schema{Type: TypeBoolean}
+}
+
+// Enum returns the schema of a string that is one of the values.
+func Enum(values ...string) *Schema {
+	s := &Schema{Type: TypeString}
+	for _, v := range values {
+		s.Enum = append(s.Enum, v)
+	}
+	return s
+}
+
+// ArrayOf returns the schema of an array whose items match items.
+func ArrayOf(items *Schema) *Schema {
+	return &Schema{Type: TypeArray, Items: items}
+}
+
+// MapOf returns the schema of an object whose property values match values.
+func MapOf(values *Schema) *Schema {
+	return &Schema{Type: TypeObject, AdditionalProperties: values}
+}
+
+// WithDescription sets the description of the schema and returns it.
+func (s *Schema) WithDescription(description string) *Schema {
+	s.Description = description
+	return s
+}
+
+// Validate checks a decoded JSON or YAML document against the schema. The error lists every
+// violation with the path of the value, such as "hooks.deploy.pre[0]".
+func (s *Schema) Validate(value any) error {
+	var v validator
+	v.validate(s, "", value)
+	switch len(v.problems) {
+	case 0:
+		return nil
+	case 1:
+		return errors.New(v.problems[0])
+	default:
+		return errors.Newf("%d problems:\n  - %s", len(v.problems), strings.Join(v.problems, "\n  - "))
+	}
+}
+
+// maxDescribedEnum is the most enum values that a problem lists; longer enums, such as the
+// regions, are left to the schema.
+const maxDescribedEnum = 8
+
+type validator struct {
+	problems []string
+}
+
+func (v *validator) addf(path, format string, args ...any) {
+	msg := fmt.Sprintf(format, args...)
+	if path != "" {
+		msg = path + ": " + msg
+	}
+	v.problems = append(v.problems, msg)
+}
+
+// validate checks value against s and reports whether it matched.
+func (v *validator) validate(s *Schema, path string, value any) bool {
+	before := len(v.problems)
+
+	if len(s.AnyOf) > 0 && !v.anyOf(s.AnyOf, path, value) {
+		return false
+	}
+	if s.Const != nil && !equal(s.Const, value) {
+		v.addf(path, "must be %s", describe(s.Const))
+		return false
+	}
+	if len(s.Enum) > 0 && !slices.ContainsFunc(s.Enum, func(e any) bool { return equal(e, value) }) {
+		if len(s.Enum) > maxDescribedEnum {
+			v.addf(path, "must be one of %d known values, got %s", len(s.Enum), describe(value))
+		} else {
+			v.addf(path, "must be one of %s", describeAll(s.Enum))
+		}
+		return false
+	}
+
+	switch s.Type {
+	case TypeObject:
+		v.object(s, path, value)
+	case TypeArray:
+		v.array(s, path, value)
+	case TypeString:
+		v.string(s, path, value)
+	case TypeInteger, TypeNumber:
+		v.number(s, path, value)
+	case TypeBoolean:
+		if _, ok := value.(bool); !ok {
+			v.addf(path, "must be a boolean")
+		}
+	}
+
+	return len(v.problems) == before
+}
+
+// anyOf reports whether value matches one of the schemas, and reports the problems of the first
+// schema that is not a const otherwise, as "must be 0" rarely explains what else is allowed.
+func (v *validator) anyOf(schemas []*Schema, path string, value any) bool {
+	var first, nonConst []string
+	for _, alt := range schemas {
+		var try validator
+		if try.validate(alt, path, value) {
+			return true
+		}
+		if first == nil {
+			first = try.problems
+		}
+		if nonConst == nil && alt.Const == nil {
+			nonConst = try.problems
+		}
+	}
+	if nonConst != nil {
+		first = nonConst
+	}
+	v.problems = append(v.problems, first...)
+	return false
+}
+
+func (v *validator) object(s *Schema, path string, value any) {
+	obj, ok := value.(map[string]any)
+	if !ok {
+		v.addf(path, "must be an object")
+		return
+	}
+
+	for _, name := range s.Required {
+		if _, ok := obj[name]; !ok {
+			v.addf(path, "missing required property %q", name)
+		}
+	}
+
+	names := make([]string, 0, len(obj))
+	for name := range obj {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	for _, name := range names {
+		child := join(path, name)
+		if s.PropertyNames != nil {
+			v.validate(s.PropertyNames, child, name)
+		}
+
+		matched := false
+		if prop, ok := s.Properties[name]; ok {
+			v.validate(prop, child, obj[name])
+			matched = true
+		}
+		for pattern, prop := range s.PatternProperties {
+			if regexp.MustCompile(pattern).MatchString(name) {
+				v.validate(prop, child, obj[name])
+				matched = true
+			}
+		}
+
+		switch {
+		case matched:
+		case s.Closed:
+			v.addf(path, "unknown property %q", name)
+		case s.AdditionalProperties != nil:
+			v.validate(s.AdditionalProperties, child, obj[name])
+		}
+	}
+}
+
+func (v *validator) array(s *Schema, path string, value any) {
+	items, ok := value.([]any)
+	if !ok {
+		v.addf(path, "must be an array")
+		return
+	}
+	if s.MinItems != nil && len(items) < *s.MinItems {
+		v.addf(path, "must have at least %d items", *s.MinItems)
+	}
+	if s.MaxItems != nil && len(items) > *s.MaxItems {
+		v.addf(path, "must have at most %d items", *s.MaxItems)
+	}
+	if s.Items != nil {
+		for i, item := range items {
+			v.validate(s.Items, path+"["+strconv.Itoa(i)+"]", item)
+		}
+	}
+}
+
+func (v *validator) string(s *Schema, path string, value any) {
+	str, ok := value.(string)
+	if !ok {
+		v.addf(path, "must be a string")
+		return
+	}
+	n := len([]rune(str))
+	if s.MinLength != nil && n < *s.MinLength {
+		if *s.MinLength == 1 {
+			v.addf(path, "must not be empty")
+		} else {
+			v.addf(path, "must be at least %d characters", *s.MinLength)
+		}
+	}
+	if s.MaxLength != nil && n > *s.MaxLength {
+		v.addf(path, "must be at most %d characters", *s.MaxLength)
+	}
+	if s.Pattern != "" && !regexp.MustCompile(s.Pattern).MatchString(str) {
+		v.addf(path, "must match %s", s.Pattern)
+	}
+	if s.Format == "hostname" && !hostnameRegex.MatchString(str) {
+		v.addf(path, "must be a domain name")
+	}
+}
+
+var hostnameRegex = regexp.MustCompile(
+	`^([a-zA-Z0-9]([a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?\.)*[a-zA-Z0-9]([a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?\.?$`)
+
+func (v *validator) number(s *Schema, path string, value any) {
+	n, ok := toFloat(value)
+	if !ok {
+		v.addf(path, "must be a %s", s.Type)
+		return
+	}
+	if s.Type == TypeInteger && n != math.Trunc(n) {
+		v.addf(path, "must be an integer")
+		return
+	}
+	if s.Minimum != nil && n < *s.Minimum {
+		v.addf(path, "must be at least %s", formatFloat(*s.Minimum))
+	}
+	if s.Maximum != nil && n > *s.Maximum {
+		v.addf(path, "must be at most %s", formatFloat(*s.Maximum))
+	}
+}
+
+// toFloat converts the numbers that the JSON and YAML decoders produce.
+func toFloat(value any) (float64, bool) {
+	switch n := value.(type) {
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case uint64:
+		return float64(n), true
+	case float64:
+		return n, true
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil
+	default:
+		return 0, false
+	}
+}
+
+// equal compares a const or enum value of the schema with a decoded value, treating numbers of
+// different types as equal when their values are.
+func equal(want, got any) bool {
+	if w, ok := toFloat(want); ok {
+		g, ok := toFloat(got)
+		return ok && w == g
+	}
+	return want == got
+}
+
+func describe(value any) string {
+	data, _ := json.Marshal(value)
+	return string(data)
+}
+
+func describeAll(values []any) string {
+	described := make([]string, 0, len(values))
+	for _, value := range values {
+		described = append(described, describe(value))
+	}
+	return strings.Join(described, ", ")
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+func join(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
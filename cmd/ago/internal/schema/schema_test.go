@@ -0,0 +1,139 @@
+package schema_test
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/advdv/ago/cmd/ago/internal/schema"
+)
+
+type testFile struct {
+	Version string               `yaml:"version" validate:"required,oneof=1"`
+	Name    string               `yaml:"name,omitempty" validate:"omitempty,lowercase,alphanum"`
+	Memory  int                  `yaml:"memory,omitempty" validate:"omitempty,min=128,max=1024"`
+	Days    []string             `yaml:"days,omitempty" validate:"dive,oneof=mon tue"`
+	Hooks   map[string]testHooks `yaml:"hooks,omitempty" validate:"omitempty,dive,keys,oneof=deploy diff,endkeys"`
+	Ignored string               `yaml:"-"`
+}
+
+type testHooks struct {
+	Pre []string `yaml:"pre,omitempty"`
+}
+
+func TestFor(t *testing.T) {
+	t.Parallel()
+
+	s := schema.For(reflect.TypeFor[testFile](), "yaml")
+	data, err := json.Marshal(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got map[string]any
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got["additionalProperties"] != false {
+		t.Errorf("expected a closed object, got %s", data)
+	}
+	props, _ := got["properties"].(map[string]any)
+	if _, ok := props["Ignored"]; ok || len(props) != 5 {
+		t.Errorf("unexpected properties: %v", props)
+	}
+	if required, _ := got["required"].([]any); len(required) != 1 || required[0] != "version" {
+		t.Errorf("required = %v, want [version]", got["required"])
+	}
+	hooks, _ := props["hooks"].(map[string]any)
+	if names, _ := hooks["propertyNames"].(map[string]any); len(names["enum"].([]any)) != 2 {
+		t.Errorf("expected the hook names as property names, got %v", hooks)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	t.Parallel()
+
+	s := schema.For(reflect.TypeFor[testFile](), "yaml")
+
+	tests := []struct {
+		name  string
+		doc   any
+		wants []string
+	}{
+		{
+			name: "valid",
+			doc: map[string]any{
+				"version": "1", "name": "worker", "memory": uint64(256), "days": []any{"mon"},
+				"hooks": map[string]any{"deploy": map[string]any{"pre": []any{"make test"}}},
+			},
+		},
+		{
+			name: "zero values of omitempty fields",
+			doc:  map[string]any{"version": "1", "name": "", "memory": uint64(0)},
+		},
+		{
+			name:  "missing required property",
+			doc:   map[string]any{},
+			wants: []string{`missing required property "version"`},
+		},
+		{
+			name: "every problem with its path",
+			doc: map[string]any{
+				"version": "2", "name": "Worker", "memory": uint64(64), "days": []any{"mon", "fri"},
+				"hooks":   map[string]any{"deplyo": map[string]any{"pre": "make test"}},
+				"verison": "1",
+			},
+			wants: []string{
+				`version: must be one of "1"`,
+				"name: must match",
+				"memory: must be at least 128",
+				`days[1]: must be one of "mon", "tue"`,
+				`hooks.deplyo: must be one of "deploy", "diff"`,
+				"hooks.deplyo.pre: must be an array",
+				`unknown property "verison"`,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			err := s.Validate(tt.doc)
+			if len(tt.wants) == 0 {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatal("expected error, got nil")
+			}
+			for _, want := range tt.wants {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("expected %q in error:\n%v", want, err)
+				}
+			}
+		})
+	}
+}
+
+func TestValidateNumbers(t *testing.T) {
+	t.Parallel()
+
+	s := schema.MapOf(schema.Boolean())
+	if err := s.Validate(map[string]any{"a": true, "b": "yes"}); err == nil || err.Error() != "b: must be a boolean" {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	n := schema.For(reflect.TypeFor[struct {
+		Count int `json:"count" validate:"min=1"`
+	}](), "json")
+	if err := n.Validate(map[string]any{"count": 1.5}); err == nil {
+		t.Error("expected error for a fraction")
+	}
+	if err := n.Validate(map[string]any{"count": float64(2)}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
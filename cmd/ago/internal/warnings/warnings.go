@@ -0,0 +1,129 @@
+// Package warnings reports the consequences of destructive operations in one format. Every
+// warning has a stable code, so automation can acknowledge a warning it expects with
+// --acknowledge <code>: acknowledged warnings are not printed, and the operations that a
+// danger-level warning guards go ahead without asking.
+package warnings
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+)
+
+// Severity is how serious a warning is.
+type Severity int
+
+const (
+	// Note is information about what happens next, such as a waiting period.
+	Note Severity = iota
+	// Warn is a consequence that the user probably wants to know about.
+	Warn
+	// Danger is a consequence that destroys resources or data.
+	Danger
+)
+
+func (s Severity) String() string {
+	switch s {
+	case Note:
+		return "NOTE"
+	case Warn:
+		return "WARNING"
+	case Danger:
+		return "DANGER"
+	default:
+		return fmt.Sprintf("Severity(%d)", int(s))
+	}
+}
+
+// Code identifies a kind of warning. Codes are part of the CLI's interface: automation passes
+// them to --acknowledge, so they must not change.
+type Code string
+
+// The codes of the warnings the CLI reports.
+const (
+	DeprecatedCommand       Code = "deprecated-command"
+	DNSDelegatedUnchanged   Code = "dns-delegated-unchanged"
+	AccountPostClosure      Code = "account-post-closure"
+	ProtectedResourceChange Code = "protected-resource-change"
+	StackRecreate           Code = "stack-recreate"
+	StackRollbackSkip       Code = "stack-rollback-skip"
+	StackBudget             Code = "stack-budget"
+)
+
+// Codes returns all known codes, for validating --acknowledge.
+func Codes() []Code {
+	return []Code{
+		DeprecatedCommand, DNSDelegatedUnchanged, AccountPostClosure, ProtectedResourceChange,
+		StackRecreate, StackRollbackSkip, StackBudget,
+	}
+}
+
+// Warning is a single warning. Summary is one line; Detail may span several.
+type Warning struct {
+	Code     Code
+	Severity Severity
+	Summary  string
+	Detail   string
+}
+
+// Format renders the warning as a header line with severity and code, followed by the detail
+// indented by two spaces.
+func (w Warning) Format() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s [%s] %s\n", w.Severity, w.Code, w.Summary)
+	if w.Detail != "" {
+		for line := range strings.SplitSeq(strings.TrimSpace(w.Detail), "\n") {
+			if line == "" {
+				b.WriteString("\n")
+				continue
+			}
+			b.WriteString("  " + line + "\n")
+		}
+	}
+	return b.String()
+}
+
+// Reporter prints warnings that have not been acknowledged.
+type Reporter struct {
+	out          io.Writer
+	acknowledged []Code
+}
+
+// New returns a reporter that writes to out and skips the acknowledged codes. It fails on
+// unknown codes, so a typo does not silently acknowledge nothing.
+func New(out io.Writer, acknowledged []string) (*Reporter, error) {
+	r := &Reporter{out: out}
+	for _, code := range acknowledged {
+		if !slices.Contains(Codes(), Code(code)) {
+			return nil, errors.Errorf("unknown warning code %q for --acknowledge", code)
+		}
+		r.acknowledged = append(r.acknowledged, Code(code))
+	}
+	return r, nil
+}
+
+// Acknowledged reports whether the code was acknowledged.
+func (r *Reporter) Acknowledged(code Code) bool {
+	return slices.Contains(r.acknowledged, code)
+}
+
+// Report prints the warning unless it was acknowledged.
+func (r *Reporter) Report(w Warning) {
+	if r.Acknowledged(w.Code) || r.out == nil {
+		return
+	}
+	_, _ = fmt.Fprint(r.out, "\n"+w.Format()+"\n")
+}
+
+// Require guards an operation: it returns nil when the warning was acknowledged, and otherwise
+// prints it and returns an error that says how to acknowledge it.
+func (r *Reporter) Require(w Warning) error {
+	if r.Acknowledged(w.Code) {
+		return nil
+	}
+	r.Report(w)
+	return errors.Errorf("warning %s was not acknowledged, rerun with --acknowledge %s to proceed", w.Code, w.Code)
+}
@@ -0,0 +1,84 @@
+package warnings_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/advdv/ago/cmd/ago/internal/warnings"
+)
+
+func TestWarningFormat(t *testing.T) {
+	t.Parallel()
+
+	w := warnings.Warning{
+		Code:     warnings.DNSDelegatedUnchanged,
+		Severity: warnings.Danger,
+		Summary:  "The flag has NOT been changed",
+		Detail:   "First line.\n\nSecond paragraph.",
+	}
+
+	want := "DANGER [dns-delegated-unchanged] The flag has NOT been changed\n" +
+		"  First line.\n" +
+		"\n" +
+		"  Second paragraph.\n"
+	if got := w.Format(); got != want {
+		t.Errorf("unexpected format:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestReporter(t *testing.T) {
+	t.Parallel()
+
+	note := warnings.Warning{Code: warnings.AccountPostClosure, Severity: warnings.Note, Summary: "Closing"}
+	danger := warnings.Warning{Code: warnings.ProtectedResourceChange, Severity: warnings.Danger, Summary: "Replacing"}
+
+	t.Run("unacknowledged", func(t *testing.T) {
+		t.Parallel()
+
+		var buf bytes.Buffer
+		r, err := warnings.New(&buf, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		r.Report(note)
+		if !strings.Contains(buf.String(), "NOTE [account-post-closure] Closing") {
+			t.Errorf("expected the note to be printed, got %q", buf.String())
+		}
+
+		err = r.Require(danger)
+		if err == nil || !strings.Contains(err.Error(), "--acknowledge protected-resource-change") {
+			t.Errorf("expected an error that names the code, got %v", err)
+		}
+		if !strings.Contains(buf.String(), "DANGER [protected-resource-change] Replacing") {
+			t.Errorf("expected the guarding warning to be printed, got %q", buf.String())
+		}
+	})
+
+	t.Run("acknowledged", func(t *testing.T) {
+		t.Parallel()
+
+		var buf bytes.Buffer
+		r, err := warnings.New(&buf, []string{"account-post-closure", "protected-resource-change"})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		r.Report(note)
+		if err := r.Require(danger); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+		if buf.Len() != 0 {
+			t.Errorf("expected no output for acknowledged warnings, got %q", buf.String())
+		}
+	})
+
+	t.Run("unknown code", func(t *testing.T) {
+		t.Parallel()
+
+		if _, err := warnings.New(nil, []string{"protected-resource"}); err == nil {
+			t.Error("expected an error for an unknown code")
+		}
+	})
+}
@@ -0,0 +1,414 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"io"
+	"slices"
+	"strings"
+
+	"github.com/advdv/ago/agcdk/agcdkjobs"
+	"github.com/advdv/ago/agcdkutil"
+	"github.com/advdv/ago/cmd/ago/internal/awsapi"
+	"github.com/advdv/ago/cmd/ago/internal/cdkcontext"
+	"github.com/advdv/ago/cmd/ago/internal/config"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+	ecstypes "github.com/aws/aws-sdk-go-v2/service/ecs/types"
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
+	lambdatypes "github.com/aws/aws-sdk-go-v2/service/lambda/types"
+	"github.com/aws/aws-sdk-go-v2/service/scheduler"
+	schedulertypes "github.com/aws/aws-sdk-go-v2/service/scheduler/types"
+	"github.com/cockroachdb/errors"
+	"github.com/urfave/cli/v3"
+)
+
+// Schedule states of EventBridge Scheduler.
+const (
+	scheduleStateEnabled  = "ENABLED"
+	scheduleStateDisabled = "DISABLED"
+)
+
+func jobsCmd() *cli.Command {
+	return &cli.Command{
+		Name:  "jobs",
+		Usage: "List, run and switch off the scheduled jobs of agcdkjobs",
+		Commands: []*cli.Command{
+			jobsListCmd(),
+			jobsRunNowCmd(),
+			jobsDisableCmd(),
+			jobsEnableCmd(),
+		},
+	}
+}
+
+func jobsDeploymentFlag() cli.Flag {
+	return &cli.StringFlag{
+		Name:  "deployment",
+		Usage: "Deployment that owns the jobs (default: the caller's Dev deployment)",
+	}
+}
+
+func jobsJobFlag() cli.Flag {
+	return &cli.StringFlag{
+		Name:     "job",
+		Usage:    "Name of the job, as given to agcdkjobs.New",
+		Required: true,
+	}
+}
+
+func jobsListCmd() *cli.Command {
+	return &cli.Command{
+		Name:   "list",
+		Usage:  "List the jobs of a deployment with their schedule and state",
+		Flags:  []cli.Flag{jobsDeploymentFlag()},
+		Action: config.RunWithConfig(runJobsList),
+	}
+}
+
+func jobsRunNowCmd() *cli.Command {
+	return &cli.Command{
+		Name:   "run-now",
+		Usage:  "Run a job once now, the way its schedule runs it",
+		Flags:  []cli.Flag{jobsDeploymentFlag(), jobsJobFlag()},
+		Action: config.RunWithConfig(runJobsRunNow),
+	}
+}
+
+func jobsDisableCmd() *cli.Command {
+	return &cli.Command{
+		Name: "disable",
+		Usage: "Switch off the schedule of a job now and record it in cdk.context.json, so that " +
+			"deploys keep it off",
+		Flags: []cli.Flag{jobsDeploymentFlag(), jobsJobFlag(), &cli.StringFlag{
+			Name:  "reason",
+			Usage: "Why the job is disabled, recorded in cdk.context.json",
+			Value: "disabled with ago jobs disable",
+		}},
+		Action: config.RunWithConfig(runJobsDisable),
+	}
+}
+
+func jobsEnableCmd() *cli.Command {
+	return &cli.Command{
+		Name:   "enable",
+		Usage:  "Switch the schedule of a disabled job back on",
+		Flags:  []cli.Flag{jobsDeploymentFlag(), jobsJobFlag()},
+		Action: config.RunWithConfig(runJobsEnable),
+	}
+}
+
+type jobsOptions struct {
+	Deployment string
+	Job        string
+	Reason     string
+	Profile    string
+	Region     string
+	Output     io.Writer
+	Result     io.Writer
+}
+
+func jobsOptionsFrom(cmd *cli.Command) jobsOptions {
+	output, result := commandOutput(cmd)
+	return jobsOptions{
+		Deployment: cmd.String("deployment"),
+		Job:        cmd.String("job"),
+		Reason:     cmd.String("reason"),
+		Profile:    cmd.String("profile"),
+		Region:     cmd.String("region"),
+		Output:     output,
+		Result:     result,
+	}
+}
+
+func runJobsList(ctx context.Context, cmd *cli.Command, cfg config.Config) error {
+	return doJobsList(ctx, cfg, jobsOptionsFrom(cmd))
+}
+
+func runJobsRunNow(ctx context.Context, cmd *cli.Command, cfg config.Config) error {
+	return doJobsRunNow(ctx, cfg, jobsOptionsFrom(cmd))
+}
+
+func runJobsDisable(ctx context.Context, cmd *cli.Command, cfg config.Config) error {
+	return doJobsSetState(ctx, cfg, jobsOptionsFrom(cmd), scheduleStateDisabled)
+}
+
+func runJobsEnable(ctx context.Context, cmd *cli.Command, cfg config.Config) error {
+	return doJobsSetState(ctx, cfg, jobsOptionsFrom(cmd), scheduleStateEnabled)
+}
+
+// jobsTarget is the schedule group of the jobs of a deployment.
+type jobsTarget struct {
+	Group      string
+	Deployment string
+	AWS        *awsapi.Clients
+}
+
+// resolveJobs finds the schedule group of the deployment's jobs in the output registry of its
+// deployment stack.
+func resolveJobs(ctx context.Context, cfg config.Config, opts jobsOptions) (jobsTarget, error) {
+	stacks, err := resolveDeploymentStacks(ctx, cfg, opts.Deployment, opts.Profile, opts.Region)
+	if err != nil {
+		return jobsTarget{}, err
+	}
+
+	outputs, err := stacks.outputs(ctx, stacks.Stack)
+	if err != nil {
+		return jobsTarget{}, err
+	}
+	group := outputs[agcdkutil.JobsScheduleGroupOutputKey]
+	if group == "" {
+		return jobsTarget{}, errors.Errorf("no jobs found in the outputs of %s, schedule them with agcdkjobs.New",
+			stacks.Stack)
+	}
+
+	return jobsTarget{Group: group, Deployment: stacks.Deployment, AWS: stacks.aws}, nil
+}
+
+// scheduleRunner returns what runs the job of a schedule.
+func scheduleRunner(s *scheduler.GetScheduleOutput) string {
+	if s.Target != nil && s.Target.EcsParameters != nil {
+		return agcdkjobs.RunnerECS
+	}
+	return agcdkjobs.RunnerLambda
+}
+
+// getSchedule returns the schedule of a job.
+func (t jobsTarget) getSchedule(ctx context.Context, name string) (*scheduler.GetScheduleOutput, error) {
+	s, err := t.AWS.Scheduler.GetSchedule(ctx, &scheduler.GetScheduleInput{
+		GroupName: aws.String(t.Group),
+		Name:      aws.String(name),
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get the schedule of job %q", name)
+	}
+	if s.Target == nil {
+		return nil, errors.Errorf("schedule of job %q has no target", name)
+	}
+	return s, nil
+}
+
+// jobsListEntry is a job in the result of 'ago jobs list' in the JSON output format.
+type jobsListEntry struct {
+	Name           string `json:"name"`
+	State          string `json:"state"`
+	Schedule       string `json:"schedule"`
+	TimeZone       string `json:"timeZone,omitempty"`
+	Runner         string `json:"runner"`
+	DisabledReason string `json:"disabledReason,omitempty"`
+}
+
+func doJobsList(ctx context.Context, cfg config.Config, opts jobsOptions) error {
+	target, err := resolveJobs(ctx, cfg, opts)
+	if err != nil {
+		return err
+	}
+
+	var names []string
+	pages := scheduler.NewListSchedulesPaginator(target.AWS.Scheduler, &scheduler.ListSchedulesInput{
+		GroupName: aws.String(target.Group),
+	})
+	for pages.HasMorePages() {
+		page, err := pages.NextPage(ctx)
+		if err != nil {
+			return errors.Wrap(err, "failed to list schedules")
+		}
+		for _, s := range page.Schedules {
+			names = append(names, aws.ToString(s.Name))
+		}
+	}
+	slices.Sort(names)
+
+	reasons := disabledJobs(cfg, target.Deployment)
+	entries := make([]jobsListEntry, 0, len(names))
+	for _, name := range names {
+		s, err := target.getSchedule(ctx, name)
+		if err != nil {
+			return err
+		}
+		entries = append(entries, jobsListEntry{
+			Name:           name,
+			State:          string(s.State),
+			Schedule:       aws.ToString(s.ScheduleExpression),
+			TimeZone:       aws.ToString(s.ScheduleExpressionTimezone),
+			Runner:         scheduleRunner(s),
+			DisabledReason: reasons[name],
+		})
+	}
+
+	if opts.Result != nil {
+		return writeResult(opts.Result, entries)
+	}
+
+	writeOutputf(opts.Output, "Jobs of %s (schedule group %s):\n", target.Deployment, target.Group)
+	for _, e := range entries {
+		line := "  " + e.Name + "  " + e.State + "  " + e.Schedule
+		if e.TimeZone != "" {
+			line += " " + e.TimeZone
+		}
+		line += "  " + e.Runner
+		if e.DisabledReason != "" {
+			line += "  (" + e.DisabledReason + ")"
+		}
+		writeOutputf(opts.Output, "%s\n", line)
+	}
+	if len(entries) == 0 {
+		writeOutputf(opts.Output, "  (none)\n")
+	}
+	return nil
+}
+
+// disabledJobs returns the jobs of a deployment that cdk.context.json records as disabled, with
+// their reason.
+func disabledJobs(cfg config.Config, deployment string) map[string]string {
+	cdk, err := loadCDKContext(cfg)
+	if err != nil {
+		return nil
+	}
+	all, _ := cdk.Values[cdk.Prefix+agcdkutil.DisabledJobsContextKey].(map[string]any)
+	jobs, _ := all[deployment].(map[string]any)
+
+	reasons := make(map[string]string, len(jobs))
+	for name, reason := range jobs {
+		reasons[name], _ = reason.(string)
+	}
+	return reasons
+}
+
+func doJobsRunNow(ctx context.Context, cfg config.Config, opts jobsOptions) error {
+	target, err := resolveJobs(ctx, cfg, opts)
+	if err != nil {
+		return err
+	}
+	s, err := target.getSchedule(ctx, opts.Job)
+	if err != nil {
+		return err
+	}
+
+	if in := runTaskInput(s); in != nil {
+		_, err = target.AWS.ECS.RunTask(ctx, in)
+	} else {
+		_, err = target.AWS.Lambda.Invoke(ctx, invokeInput(s))
+	}
+	if err != nil {
+		return errors.Wrapf(err, "failed to run job %q", opts.Job)
+	}
+
+	writeOutputf(opts.Output, "Started job %s of %s on %s\n", opts.Job, target.Deployment, scheduleRunner(s))
+	return nil
+}
+
+// invokeInput returns the input that invokes the function of a schedule once, without waiting
+// for it to finish.
+func invokeInput(s *scheduler.GetScheduleOutput) *lambda.InvokeInput {
+	return &lambda.InvokeInput{
+		FunctionName:   s.Target.Arn,
+		InvocationType: lambdatypes.InvocationTypeEvent,
+		Payload:        []byte(cmp.Or(aws.ToString(s.Target.Input), "{}")),
+	}
+}
+
+// runTaskInput returns the input that starts the task of a schedule once, or nil when a function
+// runs the job.
+func runTaskInput(s *scheduler.GetScheduleOutput) *ecs.RunTaskInput {
+	params := s.Target.EcsParameters
+	if params == nil {
+		return nil
+	}
+
+	vpc := &ecstypes.AwsVpcConfiguration{AssignPublicIp: ecstypes.AssignPublicIpDisabled}
+	if n := params.NetworkConfiguration; n != nil && n.AwsvpcConfiguration != nil {
+		vpc.Subnets = n.AwsvpcConfiguration.Subnets
+		vpc.SecurityGroups = n.AwsvpcConfiguration.SecurityGroups
+		if n.AwsvpcConfiguration.AssignPublicIp != "" {
+			vpc.AssignPublicIp = ecstypes.AssignPublicIp(n.AwsvpcConfiguration.AssignPublicIp)
+		}
+	}
+	return &ecs.RunTaskInput{
+		Cluster:              s.Target.Arn,
+		TaskDefinition:       params.TaskDefinitionArn,
+		LaunchType:           ecstypes.LaunchType(cmp.Or(string(params.LaunchType), string(ecstypes.LaunchTypeFargate))),
+		NetworkConfiguration: &ecstypes.NetworkConfiguration{AwsvpcConfiguration: vpc},
+	}
+}
+
+func doJobsSetState(ctx context.Context, cfg config.Config, opts jobsOptions, state string) error {
+	target, err := resolveJobs(ctx, cfg, opts)
+	if err != nil {
+		return err
+	}
+	s, err := target.getSchedule(ctx, opts.Job)
+	if err != nil {
+		return err
+	}
+
+	if _, err := target.AWS.Scheduler.UpdateSchedule(ctx, scheduleUpdateInput(s, state)); err != nil {
+		return errors.Wrapf(err, "failed to update the schedule of job %q", opts.Job)
+	}
+
+	if err := cdkcontext.Update(cfg.CDKDir(), func(context map[string]any, prefix string) error {
+		setJobDisabled(context, prefix, target.Deployment, opts.Job, opts.Reason, state == scheduleStateDisabled)
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	if state == scheduleStateDisabled {
+		writeOutputf(opts.Output, "Disabled job %s of %s. Commit cdk.context.json so that deploys keep it "+
+			"disabled\n", opts.Job, target.Deployment)
+		return nil
+	}
+	writeOutputf(opts.Output, "Enabled job %s of %s. Commit cdk.context.json so that deploys keep it "+
+		"enabled\n", opts.Job, target.Deployment)
+	return nil
+}
+
+// scheduleUpdateInput returns the input that updates a schedule to the given state. Update
+// replaces the whole schedule, so everything else is passed on unchanged.
+func scheduleUpdateInput(s *scheduler.GetScheduleOutput, state string) *scheduler.UpdateScheduleInput {
+	return &scheduler.UpdateScheduleInput{
+		Name:                       s.Name,
+		GroupName:                  s.GroupName,
+		ScheduleExpression:         s.ScheduleExpression,
+		ScheduleExpressionTimezone: s.ScheduleExpressionTimezone,
+		FlexibleTimeWindow:         s.FlexibleTimeWindow,
+		Target:                     s.Target,
+		Description:                s.Description,
+		StartDate:                  s.StartDate,
+		EndDate:                    s.EndDate,
+		KmsKeyArn:                  s.KmsKeyArn,
+		ActionAfterCompletion:      s.ActionAfterCompletion,
+		State:                      schedulertypes.ScheduleState(state),
+	}
+}
+
+// setJobDisabled records or removes a disabled job of a deployment in the cdk.context.json
+// contents, dropping maps that become empty.
+func setJobDisabled(context map[string]any, prefix, deployment, job, reason string, disabled bool) {
+	key := prefix + agcdkutil.DisabledJobsContextKey
+	all, _ := context[key].(map[string]any)
+	if all == nil {
+		all = map[string]any{}
+	}
+	jobs, _ := all[deployment].(map[string]any)
+	if jobs == nil {
+		jobs = map[string]any{}
+	}
+
+	if disabled {
+		jobs[job] = strings.TrimSpace(reason)
+	} else {
+		delete(jobs, job)
+	}
+
+	if len(jobs) == 0 {
+		delete(all, deployment)
+	} else {
+		all[deployment] = jobs
+	}
+	if len(all) == 0 {
+		delete(context, key)
+		return
+	}
+	context[key] = all
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"reflect"
+	"slices"
+	"testing"
+
+	"github.com/advdv/ago/agcdkutil"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	ecstypes "github.com/aws/aws-sdk-go-v2/service/ecs/types"
+	lambdatypes "github.com/aws/aws-sdk-go-v2/service/lambda/types"
+	"github.com/aws/aws-sdk-go-v2/service/scheduler"
+	schedulertypes "github.com/aws/aws-sdk-go-v2/service/scheduler/types"
+)
+
+func testLambdaSchedule() *scheduler.GetScheduleOutput {
+	return &scheduler.GetScheduleOutput{
+		GroupName:                  aws.String("myappEuc1Dev"),
+		Name:                       aws.String("nightly-report"),
+		ScheduleExpression:         aws.String("cron(0 3 * * ? *)"),
+		ScheduleExpressionTimezone: aws.String("Europe/Amsterdam"),
+		State:                      schedulertypes.ScheduleStateEnabled,
+		FlexibleTimeWindow:         &schedulertypes.FlexibleTimeWindow{Mode: schedulertypes.FlexibleTimeWindowModeOff},
+		Target: &schedulertypes.Target{
+			Arn:     aws.String("arn:aws:lambda:eu-central-1:123456789012:function:nightly"),
+			Input:   aws.String(`{"job":"nightly-report"}`),
+			RoleArn: aws.String("arn:aws:iam::123456789012:role/scheduler"),
+		},
+	}
+}
+
+func testECSSchedule() *scheduler.GetScheduleOutput {
+	return &scheduler.GetScheduleOutput{
+		Name:               aws.String("reindex"),
+		ScheduleExpression: aws.String("rate(6 hours)"),
+		State:              schedulertypes.ScheduleStateEnabled,
+		Target: &schedulertypes.Target{
+			Arn: aws.String("arn:aws:ecs:eu-central-1:123456789012:cluster/main"),
+			EcsParameters: &schedulertypes.EcsParameters{
+				TaskDefinitionArn: aws.String("arn:aws:ecs:eu-central-1:123456789012:task-definition/reindex:3"),
+				NetworkConfiguration: &schedulertypes.NetworkConfiguration{
+					AwsvpcConfiguration: &schedulertypes.AwsVpcConfiguration{
+						Subnets:        []string{"subnet-1"},
+						SecurityGroups: []string{"sg-1"},
+					},
+				},
+			},
+		},
+	}
+}
+
+func TestRunNowInput(t *testing.T) {
+	t.Parallel()
+
+	lambdaSchedule, ecsSchedule := testLambdaSchedule(), testECSSchedule()
+	if scheduleRunner(lambdaSchedule) != "lambda" || scheduleRunner(ecsSchedule) != "ecs" {
+		t.Errorf("unexpected runners %q and %q", scheduleRunner(lambdaSchedule), scheduleRunner(ecsSchedule))
+	}
+
+	if in := runTaskInput(lambdaSchedule); in != nil {
+		t.Errorf("expected no task for a function, got %v", in)
+	}
+	invoke := invokeInput(lambdaSchedule)
+	if aws.ToString(invoke.FunctionName) != "arn:aws:lambda:eu-central-1:123456789012:function:nightly" ||
+		invoke.InvocationType != lambdatypes.InvocationTypeEvent ||
+		string(invoke.Payload) != `{"job":"nightly-report"}` {
+		t.Errorf("unexpected invoke input %+v", invoke)
+	}
+
+	lambdaSchedule.Target.Input = nil
+	if got := string(invokeInput(lambdaSchedule).Payload); got != "{}" {
+		t.Errorf("expected an empty object payload without input, got %s", got)
+	}
+
+	task := runTaskInput(ecsSchedule)
+	if task == nil {
+		t.Fatal("expected a task for an ECS schedule")
+	}
+	if aws.ToString(task.Cluster) != "arn:aws:ecs:eu-central-1:123456789012:cluster/main" ||
+		aws.ToString(task.TaskDefinition) != "arn:aws:ecs:eu-central-1:123456789012:task-definition/reindex:3" ||
+		task.LaunchType != ecstypes.LaunchTypeFargate {
+		t.Errorf("unexpected run task input %+v", task)
+	}
+	vpc := task.NetworkConfiguration.AwsvpcConfiguration
+	if !slices.Equal(vpc.Subnets, []string{"subnet-1"}) || !slices.Equal(vpc.SecurityGroups, []string{"sg-1"}) ||
+		vpc.AssignPublicIp != ecstypes.AssignPublicIpDisabled {
+		t.Errorf("unexpected network configuration %+v", vpc)
+	}
+}
+
+func TestScheduleUpdateInput(t *testing.T) {
+	t.Parallel()
+
+	s := testLambdaSchedule()
+	got := scheduleUpdateInput(s, scheduleStateDisabled)
+	if got.State != schedulertypes.ScheduleStateDisabled {
+		t.Errorf("State = %v, want %s", got.State, scheduleStateDisabled)
+	}
+	if aws.ToString(got.ScheduleExpression) != "cron(0 3 * * ? *)" || aws.ToString(got.GroupName) != "myappEuc1Dev" ||
+		aws.ToString(got.ScheduleExpressionTimezone) != "Europe/Amsterdam" || got.Target != s.Target ||
+		got.FlexibleTimeWindow != s.FlexibleTimeWindow {
+		t.Errorf("expected the rest of the schedule to be kept, got %+v", got)
+	}
+}
+
+func TestSetJobDisabled(t *testing.T) {
+	t.Parallel()
+
+	key := "myapp-" + agcdkutil.DisabledJobsContextKey
+	context := map[string]any{}
+
+	setJobDisabled(context, "myapp-", "Dev", "nightly-report", " flaky upstream ", true)
+	setJobDisabled(context, "myapp-", "Dev", "reindex", "", true)
+	want := map[string]any{"Dev": map[string]any{"nightly-report": "flaky upstream", "reindex": ""}}
+	if !reflect.DeepEqual(context[key], want) {
+		t.Fatalf("got %v, want %v", context[key], want)
+	}
+
+	setJobDisabled(context, "myapp-", "Dev", "reindex", "", false)
+	setJobDisabled(context, "myapp-", "Prod", "reindex", "", false)
+	want = map[string]any{"Dev": map[string]any{"nightly-report": "flaky upstream"}}
+	if !reflect.DeepEqual(context[key], want) {
+		t.Fatalf("got %v, want %v", context[key], want)
+	}
+
+	setJobDisabled(context, "myapp-", "Dev", "nightly-report", "", false)
+	if _, ok := context[key]; ok {
+		t.Errorf("expected the key to be removed once no job is disabled, got %v", context[key])
+	}
+}
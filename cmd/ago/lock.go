@@ -0,0 +1,398 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"os/user"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/advdv/ago/cmd/ago/internal/awsapi"
+	"github.com/advdv/ago/cmd/ago/internal/cmdexec"
+	"github.com/advdv/ago/cmd/ago/internal/config"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	ssmtypes "github.com/aws/aws-sdk-go-v2/service/ssm/types"
+	"github.com/cockroachdb/errors"
+	"github.com/urfave/cli/v3"
+)
+
+func lockCmd() *cli.Command {
+	return &cli.Command{
+		Name:  "lock",
+		Usage: "Inspect and break the locks that bootstrap, deploy and destroy hold while running",
+		Commands: []*cli.Command{
+			{
+				Name:   "status",
+				Usage:  "List the locks that are currently held",
+				Action: config.RunWithConfig(runLockStatus),
+			},
+			{
+				Name:      "break",
+				Usage:     "Release a lock that was left behind by an interrupted command",
+				ArgsUsage: "<scope>",
+				Action:    config.RunWithConfig(runLockBreak),
+			},
+		},
+	}
+}
+
+// lockLocalDir is the directory in the project that holds local locks.
+const lockLocalDir = ".ago-locks"
+
+// Lock scopes. Deployment scopes are suffixed with the deployment identifier.
+const (
+	lockScopeBootstrap  = "bootstrap"
+	lockScopeShared     = "shared"
+	lockScopeDeployment = "deployment-"
+)
+
+// lockInfo describes who holds a lock.
+type lockInfo struct {
+	Scope     string    `json:"scope"`
+	Command   string    `json:"command"`
+	Owner     string    `json:"owner"`
+	PID       int       `json:"pid"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// locker stores locks. Acquire fails with errLockHeld when the scope is locked already.
+type locker interface {
+	Acquire(ctx context.Context, info lockInfo) error
+	Release(ctx context.Context, scope string) error
+	List(ctx context.Context) ([]lockInfo, error)
+}
+
+var errLockHeld = errors.New("lock is held")
+
+// deploymentLockScopes returns the scopes that a command on the given deployments must hold.
+func deploymentLockScopes(deployments []string) []string {
+	scopes := make([]string, 0, len(deployments))
+	for _, d := range deployments {
+		scopes = append(scopes, lockScopeDeployment+d)
+	}
+	slices.Sort(scopes)
+	return slices.Compact(scopes)
+}
+
+// withLocks runs fn while holding the locks of all scopes. Scopes are acquired in order, so two
+// commands that need overlapping scopes cannot deadlock, and are released when fn returns.
+func withLocks(
+	ctx context.Context, lk locker, output io.Writer, command string, scopes []string, fn func() error,
+) (err error) {
+	owner := lockOwner()
+	var held []string
+	defer func() {
+		for _, scope := range slices.Backward(held) {
+			if releaseErr := lk.Release(context.WithoutCancel(ctx), scope); releaseErr != nil {
+				err = errors.CombineErrors(err, errors.Wrapf(releaseErr, "failed to release lock %q", scope))
+			}
+		}
+	}()
+
+	for _, scope := range scopes {
+		info := lockInfo{Scope: scope, Command: command, Owner: owner, PID: os.Getpid(), CreatedAt: time.Now()}
+		if err := lk.Acquire(ctx, info); err != nil {
+			return describeLockError(ctx, lk, scope, err)
+		}
+		held = append(held, scope)
+	}
+
+	writeOutputf(output, "Acquired lock(s): %s\n", strings.Join(scopes, ", "))
+	return fn()
+}
+
+// describeLockError explains who holds a lock that could not be acquired.
+func describeLockError(ctx context.Context, lk locker, scope string, err error) error {
+	if !errors.Is(err, errLockHeld) {
+		return errors.Wrapf(err, "failed to acquire lock %q", scope)
+	}
+
+	locks, listErr := lk.List(ctx)
+	if listErr == nil {
+		for _, l := range locks {
+			if l.Scope == scope {
+				return errors.Errorf(
+					"lock %q is held by %s (%s, pid %d) since %s\n\n"+
+						"If that command is no longer running, release the lock with: ago lock break %s",
+					scope, l.Owner, l.Command, l.PID, l.CreatedAt.Format(time.RFC3339), scope)
+			}
+		}
+	}
+
+	return errors.Errorf("lock %q is held by another command\n\n"+
+		"If that command is no longer running, release the lock with: ago lock break %s", scope, scope)
+}
+
+func lockOwner() string {
+	name := "unknown"
+	if u, err := user.Current(); err == nil {
+		name = u.Username
+	}
+	if host, err := os.Hostname(); err == nil {
+		name += "@" + host
+	}
+	return name
+}
+
+// newLocker returns the locker of the configured backend. The SSM backend stores locks in the
+// primary region of the project account that the profile belongs to.
+func newLocker(cfg config.Config, profile, region, qualifier string) locker {
+	if cfg.Inner.Locks() == config.LockBackendSSM {
+		return &ssmLocker{profile: profile, region: region, qualifier: qualifier}
+	}
+	return &fileLocker{dir: filepath.Join(cfg.ProjectDir, lockLocalDir)}
+}
+
+// fileLocker holds locks as files that are created exclusively.
+type fileLocker struct {
+	dir string
+}
+
+func (l *fileLocker) Acquire(_ context.Context, info lockInfo) error {
+	// A dry run still fails on a held lock, but does not take one.
+	if cmdexec.DryRun() {
+		if _, err := os.Stat(l.path(info.Scope)); err == nil {
+			return errLockHeld
+		}
+		cmdexec.ReportDryRun("would create lock %s", l.path(info.Scope))
+		return nil
+	}
+
+	if err := os.MkdirAll(l.dir, 0o755); err != nil { //nolint:gosec // lock directory needs to be readable
+		return errors.Wrap(err, "failed to create lock directory")
+	}
+	// Keep the locks out of version control and 'ago check uncommitted-changes'.
+	if err := os.WriteFile(filepath.Join(l.dir, ".gitignore"), []byte("*\n"), 0o644); err != nil { //nolint:gosec // ignore file needs to be readable
+		return errors.Wrap(err, "failed to write lock directory .gitignore")
+	}
+
+	data, err := json.MarshalIndent(info, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal lock")
+	}
+
+	f, err := os.OpenFile(l.path(info.Scope), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644) //nolint:gosec // lock file needs to be readable
+	if err != nil {
+		if os.IsExist(err) {
+			return errLockHeld
+		}
+		return errors.Wrap(err, "failed to create lock file")
+	}
+	defer f.Close()
+
+	if _, err := f.Write(data); err != nil {
+		return errors.Wrap(err, "failed to write lock file")
+	}
+	return nil
+}
+
+func (l *fileLocker) Release(_ context.Context, scope string) error {
+	if err := cmdexec.Remove(l.path(scope)); err != nil && !os.IsNotExist(err) {
+		return errors.Wrap(err, "failed to remove lock file")
+	}
+	return nil
+}
+
+func (l *fileLocker) List(_ context.Context) ([]lockInfo, error) {
+	paths, err := filepath.Glob(filepath.Join(l.dir, "*.json"))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list lock files")
+	}
+
+	locks := make([]lockInfo, 0, len(paths))
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to read lock file")
+		}
+		var info lockInfo
+		if err := json.Unmarshal(data, &info); err != nil {
+			return nil, errors.Wrapf(err, "failed to parse lock file %s", path)
+		}
+		locks = append(locks, info)
+	}
+	return locks, nil
+}
+
+func (l *fileLocker) path(scope string) string {
+	return filepath.Join(l.dir, scope+".json")
+}
+
+// ssmLocker holds locks as SSM parameters under /{qualifier}/locks. Creating a parameter that
+// already exists fails, which makes acquiring a lock atomic.
+type ssmLocker struct {
+	profile   string
+	region    string
+	qualifier string
+
+	// newClients creates the clients, awsapi.New when nil. Tests substitute fakes.
+	newClients func(ctx context.Context, profile, region string) (*awsapi.Clients, error)
+}
+
+func (l *ssmLocker) clients(ctx context.Context) (*awsapi.Clients, error) {
+	if l.newClients != nil {
+		return l.newClients(ctx, l.profile, l.region)
+	}
+	return awsapi.New(ctx, l.profile, l.region)
+}
+
+func (l *ssmLocker) Acquire(ctx context.Context, info lockInfo) error {
+	data, err := json.Marshal(info)
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal lock")
+	}
+
+	clients, err := l.clients(ctx)
+	if err != nil {
+		return err
+	}
+
+	if _, err := clients.SSM.PutParameter(ctx, &ssm.PutParameterInput{
+		Name:  aws.String(l.name(info.Scope)),
+		Type:  ssmtypes.ParameterTypeString,
+		Value: aws.String(string(data)),
+	}); err != nil {
+		if awsapi.IsErrorCode(err, "ParameterAlreadyExists") {
+			return errLockHeld
+		}
+		return errors.Wrap(err, "failed to create lock parameter")
+	}
+	return nil
+}
+
+func (l *ssmLocker) Release(ctx context.Context, scope string) error {
+	clients, err := l.clients(ctx)
+	if err != nil {
+		return err
+	}
+
+	if _, err := clients.SSM.DeleteParameter(ctx, &ssm.DeleteParameterInput{
+		Name: aws.String(l.name(scope)),
+	}); err != nil && !awsapi.IsErrorCode(err, "ParameterNotFound") {
+		return errors.Wrap(err, "failed to delete lock parameter")
+	}
+	return nil
+}
+
+func (l *ssmLocker) List(ctx context.Context) ([]lockInfo, error) {
+	clients, err := l.clients(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var locks []lockInfo
+	pages := ssm.NewGetParametersByPathPaginator(clients.SSM, &ssm.GetParametersByPathInput{
+		Path: aws.String("/" + l.qualifier + "/locks"),
+	})
+	for pages.HasMorePages() {
+		page, err := pages.NextPage(ctx)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to list lock parameters")
+		}
+		for _, param := range page.Parameters {
+			var info lockInfo
+			if err := json.Unmarshal([]byte(aws.ToString(param.Value)), &info); err != nil {
+				return nil, errors.Wrap(err, "failed to parse lock parameter")
+			}
+			locks = append(locks, info)
+		}
+	}
+	return locks, nil
+}
+
+func (l *ssmLocker) name(scope string) string {
+	return "/" + l.qualifier + "/locks/" + scope
+}
+
+type lockOptions struct {
+	Scope   string
+	Profile string
+	Output  io.Writer
+}
+
+func runLockStatus(ctx context.Context, cmd *cli.Command, cfg config.Config) error {
+	return doLockStatus(ctx, cfg, lockOptions{
+		Profile: cmd.String("profile"),
+		Output:  os.Stdout,
+	})
+}
+
+func runLockBreak(ctx context.Context, cmd *cli.Command, cfg config.Config) error {
+	scope := cmd.Args().First()
+	if scope == "" {
+		return errors.New("scope argument is required")
+	}
+
+	return doLockBreak(ctx, cfg, lockOptions{
+		Scope:   scope,
+		Profile: cmd.String("profile"),
+		Output:  os.Stdout,
+	})
+}
+
+func doLockStatus(ctx context.Context, cfg config.Config, opts lockOptions) error {
+	lk, err := projectLocker(cfg, opts.Profile)
+	if err != nil {
+		return err
+	}
+
+	locks, err := lk.List(ctx)
+	if err != nil {
+		return err
+	}
+
+	if len(locks) == 0 {
+		writeOutputf(opts.Output, "No locks are held.\n")
+		return nil
+	}
+
+	slices.SortFunc(locks, func(a, b lockInfo) int { return strings.Compare(a.Scope, b.Scope) })
+	for _, l := range locks {
+		writeOutputf(opts.Output, "%-24s %-10s %s (pid %d) since %s\n",
+			l.Scope, l.Command, l.Owner, l.PID, l.CreatedAt.Format(time.RFC3339))
+	}
+	return nil
+}
+
+func doLockBreak(ctx context.Context, cfg config.Config, opts lockOptions) error {
+	lk, err := projectLocker(cfg, opts.Profile)
+	if err != nil {
+		return err
+	}
+
+	if err := lk.Release(ctx, opts.Scope); err != nil {
+		return err
+	}
+
+	writeOutputf(opts.Output, "Released lock %q\n", opts.Scope)
+	return nil
+}
+
+// projectLocker returns the locker for the lock commands, which are not tied to a deployment.
+func projectLocker(cfg config.Config, profileFlag string) (locker, error) {
+	if cfg.Inner.Locks() != config.LockBackendSSM {
+		return newLocker(cfg, "", "", ""), nil
+	}
+
+	cdk, err := loadCDKContext(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	profile, err := resolveAWSProfile(profileFlag, func() (string, error) { return getCDKProfile(cdk.Context) })
+	if err != nil {
+		return nil, err
+	}
+
+	if err := cdk.Require("primary-region"); err != nil {
+		return nil, err
+	}
+	primaryRegion := cdk.PrimaryRegion
+
+	return newLocker(cfg, profile, primaryRegion, cdk.Qualifier), nil
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/advdv/ago/cmd/ago/internal/awsapi"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	ssmtypes "github.com/aws/aws-sdk-go-v2/service/ssm/types"
+	"github.com/aws/smithy-go"
+	"github.com/cockroachdb/errors"
+)
+
+func TestDeploymentLockScopes(t *testing.T) {
+	t.Parallel()
+
+	got := deploymentLockScopes([]string{"Stag", "Dev", "Stag"})
+	want := []string{"deployment-Dev", "deployment-Stag"}
+	if !slices.Equal(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+}
+
+func TestWithLocks(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	lk := &fileLocker{dir: t.TempDir()}
+	var buf bytes.Buffer
+
+	err := withLocks(ctx, lk, &buf, "deploy", []string{"deployment-Dev", "deployment-Stag"}, func() error {
+		locks, err := lk.List(ctx)
+		if err != nil {
+			return err
+		}
+		if len(locks) != 2 {
+			t.Errorf("expected 2 held locks, got %v", locks)
+		}
+
+		err = withLocks(ctx, lk, &buf, "destroy", []string{"deployment-Stag"}, func() error {
+			t.Error("expected conflicting command not to run")
+			return nil
+		})
+		if err == nil || !strings.Contains(err.Error(), "ago lock break deployment-Stag") {
+			t.Errorf("expected lock held error, got %v", err)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	locks, err := lk.List(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(locks) != 0 {
+		t.Errorf("expected locks to be released, got %v", locks)
+	}
+
+	failure := errors.New("deploy failed")
+	err = withLocks(ctx, lk, &buf, "deploy", []string{"deployment-Dev"}, func() error { return failure })
+	if !errors.Is(err, failure) {
+		t.Errorf("expected deploy error, got %v", err)
+	}
+	if locks, _ := lk.List(ctx); len(locks) != 0 {
+		t.Errorf("expected locks to be released after failure, got %v", locks)
+	}
+}
+
+// fakeSSM stores parameters in memory and fails like SSM on existing and missing parameters.
+type fakeSSM struct {
+	awsapi.SSM
+	params map[string]string
+}
+
+func (f *fakeSSM) PutParameter(
+	_ context.Context, in *ssm.PutParameterInput, _ ...func(*ssm.Options),
+) (*ssm.PutParameterOutput, error) {
+	if _, ok := f.params[aws.ToString(in.Name)]; ok {
+		return nil, &smithy.GenericAPIError{Code: "ParameterAlreadyExists"}
+	}
+	f.params[aws.ToString(in.Name)] = aws.ToString(in.Value)
+	return &ssm.PutParameterOutput{}, nil
+}
+
+func (f *fakeSSM) DeleteParameter(
+	_ context.Context, in *ssm.DeleteParameterInput, _ ...func(*ssm.Options),
+) (*ssm.DeleteParameterOutput, error) {
+	if _, ok := f.params[aws.ToString(in.Name)]; !ok {
+		return nil, &smithy.GenericAPIError{Code: "ParameterNotFound"}
+	}
+	delete(f.params, aws.ToString(in.Name))
+	return &ssm.DeleteParameterOutput{}, nil
+}
+
+func (f *fakeSSM) GetParametersByPath(
+	_ context.Context, in *ssm.GetParametersByPathInput, _ ...func(*ssm.Options),
+) (*ssm.GetParametersByPathOutput, error) {
+	out := &ssm.GetParametersByPathOutput{}
+	for name, value := range f.params {
+		if strings.HasPrefix(name, aws.ToString(in.Path)+"/") {
+			out.Parameters = append(out.Parameters, ssmtypes.Parameter{Name: aws.String(name), Value: aws.String(value)})
+		}
+	}
+	return out, nil
+}
+
+func TestSSMLocker(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	client := &fakeSSM{params: map[string]string{}}
+	lk := &ssmLocker{
+		profile: "myapp-admin", region: "us-east-1", qualifier: "myapp",
+		newClients: func(context.Context, string, string) (*awsapi.Clients, error) {
+			return &awsapi.Clients{SSM: client}, nil
+		},
+	}
+
+	if err := lk.Acquire(ctx, lockInfo{Scope: "deployment-Dev", Owner: "adam"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, ok := client.params["/myapp/locks/deployment-Dev"]; !ok {
+		t.Errorf("expected lock parameter, got %v", client.params)
+	}
+	if err := lk.Acquire(ctx, lockInfo{Scope: "deployment-Dev"}); !errors.Is(err, errLockHeld) {
+		t.Errorf("expected errLockHeld, got %v", err)
+	}
+
+	locks, err := lk.List(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(locks) != 1 || locks[0].Owner != "adam" {
+		t.Errorf("unexpected locks %v", locks)
+	}
+
+	if err := lk.Release(ctx, "deployment-Dev"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := lk.Release(ctx, "deployment-Dev"); err != nil {
+		t.Errorf("expected releasing a missing lock to succeed, got %v", err)
+	}
+}
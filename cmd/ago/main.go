@@ -3,8 +3,12 @@ package main
 import (
 	"context"
 	"fmt"
+	"io"
 	"os"
 
+	"github.com/advdv/ago/cmd/ago/internal/awsapi"
+	"github.com/advdv/ago/cmd/ago/internal/cmdexec"
+	"github.com/advdv/ago/cmd/ago/internal/warnings"
 	"github.com/urfave/cli/v3"
 )
 
@@ -16,17 +20,123 @@ func main() {
 		Name:    "ago",
 		Usage:   "Development task runner for the ago project",
 		Version: Version,
-		Commands: []*cli.Command{
+		Flags: append(globalAWSFlags(), &cli.BoolFlag{
+			Name:    "cache-tools",
+			Usage:   "Resolve mise tools once per run and execute them directly instead of through 'mise exec'",
+			Sources: cli.EnvVars("AGO_CACHE_TOOLS"),
+		}, &cli.BoolFlag{
+			Name: "dry-run",
+			Usage: "Print the commands, AWS API calls and file writes that would change state instead of " +
+				"performing them; reads still happen",
+			Sources: cli.EnvVars("AGO_DRY_RUN"),
+		}, &cli.BoolFlag{
+			Name:    "verbose",
+			Usage:   "Print every external command to stderr, with its exit code and duration",
+			Sources: cli.EnvVars("AGO_VERBOSE"),
+		}, &cli.BoolFlag{
+			Name:    "audit-log",
+			Usage:   "Record every external command, with its directory, duration and exit code, in " + cmdexec.AuditLogDir,
+			Sources: cli.EnvVars("AGO_AUDIT_LOG"),
+		}, outputFormatFlag(), &cli.StringSliceFlag{
+			Name:    "acknowledge",
+			Usage:   "Acknowledge a warning by its code: it is not printed and the operation it guards proceeds",
+			Sources: cli.EnvVars("AGO_ACKNOWLEDGE"),
+		}),
+		Before: func(ctx context.Context, cmd *cli.Command) (context.Context, error) {
+			if cmd.Bool("cache-tools") {
+				cmdexec.EnableToolCache()
+			}
+			if cmd.Bool("verbose") {
+				cmdexec.EnableVerbose(os.Stderr)
+			}
+			if cmd.Bool("audit-log") {
+				cmdexec.EnableAuditLog()
+			}
+			if cmd.Bool("dry-run") {
+				report, _ := commandOutput(cmd)
+				cmdexec.EnableDryRun(report)
+				awsapi.EnableDryRun(report)
+			}
+			return ctx, nil
+		},
+		Commands: append([]*cli.Command{
 			backendCmd(),
 			infraCmd(),
 			checkCmd(),
 			devCmd(),
 			initCmd(),
-		},
+			setupCmd(),
+			onboardCmd(),
+			toolsCmd(),
+			reportCmd(),
+			contextCmd(),
+			lockCmd(),
+			authCmd(),
+			provenanceCmd(),
+			queueCmd(),
+			wsCmd(),
+			jobsCmd(),
+			statusCmd(),
+			frontendCmd(),
+			secretsCmd(),
+			ciCmd(),
+			eventsCmd(),
+			metaCmd(),
+			configCmd(),
+		}, deprecatedCmds(os.Stderr)...),
 	}
+	withConfigDefaults(cmd.Commands)
 
 	if err := cmd.Run(context.Background(), os.Args); err != nil {
 		fmt.Fprintln(os.Stderr, err)
 		os.Exit(1)
 	}
 }
+
+// commandRename records a command that moved to a new place in the command tree. The old name
+// keeps working as a hidden command that warns before running the command at its new place.
+type commandRename struct {
+	Old     string
+	New     string
+	Command func() *cli.Command
+}
+
+// commandRenames is the mapping table of renamed commands. Remove an entry once users had a
+// release to move to the new name.
+var commandRenames = []commandRename{
+	{Old: "cdk", New: "infra cdk", Command: cdkCmd},
+	{Old: "tf", New: "infra tf", Command: tfCmd},
+	{Old: "org", New: "infra org", Command: orgCmd},
+}
+
+// deprecatedCmds returns a hidden top-level command for every renamed command. Each is built
+// from the same constructor as the command at its new place, so no command code is duplicated.
+func deprecatedCmds(w io.Writer) []*cli.Command {
+	cmds := make([]*cli.Command, 0, len(commandRenames))
+	for _, rename := range commandRenames {
+		cmd := rename.Command()
+		cmd.Name = rename.Old
+		cmd.Hidden = true
+		cmd.Usage = fmt.Sprintf("Deprecated: use 'ago %s'", rename.New)
+
+		before := cmd.Before
+		cmd.Before = func(ctx context.Context, cmd *cli.Command) (context.Context, error) {
+			warn, err := warnings.New(w, cmd.StringSlice("acknowledge"))
+			if err != nil {
+				return ctx, err
+			}
+			warn.Report(warnings.Warning{
+				Code:     warnings.DeprecatedCommand,
+				Severity: warnings.Warn,
+				Summary:  fmt.Sprintf("'ago %s' is deprecated and will be removed, use 'ago %s' instead", rename.Old, rename.New),
+			})
+			if before != nil {
+				return before(ctx, cmd)
+			}
+			return ctx, nil
+		}
+
+		cmds = append(cmds, cmd)
+	}
+	return cmds
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/urfave/cli/v3"
+)
+
+func TestCommandRenames(t *testing.T) {
+	t.Parallel()
+
+	root := &cli.Command{Commands: []*cli.Command{infraCmd()}}
+
+	for _, rename := range commandRenames {
+		t.Run(rename.Old, func(t *testing.T) {
+			t.Parallel()
+
+			cmd := root
+			for name := range strings.FieldsSeq(rename.New) {
+				if cmd = cmd.Command(name); cmd == nil {
+					t.Fatalf("new command 'ago %s' does not exist", rename.New)
+				}
+			}
+			if root.Command(rename.Old) != nil {
+				t.Errorf("old name %q is still a regular command", rename.Old)
+			}
+		})
+	}
+}
+
+func TestDeprecatedCmds(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	for _, cmd := range deprecatedCmds(&buf) {
+		if !cmd.Hidden {
+			t.Errorf("deprecated command %q is not hidden", cmd.Name)
+		}
+
+		buf.Reset()
+		if _, err := cmd.Before(context.Background(), cmd); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !strings.Contains(buf.String(), "'ago "+cmd.Name+"' is deprecated") {
+			t.Errorf("expected deprecation warning for %q, got %q", cmd.Name, buf.String())
+		}
+	}
+}
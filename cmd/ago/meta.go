@@ -0,0 +1,345 @@
+package main
+
+import (
+	"context"
+	"io"
+
+	"github.com/advdv/ago/agcdk/agcdkapi"
+	"github.com/advdv/ago/agcdk/agcdkdb"
+	"github.com/advdv/ago/agcdk/agcdkqueue"
+	"github.com/advdv/ago/agcdk/agcdkstatic"
+	"github.com/advdv/ago/agcdk/agcdkwebapi"
+	"github.com/advdv/ago/agcdkutil"
+	"github.com/advdv/ago/cmd/ago/internal/ops"
+	"github.com/cockroachdb/errors"
+	"github.com/urfave/cli/v3"
+)
+
+func metaCmd() *cli.Command {
+	return &cli.Command{
+		Name:  "meta",
+		Usage: "Describe the conventions of ago as data for other tools",
+		Commands: []*cli.Command{
+			{
+				Name: "naming",
+				Usage: "Print the naming conventions for stacks, profiles, secrets, parameters and outputs " +
+					"(see cmd/ago/naming.json)",
+				Action: func(_ context.Context, cmd *cli.Command) error {
+					progress, result := commandOutput(cmd)
+					return doMetaNaming(progress, result)
+				},
+			},
+			{
+				Name:      "schema",
+				Usage:     "Print the JSON Schema of .ago.yml, deployments.yaml or the CDK context (see cmd/ago/schemas)",
+				ArgsUsage: "<ago|deployments|cdk-context>",
+				Action: func(_ context.Context, cmd *cli.Command) error {
+					name := cmd.Args().First()
+					if name == "" {
+						return errors.New("schema argument is required")
+					}
+					progress, result := commandOutput(cmd)
+					return doMetaSchema(progress, result, name)
+				},
+			},
+		},
+	}
+}
+
+// Example values that the naming conventions are applied to.
+const (
+	namingExampleQualifier  = "myapp"
+	namingExampleRegion     = "eu-central-1"
+	namingExampleDeployment = "Dev"
+	namingExampleUsername   = "Alice"
+	namingExampleQueue      = "order-events"
+	namingExampleDatabase   = "main"
+	namingExampleWebSocket  = "chat"
+	namingExampleWebAPI     = "api"
+	namingExampleStaticSite = "web"
+)
+
+// namingConvention describes how ago names one kind of resource. Format holds {placeholders} for
+// the values the name is made of, Case tells how the assembled name is cased, and Example is the
+// name that ago itself produces for the example values.
+type namingConvention struct {
+	Kind        string `json:"kind"`
+	Format      string `json:"format"`
+	Case        string `json:"case,omitempty"`
+	Example     string `json:"example"`
+	Description string `json:"description"`
+}
+
+// namingReport holds all naming conventions of ago. The checked-in naming.json is this report
+// for tools in other languages; TestNamingArtifact keeps the two in sync.
+type namingReport struct {
+	RegionIdents      map[string]string  `json:"region_idents"`
+	StackNames        []namingConvention `json:"stack_names"`
+	Profiles          []namingConvention `json:"profiles"`
+	Secrets           []namingConvention `json:"secrets"`
+	Parameters        []namingConvention `json:"parameters"`
+	OutputKeys        []namingConvention `json:"output_keys"`
+	Groups            []namingConvention `json:"groups"`
+	DeploymentTagKey  string             `json:"deployment_tag_key"`
+	SharedDeployment  string             `json:"shared_deployment"`
+	PreservedExportID string             `json:"preserved_export_id_prefix"`
+}
+
+// namingConventions builds the report from the functions that ago uses to name things, so the
+// examples cannot drift from what ago produces.
+func namingConventions() namingReport {
+	regionIdent := agcdkutil.RegionIdentFor(namingExampleRegion)
+	sharedStack := agcdkutil.SharedStackName(namingExampleQualifier, regionIdent)
+	locker := &ssmLocker{qualifier: "{qualifier}"}
+
+	return namingReport{
+		RegionIdents: agcdkutil.RegionIdents,
+		StackNames: []namingConvention{
+			{
+				Kind:        "shared",
+				Format:      "{qualifier}-{regionIdent}Shared",
+				Case:        "lowerCamel of {qualifier}-{regionIdent}",
+				Example:     sharedStack,
+				Description: "Stack with the resources that all deployments in a region share",
+			},
+			{
+				Kind:    "deployment",
+				Format:  "{qualifier}-{regionIdent}{deployment}",
+				Case:    "lowerCamel of {qualifier}-{regionIdent}",
+				Example: agcdkutil.DeploymentStackName(namingExampleQualifier, regionIdent, namingExampleDeployment),
+				Description: "Stack of a single deployment in a region; deployment identifiers start with an " +
+					"upper-case letter",
+			},
+		},
+		Profiles: []namingConvention{
+			{
+				Kind:        "deployer",
+				Format:      ops.DeployerProfileName("{qualifier}", "{username}"),
+				Case:        "lower-case {username}",
+				Example:     ops.DeployerProfileName(namingExampleQualifier, namingExampleUsername),
+				Description: "AWS CLI profile with the access key of a deployer or dev deployer",
+			},
+		},
+		Secrets: []namingConvention{
+			{
+				Kind:        "deployer",
+				Format:      ops.DeployerSecretName("{qualifier}", "{username}"),
+				Example:     ops.DeployerSecretName(namingExampleQualifier, namingExampleUsername),
+				Description: "Secrets Manager secret with the access key of a deployer",
+			},
+			{
+				Kind:        "dev-deployer",
+				Format:      ops.DevDeployerSecretName("{qualifier}", "{username}"),
+				Example:     ops.DevDeployerSecretName(namingExampleQualifier, namingExampleUsername),
+				Description: "Secrets Manager secret with the access key of a dev deployer",
+			},
+			{
+				Kind:        "main",
+				Format:      ops.MainSecretName("{qualifier}"),
+				Example:     ops.MainSecretName(namingExampleQualifier),
+				Description: "Secrets Manager secret of the project, replicated to the secondary regions",
+			},
+			{
+				Kind:        "deployment",
+				Format:      ops.DeploymentSecretName("{qualifier}", "{deployment}", "{name}"),
+				Example:     ops.DeploymentSecretName(namingExampleQualifier, namingExampleDeployment, "api-key"),
+				Description: "Secrets Manager secret of a deployment, managed with ago secrets",
+			},
+			{
+				Kind:        "shared",
+				Format:      ops.SharedSecretName("{qualifier}", "{name}"),
+				Example:     ops.SharedSecretName(namingExampleQualifier, "api-key"),
+				Description: "Secrets Manager secret shared by all deployments, managed with ago secrets --shared",
+			},
+			{
+				Kind:        "database",
+				Format:      agcdkdb.SecretName("{qualifier}", "{deployment}", "{database}"),
+				Example:     agcdkdb.SecretName(namingExampleQualifier, namingExampleDeployment, namingExampleDatabase),
+				Description: "Secrets Manager secret with the rotated credentials of a database created by agcdkdb",
+			},
+		},
+		Parameters: []namingConvention{
+			{
+				Kind:        "output-registry",
+				Format:      agcdkutil.OutputRegistryParameterName("{stackName}"),
+				Example:     agcdkutil.OutputRegistryParameterName(sharedStack),
+				Description: "SSM parameter with the outputs of a stack as a JSON object of output key to value",
+			},
+			{
+				Kind:        "lock",
+				Format:      locker.name("{scope}"),
+				Example:     (&ssmLocker{qualifier: namingExampleQualifier}).name(lockScopeBootstrap),
+				Description: "SSM parameter that holds a lock in the ssm locks backend",
+			},
+		},
+		OutputKeys: []namingConvention{
+			{
+				Kind:        "hosted-zone-name-servers",
+				Format:      agcdkutil.NameServersOutputKey,
+				Example:     agcdkutil.NameServersOutputKey,
+				Description: "Name servers of the hosted zone in the primary shared stack, created by agcdkdns",
+			},
+			{
+				Kind:        "repository-uri",
+				Format:      agcdkutil.RepositoryURIOutputKey,
+				Example:     agcdkutil.RepositoryURIOutputKey,
+				Description: "URI of the main ECR repository in the primary shared stack, created by agcdkrepos",
+			},
+			{
+				Kind:        "event-bus-name",
+				Format:      agcdkutil.EventBusNameOutputKey,
+				Example:     agcdkutil.EventBusNameOutputKey,
+				Description: "Name of the event bus created by agcdkevents",
+			},
+			{
+				Kind:        "event-archive-name",
+				Format:      agcdkutil.EventArchiveNameOutputKey,
+				Example:     agcdkutil.EventArchiveNameOutputKey,
+				Description: "Name of the event archive created by agcdkevents",
+			},
+			{
+				Kind:        "queue-url",
+				Format:      "Queue{queue}URL",
+				Case:        "Camel of {queue}",
+				Example:     agcdkqueue.URLOutputKey(namingExampleQueue),
+				Description: "URL of a queue created by agcdkqueue",
+			},
+			{
+				Kind:        "queue-dlq-url",
+				Format:      "Queue{queue}DLQURL",
+				Case:        "Camel of {queue}",
+				Example:     agcdkqueue.DeadLetterURLOutputKey(namingExampleQueue),
+				Description: "URL of the dead-letter queue of a queue created by agcdkqueue",
+			},
+			{
+				Kind:        "database-proxy-endpoint",
+				Format:      "Database{database}ProxyEndpoint",
+				Case:        "Camel of {database}",
+				Example:     agcdkdb.ProxyEndpointOutputKey(namingExampleDatabase),
+				Description: "Proxy endpoint of a database created by agcdkdb",
+			},
+			{
+				Kind:        "database-secret-arn",
+				Format:      "Database{database}SecretARN",
+				Case:        "Camel of {database}",
+				Example:     agcdkdb.SecretARNOutputKey(namingExampleDatabase),
+				Description: "ARN of the credentials secret of a database created by agcdkdb",
+			},
+			{
+				Kind:        "database-cluster-arn",
+				Format:      "Database{database}ClusterARN",
+				Case:        "Camel of {database}",
+				Example:     agcdkdb.ClusterARNOutputKey(namingExampleDatabase),
+				Description: "ARN of the cluster of a database created by agcdkdb, used by the Data API",
+			},
+			{
+				Kind:        "jobs-schedule-group",
+				Format:      agcdkutil.JobsScheduleGroupOutputKey,
+				Example:     agcdkutil.JobsScheduleGroupOutputKey,
+				Description: "Schedule group of the jobs created by agcdkjobs in a deployment stack",
+			},
+			{
+				Kind:        "webapi-url",
+				Format:      "WebAPI{api}URL",
+				Case:        "Camel of {api}",
+				Example:     agcdkwebapi.URLOutputKey(namingExampleWebAPI),
+				Description: "URL of an HTTP API created by agcdkwebapi",
+			},
+			{
+				Kind:        "websocket-url",
+				Format:      "WebSocket{api}URL",
+				Case:        "Camel of {api}",
+				Example:     agcdkapi.URLOutputKey(namingExampleWebSocket),
+				Description: "URL that clients connect to of a WebSocket API created by agcdkapi",
+			},
+			{
+				Kind:        "websocket-callback-url",
+				Format:      "WebSocket{api}CallbackURL",
+				Case:        "Camel of {api}",
+				Example:     agcdkapi.CallbackURLOutputKey(namingExampleWebSocket),
+				Description: "Management API URL of a WebSocket API created by agcdkapi",
+			},
+			{
+				Kind:        "websocket-connections-table",
+				Format:      "WebSocket{api}ConnectionsTable",
+				Case:        "Camel of {api}",
+				Example:     agcdkapi.ConnectionsTableOutputKey(namingExampleWebSocket),
+				Description: "Connections table of a WebSocket API created by agcdkapi",
+			},
+			{
+				Kind:        "static-bucket",
+				Format:      "Static{site}Bucket",
+				Case:        "Camel of {site}",
+				Example:     agcdkstatic.BucketOutputKey(namingExampleStaticSite),
+				Description: "Bucket of a static site created by agcdkstatic",
+			},
+			{
+				Kind:        "static-distribution-id",
+				Format:      "Static{site}DistributionID",
+				Case:        "Camel of {site}",
+				Example:     agcdkstatic.DistributionIDOutputKey(namingExampleStaticSite),
+				Description: "CloudFront distribution of a static site created by agcdkstatic",
+			},
+			{
+				Kind:        "static-url",
+				Format:      "Static{site}URL",
+				Case:        "Camel of {site}",
+				Example:     agcdkstatic.URLOutputKey(namingExampleStaticSite),
+				Description: "URL of a static site created by agcdkstatic",
+			},
+		},
+		Groups: []namingConvention{
+			{
+				Kind:        "deployers",
+				Format:      ops.DeployersGroupName("{qualifier}"),
+				Example:     ops.DeployersGroupName(namingExampleQualifier),
+				Description: "IAM group of the deployers with full deployer permissions",
+			},
+		},
+		DeploymentTagKey:  agcdkutil.DeploymentSessionTagKey,
+		SharedDeployment:  agcdkutil.SharedDeploymentTag,
+		PreservedExportID: agcdkutil.PreservedExportIDPrefix,
+	}
+}
+
+func doMetaNaming(progress, result io.Writer) error {
+	report := namingConventions()
+	if result != nil {
+		return writeResult(result, report)
+	}
+
+	writeOutputf(progress, "Region identifiers:\n")
+	for _, region := range agcdkutil.AllKnownRegions() {
+		writeOutputf(progress, "  %-16s %s\n", region, report.RegionIdents[region])
+	}
+
+	sections := []struct {
+		title       string
+		conventions []namingConvention
+	}{
+		{"Stack names", report.StackNames},
+		{"Profiles", report.Profiles},
+		{"Secrets", report.Secrets},
+		{"SSM parameters", report.Parameters},
+		{"Output keys", report.OutputKeys},
+		{"IAM groups", report.Groups},
+	}
+	for _, section := range sections {
+		writeOutputf(progress, "\n%s:\n", section.title)
+		for _, c := range section.conventions {
+			format := c.Format
+			if c.Case != "" {
+				format += " (" + c.Case + ")"
+			}
+			writeOutputf(progress, "  %-18s %s\n", c.Kind, format)
+			writeOutputf(progress, "  %-18s e.g. %s\n", "", c.Example)
+			writeOutputf(progress, "  %-18s %s\n", "", c.Description)
+		}
+	}
+
+	writeOutputf(progress, "\nDeploy role session tag: %s (%s for shared stacks)\n",
+		report.DeploymentTagKey, report.SharedDeployment)
+	writeOutputf(progress, "Preserved export output IDs start with: %s\n", report.PreservedExportID)
+
+	return nil
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestNamingArtifact(t *testing.T) {
+	t.Parallel()
+
+	var result bytes.Buffer
+	if err := doMetaNaming(nil, &result); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile("naming.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got, want any
+	if err := json.Unmarshal(result.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(data, &want); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("naming.json is out of date, regenerate it with: ago --output json meta naming > cmd/ago/naming.json")
+	}
+}
+
+func TestNamingExamples(t *testing.T) {
+	t.Parallel()
+
+	report := namingConventions()
+	examples := map[string]string{
+		"shared":     "myappEuc1Shared",
+		"deployment": "myappEuc1Dev",
+	}
+	for _, c := range report.StackNames {
+		if c.Example != examples[c.Kind] {
+			t.Errorf("%s stack name example = %q, want %q", c.Kind, c.Example, examples[c.Kind])
+		}
+	}
+	if got := report.Profiles[0].Example; got != "myapp-alice" {
+		t.Errorf("deployer profile example = %q, want %q", got, "myapp-alice")
+	}
+}
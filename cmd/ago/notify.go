@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/advdv/ago/cmd/ago/internal/cmdexec"
+	"github.com/advdv/ago/cmd/ago/internal/config"
+	"github.com/cockroachdb/errors"
+)
+
+// withNotification runs fn and, when .ago.yml enables notifications for the command and fn ran
+// long enough, sends a desktop notification that says whether it finished or failed. A
+// notification that cannot be sent is reported on output but does not fail the command.
+func withNotification(
+	ctx context.Context, cfg config.Config, output io.Writer, command, target string, fn func() error,
+) error {
+	start := time.Now()
+	err := fn()
+
+	elapsed := time.Since(start)
+	if cmdexec.DryRun() || !cfg.Inner.Notifications.Notify(command, elapsed) {
+		return err
+	}
+
+	title, message := notificationText(command, target, elapsed, err)
+	if nerr := sendDesktopNotification(context.WithoutCancel(ctx), title, message); nerr != nil {
+		writeOutputf(output, "Could not send desktop notification: %v\n", nerr)
+	}
+
+	return err
+}
+
+// notificationText returns the title and message of the notification for a command that ran for
+// elapsed and returned err. Target names what the command ran against, such as a deployment, and
+// may be empty.
+func notificationText(command, target string, elapsed time.Duration, err error) (title, message string) {
+	title = "ago " + command
+	if target != "" {
+		title += " " + target
+	}
+
+	elapsed = elapsed.Round(time.Second)
+	if err != nil {
+		// Only the first line, errors from cdk and the AWS CLI can span many.
+		reason, _, _ := strings.Cut(err.Error(), "\n")
+		return title + " failed", fmt.Sprintf("Failed after %s: %s", elapsed, reason)
+	}
+	return title + " finished", fmt.Sprintf("Finished in %s", elapsed)
+}
+
+// sendDesktopNotification shows a notification through osascript on macOS and notify-send on
+// Linux.
+func sendDesktopNotification(ctx context.Context, title, message string) error {
+	exec := cmdexec.NewWithDir(".").WithOutput(io.Discard, io.Discard)
+	switch runtime.GOOS {
+	case "darwin":
+		script := "display notification " + appleScriptString(message) + " with title " + appleScriptString(title)
+		return errors.Wrap(exec.Run(ctx, "osascript", "-e", script), "osascript failed")
+	case "linux":
+		return errors.Wrap(exec.Run(ctx, "notify-send", "--app-name=ago", title, message), "notify-send failed")
+	default:
+		return errors.Newf("desktop notifications are not supported on %s", runtime.GOOS)
+	}
+}
+
+// appleScriptString quotes s as an AppleScript string literal.
+func appleScriptString(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
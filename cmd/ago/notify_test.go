@@ -0,0 +1,30 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/cockroachdb/errors"
+)
+
+func TestNotificationText(t *testing.T) {
+	t.Parallel()
+
+	title, message := notificationText("deploy", "Dev1", 2*time.Minute+400*time.Millisecond, nil)
+	if title != "ago deploy Dev1 finished" || message != "Finished in 2m0s" {
+		t.Errorf("unexpected notification %q: %q", title, message)
+	}
+
+	title, message = notificationText("bootstrap", "", 90*time.Second, errors.New("stack failed\ndetails"))
+	if title != "ago bootstrap failed" || message != "Failed after 1m30s: stack failed" {
+		t.Errorf("unexpected notification %q: %q", title, message)
+	}
+}
+
+func TestAppleScriptString(t *testing.T) {
+	t.Parallel()
+
+	if got := appleScriptString(`say "hi" \ bye`); got != `"say \"hi\" \\ bye"` {
+		t.Errorf("unexpected AppleScript string %s", got)
+	}
+}
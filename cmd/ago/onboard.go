@@ -0,0 +1,320 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"text/template"
+
+	"github.com/advdv/ago/agcdkutil"
+	"github.com/advdv/ago/cmd/ago/internal/awsapi"
+	"github.com/advdv/ago/cmd/ago/internal/cmdexec"
+	"github.com/advdv/ago/cmd/ago/internal/config"
+	"github.com/advdv/ago/cmd/ago/internal/ops"
+	"github.com/cockroachdb/errors"
+	"github.com/urfave/cli/v3"
+)
+
+var onboardingTemplate = template.Must(template.New("onboard.sh").Parse(`#!/usr/bin/env bash
+# Onboarding of {{.Username}} to {{.Project}}, generated by 'ago onboard {{.Username}}'.
+{{- if .AccessKey}}
+# This script contains the access key of your deployer user: delete it once it ran.
+{{- end}}
+# Run it from the root of a checkout of the project.
+set -euo pipefail
+
+# 1. Tools
+# Install mise (https://mise.jdx.dev) and activate it in your shell. It installs the tools of the
+# project:
+{{- range .Tools}}
+#   {{.Name}} {{.Version}}
+{{- end}}
+mise trust
+mise install
+
+# 2. AWS profile
+# You are a {{.Role}} of {{.Project}} and use the profile {{.Profile}}.
+{{- if .SSO.StartURL}}
+# It signs in through IAM Identity Center with the permission set {{.PermissionSet}}.
+if ! grep -q '^\[sso-session {{.SSOSession}}\]' ~/.aws/config 2>/dev/null; then
+	mkdir -p ~/.aws
+	printf '\n[sso-session %s]\nsso_start_url = %s\nsso_region = %s\nsso_registration_scopes = sso:account:access\n' \
+		'{{.SSOSession}}' '{{.SSO.StartURL}}' '{{.SSO.Region}}' >>~/.aws/config
+fi
+aws configure set sso_session '{{.SSOSession}}' --profile '{{.Profile}}'
+aws configure set sso_account_id '{{.SSO.AccountID}}' --profile '{{.Profile}}'
+aws configure set sso_role_name '{{.PermissionSet}}' --profile '{{.Profile}}'
+aws configure set region '{{.Region}}' --profile '{{.Profile}}'
+aws configure set cli_pager '' --profile '{{.Profile}}'
+aws sso login --sso-session '{{.SSOSession}}'
+{{- else if and .AccessKey .Vault}}
+# The access key is stored in aws-vault, which hands it to the profile.
+AWS_ACCESS_KEY_ID='{{.AccessKey.AccessKeyID}}' AWS_SECRET_ACCESS_KEY='{{.AccessKey.SecretAccessKey}}' \
+	aws-vault add '{{.Profile}}' --env --no-add-config
+aws configure set credential_process 'aws-vault export --format=json {{.Profile}}' --profile '{{.Profile}}'
+aws configure set region '{{.Region}}' --profile '{{.Profile}}'
+aws configure set cli_pager '' --profile '{{.Profile}}'
+{{- else if .AccessKey}}
+aws configure set aws_access_key_id '{{.AccessKey.AccessKeyID}}' --profile '{{.Profile}}'
+aws configure set aws_secret_access_key '{{.AccessKey.SecretAccessKey}}' --profile '{{.Profile}}'
+aws configure set region '{{.Region}}' --profile '{{.Profile}}'
+aws configure set cli_pager '' --profile '{{.Profile}}'
+{{- else}}
+# The access key of your deployer user is in the secret {{.SecretName}}. Ask an admin of the
+# project for a script with 'ago onboard {{.Username}} --with-credentials' if the profile is missing.
+if ! aws configure get aws_access_key_id --profile '{{.Profile}}' >/dev/null &&
+	! aws configure get credential_process --profile '{{.Profile}}' >/dev/null; then
+	echo "profile {{.Profile}} has no credentials: ask an admin of {{.Project}} to onboard you" >&2
+	exit 1
+fi
+{{- end}}
+aws sts get-caller-identity --profile '{{.Profile}}'
+
+# 3. Your deployment
+# {{.Deployment}} is the stack {{.DeploymentStack}} in {{.Region}}
+{{- if .Deployed}}, which is deployed.{{else}}, which was not deployed yet.{{end}}
+# ago finds your profile and deployment by itself, so the commands need no arguments.
+ago check doctor
+{{- if .Deployed}}
+ago infra cdk diff
+{{- else}}
+ago infra cdk deploy
+{{- end}}
+`))
+
+func onboardCmd() *cli.Command {
+	return &cli.Command{
+		Name:      "onboard",
+		Usage:     "Generate a setup script for a deployer from the live state of the project",
+		ArgsUsage: "<username>",
+		Flags: []cli.Flag{
+			&cli.BoolFlag{
+				Name:  "with-credentials",
+				Usage: "Include the access key of the deployer user in the script (IAM user auth only)",
+			},
+			&cli.StringFlag{
+				Name:  "out",
+				Usage: "Write the script to this file instead of standard output",
+			},
+		},
+		Action: config.RunWithConfig(runOnboard),
+	}
+}
+
+type onboardOptions struct {
+	Username        string
+	WithCredentials bool
+	Out             string
+	Profile         string
+	Output          io.Writer
+	Script          io.Writer
+	Result          io.Writer
+}
+
+// onboardResult is the result of onboard in the JSON output format.
+type onboardResult struct {
+	Username   string `json:"username"`
+	Profile    string `json:"profile"`
+	Deployment string `json:"deployment"`
+	Deployed   bool   `json:"deployed"`
+	Auth       string `json:"auth"`
+	Script     string `json:"script,omitempty"`
+	Out        string `json:"out,omitempty"`
+}
+
+// onboarding is what the onboarding script of a deployer is generated from.
+type onboarding struct {
+	Project         string
+	Username        string
+	Role            string
+	Profile         string
+	Region          string
+	Deployment      string
+	DeploymentStack string
+	Deployed        bool
+	Tools           []MiseTool
+	// Vault stores the access key in aws-vault rather than ~/.aws/credentials.
+	Vault      bool
+	SecretName string
+	AccessKey  *ops.DeployerAccessKey
+	// SSO is set when the deployer signs in through IAM Identity Center.
+	SSO           ops.SSOProfileConfig
+	SSOSession    string
+	PermissionSet string
+}
+
+func runOnboard(ctx context.Context, cmd *cli.Command, cfg config.Config) error {
+	username := cmd.Args().First()
+	if username == "" {
+		return errors.New("username argument is required")
+	}
+
+	// The script goes to standard output unless --out is given, so progress goes to stderr.
+	output, result := commandOutput(cmd)
+	script := io.Writer(os.Stdout)
+	if cmd.String("out") == "" {
+		output = os.Stderr
+	}
+	if result != nil {
+		script = nil
+	}
+
+	return doOnboard(ctx, cfg, onboardOptions{
+		Username:        username,
+		WithCredentials: cmd.Bool("with-credentials"),
+		Out:             cmd.String("out"),
+		Profile:         cmd.String("profile"),
+		Output:          output,
+		Script:          script,
+		Result:          result,
+	})
+}
+
+func doOnboard(ctx context.Context, cfg config.Config, opts onboardOptions) error {
+	if err := validateDeployerUsername(opts.Username); err != nil {
+		return err
+	}
+
+	cdkCtx, err := readCDKContext(cfg)
+	if err != nil {
+		return err
+	}
+	if err := cdkCtx.Require("primary-region"); err != nil {
+		return err
+	}
+	qualifier, primaryRegion := cdkCtx.Qualifier, cdkCtx.PrimaryRegion
+
+	ob := onboarding{
+		Project:    filepath.Base(cfg.ProjectDir),
+		Username:   opts.Username,
+		Profile:    ops.DeployerProfileName(qualifier, opts.Username),
+		Region:     primaryRegion,
+		Deployment: "Dev" + opts.Username,
+		Vault:      cfg.Inner.Credentials() == config.CredentialsBackendAWSVault,
+	}
+	ob.DeploymentStack = agcdkutil.DeploymentStackName(qualifier, agcdkutil.RegionIdentFor(primaryRegion), ob.Deployment)
+
+	switch {
+	case slices.Contains(cdkCtx.Deployers, opts.Username):
+		ob.Role = "deployer"
+		ob.SecretName = ops.DeployerSecretName(qualifier, opts.Username)
+		ob.PermissionSet = ops.DeployerPermissionSetName(qualifier)
+	case slices.Contains(cdkCtx.DevDeployers, opts.Username):
+		ob.Role = "dev deployer"
+		ob.SecretName = ops.DevDeployerSecretName(qualifier, opts.Username)
+		ob.PermissionSet = ops.DevDeployerPermissionSetName(qualifier, opts.Username)
+	default:
+		return errors.Errorf("%q is not a deployer of the project: run 'ago infra cdk add-deployer %s' "+
+			"and 'ago infra cdk bootstrap' first", opts.Username, opts.Username)
+	}
+	if !slices.Contains(cdkCtx.Deployments, ob.Deployment) {
+		return errors.Errorf("deployment %q not found in cdk.context.json: run 'ago infra cdk add-deployer %s'",
+			ob.Deployment, opts.Username)
+	}
+
+	auth, err := deployerAuth(cdkCtx)
+	if err != nil {
+		return err
+	}
+	if opts.WithCredentials && auth == ops.DeployerAuthSSO {
+		return errors.New("--with-credentials needs IAM user auth: SSO deployers sign in without an access key")
+	}
+
+	if ob.Tools, err = readMiseTools(cfg.ProjectDir); err != nil {
+		return err
+	}
+
+	profile, err := resolveAWSProfile(opts.Profile, func() (string, error) { return getAdminProfile(cdkCtx) })
+	if err != nil {
+		return err
+	}
+	clients, err := awsapi.New(ctx, profile, primaryRegion)
+	if err != nil {
+		return err
+	}
+
+	writeOutputf(opts.Output, "Reading the state of %s with profile %q...\n", ob.Project, profile)
+	bootstrapped, err := ops.StackExists(ctx, clients, ops.PreBootstrapStackName(qualifier))
+	if err != nil {
+		return err
+	}
+	if !bootstrapped {
+		return errors.New("the project is not bootstrapped: run 'ago infra cdk bootstrap' first")
+	}
+	if ob.Deployed, err = ops.StackExists(ctx, clients, ob.DeploymentStack); err != nil {
+		return err
+	}
+
+	switch auth {
+	case ops.DeployerAuthSSO:
+		settings, err := readDeployerSSOSettings(cdkCtx)
+		if err != nil {
+			return err
+		}
+		accountID, err := ops.AccountID(ctx, clients)
+		if err != nil {
+			return err
+		}
+		ob.SSO = ops.SSOProfileConfig{StartURL: settings.StartURL, Region: settings.Region, AccountID: accountID}
+		ob.SSOSession = ops.SSOSessionName(qualifier)
+	default:
+		// The secret only exists once bootstrap created the deployer user.
+		key, err := ops.ReadDeployerAccessKey(ctx, clients, ob.SecretName)
+		if err != nil {
+			return errors.Wrapf(err, "no access key for %s yet: run 'ago infra cdk bootstrap'", opts.Username)
+		}
+		if opts.WithCredentials {
+			ob.AccessKey = &key
+		}
+	}
+
+	script, err := renderOnboarding(ob)
+	if err != nil {
+		return err
+	}
+
+	res := onboardResult{
+		Username:   ob.Username,
+		Profile:    ob.Profile,
+		Deployment: ob.Deployment,
+		Deployed:   ob.Deployed,
+		Auth:       auth,
+		Out:        opts.Out,
+	}
+	if opts.Out != "" {
+		// A script with an access key is as secret as the key.
+		perm := os.FileMode(0o755)
+		if ob.AccessKey != nil {
+			perm = 0o700
+		}
+		if err := cmdexec.WriteFile(opts.Out, script, perm); err != nil { //nolint:gosec // the script is executable
+			return errors.Wrapf(err, "failed to write %s", opts.Out)
+		}
+		writeOutputf(opts.Output, "Wrote the onboarding script of %s to %s\n", opts.Username, opts.Out)
+	} else {
+		res.Script = string(script)
+		if opts.Script != nil {
+			if _, err := opts.Script.Write(script); err != nil {
+				return errors.Wrap(err, "failed to write the onboarding script")
+			}
+		}
+	}
+	if ob.AccessKey != nil {
+		writeOutputf(opts.Output, "The script contains the access key of %s: share it over a secure channel.\n",
+			opts.Username)
+	}
+
+	return writeResult(opts.Result, res)
+}
+
+// renderOnboarding renders the onboarding script of a deployer.
+func renderOnboarding(ob onboarding) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := onboardingTemplate.Execute(&buf, ob); err != nil {
+		return nil, errors.Wrap(err, "failed to execute onboarding template")
+	}
+	return buf.Bytes(), nil
+}
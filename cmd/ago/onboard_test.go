@@ -0,0 +1,103 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/advdv/ago/cmd/ago/internal/ops"
+)
+
+func TestRenderOnboarding(t *testing.T) {
+	t.Parallel()
+
+	base := onboarding{
+		Project:         "myapp",
+		Username:        "Eve",
+		Role:            "dev deployer",
+		Profile:         "myapp-eve",
+		Region:          "eu-central-1",
+		Deployment:      "DevEve",
+		DeploymentStack: "myappEuc1DevEve",
+		Tools:           []MiseTool{{Name: "go", Version: "1.25.1"}, {Name: "npm:aws-cdk", Version: "2.200.0"}},
+		SecretName:      "myapp/dev-deployers/Eve",
+		PermissionSet:   "myapp-dev-deployer-Eve",
+	}
+
+	for _, tc := range []struct {
+		name     string
+		modify   func(ob *onboarding)
+		contains []string
+		excludes []string
+	}{
+		{
+			name: "without credentials",
+			contains: []string{
+				"#   npm:aws-cdk 2.200.0",
+				"in the secret myapp/dev-deployers/Eve",
+				"which was not deployed yet.",
+				"ago infra cdk deploy",
+			},
+			excludes: []string{"aws_secret_access_key", "sso_session"},
+		},
+		{
+			name: "with an access key",
+			modify: func(ob *onboarding) {
+				ob.AccessKey = &ops.DeployerAccessKey{AccessKeyID: "AKIAEXAMPLE", SecretAccessKey: "c2VjcmV0"}
+				ob.Deployed = true
+			},
+			contains: []string{
+				"aws configure set aws_secret_access_key 'c2VjcmV0' --profile 'myapp-eve'",
+				"delete it once it ran",
+				"ago infra cdk diff",
+			},
+			excludes: []string{"aws-vault", "ago infra cdk deploy"},
+		},
+		{
+			name: "with an access key in aws-vault",
+			modify: func(ob *onboarding) {
+				ob.AccessKey = &ops.DeployerAccessKey{AccessKeyID: "AKIAEXAMPLE", SecretAccessKey: "c2VjcmV0"}
+				ob.Vault = true
+			},
+			contains: []string{"aws-vault add 'myapp-eve' --env --no-add-config"},
+			excludes: []string{"aws configure set aws_secret_access_key"},
+		},
+		{
+			name: "with sso",
+			modify: func(ob *onboarding) {
+				ob.SSO = ops.SSOProfileConfig{
+					StartURL: "https://example.awsapps.com/start", Region: "eu-west-1", AccountID: "123456789012",
+				}
+				ob.SSOSession = "myapp"
+			},
+			contains: []string{
+				"'myapp' 'https://example.awsapps.com/start' 'eu-west-1' >>~/.aws/config",
+				"aws configure set sso_role_name 'myapp-dev-deployer-Eve' --profile 'myapp-eve'",
+				"aws sso login --sso-session 'myapp'",
+			},
+			excludes: []string{"aws_access_key_id"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			ob := base
+			if tc.modify != nil {
+				tc.modify(&ob)
+			}
+			script, err := renderOnboarding(ob)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, s := range tc.contains {
+				if !strings.Contains(string(script), s) {
+					t.Errorf("expected script to contain %q, got:\n%s", s, script)
+				}
+			}
+			for _, s := range tc.excludes {
+				if strings.Contains(string(script), s) {
+					t.Errorf("expected script not to contain %q, got:\n%s", s, script)
+				}
+			}
+		})
+	}
+}
@@ -7,8 +7,10 @@ func orgCmd() *cli.Command {
 		Name:  "org",
 		Usage: "Organization and management account operations",
 		Commands: []*cli.Command{
+			orgInitCmd(),
 			orgCreateAccountCmd(),
 			orgDestroyAccountCmd(),
+			orgSetAccountDetailsCmd(),
 			orgDNSDelegateCmd(),
 			orgDNSUndelegateCmd(),
 			orgDNSVerifyCmd(),
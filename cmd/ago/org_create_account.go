@@ -2,13 +2,13 @@ package main
 
 import (
 	"context"
-	"encoding/json"
 	"io"
-	"os"
 	"path/filepath"
 
-	"github.com/advdv/ago/cmd/ago/internal/cmdexec"
+	"github.com/advdv/ago/cmd/ago/internal/awsapi"
+	"github.com/advdv/ago/cmd/ago/internal/cdkcontext"
 	"github.com/advdv/ago/cmd/ago/internal/config"
+	"github.com/advdv/ago/cmd/ago/internal/ops"
 	"github.com/cockroachdb/errors"
 	"github.com/urfave/cli/v3"
 )
@@ -28,11 +28,6 @@ func orgCreateAccountCmd() *cli.Command {
 				Usage:    "Email pattern for the account (use {project} as placeholder)",
 				Required: true,
 			},
-			&cli.StringFlag{
-				Name:  "region",
-				Usage: "AWS region for the CloudFormation stack",
-				Value: "eu-central-1",
-			},
 			&cli.BoolFlag{
 				Name:  "write-profile",
 				Usage: "Write AWS CLI profile to ~/.aws/config",
@@ -50,6 +45,15 @@ type createAccountOptions struct {
 	WriteProfile      bool
 	EmailPattern      string
 	Output            io.Writer
+	Result            io.Writer
+}
+
+// createAccountResult is the result of create-account in the JSON output format.
+type createAccountResult struct {
+	AccountID   string `json:"accountId"`
+	AccountName string `json:"accountName"`
+	StackName   string `json:"stackName"`
+	Profile     string `json:"profile,omitempty"`
 }
 
 func runCreateProjectAccount(ctx context.Context, cmd *cli.Command, cfg config.Config) error {
@@ -58,13 +62,17 @@ func runCreateProjectAccount(ctx context.Context, cmd *cli.Command, cfg config.C
 		return err
 	}
 
-	return doCreateProjectAccount(ctx, cfg, createAccountOptions{
-		ProjectName:       projectName,
-		ManagementProfile: cmd.String("management-profile"),
-		Region:            cmd.String("region"),
-		WriteProfile:      cmd.Bool("write-profile"),
-		EmailPattern:      cmd.String("email-pattern"),
-		Output:            os.Stdout,
+	output, result := commandOutput(cmd)
+	return withNotification(ctx, cfg, output, "create-account", projectName, func() error {
+		return doCreateProjectAccount(ctx, cfg, createAccountOptions{
+			ProjectName:       projectName,
+			ManagementProfile: cmd.String("management-profile"),
+			Region:            resolveAWSRegion(cmd.String("region"), cfg.Inner.Region()),
+			WriteProfile:      cmd.Bool("write-profile"),
+			EmailPattern:      cmd.String("email-pattern"),
+			Output:            output,
+			Result:            result,
+		})
 	})
 }
 
@@ -73,23 +81,26 @@ func doCreateProjectAccount(ctx context.Context, cfg config.Config, opts createA
 		return errors.New("email pattern is required for account creation")
 	}
 
-	exec := cmdexec.New(cfg).WithOutput(opts.Output, opts.Output)
-
 	templatePath, cleanup, err := renderAccountStackTemplate(opts.ProjectName, opts.EmailPattern)
 	if err != nil {
 		return errors.Wrap(err, "failed to render account stack template")
 	}
 	defer cleanup()
 
-	stackName := "ago-account-" + opts.ProjectName
+	account := ops.Account{
+		ProjectName:       opts.ProjectName,
+		ManagementProfile: opts.ManagementProfile,
+		Region:            opts.Region,
+	}
 
-	writeOutputf(opts.Output, "Deploying account stack %q...\n", stackName)
+	writeOutputf(opts.Output, "Deploying account stack %q...\n", account.StackName())
 
-	if err := deployAccountStack(ctx, exec, opts, stackName, templatePath); err != nil {
+	clients, err := awsapi.New(ctx, account.ManagementProfile, account.Region)
+	if err != nil {
 		return err
 	}
 
-	accountID, err := getAccountStackOutput(ctx, exec, opts, stackName, "AccountId")
+	accountID, err := ops.CreateAccount(ctx, clients, account, templatePath)
 	if err != nil {
 		return err
 	}
@@ -98,140 +109,43 @@ func doCreateProjectAccount(ctx context.Context, cfg config.Config, opts createA
 	writeOutputf(opts.Output, "  Account ID: %s\n", accountID)
 	writeOutputf(opts.Output, "  Account Name: %s\n", opts.ProjectName)
 
+	res := createAccountResult{AccountID: accountID, AccountName: opts.ProjectName, StackName: account.StackName()}
 	if opts.WriteProfile {
-		profileName := opts.ProjectName + "-admin"
-		if err := writeAWSProfile(ctx, exec, opts, profileName, accountID); err != nil {
+		profileName := account.AdminProfileName()
+		if err := ops.WriteAdminProfile(account, accountID); err != nil {
 			return err
 		}
 		writeOutputf(opts.Output, "  AWS Profile: %s (written to ~/.aws/config)\n", profileName)
 
-		if err := updateCDKContextProfile(cfg.ProjectDir, opts.ProjectName, profileName); err != nil {
+		if err := updateCDKContextProfile(cfg, opts.ProjectName, profileName); err != nil {
 			return err
 		}
 
-		if err := updateCDKJSONProfile(cfg.ProjectDir, profileName); err != nil {
+		if err := updateCDKJSONProfile(cfg, profileName); err != nil {
 			return err
 		}
+		res.Profile = profileName
 	}
 
-	return nil
-}
-
-func deployAccountStack(
-	ctx context.Context, exec cmdexec.Executor, opts createAccountOptions, stackName, templatePath string,
-) error {
-	return exec.Mise(ctx, "aws", "cloudformation", "deploy",
-		"--stack-name", stackName,
-		"--template-file", templatePath,
-		"--region", opts.Region,
-		"--profile", opts.ManagementProfile,
-		"--no-fail-on-empty-changeset",
-	)
-}
-
-func getAccountStackOutput(
-	ctx context.Context, exec cmdexec.Executor, opts createAccountOptions, stackName, outputKey string,
-) (string, error) {
-	output, err := exec.MiseOutput(ctx, "aws", "cloudformation", "describe-stacks",
-		"--stack-name", stackName,
-		"--region", opts.Region,
-		"--profile", opts.ManagementProfile,
-		"--query", "Stacks[0].Outputs",
-		"--output", "json",
-	)
-	if err != nil {
-		return "", errors.Wrap(err, "failed to describe stack")
-	}
-
-	var outputs []struct {
-		OutputKey   string `json:"OutputKey"`   //nolint:tagliatelle // AWS API uses PascalCase
-		OutputValue string `json:"OutputValue"` //nolint:tagliatelle // AWS API uses PascalCase
-	}
-	if err := json.Unmarshal([]byte(output), &outputs); err != nil {
-		return "", errors.Wrap(err, "failed to parse stack outputs")
-	}
-
-	for _, o := range outputs {
-		if o.OutputKey == outputKey {
-			return o.OutputValue, nil
-		}
-	}
-
-	return "", errors.Errorf("output %q not found in stack %q", outputKey, stackName)
+	return writeResult(opts.Result, res)
 }
 
-func updateCDKContextProfile(projectDir, projectName, profileName string) error {
-	contextPath := filepath.Join(projectDir, "infra", "cdk", "cdk", "cdk.context.json")
-
-	data, err := os.ReadFile(contextPath)
-	if err != nil {
-		return errors.Wrap(err, "failed to read cdk.context.json")
-	}
-
-	var context map[string]any
-	if err := json.Unmarshal(data, &context); err != nil {
-		return errors.Wrap(err, "failed to parse cdk.context.json")
-	}
-
-	context[projectName+"-admin-profile"] = profileName
-
-	output, err := json.MarshalIndent(context, "", "  ")
-	if err != nil {
-		return errors.Wrap(err, "failed to marshal cdk.context.json")
-	}
-
-	if err := os.WriteFile(contextPath, output, 0o644); err != nil { //nolint:gosec // config file needs to be readable
-		return errors.Wrap(err, "failed to write cdk.context.json")
-	}
-
-	return nil
+func updateCDKContextProfile(cfg config.Config, projectName, profileName string) error {
+	return cdkcontext.Update(cfg.CDKDir(), func(context map[string]any, _ string) error {
+		context[projectName+"-admin-profile"] = profileName
+		return nil
+	})
 }
 
-func updateCDKJSONProfile(projectDir, profileName string) error {
-	cdkJSONPath := filepath.Join(projectDir, "infra", "cdk", "cdk", "cdk.json")
-
-	data, err := os.ReadFile(cdkJSONPath)
+func updateCDKJSONProfile(cfg config.Config, profileName string) error {
+	f, err := cdkcontext.ReadFile(cfg.CDKJSONPath())
 	if err != nil {
-		return errors.Wrap(err, "failed to read cdk.json")
-	}
-
-	var cdkJSON map[string]any
-	if err := json.Unmarshal(data, &cdkJSON); err != nil {
-		return errors.Wrap(err, "failed to parse cdk.json")
-	}
-
-	cdkJSON["profile"] = profileName
-	cdkJSON["admin-profile"] = profileName
-
-	output, err := json.MarshalIndent(cdkJSON, "", "  ")
-	if err != nil {
-		return errors.Wrap(err, "failed to marshal cdk.json")
-	}
-
-	if err := os.WriteFile(cdkJSONPath, output, 0o644); err != nil { //nolint:gosec // config file needs to be readable
-		return errors.Wrap(err, "failed to write cdk.json")
-	}
-
-	return nil
-}
-
-func writeAWSProfile(
-	ctx context.Context, exec cmdexec.Executor, opts createAccountOptions, profileName, accountID string,
-) error {
-	roleArn := "arn:aws:iam::" + accountID + ":role/OrganizationAccountAccessRole"
-
-	settings := []struct{ key, value string }{
-		{"role_arn", roleArn},
-		{"source_profile", opts.ManagementProfile},
-		{"region", opts.Region},
-		{"cli_pager", ""},
+		return err
 	}
-
-	for _, s := range settings {
-		if err := exec.Mise(ctx, "aws", "configure", "set", s.key, s.value, "--profile", profileName); err != nil {
-			return errors.Wrapf(err, "failed to set %s for profile %s", s.key, profileName)
+	for _, key := range []string{"profile", "admin-profile"} {
+		if err := f.Set(key, profileName); err != nil {
+			return err
 		}
 	}
-
-	return nil
+	return f.Write()
 }
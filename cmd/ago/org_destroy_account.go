@@ -6,8 +6,10 @@ import (
 	"os"
 	"path/filepath"
 
-	"github.com/advdv/ago/cmd/ago/internal/cmdexec"
+	"github.com/advdv/ago/cmd/ago/internal/awsapi"
 	"github.com/advdv/ago/cmd/ago/internal/config"
+	"github.com/advdv/ago/cmd/ago/internal/ops"
+	"github.com/advdv/ago/cmd/ago/internal/warnings"
 	"github.com/cockroachdb/errors"
 	"github.com/urfave/cli/v3"
 )
@@ -27,11 +29,6 @@ func orgDestroyAccountCmd() *cli.Command {
 				Usage:    "Confirm destruction by specifying the project name",
 				Required: true,
 			},
-			&cli.StringFlag{
-				Name:  "region",
-				Usage: "AWS region for the CloudFormation stack",
-				Value: "eu-central-1",
-			},
 		},
 		Action: config.RunWithConfig(runDestroyProjectAccount),
 	}
@@ -42,6 +39,7 @@ type destroyAccountOptions struct {
 	ManagementProfile string
 	Region            string
 	ConfirmName       string
+	Acknowledge       []string
 	Output            io.Writer
 }
 
@@ -54,8 +52,9 @@ func runDestroyProjectAccount(ctx context.Context, cmd *cli.Command, cfg config.
 	return doDestroyProjectAccount(ctx, cfg, destroyAccountOptions{
 		ProjectName:       projectName,
 		ManagementProfile: cmd.String("management-profile"),
-		Region:            cmd.String("region"),
+		Region:            resolveAWSRegion(cmd.String("region"), cfg.Inner.Region()),
 		ConfirmName:       cmd.String("confirm"),
+		Acknowledge:       cmd.StringSlice("acknowledge"),
 		Output:            os.Stdout,
 	})
 }
@@ -66,75 +65,64 @@ func doDestroyProjectAccount(ctx context.Context, cfg config.Config, opts destro
 			"confirmation name %q does not match project name %q", opts.ConfirmName, opts.ProjectName)
 	}
 
+	warn, err := warnings.New(opts.Output, opts.Acknowledge)
+	if err != nil {
+		return err
+	}
+
 	if err := doDNSUndelegate(ctx, cfg, dnsUndelegateOptions{
 		Region:            opts.Region,
 		ManagementProfile: opts.ManagementProfile,
 		Confirm:           opts.ProjectName,
+		Acknowledge:       opts.Acknowledge,
 		Output:            opts.Output,
 	}); err != nil {
 		return errors.Wrap(err, "failed to remove DNS delegation")
 	}
 
-	exec := cmdexec.New(cfg).WithOutput(opts.Output, opts.Output)
-	stackName := "ago-account-" + opts.ProjectName
-
-	accountID, err := getAccountStackOutput(ctx, exec, createAccountOptions{
+	account := ops.Account{
+		ProjectName:       opts.ProjectName,
 		ManagementProfile: opts.ManagementProfile,
 		Region:            opts.Region,
-	}, stackName, "AccountId")
+	}
+
+	clients, err := awsapi.New(ctx, account.ManagementProfile, account.Region)
+	if err != nil {
+		return err
+	}
+
+	accountID, err := ops.AccountStackID(ctx, clients, account)
 	if err != nil {
 		return errors.Wrap(err, "failed to get account ID from stack")
 	}
 
 	writeOutputf(opts.Output, "Closing AWS account %s...\n", accountID)
 
-	if err := closeAWSAccount(ctx, exec, opts, accountID); err != nil {
+	if err := ops.CloseAccount(ctx, clients, accountID); err != nil {
 		return err
 	}
 
-	writeOutputf(opts.Output, "Deleting CloudFormation stack %q...\n", stackName)
+	writeOutputf(opts.Output, "Deleting CloudFormation stack %q...\n", account.StackName())
 
-	if err := deleteAccountStack(ctx, exec, opts, stackName); err != nil {
+	if err := ops.DeleteAccountStack(ctx, clients, account); err != nil {
 		return err
 	}
 
-	profileName := opts.ProjectName + "-admin"
+	profileName := account.AdminProfileName()
 
 	writeOutputf(opts.Output, "Removing AWS profile %q from ~/.aws/config and ~/.aws/credentials...\n", profileName)
 
-	if err := removeAWSProfile(profileName); err != nil {
+	if err := ops.RemoveProfile(profileName); err != nil {
 		return err
 	}
 
 	writeOutputf(opts.Output, "Account %s closed and removed successfully.\n", accountID)
-	writeOutputf(opts.Output, "Note: The account enters a 90-day post-closure period before permanent deletion.\n")
+	warn.Report(warnings.Warning{
+		Code:     warnings.AccountPostClosure,
+		Severity: warnings.Note,
+		Summary:  "The account enters a 90-day post-closure period before permanent deletion",
+		Detail:   "It can be reopened through AWS Support during this period.",
+	})
 
 	return nil
 }
-
-func closeAWSAccount(
-	ctx context.Context, exec cmdexec.Executor, opts destroyAccountOptions, accountID string,
-) error {
-	return exec.Mise(ctx, "aws", "organizations", "close-account",
-		"--account-id", accountID,
-		"--profile", opts.ManagementProfile,
-	)
-}
-
-func deleteAccountStack(
-	ctx context.Context, exec cmdexec.Executor, opts destroyAccountOptions, stackName string,
-) error {
-	if err := exec.Mise(ctx, "aws", "cloudformation", "delete-stack",
-		"--stack-name", stackName,
-		"--region", opts.Region,
-		"--profile", opts.ManagementProfile,
-	); err != nil {
-		return errors.Wrap(err, "failed to delete stack")
-	}
-
-	return exec.Mise(ctx, "aws", "cloudformation", "wait", "stack-delete-complete",
-		"--stack-name", stackName,
-		"--region", opts.Region,
-		"--profile", opts.ManagementProfile,
-	)
-}
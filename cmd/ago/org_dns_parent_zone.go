@@ -0,0 +1,81 @@
+package main
+
+import (
+	"cmp"
+
+	"github.com/advdv/ago/agcdkutil"
+	"github.com/advdv/ago/cmd/ago/internal/cdkcontext"
+	"github.com/advdv/ago/cmd/ago/internal/ops"
+	"github.com/urfave/cli/v3"
+)
+
+const defaultParentZoneRoleName = "OrganizationAccountAccessRole"
+
+// parentZoneOptions describe where the parent hosted zone lives when it is not in the
+// management account, e.g. when DNS is centralized in a dedicated networking account.
+type parentZoneOptions struct {
+	Profile  string
+	Account  string
+	RoleName string
+}
+
+func parentZoneFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringFlag{
+			Name:  "parent-zone-profile",
+			Usage: "AWS profile for the account that owns the parent hosted zone (defaults to context parent-zone-profile)",
+		},
+		&cli.StringFlag{
+			Name:  "parent-zone-account",
+			Usage: "Account ID that owns the parent hosted zone, assumed from the management profile (defaults to context parent-zone-account)",
+		},
+		&cli.StringFlag{
+			Name:  "parent-zone-role",
+			Usage: "Role to assume in the parent zone account (defaults to context parent-zone-role or " + defaultParentZoneRoleName + ")",
+		},
+	}
+}
+
+func parentZoneOptionsFromCmd(cmd *cli.Command) parentZoneOptions {
+	return parentZoneOptions{
+		Profile:  cmd.String("parent-zone-profile"),
+		Account:  cmd.String("parent-zone-account"),
+		RoleName: cmd.String("parent-zone-role"),
+	}
+}
+
+// resolveParentZoneProfile returns the AWS profile to use for the account that owns the parent
+// hosted zone. An explicit profile wins. Otherwise, when an account is configured, a profile that
+// assumes a role in that account from the management profile is written to ~/.aws/config. Without
+// either, the parent zone is expected to live in the management account.
+func resolveParentZoneProfile(
+	cdkContext *cdkcontext.Context, managementProfile, region string, opts parentZoneOptions,
+) (string, error) {
+	if profile := cmp.Or(opts.Profile, cdkContext.String("parent-zone-profile")); profile != "" {
+		return profile, nil
+	}
+
+	account := cmp.Or(opts.Account, cdkContext.String("parent-zone-account"))
+	if account == "" {
+		return managementProfile, nil
+	}
+
+	roleName := cmp.Or(opts.RoleName, cdkContext.String("parent-zone-role"), defaultParentZoneRoleName)
+	roleArn := agcdkutil.ARN(agcdkutil.PartitionFor(region), "iam", "", account, "role/"+roleName)
+	profileName := parentZoneProfileName(account)
+
+	if err := ops.SetProfile(profileName, []ops.ProfileSetting{
+		{Key: "role_arn", Value: roleArn},
+		{Key: "source_profile", Value: managementProfile},
+		{Key: "region", Value: region},
+		{Key: "cli_pager", Value: ""},
+	}); err != nil {
+		return "", err
+	}
+
+	return profileName, nil
+}
+
+func parentZoneProfileName(account string) string {
+	return "ago-parent-zone-" + account
+}
@@ -0,0 +1,50 @@
+package main
+
+import "testing"
+
+func TestResolveParentZoneProfile(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name    string
+		context map[string]any
+		opts    parentZoneOptions
+		want    string
+	}{
+		{
+			name:    "defaults to management profile",
+			context: map[string]any{"myapp-qualifier": "myapp"},
+			want:    "management",
+		},
+		{
+			name:    "profile from context",
+			context: map[string]any{"myapp-qualifier": "myapp", "myapp-parent-zone-profile": "networking"},
+			want:    "networking",
+		},
+		{
+			name:    "flag overrides context",
+			context: map[string]any{"myapp-qualifier": "myapp", "myapp-parent-zone-profile": "networking"},
+			opts:    parentZoneOptions{Profile: "dns"},
+			want:    "dns",
+		},
+		{
+			name:    "profile wins over account",
+			context: map[string]any{"myapp-qualifier": "myapp", "myapp-parent-zone-account": "111111111111"},
+			opts:    parentZoneOptions{Profile: "dns"},
+			want:    "dns",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			got, err := resolveParentZoneProfile(newTestCDKContext(t, tt.context), "management",
+				"eu-central-1", tt.opts)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
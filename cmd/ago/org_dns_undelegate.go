@@ -4,10 +4,11 @@ import (
 	"context"
 	"io"
 	"os"
-	"strings"
 
-	"github.com/advdv/ago/cmd/ago/internal/cmdexec"
+	"github.com/advdv/ago/cmd/ago/internal/awsapi"
 	"github.com/advdv/ago/cmd/ago/internal/config"
+	"github.com/advdv/ago/cmd/ago/internal/ops"
+	"github.com/advdv/ago/cmd/ago/internal/warnings"
 	"github.com/cockroachdb/errors"
 	"github.com/urfave/cli/v3"
 )
@@ -16,15 +17,7 @@ func orgDNSUndelegateCmd() *cli.Command {
 	return &cli.Command{
 		Name:  "dns-undelegate",
 		Usage: "Remove DNS delegation from parent zone",
-		Flags: []cli.Flag{
-			&cli.StringFlag{
-				Name:  "profile",
-				Usage: "AWS profile for the project account (defaults to cdk.json profile)",
-			},
-			&cli.StringFlag{
-				Name:  "region",
-				Usage: "AWS region where the delegation stack is deployed (defaults to primary region from context)",
-			},
+		Flags: append([]cli.Flag{
 			&cli.StringFlag{
 				Name:  "management-profile",
 				Usage: "AWS profile for the management account (defaults to context management-profile)",
@@ -34,7 +27,7 @@ func orgDNSUndelegateCmd() *cli.Command {
 				Usage:    "Confirm undelegation by specifying the qualifier",
 				Required: true,
 			},
-		},
+		}, parentZoneFlags()...),
 		Action: config.RunWithConfig(runDNSUndelegate),
 	}
 }
@@ -44,6 +37,8 @@ type dnsUndelegateOptions struct {
 	Region            string
 	ManagementProfile string
 	Confirm           string
+	ParentZone        parentZoneOptions
+	Acknowledge       []string
 	Output            io.Writer
 }
 
@@ -53,52 +48,64 @@ func runDNSUndelegate(ctx context.Context, cmd *cli.Command, cfg config.Config)
 		Region:            cmd.String("region"),
 		ManagementProfile: cmd.String("management-profile"),
 		Confirm:           cmd.String("confirm"),
+		ParentZone:        parentZoneOptionsFromCmd(cmd),
+		Acknowledge:       cmd.StringSlice("acknowledge"),
 		Output:            os.Stdout,
 	})
 }
 
 func doDNSUndelegate(ctx context.Context, cfg config.Config, opts dnsUndelegateOptions) error {
-	exec := cmdexec.New(cfg).WithOutput(opts.Output, opts.Output)
-
-	cdkContext, err := readCDKContext(cfg)
+	warn, err := warnings.New(opts.Output, opts.Acknowledge)
 	if err != nil {
 		return err
 	}
 
-	qualifier, err := cdkContext.getString("qualifier")
+	cdkContext, err := readCDKContext(cfg)
 	if err != nil {
 		return err
 	}
 
+	qualifier := cdkContext.Qualifier
+
 	if opts.Confirm != qualifier {
 		return errors.Errorf(
 			"confirmation %q does not match qualifier %q", opts.Confirm, qualifier)
 	}
 
-	region := opts.Region
+	region := resolveAWSRegion(opts.Region)
 	if region == "" {
-		region, err = cdkContext.getString("primary-region")
-		if err != nil {
+		if err := cdkContext.Require("primary-region"); err != nil {
 			return err
 		}
+		region = cdkContext.PrimaryRegion
 	}
 
 	managementProfile := opts.ManagementProfile
 	if managementProfile == "" {
-		managementProfile, err = cdkContext.getString("management-profile")
-		if err != nil {
+		if err := cdkContext.Require("management-profile"); err != nil {
 			return errors.Wrap(err, "management profile not found in context (provide --management-profile)")
 		}
+		managementProfile = cdkContext.String("management-profile")
+	}
+
+	if err := cdkContext.Require("base-domain-name"); err != nil {
+		return err
 	}
+	baseDomainName := cdkContext.BaseDomainName
 
-	baseDomainName, err := cdkContext.getString("base-domain-name")
+	parentZoneProfile, err := resolveParentZoneProfile(cdkContext, managementProfile, region, opts.ParentZone)
 	if err != nil {
 		return err
 	}
 
-	stackName := "ago-dns-delegate-" + qualifier
+	stackName := ops.DelegationStackName(qualifier)
 
-	exists, err := stackExists(ctx, exec, managementProfile, region, stackName)
+	clients, err := awsapi.New(ctx, parentZoneProfile, region)
+	if err != nil {
+		return err
+	}
+
+	exists, err := ops.StackExists(ctx, clients, stackName)
 	if err != nil {
 		return err
 	}
@@ -111,76 +118,30 @@ func doDNSUndelegate(ctx context.Context, cfg config.Config, opts dnsUndelegateO
 	writeOutputf(opts.Output, "Deleting DNS delegation stack %q...\n", stackName)
 	writeOutputf(opts.Output, "  Domain: %s\n", baseDomainName)
 	writeOutputf(opts.Output, "  Region: %s\n", region)
-	writeOutputf(opts.Output, "  Profile: %s\n\n", managementProfile)
-
-	if err := deleteDNSDelegationStack(ctx, exec, managementProfile, region, stackName); err != nil {
-		return err
-	}
+	writeOutputf(opts.Output, "  Profile: %s\n\n", parentZoneProfile)
 
-	writeOutputf(opts.Output, "\nDNS delegation stack deleted successfully.\n")
-	printDNSDelegatedWarning(opts.Output, cdkContext.prefix)
-
-	return nil
-}
-
-func stackExists(
-	ctx context.Context, exec cmdexec.Executor, profile, region, stackName string,
-) (bool, error) {
-	_, err := exec.MiseOutput(ctx, "aws", "cloudformation", "describe-stacks",
-		"--stack-name", stackName,
-		"--region", region,
-		"--profile", profile,
-		"--output", "json",
-	)
-	if err != nil {
-		errStr := err.Error()
-		if strings.Contains(errStr, "does not exist") ||
-			strings.Contains(errStr, "ValidationError") {
-			return false, nil
-		}
-		return false, errors.Wrapf(err, "failed to check if stack %q exists", stackName)
-	}
-	return true, nil
-}
-
-func deleteDNSDelegationStack(
-	ctx context.Context, exec cmdexec.Executor, profile, region, stackName string,
-) error {
-	if err := exec.Mise(ctx, "aws", "cloudformation", "delete-stack",
-		"--stack-name", stackName,
-		"--region", region,
-		"--profile", profile,
-	); err != nil {
+	if err := ops.DeleteStack(ctx, clients, stackName); err != nil {
 		return errors.Wrap(err, "failed to delete DNS delegation stack")
 	}
 
-	if err := exec.Mise(ctx, "aws", "cloudformation", "wait", "stack-delete-complete",
-		"--stack-name", stackName,
-		"--region", region,
-		"--profile", profile,
-	); err != nil {
-		return errors.Wrap(err, "failed waiting for stack deletion")
-	}
+	writeOutputf(opts.Output, "\nDNS delegation stack deleted successfully.\n")
+	warn.Report(dnsDelegatedWarning(cdkContext.Prefix))
 
 	return nil
 }
 
-func printDNSDelegatedWarning(output io.Writer, prefix string) {
-	writeOutputf(output, `
-================================================================================
-                              IMPORTANT NOTICE
-================================================================================
-
-The '%sdns-delegated' flag in cdk.context.json has NOT been changed.
-
-WARNING: If you manually set this flag to false and then run 'cdk deploy',
-CDK will DESTROY resources that depend on DNS validation (certificates,
-API Gateway custom domains, CloudFront distributions, etc.).
+// dnsDelegatedWarning explains that undelegating leaves the dns-delegated flag alone, because
+// clearing it would make the next deploy destroy everything that depends on DNS validation.
+func dnsDelegatedWarning(prefix string) warnings.Warning {
+	return warnings.Warning{
+		Code:     warnings.DNSDelegatedUnchanged,
+		Severity: warnings.Danger,
+		Summary:  "The '" + prefix + "dns-delegated' flag in cdk.context.json has NOT been changed",
+		Detail: `If you manually set this flag to false and then run 'cdk deploy', CDK will DESTROY
+resources that depend on DNS validation (certificates, API Gateway custom domains,
+CloudFront distributions, etc.).
 
 Only set the flag to false if you understand and accept these consequences.
-
-To restore DNS delegation, run: ago org dns-delegate
-
-================================================================================
-`, prefix)
+To restore DNS delegation, run: ago org dns-delegate`,
+	}
 }
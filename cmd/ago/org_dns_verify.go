@@ -3,12 +3,13 @@ package main
 import (
 	"context"
 	"io"
-	"os"
 	"strings"
 	"time"
 
-	"github.com/advdv/ago/cmd/ago/internal/cmdexec"
+	"github.com/advdv/ago/agcdkutil"
+	"github.com/advdv/ago/cmd/ago/internal/awsapi"
 	"github.com/advdv/ago/cmd/ago/internal/config"
+	"github.com/advdv/ago/cmd/ago/internal/ops"
 	"github.com/cockroachdb/errors"
 	"github.com/urfave/cli/v3"
 )
@@ -22,14 +23,6 @@ func orgDNSVerifyCmd() *cli.Command {
 				Name:  "stack-name",
 				Usage: "CloudFormation stack name containing the hosted zone (defaults to {qualifier}-Shared-{region-ident})",
 			},
-			&cli.StringFlag{
-				Name:  "profile",
-				Usage: "AWS profile for the project account (defaults to cdk.json profile)",
-			},
-			&cli.StringFlag{
-				Name:  "region",
-				Usage: "AWS region where the shared stack is deployed (defaults to primary region from context)",
-			},
 			&cli.BoolFlag{
 				Name:  "wait",
 				Usage: "Wait for DNS propagation instead of checking once",
@@ -52,60 +45,69 @@ type dnsVerifyOptions struct {
 	Wait      bool
 	Timeout   time.Duration
 	Output    io.Writer
+	Result    io.Writer
+}
+
+// dnsVerifyResult is the result of dns-verify in the JSON output format.
+type dnsVerifyResult struct {
+	Domain      string   `json:"domain"`
+	NameServers []string `json:"nameServers"`
+	Verified    bool     `json:"verified"`
 }
 
 func runDNSVerify(ctx context.Context, cmd *cli.Command, cfg config.Config) error {
-	return doDNSVerify(ctx, cfg, dnsVerifyOptions{
-		StackName: cmd.String("stack-name"),
-		Profile:   cmd.String("profile"),
-		Region:    cmd.String("region"),
-		Wait:      cmd.Bool("wait"),
-		Timeout:   cmd.Duration("timeout"),
-		Output:    os.Stdout,
+	output, result := commandOutput(cmd)
+	return withNotification(ctx, cfg, output, "dns-verify", "", func() error {
+		return doDNSVerify(ctx, cfg, dnsVerifyOptions{
+			StackName: cmd.String("stack-name"),
+			Profile:   cmd.String("profile"),
+			Region:    cmd.String("region"),
+			Wait:      cmd.Bool("wait"),
+			Timeout:   cmd.Duration("timeout"),
+			Output:    output,
+			Result:    result,
+		})
 	})
 }
 
 func doDNSVerify(ctx context.Context, cfg config.Config, opts dnsVerifyOptions) error {
-	exec := cmdexec.New(cfg).WithOutput(opts.Output, opts.Output)
-
 	cdkContext, err := readCDKContext(cfg)
 	if err != nil {
 		return err
 	}
 
-	profile := opts.Profile
-	if profile == "" {
-		profile, err = getCDKProfile(cfg)
-		if err != nil {
-			return err
-		}
+	profile, err := resolveAWSProfile(opts.Profile, func() (string, error) { return getCDKProfile(cdkContext) })
+	if err != nil {
+		return err
 	}
 
-	region := opts.Region
+	region := resolveAWSRegion(opts.Region)
 	if region == "" {
-		region, err = cdkContext.getString("primary-region")
-		if err != nil {
+		if err := cdkContext.Require("primary-region"); err != nil {
 			return err
 		}
+		region = cdkContext.PrimaryRegion
 	}
 
 	stackName := opts.StackName
 	if stackName == "" {
-		stackName, err = deriveSharedStackName(cdkContext, region)
-		if err != nil {
-			return err
-		}
+		stackName = deriveSharedStackName(cdkContext, region)
 	}
 
-	nameServers, err := getStackOutputValue(ctx, exec, profile, region, stackName, "HostedZoneNameServers")
+	clients, err := awsapi.New(ctx, profile, region)
 	if err != nil {
-		return errors.Wrap(err, "failed to get name servers from stack (is the shared stack deployed?)")
+		return err
 	}
 
-	baseDomainName, err := cdkContext.getString("base-domain-name")
+	nameServers, err := ops.StackOutput(ctx, clients, stackName, agcdkutil.NameServersOutputKey)
 	if err != nil {
+		return errors.Wrap(err, "failed to get name servers from stack (is the shared stack deployed?)")
+	}
+
+	if err := cdkContext.Require("base-domain-name"); err != nil {
 		return err
 	}
+	baseDomainName := cdkContext.BaseDomainName
 
 	nsList := strings.Split(nameServers, ",")
 
@@ -117,45 +119,31 @@ func doDNSVerify(ctx context.Context, cfg config.Config, opts dnsVerifyOptions)
 	writeOutputf(opts.Output, "\n")
 
 	if opts.Wait {
-		if err := waitForDNSPropagation(ctx, opts.Output, baseDomainName, nsList, opts.Timeout); err != nil {
+		if err := ops.WaitForDelegation(ctx, opts.Output, baseDomainName, nsList, opts.Timeout); err != nil {
 			return err
 		}
 	} else {
-		verified, err := checkDNSOnce(ctx, baseDomainName, nsList)
+		verified, err := ops.CheckDelegation(ctx, baseDomainName, nsList)
 		if err != nil {
 			return errors.Wrap(err, "DNS lookup failed")
 		}
 		if !verified {
 			writeOutputf(opts.Output, "DNS delegation NOT verified. NS records do not match expected values.\n")
 			writeOutputf(opts.Output, "Run with --wait to poll until propagation completes.\n")
+			if err := writeResult(opts.Result, dnsVerifyResult{Domain: baseDomainName, NameServers: nsList}); err != nil {
+				return err
+			}
 			return errors.New("DNS delegation not verified")
 		}
-		writeOutputf(opts.Output, "DNS records verified via %s\n", publicDNSServer)
+		writeOutputf(opts.Output, "DNS records verified via %s\n", ops.PublicDNSServer)
 	}
 
-	if err := setDNSDelegatedFlag(cfg, cdkContext.prefix); err != nil {
+	if err := setDNSDelegatedFlag(cfg); err != nil {
 		return err
 	}
 
 	writeOutputf(opts.Output, "Updated cdk.context.json: dns-delegated = true\n")
 	writeOutputf(opts.Output, "\nDNS verification complete.\n")
 
-	return nil
-}
-
-func checkDNSOnce(ctx context.Context, baseDomainName string, expectedNS []string) (bool, error) {
-	resolver := newPublicDNSResolver()
-
-	expectedSet := make(map[string]bool, len(expectedNS))
-	for _, ns := range expectedNS {
-		normalized := strings.TrimSuffix(strings.ToLower(ns), ".") + "."
-		expectedSet[normalized] = true
-	}
-
-	nsRecords, err := lookupNSWithRetry(ctx, resolver, baseDomainName)
-	if err != nil {
-		return false, err
-	}
-
-	return nsRecordsMatch(nsRecords, expectedSet), nil
+	return writeResult(opts.Result, dnsVerifyResult{Domain: baseDomainName, NameServers: nsList, Verified: true})
 }
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"context"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/advdv/ago/agcdkutil"
+	"github.com/advdv/ago/cmd/ago/internal/awsapi"
+	"github.com/advdv/ago/cmd/ago/internal/config"
+	"github.com/advdv/ago/cmd/ago/internal/ops"
+	cfntypes "github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
+	"github.com/aws/aws-sdk-go-v2/service/organizations"
+	orgtypes "github.com/aws/aws-sdk-go-v2/service/organizations/types"
+	"github.com/cockroachdb/errors"
+	"github.com/urfave/cli/v3"
+)
+
+const managementStackName = "ago-management"
+
+func orgInitCmd() *cli.Command {
+	return &cli.Command{
+		Name:  "init",
+		Usage: "Prepare the management account for ago (run once per organization, before 'ago init')",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:  "role-name",
+				Usage: "Name of the IAM role that runs organization commands",
+				Value: "ago-management",
+			},
+			&cli.StringFlag{
+				Name:  "profile-name",
+				Usage: "Name of the AWS CLI profile to write for the management role",
+				Value: "ago-management",
+			},
+			&cli.StringSliceFlag{
+				Name:  "operator",
+				Usage: "IAM user allowed to assume the management role (repeatable)",
+			},
+			&cli.BoolFlag{
+				Name:  "write-profile",
+				Usage: "Write AWS CLI profile to ~/.aws/config",
+				Value: true,
+			},
+		},
+		Action: runOrgInit,
+	}
+}
+
+type orgInitOptions struct {
+	Profile      string
+	Region       string
+	RoleName     string
+	ProfileName  string
+	Operators    []string
+	WriteProfile bool
+	Output       io.Writer
+}
+
+func runOrgInit(ctx context.Context, cmd *cli.Command) error {
+	// There is no project yet, so the management profile must be given explicitly.
+	profile := awsProfileOverride(cmd.String("profile"))
+	if profile == "" {
+		return errors.New("a profile with administrator access to the management account is required " +
+			"(--profile or AWS_PROFILE)")
+	}
+
+	return doOrgInit(ctx, orgInitOptions{
+		Profile:      profile,
+		Region:       resolveAWSRegion(cmd.String("region"), config.FallbackRegion),
+		RoleName:     cmd.String("role-name"),
+		ProfileName:  cmd.String("profile-name"),
+		Operators:    cmd.StringSlice("operator"),
+		WriteProfile: cmd.Bool("write-profile"),
+		Output:       os.Stdout,
+	})
+}
+
+func doOrgInit(ctx context.Context, opts orgInitOptions) error {
+	clients, err := awsapi.New(ctx, opts.Profile, opts.Region)
+	if err != nil {
+		return err
+	}
+
+	writeOutputf(opts.Output, "Verifying AWS access with profile %q...\n", opts.Profile)
+	if err := ops.VerifyAccess(ctx, clients); err != nil {
+		return err
+	}
+
+	if err := ensureOrganization(ctx, clients, opts.Output); err != nil {
+		return err
+	}
+
+	templatePath, cleanup, err := renderManagementStackTemplate(opts.RoleName)
+	if err != nil {
+		return errors.Wrap(err, "failed to render management stack template")
+	}
+	defer cleanup()
+
+	writeOutputf(opts.Output, "Deploying management stack %q...\n", managementStackName)
+	if err := ops.DeployTemplate(ctx, clients, managementStackName, templatePath, []ops.CFNParameter{
+		{Key: "Operators", Value: strings.Join(opts.Operators, ","), List: true},
+	}, cfntypes.CapabilityCapabilityNamedIam); err != nil {
+		return errors.Wrap(err, "failed to deploy management stack")
+	}
+
+	roleArn, err := ops.StackOutput(ctx, clients, managementStackName, agcdkutil.ManagementRoleARNOutputKey)
+	if err != nil {
+		return err
+	}
+
+	writeOutputf(opts.Output, "Management account is ready!\n")
+	writeOutputf(opts.Output, "  Role: %s\n", roleArn)
+	if len(opts.Operators) > 0 {
+		writeOutputf(opts.Output, "  Operators: %s\n", strings.Join(opts.Operators, ", "))
+	}
+
+	if opts.WriteProfile {
+		if err := ops.SetProfile(opts.ProfileName, []ops.ProfileSetting{
+			{Key: "role_arn", Value: roleArn},
+			{Key: "source_profile", Value: opts.Profile},
+			{Key: "region", Value: opts.Region},
+			{Key: "cli_pager", Value: ""},
+		}); err != nil {
+			return err
+		}
+		writeOutputf(opts.Output, "  AWS Profile: %s (written to ~/.aws/config)\n", opts.ProfileName)
+	}
+
+	writeOutputf(opts.Output, "\nUse '%s' as the management profile when running 'ago init'.\n", opts.ProfileName)
+
+	return nil
+}
+
+// ensureOrganization creates an organization with all features enabled if the management
+// account is not part of one yet.
+func ensureOrganization(ctx context.Context, clients *awsapi.Clients, output io.Writer) error {
+	_, err := clients.Organizations.DescribeOrganization(ctx, &organizations.DescribeOrganizationInput{})
+	if err == nil {
+		writeOutputf(output, "Organizations is already enabled\n")
+		return nil
+	}
+	if !awsapi.IsErrorCode(err, "AWSOrganizationsNotInUseException") {
+		return errors.Wrap(err, "failed to describe organization")
+	}
+
+	writeOutputf(output, "Enabling Organizations...\n")
+	if _, err := clients.Organizations.CreateOrganization(ctx, &organizations.CreateOrganizationInput{
+		FeatureSet: orgtypes.OrganizationFeatureSetAll,
+	}); err != nil {
+		return errors.Wrap(err, "failed to create organization")
+	}
+
+	return nil
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/mail"
+	"path/filepath"
+	"strings"
+
+	"github.com/advdv/ago/cmd/ago/internal/awsapi"
+	"github.com/advdv/ago/cmd/ago/internal/config"
+	"github.com/advdv/ago/cmd/ago/internal/ops"
+	"github.com/cockroachdb/errors"
+	"github.com/urfave/cli/v3"
+)
+
+func orgSetAccountDetailsCmd() *cli.Command {
+	return &cli.Command{
+		Name:  "set-account-details",
+		Usage: "Set the name and the billing, security and operations contacts of the project account",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:     "management-profile",
+				Usage:    "AWS profile for the management account",
+				Required: true,
+			},
+			&cli.StringFlag{
+				Name:  "account-name",
+				Usage: "New name of the account",
+			},
+			&cli.StringFlag{
+				Name:  "billing-email",
+				Usage: "Email address of the billing contact",
+			},
+			&cli.StringFlag{
+				Name:  "security-email",
+				Usage: "Email address of the security contact",
+			},
+			&cli.StringFlag{
+				Name:  "operations-email",
+				Usage: "Email address of the operations contact",
+			},
+			&cli.StringFlag{
+				Name:  "contact-name",
+				Usage: "Name of the alternate contacts (required with a contact email)",
+			},
+			&cli.StringFlag{
+				Name:  "contact-phone",
+				Usage: "Phone number of the alternate contacts (required with a contact email)",
+			},
+			&cli.StringFlag{
+				Name:  "contact-title",
+				Usage: "Title of the alternate contacts (defaults to the contact type, such as \"Billing contact\")",
+			},
+		},
+		Action: config.RunWithConfig(runSetAccountDetails),
+	}
+}
+
+type setAccountDetailsOptions struct {
+	ProjectName       string
+	ManagementProfile string
+	Region            string
+	AccountName       string
+	BillingEmail      string
+	SecurityEmail     string
+	OperationsEmail   string
+	ContactName       string
+	ContactPhone      string
+	ContactTitle      string
+	Output            io.Writer
+	Result            io.Writer
+}
+
+// setAccountDetailsResult is the result of set-account-details in the JSON output format.
+type setAccountDetailsResult struct {
+	AccountID   string   `json:"accountId"`
+	AccountName string   `json:"accountName,omitempty"`
+	Contacts    []string `json:"contacts"`
+}
+
+func runSetAccountDetails(ctx context.Context, cmd *cli.Command, cfg config.Config) error {
+	projectName := filepath.Base(cfg.ProjectDir)
+	if err := validateProjectName(projectName); err != nil {
+		return err
+	}
+
+	output, result := commandOutput(cmd)
+	return doSetAccountDetails(ctx, setAccountDetailsOptions{
+		ProjectName:       projectName,
+		ManagementProfile: cmd.String("management-profile"),
+		Region:            resolveAWSRegion(cmd.String("region"), cfg.Inner.Region()),
+		AccountName:       cmd.String("account-name"),
+		BillingEmail:      cmd.String("billing-email"),
+		SecurityEmail:     cmd.String("security-email"),
+		OperationsEmail:   cmd.String("operations-email"),
+		ContactName:       cmd.String("contact-name"),
+		ContactPhone:      cmd.String("contact-phone"),
+		ContactTitle:      cmd.String("contact-title"),
+		Output:            output,
+		Result:            result,
+	})
+}
+
+func doSetAccountDetails(ctx context.Context, opts setAccountDetailsOptions) error {
+	contacts, err := alternateContacts(opts)
+	if err != nil {
+		return err
+	}
+	if opts.AccountName == "" && len(contacts) == 0 {
+		return errors.New("nothing to set: specify --account-name or a contact email")
+	}
+
+	account := ops.Account{
+		ProjectName:       opts.ProjectName,
+		ManagementProfile: opts.ManagementProfile,
+		Region:            opts.Region,
+	}
+
+	clients, err := awsapi.New(ctx, account.ManagementProfile, account.Region)
+	if err != nil {
+		return err
+	}
+
+	accountID, err := ops.AccountStackID(ctx, clients, account)
+	if err != nil {
+		return errors.Wrap(err, "failed to get account ID from stack")
+	}
+
+	writeOutputf(opts.Output, "Enabling trusted access for account management...\n")
+	if err := ops.EnableAccountManagement(ctx, clients); err != nil {
+		return err
+	}
+
+	res := setAccountDetailsResult{AccountID: accountID, AccountName: opts.AccountName, Contacts: []string{}}
+	if opts.AccountName != "" {
+		writeOutputf(opts.Output, "Renaming account %s to %q...\n", accountID, opts.AccountName)
+		if err := ops.PutAccountName(ctx, clients, accountID, opts.AccountName); err != nil {
+			return err
+		}
+	}
+	for _, contact := range contacts {
+		writeOutputf(opts.Output, "Setting %s contact of account %s to %s...\n",
+			strings.ToLower(contact.Type), accountID, contact.Email)
+		if err := ops.PutAlternateContact(ctx, clients, accountID, contact); err != nil {
+			return err
+		}
+		res.Contacts = append(res.Contacts, contact.Type)
+	}
+
+	writeOutputf(opts.Output, "Account details of %s updated.\n", accountID)
+	return writeResult(opts.Result, res)
+}
+
+// alternateContacts returns the contacts to set, one per contact email. The Account Management
+// API requires a name, title and phone number for every contact, so they are shared between the
+// contacts and the title defaults to the contact type.
+func alternateContacts(opts setAccountDetailsOptions) ([]ops.AlternateContact, error) {
+	var contacts []ops.AlternateContact
+	for _, c := range []struct{ kind, title, email string }{
+		{ops.ContactBilling, "Billing contact", opts.BillingEmail},
+		{ops.ContactSecurity, "Security contact", opts.SecurityEmail},
+		{ops.ContactOperations, "Operations contact", opts.OperationsEmail},
+	} {
+		if c.email == "" {
+			continue
+		}
+		if _, err := mail.ParseAddress(c.email); err != nil {
+			return nil, errors.Errorf("invalid %s email %q", strings.ToLower(c.kind), c.email)
+		}
+		title := c.title
+		if opts.ContactTitle != "" {
+			title = opts.ContactTitle
+		}
+		contacts = append(contacts, ops.AlternateContact{
+			Type:  c.kind,
+			Name:  opts.ContactName,
+			Title: title,
+			Email: c.email,
+			Phone: opts.ContactPhone,
+		})
+	}
+
+	if len(contacts) > 0 && (opts.ContactName == "" || opts.ContactPhone == "") {
+		return nil, errors.New("--contact-name and --contact-phone are required to set a contact")
+	}
+	return contacts, nil
+}
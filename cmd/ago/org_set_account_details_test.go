@@ -0,0 +1,39 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/advdv/ago/cmd/ago/internal/ops"
+)
+
+func TestAlternateContacts(t *testing.T) {
+	t.Parallel()
+
+	contacts, err := alternateContacts(setAccountDetailsOptions{
+		BillingEmail:  "billing@example.com",
+		SecurityEmail: "security@example.com",
+		ContactName:   "Ops Team",
+		ContactPhone:  "+31600000000",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(contacts) != 2 {
+		t.Fatalf("expected 2 contacts, got %+v", contacts)
+	}
+	if contacts[0].Type != ops.ContactBilling || contacts[0].Title != "Billing contact" {
+		t.Errorf("expected a billing contact with the default title, got %+v", contacts[0])
+	}
+	if contacts[1].Type != ops.ContactSecurity || contacts[1].Email != "security@example.com" {
+		t.Errorf("expected the security contact, got %+v", contacts[1])
+	}
+
+	if _, err := alternateContacts(setAccountDetailsOptions{BillingEmail: "billing@example.com"}); err == nil {
+		t.Error("expected an error without a contact name and phone")
+	}
+	if _, err := alternateContacts(setAccountDetailsOptions{
+		BillingEmail: "not an email", ContactName: "Ops", ContactPhone: "+31600000000",
+	}); err == nil {
+		t.Error("expected an error for an invalid email")
+	}
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"slices"
+
+	"github.com/cockroachdb/errors"
+	"github.com/urfave/cli/v3"
+)
+
+// Output formats of the root --output flag. In the JSON format, commands that report a result
+// print it as a single JSON document on stdout and their progress on stderr, so the result can
+// be piped into other tools. Commands without a result print their progress as usual.
+const (
+	outputFormatText = "text"
+	outputFormatJSON = "json"
+)
+
+// outputFormatFlag is defined on the root command. Subcommands with an --output flag of their
+// own, such as 'report inventory', only see that flag, so it is given before the subcommand.
+func outputFormatFlag() cli.Flag {
+	return &cli.StringFlag{
+		Name:    "output",
+		Usage:   "Output format of command results: text or json (json prints progress to stderr)",
+		Value:   outputFormatText,
+		Sources: cli.EnvVars("AGO_OUTPUT"),
+		Validator: func(format string) error {
+			if !slices.Contains([]string{outputFormatText, outputFormatJSON}, format) {
+				return errors.Errorf("invalid output format %q: must be %q or %q",
+					format, outputFormatText, outputFormatJSON)
+			}
+			return nil
+		},
+	}
+}
+
+// jsonOutput reports whether the command's result is printed as JSON.
+func jsonOutput(cmd *cli.Command) bool {
+	return cmd.String("output") == outputFormatJSON
+}
+
+// commandOutput returns where a command prints its progress and, in the JSON format, where it
+// prints its result. The result writer is nil in the text format.
+func commandOutput(cmd *cli.Command) (progress, result io.Writer) {
+	if jsonOutput(cmd) {
+		return os.Stderr, os.Stdout
+	}
+	return os.Stdout, nil
+}
+
+// writeResult prints a command's result as indented JSON. It does nothing when w is nil, which
+// is the result writer of the text format.
+func writeResult(w io.Writer, result any) error {
+	if w == nil {
+		return nil
+	}
+	data, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal result")
+	}
+	writeOutputf(w, "%s\n", data)
+	return nil
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/urfave/cli/v3"
+)
+
+func TestOutputFormatFlag(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		args     []string
+		wantJSON bool
+		wantErr  bool
+	}{
+		{args: []string{"ago", "sub"}},
+		{args: []string{"ago", "--output", "text", "sub"}},
+		{args: []string{"ago", "--output", "json", "sub"}, wantJSON: true},
+		{args: []string{"ago", "--output", "yaml", "sub"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		var gotJSON bool
+		root := &cli.Command{
+			Name:  "ago",
+			Flags: []cli.Flag{outputFormatFlag()},
+			Commands: []*cli.Command{{
+				Name: "sub",
+				Action: func(_ context.Context, cmd *cli.Command) error {
+					gotJSON = jsonOutput(cmd)
+					return nil
+				},
+			}},
+		}
+
+		err := root.Run(context.Background(), tt.args)
+		if (err != nil) != tt.wantErr {
+			t.Fatalf("%v: expected error %v, got %v", tt.args, tt.wantErr, err)
+		}
+		if gotJSON != tt.wantJSON {
+			t.Errorf("%v: expected JSON output %v, got %v", tt.args, tt.wantJSON, gotJSON)
+		}
+	}
+}
+
+func TestWriteResult(t *testing.T) {
+	t.Parallel()
+
+	if err := writeResult(nil, map[string]string{"a": "b"}); err != nil {
+		t.Fatalf("unexpected error for the text format: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := writeResult(&buf, createAccountResult{AccountID: "123", AccountName: "proj", StackName: "s"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := "{\n  \"accountId\": \"123\",\n  \"accountName\": \"proj\",\n  \"stackName\": \"s\"\n}\n"
+	if buf.String() != want {
+		t.Errorf("expected %q, got %q", want, buf.String())
+	}
+}
@@ -0,0 +1,453 @@
+package main
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/advdv/ago/agcdkutil"
+	"github.com/advdv/ago/cmd/ago/internal/awsapi"
+	"github.com/advdv/ago/cmd/ago/internal/cmdexec"
+	"github.com/advdv/ago/cmd/ago/internal/config"
+	"github.com/advdv/ago/cmd/ago/internal/ops"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation"
+	"github.com/aws/aws-sdk-go-v2/service/ecr"
+	ecrtypes "github.com/aws/aws-sdk-go-v2/service/ecr/types"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/cockroachdb/errors"
+	"github.com/urfave/cli/v3"
+)
+
+func provenanceCmd() *cli.Command {
+	return &cli.Command{
+		Name:  "provenance",
+		Usage: "Inspect the provenance records that 'ago infra cdk deploy' uploads after every deploy",
+		Commands: []*cli.Command{
+			{
+				Name:  "show",
+				Usage: "Show who deployed what to a deployment, newest first",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:  "deployment",
+						Usage: "Deployment identifier (defaults to Dev{username} for the current deployer)",
+					},
+					&cli.IntFlag{
+						Name:  "limit",
+						Usage: "Maximum number of deploys to show",
+						Value: 10,
+					},
+					&cli.BoolFlag{
+						Name:  "json",
+						Usage: "Print the records as JSON",
+					},
+				},
+				Action: config.RunWithConfig(runProvenanceShow),
+			},
+		},
+	}
+}
+
+// provenancePrefix is the key prefix of the provenance records in the provenance bucket. Records
+// are stored as {prefix}{deployment}/{timestamp}-{sha}.json, so they list in deploy order.
+const provenancePrefix = "deployments/"
+
+// provenanceRecord describes a single deploy of a deployment.
+type provenanceRecord struct {
+	Deployment string            `json:"deployment"`
+	Deployer   string            `json:"deployer"`
+	Profile    string            `json:"profile"`
+	GitSHA     string            `json:"git_sha,omitempty"`
+	GitDirty   bool              `json:"git_dirty,omitempty"`
+	CIRun      string            `json:"ci_run,omitempty"`
+	StartedAt  time.Time         `json:"started_at"`
+	FinishedAt time.Time         `json:"finished_at"`
+	Succeeded  bool              `json:"succeeded"`
+	Error      string            `json:"error,omitempty"`
+	Images     []provenanceImage `json:"images,omitempty"`
+	Stacks     []provenanceStack `json:"stacks,omitempty"`
+}
+
+// provenanceImage is a backend image that the deployment referenced.
+type provenanceImage struct {
+	Name   string `json:"name"`
+	Tag    string `json:"tag"`
+	Digest string `json:"digest,omitempty"`
+}
+
+// provenanceStack is a stack that the deploy executed a change set on.
+type provenanceStack struct {
+	Name        string           `json:"name"`
+	Region      string           `json:"region"`
+	ChangeSetID string           `json:"change_set_id"`
+	Changes     []resourceChange `json:"changes"`
+}
+
+// objectKey returns the key of the record in the provenance bucket.
+func (r provenanceRecord) objectKey() string {
+	sha := "nogit"
+	if r.GitSHA != "" {
+		sha = r.GitSHA[:min(len(r.GitSHA), 12)]
+	}
+	return provenancePrefix + r.Deployment + "/" + r.StartedAt.UTC().Format("20060102T150405Z") + "-" + sha + ".json"
+}
+
+// deployProvenance is what deploy knows about itself for the provenance records.
+type deployProvenance struct {
+	Deployments []string
+	Deployer    string
+	Profile     string
+	Qualifier   string
+	Region      string
+	Regions     []string
+	ImageTags   map[string]map[string]string
+	Stacks      []stackRef
+	StartedAt   time.Time
+}
+
+// stackChangeSetIDs returns the id of the change set each stack was last updated with. Deploy
+// compares the ids before and after, so stacks that CDK left unchanged are not recorded.
+func stackChangeSetIDs(ctx context.Context, profile string, stacks []stackRef) map[string]string {
+	ids := make(map[string]string, len(stacks))
+	for _, stack := range stacks {
+		clients, err := awsapi.New(ctx, profile, stack.Region)
+		if err != nil {
+			continue
+		}
+		out, err := clients.CloudFormation.DescribeStacks(ctx, &cloudformation.DescribeStacksInput{
+			StackName: aws.String(stack.Name),
+		})
+		if err != nil || len(out.Stacks) == 0 || aws.ToString(out.Stacks[0].ChangeSetId) == "" {
+			continue
+		}
+		ids[stack.Name] = aws.ToString(out.Stacks[0].ChangeSetId)
+	}
+	return ids
+}
+
+// recordDeployProvenance uploads a provenance record for every deployment of a deploy. A failed
+// upload only warns, since the deploy itself already happened.
+func recordDeployProvenance(
+	ctx context.Context, cfg config.Config, exec cmdexec.Executor, output io.Writer,
+	deploy deployProvenance, before map[string]string, deployErr error,
+) {
+	ctx = context.WithoutCancel(ctx)
+	after := stackChangeSetIDs(ctx, deploy.Profile, deploy.Stacks)
+
+	stacks := make([]provenanceStack, 0, len(deploy.Stacks))
+	for _, stack := range deploy.Stacks {
+		id := after[stack.Name]
+		if id == "" || id == before[stack.Name] {
+			continue
+		}
+		changes, err := describeExecutedChangeSet(ctx, deploy.Profile, stack, id)
+		if err != nil {
+			writeOutputf(output, "warning: %v\n", err)
+		}
+		stacks = append(stacks, provenanceStack{Name: stack.Name, Region: stack.Region, ChangeSetID: id, Changes: changes})
+	}
+
+	sha, dirty := gitProvenance(ctx, exec)
+	for _, deployment := range deploy.Deployments {
+		record := provenanceRecord{
+			Deployment: deployment,
+			Deployer:   deploy.Deployer,
+			Profile:    deploy.Profile,
+			GitSHA:     sha,
+			GitDirty:   dirty,
+			CIRun:      ciRunURL(),
+			StartedAt:  deploy.StartedAt,
+			FinishedAt: time.Now(),
+			Succeeded:  deployErr == nil,
+			Images:     imageProvenance(ctx, cfg, deploy, deployment),
+			Stacks:     stacksOfDeployment(stacks, deployStacks(deploy.Qualifier, deploy.Regions, []string{deployment})),
+		}
+		if deployErr != nil {
+			record.Error = deployErr.Error()
+		}
+
+		if err := uploadProvenance(ctx, deploy.Profile, deploy.Region, deploy.Qualifier, record); err != nil {
+			writeOutputf(output, "warning: failed to record deploy provenance: %v\n", err)
+			return
+		}
+		writeOutputf(output, "Recorded deploy provenance: %s\n", record.objectKey())
+	}
+}
+
+// stacksOfDeployment returns the changed stacks that are among the stacks of a deployment.
+func stacksOfDeployment(stacks []provenanceStack, refs []stackRef) []provenanceStack {
+	var result []provenanceStack
+	for _, stack := range stacks {
+		if slices.ContainsFunc(refs, func(ref stackRef) bool { return ref.Name == stack.Name }) {
+			result = append(result, stack)
+		}
+	}
+	return result
+}
+
+func describeExecutedChangeSet(
+	ctx context.Context, profile string, stack stackRef, changeSetID string,
+) ([]resourceChange, error) {
+	clients, err := awsapi.New(ctx, profile, stack.Region)
+	if err != nil {
+		return nil, err
+	}
+	changes, err := ops.ChangeSetChanges(ctx, clients, stack.Name, changeSetID)
+	if err != nil {
+		return nil, err
+	}
+	return toResourceChanges(changes), nil
+}
+
+// gitProvenance returns the commit that is deployed and whether the working tree has changes on
+// top of it. Projects outside git yield no commit.
+func gitProvenance(ctx context.Context, exec cmdexec.Executor) (string, bool) {
+	sha, err := exec.Output(ctx, "git", "rev-parse", "HEAD")
+	if err != nil {
+		return "", false
+	}
+	status, err := exec.Output(ctx, "git", "status", "--porcelain")
+	return strings.TrimSpace(sha), err == nil && strings.TrimSpace(status) != ""
+}
+
+// ciRunURL returns the URL of the GitHub Actions run that deploys, if any.
+func ciRunURL() string {
+	runID := os.Getenv("GITHUB_RUN_ID")
+	if runID == "" {
+		return ""
+	}
+	return os.Getenv("GITHUB_SERVER_URL") + "/" + os.Getenv("GITHUB_REPOSITORY") + "/actions/runs/" + runID
+}
+
+// imageProvenance returns the image tags recorded for the deployment, with the digests they
+// pointed at when the deploy ran.
+func imageProvenance(
+	ctx context.Context, cfg config.Config, deploy deployProvenance, deployment string,
+) []provenanceImage {
+	tags := deploy.ImageTags[deployment]
+	images := make([]provenanceImage, 0, len(tags))
+	for name, tag := range tags {
+		images = append(images, provenanceImage{Name: name, Tag: tag})
+	}
+	slices.SortFunc(images, func(a, b provenanceImage) int { return cmp.Compare(a.Name, b.Name) })
+	if len(images) == 0 {
+		return nil
+	}
+
+	repo, err := resolveBackendRepository(ctx, cfg, deploy.Profile, deploy.Region, "")
+	if err != nil {
+		return images
+	}
+
+	ids := make([]ecrtypes.ImageIdentifier, 0, len(images))
+	for _, image := range images {
+		ids = append(ids, ecrtypes.ImageIdentifier{ImageTag: aws.String(image.Tag)})
+	}
+	out, err := repo.AWS.ECR.DescribeImages(ctx, &ecr.DescribeImagesInput{
+		RepositoryName: aws.String(extractRepoName(repo.URI)),
+		ImageIds:       ids,
+	})
+	if err != nil {
+		return images
+	}
+	for i := range images {
+		for _, d := range out.ImageDetails {
+			if slices.Contains(d.ImageTags, images[i].Tag) {
+				images[i].Digest = aws.ToString(d.ImageDigest)
+			}
+		}
+	}
+	return images
+}
+
+// provenanceBucket returns the bucket that the pre-bootstrap stack created for provenance records.
+func provenanceBucket(ctx context.Context, clients *awsapi.Clients, qualifier string) (string, error) {
+	bucket, err := ops.StackOutput(ctx, clients, ops.PreBootstrapStackName(qualifier),
+		agcdkutil.ProvenanceBucketNameOutputKey)
+	if err != nil {
+		return "", errors.Wrap(err, "provenance bucket not found, run 'ago infra cdk bootstrap' to create it")
+	}
+	return bucket, nil
+}
+
+// uploadProvenance uploads a record to the provenance bucket, which lives in the primary region.
+func uploadProvenance(ctx context.Context, profile, primaryRegion, qualifier string, record provenanceRecord) error {
+	clients, err := awsapi.New(ctx, profile, primaryRegion)
+	if err != nil {
+		return err
+	}
+	bucket, err := provenanceBucket(ctx, clients, qualifier)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(record, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal provenance record")
+	}
+
+	if _, err := clients.S3.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:      aws.String(bucket),
+		Key:         aws.String(record.objectKey()),
+		Body:        bytes.NewReader(data),
+		ContentType: aws.String("application/json"),
+	}); err != nil {
+		return errors.Wrap(err, "failed to upload provenance record")
+	}
+	return nil
+}
+
+// readProvenance downloads a record from the provenance bucket.
+func readProvenance(ctx context.Context, clients *awsapi.Clients, bucket, key string) (provenanceRecord, error) {
+	out, err := clients.S3.GetObject(ctx, &s3.GetObjectInput{Bucket: aws.String(bucket), Key: aws.String(key)})
+	if err != nil {
+		return provenanceRecord{}, errors.Wrapf(err, "failed to read provenance record %q", key)
+	}
+	defer out.Body.Close()
+
+	var record provenanceRecord
+	if err := json.NewDecoder(out.Body).Decode(&record); err != nil {
+		return provenanceRecord{}, errors.Wrapf(err, "failed to parse provenance record %q", key)
+	}
+	return record, nil
+}
+
+type provenanceShowOptions struct {
+	Deployment string
+	Profile    string
+	Limit      int
+	JSON       bool
+	Output     io.Writer
+}
+
+func runProvenanceShow(ctx context.Context, cmd *cli.Command, cfg config.Config) error {
+	return doProvenanceShow(ctx, cfg, provenanceShowOptions{
+		Deployment: cmd.String("deployment"),
+		Profile:    cmd.String("profile"),
+		Limit:      int(cmd.Int("limit")),
+		JSON:       cmd.Bool("json") || jsonOutput(cmd),
+		Output:     os.Stdout,
+	})
+}
+
+func doProvenanceShow(ctx context.Context, cfg config.Config, opts provenanceShowOptions) error {
+	cdk, err := loadCDKContext(cfg)
+	if err != nil {
+		return err
+	}
+
+	username, usernameErr := resolveCDKCallerUsername(ctx, opts.Profile, cdk.Context)
+
+	deployment, err := resolveDeploymentIdent(cfg.Inner, cdkCommandOptions{Deployment: opts.Deployment},
+		cdk.Context, username, usernameErr)
+	if err != nil {
+		return err
+	}
+
+	profile := resolveCDKProfile(opts.Profile, cdk.Context, username)
+
+	clients, err := awsapi.New(ctx, profile, cdk.PrimaryRegion)
+	if err != nil {
+		return err
+	}
+	bucket, err := provenanceBucket(ctx, clients, cdk.Qualifier)
+	if err != nil {
+		return err
+	}
+
+	var keys []string
+	pages := s3.NewListObjectsV2Paginator(clients.S3, &s3.ListObjectsV2Input{
+		Bucket: aws.String(bucket),
+		Prefix: aws.String(provenancePrefix + deployment + "/"),
+	})
+	for pages.HasMorePages() {
+		page, err := pages.NextPage(ctx)
+		if err != nil {
+			return errors.Wrap(err, "failed to list provenance records")
+		}
+		for _, object := range page.Contents {
+			keys = append(keys, aws.ToString(object.Key))
+		}
+	}
+	slices.Sort(keys)
+	slices.Reverse(keys)
+	if opts.Limit > 0 && len(keys) > opts.Limit {
+		keys = keys[:opts.Limit]
+	}
+
+	records := make([]provenanceRecord, 0, len(keys))
+	for _, key := range keys {
+		record, err := readProvenance(ctx, clients, bucket, key)
+		if err != nil {
+			return err
+		}
+		records = append(records, record)
+	}
+
+	if opts.JSON {
+		data, err := json.MarshalIndent(records, "", "  ")
+		if err != nil {
+			return errors.Wrap(err, "failed to marshal provenance records")
+		}
+		writeOutputf(opts.Output, "%s\n", data)
+		return nil
+	}
+
+	if len(records) == 0 {
+		writeOutputf(opts.Output, "No deploys of %s have been recorded\n", deployment)
+		return nil
+	}
+
+	for i, record := range records {
+		if i > 0 {
+			writeOutputf(opts.Output, "\n")
+		}
+		writeProvenanceRecord(opts.Output, record)
+	}
+	return nil
+}
+
+// writeProvenanceRecord prints a record with a summary of the changes to each stack.
+func writeProvenanceRecord(w io.Writer, r provenanceRecord) {
+	status := "succeeded"
+	if !r.Succeeded {
+		status = "failed"
+	}
+	sha := cmp.Or(r.GitSHA, "(not in git)")
+	if r.GitDirty {
+		sha += " (uncommitted changes)"
+	}
+
+	writeOutputf(w, "%s %s by %s (%s)\n", r.StartedAt.UTC().Format(time.RFC3339), status, r.Deployer,
+		r.FinishedAt.Sub(r.StartedAt).Round(time.Second))
+	writeOutputf(w, "  Commit: %s\n", sha)
+	if r.CIRun != "" {
+		writeOutputf(w, "  CI run: %s\n", r.CIRun)
+	}
+	if r.Error != "" {
+		writeOutputf(w, "  Error: %s\n", r.Error)
+	}
+	for _, image := range r.Images {
+		writeOutputf(w, "  Image %s: %s %s\n", image.Name, image.Tag, image.Digest)
+	}
+	if len(r.Stacks) == 0 {
+		writeOutputf(w, "  No stack changes\n")
+	}
+	for _, stack := range r.Stacks {
+		counts := map[string]int{}
+		for _, c := range stack.Changes {
+			counts[c.Action]++
+		}
+		writeOutputf(w, "  %s (%s): %d added, %d modified, %d removed\n", stack.Name, stack.Region,
+			counts["Add"], counts["Modify"], counts["Remove"])
+		for _, c := range stack.Changes {
+			writeOutputf(w, "    %-6s %s %s\n", c.Action, c.ResourceType, c.LogicalResourceID)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestProvenanceObjectKey(t *testing.T) {
+	t.Parallel()
+
+	started := time.Date(2026, 10, 16, 12, 30, 5, 0, time.FixedZone("CEST", 2*60*60))
+	tests := []struct {
+		name   string
+		record provenanceRecord
+		want   string
+	}{
+		{
+			name:   "git commit",
+			record: provenanceRecord{Deployment: "Prod", GitSHA: "0123456789abcdef0123", StartedAt: started},
+			want:   "deployments/Prod/20261016T103005Z-0123456789ab.json",
+		},
+		{
+			name:   "no git",
+			record: provenanceRecord{Deployment: "DevAdam", StartedAt: started},
+			want:   "deployments/DevAdam/20261016T103005Z-nogit.json",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := tt.record.objectKey(); got != tt.want {
+				t.Errorf("objectKey() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStacksOfDeployment(t *testing.T) {
+	t.Parallel()
+
+	regions := []string{"eu-central-1"}
+	changed := []provenanceStack{}
+	for _, ref := range deployStacks("myapp", regions, []string{"Dev", "Prod"}) {
+		changed = append(changed, provenanceStack{Name: ref.Name, Region: ref.Region})
+	}
+
+	got := stacksOfDeployment(changed, deployStacks("myapp", regions, []string{"Prod"}))
+	names := make([]string, 0, len(got))
+	for _, stack := range got {
+		names = append(names, stack.Name)
+	}
+
+	if len(names) != 2 || strings.Contains(strings.Join(names, " "), "Dev") {
+		t.Errorf("expected the shared and Prod stacks, got %v", names)
+	}
+}
+
+func TestWriteProvenanceRecord(t *testing.T) {
+	t.Parallel()
+
+	started := time.Date(2026, 10, 16, 10, 0, 0, 0, time.UTC)
+	var buf bytes.Buffer
+	writeProvenanceRecord(&buf, provenanceRecord{
+		Deployment: "Prod",
+		Deployer:   "adam",
+		GitSHA:     "0123456789ab",
+		GitDirty:   true,
+		StartedAt:  started,
+		FinishedAt: started.Add(95 * time.Second),
+		Succeeded:  true,
+		Images:     []provenanceImage{{Name: "api", Tag: "api-Prod-abc", Digest: "sha256:def"}},
+		Stacks: []provenanceStack{{
+			Name:   "myappUse1Prod",
+			Region: "us-east-1",
+			Changes: []resourceChange{
+				{Action: "Add", ResourceType: "AWS::SQS::Queue", LogicalResourceID: "Jobs"},
+				{Action: "Modify", ResourceType: "AWS::Lambda::Function", LogicalResourceID: "Api"},
+			},
+		}},
+	})
+
+	for _, want := range []string{
+		"2026-10-16T10:00:00Z succeeded by adam (1m35s)",
+		"Commit: 0123456789ab (uncommitted changes)",
+		"Image api: api-Prod-abc sha256:def",
+		"myappUse1Prod (us-east-1): 1 added, 1 modified, 0 removed",
+		"Add    AWS::SQS::Queue Jobs",
+	} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("output does not contain %q:\n%s", want, buf.String())
+		}
+	}
+}
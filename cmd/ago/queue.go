@@ -0,0 +1,384 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"maps"
+	"os"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/advdv/ago/agcdk/agcdkqueue"
+	"github.com/advdv/ago/cmd/ago/internal/awsapi"
+	"github.com/advdv/ago/cmd/ago/internal/config"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	sqstypes "github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/cockroachdb/errors"
+	"github.com/urfave/cli/v3"
+)
+
+// queuePeekVisibility is how long peeked messages stay hidden. It only has to outlast a single
+// inspect or redrive, which makes the messages visible again when it is done with them.
+const queuePeekVisibility = 60
+
+func queueCmd() *cli.Command {
+	return &cli.Command{
+		Name:  "queue",
+		Usage: "Inspect and redrive the dead-letter queues of agcdkqueue queues",
+		Commands: []*cli.Command{
+			queueInspectCmd(),
+			queueRedriveCmd(),
+		},
+	}
+}
+
+// queueFlags returns the flags that select a queue, shared by the queue commands.
+func queueFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringFlag{
+			Name:  "deployment",
+			Usage: "Deployment that owns the queue (default: the caller's Dev deployment)",
+		},
+		&cli.StringFlag{
+			Name:     "queue",
+			Usage:    "Name of the queue, as given to agcdkqueue.New",
+			Required: true,
+		},
+	}
+}
+
+func queueInspectCmd() *cli.Command {
+	return &cli.Command{
+		Name:  "inspect",
+		Usage: "Show the message counts of a queue and peek at the messages in its dead-letter queue",
+		Flags: append(queueFlags(), &cli.IntFlag{
+			Name:  "max",
+			Usage: "Maximum number of dead-letter messages to show",
+			Value: 10,
+		}),
+		Action: config.RunWithConfig(runQueueInspect),
+	}
+}
+
+func queueRedriveCmd() *cli.Command {
+	return &cli.Command{
+		Name:  "redrive",
+		Usage: "Move messages from the dead-letter queue back to the queue",
+		Flags: append(queueFlags(), &cli.StringSliceFlag{
+			Name:  "message-id",
+			Usage: "Only redrive the message with this ID (repeatable, default: all messages)",
+		}),
+		Action: config.RunWithConfig(runQueueRedrive),
+	}
+}
+
+type queueOptions struct {
+	Deployment    string
+	Queue         string
+	Profile       string
+	Region        string
+	ScopedSession bool
+	Max           int
+	MessageIDs    []string
+	Output        io.Writer
+}
+
+func runQueueInspect(ctx context.Context, cmd *cli.Command, cfg config.Config) error {
+	return doQueueInspect(ctx, cfg, queueOptions{
+		Deployment:    cmd.String("deployment"),
+		Queue:         cmd.String("queue"),
+		Profile:       cmd.String("profile"),
+		Region:        cmd.String("region"),
+		ScopedSession: cmd.Bool("scoped-session"),
+		Max:           int(cmd.Int("max")),
+		Output:        os.Stdout,
+	})
+}
+
+func runQueueRedrive(ctx context.Context, cmd *cli.Command, cfg config.Config) error {
+	return doQueueRedrive(ctx, cfg, queueOptions{
+		Deployment:    cmd.String("deployment"),
+		Queue:         cmd.String("queue"),
+		Profile:       cmd.String("profile"),
+		Region:        cmd.String("region"),
+		ScopedSession: cmd.Bool("scoped-session"),
+		MessageIDs:    cmd.StringSlice("message-id"),
+		Output:        os.Stdout,
+	})
+}
+
+func doQueueInspect(ctx context.Context, cfg config.Config, opts queueOptions) error {
+	target, clients, err := resolveQueue(ctx, cfg, opts, "queue-inspect", queueInspectActions...)
+	if err != nil {
+		return err
+	}
+	return inspectQueue(ctx, clients.SQS, opts.Output, target, opts.Max, time.Now())
+}
+
+func doQueueRedrive(ctx context.Context, cfg config.Config, opts queueOptions) error {
+	target, clients, err := resolveQueue(ctx, cfg, opts, "queue-redrive", queueRedriveActions...)
+	if err != nil {
+		return err
+	}
+	return redriveQueue(ctx, clients.SQS, opts.Output, target, opts.MessageIDs)
+}
+
+// queueTarget is a queue created by agcdkqueue and its dead-letter queue.
+type queueTarget struct {
+	Name          string
+	URL           string
+	DeadLetterURL string
+}
+
+// queueInspectActions are the actions that inspect takes on a queue and its dead-letter queue.
+var queueInspectActions = []string{"sqs:GetQueueAttributes", "sqs:ReceiveMessage", "sqs:ChangeMessageVisibility"}
+
+// queueRedriveActions are the actions that redrive takes on a queue and its dead-letter queue.
+var queueRedriveActions = append(slices.Clone(queueInspectActions),
+	"sqs:SendMessage", "sqs:DeleteMessage", "sqs:StartMessageMoveTask")
+
+// resolveQueue finds the URLs of a queue in the output registries of the deployment stack and
+// the shared stack, in that order. A scoped session may only take the actions on the queue and its
+// dead-letter queue.
+func resolveQueue(
+	ctx context.Context, cfg config.Config, opts queueOptions, command string, actions ...string,
+) (queueTarget, *awsapi.Clients, error) {
+	stacks, err := resolveDeploymentStacks(ctx, cfg, opts.Deployment, opts.Profile, opts.Region)
+	if err != nil {
+		return queueTarget{}, nil, err
+	}
+	stackNames := []string{stacks.Stack, stacks.SharedStack}
+
+	target := queueTarget{Name: opts.Queue}
+	for _, stackName := range stackNames {
+		outputs, err := stacks.outputs(ctx, stackName)
+		if err != nil {
+			return queueTarget{}, nil, err
+		}
+		url := outputs[agcdkqueue.URLOutputKey(opts.Queue)]
+		dlqURL := outputs[agcdkqueue.DeadLetterURLOutputKey(opts.Queue)]
+		if url != "" && dlqURL != "" {
+			target.URL, target.DeadLetterURL = url, dlqURL
+			break
+		}
+	}
+	if target.URL == "" {
+		return queueTarget{}, nil, errors.Errorf("queue %q not found in the outputs of %s",
+			opts.Queue, strings.Join(stackNames, " or "))
+	}
+
+	clients, err := stacks.clients(ctx, opts.ScopedSession, command, func(account sessionAccount) []sessionStatement {
+		return []sessionStatement{allow([]string{
+			account.arn("sqs", path.Base(target.URL)),
+			account.arn("sqs", path.Base(target.DeadLetterURL)),
+		}, actions...)}
+	})
+	if err != nil {
+		return queueTarget{}, nil, err
+	}
+
+	return target, clients, nil
+}
+
+// inspectQueue prints the message counts of the queue and up to max messages of its dead-letter
+// queue, with their age, receive count and pretty-printed body.
+func inspectQueue(
+	ctx context.Context, client awsapi.SQS, output io.Writer, target queueTarget, maxMessages int, now time.Time,
+) error {
+	counts, err := queueAttributes(ctx, client, target.URL)
+	if err != nil {
+		return err
+	}
+	dlqCounts, err := queueAttributes(ctx, client, target.DeadLetterURL)
+	if err != nil {
+		return err
+	}
+
+	writeOutputf(output, "Queue %s: %s visible, %s in flight\n", target.Name,
+		counts[string(sqstypes.QueueAttributeNameApproximateNumberOfMessages)],
+		counts[string(sqstypes.QueueAttributeNameApproximateNumberOfMessagesNotVisible)])
+	writeOutputf(output, "Dead-letter queue: %s messages\n",
+		dlqCounts[string(sqstypes.QueueAttributeNameApproximateNumberOfMessages)])
+
+	messages, err := peekMessages(ctx, client, target.DeadLetterURL, maxMessages)
+	if err != nil {
+		return err
+	}
+	defer releaseMessages(ctx, client, target.DeadLetterURL, messages)
+
+	for _, msg := range messages {
+		writeOutputf(output, "\nMessage %s\n", aws.ToString(msg.MessageId))
+		writeOutputf(output, "  Age: %s\n", formatMessageAge(msg.Attributes, now))
+		writeOutputf(output, "  Receive count: %s\n",
+			msg.Attributes[string(sqstypes.MessageSystemAttributeNameApproximateReceiveCount)])
+		for _, name := range slices.Sorted(maps.Keys(msg.MessageAttributes)) {
+			writeOutputf(output, "  Attribute %s: %s\n", name, aws.ToString(msg.MessageAttributes[name].StringValue))
+		}
+		writeOutputf(output, "%s\n", indentLines(formatMessageBody(aws.ToString(msg.Body)), "  "))
+	}
+
+	return nil
+}
+
+// redriveQueue moves messages from the dead-letter queue back to the queue. Without message IDs
+// it starts an SQS message move task for all messages; with message IDs it sends those messages
+// to the queue itself and deletes them from the dead-letter queue.
+func redriveQueue(
+	ctx context.Context, client awsapi.SQS, output io.Writer, target queueTarget, messageIDs []string,
+) error {
+	if len(messageIDs) == 0 {
+		dlqAttrs, err := queueAttributes(ctx, client, target.DeadLetterURL)
+		if err != nil {
+			return err
+		}
+		if _, err := client.StartMessageMoveTask(ctx, &sqs.StartMessageMoveTaskInput{
+			SourceArn: aws.String(dlqAttrs[string(sqstypes.QueueAttributeNameQueueArn)]),
+		}); err != nil {
+			return errors.Wrapf(err, "failed to start redrive of the dead-letter queue of %q", target.Name)
+		}
+		writeOutputf(output, "Started redrive of %s messages to queue %s.\n",
+			dlqAttrs[string(sqstypes.QueueAttributeNameApproximateNumberOfMessages)], target.Name)
+		return nil
+	}
+
+	messages, err := peekMessages(ctx, client, target.DeadLetterURL, 0)
+	if err != nil {
+		return err
+	}
+
+	var keep []sqstypes.Message
+	defer func() { releaseMessages(ctx, client, target.DeadLetterURL, keep) }()
+
+	redriven := map[string]bool{}
+	for i, msg := range messages {
+		id := aws.ToString(msg.MessageId)
+		if !slices.Contains(messageIDs, id) {
+			keep = append(keep, msg)
+			continue
+		}
+
+		if _, err := client.SendMessage(ctx, &sqs.SendMessageInput{
+			QueueUrl:          aws.String(target.URL),
+			MessageBody:       msg.Body,
+			MessageAttributes: msg.MessageAttributes,
+		}); err != nil {
+			keep = append(keep, messages[i:]...)
+			return errors.Wrapf(err, "failed to send message %s to queue %q", id, target.Name)
+		}
+		if _, err := client.DeleteMessage(ctx, &sqs.DeleteMessageInput{
+			QueueUrl:      aws.String(target.DeadLetterURL),
+			ReceiptHandle: msg.ReceiptHandle,
+		}); err != nil {
+			keep = append(keep, messages[i+1:]...)
+			return errors.Wrapf(err, "message %s was sent to queue %q but not deleted from the dead-letter queue",
+				id, target.Name)
+		}
+
+		redriven[id] = true
+		writeOutputf(output, "Redrove message %s to queue %s.\n", id, target.Name)
+	}
+
+	var missing []string
+	for _, id := range messageIDs {
+		if !redriven[id] {
+			missing = append(missing, id)
+		}
+	}
+	if len(missing) > 0 {
+		return errors.Errorf("messages not found in the dead-letter queue: %s", strings.Join(missing, ", "))
+	}
+
+	return nil
+}
+
+// queueAttributes returns the attributes of a queue that the queue commands report.
+func queueAttributes(ctx context.Context, client awsapi.SQS, url string) (map[string]string, error) {
+	out, err := client.GetQueueAttributes(ctx, &sqs.GetQueueAttributesInput{
+		QueueUrl: aws.String(url),
+		AttributeNames: []sqstypes.QueueAttributeName{
+			sqstypes.QueueAttributeNameQueueArn,
+			sqstypes.QueueAttributeNameApproximateNumberOfMessages,
+			sqstypes.QueueAttributeNameApproximateNumberOfMessagesNotVisible,
+		},
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get attributes of queue %s", url)
+	}
+	return out.Attributes, nil
+}
+
+// peekMessages receives up to maxMessages messages, or all visible messages when maxMessages is
+// zero. It long-polls briefly, so that an empty answer means the queue has no more visible
+// messages rather than that the sampled servers had none. The messages stay hidden until they
+// are deleted or released with releaseMessages.
+func peekMessages(
+	ctx context.Context, client awsapi.SQS, url string, maxMessages int,
+) ([]sqstypes.Message, error) {
+	var messages []sqstypes.Message
+	for maxMessages == 0 || len(messages) < maxMessages {
+		batch := int32(10)
+		if maxMessages > 0 {
+			batch = int32(min(10, maxMessages-len(messages))) //nolint:gosec // at most 10
+		}
+
+		out, err := client.ReceiveMessage(ctx, &sqs.ReceiveMessageInput{
+			QueueUrl:                    aws.String(url),
+			MaxNumberOfMessages:         batch,
+			VisibilityTimeout:           queuePeekVisibility,
+			WaitTimeSeconds:             1,
+			MessageAttributeNames:       []string{"All"},
+			MessageSystemAttributeNames: []sqstypes.MessageSystemAttributeName{sqstypes.MessageSystemAttributeNameAll},
+		})
+		if err != nil {
+			releaseMessages(ctx, client, url, messages)
+			return nil, errors.Wrapf(err, "failed to receive messages from queue %s", url)
+		}
+		if len(out.Messages) == 0 {
+			break
+		}
+		messages = append(messages, out.Messages...)
+	}
+	return messages, nil
+}
+
+// releaseMessages makes peeked messages visible again. It is best-effort: messages it fails to
+// release become visible when queuePeekVisibility passes.
+func releaseMessages(ctx context.Context, client awsapi.SQS, url string, messages []sqstypes.Message) {
+	for _, msg := range messages {
+		_, _ = client.ChangeMessageVisibility(ctx, &sqs.ChangeMessageVisibilityInput{
+			QueueUrl:          aws.String(url),
+			ReceiptHandle:     msg.ReceiptHandle,
+			VisibilityTimeout: 0,
+		})
+	}
+}
+
+// formatMessageAge returns how long ago the message was first sent, from its SentTimestamp
+// attribute in epoch milliseconds.
+func formatMessageAge(attributes map[string]string, now time.Time) string {
+	ms, err := strconv.ParseInt(attributes[string(sqstypes.MessageSystemAttributeNameSentTimestamp)], 10, 64)
+	if err != nil {
+		return "unknown"
+	}
+	sent := time.UnixMilli(ms)
+	return now.Sub(sent).Truncate(time.Second).String() + " (sent " + sent.UTC().Format(time.RFC3339) + ")"
+}
+
+// formatMessageBody pretty-prints a JSON body and returns any other body unchanged.
+func formatMessageBody(body string) string {
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, []byte(body), "", "  "); err != nil {
+		return body
+	}
+	return buf.String()
+}
+
+func indentLines(s, indent string) string {
+	return indent + strings.ReplaceAll(s, "\n", "\n"+indent)
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/advdv/ago/cmd/ago/internal/awsapi"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	sqstypes "github.com/aws/aws-sdk-go-v2/service/sqs/types"
+)
+
+// fakeSQS serves the messages of a single dead-letter queue, each message once, and records
+// what is sent, deleted and released.
+type fakeSQS struct {
+	awsapi.SQS
+	messages []sqstypes.Message
+	sent     []string
+	deleted  []string
+	released []string
+}
+
+func (f *fakeSQS) ReceiveMessage(
+	_ context.Context, in *sqs.ReceiveMessageInput, _ ...func(*sqs.Options),
+) (*sqs.ReceiveMessageOutput, error) {
+	n := min(int(in.MaxNumberOfMessages), len(f.messages))
+	out := &sqs.ReceiveMessageOutput{Messages: f.messages[:n]}
+	f.messages = f.messages[n:]
+	return out, nil
+}
+
+func (f *fakeSQS) SendMessage(
+	_ context.Context, in *sqs.SendMessageInput, _ ...func(*sqs.Options),
+) (*sqs.SendMessageOutput, error) {
+	f.sent = append(f.sent, aws.ToString(in.MessageBody))
+	return &sqs.SendMessageOutput{}, nil
+}
+
+func (f *fakeSQS) DeleteMessage(
+	_ context.Context, in *sqs.DeleteMessageInput, _ ...func(*sqs.Options),
+) (*sqs.DeleteMessageOutput, error) {
+	f.deleted = append(f.deleted, aws.ToString(in.ReceiptHandle))
+	return &sqs.DeleteMessageOutput{}, nil
+}
+
+func (f *fakeSQS) ChangeMessageVisibility(
+	_ context.Context, in *sqs.ChangeMessageVisibilityInput, _ ...func(*sqs.Options),
+) (*sqs.ChangeMessageVisibilityOutput, error) {
+	f.released = append(f.released, aws.ToString(in.ReceiptHandle))
+	return &sqs.ChangeMessageVisibilityOutput{}, nil
+}
+
+func TestRedriveQueue_Selected(t *testing.T) {
+	t.Parallel()
+
+	client := &fakeSQS{}
+	for _, id := range []string{"m1", "m2", "m3"} {
+		client.messages = append(client.messages, sqstypes.Message{
+			MessageId: aws.String(id), ReceiptHandle: aws.String("rh-" + id), Body: aws.String("body-" + id),
+		})
+	}
+
+	target := queueTarget{Name: "orders", URL: "https://sqs/orders", DeadLetterURL: "https://sqs/orders-dlq"}
+	var buf bytes.Buffer
+	err := redriveQueue(context.Background(), client, &buf, target, []string{"m2", "m9"})
+	if err == nil || !strings.Contains(err.Error(), "not found in the dead-letter queue: m9") {
+		t.Fatalf("expected error for missing message, got %v", err)
+	}
+
+	if !slices.Equal(client.sent, []string{"body-m2"}) {
+		t.Errorf("sent = %v, want [body-m2]", client.sent)
+	}
+	if !slices.Equal(client.deleted, []string{"rh-m2"}) {
+		t.Errorf("deleted = %v, want [rh-m2]", client.deleted)
+	}
+	if !slices.Equal(client.released, []string{"rh-m1", "rh-m3"}) {
+		t.Errorf("released = %v, want [rh-m1 rh-m3]", client.released)
+	}
+	if !strings.Contains(buf.String(), "Redrove message m2 to queue orders.") {
+		t.Errorf("unexpected output: %s", buf.String())
+	}
+}
+
+func TestFormatMessageBody(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{name: "json", body: `{"order":"o-1","items":[1]}`, want: "{\n  \"order\": \"o-1\",\n  \"items\": [\n    1\n  ]\n}"},
+		{name: "text", body: "not json", want: "not json"},
+		{name: "empty", body: "", want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := formatMessageBody(tt.body); got != tt.want {
+				t.Errorf("formatMessageBody(%q) = %q, want %q", tt.body, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormatMessageAge(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	attrs := map[string]string{"SentTimestamp": strconv.FormatInt(now.Add(-90*time.Minute).UnixMilli(), 10)}
+
+	if got, want := formatMessageAge(attrs, now), "1h30m0s (sent 2026-10-16T10:30:00Z)"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if got := formatMessageAge(nil, now); got != "unknown" {
+		t.Errorf("expected unknown age without timestamp, got %q", got)
+	}
+}
@@ -0,0 +1,14 @@
+package main
+
+import "github.com/urfave/cli/v3"
+
+func reportCmd() *cli.Command {
+	return &cli.Command{
+		Name:  "report",
+		Usage: "Generate reports from live AWS state",
+		Commands: []*cli.Command{
+			reportComplianceCmd(),
+			reportInventoryCmd(),
+		},
+	}
+}
@@ -0,0 +1,433 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/url"
+	"os"
+	"slices"
+	"text/template"
+	"time"
+
+	"github.com/advdv/ago/agcdkutil"
+	"github.com/advdv/ago/cmd/ago/internal/awsapi"
+	"github.com/advdv/ago/cmd/ago/internal/config"
+	"github.com/advdv/ago/cmd/ago/internal/ops"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudtrail"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+	iamtypes "github.com/aws/aws-sdk-go-v2/service/iam/types"
+	"github.com/cockroachdb/errors"
+	"github.com/urfave/cli/v3"
+)
+
+func reportComplianceCmd() *cli.Command {
+	return &cli.Command{
+		Name:  "compliance",
+		Usage: "Compile IAM guardrails, deployers and audit logging status into a report for auditors",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:  "format",
+				Usage: "Report format (markdown or json)",
+				Value: "markdown",
+			},
+			&cli.IntFlag{
+				Name:  "max-key-age",
+				Usage: "Access key age in days after which a key is flagged for rotation",
+				Value: 90,
+			},
+		},
+		Action: config.RunWithConfig(runReportCompliance),
+	}
+}
+
+type reportComplianceOptions struct {
+	Profile   string
+	Format    string
+	MaxKeyAge int
+	Now       time.Time
+	Output    io.Writer
+}
+
+func runReportCompliance(ctx context.Context, cmd *cli.Command, cfg config.Config) error {
+	return doReportCompliance(ctx, cfg, reportComplianceOptions{
+		Profile:   cmd.String("profile"),
+		Format:    cmd.String("format"),
+		MaxKeyAge: int(cmd.Int("max-key-age")),
+		Now:       time.Now(),
+		Output:    os.Stdout,
+	})
+}
+
+type complianceReport struct {
+	Project              string               `json:"project"`
+	AccountID            string               `json:"account_id"`
+	GeneratedAt          time.Time            `json:"generated_at"`
+	PermissionsBoundary  compliancePolicy     `json:"permissions_boundary"`
+	ExecutionPolicy      compliancePolicy     `json:"execution_policy"`
+	Deployers            []complianceDeployer `json:"deployers"`
+	Deployments          []complianceDeploy   `json:"deployments"`
+	FullDeployersGroup   string               `json:"full_deployers_group"`
+	FullDeployersMembers []string             `json:"full_deployers_members"`
+	Trails               []complianceTrail    `json:"trails"`
+	MaxKeyAgeDays        int                  `json:"max_key_age_days"`
+	Findings             []string             `json:"findings"`
+}
+
+type compliancePolicy struct {
+	Name      string          `json:"name"`
+	Arn       string          `json:"arn"`
+	VersionID string          `json:"version_id"`
+	Document  json.RawMessage `json:"document"`
+}
+
+type complianceDeployer struct {
+	Username   string                `json:"username"`
+	Kind       string                `json:"kind"`
+	AccessKeys []complianceAccessKey `json:"access_keys"`
+}
+
+type complianceAccessKey struct {
+	AccessKeyID string    `json:"access_key_id"`
+	Status      string    `json:"status"`
+	CreatedAt   time.Time `json:"created_at"`
+	AgeDays     int       `json:"age_days"`
+	Stale       bool      `json:"stale"`
+}
+
+type complianceDeploy struct {
+	Name       string `json:"name"`
+	Restricted bool   `json:"restricted"`
+}
+
+type complianceTrail struct {
+	Name          string `json:"name"`
+	HomeRegion    string `json:"home_region"`
+	MultiRegion   bool   `json:"multi_region"`
+	IsLogging     bool   `json:"is_logging"`
+	S3BucketName  string `json:"s3_bucket_name"`
+	LogValidation bool   `json:"log_file_validation"`
+}
+
+func doReportCompliance(ctx context.Context, cfg config.Config, opts reportComplianceOptions) error {
+	if opts.Format != "markdown" && opts.Format != "json" {
+		return errors.Errorf("unsupported format %q (use markdown or json)", opts.Format)
+	}
+
+	cdk, err := loadCDKContext(cfg)
+	if err != nil {
+		return err
+	}
+
+	profile, err := resolveAWSProfile(opts.Profile, func() (string, error) { return getAdminProfile(cdk.Context) })
+	if err != nil {
+		return err
+	}
+
+	clients, err := awsapi.New(ctx, profile, "")
+	if err != nil {
+		return err
+	}
+
+	accountID, err := ops.AccountID(ctx, clients)
+	if err != nil {
+		return err
+	}
+
+	partition, err := getAWSPartition(ctx, clients)
+	if err != nil {
+		return err
+	}
+
+	report := complianceReport{
+		Project:            cdk.Qualifier,
+		AccountID:          accountID,
+		GeneratedAt:        opts.Now.UTC(),
+		FullDeployersGroup: ops.DeployersGroupName(cdk.Qualifier),
+		MaxKeyAgeDays:      opts.MaxKeyAge,
+	}
+
+	report.PermissionsBoundary, err = getManagedPolicy(ctx, clients, partition, accountID,
+		agcdkutil.PermissionsBoundaryName(cdk.Qualifier))
+	if err != nil {
+		return err
+	}
+
+	report.ExecutionPolicy, err = getManagedPolicy(ctx, clients, partition, accountID,
+		cdk.Qualifier+"-execution-policy")
+	if err != nil {
+		return err
+	}
+
+	deployerKinds := []struct{ kind, key string }{
+		{"deployer", "deployers"},
+		{"dev-deployer", "dev-deployers"},
+	}
+	for _, dk := range deployerKinds {
+		for _, username := range cdk.Strings(dk.key) {
+			keys, err := listUserAccessKeys(ctx, clients, username, opts.Now, opts.MaxKeyAge)
+			if err != nil {
+				return err
+			}
+			report.Deployers = append(report.Deployers, complianceDeployer{
+				Username:   username,
+				Kind:       dk.kind,
+				AccessKeys: keys,
+			})
+		}
+	}
+
+	for _, deployment := range cdk.Deployments {
+		report.Deployments = append(report.Deployments, complianceDeploy{
+			Name:       deployment,
+			Restricted: isRestrictedDeployment(deployment),
+		})
+	}
+
+	report.FullDeployersMembers, err = getGroupMembers(ctx, clients, report.FullDeployersGroup)
+	if err != nil {
+		return err
+	}
+
+	report.Trails, err = listCloudTrails(ctx, clients, profile)
+	if err != nil {
+		return err
+	}
+
+	report.Findings = complianceFindings(report)
+
+	if opts.Format == "json" {
+		output, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			return errors.Wrap(err, "failed to marshal report")
+		}
+		writeOutputf(opts.Output, "%s\n", output)
+		return nil
+	}
+
+	return renderComplianceMarkdown(opts.Output, report)
+}
+
+func getManagedPolicy(
+	ctx context.Context, clients *awsapi.Clients, partition, accountID, policyName string,
+) (compliancePolicy, error) {
+	policyArn := agcdkutil.ARN(partition, "iam", "", accountID, "policy/"+policyName)
+
+	policy, err := clients.IAM.GetPolicy(ctx, &iam.GetPolicyInput{PolicyArn: aws.String(policyArn)})
+	if err != nil {
+		return compliancePolicy{}, errors.Wrapf(err, "failed to get policy %q", policyName)
+	}
+	if policy.Policy == nil {
+		return compliancePolicy{}, errors.Errorf("policy %q not found", policyName)
+	}
+	versionID := aws.ToString(policy.Policy.DefaultVersionId)
+
+	version, err := clients.IAM.GetPolicyVersion(ctx, &iam.GetPolicyVersionInput{
+		PolicyArn: aws.String(policyArn),
+		VersionId: aws.String(versionID),
+	})
+	if err != nil {
+		return compliancePolicy{}, errors.Wrapf(err, "failed to get policy document for %q", policyName)
+	}
+	if version.PolicyVersion == nil {
+		return compliancePolicy{}, errors.Errorf("policy version %s of %q not found", versionID, policyName)
+	}
+
+	// IAM returns policy documents URL-encoded.
+	document, err := url.QueryUnescape(aws.ToString(version.PolicyVersion.Document))
+	if err != nil {
+		return compliancePolicy{}, errors.Wrapf(err, "failed to decode policy document for %q", policyName)
+	}
+	if !json.Valid([]byte(document)) {
+		return compliancePolicy{}, errors.Errorf("policy document for %q is not valid JSON", policyName)
+	}
+
+	return compliancePolicy{
+		Name:      policyName,
+		Arn:       policyArn,
+		VersionID: versionID,
+		Document:  json.RawMessage(document),
+	}, nil
+}
+
+func listUserAccessKeys(
+	ctx context.Context, clients *awsapi.Clients, username string, now time.Time, maxAgeDays int,
+) ([]complianceAccessKey, error) {
+	keys := []complianceAccessKey{}
+	pages := iam.NewListAccessKeysPaginator(clients.IAM, &iam.ListAccessKeysInput{UserName: aws.String(username)})
+	for pages.HasMorePages() {
+		page, err := pages.NextPage(ctx)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to list access keys for %s", username)
+		}
+		for _, m := range page.AccessKeyMetadata {
+			createDate := aws.ToTime(m.CreateDate)
+			age := int(now.Sub(createDate).Hours() / 24)
+			keys = append(keys, complianceAccessKey{
+				AccessKeyID: aws.ToString(m.AccessKeyId),
+				Status:      string(m.Status),
+				CreatedAt:   createDate.UTC(),
+				AgeDays:     age,
+				Stale:       m.Status == iamtypes.StatusTypeActive && age > maxAgeDays,
+			})
+		}
+	}
+
+	return keys, nil
+}
+
+func getGroupMembers(ctx context.Context, clients *awsapi.Clients, groupName string) ([]string, error) {
+	var members []string
+	pages := iam.NewGetGroupPaginator(clients.IAM, &iam.GetGroupInput{GroupName: aws.String(groupName)})
+	for pages.HasMorePages() {
+		page, err := pages.NextPage(ctx)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to get members of group %q", groupName)
+		}
+		for _, user := range page.Users {
+			members = append(members, aws.ToString(user.UserName))
+		}
+	}
+
+	slices.Sort(members)
+	return members, nil
+}
+
+// listCloudTrails returns the trails of the account with their logging status, which is read in
+// the home region of each trail.
+func listCloudTrails(ctx context.Context, clients *awsapi.Clients, profile string) ([]complianceTrail, error) {
+	trails, err := clients.CloudTrail.DescribeTrails(ctx, &cloudtrail.DescribeTrailsInput{})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to describe CloudTrail trails")
+	}
+
+	result := make([]complianceTrail, 0, len(trails.TrailList))
+	for _, t := range trails.TrailList {
+		home, err := awsapi.New(ctx, profile, aws.ToString(t.HomeRegion))
+		if err != nil {
+			return nil, err
+		}
+		status, err := home.CloudTrail.GetTrailStatus(ctx, &cloudtrail.GetTrailStatusInput{Name: t.TrailARN})
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to get status of trail %q", aws.ToString(t.Name))
+		}
+
+		result = append(result, complianceTrail{
+			Name:          aws.ToString(t.Name),
+			HomeRegion:    aws.ToString(t.HomeRegion),
+			MultiRegion:   aws.ToBool(t.IsMultiRegionTrail),
+			IsLogging:     aws.ToBool(status.IsLogging),
+			S3BucketName:  aws.ToString(t.S3BucketName),
+			LogValidation: aws.ToBool(t.LogFileValidationEnabled),
+		})
+	}
+
+	return result, nil
+}
+
+// complianceFindings derives human-readable findings that an auditor should follow up on.
+func complianceFindings(report complianceReport) []string {
+	findings := []string{}
+
+	for _, d := range report.Deployers {
+		for _, k := range d.AccessKeys {
+			if k.Stale {
+				findings = append(findings, "access key "+k.AccessKeyID+" of "+d.Username+
+					" is older than the maximum key age")
+			}
+		}
+	}
+
+	devDeployers := make(map[string]bool)
+	for _, d := range report.Deployers {
+		if d.Kind == "dev-deployer" {
+			devDeployers[d.Username] = true
+		}
+	}
+	for _, member := range report.FullDeployersMembers {
+		if devDeployers[member] {
+			findings = append(findings, "dev-deployer "+member+" is a member of "+report.FullDeployersGroup)
+		}
+	}
+
+	logging := false
+	for _, t := range report.Trails {
+		if t.IsLogging && t.MultiRegion {
+			logging = true
+		}
+	}
+	if !logging {
+		findings = append(findings, "no multi-region CloudTrail trail is actively logging")
+	}
+
+	return findings
+}
+
+var complianceMarkdownTemplate = template.Must(template.New("compliance.md").Parse(`# Compliance report: {{.Project}}
+
+- Account: {{.AccountID}}
+- Generated at: {{.GeneratedAt.Format "2006-01-02T15:04:05Z07:00"}}
+
+## Findings
+{{if .Findings}}{{range .Findings}}
+- {{.}}{{end}}{{else}}
+No findings.{{end}}
+
+## Permissions boundary
+
+- Name: {{.PermissionsBoundary.Name}}
+- ARN: {{.PermissionsBoundary.Arn}}
+- Version: {{.PermissionsBoundary.VersionID}}
+
+` + "```json" + `
+{{printf "%s" .PermissionsBoundary.Document}}
+` + "```" + `
+
+## Execution policy
+
+- Name: {{.ExecutionPolicy.Name}}
+- ARN: {{.ExecutionPolicy.Arn}}
+- Version: {{.ExecutionPolicy.VersionID}}
+
+` + "```json" + `
+{{printf "%s" .ExecutionPolicy.Document}}
+` + "```" + `
+
+## Deployers
+
+| Username | Kind | Access key | Status | Created | Age (days) |
+|----------|------|------------|--------|---------|------------|
+{{- range $d := .Deployers}}{{range $d.AccessKeys}}
+| {{$d.Username}} | {{$d.Kind}} | {{.AccessKeyID}} | {{.Status}} | {{.CreatedAt.Format "2006-01-02"}} | {{.AgeDays}}{{if .Stale}} (stale){{end}} |
+{{- else}}
+| {{$d.Username}} | {{$d.Kind}} | - | - | - | - |
+{{- end}}{{end}}
+
+## Restricted deployments
+
+Members of {{.FullDeployersGroup}}: {{if .FullDeployersMembers}}{{range $i, $m := .FullDeployersMembers}}{{if $i}}, {{end}}{{$m}}{{end}}{{else}}(none){{end}}
+
+| Deployment | Requires full deployer |
+|------------|------------------------|
+{{- range .Deployments}}
+| {{.Name}} | {{if .Restricted}}yes{{else}}no{{end}} |
+{{- end}}
+
+## Audit logging
+
+| Trail | Home region | Multi-region | Logging | Log validation | Bucket |
+|-------|-------------|--------------|---------|----------------|--------|
+{{- range .Trails}}
+| {{.Name}} | {{.HomeRegion}} | {{.MultiRegion}} | {{.IsLogging}} | {{.LogValidation}} | {{.S3BucketName}} |
+{{- else}}
+| (none) | - | - | - | - | - |
+{{- end}}
+`))
+
+func renderComplianceMarkdown(w io.Writer, report complianceReport) error {
+	if err := complianceMarkdownTemplate.Execute(w, report); err != nil {
+		return errors.Wrap(err, "failed to render compliance report")
+	}
+	return nil
+}
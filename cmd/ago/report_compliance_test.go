@@ -0,0 +1,81 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestComplianceFindings(t *testing.T) {
+	t.Parallel()
+
+	report := complianceReport{
+		FullDeployersGroup:   "myapp-deployers",
+		FullDeployersMembers: []string{"Adam", "Bob"},
+		Deployers: []complianceDeployer{
+			{Username: "Adam", Kind: "deployer", AccessKeys: []complianceAccessKey{
+				{AccessKeyID: "AKIAOLD", Status: "Active", Stale: true},
+			}},
+			{Username: "Bob", Kind: "dev-deployer"},
+		},
+		Trails: []complianceTrail{{Name: "org-trail", MultiRegion: true, IsLogging: false}},
+	}
+
+	findings := complianceFindings(report)
+	want := []string{"AKIAOLD", "dev-deployer Bob", "CloudTrail"}
+	if len(findings) != len(want) {
+		t.Fatalf("expected %d findings, got %d: %v", len(want), len(findings), findings)
+	}
+	for i, w := range want {
+		if !strings.Contains(findings[i], w) {
+			t.Errorf("finding %d: expected to contain %q, got %q", i, w, findings[i])
+		}
+	}
+}
+
+func TestRenderComplianceMarkdown(t *testing.T) {
+	t.Parallel()
+
+	report := complianceReport{
+		Project:     "myapp",
+		AccountID:   "123456789012",
+		GeneratedAt: time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC),
+		PermissionsBoundary: compliancePolicy{
+			Name:     "myapp-permissions-boundary",
+			Document: json.RawMessage(`{"Version":"2012-10-17"}`),
+		},
+		ExecutionPolicy: compliancePolicy{
+			Name:     "myapp-execution-policy",
+			Document: json.RawMessage(`{}`),
+		},
+		Deployers: []complianceDeployer{
+			{Username: "Adam", Kind: "deployer", AccessKeys: []complianceAccessKey{
+				{AccessKeyID: "AKIA1", Status: "Active", AgeDays: 120, Stale: true},
+			}},
+			{Username: "Bob", Kind: "dev-deployer"},
+		},
+		Deployments: []complianceDeploy{{Name: "Prod", Restricted: true}, {Name: "DevBob"}},
+	}
+
+	var buf bytes.Buffer
+	if err := renderComplianceMarkdown(&buf, report); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	out := buf.String()
+	for _, want := range []string{
+		"# Compliance report: myapp",
+		`{"Version":"2012-10-17"}`,
+		"| Adam | deployer | AKIA1 | Active | 0001-01-01 | 120 (stale) |",
+		"| Bob | dev-deployer | - | - | - | - |",
+		"| Prod | yes |",
+		"| DevBob | no |",
+		"No findings.",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected output to contain %q\n%s", want, out)
+		}
+	}
+}
@@ -0,0 +1,326 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/advdv/ago/agcdkutil"
+	"github.com/advdv/ago/cmd/ago/internal/awsapi"
+	"github.com/advdv/ago/cmd/ago/internal/config"
+	"github.com/advdv/ago/cmd/ago/internal/ops"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation"
+	"github.com/aws/aws-sdk-go-v2/service/ecr"
+	"github.com/aws/aws-sdk-go-v2/service/route53"
+	"github.com/cockroachdb/errors"
+	"github.com/urfave/cli/v3"
+)
+
+func reportInventoryCmd() *cli.Command {
+	return &cli.Command{
+		Name:  "inventory",
+		Usage: "List all stacks, resources, images and DNS records owned by the project",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:  "format",
+				Usage: "Format of the inventory (json or csv)",
+				Value: "json",
+			},
+		},
+		Action: config.RunWithConfig(runReportInventory),
+	}
+}
+
+type reportInventoryOptions struct {
+	Format  string
+	Profile string
+	Output  io.Writer
+}
+
+func runReportInventory(ctx context.Context, cmd *cli.Command, cfg config.Config) error {
+	return doReportInventory(ctx, cfg, reportInventoryOptions{
+		Format:  cmd.String("format"),
+		Profile: cmd.String("profile"),
+		Output:  os.Stdout,
+	})
+}
+
+// inventoryItem is a single AWS resource owned by the project.
+type inventoryItem struct {
+	Kind       string `json:"kind"`
+	Region     string `json:"region"`
+	Deployment string `json:"deployment,omitempty"`
+	Stack      string `json:"stack,omitempty"`
+	Type       string `json:"type"`
+	ID         string `json:"id"`
+	Detail     string `json:"detail,omitempty"`
+}
+
+// inventoryStack identifies a CloudFormation stack the project is expected to own.
+type inventoryStack struct {
+	Name       string
+	Region     string
+	Deployment string
+}
+
+func doReportInventory(ctx context.Context, cfg config.Config, opts reportInventoryOptions) error {
+	if opts.Format != "json" && opts.Format != "csv" {
+		return errors.Errorf("unsupported format %q (use json or csv)", opts.Format)
+	}
+
+	cdk, err := loadCDKContext(cfg)
+	if err != nil {
+		return err
+	}
+
+	profile, err := resolveAWSProfile(opts.Profile, func() (string, error) { return getCDKProfile(cdk.Context) })
+	if err != nil {
+		return err
+	}
+
+	if err := cdk.Require("primary-region"); err != nil {
+		return err
+	}
+	regions := cdk.Regions()
+	deployments := cdk.Deployments
+
+	var items []inventoryItem
+	for _, stack := range inventoryStacks(cdk.Qualifier, regions, deployments) {
+		clients, err := awsapi.New(ctx, profile, stack.Region)
+		if err != nil {
+			return err
+		}
+		exists, err := ops.StackExists(ctx, clients, stack.Name)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			continue
+		}
+
+		stackItems, err := listInventoryStackResources(ctx, clients, stack)
+		if err != nil {
+			return err
+		}
+		items = append(items, stackItems...)
+	}
+
+	for _, item := range items {
+		if item.Type != "AWS::ECR::Repository" && item.Type != "AWS::Route53::HostedZone" {
+			continue
+		}
+		clients, err := awsapi.New(ctx, profile, item.Region)
+		if err != nil {
+			return err
+		}
+		switch item.Type {
+		case "AWS::ECR::Repository":
+			images, err := listInventoryImages(ctx, clients, item.ID)
+			if err != nil {
+				return err
+			}
+			items = append(items, images...)
+		case "AWS::Route53::HostedZone":
+			records, err := listInventoryRecords(ctx, clients, item.ID)
+			if err != nil {
+				return err
+			}
+			items = append(items, records...)
+		}
+	}
+
+	if opts.Format == "csv" {
+		return writeInventoryCSV(opts.Output, items)
+	}
+
+	output, err := json.MarshalIndent(items, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal inventory")
+	}
+	writeOutputf(opts.Output, "%s\n", output)
+
+	return nil
+}
+
+// inventoryStacks returns the shared and deployment stacks for every region, using the
+// same naming as agcdkutil so that the inventory matches what the CDK app synthesizes.
+func inventoryStacks(qualifier string, regions, deployments []string) []inventoryStack {
+	var stacks []inventoryStack
+	for _, region := range regions {
+		regionIdent := agcdkutil.RegionIdentFor(region)
+		stacks = append(stacks, inventoryStack{
+			Name:   agcdkutil.SharedStackName(qualifier, regionIdent),
+			Region: region,
+		})
+		for _, deployment := range deployments {
+			stacks = append(stacks, inventoryStack{
+				Name:       agcdkutil.DeploymentStackName(qualifier, regionIdent, deployment),
+				Region:     region,
+				Deployment: deployment,
+			})
+		}
+	}
+	return stacks
+}
+
+func listInventoryStackResources(
+	ctx context.Context, clients *awsapi.Clients, stack inventoryStack,
+) ([]inventoryItem, error) {
+	resources, err := listStackResources(ctx, clients, stack.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	items := []inventoryItem{{
+		Kind:       "stack",
+		Region:     stack.Region,
+		Deployment: stack.Deployment,
+		Stack:      stack.Name,
+		Type:       "AWS::CloudFormation::Stack",
+		ID:         stack.Name,
+	}}
+	for _, r := range resources {
+		items = append(items, inventoryItem{
+			Kind:       "resource",
+			Region:     stack.Region,
+			Deployment: stack.Deployment,
+			Stack:      stack.Name,
+			Type:       r.ResourceType,
+			ID:         r.PhysicalResourceID,
+			Detail:     r.LogicalResourceID + " (" + r.ResourceStatus + ")",
+		})
+	}
+
+	return items, nil
+}
+
+// stackResource is a resource of a deployed CloudFormation stack.
+type stackResource struct {
+	LogicalResourceID  string
+	PhysicalResourceID string
+	ResourceType       string
+	ResourceStatus     string
+}
+
+func listStackResources(ctx context.Context, clients *awsapi.Clients, stackName string) ([]stackResource, error) {
+	var resources []stackResource
+	pages := cloudformation.NewListStackResourcesPaginator(clients.CloudFormation,
+		&cloudformation.ListStackResourcesInput{StackName: aws.String(stackName)})
+	for pages.HasMorePages() {
+		page, err := pages.NextPage(ctx)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to list resources of stack %q", stackName)
+		}
+		for _, r := range page.StackResourceSummaries {
+			resources = append(resources, stackResource{
+				LogicalResourceID:  aws.ToString(r.LogicalResourceId),
+				PhysicalResourceID: aws.ToString(r.PhysicalResourceId),
+				ResourceType:       aws.ToString(r.ResourceType),
+				ResourceStatus:     string(r.ResourceStatus),
+			})
+		}
+	}
+
+	// Roll the resources of sub-stacks up under their parent, so callers see the deployment as one stack.
+	rolled := make([]stackResource, 0, len(resources))
+	for _, r := range resources {
+		rolled = append(rolled, r)
+		if r.ResourceType != "AWS::CloudFormation::Stack" || r.PhysicalResourceID == "" {
+			continue
+		}
+		nested, err := listStackResources(ctx, clients, r.PhysicalResourceID)
+		if err != nil {
+			return nil, err
+		}
+		rolled = append(rolled, subStackResources(r.LogicalResourceID, nested)...)
+	}
+
+	return rolled, nil
+}
+
+// subStackName returns the name that a sub-stack was given in agcdkutil.NewSubStack from the
+// logical ID of its AWS::CloudFormation::Stack resource, such as "Data" for
+// "DataNestedStackDataNestedStackResource8D2E1B4A".
+func subStackName(logicalID string) string {
+	name, _, _ := strings.Cut(logicalID, "NestedStack")
+	return name
+}
+
+// subStackResources prefixes the logical IDs of a sub-stack's resources with the name of the
+// sub-stack, which keeps them apart from the resources of the parent.
+func subStackResources(logicalID string, resources []stackResource) []stackResource {
+	prefixed := make([]stackResource, 0, len(resources))
+	for _, r := range resources {
+		r.LogicalResourceID = subStackName(logicalID) + "/" + r.LogicalResourceID
+		prefixed = append(prefixed, r)
+	}
+	return prefixed
+}
+
+func listInventoryImages(ctx context.Context, clients *awsapi.Clients, repoName string) ([]inventoryItem, error) {
+	var items []inventoryItem
+	pages := ecr.NewDescribeImagesPaginator(clients.ECR, &ecr.DescribeImagesInput{
+		RepositoryName: aws.String(repoName),
+	})
+	for pages.HasMorePages() {
+		page, err := pages.NextPage(ctx)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to list images in repository %q", repoName)
+		}
+		for _, img := range page.ImageDetails {
+			items = append(items, inventoryItem{
+				Kind:   "image",
+				Region: clients.Region,
+				Type:   "AWS::ECR::Image",
+				ID:     repoName + "@" + aws.ToString(img.ImageDigest),
+				Detail: strings.Join(img.ImageTags, " "),
+			})
+		}
+	}
+
+	return items, nil
+}
+
+func listInventoryRecords(ctx context.Context, clients *awsapi.Clients, hostedZoneID string) ([]inventoryItem, error) {
+	var items []inventoryItem
+	pages := route53.NewListResourceRecordSetsPaginator(clients.Route53, &route53.ListResourceRecordSetsInput{
+		HostedZoneId: aws.String(hostedZoneID),
+	})
+	for pages.HasMorePages() {
+		page, err := pages.NextPage(ctx)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to list records in hosted zone %q", hostedZoneID)
+		}
+		for _, r := range page.ResourceRecordSets {
+			items = append(items, inventoryItem{
+				Kind:   "dns-record",
+				Region: "global",
+				Type:   "AWS::Route53::RecordSet",
+				ID:     aws.ToString(r.Name),
+				Detail: string(r.Type) + " in " + hostedZoneID,
+			})
+		}
+	}
+
+	return items, nil
+}
+
+func writeInventoryCSV(w io.Writer, items []inventoryItem) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write([]string{"kind", "region", "deployment", "stack", "type", "id", "detail"}); err != nil {
+		return errors.Wrap(err, "failed to write csv header")
+	}
+	for _, item := range items {
+		if err := cw.Write([]string{
+			item.Kind, item.Region, item.Deployment, item.Stack, item.Type, item.ID, item.Detail,
+		}); err != nil {
+			return errors.Wrap(err, "failed to write csv row")
+		}
+	}
+	cw.Flush()
+	return errors.Wrap(cw.Error(), "failed to flush csv")
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestInventoryStacks(t *testing.T) {
+	t.Parallel()
+
+	stacks := inventoryStacks("myapp", []string{"eu-central-1", "eu-north-1"}, []string{"Prod", "Dev1"})
+
+	want := []inventoryStack{
+		{Name: "myappEuc1Shared", Region: "eu-central-1"},
+		{Name: "myappEuc1Prod", Region: "eu-central-1", Deployment: "Prod"},
+		{Name: "myappEuc1Dev1", Region: "eu-central-1", Deployment: "Dev1"},
+		{Name: "myappEun1Shared", Region: "eu-north-1"},
+		{Name: "myappEun1Prod", Region: "eu-north-1", Deployment: "Prod"},
+		{Name: "myappEun1Dev1", Region: "eu-north-1", Deployment: "Dev1"},
+	}
+
+	if len(stacks) != len(want) {
+		t.Fatalf("expected %d stacks, got %d: %v", len(want), len(stacks), stacks)
+	}
+	for i := range want {
+		if stacks[i] != want[i] {
+			t.Errorf("stack %d: expected %+v, got %+v", i, want[i], stacks[i])
+		}
+	}
+}
+
+func TestWriteInventoryCSV(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	err := writeInventoryCSV(&buf, []inventoryItem{{
+		Kind:   "image",
+		Region: "eu-central-1",
+		Type:   "AWS::ECR::Image",
+		ID:     "repo@sha256:abc",
+		Detail: "coreapi-dev-1, coreapi-dev-2",
+	}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := "kind,region,deployment,stack,type,id,detail\n" +
+		"image,eu-central-1,,,AWS::ECR::Image,repo@sha256:abc,\"coreapi-dev-1, coreapi-dev-2\"\n"
+	if buf.String() != want {
+		t.Errorf("expected:\n%s\ngot:\n%s", want, buf.String())
+	}
+}
+
+func TestSubStackResources(t *testing.T) {
+	t.Parallel()
+
+	resources := subStackResources("DataNestedStackDataNestedStackResource8D2E1B4A", []stackResource{
+		{LogicalResourceID: "OrdersTable1A2B3C4D", ResourceType: "AWS::DynamoDB::Table"},
+		{LogicalResourceID: "Archive/Bucket83908E77", ResourceType: "AWS::S3::Bucket"},
+	})
+
+	want := []string{"Data/OrdersTable1A2B3C4D", "Data/Archive/Bucket83908E77"}
+	if len(resources) != len(want) {
+		t.Fatalf("expected %d resources, got %+v", len(want), resources)
+	}
+	for i, id := range want {
+		if resources[i].LogicalResourceID != id {
+			t.Errorf("expected %q, got %q", id, resources[i].LogicalResourceID)
+		}
+	}
+}
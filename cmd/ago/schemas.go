@@ -0,0 +1,113 @@
+package main
+
+import (
+	"io"
+	"os"
+	"reflect"
+	"slices"
+
+	"github.com/advdv/ago/agcdkutil"
+	"github.com/advdv/ago/cmd/ago/internal/cdkcontext"
+	"github.com/advdv/ago/cmd/ago/internal/config"
+	"github.com/advdv/ago/cmd/ago/internal/schema"
+	"github.com/cockroachdb/errors"
+	"github.com/goccy/go-yaml"
+)
+
+// IDs of the JSON Schemas of the files that ago reads, besides .ago.yml (see config.SchemaID). The
+// schemas are checked in under cmd/ago/schemas so that editors can load them by these URLs, and
+// TestSchemaArtifacts keeps them in sync.
+const (
+	deploymentsSchemaID = "https://raw.githubusercontent.com/advdv/ago/main/cmd/ago/schemas/deployments.v1.json"
+	cdkContextSchemaID  = "https://raw.githubusercontent.com/advdv/ago/main/cmd/ago/schemas/cdk-context.v1.json"
+)
+
+// metaSchemas are the schemas that 'ago meta schema' prints, by name. Each is checked in as
+// cmd/ago/schemas/{name}.v1.json.
+var metaSchemas = map[string]func() *schema.Schema{
+	"ago":         config.Schema,
+	"deployments": func() *schema.Schema { return deploymentsSchema(nil) },
+	"cdk-context": func() *schema.Schema { return cdkContextSchema("") },
+}
+
+// deploymentsSchema returns the JSON Schema of infra/deployments.yaml, generated from
+// agcdkutil.DeploymentsFile. With known deployments, settings of other deployments are rejected.
+func deploymentsSchema(known []string) *schema.Schema {
+	s := schema.For(reflect.TypeFor[agcdkutil.DeploymentsFile](), "yaml")
+	s.Schema = schema.Dialect
+	s.ID = deploymentsSchemaID
+	s.Title = "deployments.yaml"
+	s.Description = "Settings of each deployment, read by the CDK app and by 'ago infra cdk deploy'"
+	if len(known) > 0 {
+		s.Properties["deployments"].PropertyNames = schema.Enum(known...)
+	}
+	return s
+}
+
+// cdkContextSchema returns the JSON Schema of the ago-owned keys in the CDK context, that is the
+// "context" of cdk.json and cdk.context.json, see cdkcontext.Schema.
+func cdkContextSchema(prefix string) *schema.Schema {
+	s := cdkcontext.Schema(prefix)
+	s.Schema = schema.Dialect
+	s.ID = cdkContextSchemaID
+	s.Title = cdkcontext.FileName
+	s.Description = "CDK context keys that ago reads and writes, see 'ago context explain'"
+	return s
+}
+
+// readCDKContext loads the CDK context of the project and checks its ago-owned keys against the
+// schema, so every problem is reported before any command acts on the context.
+func readCDKContext(cfg config.Config) (*cdkcontext.Context, error) {
+	cdkCtx, err := cdkcontext.Load(cfg.CDKDir())
+	if err != nil {
+		return nil, err
+	}
+	if err := cdkCtx.Validate(cdkContextSchema(cdkCtx.Prefix)); err != nil {
+		return nil, err
+	}
+	return cdkCtx, nil
+}
+
+// loadDeploymentsFile validates infra/deployments.yaml against its schema, which reports every
+// problem at once, before agcdkutil.LoadDeploymentsFile decodes it.
+func loadDeploymentsFile(cfg config.Config, known []string) (agcdkutil.DeploymentsFile, error) {
+	path := deploymentsFilePath(cfg)
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return agcdkutil.DeploymentsFile{}, nil
+	}
+	if err != nil {
+		return agcdkutil.DeploymentsFile{}, errors.Wrapf(err, "failed to read %s", path)
+	}
+
+	var doc any
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return agcdkutil.DeploymentsFile{}, errors.Wrapf(err, "failed to parse %s", path)
+	}
+	if doc != nil {
+		if err := deploymentsSchema(known).Validate(doc); err != nil {
+			return agcdkutil.DeploymentsFile{}, errors.Wrapf(err, "invalid %s", path)
+		}
+	}
+
+	return agcdkutil.LoadDeploymentsFile(path, known)
+}
+
+// doMetaSchema prints the named JSON Schema. Schemas are JSON in either output format.
+func doMetaSchema(progress, result io.Writer, name string) error {
+	build, ok := metaSchemas[name]
+	if !ok {
+		names := make([]string, 0, len(metaSchemas))
+		for n := range metaSchemas {
+			names = append(names, n)
+		}
+		slices.Sort(names)
+		return errors.Errorf("unknown schema %q, must be one of: %v", name, names)
+	}
+
+	w := result
+	if w == nil {
+		w = progress
+	}
+	return writeResult(w, build())
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/advdv/ago/cmd/ago/internal/cdkcontext"
+	"github.com/advdv/ago/cmd/ago/internal/config"
+)
+
+func TestSchemaArtifacts(t *testing.T) {
+	t.Parallel()
+
+	for name := range metaSchemas {
+		var result bytes.Buffer
+		if err := doMetaSchema(nil, &result, name); err != nil {
+			t.Fatal(err)
+		}
+
+		data, err := os.ReadFile(filepath.Join("schemas", name+".v1.json"))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var got, want any
+		if err := json.Unmarshal(result.Bytes(), &got); err != nil {
+			t.Fatal(err)
+		}
+		if err := json.Unmarshal(data, &want); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("schemas/%s.v1.json is out of date, regenerate it with: "+
+				"ago meta schema %s > cmd/ago/schemas/%s.v1.json", name, name, name)
+		}
+	}
+}
+
+// validateContext checks the values of a CDK context against the schema of its ago-owned keys.
+func validateContext(t *testing.T, values map[string]any) error {
+	t.Helper()
+	cdkCtx, err := cdkcontext.New(values)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cdkCtx.Validate(cdkContextSchema(cdkCtx.Prefix))
+}
+
+func TestValidateCDKContext(t *testing.T) {
+	t.Parallel()
+
+	valid := map[string]any{
+		"app":                  "go run .",
+		"profile":              "myapp-deployer",
+		"myapp-qualifier":      "myapp",
+		"myapp-primary-region": "eu-central-1",
+		"myapp-deployments":    []any{"Dev", "Prod"},
+		"myapp-image-tags":     map[string]any{"Dev": map[string]any{"backend": "abc123"}},
+	}
+	if err := validateContext(t, valid); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	invalid := map[string]any{
+		"myapp-qualifier":      "myappqualifierx",
+		"myapp-primary-region": "mars-1",
+		"myapp-dns-delegated":  "yes",
+	}
+	err := validateContext(t, invalid)
+	if err == nil {
+		t.Fatal("expected error, got nil")
+	}
+	for _, want := range []string{
+		"3 problems",
+		"myapp-dns-delegated: must be a boolean",
+		`myapp-primary-region: must be one of 35 known values, got "mars-1"`,
+		"myapp-qualifier: must be at most 10 characters",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("expected %q in error:\n%v", want, err)
+		}
+	}
+}
+
+func TestLoadDeploymentsFileSchema(t *testing.T) {
+	t.Parallel()
+
+	cfg := config.Config{ProjectDir: t.TempDir()}
+	if err := os.MkdirAll(filepath.Join(cfg.ProjectDir, "infra"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	content := "deployments:\n  Prod:\n    sizing: {memory_mib: 64}\n    rolout: {strategy: canary}\n  Prd: {}\n"
+	if err := os.WriteFile(deploymentsFilePath(cfg), []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := loadDeploymentsFile(cfg, []string{"Dev", "Prod"})
+	if err == nil {
+		t.Fatal("expected error, got nil")
+	}
+	for _, want := range []string{
+		`deployments.Prd: must be one of "Dev", "Prod"`,
+		"deployments.Prod.sizing.memory_mib: must be at least 128",
+		`deployments.Prod: unknown property "rolout"`,
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("expected %q in error:\n%v", want, err)
+		}
+	}
+}
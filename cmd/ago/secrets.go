@@ -0,0 +1,584 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/advdv/ago/cmd/ago/internal/awsapi"
+	"github.com/advdv/ago/cmd/ago/internal/config"
+	"github.com/advdv/ago/cmd/ago/internal/ops"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
+	lambdatypes "github.com/aws/aws-sdk-go-v2/service/lambda/types"
+	"github.com/cockroachdb/errors"
+	"github.com/urfave/cli/v3"
+)
+
+func secretsCmd() *cli.Command {
+	return &cli.Command{
+		Name:  "secrets",
+		Usage: "Read, write and rotate the Secrets Manager secrets of a deployment and the main secret",
+		Commands: []*cli.Command{
+			secretsGetCmd(),
+			secretsSetCmd(),
+			secretsListCmd(),
+			secretsRotateCmd(),
+		},
+	}
+}
+
+// secretsFlags returns the flags that select where secrets live, shared by the secrets commands.
+// The region is the global --region, which defaults to the primary region.
+func secretsFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringFlag{
+			Name:  "deployment",
+			Usage: "Deployment that owns the secrets (default: the caller's Dev deployment)",
+		},
+		&cli.BoolFlag{
+			Name:  "shared",
+			Usage: "Use the secrets shared by all deployments instead of those of a deployment",
+		},
+	}
+}
+
+func secretsGetCmd() *cli.Command {
+	return &cli.Command{
+		Name:      "get",
+		Usage:     "Print the value of a secret, or of a key in its JSON value",
+		ArgsUsage: "<name>",
+		Flags: append(secretsFlags(), &cli.StringFlag{
+			Name:  "key",
+			Usage: "Dot-separated path of a key in the JSON value, e.g. db.password",
+		}),
+		Action: config.RunWithConfig(runSecretsGet),
+	}
+}
+
+func secretsSetCmd() *cli.Command {
+	return &cli.Command{
+		Name:      "set",
+		Usage:     "Store the value of a secret, or of a key in its JSON value; a value of - is read from stdin",
+		ArgsUsage: "<name> <value>",
+		Flags: append(secretsFlags(), &cli.StringFlag{
+			Name:  "key",
+			Usage: "Dot-separated path of a key in the JSON value to set, keeping the other keys",
+		}),
+		Action: config.RunWithConfig(runSecretsSet),
+	}
+}
+
+func secretsListCmd() *cli.Command {
+	return &cli.Command{
+		Name:   "list",
+		Usage:  "List the secrets of a deployment, without their values",
+		Flags:  secretsFlags(),
+		Action: config.RunWithConfig(runSecretsList),
+	}
+}
+
+func secretsRotateCmd() *cli.Command {
+	return &cli.Command{
+		Name: "rotate",
+		Usage: "Stage a new random value in the main secret, or in the named secret, validate it with the " +
+			"pre secrets-rotate hooks, promote it and restart the Lambda functions that reference the secret",
+		ArgsUsage: "[name]",
+		Flags: append(secretsFlags(),
+			&cli.StringFlag{
+				Name:  "key",
+				Usage: "Dot-separated path of a key in the JSON value to rotate, keeping the other keys",
+			},
+			&cli.BoolFlag{
+				Name:  "no-refresh",
+				Usage: "Leave the Lambda functions that reference the secret running with the value they read",
+			},
+		),
+		Action: config.RunWithConfig(runSecretsRotate),
+	}
+}
+
+type secretsOptions struct {
+	Deployment    string
+	Shared        bool
+	Profile       string
+	Region        string
+	ScopedSession bool
+	Name          string
+	Key           string
+	Value         string
+	NoRefresh     bool
+	Output        io.Writer
+	Result        io.Writer
+}
+
+func secretsOptionsFromCmd(cmd *cli.Command) secretsOptions {
+	progress, result := commandOutput(cmd)
+	return secretsOptions{
+		Deployment:    cmd.String("deployment"),
+		Shared:        cmd.Bool("shared"),
+		Profile:       cmd.String("profile"),
+		Region:        cmd.String("region"),
+		ScopedSession: cmd.Bool("scoped-session"),
+		Name:          cmd.Args().First(),
+		Key:           cmd.String("key"),
+		Output:        progress,
+		Result:        result,
+	}
+}
+
+func runSecretsGet(ctx context.Context, cmd *cli.Command, cfg config.Config) error {
+	opts := secretsOptionsFromCmd(cmd)
+	opts.Output = os.Stdout
+	if opts.Name == "" {
+		return errors.New("name argument is required")
+	}
+	return doSecretsGet(ctx, cfg, opts)
+}
+
+func runSecretsSet(ctx context.Context, cmd *cli.Command, cfg config.Config) error {
+	opts := secretsOptionsFromCmd(cmd)
+	if opts.Name == "" || cmd.Args().Len() < 2 {
+		return errors.New("name and value arguments are required")
+	}
+
+	opts.Value = cmd.Args().Get(1)
+	if opts.Value == "-" {
+		data, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return errors.Wrap(err, "failed to read the value from stdin")
+		}
+		opts.Value = strings.TrimSuffix(string(data), "\n")
+	}
+	return doSecretsSet(ctx, cfg, opts)
+}
+
+func runSecretsList(ctx context.Context, cmd *cli.Command, cfg config.Config) error {
+	return doSecretsList(ctx, cfg, secretsOptionsFromCmd(cmd))
+}
+
+func runSecretsRotate(ctx context.Context, cmd *cli.Command, cfg config.Config) error {
+	opts := secretsOptionsFromCmd(cmd)
+	opts.NoRefresh = cmd.Bool("no-refresh")
+	return doSecretsRotate(ctx, cfg, opts)
+}
+
+// doSecretsGet prints the value of a secret. With a key, the value must be a JSON object and
+// only the value at the key is printed; strings are printed without quotes.
+func doSecretsGet(ctx context.Context, cfg config.Config, opts secretsOptions) error {
+	secretName, clients, err := resolveSecret(ctx, cfg, opts, "secrets-get", "secretsmanager:GetSecretValue")
+	if err != nil {
+		return err
+	}
+
+	value, found, err := ops.SecretString(ctx, clients, secretName)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return errors.Errorf("secret %s does not exist", secretName)
+	}
+
+	if opts.Key != "" {
+		if value, err = jsonPathGet(value, opts.Key); err != nil {
+			return errors.Wrapf(err, "secret %s", secretName)
+		}
+	}
+	writeOutputf(opts.Output, "%s\n", value)
+	return nil
+}
+
+// doSecretsSet stores the value of a secret, creating the secret when it does not exist. With a
+// key, the value is set at the key of the secret's JSON object and the other keys are kept.
+func doSecretsSet(ctx context.Context, cfg config.Config, opts secretsOptions) error {
+	secretName, clients, err := resolveSecret(ctx, cfg, opts, "secrets-set",
+		"secretsmanager:GetSecretValue", "secretsmanager:PutSecretValue", "secretsmanager:CreateSecret")
+	if err != nil {
+		return err
+	}
+
+	value := opts.Value
+	if opts.Key != "" {
+		current, _, err := ops.SecretString(ctx, clients, secretName)
+		if err != nil {
+			return err
+		}
+		if value, err = jsonPathSet(current, opts.Key, opts.Value); err != nil {
+			return errors.Wrapf(err, "secret %s", secretName)
+		}
+	}
+
+	if err := ops.PutSecretString(ctx, clients, secretName, value); err != nil {
+		return err
+	}
+	writeOutputf(opts.Output, "Stored secret %s.\n", secretName)
+	return nil
+}
+
+// doSecretsList prints the names of the secrets of a deployment, or of the project with --shared.
+func doSecretsList(ctx context.Context, cfg config.Config, opts secretsOptions) error {
+	opts.Name = ""
+	prefix, clients, err := resolveSecret(ctx, cfg, opts, "secrets-list", "secretsmanager:ListSecrets")
+	if err != nil {
+		return err
+	}
+
+	secrets, err := ops.ListSecrets(ctx, clients, prefix)
+	if err != nil {
+		return err
+	}
+	if opts.Result != nil {
+		return writeResult(opts.Result, secrets)
+	}
+
+	if len(secrets) == 0 {
+		writeOutputf(opts.Output, "No secrets found with prefix %s.\n", prefix)
+		return nil
+	}
+	for _, s := range secrets {
+		writeOutputf(opts.Output, "%s", s.Name)
+		if s.LastChanged != "" {
+			writeOutputf(opts.Output, " (changed %s)", s.LastChanged)
+		}
+		writeOutputf(opts.Output, "\n")
+	}
+	return nil
+}
+
+// secretRefreshVariable is the environment variable that rotate sets on the Lambda functions that
+// reference a rotated secret. Changing the configuration of a function replaces its execution
+// environments, so the secret is read afresh; the next deploy of the function removes it again.
+const secretRefreshVariable = "AGO_SECRET_ROTATION"
+
+// secretsRotateResult is the result of secrets rotate in the JSON output format.
+type secretsRotateResult struct {
+	Secret             string   `json:"secret"`
+	VersionID          string   `json:"versionId"`
+	ReplicasAdded      []string `json:"replicasAdded"`
+	RefreshedFunctions []string `json:"refreshedFunctions"`
+}
+
+// rotateTarget is the secret that rotate rotates, with the regions it is read in. The first region
+// holds the primary secret, the others its replicas.
+type rotateTarget struct {
+	Secret     string
+	Qualifier  string
+	Deployment string
+	Profile    string
+	Regions    []string
+}
+
+// doSecretsRotate rotates a secret in stages, so that clients that read AWSCURRENT never see a
+// value that failed validation. The new value is staged as AWSPENDING, the pre hooks of
+// secrets-rotate validate it, and only then is it promoted to AWSCURRENT and replicated. The
+// Lambda functions that reference the secret are restarted last, and the post hooks run once they
+// are.
+func doSecretsRotate(ctx context.Context, cfg config.Config, opts secretsOptions) error {
+	target, err := resolveRotateTarget(ctx, cfg, opts)
+	if err != nil {
+		return err
+	}
+
+	clients, err := awsapi.New(ctx, target.Profile, target.Regions[0])
+	if err != nil {
+		return err
+	}
+
+	current, found, err := ops.SecretString(ctx, clients, target.Secret)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return errors.Errorf("secret %s does not exist, store a value with 'ago secrets set' first", target.Secret)
+	}
+
+	value, err := ops.GenerateSecretValue(ctx, clients)
+	if err != nil {
+		return err
+	}
+	if opts.Key != "" {
+		if value, err = jsonPathSet(current, opts.Key, value); err != nil {
+			return errors.Wrapf(err, "secret %s", target.Secret)
+		}
+	}
+
+	writeOutputf(opts.Output, "Rotating secret %s in %s...\n", target.Secret, target.Regions[0])
+	versionID, err := ops.StageSecretValue(ctx, clients, target.Secret, value)
+	if err != nil {
+		return err
+	}
+	writeOutputf(opts.Output, "  Staged version %s as %s.\n", versionID, ops.SecretStagePending)
+
+	env := hookEnv{
+		Command:       "secrets-rotate",
+		Deployment:    target.Deployment,
+		Profile:       target.Profile,
+		Qualifier:     target.Qualifier,
+		Secret:        target.Secret,
+		SecretVersion: versionID,
+	}
+	if err := runHooks(ctx, cfg, opts.Output, hookPhasePre, env); err != nil {
+		if discardErr := ops.DiscardSecretVersion(ctx, clients, target.Secret, versionID); discardErr != nil {
+			return errors.CombineErrors(err, discardErr)
+		}
+		return errors.Wrapf(err, "discarded version %s, secret %s is unchanged", versionID, target.Secret)
+	}
+
+	if err := ops.PromoteSecretVersion(ctx, clients, target.Secret, versionID); err != nil {
+		return err
+	}
+	writeOutputf(opts.Output, "  Promoted version %s to %s.\n", versionID, ops.SecretStageCurrent)
+
+	res := secretsRotateResult{Secret: target.Secret, VersionID: versionID, RefreshedFunctions: []string{}}
+	if len(target.Regions) > 1 {
+		if res.ReplicasAdded, err = ops.ReplicateSecret(ctx, clients, target.Secret, target.Regions[1:]); err != nil {
+			return err
+		}
+		for _, region := range res.ReplicasAdded {
+			writeOutputf(opts.Output, "  Added a replica in %s.\n", region)
+		}
+	}
+
+	if !opts.NoRefresh {
+		for _, region := range target.Regions {
+			refreshed, err := refreshSecretDependents(ctx, target, region, versionID)
+			if err != nil {
+				return err
+			}
+			for _, name := range refreshed {
+				writeOutputf(opts.Output, "  Restarted Lambda function %s in %s.\n", name, region)
+			}
+			res.RefreshedFunctions = append(res.RefreshedFunctions, refreshed...)
+		}
+	}
+
+	if err := runHooks(ctx, cfg, opts.Output, hookPhasePost, env); err != nil {
+		return err
+	}
+
+	writeOutputf(opts.Output, "Rotated secret %s.\n", target.Secret)
+	return writeResult(opts.Result, res)
+}
+
+// resolveRotateTarget returns the secret to rotate. Without a name that is the main secret of the
+// project, which lives in the primary region and is replicated to the secondary regions. A named
+// secret belongs to a deployment, or to the project with --shared, and lives in a single region.
+func resolveRotateTarget(ctx context.Context, cfg config.Config, opts secretsOptions) (rotateTarget, error) {
+	if opts.Name != "" {
+		stacks, err := resolveDeploymentStacks(ctx, cfg, opts.Deployment, opts.Profile, opts.Region)
+		if err != nil {
+			return rotateTarget{}, err
+		}
+
+		target := rotateTarget{
+			Secret:     ops.DeploymentSecretName(stacks.Qualifier, stacks.Deployment, opts.Name),
+			Qualifier:  stacks.Qualifier,
+			Deployment: stacks.Deployment,
+			Profile:    stacks.Profile,
+			Regions:    []string{stacks.Region},
+		}
+		if opts.Shared {
+			target.Secret = ops.SharedSecretName(stacks.Qualifier, opts.Name)
+			target.Deployment = ""
+		}
+		return target, nil
+	}
+
+	// The global --region, which .ago.yml may set, does not matter for the main secret.
+	if opts.Deployment != "" || opts.Shared {
+		return rotateTarget{}, errors.New("the main secret belongs to the project and is replicated to every " +
+			"region, name a secret to rotate one of a deployment")
+	}
+
+	cdk, err := loadCDKContext(cfg)
+	if err != nil {
+		return rotateTarget{}, err
+	}
+
+	username, _ := resolveCDKCallerUsername(ctx, opts.Profile, cdk.Context)
+	profile := resolveCDKProfile(opts.Profile, cdk.Context, username)
+
+	regions, err := sharedDeployRegions(cdk.Context, "")
+	if err != nil {
+		return rotateTarget{}, err
+	}
+
+	return rotateTarget{
+		Secret:    ops.MainSecretName(cdk.Qualifier),
+		Qualifier: cdk.Qualifier,
+		Profile:   profile,
+		Regions:   regions,
+	}, nil
+}
+
+// lambdaFunction is a Lambda function with the environment variables it is configured with.
+type lambdaFunction struct {
+	Name      string
+	Variables map[string]string
+}
+
+// refreshSecretDependents restarts the Lambda functions in the region that reference the secret in
+// an environment variable, and returns their names.
+func refreshSecretDependents(ctx context.Context, target rotateTarget, region, versionID string) ([]string, error) {
+	clients, err := awsapi.New(ctx, target.Profile, region)
+	if err != nil {
+		return nil, err
+	}
+
+	var functions []lambdaFunction
+	pages := lambda.NewListFunctionsPaginator(clients.Lambda, &lambda.ListFunctionsInput{})
+	for pages.HasMorePages() {
+		page, err := pages.NextPage(ctx)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to list the Lambda functions in %s", region)
+		}
+		for _, fn := range page.Functions {
+			var variables map[string]string
+			if fn.Environment != nil {
+				variables = fn.Environment.Variables
+			}
+			functions = append(functions, lambdaFunction{Name: aws.ToString(fn.FunctionName), Variables: variables})
+		}
+	}
+
+	var refreshed []string
+	for _, fn := range secretDependents(functions, target.Secret) {
+		variables := maps.Clone(fn.Variables)
+		variables[secretRefreshVariable] = versionID
+
+		if _, err := clients.Lambda.UpdateFunctionConfiguration(ctx, &lambda.UpdateFunctionConfigurationInput{
+			FunctionName: aws.String(fn.Name),
+			Environment:  &lambdatypes.Environment{Variables: variables},
+		}); err != nil {
+			return nil, errors.Wrapf(err, "failed to restart Lambda function %s", fn.Name)
+		}
+		refreshed = append(refreshed, fn.Name)
+	}
+	return refreshed, nil
+}
+
+// secretDependents returns the functions with an environment variable that references the secret,
+// sorted by name.
+func secretDependents(functions []lambdaFunction, secretName string) []lambdaFunction {
+	var dependents []lambdaFunction
+	for _, fn := range functions {
+		for _, value := range fn.Variables {
+			if referencesSecret(value, secretName) {
+				dependents = append(dependents, fn)
+				break
+			}
+		}
+	}
+	slices.SortFunc(dependents, func(a, b lambdaFunction) int { return strings.Compare(a.Name, b.Name) })
+	return dependents
+}
+
+// referencesSecret reports whether the value is the name or the ARN of the secret. The ARN of a
+// secret in any region ends in the name, a dash and six random characters.
+func referencesSecret(value, secretName string) bool {
+	if value == secretName || strings.HasSuffix(value, ":secret:"+secretName) {
+		return true
+	}
+	_, suffix, ok := strings.Cut(value, ":secret:"+secretName+"-")
+	return ok && len(suffix) == 6 && !strings.ContainsAny(suffix, "-/")
+}
+
+// resolveSecret returns the full name of the secret and clients for the region it lives in. The
+// name is scoped to the deployment, or to the project with --shared. An empty name resolves to the
+// prefix of the scope, which is what list uses. A scoped session may only take the actions on the
+// secret; listing secrets cannot be limited to some of them.
+func resolveSecret(
+	ctx context.Context, cfg config.Config, opts secretsOptions, command string, actions ...string,
+) (string, *awsapi.Clients, error) {
+	stacks, err := resolveDeploymentStacks(ctx, cfg, opts.Deployment, opts.Profile, opts.Region)
+	if err != nil {
+		return "", nil, err
+	}
+
+	secretName := ops.DeploymentSecretName(stacks.Qualifier, stacks.Deployment, opts.Name)
+	if opts.Shared {
+		secretName = ops.SharedSecretName(stacks.Qualifier, opts.Name)
+	}
+
+	clients, err := stacks.clients(ctx, opts.ScopedSession, command, func(account sessionAccount) []sessionStatement {
+		if opts.Name == "" {
+			return []sessionStatement{allow([]string{"*"}, actions...)}
+		}
+		// Secret ARNs end in a dash and six random characters.
+		return []sessionStatement{allow([]string{account.arn("secretsmanager", "secret:"+secretName+"-??????")},
+			actions...)}
+	})
+	if err != nil {
+		return "", nil, err
+	}
+	return secretName, clients, nil
+}
+
+// jsonPathGet returns the value at the dot-separated path of a JSON object. Strings are returned
+// as is, other values as JSON.
+func jsonPathGet(document, path string) (string, error) {
+	var value any
+	if err := json.Unmarshal([]byte(document), &value); err != nil {
+		return "", errors.Wrap(err, "value is not JSON")
+	}
+
+	for _, key := range strings.Split(path, ".") {
+		obj, ok := value.(map[string]any)
+		if !ok {
+			return "", errors.Errorf("key %q is not in a JSON object", key)
+		}
+		if value, ok = obj[key]; !ok {
+			return "", errors.Errorf("key %q not found", path)
+		}
+	}
+
+	if s, ok := value.(string); ok {
+		return s, nil
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to marshal value")
+	}
+	return string(data), nil
+}
+
+// jsonPathSet sets the string value at the dot-separated path of a JSON object and returns the
+// updated object. Objects along the path are created as needed; an empty document is an empty
+// object.
+func jsonPathSet(document, path, value string) (string, error) {
+	root := map[string]any{}
+	if document != "" {
+		if err := json.Unmarshal([]byte(document), &root); err != nil {
+			return "", errors.Wrap(err, "value is not a JSON object")
+		}
+	}
+	if root == nil {
+		root = map[string]any{}
+	}
+
+	keys := strings.Split(path, ".")
+	obj := root
+	for _, key := range keys[:len(keys)-1] {
+		switch next := obj[key].(type) {
+		case map[string]any:
+			obj = next
+		case nil:
+			child := map[string]any{}
+			obj[key] = child
+			obj = child
+		default:
+			return "", errors.Errorf("key %q is not a JSON object", key)
+		}
+	}
+	obj[keys[len(keys)-1]] = value
+
+	data, err := json.Marshal(root)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to marshal value")
+	}
+	return string(data), nil
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestJSONPathGet(t *testing.T) {
+	t.Parallel()
+
+	doc := `{"db":{"password":"s3cret","port":5432},"token":"abc"}`
+	for path, want := range map[string]string{
+		"token":       "abc",
+		"db.password": "s3cret",
+		"db.port":     "5432",
+		"db":          `{"password":"s3cret","port":5432}`,
+	} {
+		got, err := jsonPathGet(doc, path)
+		if err != nil {
+			t.Errorf("jsonPathGet(%q): %v", path, err)
+			continue
+		}
+		if got != want {
+			t.Errorf("jsonPathGet(%q) = %q, want %q", path, got, want)
+		}
+	}
+
+	for _, path := range []string{"missing", "token.nested"} {
+		if _, err := jsonPathGet(doc, path); err == nil {
+			t.Errorf("jsonPathGet(%q): expected an error", path)
+		}
+	}
+	if _, err := jsonPathGet("not json", "token"); err == nil {
+		t.Error("expected an error for a value that is not JSON")
+	}
+}
+
+func TestJSONPathSet(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		doc, path, value, want string
+	}{
+		{"", "token", "abc", `{"token":"abc"}`},
+		{`{"token":"abc"}`, "db.password", "s3cret", `{"db":{"password":"s3cret"},"token":"abc"}`},
+		{`{"db":{"password":"old","port":5432}}`, "db.password", "new", `{"db":{"password":"new","port":5432}}`},
+	} {
+		got, err := jsonPathSet(tc.doc, tc.path, tc.value)
+		if err != nil {
+			t.Errorf("jsonPathSet(%q, %q): %v", tc.doc, tc.path, err)
+			continue
+		}
+		if got != tc.want {
+			t.Errorf("jsonPathSet(%q, %q) = %s, want %s", tc.doc, tc.path, got, tc.want)
+		}
+	}
+
+	if _, err := jsonPathSet(`{"token":"abc"}`, "token.nested", "x"); err == nil {
+		t.Error("expected an error when setting a key below a string")
+	}
+}
+
+func TestReferencesSecret(t *testing.T) {
+	t.Parallel()
+
+	for value, want := range map[string]bool{
+		"myapp/main-secret": true,
+		"arn:aws:secretsmanager:eu-central-1:123456789012:secret:myapp/main-secret-AbC123":     true,
+		"arn:aws:secretsmanager:eu-west-1:123456789012:secret:myapp/main-secret-xYz789":        true,
+		"arn:aws:secretsmanager:eu-central-1:123456789012:secret:myapp/main-secret":            true,
+		"arn:aws:secretsmanager:eu-central-1:123456789012:secret:myapp/main-secret-old-AbC123": false,
+		"myapp/main-secret-old": false,
+		"myapp/Dev/api-key":     false,
+	} {
+		if got := referencesSecret(value, "myapp/main-secret"); got != want {
+			t.Errorf("referencesSecret(%q) = %v, want %v", value, got, want)
+		}
+	}
+}
+
+func TestSecretDependents(t *testing.T) {
+	t.Parallel()
+
+	functions := []lambdaFunction{
+		{Name: "myapp-worker", Variables: map[string]string{
+			"MAIN_SECRET_ARN": "arn:aws:secretsmanager:eu-central-1:123456789012:secret:myapp/main-secret-AbC123",
+		}},
+		{Name: "myapp-api", Variables: map[string]string{"SECRET_NAME": "myapp/main-secret", "PORT": "8080"}},
+		{Name: "myapp-cron", Variables: map[string]string{"SECRET_NAME": "myapp/Dev/api-key"}},
+		{Name: "other"},
+	}
+
+	var names []string
+	for _, fn := range secretDependents(functions, "myapp/main-secret") {
+		names = append(names, fn.Name)
+	}
+	if want := []string{"myapp-api", "myapp-worker"}; !slices.Equal(names, want) {
+		t.Errorf("expected %v, got %v", want, names)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"sort"
 	"strings"
 
+	"github.com/advdv/ago/cmd/ago/internal/cdkcontext"
 	"github.com/cockroachdb/errors"
 )
 
@@ -51,6 +52,11 @@ var serviceRegistry = map[string]ServicePermissions{
 		ExecutionActions: []string{"*"},
 		ConsoleActions:   []string{"Describe*", "List*", "GetItem", "BatchGetItem", "Query", "Scan"},
 	},
+	"ec2": {
+		// VPCs and security groups of Fargate services and databases.
+		ExecutionActions: []string{"*"},
+		ConsoleActions:   []string{"Describe*", "Get*"},
+	},
 	"ecr": {
 		ExecutionActions: []string{"*"},
 		ConsoleActions:   []string{"Describe*", "Get*", "List*", "BatchGetImage"},
@@ -89,6 +95,15 @@ var serviceRegistry = map[string]ServicePermissions{
 			"StartQuery", "StopQuery", "FilterLogEvents",
 		},
 	},
+	"rds": {
+		ExecutionActions: []string{"*"},
+		ConsoleActions:   []string{"Describe*", "List*"},
+	},
+	"rds-data": {
+		// Stacks may run statements through custom resources. The query editor can write, so
+		// console users get no Data API access.
+		ExecutionActions: []string{"*"},
+	},
 	"route53": {
 		ExecutionActions: []string{"*"},
 		ConsoleActions:   []string{"Get*", "List*"},
@@ -108,6 +123,12 @@ var serviceRegistry = map[string]ServicePermissions{
 			"ListSecretVersionIds", "GetResourcePolicy", "BatchGetSecretValue",
 		},
 	},
+	"serverlessrepo": {
+		// Secret rotation functions of databases are deployed from the Serverless Application
+		// Repository.
+		ExecutionActions: []string{"GetApplication", "GetCloudFormationTemplate", "CreateCloudFormationTemplate"},
+		ConsoleActions:   []string{"Get*", "List*"},
+	},
 	"sns": {
 		ExecutionActions: []string{"*"},
 		ConsoleActions:   []string{"Get*", "List*"},
@@ -245,35 +266,14 @@ func DefaultServices() []string {
 	}
 }
 
-// ParseServicesFromContext extracts the services list from CDK context.
-// The context key is "{prefix}services" and the value is a list of service names.
-func ParseServicesFromContext(context map[string]any, prefix string) ([]string, error) {
-	key := prefix + "services"
-	val, ok := context[key]
-	if !ok {
+// projectServices returns the services of the "{prefix}services" context key, or the default
+// services when the project does not list them.
+func projectServices(cdkCtx *cdkcontext.Context) ([]string, error) {
+	if cdkCtx.Services == nil {
 		return DefaultServices(), nil
 	}
-
-	switch contextValue := val.(type) {
-	case []any:
-		services := make([]string, 0, len(contextValue))
-		for _, item := range contextValue {
-			if s, ok := item.(string); ok {
-				services = append(services, s)
-			} else {
-				return nil, errors.Errorf("invalid service in %s: expected string, got %T", key, item)
-			}
-		}
-		if err := ValidateServices(services); err != nil {
-			return nil, err
-		}
-		return services, nil
-	case []string:
-		if err := ValidateServices(contextValue); err != nil {
-			return nil, err
-		}
-		return slices.Clone(contextValue), nil
-	default:
-		return nil, errors.Errorf("invalid %s: expected array, got %T", key, val)
+	if err := ValidateServices(cdkCtx.Services); err != nil {
+		return nil, err
 	}
+	return slices.Clone(cdkCtx.Services), nil
 }
@@ -4,6 +4,8 @@ import (
 	"slices"
 	"strings"
 	"testing"
+
+	"github.com/advdv/ago/cmd/ago/internal/cdkcontext"
 )
 
 func TestSupportedServices(t *testing.T) {
@@ -165,20 +167,18 @@ func TestGenerateConsoleActions_IncludesConsoleOnlyServices(t *testing.T) {
 	}
 }
 
-func TestParseServicesFromContext(t *testing.T) {
+func TestProjectServices(t *testing.T) {
 	t.Parallel()
 
 	tests := []struct {
 		name    string
 		context map[string]any
-		prefix  string
 		want    []string
 		wantErr bool
 	}{
 		{
 			name:    "missing key returns defaults",
 			context: map[string]any{},
-			prefix:  "proj-",
 			want:    DefaultServices(),
 			wantErr: false,
 		},
@@ -187,7 +187,6 @@ func TestParseServicesFromContext(t *testing.T) {
 			context: map[string]any{
 				"proj-services": []any{"lambda", "s3"},
 			},
-			prefix:  "proj-",
 			want:    []string{"lambda", "s3"},
 			wantErr: false,
 		},
@@ -196,37 +195,33 @@ func TestParseServicesFromContext(t *testing.T) {
 			context: map[string]any{
 				"proj-services": []any{"lambda", "not-a-service"},
 			},
-			prefix:  "proj-",
-			wantErr: true,
-		},
-		{
-			name: "wrong type for services",
-			context: map[string]any{
-				"proj-services": "lambda,s3",
-			},
-			prefix:  "proj-",
 			wantErr: true,
 		},
 		{
-			name: "wrong type in array",
+			name: "empty list",
 			context: map[string]any{
-				"proj-services": []any{"lambda", 123},
+				"proj-services": []any{},
 			},
-			prefix:  "proj-",
-			wantErr: true,
+			want:    []string{},
+			wantErr: false,
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			t.Parallel()
-			got, err := ParseServicesFromContext(tt.context, tt.prefix)
+			tt.context["proj-qualifier"] = "proj"
+			cdkCtx, err := cdkcontext.New(tt.context)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := projectServices(cdkCtx)
 			if (err != nil) != tt.wantErr {
-				t.Errorf("ParseServicesFromContext() error = %v, wantErr %v", err, tt.wantErr)
+				t.Errorf("projectServices() error = %v, wantErr %v", err, tt.wantErr)
 				return
 			}
 			if !tt.wantErr && !slices.Equal(got, tt.want) {
-				t.Errorf("ParseServicesFromContext() = %v, want %v", got, tt.want)
+				t.Errorf("projectServices() = %v, want %v", got, tt.want)
 			}
 		})
 	}
@@ -253,3 +248,25 @@ func TestServiceRegistry_AllHaveActions(t *testing.T) {
 		}
 	}
 }
+
+func TestGenerateActions_RDS(t *testing.T) {
+	t.Parallel()
+
+	services := []string{"rds", "rds-data"}
+	if err := ValidateServices(services); err != nil {
+		t.Fatal(err)
+	}
+
+	execution := GenerateExecutionActions(services)
+	for _, exp := range []string{"rds:*", "rds-data:*"} {
+		if !slices.Contains(execution, exp) {
+			t.Errorf("expected %q in execution actions", exp)
+		}
+	}
+
+	for _, action := range GenerateConsoleActions(services) {
+		if strings.HasPrefix(action, "rds-data:") {
+			t.Errorf("expected no Data API console actions, got %q", action)
+		}
+	}
+}
@@ -0,0 +1,300 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/advdv/ago/cmd/ago/internal/cmdexec"
+	"github.com/advdv/ago/cmd/ago/internal/config"
+	"github.com/cockroachdb/errors"
+	"github.com/urfave/cli/v3"
+)
+
+// setupStateFileName holds the progress of 'ago setup' in the project directory, so that an
+// interrupted run can be resumed. It is removed once all steps have completed.
+const setupStateFileName = ".ago-setup.json"
+
+// Setup steps, in the order they are executed.
+const (
+	setupStepInit          = "init"
+	setupStepCreateAccount = "create-account"
+	setupStepBootstrap     = "bootstrap"
+	setupStepDeploy        = "deploy"
+	setupStepDNSDelegate   = "dns-delegate"
+	setupStepDNSVerify     = "dns-verify"
+	setupStepDeployShared  = "deploy-shared"
+)
+
+func setupCmd() *cli.Command {
+	return &cli.Command{
+		Name:      "setup",
+		Usage:     "Go from an empty directory to a deployed environment (init, account, bootstrap, deploy, DNS)",
+		ArgsUsage: "[directory]",
+		Flags: []cli.Flag{
+			&cli.BoolFlag{
+				Name:    "yes",
+				Aliases: []string{"y"},
+				Usage:   "Accept all defaults without prompting",
+			},
+			&cli.StringFlag{
+				Name:  "local-ago",
+				Usage: "Path to local ago module (adds replace directive to go.mod)",
+			},
+			&cli.StringFlag{
+				Name:  "deployment",
+				Usage: "Deployment to deploy first (defaults to the initial deployer's development deployment)",
+			},
+			&cli.BoolFlag{
+				Name:  "deploy-shared",
+				Usage: "Finish by deploying the Shared stacks of all regions with 'ago infra cdk deploy-shared'",
+			},
+			&cli.DurationFlag{
+				Name:  "verification-timeout",
+				Usage: "Timeout for DNS propagation verification",
+				Value: time.Hour,
+			},
+		},
+		Action: runSetup,
+	}
+}
+
+type setupOptions struct {
+	Dir                 string
+	Yes                 bool
+	LocalAgoPath        string
+	Deployment          string
+	DeployShared        bool
+	VerificationTimeout time.Duration
+	Output              io.Writer
+}
+
+func runSetup(ctx context.Context, cmd *cli.Command) error {
+	dir, err := absProjectDir(cmd.Args().First())
+	if err != nil {
+		return err
+	}
+
+	return doSetup(ctx, setupOptions{
+		Dir:                 dir,
+		Yes:                 cmd.Bool("yes"),
+		LocalAgoPath:        cmd.String("local-ago"),
+		Deployment:          cmd.String("deployment"),
+		DeployShared:        cmd.Bool("deploy-shared"),
+		VerificationTimeout: cmd.Duration("verification-timeout"),
+		Output:              os.Stdout,
+	})
+}
+
+// setupState records the answers from the init wizard that later steps need, and the steps
+// that have completed.
+type setupState struct {
+	ManagementProfile string   `json:"management_profile"`
+	Region            string   `json:"region"`
+	EmailPattern      string   `json:"email_pattern"`
+	BaseDomainName    string   `json:"base_domain_name,omitempty"`
+	Deployment        string   `json:"deployment,omitempty"`
+	DeployShared      bool     `json:"deploy_shared,omitempty"`
+	Completed         []string `json:"completed"`
+}
+
+// steps returns the steps to run for this project. The DNS steps are skipped when the project
+// has no base domain. Deploying the Shared stacks is an optional last step.
+func (s setupState) steps() []string {
+	steps := []string{setupStepInit, setupStepCreateAccount, setupStepBootstrap, setupStepDeploy}
+	if s.BaseDomainName != "" {
+		steps = append(steps, setupStepDNSDelegate, setupStepDNSVerify)
+	}
+	if s.DeployShared {
+		steps = append(steps, setupStepDeployShared)
+	}
+	return steps
+}
+
+func (s setupState) done(step string) bool {
+	return slices.Contains(s.Completed, step)
+}
+
+func loadSetupState(dir string) (setupState, bool, error) {
+	data, err := os.ReadFile(filepath.Join(dir, setupStateFileName))
+	if errors.Is(err, os.ErrNotExist) {
+		return setupState{}, false, nil
+	}
+	if err != nil {
+		return setupState{}, false, errors.Wrap(err, "failed to read setup state")
+	}
+
+	var state setupState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return setupState{}, false, errors.Wrap(err, "failed to parse setup state")
+	}
+
+	return state, true, nil
+}
+
+func saveSetupState(dir string, state setupState) error {
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal setup state")
+	}
+
+	if err := cmdexec.WriteFile(filepath.Join(dir, setupStateFileName), data, 0o600); err != nil {
+		return errors.Wrap(err, "failed to write setup state")
+	}
+
+	return nil
+}
+
+func doSetup(ctx context.Context, opts setupOptions) error {
+	state, resumed, err := loadSetupState(opts.Dir)
+	if err != nil {
+		return err
+	}
+
+	if resumed {
+		writeOutputf(opts.Output, "Resuming setup in %s (completed: %v)\n", opts.Dir, state.Completed)
+	} else {
+		writeOutputf(opts.Output, "==> Initializing project in %s\n", opts.Dir)
+		if state, err = setupInit(ctx, opts); err != nil {
+			return err
+		}
+
+		// The later steps read the project that a dry run did not scaffold.
+		if cmdexec.DryRun() {
+			cmdexec.ReportDryRun("would continue with the steps %v", state.steps()[1:])
+			return nil
+		}
+	}
+
+	cfg, err := loadSetupConfig(opts.Dir)
+	if err != nil {
+		return err
+	}
+
+	// The optional step can also be added when resuming.
+	state.DeployShared = state.DeployShared || opts.DeployShared
+
+	for _, step := range state.steps() {
+		if state.done(step) {
+			continue
+		}
+
+		writeOutputf(opts.Output, "\n==> Running step %q\n", step)
+		if err := runSetupStep(ctx, cfg, opts, state, step); err != nil {
+			return errors.Wrapf(err, "setup step %q failed (re-run 'ago setup' to resume)", step)
+		}
+
+		// A dry run skips the step, so it must not be recorded as completed.
+		if cmdexec.DryRun() {
+			continue
+		}
+
+		state.Completed = append(state.Completed, step)
+		if err := saveSetupState(opts.Dir, state); err != nil {
+			return err
+		}
+	}
+
+	if err := cmdexec.Remove(filepath.Join(opts.Dir, setupStateFileName)); err != nil {
+		return errors.Wrap(err, "failed to remove setup state")
+	}
+
+	writeOutputf(opts.Output, "\nSetup complete!\n")
+	if state.BaseDomainName != "" {
+		writeOutputf(opts.Output, "  Domain: https://%s\n", state.BaseDomainName)
+	}
+
+	return nil
+}
+
+// setupInit runs the init wizard and scaffolds the project. Account creation and the CDK check
+// are left to the later steps so that each of them can be resumed on its own.
+func setupInit(ctx context.Context, opts setupOptions) (setupState, error) {
+	tmpl := embeddedProjectTemplates()[defaultProjectTemplate]
+	result, err := runInitWizard(opts.Yes, filepath.Base(opts.Dir), tmpl)
+	if err != nil {
+		return setupState{}, err
+	}
+
+	initOpts := initOptionsFromResult(opts.Dir, result, tmpl)
+	initOpts.LocalAgoPath = opts.LocalAgoPath
+	initOpts.SkipAccountCreation = true
+	initOpts.SkipCDKVerify = true
+
+	if err := doInit(ctx, initOpts); err != nil {
+		return setupState{}, err
+	}
+
+	state := setupState{
+		ManagementProfile: result.ManagementProfile,
+		Region:            result.PrimaryRegion,
+		EmailPattern:      initOpts.CDKConfig.EmailPattern,
+		BaseDomainName:    result.BaseDomainName,
+		DeployShared:      opts.DeployShared,
+		Completed:         []string{setupStepInit},
+	}
+	if result.InitialDeployer != "" {
+		state.Deployment = "Dev" + result.InitialDeployer
+	}
+
+	if err := saveSetupState(opts.Dir, state); err != nil {
+		return setupState{}, err
+	}
+
+	return state, nil
+}
+
+func loadSetupConfig(dir string) (config.Config, error) {
+	inner, projectDir, err := config.NewFinder(config.NewLoader()).Find(dir)
+	if err != nil {
+		return config.Config{}, err
+	}
+
+	return config.Config{Inner: inner, ProjectDir: projectDir}, nil
+}
+
+func runSetupStep(ctx context.Context, cfg config.Config, opts setupOptions, state setupState, step string) error {
+	switch step {
+	case setupStepCreateAccount:
+		return doCreateProjectAccount(ctx, cfg, createAccountOptions{
+			ProjectName:       filepath.Base(cfg.ProjectDir),
+			ManagementProfile: state.ManagementProfile,
+			Region:            state.Region,
+			WriteProfile:      true,
+			EmailPattern:      state.EmailPattern,
+			Output:            opts.Output,
+		})
+	case setupStepBootstrap:
+		return doBootstrap(ctx, cfg, bootstrapOptions{
+			Output: opts.Output,
+		})
+	case setupStepDeploy:
+		return doDeploy(ctx, cfg, cdkCommandOptions{
+			Deployment: cmp.Or(opts.Deployment, state.Deployment),
+			Output:     opts.Output,
+		})
+	case setupStepDNSDelegate:
+		return doDNSDelegate(ctx, cfg, dnsDelegateOptions{
+			ManagementProfile:   state.ManagementProfile,
+			VerificationTimeout: opts.VerificationTimeout,
+			Output:              opts.Output,
+		})
+	case setupStepDNSVerify:
+		return doDNSVerify(ctx, cfg, dnsVerifyOptions{
+			Wait:    true,
+			Timeout: opts.VerificationTimeout,
+			Output:  opts.Output,
+		})
+	case setupStepDeployShared:
+		return doDeployShared(ctx, cfg, deploySharedOptions{
+			Output: opts.Output,
+		})
+	default:
+		return errors.Errorf("unknown setup step %q", step)
+	}
+}
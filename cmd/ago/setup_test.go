@@ -0,0 +1,79 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestSetupStateSteps(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name  string
+		state setupState
+		want  []string
+	}{
+		{
+			name:  "without base domain",
+			state: setupState{},
+			want:  []string{"init", "create-account", "bootstrap", "deploy"},
+		},
+		{
+			name:  "with base domain",
+			state: setupState{BaseDomainName: "myapp.example.com"},
+			want:  []string{"init", "create-account", "bootstrap", "deploy", "dns-delegate", "dns-verify"},
+		},
+		{
+			name:  "with shared stacks",
+			state: setupState{DeployShared: true},
+			want:  []string{"init", "create-account", "bootstrap", "deploy", "deploy-shared"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if got := tt.state.steps(); !slices.Equal(got, tt.want) {
+				t.Errorf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestSetupStateRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+
+	_, found, err := loadSetupState(dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if found {
+		t.Fatal("expected no state in empty directory")
+	}
+
+	want := setupState{
+		ManagementProfile: "management",
+		Region:            "eu-central-1",
+		EmailPattern:      "admin+{project}@example.com",
+		Deployment:        "DevAdam",
+		Completed:         []string{setupStepInit, setupStepCreateAccount},
+	}
+	if err := saveSetupState(dir, want); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	got, found, err := loadSetupState(dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !found {
+		t.Fatal("expected state to be found")
+	}
+	if !got.done(setupStepCreateAccount) || got.done(setupStepBootstrap) {
+		t.Errorf("unexpected completed steps: %v", got.Completed)
+	}
+	if got.Deployment != want.Deployment || got.ManagementProfile != want.ManagementProfile {
+		t.Errorf("expected %+v, got %+v", want, got)
+	}
+}
@@ -0,0 +1,323 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"io"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/advdv/ago/agcdkutil"
+	"github.com/advdv/ago/cmd/ago/internal/awsapi"
+	"github.com/advdv/ago/cmd/ago/internal/config"
+	"github.com/advdv/ago/cmd/ago/internal/ops"
+	"github.com/advdv/ago/cmd/ago/internal/warnings"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation"
+	"github.com/cockroachdb/errors"
+	"github.com/urfave/cli/v3"
+)
+
+// devDeploymentPrefix starts the identifier of every Dev deployment, followed by the name of the
+// deployer that owns it.
+const devDeploymentPrefix = "Dev"
+
+func statusCmd() *cli.Command {
+	return &cli.Command{
+		Name: "status",
+		Usage: "Show the deployed Dev deployments with their owner and when their stacks were last updated. " +
+			"With --stale only those not updated in --stale-days or whose deployer was removed, which a " +
+			"scheduled CI job can reap with --reap --confirm <qualifier>",
+		Flags: []cli.Flag{
+			&cli.BoolFlag{
+				Name:  "stale",
+				Usage: "Only show the Dev deployments that are stale, with the command that destroys them",
+			},
+			&cli.IntFlag{
+				Name:  "stale-days",
+				Usage: "Number of days without a stack update after which a Dev deployment is stale",
+				Value: 14,
+			},
+			&cli.BoolFlag{
+				Name:  "reap",
+				Usage: "Delete the stacks of the stale Dev deployments, requires --confirm",
+			},
+			&cli.StringFlag{
+				Name:  "confirm",
+				Usage: "The project's qualifier, to confirm that --reap deletes the stacks and their data",
+			},
+			&cli.BoolFlag{
+				Name: "empty",
+				Usage: "Empty the S3 buckets and ECR repositories of the reaped stacks, which CloudFormation " +
+					"cannot delete while they hold objects or images",
+			},
+		},
+		Action: config.RunWithConfig(runStatus),
+	}
+}
+
+type statusOptions struct {
+	Stale       bool
+	StaleDays   int
+	Reap        bool
+	Confirm     string
+	Empty       bool
+	Acknowledge []string
+	Profile     string
+	Now         time.Time
+	Output      io.Writer
+	Result      io.Writer
+}
+
+func runStatus(ctx context.Context, cmd *cli.Command, cfg config.Config) error {
+	output, result := commandOutput(cmd)
+	return doStatus(ctx, cfg, statusOptions{
+		Stale:       cmd.Bool("stale") || cmd.Bool("reap"),
+		StaleDays:   int(cmd.Int("stale-days")),
+		Reap:        cmd.Bool("reap"),
+		Confirm:     cmd.String("confirm"),
+		Empty:       cmd.Bool("empty"),
+		Acknowledge: cmd.StringSlice("acknowledge"),
+		Profile:     cmd.String("profile"),
+		Now:         time.Now(),
+		Output:      output,
+		Result:      result,
+	})
+}
+
+// deployedStack is a deployed stack of a Dev deployment.
+type deployedStack struct {
+	Name        string
+	Region      string
+	Deployment  string
+	LastUpdated time.Time
+}
+
+// devDeploymentStatus is a deployed Dev deployment in the result of 'ago status'.
+type devDeploymentStatus struct {
+	Deployment  string    `json:"deployment"`
+	Owner       string    `json:"owner,omitempty"`
+	Regions     []string  `json:"regions"`
+	Stacks      []string  `json:"stacks"`
+	LastUpdated time.Time `json:"lastUpdated"`
+	Stale       bool      `json:"stale"`
+	Reasons     []string  `json:"reasons,omitempty"`
+	Reaped      bool      `json:"reaped,omitempty"`
+}
+
+func doStatus(ctx context.Context, cfg config.Config, opts statusOptions) error {
+	if opts.StaleDays <= 0 {
+		return errors.Errorf("--stale-days must be positive, got %d", opts.StaleDays)
+	}
+
+	cdk, err := loadCDKContext(cfg)
+	if err != nil {
+		return err
+	}
+	if opts.Reap && opts.Confirm != cdk.Qualifier {
+		return errors.Errorf("reaping deletes the stacks of the stale Dev deployments and the data they hold, "+
+			"rerun with --confirm %s to proceed", cdk.Qualifier)
+	}
+
+	profile, err := resolveAWSProfile(opts.Profile, func() (string, error) { return getCDKProfile(cdk.Context) })
+	if err != nil {
+		return err
+	}
+
+	if err := cdk.Require("primary-region"); err != nil {
+		return err
+	}
+	regions := cdk.Regions()
+
+	var stacks []deployedStack
+	for _, region := range regions {
+		clients, err := awsapi.New(ctx, profile, region)
+		if err != nil {
+			return err
+		}
+		found, err := listDevStacks(ctx, clients, cdk.Qualifier, region)
+		if err != nil {
+			return err
+		}
+		stacks = append(stacks, found...)
+	}
+
+	deployers := append(cdk.Deployers,
+		cdk.DevDeployers...)
+	statuses := devDeploymentStatuses(stacks, deployers, opts.Now, time.Duration(opts.StaleDays)*24*time.Hour)
+	if opts.Stale {
+		statuses = slices.DeleteFunc(statuses, func(s devDeploymentStatus) bool { return !s.Stale })
+	}
+
+	deployments := cdk.Deployments
+	for _, status := range statuses {
+		writeOutputf(opts.Output, "%s (owner: %s, last updated %s, regions: %s)\n", status.Deployment,
+			cmp.Or(status.Owner, "none"), status.LastUpdated.Format(time.DateOnly), strings.Join(status.Regions, ", "))
+		for _, reason := range status.Reasons {
+			writeOutputf(opts.Output, "  stale: %s\n", reason)
+		}
+		switch {
+		case !status.Stale || opts.Reap:
+		case slices.Contains(deployments, status.Deployment):
+			writeOutputf(opts.Output, "  destroy: ago infra cdk destroy %s --confirm %s\n",
+				status.Deployment, cdk.Qualifier)
+		default:
+			writeOutputf(opts.Output, "  destroy: ago status --stale --reap --confirm %s "+
+				"(the deployment is no longer in cdk.context.json)\n", cdk.Qualifier)
+		}
+	}
+	if len(statuses) == 0 {
+		if opts.Stale {
+			writeOutputf(opts.Output, "No stale Dev deployments\n")
+		} else {
+			writeOutputf(opts.Output, "No deployed Dev deployments\n")
+		}
+	}
+
+	if opts.Reap && len(statuses) > 0 {
+		if err := reapDevDeployments(ctx, cfg, cdk, opts, profile, regions, statuses); err != nil {
+			return err
+		}
+	}
+
+	return writeResult(opts.Result, statuses)
+}
+
+// reapDevDeployments deletes the stacks of the stale Dev deployments, the secondary regions before
+// the primary region as destroy does. The deployments are reaped one at a time under their deploy
+// lock, and protected resources are refused unless that was acknowledged.
+func reapDevDeployments(
+	ctx context.Context, cfg config.Config, cdk *cdkContext, opts statusOptions, profile string,
+	regions []string, statuses []devDeploymentStatus,
+) error {
+	warn, err := warnings.New(opts.Output, opts.Acknowledge)
+	if err != nil {
+		return err
+	}
+
+	lk := newLocker(cfg, profile, regions[0], cdk.Qualifier)
+
+	for i, status := range statuses {
+		var stages []deployStage
+		for _, stage := range destroyStages(cdk.Qualifier, regions, []string{status.Deployment}, false) {
+			stage.Stacks = slices.DeleteFunc(stage.Stacks, func(s string) bool {
+				return !slices.Contains(status.Stacks, s)
+			})
+			if len(stage.Stacks) > 0 {
+				stages = append(stages, stage)
+			}
+		}
+
+		scopes := deploymentLockScopes([]string{status.Deployment})
+		if err := withLocks(ctx, lk, opts.Output, "reap", scopes, func() error {
+			if err := prepareDestroy(ctx, opts.Output, warn, profile, stages, opts.Empty); err != nil {
+				return err
+			}
+			for _, stage := range stages {
+				clients, err := awsapi.New(ctx, profile, stage.Region)
+				if err != nil {
+					return err
+				}
+				for _, stack := range stage.Stacks {
+					writeOutputf(opts.Output, "Deleting stack %s in %s...\n", stack, stage.Region)
+					if err := ops.DeleteStack(ctx, clients, stack); err != nil {
+						return err
+					}
+				}
+			}
+			return nil
+		}); err != nil {
+			return errors.Wrapf(err, "failed to reap deployment %q", status.Deployment)
+		}
+
+		statuses[i].Reaped = true
+		writeOutputf(opts.Output, "Reaped %s\n", status.Deployment)
+	}
+	return nil
+}
+
+// listDevStacks returns the deployed stacks of the Dev deployments in the region of the clients,
+// also those of deployments that were removed from cdk.context.json.
+func listDevStacks(ctx context.Context, clients *awsapi.Clients, qualifier, region string) ([]deployedStack, error) {
+	prefix := agcdkutil.DeploymentStackName(qualifier, agcdkutil.RegionIdentFor(region), devDeploymentPrefix)
+
+	var stacks []deployedStack
+	paginator := cloudformation.NewDescribeStacksPaginator(clients.CloudFormation,
+		&cloudformation.DescribeStacksInput{})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to list the stacks in region %s", region)
+		}
+		for _, stack := range page.Stacks {
+			name := aws.ToString(stack.StackName)
+			if !strings.HasPrefix(name, prefix) || stack.StackStatus == "DELETE_COMPLETE" {
+				continue
+			}
+			lastUpdated := aws.ToTime(stack.CreationTime)
+			if stack.LastUpdatedTime != nil {
+				lastUpdated = aws.ToTime(stack.LastUpdatedTime)
+			}
+			stacks = append(stacks, deployedStack{
+				Name:        name,
+				Region:      region,
+				Deployment:  devDeploymentPrefix + strings.TrimPrefix(name, prefix),
+				LastUpdated: lastUpdated,
+			})
+		}
+	}
+	return stacks, nil
+}
+
+// devDeploymentStatuses groups the deployed stacks by Dev deployment, sorted by deployment. A
+// deployment is stale when none of its stacks was updated within maxAge, or when the deployer
+// that owns it is no longer among the deployers.
+func devDeploymentStatuses(
+	stacks []deployedStack, deployers []string, now time.Time, maxAge time.Duration,
+) []devDeploymentStatus {
+	byDeployment := map[string]*devDeploymentStatus{}
+	for _, stack := range stacks {
+		status, ok := byDeployment[stack.Deployment]
+		if !ok {
+			status = &devDeploymentStatus{
+				Deployment: stack.Deployment,
+				Owner:      strings.TrimPrefix(stack.Deployment, devDeploymentPrefix),
+			}
+			byDeployment[stack.Deployment] = status
+		}
+		status.Stacks = append(status.Stacks, stack.Name)
+		if !slices.Contains(status.Regions, stack.Region) {
+			status.Regions = append(status.Regions, stack.Region)
+		}
+		if stack.LastUpdated.After(status.LastUpdated) {
+			status.LastUpdated = stack.LastUpdated
+		}
+	}
+
+	statuses := make([]devDeploymentStatus, 0, len(byDeployment))
+	for _, deployment := range slices.Sorted(maps.Keys(byDeployment)) {
+		status := *byDeployment[deployment]
+		if age := now.Sub(status.LastUpdated); age > maxAge {
+			status.Reasons = append(status.Reasons, "not updated in "+
+				formatDays(age)+", more than "+formatDays(maxAge))
+		}
+		if status.Owner != "" && !slices.Contains(deployers, status.Owner) {
+			status.Reasons = append(status.Reasons, "deployer "+status.Owner+" was removed")
+		}
+		status.Stale = len(status.Reasons) > 0
+		statuses = append(statuses, status)
+	}
+	return statuses
+}
+
+// formatDays formats a duration in whole days.
+func formatDays(d time.Duration) string {
+	days := int(d / (24 * time.Hour))
+	if days == 1 {
+		return "1 day"
+	}
+	return strconv.Itoa(days) + " days"
+}
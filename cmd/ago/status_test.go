@@ -0,0 +1,38 @@
+package main
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestDevDeploymentStatuses(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2026, 3, 20, 12, 0, 0, 0, time.UTC)
+	stacks := []deployedStack{
+		{Name: "myappEuwest1DevBob", Region: "eu-west-1", Deployment: "DevBob", LastUpdated: now.AddDate(0, 0, -30)},
+		{Name: "myappUseast1DevBob", Region: "us-east-1", Deployment: "DevBob", LastUpdated: now.AddDate(0, 0, -3)},
+		{Name: "myappEuwest1DevAdam", Region: "eu-west-1", Deployment: "DevAdam", LastUpdated: now.AddDate(0, 0, -20)},
+		{Name: "myappEuwest1DevCarol", Region: "eu-west-1", Deployment: "DevCarol", LastUpdated: now.AddDate(0, 0, -1)},
+	}
+
+	statuses := devDeploymentStatuses(stacks, []string{"Adam", "Bob"}, now, 14*24*time.Hour)
+	if len(statuses) != 3 {
+		t.Fatalf("expected 3 deployments, got %d", len(statuses))
+	}
+
+	adam, bob, carol := statuses[0], statuses[1], statuses[2]
+	if adam.Deployment != "DevAdam" || !adam.Stale ||
+		!slices.Equal(adam.Reasons, []string{"not updated in 20 days, more than 14 days"}) {
+		t.Errorf("unexpected status of DevAdam: %+v", adam)
+	}
+	if bob.Stale || !bob.LastUpdated.Equal(now.AddDate(0, 0, -3)) ||
+		!slices.Equal(bob.Regions, []string{"eu-west-1", "us-east-1"}) || len(bob.Stacks) != 2 {
+		t.Errorf("unexpected status of DevBob: %+v", bob)
+	}
+	if carol.Owner != "Carol" || !carol.Stale ||
+		!slices.Equal(carol.Reasons, []string{"deployer Carol was removed"}) {
+		t.Errorf("unexpected status of DevCarol: %+v", carol)
+	}
+}
@@ -55,11 +55,26 @@ Parameters:
     Type: CommaDelimitedList
     Description: List of dev deployer usernames
     Default: ""
+  CIRepository:
+    Type: String
+    Description: GitHub repository, as owner/name, whose workflows may assume the CI deployer role
+    Default: ""
+  DeployerAuth:
+    Type: String
+    Description: How deployers authenticate, with IAM users and access keys or through IAM Identity Center
+    AllowedValues: [iam-users, sso]
+    Default: iam-users
 
 Conditions:
   HasSecondaryRegions: !Not [!Equals [!Join ["", !Ref SecondaryRegions], ""]]
-  HasDeployers: !Not [!Equals [!Join ["", !Ref Deployers], ""]]
-  HasDevDeployers: !Not [!Equals [!Join ["", !Ref DevDeployers], ""]]
+  UsesIAMUsers: !Equals [!Ref DeployerAuth, iam-users]
+  HasDeployers: !And
+    - !Not [!Equals [!Join ["", !Ref Deployers], ""]]
+    - !Condition UsesIAMUsers
+  HasDevDeployers: !And
+    - !Not [!Equals [!Join ["", !Ref DevDeployers], ""]]
+    - !Condition UsesIAMUsers
+  HasCIRepository: !Not [!Equals [!Ref CIRepository, ""]]
 
 Resources:
   DeployerPolicy:
@@ -72,9 +87,11 @@ Resources:
         Statement:
           - Sid: AssumeCDKRoles
             Effect: Allow
-            Action: sts:AssumeRole
+            Action:
+              - sts:AssumeRole
+              - sts:TagSession
             Resource:
-              - !Sub "arn:aws:iam::${AWS::AccountId}:role/cdk-${Qualifier}-*"
+              - !Sub "arn:${AWS::Partition}:iam::${AWS::AccountId}:role/cdk-${Qualifier}-*"
           - Sid: CloudFormationAccess
             Effect: Allow
             Action:
@@ -89,20 +106,44 @@ Resources:
               - s3:GetObject
               - s3:ListBucket
             Resource:
-              - !Sub "arn:aws:s3:::cdk-${Qualifier}-assets-${AWS::AccountId}-*"
-              - !Sub "arn:aws:s3:::cdk-${Qualifier}-assets-${AWS::AccountId}-*/*"
+              - !Sub "arn:${AWS::Partition}:s3:::cdk-${Qualifier}-assets-${AWS::AccountId}-*"
+              - !Sub "arn:${AWS::Partition}:s3:::cdk-${Qualifier}-assets-${AWS::AccountId}-*/*"
           - Sid: SSMParameterAccess
             Effect: Allow
             Action:
               - ssm:GetParameter
               - ssm:GetParameters
-            Resource: !Sub "arn:aws:ssm:*:${AWS::AccountId}:parameter/cdk-bootstrap/${Qualifier}/*"
+            Resource: !Sub "arn:${AWS::Partition}:ssm:*:${AWS::AccountId}:parameter/cdk-bootstrap/${Qualifier}/*"
+          - Sid: CommandLocks
+            Effect: Allow
+            Action:
+              - ssm:PutParameter
+              - ssm:DeleteParameter
+              - ssm:GetParametersByPath
+            Resource:
+              - !Sub "arn:${AWS::Partition}:ssm:*:${AWS::AccountId}:parameter/${Qualifier}/locks"
+              - !Sub "arn:${AWS::Partition}:ssm:*:${AWS::AccountId}:parameter/${Qualifier}/locks/*"
+          - Sid: ImagePromotions
+            Effect: Allow
+            Action:
+              - ssm:PutParameter
+              - ssm:GetParameterHistory
+            Resource: !Sub "arn:${AWS::Partition}:ssm:*:${AWS::AccountId}:parameter/${Qualifier}/promotions/*"
+          - Sid: DeployProvenance
+            Effect: Allow
+            Action:
+              - s3:PutObject
+              - s3:GetObject
+              - s3:ListBucket
+            Resource:
+              - !GetAtt ProvenanceBucket.Arn
+              - !Sub "${ProvenanceBucket.Arn}/*"
           - Sid: ConsoleFederation
             Effect: Allow
             Action:
               - sts:GetFederationToken
               - sts:TagSession
-            Resource: !Sub "arn:aws:sts::${AWS::AccountId}:federated-user/*"
+            Resource: !Sub "arn:${AWS::Partition}:sts::${AWS::AccountId}:federated-user/*"
           - Sid: ConsoleReadAccess
             Effect: Allow
             Action:
@@ -130,10 +171,10 @@ Resources:
             Effect: Allow
             Action: iam:CreateServiceLinkedRole
             Resource:
-              - !Sub "arn:aws:iam::${AWS::AccountId}:role/aws-service-role/replication.ecr.amazonaws.com/*"
-              - !Sub "arn:aws:iam::${AWS::AccountId}:role/aws-service-role/replication.dynamodb.amazonaws.com/*"
-              - !Sub "arn:aws:iam::${AWS::AccountId}:role/aws-service-role/ops.apigateway.amazonaws.com/*"
-              - !Sub "arn:aws:iam::${AWS::AccountId}:role/aws-service-role/autoscaling.amazonaws.com/*"
+              - !Sub "arn:${AWS::Partition}:iam::${AWS::AccountId}:role/aws-service-role/replication.ecr.amazonaws.com/*"
+              - !Sub "arn:${AWS::Partition}:iam::${AWS::AccountId}:role/aws-service-role/replication.dynamodb.amazonaws.com/*"
+              - !Sub "arn:${AWS::Partition}:iam::${AWS::AccountId}:role/aws-service-role/ops.apigateway.amazonaws.com/*"
+              - !Sub "arn:${AWS::Partition}:iam::${AWS::AccountId}:role/aws-service-role/autoscaling.amazonaws.com/*"
           - Sid: EnforceBoundary
             Effect: Deny
             Action:
@@ -142,7 +183,7 @@ Resources:
             Resource: "*"
             Condition:
               StringNotEquals:
-                iam:PermissionsBoundary: !Sub "arn:aws:iam::${AWS::AccountId}:policy/${Qualifier}-permissions-boundary"
+                iam:PermissionsBoundary: !Sub "arn:${AWS::Partition}:iam::${AWS::AccountId}:policy/${Qualifier}-permissions-boundary"
 
   PermissionsBoundary:
     Type: AWS::IAM::ManagedPolicy
@@ -163,7 +204,7 @@ Resources:
               - iam:DeletePolicyVersion
               - iam:CreatePolicyVersion
               - iam:SetDefaultPolicyVersion
-            Resource: !Sub "arn:aws:iam::${AWS::AccountId}:policy/${Qualifier}-permissions-boundary"
+            Resource: !Sub "arn:${AWS::Partition}:iam::${AWS::AccountId}:policy/${Qualifier}-permissions-boundary"
           - Sid: DenyBoundaryRemoval
             Effect: Deny
             Action:
@@ -178,7 +219,89 @@ Resources:
             Resource: "*"
             Condition:
               StringNotEquals:
-                iam:PermissionsBoundary: !Sub "arn:aws:iam::${AWS::AccountId}:policy/${Qualifier}-permissions-boundary"
+                iam:PermissionsBoundary: !Sub "arn:${AWS::Partition}:iam::${AWS::AccountId}:policy/${Qualifier}-permissions-boundary"
+
+  DevDeployerScopePolicy:
+    Type: AWS::IAM::ManagedPolicy
+    Properties:
+      ManagedPolicyName: !Sub "${Qualifier}-dev-deployer-scope"
+      Description: Restricts dev deployers to the shared stacks and their own deployment
+      PolicyDocument:
+        Version: "2012-10-17"
+        Statement:
+          - Sid: RequireDeploymentSessionTag
+            Effect: Deny
+            Action: sts:AssumeRole
+            Resource: !Sub "arn:${AWS::Partition}:iam::${AWS::AccountId}:role/cdk-${Qualifier}-deploy-role-*"
+            Condition:
+              "Null":
+                aws:RequestTag/ago-deployment: "true"
+          - Sid: RestrictDeploymentSessionTag
+            Effect: Deny
+            Action: sts:TagSession
+            Resource: "*"
+            Condition:
+              StringNotEquals:
+                aws:RequestTag/ago-deployment:
+                  - "${aws:PrincipalTag/ago-deployment}"
+                  - Shared
+
+  DeploymentScopePolicy:
+    Type: AWS::IAM::ManagedPolicy
+    Properties:
+      ManagedPolicyName: !Sub "${Qualifier}-deployment-scope"
+      Description: Restricts deploy role sessions to the stacks of the deployment they are tagged with, and lets them operate its resources
+      PolicyDocument:
+        Version: "2012-10-17"
+        Statement:
+          - Sid: DenyOtherDeploymentStacks
+            Effect: Deny
+            Action:
+              - cloudformation:CreateStack
+              - cloudformation:UpdateStack
+              - cloudformation:DeleteStack
+              - cloudformation:CreateChangeSet
+              - cloudformation:ExecuteChangeSet
+              - cloudformation:DeleteChangeSet
+              - cloudformation:CancelUpdateStack
+              - cloudformation:ContinueUpdateRollback
+              - cloudformation:RollbackStack
+            NotResource: !Sub "arn:${AWS::Partition}:cloudformation:*:${AWS::AccountId}:stack/${Qualifier}*${!aws:PrincipalTag/ago-deployment}/*"
+            Condition:
+              "Null":
+                aws:PrincipalTag/ago-deployment: "false"
+          - Sid: OperateDeploymentResources
+            Effect: Allow
+            Action:
+              - sqs:GetQueueAttributes
+              - sqs:ReceiveMessage
+              - sqs:ChangeMessageVisibility
+              - sqs:SendMessage
+              - sqs:DeleteMessage
+              - sqs:StartMessageMoveTask
+              - events:PutEvents
+              - events:DescribeArchive
+              - events:StartReplay
+              - secretsmanager:GetSecretValue
+              - secretsmanager:PutSecretValue
+              - secretsmanager:CreateSecret
+              - secretsmanager:DescribeSecret
+            Resource:
+              - !Sub "arn:${AWS::Partition}:sqs:*:${AWS::AccountId}:${Qualifier}*"
+              - !Sub "arn:${AWS::Partition}:events:*:${AWS::AccountId}:event-bus/${Qualifier}*"
+              - !Sub "arn:${AWS::Partition}:events:*:${AWS::AccountId}:archive/${Qualifier}*"
+              - !Sub "arn:${AWS::Partition}:events:*:${AWS::AccountId}:replay/*"
+              - !Sub "arn:${AWS::Partition}:secretsmanager:*:${AWS::AccountId}:secret:${Qualifier}/*"
+            Condition:
+              "Null":
+                aws:PrincipalTag/ago-deployment: "false"
+          - Sid: ListDeploymentSecrets
+            Effect: Allow
+            Action: secretsmanager:ListSecrets
+            Resource: "*"
+            Condition:
+              "Null":
+                aws:PrincipalTag/ago-deployment: "false"
 
   DeployersGroup:
     Type: AWS::IAM::Group
@@ -193,6 +316,7 @@ Resources:
       GroupName: !Sub "${Qualifier}-dev-deployers"
       ManagedPolicyArns:
         - !Ref DeployerPolicy
+        - !Ref DevDeployerScopePolicy
 
   MainSecret:
     Type: AWS::SecretsManager::Secret
@@ -221,6 +345,24 @@ Resources:
               StringEquals:
                 aws:SourceAccount: !Ref AWS::AccountId
 
+  ProvenanceBucket:
+    Type: AWS::S3::Bucket
+    DeletionPolicy: Retain
+    UpdateReplacePolicy: Retain
+    Properties:
+      BucketName: !Sub "${Qualifier}-provenance-${AWS::AccountId}"
+      VersioningConfiguration:
+        Status: Enabled
+      BucketEncryption:
+        ServerSideEncryptionConfiguration:
+          - ServerSideEncryptionByDefault:
+              SSEAlgorithm: AES256
+      PublicAccessBlockConfiguration:
+        BlockPublicAcls: true
+        BlockPublicPolicy: true
+        IgnorePublicAcls: true
+        RestrictPublicBuckets: true
+
   GitHubOIDCProvider:
     Type: AWS::IAM::OIDCProvider
     Properties:
@@ -233,6 +375,7 @@ Resources:
 
   CIDeployerRole:
     Type: AWS::IAM::Role
+    Condition: HasCIRepository
     Properties:
       RoleName: !Sub "${Qualifier}-ci-deployer"
       AssumeRolePolicyDocument:
@@ -244,7 +387,7 @@ Resources:
             Action: sts:AssumeRoleWithWebIdentity
             Condition:
               StringLike:
-                token.actions.githubusercontent.com:sub: "repo:*:*"
+                token.actions.githubusercontent.com:sub: !Sub "repo:${CIRepository}:*"
               StringEquals:
                 token.actions.githubusercontent.com:aud: sts.amazonaws.com
       ManagedPolicyArns:
@@ -290,6 +433,9 @@ Resources:
         Condition: HasDevDeployers
         Properties:
           UserName: !Ref UserName
+          Tags:
+            - Key: ago-deployment
+              Value: !Sub "Dev${UserName}"
           Groups:
             - !Ref DevDeployersGroup
       DevDeployerAccessKey${UserName}:
@@ -325,6 +471,9 @@ Outputs:
     Value: !Ref PermissionsBoundary
     Export:
       Name: !Sub "${Qualifier}-PermissionsBoundaryArn"
+  DeploymentScopePolicyArn:
+    Description: ARN of the policy that restricts deploy role sessions to their tagged deployment
+    Value: !Ref DeploymentScopePolicy
   PermissionsBoundaryName:
     Description: Name of the permissions boundary
     Value: !Sub "${Qualifier}-permissions-boundary"
@@ -340,13 +489,121 @@ Outputs:
     Value: !GetAtt DevDeployersGroup.Arn
     Export:
       Name: !Sub "${Qualifier}-DevDeployersGroupArn"
+  ProvenanceBucketName:
+    Description: Name of the bucket that holds the provenance records of deploys
+    Value: !Ref ProvenanceBucket
   CIDeployerRoleArn:
+    Condition: HasCIRepository
     Description: ARN of the CI deployer role
     Value: !GetAtt CIDeployerRole.Arn
     Export:
       Name: !Sub "${Qualifier}-CIDeployerRoleArn"
 `))
 
+// deployerSSOTemplate is deployed to the management account, which administers IAM Identity
+// Center. The permission sets reference the deployer policy that the pre-bootstrap stack created
+// in the project account by name. Dev deployers get a permission set each that limits the
+// deployment session tag to their own deployment, as the dev deployer scope policy does for IAM
+// users through their principal tag.
+var deployerSSOTemplate = template.Must(template.New("deployer-sso.yaml").Parse(
+	`AWSTemplateFormatVersion: '2010-09-09'
+Description: IAM Identity Center access of the deployers of {{.Qualifier}}
+
+Resources:
+  DeployerPermissionSet:
+    Type: AWS::SSO::PermissionSet
+    Properties:
+      InstanceArn: {{.InstanceARN}}
+      Name: {{.DeployerPermissionSet}}
+      Description: Full deployer of {{.Qualifier}}
+      SessionDuration: {{.SessionDuration}}
+      CustomerManagedPolicyReferences:
+        - Name: {{.Qualifier}}-deployer-policy
+
+  DeployersGroup:
+    Type: AWS::IdentityStore::Group
+    Properties:
+      IdentityStoreId: {{.IdentityStoreID}}
+      DisplayName: {{.Qualifier}}-deployers
+      Description: Full deployers of {{.Qualifier}}
+
+  DeployersAssignment:
+    Type: AWS::SSO::Assignment
+    Properties:
+      InstanceArn: {{.InstanceARN}}
+      PermissionSetArn: !GetAtt DeployerPermissionSet.PermissionSetArn
+      PrincipalType: GROUP
+      PrincipalId: !GetAtt DeployersGroup.GroupId
+      TargetType: AWS_ACCOUNT
+      TargetId: "{{.AccountID}}"
+
+  DevDeployersGroup:
+    Type: AWS::IdentityStore::Group
+    Properties:
+      IdentityStoreId: {{.IdentityStoreID}}
+      DisplayName: {{.Qualifier}}-dev-deployers
+      Description: Dev deployers of {{.Qualifier}}, each assigned their own permission set
+{{- range .Deployers}}
+
+  DeployerMembership{{.LogicalID}}:
+    Type: AWS::IdentityStore::GroupMembership
+    Properties:
+      IdentityStoreId: {{$.IdentityStoreID}}
+      GroupId: !GetAtt DeployersGroup.GroupId
+      MemberId:
+        UserId: {{.UserID}}
+{{- end}}
+{{- range .DevDeployers}}
+
+  DevDeployerMembership{{.LogicalID}}:
+    Type: AWS::IdentityStore::GroupMembership
+    Properties:
+      IdentityStoreId: {{$.IdentityStoreID}}
+      GroupId: !GetAtt DevDeployersGroup.GroupId
+      MemberId:
+        UserId: {{.UserID}}
+
+  DevDeployerPermissionSet{{.LogicalID}}:
+    Type: AWS::SSO::PermissionSet
+    Properties:
+      InstanceArn: {{$.InstanceARN}}
+      Name: {{.PermissionSet}}
+      Description: Dev deployer {{.Username}} of {{$.Qualifier}}
+      SessionDuration: {{$.SessionDuration}}
+      CustomerManagedPolicyReferences:
+        - Name: {{$.Qualifier}}-deployer-policy
+      InlinePolicy:
+        Version: "2012-10-17"
+        Statement:
+          - Sid: RequireDeploymentSessionTag
+            Effect: Deny
+            Action: sts:AssumeRole
+            Resource: !Sub "arn:${AWS::Partition}:iam::{{$.AccountID}}:role/cdk-{{$.Qualifier}}-deploy-role-*"
+            Condition:
+              "Null":
+                aws:RequestTag/ago-deployment: "true"
+          - Sid: RestrictDeploymentSessionTag
+            Effect: Deny
+            Action: sts:TagSession
+            Resource: "*"
+            Condition:
+              StringNotEquals:
+                aws:RequestTag/ago-deployment:
+                  - {{.Deployment}}
+                  - Shared
+
+  DevDeployerAssignment{{.LogicalID}}:
+    Type: AWS::SSO::Assignment
+    Properties:
+      InstanceArn: {{$.InstanceARN}}
+      PermissionSetArn: !GetAtt DevDeployerPermissionSet{{.LogicalID}}.PermissionSetArn
+      PrincipalType: USER
+      PrincipalId: {{.UserID}}
+      TargetType: AWS_ACCOUNT
+      TargetId: "{{$.AccountID}}"
+{{- end}}
+`))
+
 var nsDelegationTemplate = template.Must(template.New("ns-delegation.yaml").Parse(
 	`AWSTemplateFormatVersion: '2010-09-09'
 Description: DNS delegation for {{.Qualifier}} to {{.BaseDomainName}}
@@ -365,6 +622,107 @@ Resources:
 {{- end}}
 `))
 
+var managementStackTemplate = template.Must(template.New("management-stack.yaml").Parse(
+	`AWSTemplateFormatVersion: '2010-09-09'
+Description: Management account prerequisites for ago
+
+Parameters:
+  Operators:
+    Type: CommaDelimitedList
+    Description: IAM users allowed to assume the {{.RoleName}} role
+    Default: ""
+
+Conditions:
+  HasOperators: !Not [!Equals [!Join ["", !Ref Operators], ""]]
+
+Resources:
+  ManagementRole:
+    Type: AWS::IAM::Role
+    Properties:
+      RoleName: {{.RoleName}}
+      Description: Role for running ago organization commands
+      MaxSessionDuration: 43200
+      AssumeRolePolicyDocument:
+        Version: "2012-10-17"
+        Statement:
+          - Effect: Allow
+            Principal:
+              AWS: !Sub "arn:${AWS::Partition}:iam::${AWS::AccountId}:root"
+            Action:
+              - sts:AssumeRole
+              - sts:TagSession
+      Policies:
+        - PolicyName: ago-management
+          PolicyDocument:
+            Version: "2012-10-17"
+            Statement:
+              - Sid: ManageProjectAccounts
+                Effect: Allow
+                Action:
+                  - organizations:CreateAccount
+                  - organizations:CloseAccount
+                  - organizations:DescribeAccount
+                  - organizations:DescribeCreateAccountStatus
+                  - organizations:DescribeOrganization
+                  - organizations:ListAccounts
+                  - organizations:ListParents
+                  - organizations:ListRoots
+                  - organizations:MoveAccount
+                  - organizations:TagResource
+                Resource: "*"
+              - Sid: ManageAgoStacks
+                Effect: Allow
+                Action: cloudformation:*
+                Resource:
+                  - !Sub "arn:${AWS::Partition}:cloudformation:*:${AWS::AccountId}:stack/ago-*/*"
+              - Sid: ReadStacks
+                Effect: Allow
+                Action:
+                  - cloudformation:ListStacks
+                  - cloudformation:ValidateTemplate
+                  - cloudformation:CreateChangeSet
+                Resource: "*"
+              - Sid: AccessProjectAccounts
+                Effect: Allow
+                Action: sts:AssumeRole
+                Resource: !Sub "arn:${AWS::Partition}:iam::*:role/OrganizationAccountAccessRole"
+              - Sid: DelegateDNS
+                Effect: Allow
+                Action:
+                  - route53:ListHostedZones
+                  - route53:ListHostedZonesByName
+                  - route53:GetHostedZone
+                  - route53:ListResourceRecordSets
+                  - route53:ChangeResourceRecordSets
+                  - route53:GetChange
+                Resource: "*"
+
+  OperatorsGroup:
+    Type: AWS::IAM::Group
+    Properties:
+      GroupName: {{.RoleName}}-operators
+      Policies:
+        - PolicyName: assume-{{.RoleName}}
+          PolicyDocument:
+            Version: "2012-10-17"
+            Statement:
+              - Effect: Allow
+                Action: sts:AssumeRole
+                Resource: !GetAtt ManagementRole.Arn
+
+  OperatorsMembership:
+    Type: AWS::IAM::UserToGroupAddition
+    Condition: HasOperators
+    Properties:
+      GroupName: !Ref OperatorsGroup
+      Users: !Ref Operators
+
+Outputs:
+  ManagementRoleArn:
+    Description: ARN of the role used to run ago organization commands
+    Value: !GetAtt ManagementRole.Arn
+`))
+
 type accountStackData struct {
 	Qualifier string
 	Email     string
@@ -396,6 +754,40 @@ func renderPreBootstrapTemplate(qualifier string, services []string) (path strin
 	return renderTemplateToTempFile(preBootstrapTemplate, data, "pre-bootstrap-*.yaml")
 }
 
+type deployerSSOData struct {
+	Qualifier             string
+	InstanceARN           string
+	IdentityStoreID       string
+	AccountID             string
+	DeployerPermissionSet string
+	SessionDuration       string
+	Deployers             []ssoDeployer
+	DevDeployers          []ssoDeployer
+}
+
+// ssoDeployer is a deployer as an IAM Identity Center user. PermissionSet and Deployment are only
+// set for dev deployers.
+type ssoDeployer struct {
+	LogicalID     string
+	Username      string
+	UserID        string
+	PermissionSet string
+	Deployment    string
+}
+
+func renderDeployerSSOTemplate(data deployerSSOData) (path string, cleanup func(), err error) {
+	return renderTemplateToTempFile(deployerSSOTemplate, data, "deployer-sso-*.yaml")
+}
+
+type managementStackData struct {
+	RoleName string
+}
+
+func renderManagementStackTemplate(roleName string) (path string, cleanup func(), err error) {
+	return renderTemplateToTempFile(managementStackTemplate, managementStackData{RoleName: roleName},
+		"management-stack-*.yaml")
+}
+
 type nsDelegationData struct {
 	Qualifier      string
 	BaseDomainName string
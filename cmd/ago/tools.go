@@ -0,0 +1,552 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"maps"
+	"os"
+	osexec "os/exec"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/advdv/ago/cmd/ago/internal/cmdexec"
+	"github.com/advdv/ago/cmd/ago/internal/config"
+	"github.com/cockroachdb/errors"
+	"github.com/urfave/cli/v3"
+)
+
+// pinnedVersionRegex matches a version that pins a single release, such as "1.25.1" or "v2.3.0",
+// as opposed to a spec such as "latest" or "22" that mise resolves to one.
+var pinnedVersionRegex = regexp.MustCompile(`^v?\d+\.\d+\.\d+`)
+
+func toolsCmd() *cli.Command {
+	return &cli.Command{
+		Name:  "tools",
+		Usage: "Manage the mise toolchain of the project",
+		Commands: []*cli.Command{
+			{
+				Name:   "list",
+				Usage:  "List the tool versions declared in mise.toml next to the installed ones",
+				Action: config.RunWithConfig(runToolsList),
+			},
+			{
+				Name:      "check",
+				Usage:     "Check that the tools which ago commands run are declared and installed",
+				ArgsUsage: "[command...]",
+				Description: "Checks the tools of the given commands, or of every command that runs tools.\n" +
+					"Commands: " + strings.Join(slices.Sorted(maps.Keys(commandTools())), ", "),
+				Action: config.RunWithConfig(runToolsCheck),
+			},
+			{
+				Name:   "install",
+				Usage:  "Install the tools declared in mise.toml that are missing",
+				Action: config.RunWithConfig(runToolsInstall),
+			},
+			{
+				Name:  "update",
+				Usage: "Bump the tool versions pinned in mise.toml to the latest stable releases",
+				Flags: []cli.Flag{
+					&cli.StringSliceFlag{
+						Name:  "tool",
+						Usage: "Only update these tools, by their name in mise.toml (e.g. go,npm:aws-cdk)",
+					},
+					&cli.BoolFlag{
+						Name:  "keep-major",
+						Usage: "Stay on the major version that each tool is pinned to",
+					},
+				},
+				Action: config.RunWithConfig(runToolsUpdate),
+			},
+		},
+	}
+}
+
+// toolRequirement is an executable that ago commands run.
+type toolRequirement struct {
+	// Binary is the name of the executable.
+	Binary string
+	// Mise is the name of the tool in mise.toml, or empty for tools that mise does not manage.
+	Mise string
+	// Install tells how to install a tool that mise does not manage.
+	Install string
+}
+
+// commandTools returns the tools that ago commands run, keyed by command name. AWS is called
+// through the SDK, so no AWS CLI is needed. The tools of the image builders are checked when
+// build-and-push selects one.
+func commandTools() map[string][]toolRequirement {
+	cdk := toolRequirement{Binary: "cdk", Mise: "npm:aws-cdk"}
+	node := toolRequirement{Binary: "node", Mise: "node"}
+	docker := toolRequirement{Binary: "docker", Install: "install Docker Desktop or Docker Engine"}
+
+	return map[string][]toolRequirement{
+		"bootstrap":     {node, cdk},
+		"deploy":        {node, cdk},
+		"deploy-shared": {node, cdk},
+		"diff":          {node, cdk},
+		"destroy":       {node, cdk},
+		"shell":         {docker},
+		"repo-setup":    {{Binary: "gh", Install: "install the GitHub CLI and run 'gh auth login'"}},
+	}
+}
+
+// toolProblem is a tool that a command needs but cannot run.
+type toolProblem struct {
+	Tool    string `json:"tool"`
+	Problem string `json:"problem"`
+	Fix     string `json:"fix"`
+}
+
+// findToolProblems returns the tools of reqs that cannot run, in order. Tools that mise manages
+// must be declared in mise.toml and installed; other tools must be on the PATH.
+func findToolProblems(
+	ctx context.Context, exec cmdexec.Executor, declared []MiseTool, reqs []toolRequirement,
+) []toolProblem {
+	var problems []toolProblem
+	for _, req := range reqs {
+		switch {
+		case req.Mise == "":
+			if _, err := osexec.LookPath(req.Binary); err != nil {
+				problems = append(problems, toolProblem{Tool: req.Binary, Problem: "not found on the PATH", Fix: req.Install})
+			}
+		case !slices.ContainsFunc(declared, func(t MiseTool) bool { return t.Name == req.Mise }):
+			problems = append(problems, toolProblem{
+				Tool:    req.Mise,
+				Problem: "not declared in mise.toml",
+				Fix:     "run 'mise use " + req.Mise + "'",
+			})
+		default:
+			if _, err := exec.Output(ctx, "mise", "which", req.Binary); err != nil {
+				problems = append(problems, toolProblem{
+					Tool:    req.Mise,
+					Problem: "declared in mise.toml but not installed",
+					Fix:     "run 'ago tools install'",
+				})
+			}
+		}
+	}
+	return problems
+}
+
+// requireTools returns a single error that lists every tool the command needs but cannot run, so
+// that a missing tool is reported before the command starts instead of failing it halfway.
+func requireTools(ctx context.Context, cfg config.Config, command string) error {
+	declared, err := readMiseTools(cfg.ProjectDir)
+	if err != nil {
+		return err
+	}
+
+	problems := findToolProblems(ctx, cmdexec.New(cfg), declared, commandTools()[command])
+	if len(problems) == 0 {
+		return nil
+	}
+	return toolProblemsError(command, problems)
+}
+
+// toolProblemsError returns an error that lists the problems of the tools that a command needs.
+func toolProblemsError(command string, problems []toolProblem) error {
+	var b strings.Builder
+	for _, p := range problems {
+		b.WriteString("\n  " + p.Tool + ": " + p.Problem + ", " + p.Fix)
+	}
+	return errors.Newf("'ago %s' needs tools that cannot run:%s", command, b.String())
+}
+
+type toolsCheckOptions struct {
+	Commands []string
+	Output   io.Writer
+	Result   io.Writer
+}
+
+// toolsCheckResult is the result of tools check in the JSON output format.
+type toolsCheckResult struct {
+	Problems map[string][]toolProblem `json:"problems"`
+}
+
+func runToolsCheck(ctx context.Context, cmd *cli.Command, cfg config.Config) error {
+	output, result := commandOutput(cmd)
+	return doToolsCheck(ctx, cfg, toolsCheckOptions{
+		Commands: cmd.Args().Slice(),
+		Output:   output,
+		Result:   result,
+	})
+}
+
+func doToolsCheck(ctx context.Context, cfg config.Config, opts toolsCheckOptions) error {
+	tools := commandTools()
+	commands := opts.Commands
+	if len(commands) == 0 {
+		commands = slices.Sorted(maps.Keys(tools))
+	}
+	for _, command := range commands {
+		if _, ok := tools[command]; !ok {
+			return errors.Errorf("unknown command %q, expected one of: %s",
+				command, strings.Join(slices.Sorted(maps.Keys(tools)), ", "))
+		}
+	}
+
+	declared, err := readMiseTools(cfg.ProjectDir)
+	if err != nil {
+		return err
+	}
+
+	exec := cmdexec.New(cfg)
+	res := toolsCheckResult{Problems: map[string][]toolProblem{}}
+	for _, command := range commands {
+		problems := findToolProblems(ctx, exec, declared, tools[command])
+		if len(problems) == 0 {
+			writeOutputf(opts.Output, "%s: ok\n", command)
+			continue
+		}
+		res.Problems[command] = problems
+		writeOutputf(opts.Output, "%s:\n", command)
+		for _, p := range problems {
+			writeOutputf(opts.Output, "  %s: %s, %s\n", p.Tool, p.Problem, p.Fix)
+		}
+	}
+
+	if err := writeResult(opts.Result, res); err != nil {
+		return err
+	}
+	if len(res.Problems) > 0 {
+		return errors.Newf("%d of %d commands are missing tools", len(res.Problems), len(commands))
+	}
+	return nil
+}
+
+type toolsInstallOptions struct {
+	Output io.Writer
+}
+
+func runToolsInstall(ctx context.Context, cmd *cli.Command, cfg config.Config) error {
+	output, _ := commandOutput(cmd)
+	return doToolsInstall(ctx, cfg, toolsInstallOptions{Output: output})
+}
+
+// doToolsInstall installs the missing tools of mise.toml and then checks every command, so that
+// tools mise cannot install, such as Docker, are still reported.
+func doToolsInstall(ctx context.Context, cfg config.Config, opts toolsInstallOptions) error {
+	writeOutputf(opts.Output, "Installing tools from mise.toml...\n")
+	if err := cmdexec.New(cfg).WithOutput(opts.Output, opts.Output).Run(ctx, "mise", "install"); err != nil {
+		return errors.Wrap(err, "mise install failed")
+	}
+
+	writeOutputf(opts.Output, "\nChecking the tools of ago commands...\n")
+	return doToolsCheck(ctx, cfg, toolsCheckOptions{Output: opts.Output, Result: io.Discard})
+}
+
+// Installation states of a tool in tools list.
+const (
+	toolStatusInstalled = "installed"
+	// toolStatusDrift means other versions than the declared one are installed.
+	toolStatusDrift   = "drift"
+	toolStatusMissing = "missing"
+)
+
+// toolListing is a tool of mise.toml with its installed versions.
+type toolListing struct {
+	Tool      string   `json:"tool"`
+	Declared  string   `json:"declared"`
+	Active    string   `json:"active"`
+	Installed []string `json:"installed"`
+	Status    string   `json:"status"`
+}
+
+// toolsListResult is the result of tools list in the JSON output format.
+type toolsListResult struct {
+	Tools []toolListing `json:"tools"`
+}
+
+// miseInstalledVersion is a version of a tool in the output of 'mise ls --json'.
+type miseInstalledVersion struct {
+	Version   string `json:"version"`
+	Installed bool   `json:"installed"`
+	Active    bool   `json:"active"`
+}
+
+type toolsListOptions struct {
+	Output io.Writer
+	Result io.Writer
+}
+
+func runToolsList(ctx context.Context, cmd *cli.Command, cfg config.Config) error {
+	output, result := commandOutput(cmd)
+	return doToolsList(ctx, cfg, toolsListOptions{Output: output, Result: result})
+}
+
+func doToolsList(ctx context.Context, cfg config.Config, opts toolsListOptions) error {
+	declared, err := readMiseTools(cfg.ProjectDir)
+	if err != nil {
+		return err
+	}
+
+	output, err := cmdexec.New(cfg).Output(ctx, "mise", "ls", "--json")
+	if err != nil {
+		return errors.Wrap(err, "failed to list mise tools")
+	}
+	var installed map[string][]miseInstalledVersion
+	if err := json.Unmarshal([]byte(output), &installed); err != nil {
+		return errors.Wrap(err, "failed to parse mise ls output")
+	}
+
+	res := toolsListResult{Tools: listTools(declared, installed)}
+	width := len("TOOL")
+	for _, t := range res.Tools {
+		width = max(width, len(t.Tool))
+	}
+	writeOutputf(opts.Output, "%-*s  %-12s  %-10s  %s\n", width, "TOOL", "DECLARED", "STATUS", "INSTALLED")
+	for _, t := range res.Tools {
+		writeOutputf(opts.Output, "%-*s  %-12s  %-10s  %s\n", width, t.Tool, t.Declared, t.Status,
+			strings.Join(t.Installed, ", "))
+	}
+
+	return writeResult(opts.Result, res)
+}
+
+// listTools matches the tools declared in mise.toml with the versions that 'mise ls --json'
+// reports. The active version is the one that the declaration resolves to.
+func listTools(declared []MiseTool, installed map[string][]miseInstalledVersion) []toolListing {
+	listings := make([]toolListing, 0, len(declared))
+	for _, tool := range declared {
+		listing := toolListing{Tool: tool.Name, Declared: tool.Version, Installed: []string{}, Status: toolStatusMissing}
+		activeInstalled := false
+		for _, v := range installed[tool.Name] {
+			if v.Active {
+				listing.Active = v.Version
+				activeInstalled = v.Installed
+			}
+			if v.Installed {
+				listing.Installed = append(listing.Installed, v.Version)
+			}
+		}
+		switch {
+		case activeInstalled:
+			listing.Status = toolStatusInstalled
+		case len(listing.Installed) > 0:
+			listing.Status = toolStatusDrift
+		}
+		listings = append(listings, listing)
+	}
+	return listings
+}
+
+type toolsUpdateOptions struct {
+	Tools     []string
+	KeepMajor bool
+	Output    io.Writer
+	Result    io.Writer
+}
+
+// toolChange is a tool whose pinned version tools update changed.
+type toolChange struct {
+	Tool string `json:"tool"`
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// toolsUpdateResult is the result of tools update in the JSON output format.
+type toolsUpdateResult struct {
+	Changes   []toolChange `json:"changes"`
+	Unchanged []string     `json:"unchanged"`
+	Skipped   []string     `json:"skipped"`
+}
+
+func runToolsUpdate(ctx context.Context, cmd *cli.Command, cfg config.Config) error {
+	output, result := commandOutput(cmd)
+	return doToolsUpdate(ctx, cfg, toolsUpdateOptions{
+		Tools:     cmd.StringSlice("tool"),
+		KeepMajor: cmd.Bool("keep-major"),
+		Output:    output,
+		Result:    result,
+	})
+}
+
+func doToolsUpdate(ctx context.Context, cfg config.Config, opts toolsUpdateOptions) error {
+	path := filepath.Join(cfg.ProjectDir, "mise.toml")
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return errors.Wrap(err, "failed to read mise.toml")
+	}
+
+	tools := parseMiseTools(string(data))
+	for _, name := range opts.Tools {
+		if !slices.ContainsFunc(tools, func(t MiseTool) bool { return t.Name == name }) {
+			return errors.Errorf("tool %q is not in mise.toml", name)
+		}
+	}
+
+	exec := cmdexec.New(cfg)
+	res := toolsUpdateResult{Changes: []toolChange{}, Unchanged: []string{}, Skipped: []string{}}
+	versions := map[string]string{}
+	for _, tool := range tools {
+		if len(opts.Tools) > 0 && !slices.Contains(opts.Tools, tool.Name) {
+			continue
+		}
+		// Arrays of versions and tables with options are left to the user.
+		if strings.HasPrefix(tool.Version, "[") || strings.HasPrefix(tool.Version, "{") {
+			res.Skipped = append(res.Skipped, tool.Name)
+			continue
+		}
+
+		writeOutputf(opts.Output, "Checking %s...\n", tool.Name)
+		latest, err := latestMiseVersion(ctx, exec, miseVersionQuery(tool, opts.KeepMajor))
+		if err != nil {
+			return err
+		}
+		if latest == tool.Version {
+			res.Unchanged = append(res.Unchanged, tool.Name)
+			continue
+		}
+		versions[tool.Name] = latest
+		res.Changes = append(res.Changes, toolChange{Tool: tool.Name, From: tool.Version, To: latest})
+	}
+
+	if len(res.Changes) == 0 {
+		writeOutputf(opts.Output, "All %d tools are up to date.\n", len(res.Unchanged))
+		return writeResult(opts.Result, res)
+	}
+
+	//nolint:gosec // config file needs to be readable
+	if err := cmdexec.WriteFile(path, []byte(setMiseToolVersions(string(data), versions)), 0o644); err != nil {
+		return errors.Wrap(err, "failed to write mise.toml")
+	}
+
+	writeOutputf(opts.Output, "\nUpdated mise.toml:\n")
+	width := 0
+	for _, c := range res.Changes {
+		width = max(width, len(c.Tool))
+	}
+	for _, c := range res.Changes {
+		writeOutputf(opts.Output, "  %-*s  %s -> %s\n", width, c.Tool, c.From, c.To)
+	}
+	if len(res.Unchanged) > 0 {
+		writeOutputf(opts.Output, "Up to date: %s\n", strings.Join(res.Unchanged, ", "))
+	}
+	if len(res.Skipped) > 0 {
+		writeOutputf(opts.Output, "Skipped, not a single version: %s\n", strings.Join(res.Skipped, ", "))
+	}
+	writeOutputf(opts.Output, "Run 'mise install' to install the new versions.\n")
+
+	return writeResult(opts.Result, res)
+}
+
+// resolveMiseVersions pins every tool of the config that has a spec, such as "latest" or "22", to
+// the latest stable release that matches it.
+func resolveMiseVersions(ctx context.Context, exec cmdexec.Executor, cfg MiseConfig) (MiseConfig, error) {
+	cfg.ExtraTools = slices.Clone(cfg.ExtraTools)
+	for _, ref := range cfg.toolRefs() {
+		if pinnedVersionRegex.MatchString(*ref.version) {
+			continue
+		}
+		query := miseVersionQuery(MiseTool{Name: ref.name, Version: *ref.version}, false)
+		version, err := latestMiseVersion(ctx, exec, query)
+		if err != nil {
+			return MiseConfig{}, err
+		}
+		*ref.version = version
+	}
+	return cfg, nil
+}
+
+// miseVersionQuery returns the argument of 'mise latest' that finds the version to pin a tool to.
+// A spec is resolved within itself. A pinned version moves to the latest release, or to the latest
+// release of its major version with keepMajor.
+func miseVersionQuery(tool MiseTool, keepMajor bool) string {
+	switch {
+	case tool.Version == "" || tool.Version == "latest":
+		return tool.Name
+	case !pinnedVersionRegex.MatchString(tool.Version):
+		return tool.Name + "@" + tool.Version
+	case keepMajor:
+		major, _, _ := strings.Cut(tool.Version, ".")
+		return tool.Name + "@" + major
+	default:
+		return tool.Name
+	}
+}
+
+// latestMiseVersion returns the latest stable version that mise knows for a query such as "go" or
+// "node@22".
+func latestMiseVersion(ctx context.Context, exec cmdexec.Executor, query string) (string, error) {
+	version, err := exec.Output(ctx, "mise", "latest", query)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to resolve the latest version of %s", query)
+	}
+	if version == "" {
+		return "", errors.Errorf("mise found no version of %s", query)
+	}
+	return version, nil
+}
+
+// readMiseTools returns the tools in the [tools] section of the project's mise.toml.
+func readMiseTools(dir string) ([]MiseTool, error) {
+	data, err := os.ReadFile(filepath.Join(dir, "mise.toml"))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read mise.toml")
+	}
+	return parseMiseTools(string(data)), nil
+}
+
+// parseMiseTools returns the tools in the [tools] section of a mise.toml, in file order. Versions
+// that are not a plain string, such as an array of versions, are returned as written.
+func parseMiseTools(data string) []MiseTool {
+	var tools []MiseTool
+	forEachMiseToolLine(data, func(line string) string {
+		name, value, _ := strings.Cut(line, "=")
+		version, _ := splitMiseVersion(value)
+		tools = append(tools, MiseTool{Name: strings.Trim(strings.TrimSpace(name), `"`), Version: version})
+		return line
+	})
+	return tools
+}
+
+// setMiseToolVersions replaces the versions of the named tools in the [tools] section of a
+// mise.toml, keeping the rest of the file, including comments after a version, as it is.
+func setMiseToolVersions(data string, versions map[string]string) string {
+	return forEachMiseToolLine(data, func(line string) string {
+		key, value, _ := strings.Cut(line, "=")
+		version, ok := versions[strings.Trim(strings.TrimSpace(key), `"`)]
+		if !ok {
+			return line
+		}
+
+		_, rest := splitMiseVersion(value)
+		return strings.TrimRight(key, " ") + ` = "` + version + `"` + rest
+	})
+}
+
+// splitMiseVersion splits the value of a tool line into the version and what follows a quoted
+// version, such as a comment. A value that is not a quoted string is returned whole as the version.
+func splitMiseVersion(value string) (version, rest string) {
+	value = strings.TrimSpace(value)
+	if strings.HasPrefix(value, `"`) {
+		if end := strings.IndexByte(value[1:], '"'); end >= 0 {
+			return value[1 : end+1], value[end+2:]
+		}
+	}
+	return value, ""
+}
+
+// forEachMiseToolLine calls fn with every "name = version" line in the [tools] section of a
+// mise.toml and returns the file with each of those lines replaced by what fn returned.
+func forEachMiseToolLine(data string, fn func(line string) string) string {
+	lines := strings.Split(data, "\n")
+	inTools := false
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case trimmed == "" || strings.HasPrefix(trimmed, "#"):
+			continue
+		case strings.HasPrefix(trimmed, "["):
+			inTools = trimmed == "[tools]"
+			continue
+		case !inTools || !strings.Contains(trimmed, "="):
+			continue
+		}
+		lines[i] = fn(line)
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/advdv/ago/cmd/ago/internal/cmdexec"
+)
+
+func TestReadMiseTools(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	content := "[env]\nFOO = \"bar\"\n\n[tools]\n# pinned\ngo = \"1.25.1\"\n\"npm:aws-cdk\" = \"2.200.0\"\n" +
+		"node = [\"22\", \"20\"]\n\n[settings]\nexperimental = true\n"
+	if err := os.WriteFile(filepath.Join(dir, "mise.toml"), []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tools, err := readMiseTools(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []MiseTool{
+		{Name: "go", Version: "1.25.1"},
+		{Name: "npm:aws-cdk", Version: "2.200.0"},
+		{Name: "node", Version: `["22", "20"]`},
+	}
+	if !slices.Equal(tools, want) {
+		t.Errorf("expected %+v, got %+v", want, tools)
+	}
+}
+
+func TestSetMiseToolVersions(t *testing.T) {
+	t.Parallel()
+
+	data := "[tools]\ngo = \"1.24.0\" # keep in sync with go.mod\n\"npm:aws-cdk\" = \"2.100.0\"\n" +
+		"node = \"22.1.0\"\n\n[settings]\ngo = \"not a tool\"\n"
+
+	got := setMiseToolVersions(data, map[string]string{"go": "1.25.1", "npm:aws-cdk": "2.200.0"})
+	want := "[tools]\ngo = \"1.25.1\" # keep in sync with go.mod\n\"npm:aws-cdk\" = \"2.200.0\"\n" +
+		"node = \"22.1.0\"\n\n[settings]\ngo = \"not a tool\"\n"
+	if got != want {
+		t.Errorf("expected:\n%s\ngot:\n%s", want, got)
+	}
+}
+
+func TestMiseVersionQuery(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		version   string
+		keepMajor bool
+		want      string
+	}{
+		{"latest", false, "node"},
+		{"22", false, "node@22"},
+		{"22.1.0", false, "node"},
+		{"22.1.0", true, "node@22"},
+		{"", true, "node"},
+	} {
+		if got := miseVersionQuery(MiseTool{Name: "node", Version: tc.version}, tc.keepMajor); got != tc.want {
+			t.Errorf("miseVersionQuery(%q, %v) = %q, want %q", tc.version, tc.keepMajor, got, tc.want)
+		}
+	}
+}
+
+func TestListTools(t *testing.T) {
+	t.Parallel()
+
+	declared := []MiseTool{
+		{Name: "go", Version: "1.25.1"},
+		{Name: "node", Version: "22"},
+		{Name: "depot", Version: "2.80.0"},
+	}
+	listings := listTools(declared, map[string][]miseInstalledVersion{
+		"go":   {{Version: "1.24.0", Installed: true}, {Version: "1.25.1", Installed: true, Active: true}},
+		"node": {{Version: "20.1.0", Installed: true}, {Version: "22.3.0", Active: true}},
+	})
+
+	want := []toolListing{
+		{
+			Tool: "go", Declared: "1.25.1", Active: "1.25.1",
+			Installed: []string{"1.24.0", "1.25.1"}, Status: toolStatusInstalled,
+		},
+		{Tool: "node", Declared: "22", Active: "22.3.0", Installed: []string{"20.1.0"}, Status: toolStatusDrift},
+		{Tool: "depot", Declared: "2.80.0", Installed: []string{}, Status: toolStatusMissing},
+	}
+	if len(listings) != len(want) {
+		t.Fatalf("expected %d listings, got %+v", len(want), listings)
+	}
+	for i, l := range listings {
+		w := want[i]
+		if l.Tool != w.Tool || l.Declared != w.Declared || l.Active != w.Active || l.Status != w.Status ||
+			!slices.Equal(l.Installed, w.Installed) {
+			t.Errorf("expected %+v, got %+v", w, l)
+		}
+	}
+}
+
+func TestFindToolProblems(t *testing.T) {
+	t.Parallel()
+
+	problems := findToolProblems(context.Background(), cmdexec.NewWithDir(t.TempDir()), nil, []toolRequirement{
+		{Binary: "cdk", Mise: "npm:aws-cdk"},
+		{Binary: "ago-no-such-tool", Install: "install it"},
+	})
+
+	want := []toolProblem{
+		{Tool: "npm:aws-cdk", Problem: "not declared in mise.toml", Fix: "run 'mise use npm:aws-cdk'"},
+		{Tool: "ago-no-such-tool", Problem: "not found on the PATH", Fix: "install it"},
+	}
+	if !slices.Equal(problems, want) {
+		t.Errorf("expected %+v, got %+v", want, problems)
+	}
+}
+
+func TestCommandTools(t *testing.T) {
+	t.Parallel()
+
+	for command, reqs := range commandTools() {
+		for _, req := range reqs {
+			if req.Mise == "" && req.Install == "" {
+				t.Errorf("%s: tool %s is neither managed by mise nor has install instructions", command, req.Binary)
+			}
+		}
+	}
+}
@@ -0,0 +1,286 @@
+package main
+
+import (
+	"context"
+	"io"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/advdv/ago/agcdk/agcdkapi"
+	"github.com/advdv/ago/cmd/ago/internal/awsapi"
+	"github.com/advdv/ago/cmd/ago/internal/config"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/apigatewaymanagementapi"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	dynamodbtypes "github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/cockroachdb/errors"
+	"github.com/urfave/cli/v3"
+)
+
+func wsCmd() *cli.Command {
+	return &cli.Command{
+		Name:  "ws",
+		Usage: "Send messages to the connections of agcdkapi WebSocket APIs, for debugging",
+		Commands: []*cli.Command{
+			wsSendCmd(),
+			wsBroadcastCmd(),
+		},
+	}
+}
+
+// wsFlags returns the flags that select a WebSocket API and the message, shared by the ws
+// commands.
+func wsFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringFlag{
+			Name:  "deployment",
+			Usage: "Deployment that owns the API (default: the caller's Dev deployment)",
+		},
+		&cli.StringFlag{
+			Name:     "api",
+			Usage:    "Name of the WebSocket API, as given to agcdkapi.NewWebSocket",
+			Required: true,
+		},
+		&cli.StringFlag{
+			Name:  "message",
+			Usage: "Message to send",
+		},
+		&cli.StringFlag{
+			Name:  "payload",
+			Usage: "Path to a file with the message to send, instead of --message",
+		},
+	}
+}
+
+func wsSendCmd() *cli.Command {
+	return &cli.Command{
+		Name:  "send",
+		Usage: "Send a message to one connection",
+		Flags: append(wsFlags(), &cli.StringFlag{
+			Name:     "connection",
+			Usage:    "ID of the connection to send to",
+			Required: true,
+		}),
+		Action: config.RunWithConfig(runWsSend),
+	}
+}
+
+func wsBroadcastCmd() *cli.Command {
+	return &cli.Command{
+		Name:   "broadcast",
+		Usage:  "Send a message to every connection in the API's connections table",
+		Flags:  wsFlags(),
+		Action: config.RunWithConfig(runWsBroadcast),
+	}
+}
+
+type wsOptions struct {
+	Deployment string
+	API        string
+	Connection string
+	Message    string
+	Payload    string
+	Profile    string
+	Region     string
+	Output     io.Writer
+	Result     io.Writer
+}
+
+// wsResult is the result of the ws commands in the JSON output format.
+type wsResult struct {
+	API  string   `json:"api"`
+	Sent []string `json:"sent"`
+	Gone []string `json:"gone"`
+}
+
+func runWsSend(ctx context.Context, cmd *cli.Command, cfg config.Config) error {
+	output, result := commandOutput(cmd)
+	return doWsSend(ctx, cfg, wsOptionsFrom(cmd, output, result))
+}
+
+func runWsBroadcast(ctx context.Context, cmd *cli.Command, cfg config.Config) error {
+	output, result := commandOutput(cmd)
+	return doWsBroadcast(ctx, cfg, wsOptionsFrom(cmd, output, result))
+}
+
+func wsOptionsFrom(cmd *cli.Command, output, result io.Writer) wsOptions {
+	return wsOptions{
+		Deployment: cmd.String("deployment"),
+		API:        cmd.String("api"),
+		Connection: cmd.String("connection"),
+		Message:    cmd.String("message"),
+		Payload:    cmd.String("payload"),
+		Profile:    cmd.String("profile"),
+		Region:     cmd.String("region"),
+		Output:     output,
+		Result:     result,
+	}
+}
+
+func doWsSend(ctx context.Context, cfg config.Config, opts wsOptions) error {
+	message, err := wsMessage(opts)
+	if err != nil {
+		return err
+	}
+	target, err := resolveWebSocket(ctx, cfg, opts)
+	if err != nil {
+		return err
+	}
+
+	res := wsResult{API: opts.API, Sent: []string{}, Gone: []string{}}
+	gone, err := target.post(ctx, opts.Connection, message)
+	switch {
+	case err != nil:
+		return err
+	case gone:
+		return errors.Errorf("connection %s is gone", opts.Connection)
+	}
+
+	writeOutputf(opts.Output, "Sent %d bytes to connection %s\n", len(message), opts.Connection)
+	res.Sent = append(res.Sent, opts.Connection)
+	return writeResult(opts.Result, res)
+}
+
+func doWsBroadcast(ctx context.Context, cfg config.Config, opts wsOptions) error {
+	message, err := wsMessage(opts)
+	if err != nil {
+		return err
+	}
+	target, err := resolveWebSocket(ctx, cfg, opts)
+	if err != nil {
+		return err
+	}
+
+	connections, err := target.connections(ctx)
+	if err != nil {
+		return err
+	}
+
+	res := wsResult{API: opts.API, Sent: []string{}, Gone: []string{}}
+	for _, id := range connections {
+		gone, err := target.post(ctx, id, message)
+		if err != nil {
+			return err
+		}
+		if gone {
+			res.Gone = append(res.Gone, id)
+			continue
+		}
+		res.Sent = append(res.Sent, id)
+	}
+
+	writeOutputf(opts.Output, "Sent %d bytes to %d of %d connections of %s\n",
+		len(message), len(res.Sent), len(connections), opts.API)
+	if len(res.Gone) > 0 {
+		writeOutputf(opts.Output, "Gone, the $disconnect handler did not remove them: %s\n",
+			strings.Join(res.Gone, ", "))
+	}
+	return writeResult(opts.Result, res)
+}
+
+// wsMessage returns the message to send, from --message or the file of --payload.
+func wsMessage(opts wsOptions) ([]byte, error) {
+	switch {
+	case opts.Message != "" && opts.Payload != "":
+		return nil, errors.New("--message and --payload are mutually exclusive")
+	case opts.Message != "":
+		return []byte(opts.Message), nil
+	case opts.Payload != "":
+		message, err := os.ReadFile(opts.Payload)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to read payload %s", opts.Payload)
+		}
+		return message, nil
+	default:
+		return nil, errors.New("expected the message to send in --message or --payload")
+	}
+}
+
+// webSocketTarget is a WebSocket API created by agcdkapi.
+type webSocketTarget struct {
+	CallbackURL      string
+	ConnectionsTable string
+	AWS              *awsapi.Clients
+	Management       awsapi.APIGatewayManagement
+}
+
+// resolveWebSocket finds the callback URL and connections table of a WebSocket API in the output
+// registries of the deployment stack and the shared stack, in that order.
+func resolveWebSocket(ctx context.Context, cfg config.Config, opts wsOptions) (webSocketTarget, error) {
+	stacks, err := resolveDeploymentStacks(ctx, cfg, opts.Deployment, opts.Profile, opts.Region)
+	if err != nil {
+		return webSocketTarget{}, err
+	}
+	stackNames := []string{stacks.Stack, stacks.SharedStack}
+
+	for _, stackName := range stackNames {
+		outputs, err := stacks.outputs(ctx, stackName)
+		if err != nil {
+			return webSocketTarget{}, err
+		}
+		callbackURL := outputs[agcdkapi.CallbackURLOutputKey(opts.API)]
+		table := outputs[agcdkapi.ConnectionsTableOutputKey(opts.API)]
+		if callbackURL != "" && table != "" {
+			management, err := awsapi.NewAPIGatewayManagement(ctx, stacks.Profile, stacks.Region, callbackURL)
+			if err != nil {
+				return webSocketTarget{}, err
+			}
+			return webSocketTarget{
+				CallbackURL:      callbackURL,
+				ConnectionsTable: table,
+				AWS:              stacks.aws,
+				Management:       management,
+			}, nil
+		}
+	}
+
+	return webSocketTarget{}, errors.Errorf("WebSocket API %q not found in the outputs of %s",
+		opts.API, strings.Join(stackNames, " or "))
+}
+
+// post sends message to a connection through the API Gateway management API. It reports whether
+// the connection is gone instead of failing, so that a broadcast continues past stale entries.
+func (t webSocketTarget) post(ctx context.Context, connectionID string, message []byte) (bool, error) {
+	_, err := t.Management.PostToConnection(ctx, &apigatewaymanagementapi.PostToConnectionInput{
+		ConnectionId: aws.String(connectionID),
+		Data:         message,
+	})
+	switch {
+	case err == nil:
+		return false, nil
+	case awsapi.IsErrorCode(err, "GoneException"):
+		return true, nil
+	default:
+		return false, errors.Wrapf(err, "failed to post to connection %s", connectionID)
+	}
+}
+
+// connections returns the IDs of the connections in the connections table.
+func (t webSocketTarget) connections(ctx context.Context) ([]string, error) {
+	var items []map[string]dynamodbtypes.AttributeValue
+	pages := dynamodb.NewScanPaginator(t.AWS.DynamoDB, &dynamodb.ScanInput{
+		TableName:            aws.String(t.ConnectionsTable),
+		ProjectionExpression: aws.String(agcdkapi.ConnectionIDAttribute),
+	})
+	for pages.HasMorePages() {
+		page, err := pages.NextPage(ctx)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to scan connections table %s", t.ConnectionsTable)
+		}
+		items = append(items, page.Items...)
+	}
+	return connectionIDs(items), nil
+}
+
+// connectionIDs returns the sorted connection IDs of the items of the connections table.
+func connectionIDs(items []map[string]dynamodbtypes.AttributeValue) []string {
+	ids := make([]string, 0, len(items))
+	for _, item := range items {
+		if id, ok := item[agcdkapi.ConnectionIDAttribute].(*dynamodbtypes.AttributeValueMemberS); ok && id.Value != "" {
+			ids = append(ids, id.Value)
+		}
+	}
+	slices.Sort(ids)
+	return ids
+}
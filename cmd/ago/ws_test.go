@@ -0,0 +1,66 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	dynamodbtypes "github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+func TestConnectionIDs(t *testing.T) {
+	t.Parallel()
+
+	items := []map[string]dynamodbtypes.AttributeValue{
+		{"connectionId": &dynamodbtypes.AttributeValueMemberS{Value: "b="}},
+		{"connectionId": &dynamodbtypes.AttributeValueMemberS{Value: "a="}},
+		{"other": &dynamodbtypes.AttributeValueMemberS{Value: "x"}},
+	}
+	if got, want := connectionIDs(items), []string{"a=", "b="}; !slices.Equal(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+}
+
+func TestWsMessage(t *testing.T) {
+	t.Parallel()
+
+	payload := filepath.Join(t.TempDir(), "message.json")
+	if err := os.WriteFile(payload, []byte(`{"from":"file"}`), 0o600); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		opts    wsOptions
+		want    string
+		wantErr string
+	}{
+		{name: "message", opts: wsOptions{Message: "hello"}, want: "hello"},
+		{name: "payload", opts: wsOptions{Payload: payload}, want: `{"from":"file"}`},
+		{name: "both", opts: wsOptions{Message: "hello", Payload: payload}, wantErr: "mutually exclusive"},
+		{name: "none", opts: wsOptions{}, wantErr: "expected the message"},
+		{name: "missing payload", opts: wsOptions{Payload: payload + ".missing"}, wantErr: "failed to read"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			message, err := wsMessage(tt.opts)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("expected error containing %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if string(message) != tt.want {
+				t.Errorf("expected %q, got %q", tt.want, message)
+			}
+		})
+	}
+}
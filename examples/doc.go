@@ -0,0 +1,12 @@
+// Package examples holds example CDK apps that use the public API of agcdkutil and the agcdk
+// constructs. Each app is a main package with a cdk.json, so 'cdk synth' runs in its directory,
+// and tests that synthesize it with the context of that cdk.json:
+//
+//   - singleregion: one region, values shared from the shared stack with Share and Use.
+//   - multiregion: a primary and a secondary region, values copied with Replicate.
+//   - multideployment: restricted deployments, a developer deployment and deployments.yaml.
+//   - fullstack: the shared base, a web API and a queue in two regions, before and after the
+//     delegation of the hosted zone.
+//
+// Run them with 'go test ./examples/...'.
+package examples
@@ -0,0 +1,6 @@
+// Command coreapi stands in for the function behind the web API of the fullstack example. A real
+// function serves the requests that API Gateway sends it through a Lambda runtime, such as the one
+// of github.com/aws/aws-lambda-go.
+package main
+
+func main() {}
@@ -0,0 +1,62 @@
+// Command fullstack is a CDK app in two regions that builds on the agcdk constructs: a shared base
+// with the hosted zone, the container repositories and, once the zone is delegated, a wildcard
+// certificate, and per deployment a web API with a queue that the API sends to.
+//
+// Before 'ago infra org dns-delegate' has delegated the zone, the fullstack-dns-delegated context
+// is unset, the shared base has no certificates, and each API is only served at its execute-api
+// endpoint. Afterwards every region serves the API of a deployment at its subdomain, such as
+// api-dev.fullstack.example.com, behind latency-based records.
+package main
+
+import (
+	"github.com/advdv/ago/agcdk/agcdkqueue"
+	"github.com/advdv/ago/agcdk/agcdksharedbase"
+	"github.com/advdv/ago/agcdk/agcdkwebapi"
+	"github.com/advdv/ago/agcdkutil"
+	"github.com/aws/aws-cdk-go/awscdk/v2"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awscertificatemanager"
+	"github.com/aws/jsii-runtime-go"
+)
+
+// Shared holds the resources of a region that every deployment uses.
+type Shared struct {
+	Base agcdksharedbase.SharedBase
+}
+
+func newShared(stack awscdk.Stack) *Shared {
+	return &Shared{Base: agcdksharedbase.New(stack, agcdksharedbase.Props{})}
+}
+
+func newDeployment(stack awscdk.Stack, shared *Shared, deploymentIdent string) {
+	var cert awscertificatemanager.ICertificate
+	if shared.Base.IsValidated() {
+		cert = shared.Base.Certificates().WildcardCertificate()
+	}
+
+	api := agcdkwebapi.New(stack, agcdkwebapi.Props{
+		Name:        "api",
+		Entry:       "coreapi",
+		Deployment:  deploymentIdent,
+		HostedZone:  shared.Base.DNS().HostedZone(),
+		Certificate: cert,
+	})
+
+	orders := agcdkqueue.New(stack, agcdkqueue.Props{Name: "orders"})
+	orders.Queue().GrantSendMessages(api.Function())
+}
+
+func setupApp(app awscdk.App) {
+	agcdkutil.SetupApp(app, agcdkutil.AppConfig{
+		Prefix:                "fullstack-",
+		DeployersGroup:        "fullstack-deployers",
+		RestrictedDeployments: []string{"Prod"},
+	}, newShared, newDeployment)
+}
+
+func main() {
+	defer jsii.Close()
+
+	app := awscdk.NewApp(nil)
+	setupApp(app)
+	app.Synth(nil)
+}
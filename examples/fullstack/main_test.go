@@ -0,0 +1,107 @@
+//nolint:paralleltest // jsii runtime doesn't support parallel tests
+package main
+
+import (
+	"maps"
+	"slices"
+	"testing"
+
+	"github.com/advdv/ago/agcdk/agcdkqueue"
+	"github.com/advdv/ago/agcdk/agcdkwebapi"
+	"github.com/advdv/ago/examples/internal/synthtest"
+	"github.com/aws/aws-cdk-go/awscdk/v2/cxapi"
+	"github.com/aws/jsii-runtime-go"
+)
+
+// synth synthesizes the app with the context overrides. Bundling is skipped, so the function of
+// the API is not built.
+func synth(t *testing.T, overrides map[string]any) cxapi.CloudAssembly {
+	t.Helper()
+
+	ctx := map[string]any{"aws:cdk:bundling-stacks": []any{}}
+	maps.Copy(ctx, overrides)
+	app := synthtest.NewApp(t, ctx)
+	setupApp(app)
+	return app.Synth(nil)
+}
+
+func TestSynthBeforeDelegation(t *testing.T) {
+	defer jsii.Close()
+
+	assembly := synth(t, nil)
+
+	want := []string{
+		"fullstackEuc1Dev", "fullstackEuc1Prod", "fullstackEuc1Shared",
+		"fullstackEuw1Dev", "fullstackEuw1Prod", "fullstackEuw1Shared",
+	}
+	if got := synthtest.StackNames(assembly); !slices.Equal(got, want) {
+		t.Fatalf("stacks = %v, want %v", got, want)
+	}
+
+	primary := synthtest.Template(t, assembly, "fullstackEuc1Shared")
+	for resourceType, want := range map[string]int{
+		"AWS::Route53::HostedZone":             1,
+		"AWS::ECR::Repository":                 1,
+		"AWS::ECR::ReplicationConfiguration":   1,
+		"AWS::CertificateManager::Certificate": 0,
+	} {
+		if got := len(synthtest.Resources(primary, resourceType)); got != want {
+			t.Errorf("expected %d %s in the primary shared stack, got %d", want, resourceType, got)
+		}
+	}
+
+	secondary := synthtest.Template(t, assembly, "fullstackEuw1Shared")
+	for resourceType, want := range map[string]int{
+		"AWS::Route53::HostedZone":           0,
+		"AWS::ECR::Repository":               1,
+		"AWS::ECR::ReplicationConfiguration": 0,
+	} {
+		if got := len(synthtest.Resources(secondary, resourceType)); got != want {
+			t.Errorf("expected %d %s in the secondary shared stack, got %d", want, resourceType, got)
+		}
+	}
+
+	dev := synthtest.Template(t, assembly, "fullstackEuc1Dev")
+	if n := len(synthtest.Resources(dev, "AWS::ApiGatewayV2::Api")); n != 1 {
+		t.Errorf("expected 1 HTTP API, got %d", n)
+	}
+	if n := len(synthtest.Resources(dev, "AWS::ApiGatewayV2::DomainName")); n != 0 {
+		t.Errorf("expected no custom domain before the delegation, got %d", n)
+	}
+	outputs := synthtest.Outputs(dev)
+	for _, key := range []string{agcdkwebapi.URLOutputKey("api"), agcdkqueue.URLOutputKey("orders")} {
+		if !slices.Contains(outputs, key) {
+			t.Errorf("expected output %s, got %v", key, outputs)
+		}
+	}
+}
+
+func TestSynthAfterDelegation(t *testing.T) {
+	defer jsii.Close()
+
+	assembly := synth(t, map[string]any{"fullstack-dns-delegated": true})
+
+	for _, stackName := range []string{"fullstackEuc1Shared", "fullstackEuw1Shared"} {
+		shared := synthtest.Template(t, assembly, stackName)
+		if n := len(synthtest.Resources(shared, "AWS::CertificateManager::Certificate")); n != 1 {
+			t.Errorf("expected a wildcard certificate in %s, got %d certificates", stackName, n)
+		}
+	}
+
+	for _, stackName := range []string{"fullstackEuc1Dev", "fullstackEuw1Dev"} {
+		dev := synthtest.Template(t, assembly, stackName)
+		domains := synthtest.Resources(dev, "AWS::ApiGatewayV2::DomainName")
+		if len(domains) != 1 {
+			t.Fatalf("expected 1 custom domain in %s, got %d", stackName, len(domains))
+		}
+		for _, domain := range domains {
+			props, _ := domain["Properties"].(map[string]any)
+			if props["DomainName"] != "api-dev.fullstack.example.com" {
+				t.Errorf("domain name in %s = %v, want %q", stackName, props["DomainName"], "api-dev.fullstack.example.com")
+			}
+		}
+		if n := len(synthtest.Resources(dev, "AWS::Route53::RecordSet")); n != 1 {
+			t.Errorf("expected the latency record of the API in %s, got %d records", stackName, n)
+		}
+	}
+}
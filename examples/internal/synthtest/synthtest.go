@@ -0,0 +1,82 @@
+// Package synthtest synthesizes the example apps in tests and inspects their templates.
+//
+// The context of an example comes from its own cdk.json, so the file that documents how the app
+// is configured is also the one that its tests run against.
+package synthtest
+
+import (
+	"encoding/json"
+	"maps"
+	"os"
+	"slices"
+	"testing"
+
+	"github.com/aws/aws-cdk-go/awscdk/v2"
+	"github.com/aws/aws-cdk-go/awscdk/v2/cxapi"
+	"github.com/aws/jsii-runtime-go"
+)
+
+// NewApp returns an app with the context of the cdk.json in the working directory, which is the
+// directory of the example under 'go test'. Overrides replace single context keys, such as the
+// deployer groups of the deployer running the synth.
+func NewApp(tb testing.TB, overrides map[string]any) awscdk.App {
+	tb.Helper()
+
+	data, err := os.ReadFile("cdk.json")
+	if err != nil {
+		tb.Fatalf("failed to read cdk.json: %v", err)
+	}
+	var cdkJSON struct {
+		Context map[string]any `json:"context"`
+	}
+	if err := json.Unmarshal(data, &cdkJSON); err != nil {
+		tb.Fatalf("failed to parse cdk.json: %v", err)
+	}
+
+	ctx := cdkJSON.Context
+	if ctx == nil {
+		ctx = map[string]any{}
+	}
+	maps.Copy(ctx, overrides)
+	return awscdk.NewApp(&awscdk.AppProps{Context: &ctx})
+}
+
+// StackNames returns the sorted names of the stacks in the assembly.
+func StackNames(assembly cxapi.CloudAssembly) []string {
+	var names []string
+	for _, stack := range *assembly.Stacks() {
+		names = append(names, *stack.StackName())
+	}
+	slices.Sort(names)
+	return names
+}
+
+// Template returns the template of the named stack.
+func Template(tb testing.TB, assembly cxapi.CloudAssembly, stackName string) map[string]any {
+	tb.Helper()
+
+	template, ok := assembly.GetStackByName(jsii.String(stackName)).Template().(map[string]any)
+	if !ok {
+		tb.Fatalf("expected the template of %s to be a map", stackName)
+	}
+	return template
+}
+
+// Resources returns the resources of the type in the template, by logical ID.
+func Resources(template map[string]any, resourceType string) map[string]map[string]any {
+	resources, _ := template["Resources"].(map[string]any)
+	found := map[string]map[string]any{}
+	for id, res := range resources {
+		r, _ := res.(map[string]any)
+		if r["Type"] == resourceType {
+			found[id] = r
+		}
+	}
+	return found
+}
+
+// Outputs returns the output keys of the template.
+func Outputs(template map[string]any) []string {
+	outputs, _ := template["Outputs"].(map[string]any)
+	return slices.Sorted(maps.Keys(outputs))
+}
@@ -0,0 +1,62 @@
+// Command multideployment is a CDK app with shared development, staging and production
+// deployments, a deployment of a single developer, and settings per deployment.
+//
+// Stag and Prod are restricted: only members of the multidep-deployers group synthesize their
+// stacks, which 'ago' learns from the IAM groups of the deployer and passes as the
+// multidep-deployer-groups context. Everyone else only gets the Dev and DevAdam stacks.
+//
+// The settings of each deployment are read from deployments.yaml, next to this file. Deployments
+// with the audit-log feature switched on get a protected bucket that 'ago infra cdk deploy'
+// refuses to replace or delete.
+package main
+
+import (
+	"github.com/advdv/ago/agcdk/agcdkqueue"
+	"github.com/advdv/ago/agcdkutil"
+	"github.com/aws/aws-cdk-go/awscdk/v2"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awss3"
+	"github.com/aws/jsii-runtime-go"
+)
+
+// Shared holds the resources of the region that every deployment uses.
+type Shared struct {
+	Artifacts awss3.Bucket
+}
+
+func newShared(stack awscdk.Stack) *Shared {
+	return &Shared{Artifacts: awss3.NewBucket(stack, jsii.String("Artifacts"), &awss3.BucketProps{
+		BlockPublicAccess: awss3.BlockPublicAccess_BLOCK_ALL(),
+		EnforceSSL:        jsii.Bool(true),
+	})}
+}
+
+func newDeployment(stack awscdk.Stack, _ *Shared, deploymentIdent string) {
+	agcdkqueue.New(stack, agcdkqueue.Props{Name: "jobs"})
+
+	if agcdkutil.FeatureEnabled(stack, deploymentIdent, "audit-log") {
+		auditLog := awss3.NewBucket(stack, jsii.String("AuditLog"), &awss3.BucketProps{
+			Versioned:         jsii.Bool(true),
+			BlockPublicAccess: awss3.BlockPublicAccess_BLOCK_ALL(),
+			EnforceSSL:        jsii.Bool(true),
+			RemovalPolicy:     awscdk.RemovalPolicy_RETAIN,
+		})
+		agcdkutil.Protect(auditLog, "audit log of "+deploymentIdent)
+	}
+}
+
+func setupApp(app awscdk.App) {
+	agcdkutil.SetupApp(app, agcdkutil.AppConfig{
+		Prefix:                "multidep-",
+		DeployersGroup:        "multidep-deployers",
+		RestrictedDeployments: []string{"Stag", "Prod"},
+		DeploymentsFile:       "deployments.yaml",
+	}, newShared, newDeployment)
+}
+
+func main() {
+	defer jsii.Close()
+
+	app := awscdk.NewApp(nil)
+	setupApp(app)
+	app.Synth(nil)
+}
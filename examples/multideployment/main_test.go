@@ -0,0 +1,57 @@
+//nolint:paralleltest // jsii runtime doesn't support parallel tests
+package main
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/advdv/ago/agcdkutil"
+	"github.com/advdv/ago/examples/internal/synthtest"
+	"github.com/aws/jsii-runtime-go"
+)
+
+func TestSynthAsDeployer(t *testing.T) {
+	defer jsii.Close()
+
+	app := synthtest.NewApp(t, nil)
+	setupApp(app)
+	assembly := app.Synth(nil)
+
+	want := []string{
+		"multidepEuw1Dev", "multidepEuw1DevAdam", "multidepEuw1Prod", "multidepEuw1Shared", "multidepEuw1Stag",
+	}
+	if got := synthtest.StackNames(assembly); !slices.Equal(got, want) {
+		t.Fatalf("stacks = %v, want %v", got, want)
+	}
+
+	for stackName, auditLog := range map[string]bool{
+		"multidepEuw1Dev":     false,
+		"multidepEuw1DevAdam": false,
+		"multidepEuw1Stag":    true,
+		"multidepEuw1Prod":    true,
+	} {
+		buckets := synthtest.Resources(synthtest.Template(t, assembly, stackName), "AWS::S3::Bucket")
+		if got := len(buckets) == 1; got != auditLog {
+			t.Errorf("audit log in %s = %v, want %v", stackName, got, auditLog)
+		}
+		for id, bucket := range buckets {
+			metadata, _ := bucket["Metadata"].(map[string]any)
+			if metadata[agcdkutil.ProtectedMetadataKey] == nil {
+				t.Errorf("expected bucket %s in %s to be protected", id, stackName)
+			}
+		}
+	}
+}
+
+func TestSynthAsDeveloper(t *testing.T) {
+	defer jsii.Close()
+
+	app := synthtest.NewApp(t, map[string]any{"multidep-deployer-groups": "multidep-developers"})
+	setupApp(app)
+	assembly := app.Synth(nil)
+
+	want := []string{"multidepEuw1Dev", "multidepEuw1DevAdam", "multidepEuw1Shared"}
+	if got := synthtest.StackNames(assembly); !slices.Equal(got, want) {
+		t.Errorf("stacks = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,59 @@
+// Command multiregion is a CDK app that deploys to a primary and a secondary region. Resources
+// that exist once, such as the topic that alerts are published to, live in the primary region,
+// and agcdkutil.Replicate copies their identifiers to the secondary regions.
+//
+// SetupApp creates the stacks of the primary region first, and makes every stack of a secondary
+// region depend on its counterpart in the primary region:
+//
+//	multiUse1Shared <- multiEuw1Shared
+//	multiUse1Dev    <- multiEuw1Dev
+//	multiUse1Prod   <- multiEuw1Prod
+package main
+
+import (
+	"github.com/advdv/ago/agcdk/agcdkqueue"
+	"github.com/advdv/ago/agcdkutil"
+	"github.com/aws/aws-cdk-go/awscdk/v2"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awssns"
+	"github.com/aws/jsii-runtime-go"
+)
+
+// alertsTopicArn names the replicated ARN of the alerts topic.
+const alertsTopicArn = "alerts-topic-arn"
+
+// Shared holds the resources of a region that every deployment uses. Alerts is only set in the
+// primary region.
+type Shared struct {
+	Alerts awssns.Topic
+}
+
+func newShared(stack awscdk.Stack) *Shared {
+	if !agcdkutil.IsPrimaryRegionStack(stack, stack) {
+		return &Shared{}
+	}
+
+	alerts := awssns.NewTopic(stack, jsii.String("Alerts"), &awssns.TopicProps{})
+	agcdkutil.Replicate(stack, alertsTopicArn, alerts.TopicArn())
+	return &Shared{Alerts: alerts}
+}
+
+func newDeployment(stack awscdk.Stack, _ *Shared, _ string) {
+	agcdkqueue.New(stack, agcdkqueue.Props{Name: "jobs"})
+	agcdkutil.Output(stack, "AlertsTopicArn", agcdkutil.ReplicatedValue(stack, alertsTopicArn),
+		agcdkutil.OutputOptions{Description: "Topic in the primary region that alerts are published to"})
+}
+
+func setupApp(app awscdk.App) {
+	agcdkutil.SetupApp(app, agcdkutil.AppConfig{
+		Prefix:         "multi-",
+		DeployersGroup: "multi-deployers",
+	}, newShared, newDeployment)
+}
+
+func main() {
+	defer jsii.Close()
+
+	app := awscdk.NewApp(nil)
+	setupApp(app)
+	app.Synth(nil)
+}
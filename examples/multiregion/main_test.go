@@ -0,0 +1,58 @@
+//nolint:paralleltest // jsii runtime doesn't support parallel tests
+package main
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/advdv/ago/examples/internal/synthtest"
+	"github.com/aws/aws-cdk-go/awscdk/v2"
+	"github.com/aws/jsii-runtime-go"
+)
+
+func TestSynth(t *testing.T) {
+	defer jsii.Close()
+
+	app := synthtest.NewApp(t, nil)
+	setupApp(app)
+	assembly := app.Synth(nil)
+
+	want := []string{
+		"multiEuw1Dev", "multiEuw1Prod", "multiEuw1Shared",
+		"multiUse1Dev", "multiUse1Prod", "multiUse1Shared",
+	}
+	if got := synthtest.StackNames(assembly); !slices.Equal(got, want) {
+		t.Fatalf("stacks = %v, want %v", got, want)
+	}
+
+	primary := synthtest.Template(t, assembly, "multiUse1Shared")
+	if n := len(synthtest.Resources(primary, "AWS::SNS::Topic")); n != 1 {
+		t.Errorf("expected the alerts topic in the primary region, got %d topics", n)
+	}
+	if n := len(synthtest.Resources(primary, "Custom::AWS")); n != 1 {
+		t.Errorf("expected the topic ARN to be copied to 1 secondary region, got %d copies", n)
+	}
+
+	secondary := synthtest.Template(t, assembly, "multiEuw1Shared")
+	if n := len(synthtest.Resources(secondary, "AWS::SNS::Topic")); n != 0 {
+		t.Errorf("expected no topic in the secondary region, got %d", n)
+	}
+
+	for secondaryStack, primaryStack := range map[string]string{
+		"multiEuw1Shared": "multiUse1Shared",
+		"multiEuw1Dev":    "multiUse1Dev",
+		"multiEuw1Prod":   "multiUse1Prod",
+	} {
+		stack := awscdk.Stack_Of(app.Node().FindChild(jsii.String(secondaryStack)))
+		if *stack.Region() != "eu-west-1" {
+			t.Errorf("%s is in %s, want eu-west-1", secondaryStack, *stack.Region())
+		}
+		var deps []string
+		for _, dep := range *stack.Dependencies() {
+			deps = append(deps, *dep.StackName())
+		}
+		if !slices.Contains(deps, primaryStack) {
+			t.Errorf("expected %s to depend on %s, got %v", secondaryStack, primaryStack, deps)
+		}
+	}
+}
@@ -0,0 +1,57 @@
+// Command singleregion is a CDK app that deploys to a single region: a shared stack with a bucket
+// that every deployment uploads to, and a stack per deployment with a queue of its own.
+//
+// Its cdk.json lists no secondary regions, so SetupApp creates one shared stack and one stack
+// per deployment, all in the primary region:
+//
+//	singleEuc1Shared
+//	singleEuc1Dev
+//	singleEuc1Prod
+package main
+
+import (
+	"github.com/advdv/ago/agcdk/agcdkqueue"
+	"github.com/advdv/ago/agcdkutil"
+	"github.com/aws/aws-cdk-go/awscdk/v2"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awss3"
+	"github.com/aws/jsii-runtime-go"
+)
+
+// uploadsBucketKey shares the name of the uploads bucket with the deployment stacks.
+var uploadsBucketKey = agcdkutil.NewShareKey[*string]("UploadsBucketName")
+
+// Shared holds the resources of the region that every deployment uses.
+type Shared struct {
+	Uploads awss3.Bucket
+}
+
+func newShared(stack awscdk.Stack) *Shared {
+	uploads := awss3.NewBucket(stack, jsii.String("Uploads"), &awss3.BucketProps{
+		Encryption:        awss3.BucketEncryption_S3_MANAGED,
+		BlockPublicAccess: awss3.BlockPublicAccess_BLOCK_ALL(),
+		EnforceSSL:        jsii.Bool(true),
+	})
+	agcdkutil.Share(stack, uploadsBucketKey, uploads.BucketName())
+	return &Shared{Uploads: uploads}
+}
+
+func newDeployment(stack awscdk.Stack, _ *Shared, _ string) {
+	agcdkqueue.New(stack, agcdkqueue.Props{Name: "jobs"})
+	agcdkutil.Output(stack, "UploadsBucketName", agcdkutil.Use(stack, uploadsBucketKey),
+		agcdkutil.OutputOptions{Description: "Bucket that the deployment uploads to"})
+}
+
+func setupApp(app awscdk.App) {
+	agcdkutil.SetupApp(app, agcdkutil.AppConfig{
+		Prefix:         "single-",
+		DeployersGroup: "single-deployers",
+	}, newShared, newDeployment)
+}
+
+func main() {
+	defer jsii.Close()
+
+	app := awscdk.NewApp(nil)
+	setupApp(app)
+	app.Synth(nil)
+}
@@ -0,0 +1,42 @@
+//nolint:paralleltest // jsii runtime doesn't support parallel tests
+package main
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/advdv/ago/agcdk/agcdkqueue"
+	"github.com/advdv/ago/examples/internal/synthtest"
+	"github.com/aws/jsii-runtime-go"
+)
+
+func TestSynth(t *testing.T) {
+	defer jsii.Close()
+
+	app := synthtest.NewApp(t, nil)
+	setupApp(app)
+	assembly := app.Synth(nil)
+
+	want := []string{"singleEuc1Dev", "singleEuc1Prod", "singleEuc1Shared"}
+	if got := synthtest.StackNames(assembly); !slices.Equal(got, want) {
+		t.Fatalf("stacks = %v, want %v", got, want)
+	}
+
+	shared := synthtest.Template(t, assembly, "singleEuc1Shared")
+	if n := len(synthtest.Resources(shared, "AWS::S3::Bucket")); n != 1 {
+		t.Errorf("expected 1 bucket in the shared stack, got %d", n)
+	}
+
+	for _, stackName := range []string{"singleEuc1Dev", "singleEuc1Prod"} {
+		deployment := synthtest.Template(t, assembly, stackName)
+		if n := len(synthtest.Resources(deployment, "AWS::SQS::Queue")); n != 2 {
+			t.Errorf("expected a queue and its dead-letter queue in %s, got %d queues", stackName, n)
+		}
+		outputs := synthtest.Outputs(deployment)
+		for _, key := range []string{agcdkqueue.URLOutputKey("jobs"), "UploadsBucketName"} {
+			if !slices.Contains(outputs, key) {
+				t.Errorf("expected output %s in %s, got %v", key, stackName, outputs)
+			}
+		}
+	}
+}